@@ -6,8 +6,13 @@ import (
 	"log/slog"
 	"media-mgmt/lib"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -17,30 +22,151 @@ var analyzeCmd = &cobra.Command{
 	Short: "Analyze video files and generate reports",
 	Long: `Scan a directory for video files, analyze their metadata using ffprobe,
 and generate comprehensive reports in multiple formats (HTML, JSON, CSV, Markdown).
+Including sqlite in --format writes the results to media.db for use with the query
+command. --format replaces the default formats, so list the others alongside it to keep
+them, as in --format html,sqlite.
+
+A .mediamgmtignore file in any scanned directory excludes paths below it using
+.gitignore syntax, e.g. "Home Videos/" or "/work/**/*.mov", with "!" to re-include.
 
 The HTML report includes an interactive React-based interface with sorting,
-filtering, and pagination capabilities.`,
+filtering, and pagination capabilities.
+
+Reports also rank files by the space a transcode is predicted to reclaim at the
+--savings-quality target, and transcode-candidates.txt lists them for use with
+transcode --file-list.
+
+The Markdown and HTML reports are headed by the title, logo, and notes in the
+"report" section of the config file; --title and --notes override them for a
+single run, e.g. --notes "Post-migration snapshot, March 2025".
+
+Saved filters from the "filters" section of the config file and from the
+filters command are offered as one-click views in the HTML report.
+
+Commands listed under "report_hooks" in the config file run after the reports
+are written, with the JSON report on stdin, the output directory as working
+directory, and the generated files in $MEDIA_MGMT_REPORT_PATHS:
+
+  report_hooks:
+    - name: notion
+      command: ["./hooks/push-to-notion.sh", "--database", "media"]
+      timeout: 2m
+      required: false
+
+With --thumbnails N, ffmpeg extracts N keyframes per file into thumbnails/ in the
+output directory, shown under each file name in the HTML report. The report links
+rather than embeds them, so keep the directory together when moving the report.
+
+Files that cannot be analyzed are listed with the failing stage and error in a
+Failed Files section of every report. With --strict, analyze then exits with
+status 2 so automation can detect partial failures; other errors exit with 1.
+Every run writes run_summary.json and run_summary.md to the output directory with
+the input, settings, tool versions, each file's outcome, and totals. --summary-json
+also writes the JSON to another path for cron wrappers and CI jobs.
+
+When a file has several video streams, the primary one is picked by scoring
+codec, resolution, bitrate, and duration, so cover art and thumbnails are not
+mistaken for the main video. Libraries the defaults misjudge, such as MJPEG
+camera footage, can adjust the scores under "heuristics" in the config file:
+
+  heuristics:
+    codec_scores:
+      mjpeg: 95
+    low_bitrate: 20000
+
+--explain-streams FILE prints each of a file's video streams with its score broken
+down by codec, stream order, pixel format, duration, attached picture flag,
+resolution, and bitrate, and the resulting decision, instead of analyzing a
+library. Include its output when reporting a misclassified file.
+
+If ffprobe is not installed but every file has a valid cached analysis in the
+output directory, reports are generated from the cache with a warning.
+
+Analyses are cached in the output directory and reused while a file's size and
+modification time are unchanged. The cache is a JSON file per video by default;
+--cache-backend sqlite keeps it in a single database instead, which stays fast
+for libraries of 100k+ files and moves an existing JSON cache into it. The cache
+is keyed by path, so a renamed or moved file is analyzed again. --cache-key content
+keys it by size, modification time, and a hash of the first and last 64 KiB, so a
+reorganized library reuses its analysis. If two existing files share that key, as
+exact copies do, each is analyzed and cached separately.
+
+--cache-dir keeps the cache outside the output directory, so runs writing reports
+to different directories share one cache. It can also be set with the
+MEDIA_MGMT_CACHE_DIR environment variable, e.g. for a volume mounted into a
+container, or for every run with "dir" under "cache" in the config file:
+
+  cache:
+    dir: /var/cache/media-mgmt
+
+--input also accepts a library on another machine, such as a seedbox, as
+ssh://[user@]host[:port]/path. It is listed with a single find run and each file
+is probed with the remote host's ffprobe over one shared SSH connection, so only
+metadata crosses the network and nothing needs to be mounted. ssh must be able to
+log in without a password prompt, using a key or agent, and the remote host needs
+GNU find and ffprobe.
+
+--input s3://bucket/prefix analyzes media archived to S3 or an S3-compatible store.
+Objects are listed with the S3 API and the local ffprobe reads each one through a
+short-lived presigned URL, fetching only the parts it needs. Credentials and region
+come from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and
+AWS_REGION; set AWS_ENDPOINT_URL for stores such as MinIO or Backblaze B2.
+
+Thumbnails, packet-level bitrates, audio sidecars, .mediamgmtignore files, and
+archive stubs are not available for remote libraries.`,
 	RunE: runAnalyze,
 }
 
 var (
-	inputDir    string
-	outputDir   string
-	parallelism int
-	verbose     bool
-	noCache     bool
+	inputDir        string
+	outputDir       string
+	parallelism     int
+	scanWorkers     int
+	verbose         bool
+	noCache         bool
+	cacheDir        string
+	cacheBackend    string
+	cacheKey        string
+	email           bool
+	noHistory       bool
+	formats         []string
+	savingsQuality  int
+	reportTitle     string
+	reportNotes     string
+	accurateBitrate bool
+	graphPatterns   []string
+	thumbnails      int
+	probeTimeout    time.Duration
+	probeRetries    int
+	strict          bool
+	summaryJSON     string
+	explainStreams  string
 )
 
 func init() {
-	analyzeCmd.Flags().StringVarP(&inputDir, "input", "i", "", "Input directory to scan for video files (required)")
+	analyzeCmd.Flags().StringVarP(&inputDir, "input", "i", "", "Input directory to scan for video files, or ssh://[user@]host[:port]/path or s3://bucket/prefix for a remote library (required)")
 	analyzeCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory for reports (required)")
 	analyzeCmd.Flags().IntVarP(&parallelism, "parallelism", "p", runtime.NumCPU(), "Number of parallel workers")
+	analyzeCmd.Flags().IntVar(&scanWorkers, "scan-workers", lib.DefaultScanWorkers, "Directories to read at once while scanning; raise for slow network mounts")
 	analyzeCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "Enable verbose logging")
 	analyzeCmd.Flags().Bool("no-cache", false, "Disable caching of analysis results")
-
-	// Mark required flags
-	analyzeCmd.MarkFlagRequired("input")
-	analyzeCmd.MarkFlagRequired("output")
+	analyzeCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Keep the analysis cache in this directory instead of the output directory, sharing it between runs (default $"+lib.CacheDirEnv+", then cache.dir in the config file)")
+	analyzeCmd.Flags().StringVar(&cacheBackend, "cache-backend", lib.CacheBackendAuto, "Analysis cache storage: json (a file per video), sqlite (one database, for libraries of 100k+ files; migrates an existing JSON cache), or auto (sqlite once a database exists, otherwise json)")
+	analyzeCmd.Flags().StringVar(&cacheKey, "cache-key", lib.CacheKeyPath, "Key analysis cache entries by file path, or by content (size, modification time, and a hash of the first and last 64 KiB) so moved and renamed files are not analyzed again")
+	analyzeCmd.Flags().BoolVar(&accurateBitrate, "accurate-bitrate", false, "Measure true per-stream bitrates and bitrate variability by reading every packet (slower; roughly one extra read of each file)")
+	analyzeCmd.Flags().StringSliceVar(&graphPatterns, "bitrate-graph", nil, "Embed a bitrate-over-time chart in the HTML report for files matching these globs, e.g. \"*Dune*\" (\"*\" for every file; reads every packet)")
+	analyzeCmd.Flags().DurationVar(&probeTimeout, "probe-timeout", lib.DefaultProbeTimeout, "Give up on an ffprobe run after this long, e.g. for files on a stalled network mount; --accurate-bitrate scans also get the file's running time (0 waits indefinitely)")
+	analyzeCmd.Flags().IntVar(&probeRetries, "probe-retries", lib.DefaultProbeRetries, "Times to retry a failed or timed out ffprobe, waiting 2s, then 4s, and so on between attempts")
+	analyzeCmd.Flags().IntVar(&thumbnails, "thumbnails", 0, "Keyframe thumbnails to extract per file with ffmpeg and show in the HTML report (0 disables)")
+	analyzeCmd.Flags().StringSliceVarP(&formats, "format", "f", lib.DefaultReportFormats, "Report formats to generate: csv, json, md, html, sqlite")
+	analyzeCmd.Flags().BoolVar(&noHistory, "no-history", false, "Disable recording analyses in the operation history")
+	analyzeCmd.Flags().BoolVar(&strict, "strict", false, "Exit with status 2 if any file could not be analyzed, after writing the reports")
+	analyzeCmd.Flags().StringVar(&summaryJSON, "summary-json", "", "Write a machine-readable run summary (status, exit code, counts, bytes, failures, duration) to this path")
+	analyzeCmd.Flags().BoolVar(&email, "email", false, "Email a summary when done using the SMTP settings in the config file")
+	analyzeCmd.Flags().IntVar(&savingsQuality, "savings-quality", lib.DefaultSavingsQuality, "Quality target (0-100) used to predict transcode savings in reports")
+	analyzeCmd.Flags().StringVar(&reportTitle, "title", "", "Report title (overrides report.title in the config file)")
+	analyzeCmd.Flags().StringVar(&reportNotes, "notes", "", "Notes shown under the report title (overrides report.notes in the config file)")
+	analyzeCmd.Flags().StringVar(&explainStreams, "explain-streams", "", "Print how each video stream of this file was scored and classified, instead of analyzing a library")
 }
 
 func runAnalyze(cmd *cobra.Command, args []string) error {
@@ -49,25 +175,182 @@ func runAnalyze(cmd *cobra.Command, args []string) error {
 
 	setupLogging(verbose)
 
+	if explainStreams != "" {
+		return runExplainStreams(explainStreams)
+	}
+	// --input and --output are required unless --explain-streams is given
+	var missing []string
+	for _, name := range []string{"input", "output"} {
+		if !cmd.Flags().Changed(name) {
+			missing = append(missing, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("required flag(s) %s not set", strings.Join(missing, ", "))
+	}
+
+	if err := lib.ValidateReportFormats(formats); err != nil {
+		return err
+	}
+	if err := lib.ValidateCacheBackend(cacheBackend); err != nil {
+		return err
+	}
+	if err := lib.ValidateCacheKey(cacheKey); err != nil {
+		return err
+	}
+	if thumbnails < 0 {
+		return fmt.Errorf("invalid --thumbnails value %d: must be 0 or more", thumbnails)
+	}
+	if scanWorkers < 1 {
+		return fmt.Errorf("invalid --scan-workers value %d: must be at least 1", scanWorkers)
+	}
+	if probeTimeout < 0 {
+		return fmt.Errorf("invalid --probe-timeout value %s: must be 0 or more", probeTimeout)
+	}
+	if probeRetries < 0 {
+		return fmt.Errorf("invalid --probe-retries value %d: must be 0 or more", probeRetries)
+	}
+	if lib.IsRemoteInput(inputDir) {
+		if err := lib.ValidateRemoteInput(inputDir); err != nil {
+			return err
+		}
+		switch {
+		case thumbnails > 0:
+			return fmt.Errorf("--thumbnails cannot be used with a remote --input")
+		case accurateBitrate:
+			return fmt.Errorf("--accurate-bitrate cannot be used with a remote --input")
+		case len(graphPatterns) > 0:
+			return fmt.Errorf("--bitrate-graph cannot be used with a remote --input")
+		case cacheKey == lib.CacheKeyContent:
+			return fmt.Errorf("--cache-key content cannot be used with a remote --input")
+		}
+	}
+	for _, pattern := range graphPatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --bitrate-graph pattern %q: %w", pattern, err)
+		}
+	}
+
 	slog.Info("Starting media analysis",
 		"input", inputDir,
 		"output", outputDir,
 		"parallelism", parallelism)
 
-	ctx := context.Background()
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	branding := config.Report
+	if reportTitle != "" {
+		branding.Title = reportTitle
+	}
+	if reportNotes != "" {
+		branding.Notes = reportNotes
+	}
+	filters, err := lib.LoadSavedFilters(config)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C cancels the analysis and any report hooks still running
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	app := &lib.App{
-		InputDir:    inputDir,
-		OutputDir:   outputDir,
-		Parallelism: parallelism,
-		NoCache:     noCache,
+		InputDir:        inputDir,
+		OutputDir:       outputDir,
+		Parallelism:     parallelism,
+		ScanWorkers:     scanWorkers,
+		NoCache:         noCache,
+		Cache:           lib.CacheOptions{Dir: lib.ResolveCacheDir(cacheDir, config), Backend: cacheBackend, Key: cacheKey},
+		AccurateBitrate: accurateBitrate,
+		GraphPatterns:   graphPatterns,
+		ProbeTimeout:    probeTimeout,
+		ProbeRetries:    probeRetries,
+		Thumbnails:      thumbnails,
+		Formats:         formats,
+		SavingsQuality:  savingsQuality,
+		Branding:        branding,
+		Filters:         filters,
+		Hooks:           config.Hooks,
+		Snapshots:       lib.NewSnapshotStore(lib.DefaultSnapshotPath()),
+	}
+	if !noHistory {
+		app.History = lib.NewHistoryStore(lib.DefaultHistoryPath())
 	}
 
-	if err := app.Run(ctx); err != nil {
-		return fmt.Errorf("analysis failed: %w", err)
+	report := lib.NewRunReport("analyze")
+	recordSettings(cmd, report)
+	report.RecordVersions(ctx, "ffprobe")
+	err = app.Run(ctx)
+	if email {
+		summary := app.Summary
+		if err != nil {
+			summary = &lib.RunSummary{Title: lib.T(lib.MsgAnalysisFailedTitle), Failures: []lib.FileFailure{{File: inputDir, Error: err.Error()}}}
+		}
+		emailSummary(summary)
+	}
+
+	cmdErr := analyzeOutcome(app, err)
+	app.FillRunReport(report)
+	writeRunReport(outputDir, summaryJSON, report, err, cmdErr)
+	if cmdErr == nil {
+		slog.Info("Analysis completed successfully")
+	}
+	return cmdErr
+}
+
+// runExplainStreams prints the score breakdown and decision for each of a file's video streams
+func runExplainStreams(path string) error {
+	if err := lib.CheckFFprobeAvailable(); err != nil {
+		return err
+	}
+	scores, err := lib.NewMediaAnalyzer().ExplainStreams(context.Background(), path)
+	if err != nil {
+		return fmt.Errorf("failed to probe %s: %w", path, err)
+	}
+	if len(scores) == 0 {
+		fmt.Printf("%s has no video streams\n", path)
+		return nil
+	}
+
+	table := lib.NewTable("STREAM", "CODEC", "SIZE", "CODEC PTS", "ORDER", "PIXFMT", "DURATION", "ATTACHED", "RESOLUTION", "BITRATE", "TOTAL", "DECISION")
+	points := func(value float64) string { return fmt.Sprintf("%.1f", value) }
+	for _, score := range scores {
+		table.AddRow(
+			strconv.Itoa(score.Index),
+			score.Codec,
+			fmt.Sprintf("%dx%d", score.Width, score.Height),
+			points(score.CodecScore),
+			points(score.IndexScore),
+			points(score.PixelFormatScore),
+			points(score.DurationScore),
+			points(score.AttachedPicScore),
+			points(score.ResolutionScore),
+			points(score.BitrateScore),
+			points(score.Total),
+			score.Kind,
+		)
+	}
+	if err := renderTable(table, lib.TableFormatText); err != nil {
+		return err
+	}
+	if len(scores) > 1 {
+		fmt.Println("\nThe highest total is the primary stream. Streams flagged as attached pictures are cover art;")
+		fmt.Println("other streams with a codec score of 10 or less or under the small resolution threshold are thumbnails.")
 	}
+	return nil
+}
 
-	slog.Info("Analysis completed successfully")
+// analyzeOutcome is the error analyze exits with: fatal if the run failed, partial if files
+// failed under --strict
+func analyzeOutcome(app *lib.App, err error) error {
+	if err != nil {
+		return fmt.Errorf("analysis failed: %w", err)
+	}
+	if strict && len(app.Failures) > 0 {
+		return &partialFailureError{fmt.Sprintf("%d of the scanned files could not be analyzed (see the Failed Files section of the reports)", len(app.Failures))}
+	}
 	return nil
 }
 
@@ -95,9 +378,13 @@ func setupLogging(verbose bool) {
 	}
 
 	var handler slog.Handler
-	if isTerminal() {
+	switch {
+	case logFormat == "json":
+		opts.ReplaceAttr = jsonLogAttr
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	case logFormat == "auto" && !noColor && isTerminal(os.Stderr):
 		handler = lib.NewColorHandler(os.Stderr, opts)
-	} else {
+	default:
 		handler = slog.NewTextHandler(os.Stderr, opts)
 	}
 
@@ -105,7 +392,16 @@ func setupLogging(verbose bool) {
 	slog.SetDefault(logger)
 }
 
-func isTerminal() bool {
-	fileInfo, _ := os.Stderr.Stat()
-	return (fileInfo.Mode() & os.ModeCharDevice) != 0
+// jsonLogAttr normalizes attributes for JSON logs so shipped fields are consistently typed.
+// Durations are emitted as integer milliseconds under a "_ms" key, e.g. age becomes age_ms.
+func jsonLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindDuration {
+		return slog.Int64(a.Key+"_ms", a.Value.Duration().Milliseconds())
+	}
+	return a
+}
+
+func isTerminal(file *os.File) bool {
+	fileInfo, err := file.Stat()
+	return err == nil && (fileInfo.Mode()&os.ModeCharDevice) != 0
 }
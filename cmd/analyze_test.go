@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestJSONLogAttr(t *testing.T) {
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want slog.Attr
+	}{
+		{"duration", slog.Duration("age", 1500*time.Millisecond), slog.Int64("age_ms", 1500)},
+		{"sub-millisecond duration", slog.Duration("wait", 900*time.Microsecond), slog.Int64("wait_ms", 0)},
+		{"string", slog.String("file", "movie.mkv"), slog.String("file", "movie.mkv")},
+		{"integer", slog.Int64("duration_ms", 42), slog.Int64("duration_ms", 42)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jsonLogAttr(nil, tt.attr); !got.Equal(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{ReplaceAttr: jsonLogAttr})).
+		Info("Probed file", "elapsed", 2*time.Second, "file", "movie.mkv")
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode log record %q: %v", buf.String(), err)
+	}
+	if _, ok := record["elapsed"]; ok || record["elapsed_ms"] != float64(2000) || record["file"] != "movie.mkv" {
+		t.Errorf("Unexpected JSON log record: %s", buf.String())
+	}
+}
+
+func TestLogFormatValidation(t *testing.T) {
+	root := &cobra.Command{Use: "media-mgmt"}
+	AddCommands(root)
+	savedFormat, savedConfig, savedLanguage := logFormat, configPath, language
+	t.Cleanup(func() { logFormat, configPath, language = savedFormat, savedConfig, savedLanguage })
+
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{"auto", false},
+		{"text", false},
+		{"json", false},
+		{"JSON", true},
+		{"xml", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			err := root.ParseFlags([]string{
+				"--config", filepath.Join(t.TempDir(), "config.yaml"),
+				"--lang", "en",
+				"--log-format", tt.format,
+			})
+			if err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+
+			err = root.PersistentPreRunE(root, nil)
+			if tt.wantErr && err == nil {
+				t.Errorf("Expected --log-format %q to be rejected", tt.format)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Expected --log-format %q to be accepted, got %v", tt.format, err)
+			}
+		})
+	}
+}
+
+func TestConfigFreeCommands(t *testing.T) {
+	root := &cobra.Command{Use: "media-mgmt"}
+	AddCommands(root)
+	savedFormat, savedConfig, savedLanguage := logFormat, configPath, language
+	t.Cleanup(func() { logFormat, configPath, language = savedFormat, savedConfig, savedLanguage })
+
+	broken := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(broken, []byte("heuristics: [broken\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := root.ParseFlags([]string{"--config", broken, "--lang", "en"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	tests := []struct {
+		args    []string
+		wantErr bool
+	}{
+		{[]string{"tools", "list"}, false},
+		{[]string{"hw"}, false},
+		{[]string{"analyze"}, true},
+	}
+	for _, tt := range tests {
+		cmd, _, err := root.Find(tt.args)
+		if err != nil {
+			t.Fatalf("Failed to find %v: %v", tt.args, err)
+		}
+		if err := root.PersistentPreRunE(cmd, nil); (err != nil) != tt.wantErr {
+			t.Errorf("%v with a broken config: got %v, want error %v", tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBenchOutcome(t *testing.T) {
+	failed := handbrake.BenchRun{Encoder: "x265", Error: "exit status 3"}
+	if err := benchOutcome([]handbrake.BenchRun{failed, failed}); err == nil {
+		t.Error("Expected an error when every encode failed")
+	}
+	if err := benchOutcome([]handbrake.BenchRun{failed, {Encoder: "vt_h265", OutputBytes: 1 << 20}}); err != nil {
+		t.Errorf("Expected no error when an encode succeeded, got %v", err)
+	}
+}
+
+func TestRetryableFailuresDeduplicates(t *testing.T) {
+	state := t.TempDir()
+	t.Setenv("MEDIA_MGMT_STATE_DIR", state)
+	dir := t.TempDir()
+	movie, show, queued := filepath.Join(dir, "movie.mkv"), filepath.Join(dir, "show.mkv"), filepath.Join(dir, "queued.mkv")
+	for _, file := range []string{movie, show, queued} {
+		if err := os.WriteFile(file, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	history := lib.NewHistoryStore(lib.DefaultHistoryPath())
+	for _, file := range []string{movie, dir + "/./movie.mkv", show, movie, queued} {
+		history.Record(lib.HistoryRecord{FilePath: file, Action: lib.HistoryActionFailed})
+	}
+
+	files, err := retryableFailures(time.Time{}, []string{queued})
+	if err != nil {
+		t.Fatalf("retryableFailures failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected movie.mkv and show.mkv once each, got %v", files)
+	}
+}
@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Move rarely needed files to cold storage, leaving .stub records behind",
+	Long: `Select files from a library by age, size, and path patterns, optionally transcode
+them to a high-compression archival profile, and move them to an archive destination.
+
+Destinations may be a local or mounted directory, an S3 URL (s3://bucket/prefix,
+uploaded with the aws CLI), or an rclone remote (rclone:remote:path). Each archived
+file is replaced by a small <file>.stub record, so analyze reports still list the
+file along with where it was archived to.`,
+	RunE: runArchive,
+}
+
+var (
+	archiveInputDir  string
+	archiveDest      string
+	archiveOlderThan time.Duration
+	archiveMinSize   string
+	archiveMatch     []string
+	archiveTranscode bool
+	archiveQuality   int
+	archiveDryRun    bool
+	archiveVerbose   bool
+)
+
+func init() {
+	archiveCmd.Flags().StringVarP(&archiveInputDir, "input", "i", "", "Library directory to select files from (required)")
+	archiveCmd.Flags().StringVarP(&archiveDest, "dest", "d", "", "Archive destination: directory, s3://bucket/prefix, or rclone:remote:path (required)")
+	archiveCmd.Flags().DurationVar(&archiveOlderThan, "older-than", 0, "Only archive files not modified for at least this long (e.g. 8760h)")
+	archiveCmd.Flags().StringVar(&archiveMinSize, "min-size", "", "Only archive files at least this large (e.g. 2G)")
+	archiveCmd.Flags().StringSliceVar(&archiveMatch, "match", []string{}, "Only archive files whose relative path or name matches one of these globs")
+	archiveCmd.Flags().BoolVar(&archiveTranscode, "transcode", false, "Transcode to a high-compression archival profile before archiving")
+	archiveCmd.Flags().IntVarP(&archiveQuality, "quality", "q", 50, "Video quality for archival transcodes (0-100, higher is better quality)")
+	archiveCmd.Flags().BoolVarP(&archiveDryRun, "dry-run", "n", false, "List the files that would be archived without changing anything")
+	archiveCmd.Flags().BoolVarP(&archiveVerbose, "verbose", "v", false, "Enable verbose logging")
+
+	archiveCmd.MarkFlagRequired("input")
+	archiveCmd.MarkFlagRequired("dest")
+}
+
+func runArchive(cmd *cobra.Command, args []string) error {
+	setupLogging(archiveVerbose)
+
+	rules := lib.ArchiveRules{OlderThan: archiveOlderThan, Match: archiveMatch}
+	if archiveMinSize != "" {
+		minSize, err := lib.ParseSize(archiveMinSize)
+		if err != nil {
+			return fmt.Errorf("invalid --min-size: %w", err)
+		}
+		rules.MinSize = minSize
+	}
+	if rules.OlderThan == 0 && rules.MinSize == 0 && len(rules.Match) == 0 {
+		return fmt.Errorf("at least one of --older-than, --min-size, or --match is required")
+	}
+
+	root, err := filepath.Abs(archiveInputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve input directory: %w", err)
+	}
+
+	dest, err := lib.NewArchiveDestination(archiveDest)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		slog.Info("Received signal, shutting down gracefully", "signal", sig)
+		cancel()
+	}()
+
+	scanner := lib.NewFileScanner(root)
+	videoFiles, err := scanner.ScanVideoFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan video files: %w", err)
+	}
+
+	candidates := lib.SelectArchiveCandidates(root, videoFiles, rules)
+	slog.Info("Selected files for archival", "candidates", len(candidates), "scanned", len(videoFiles))
+
+	if archiveDryRun {
+		for _, file := range candidates {
+			if info, err := os.Stat(file); err == nil {
+				slog.Info("Would archive", "file", file, "size", lib.FormatSize(info.Size()))
+			}
+		}
+		return nil
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if err := lib.CheckFFprobeAvailable(); err != nil {
+		return err
+	}
+	history := lib.NewHistoryStore(lib.DefaultHistoryPath())
+	processor := lib.NewMediaProcessor(1)
+	processor.History = history
+
+	archived := 0
+	for i, file := range candidates {
+		if ctx.Err() != nil {
+			slog.Info("Archival was cancelled by user")
+			return nil
+		}
+		slog.Info("Archiving file", "current", i+1, "total", len(candidates), "file", file)
+
+		if err := archiveFile(ctx, processor, history, dest, root, file); err != nil {
+			slog.Error("Failed to archive file", "file", file, "error", err)
+			continue
+		}
+		archived++
+	}
+
+	slog.Info("Archival complete", "archived", archived, "failed", len(candidates)-archived)
+	return nil
+}
+
+// archiveFile analyzes, optionally transcodes, and uploads a single file
+func archiveFile(ctx context.Context, processor *lib.MediaProcessor, history *lib.HistoryStore, dest lib.ArchiveDestination, root, file string) error {
+	var info *lib.MediaInfo
+	if infos, err := processor.ProcessFiles(ctx, []string{file}); err == nil && len(infos) == 1 {
+		info = infos[0]
+	} else {
+		slog.Warn("Failed to analyze file, stub will only record its size", "file", file)
+	}
+
+	uploadPath := file
+	if archiveTranscode {
+		transcoder := &handbrake.HandBrakeTranscoder{
+			Files:           []string{file},
+			OutputSuffix:    "-archive",
+			Overwrite:       true,
+			Quality:         archiveQuality,
+			StaleTempPolicy: handbrake.StaleTempClean,
+			History:         history,
+		}
+		if err := transcoder.Run(ctx); err != nil {
+			return fmt.Errorf("archival transcode failed: %w", err)
+		}
+		uploadPath = transcoder.OutputPath(file)
+		if _, err := os.Stat(uploadPath); err != nil {
+			return fmt.Errorf("archival transcode produced no output")
+		}
+	}
+
+	stub, err := lib.ArchiveFile(ctx, dest, root, file, uploadPath, info)
+	if err != nil {
+		return err
+	}
+
+	auditAffected(file)
+	history.Record(lib.HistoryRecord{
+		FilePath:     file,
+		Action:       lib.HistoryActionArchived,
+		OriginalSize: stub.ArchivedSize,
+		OutputPath:   stub.ArchivedTo,
+		Params:       map[string]string{"sha256": stub.SHA256, "transcoded": fmt.Sprintf("%t", stub.Transcoded)},
+	})
+
+	slog.Info("Archived file",
+		"file", filepath.Base(file),
+		"archived_to", stub.ArchivedTo,
+		"size", lib.FormatSize(stub.ArchivedSize))
+	return nil
+}
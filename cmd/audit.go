@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"media-mgmt/lib"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the log of commands run against the library",
+	Long: `Show the append-only audit log of tool invocations: who ran each command, when,
+with which flags, whether it succeeded, and which files it created, replaced, moved,
+or removed. Commands run from the CLI, the serve-mode API, and scheduled runs are
+all recorded, so everyone sharing a library can see what changed it.`,
+	Args: cobra.NoArgs,
+	RunE: runAudit,
+}
+
+var (
+	auditPath    string
+	auditSince   time.Duration
+	auditCommand string
+	auditUser    string
+	auditLimit   int
+	auditFormat  string
+)
+
+func init() {
+	auditCmd.Flags().StringVar(&auditPath, "db", lib.DefaultAuditPath(), "Path to the audit log")
+	auditCmd.Flags().DurationVar(&auditSince, "since", 0, "Only show entries from this long ago onward (e.g. 168h)")
+	auditCmd.Flags().StringVar(&auditCommand, "command", "", "Only show entries for commands containing this text")
+	auditCmd.Flags().StringVar(&auditUser, "user", "", "Only show entries by this user")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 50, "Show at most this many recent entries (0 for all)")
+	auditCmd.Flags().StringVarP(&auditFormat, "format", "f", lib.TableFormatText, "Output format: table, tsv, or json (JSON lines)")
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(auditFormat, lib.TableFormatText, lib.TableFormatTSV, "json"); err != nil {
+		return err
+	}
+
+	filter := lib.AuditFilter{Command: auditCommand, User: auditUser, Limit: auditLimit}
+	if auditSince > 0 {
+		filter.Since = time.Now().Add(-auditSince)
+	}
+	entries, err := lib.NewAuditLog(auditPath).Entries(filter)
+	if err != nil {
+		return err
+	}
+
+	if auditFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	table := lib.NewTable("TIME", "USER", "SOURCE", "COMMAND", "RESULT", "FILES", "DURATION")
+	for _, entry := range entries {
+		result := "ok"
+		if entry.Error != "" {
+			result = "error: " + entry.Error
+		}
+		table.AddRow(
+			entry.Timestamp.Local().Format(time.DateTime),
+			entry.User,
+			entry.Source,
+			formatAuditCommand(entry),
+			result,
+			strconv.Itoa(len(entry.Files)),
+			lib.FormatDuration(float64(entry.DurationMS)/1000))
+	}
+	return renderTable(table, auditFormat)
+}
+
+// formatAuditCommand renders an entry's command line from its command, flags, and arguments
+func formatAuditCommand(entry lib.AuditEntry) string {
+	parts := []string{entry.Command}
+
+	names := make([]string, 0, len(entry.Flags))
+	for name := range entry.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, "--"+name+"="+quoteIfNeeded(entry.Flags[name]))
+	}
+	for _, arg := range entry.Args {
+		parts = append(parts, quoteIfNeeded(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteIfNeeded quotes values containing whitespace so rendered command lines stay unambiguous
+func quoteIfNeeded(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"'") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// auditSession tracks the running CLI command so it can be written to the audit log when it exits
+var auditSession struct {
+	mutex   sync.Mutex
+	command *cobra.Command
+	args    []string
+	started time.Time
+	files   []string
+}
+
+// auditSkipped lists commands that only read the audit log or print help, which are not recorded
+var auditSkipped = map[string]bool{"audit": true, "help": true, "completion": true}
+
+// startAudit begins recording the command about to run
+func startAudit(cmd *cobra.Command, args []string) {
+	auditSession.mutex.Lock()
+	defer auditSession.mutex.Unlock()
+	auditSession.command = cmd
+	auditSession.args = args
+	auditSession.started = time.Now()
+}
+
+// auditAffected notes files created, replaced, moved, or removed by the running command
+func auditAffected(paths ...string) {
+	auditSession.mutex.Lock()
+	defer auditSession.mutex.Unlock()
+	auditSession.files = append(auditSession.files, paths...)
+}
+
+// FinishAudit writes the audit entry for the command that just ran, if one was started.
+// Called once Execute returns so failed commands are recorded along with their error.
+func FinishAudit(err error) {
+	auditSession.mutex.Lock()
+	defer auditSession.mutex.Unlock()
+
+	cmd := auditSession.command
+	if cmd == nil || !cmd.HasParent() || auditSkipped[cmd.Name()] {
+		return
+	}
+
+	flags := make(map[string]string)
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		flags[flag.Name] = redactFlag(flag.Name, flag.Value.String())
+	})
+
+	entry := lib.AuditEntry{
+		User:       lib.CurrentUser(),
+		Source:     lib.AuditSourceCLI,
+		Command:    strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" "),
+		Flags:      flags,
+		Args:       auditSession.args,
+		Files:      auditSession.files,
+		DurationMS: time.Since(auditSession.started).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	lib.NewAuditLog(lib.DefaultAuditPath()).Record(entry)
+}
+
+// redactFlag hides values of flags that may carry credentials
+func redactFlag(name, value string) string {
+	lower := strings.ToLower(name)
+	for _, secret := range []string{"password", "token", "secret"} {
+		if strings.Contains(lower, secret) {
+			return "[redacted]"
+		}
+	}
+	return value
+}
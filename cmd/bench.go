@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Compare encoders and qualities on a segment of one file",
+	Long: `Encode the same segment from the middle of a file with every combination of
+--encoders and --qualities, and report each encode's speed, the size its output
+would reach for the whole file, and with --vmaf its VMAF score against the source.
+Settings not compared, such as audio and subtitle handling, are transcode's
+defaults, so sizes can be compared with the savings a batch would make.
+
+Run it on a file typical of the library before a large batch, such as:
+
+  media-mgmt bench --file sample.mkv --encoders x265,vt_h265 --qualities 60,65,70 --vmaf
+
+Speeds are measured in wall time and include HandBrakeCLI's startup, which weighs
+more on short segments; --seconds lengthens the segment. Encodes are written next
+to the file as *.bench-N.mkv and removed once measured. bench exits with an error
+when every encode fails, after reporting why.`,
+	Args: cobra.NoArgs,
+	RunE: runBench,
+}
+
+var (
+	benchFile      string
+	benchEncoders  []string
+	benchQualities []int
+	benchSeconds   float64
+	benchVMAF      bool
+	benchEncPreset string
+	benchHWDecode  string
+	benchFormat    string
+)
+
+func init() {
+	benchCmd.Flags().StringVar(&benchFile, "file", "", "Video file to encode the segment from")
+	benchCmd.Flags().StringSliceVar(&benchEncoders, "encoders", []string{}, "HandBrake encoders to compare, such as x265,x265_10bit,vt_h265 (default: the one transcode would use)")
+	benchCmd.Flags().IntSliceVar(&benchQualities, "qualities", []int{70}, "Video qualities to compare (0-100, higher is better quality)")
+	benchCmd.Flags().Float64Var(&benchSeconds, "seconds", handbrake.DefaultBenchSeconds, "Length in seconds of the segment encoded from the middle of the file")
+	benchCmd.Flags().BoolVar(&benchVMAF, "vmaf", false, "Score each encode against the source with VMAF (requires ffmpeg with libvmaf)")
+	benchCmd.Flags().StringVar(&benchEncPreset, "encoder-preset", "", "Video encoder speed preset for every encode, as with transcode --encoder-preset")
+	benchCmd.Flags().StringVar(&benchHWDecode, "hw-decode", handbrake.HWDecodeAuto, "Hardware decoding of the file, as with transcode --hw-decode")
+	benchCmd.Flags().StringVar(&benchFormat, "format", lib.TableFormatText, "Output format: table, tsv, or json")
+	benchCmd.MarkFlagRequired("file")
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(benchFormat, lib.TableFormatText, lib.TableFormatTSV, "json"); err != nil {
+		return err
+	}
+	for _, quality := range benchQualities {
+		if quality < 0 || quality > 100 {
+			return fmt.Errorf("invalid --qualities value %d: must be between 0 and 100", quality)
+		}
+	}
+	if benchSeconds <= 0 {
+		return fmt.Errorf("invalid --seconds value %g: must be more than 0", benchSeconds)
+	}
+	switch benchHWDecode {
+	case handbrake.HWDecodeAuto, handbrake.HWDecodeVideoToolbox, handbrake.HWDecodeNVDec, handbrake.HWDecodeVAAPI, handbrake.HWDecodeOff:
+	default:
+		return fmt.Errorf("invalid --hw-decode value %q: must be auto, videotoolbox, nvdec, vaapi, or off", benchHWDecode)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	transcoder := &handbrake.HandBrakeTranscoder{
+		Quality:       benchQualities[0],
+		EncoderPreset: benchEncPreset,
+		HWDecode:      benchHWDecode,
+	}
+	runs, err := transcoder.Bench(ctx, benchFile, handbrake.BenchOptions{
+		Encoders:  benchEncoders,
+		Qualities: benchQualities,
+		Seconds:   benchSeconds,
+		VMAF:      benchVMAF,
+	})
+	if err != nil && len(runs) == 0 {
+		return err
+	}
+	if err == nil {
+		err = benchOutcome(runs)
+	}
+
+	if benchFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if encodeErr := encoder.Encode(runs); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	}
+
+	table := lib.NewTable("ENCODER", "QUALITY", "FPS", "TIME", "SEGMENT", "EST. SIZE", "RATIO", "VMAF")
+	for _, run := range runs {
+		if run.Error != "" && run.OutputBytes == 0 {
+			table.AddRow(run.Encoder, strconv.Itoa(run.Quality), "-", "-", "-", "-", "-", "failed: "+run.Error)
+			continue
+		}
+		fps, vmaf := "-", "-"
+		if run.FPS > 0 {
+			fps = fmt.Sprintf("%.1f", run.FPS)
+		}
+		if run.VMAF > 0 {
+			vmaf = fmt.Sprintf("%.2f", run.VMAF)
+		} else if run.Error != "" {
+			vmaf = "failed: " + run.Error
+		}
+		table.AddRow(run.Encoder, strconv.Itoa(run.Quality), fps, lib.FormatDuration(run.Seconds),
+			lib.FormatSize(run.OutputBytes), lib.FormatSize(run.EstimatedSize), fmt.Sprintf("%.0f%%", run.SizeRatio*100), vmaf)
+	}
+	if renderErr := renderTable(table, benchFormat); renderErr != nil {
+		return renderErr
+	}
+	return err
+}
+
+// benchOutcome fails the command when no encode produced output, so scripts notice
+func benchOutcome(runs []handbrake.BenchRun) error {
+	for _, run := range runs {
+		if run.OutputBytes > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("all %d benchmark encodes failed", len(runs))
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and maintain the analysis cache",
+	Long: `Inspect and maintain the analysis cache that analyze and serve keep in the output
+directory, or in the directory given by --cache-dir, $MEDIA_MGMT_CACHE_DIR, or
+cache.dir in the config file.`,
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the cache's size and the last analyze run's hit rate",
+	Long: `Show how many entries the cache holds, how much space it takes, the range of
+analysis dates, and how many files the last analyze run into the output directory
+took from the cache.`,
+	Args: cobra.NoArgs,
+	RunE: runCacheStats,
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove every cache entry, so the next run analyzes every file",
+	Args:  cobra.NoArgs,
+	RunE:  runCacheClear,
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the entries of files that no longer exist",
+	Long: `Remove the cache entries of files that have been deleted, or moved or renamed
+with path cache keys. Entries of remote libraries are kept.`,
+	Args: cobra.NoArgs,
+	RunE: runCachePrune,
+}
+
+var cacheVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that every cache entry can be read and parsed",
+	Long: `Read and parse every cache entry, listing those that are corrupt. Exits with
+status 2 if any are found; corrupt entries are ignored by analyze, which analyzes
+their files again, and are replaced when it does.`,
+	Args: cobra.NoArgs,
+	RunE: runCacheVerify,
+}
+
+var (
+	cacheOutputDir string
+	cacheDirFlag   string
+	cacheFormat    string
+	cacheDryRun    bool
+)
+
+func init() {
+	cacheCmd.PersistentFlags().StringVarP(&cacheOutputDir, "output", "o", ".", "Report directory used with analyze -o, containing the analysis cache")
+	cacheCmd.PersistentFlags().StringVar(&cacheDirFlag, "cache-dir", "", "Analysis cache directory used with analyze --cache-dir (default $"+lib.CacheDirEnv+", then cache.dir in the config file)")
+
+	cacheStatsCmd.Flags().StringVarP(&cacheFormat, "format", "f", lib.TableFormatText, "Output format: table, tsv, or json")
+	cachePruneCmd.Flags().BoolVarP(&cacheDryRun, "dry-run", "n", false, "List the entries that would be removed without removing them")
+
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cacheCmd.AddCommand(cacheVerifyCmd)
+}
+
+// openCache opens the cache the cache subcommands act on
+func openCache() (lib.CacheManager, error) {
+	dir, err := resolveCacheDir(cacheDirFlag)
+	if err != nil {
+		return nil, err
+	}
+	return lib.OpenCache(cacheOutputDir, lib.CacheOptions{Dir: dir})
+}
+
+func runCacheStats(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(cacheFormat, lib.TableFormatText, lib.TableFormatTSV, "json"); err != nil {
+		return err
+	}
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	stats, err := lib.InspectCache(cache)
+	if err != nil {
+		return err
+	}
+
+	// Hits and misses are recorded in the run summary of the last analyze run
+	var lastRun *lib.RunReport
+	if report, err := lib.ReadRunReport(filepath.Join(cacheOutputDir, lib.RunSummaryJSONFilename)); err == nil {
+		if _, ok := report.Counts["cache_hits"]; ok && report.Command == "analyze" {
+			lastRun = report
+		}
+	}
+
+	if cacheFormat == "json" {
+		output := map[string]any{
+			"location": stats.Location,
+			"entries":  stats.Entries,
+			"invalid":  stats.Invalid,
+			"outdated": stats.Outdated,
+			"bytes":    stats.Bytes,
+		}
+		if stats.Entries > 0 {
+			output["oldest"] = stats.Oldest
+			output["newest"] = stats.Newest
+		}
+		if lastRun != nil {
+			output["last_run"] = map[string]any{
+				"finished_at": lastRun.FinishedAt,
+				"hits":        lastRun.Counts["cache_hits"],
+				"misses":      lastRun.Counts["cache_misses"],
+			}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(output)
+	}
+
+	table := lib.NewTable("STAT", "VALUE")
+	table.AddRow("location", stats.Location)
+	table.AddRow("entries", strconv.Itoa(stats.Entries))
+	table.AddRow("invalid entries", strconv.Itoa(stats.Invalid))
+	table.AddRow("outdated entries", strconv.Itoa(stats.Outdated))
+	table.AddRow("size", lib.FormatSize(stats.Bytes))
+	if stats.Entries > 0 {
+		table.AddRow("oldest analysis", stats.Oldest.Local().Format(time.DateTime))
+		table.AddRow("newest analysis", stats.Newest.Local().Format(time.DateTime))
+	}
+	if lastRun != nil {
+		hits, misses := lastRun.Counts["cache_hits"], lastRun.Counts["cache_misses"]
+		rate := "-"
+		if hits+misses > 0 {
+			rate = fmt.Sprintf("%.1f%%", 100*float64(hits)/float64(hits+misses))
+		}
+		table.AddRow("last run", lastRun.FinishedAt.Local().Format(time.DateTime))
+		table.AddRow("last run hits", strconv.Itoa(hits))
+		table.AddRow("last run misses", strconv.Itoa(misses))
+		table.AddRow("last run hit rate", rate)
+	}
+	return renderTable(table, cacheFormat)
+}
+
+func runCacheClear(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	cleared, err := lib.ClearCache(cache)
+	if err != nil {
+		return err
+	}
+	slog.Info("Cleared analysis cache", "entries", cleared, "cache", cache.Location())
+	return nil
+}
+
+func runCachePrune(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	pruned, err := lib.PruneCache(cache, cacheDryRun)
+	for _, file := range pruned {
+		if cacheDryRun {
+			fmt.Println(file)
+		} else {
+			slog.Debug("Removed cache entry of missing file", "file", file)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !cacheDryRun {
+		slog.Info("Pruned analysis cache", "removed", len(pruned), "cache", cache.Location())
+	}
+	return nil
+}
+
+func runCacheVerify(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	cache, err := openCache()
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+
+	checked, problems, err := lib.VerifyCache(cache)
+	if err != nil {
+		return err
+	}
+	for _, problem := range problems {
+		slog.Warn("Invalid cache entry", "key", problem.Key, "error", problem.Error)
+	}
+	slog.Info("Verified analysis cache", "entries", checked, "invalid", len(problems), "cache", cache.Location())
+	if len(problems) > 0 {
+		return &partialFailureError{fmt.Sprintf("%d of %d cache entries are invalid", len(problems), checked)}
+	}
+	return nil
+}
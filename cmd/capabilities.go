@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Show what this build and host support",
+	Long: `Describe what this build and host support: report formats, cache backends,
+remote input schemes, transcode engines, containers and verification levels,
+hardware encoders built into the installed HandBrakeCLI and ffmpeg, and which
+optional integrations are available.
+
+With --json the description is printed as a single JSON object, for scripts and
+orchestration layers that adapt to the host instead of probing it with trial runs.
+Serve mode offers the same object at GET /api/capabilities.`,
+	Args: cobra.NoArgs,
+	RunE: runCapabilities,
+}
+
+var (
+	capabilitiesFormat string
+	capabilitiesJSON   bool
+)
+
+func init() {
+	capabilitiesCmd.Flags().StringVarP(&capabilitiesFormat, "format", "f", lib.TableFormatText, "Output format: table, tsv, or json")
+	capabilitiesCmd.Flags().BoolVar(&capabilitiesJSON, "json", false, "Same as --format json")
+}
+
+// detectFeatures builds the feature report for this host, including transcode options
+func detectFeatures(ctx context.Context) (*lib.FeatureReport, error) {
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	report := lib.DetectFeatures(ctx, config)
+	handbrake.AddFeatures(report)
+	return report, nil
+}
+
+func runCapabilities(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if capabilitiesJSON {
+		capabilitiesFormat = "json"
+	}
+	if err := validateOutputFormat(capabilitiesFormat, lib.TableFormatText, lib.TableFormatTSV, "json"); err != nil {
+		return err
+	}
+
+	report, err := detectFeatures(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if capabilitiesFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	list := func(values []string) string {
+		if len(values) == 0 {
+			return "-"
+		}
+		return strings.Join(values, ", ")
+	}
+	table := lib.NewTable("FEATURE", "SUPPORT")
+	table.AddRow("version", report.Version+" ("+report.GoVersion+", "+report.OS+"/"+report.Arch+")")
+	table.AddRow("report formats", list(report.ReportFormats))
+	table.AddRow("cache backends", list(report.CacheBackends))
+	table.AddRow("remote inputs", list(report.RemoteInputs))
+	table.AddRow("languages", list(report.Languages))
+	table.AddRow("engines", list(report.Engines))
+	table.AddRow("containers", list(report.Containers))
+	table.AddRow("verification", list(report.Verification))
+	table.AddRow("hardware encoders", list(report.HardwareEncoders))
+
+	names := make([]string, 0, len(report.Integrations))
+	for name := range report.Integrations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		support := "yes"
+		if feature := report.Integrations[name]; !feature.Available {
+			support = "no: " + feature.Reason
+		}
+		table.AddRow("integration "+name, support)
+	}
+
+	for _, name := range lib.FeatureTools {
+		support := "not found"
+		if tool := report.Tools[name]; tool != nil {
+			support = tool.Path
+			if tool.Version != "" {
+				support = tool.Version + " at " + tool.Path
+			}
+		}
+		table.AddRow("tool "+name, support)
+	}
+	return renderTable(table, capabilitiesFormat)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean DIR...",
+	Short: "Remove temporary files left behind by interrupted transcodes",
+	Long: `Find and remove the files transcode runs leave behind when they crash or are
+killed: unfinished outputs (*.mkv.tmp and *.mp4.tmp), size estimation encodes
+(*.size-test-N.mkv and *.size-sample.mkv), sample check encodes
+(*.sample-gate.mkv), pieces of chunked encodes (*.chunk-N.mkv), benchmark
+encodes (*.bench-N.mkv), and unfinished sidecar muxes (*.tmp.mux). Directories
+are scanned recursively.
+
+Files modified within --older-than are left alone, since a transcode running now
+may still be writing them. transcode itself finds these files next to the files
+in its batch at startup and handles them as --stale-tmp says; clean covers the
+rest of the library, such as directories no batch will visit again.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runClean,
+}
+
+var (
+	cleanDryRun    bool
+	cleanOlderThan string
+	cleanVerbose   bool
+)
+
+func init() {
+	cleanCmd.Flags().BoolVarP(&cleanDryRun, "dry-run", "n", false, "List the files that would be removed without removing them")
+	cleanCmd.Flags().StringVar(&cleanOlderThan, "older-than", "1h", "Only remove files not modified for this long, such as 36h, 7d, or 2w")
+	cleanCmd.Flags().BoolVarP(&cleanVerbose, "verbose", "v", false, "Enable verbose logging")
+}
+
+func runClean(cmd *cobra.Command, args []string) error {
+	setupLogging(cleanVerbose)
+
+	minAge, err := lib.ParseAge(cleanOlderThan)
+	if err != nil {
+		return fmt.Errorf("invalid --older-than value: %w", err)
+	}
+
+	var artifacts []handbrake.Artifact
+	for _, dir := range args {
+		found, err := handbrake.FindArtifacts(dir, minAge)
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", dir, err)
+		}
+		artifacts = append(artifacts, found...)
+	}
+
+	var removed, failed int
+	var freed int64
+	for _, artifact := range artifacts {
+		if cleanDryRun {
+			fmt.Println(artifact.Path)
+			continue
+		}
+		if err := os.Remove(artifact.Path); err != nil && !os.IsNotExist(err) {
+			slog.Error("Failed to remove file", "file", artifact.Path, "error", err)
+			failed++
+			continue
+		}
+		auditAffected(artifact.Path)
+		slog.Debug("Removed file", "file", artifact.Path, "kind", artifact.Kind, "size", lib.FormatSize(artifact.Size))
+		removed++
+		freed += artifact.Size
+	}
+
+	if cleanDryRun {
+		return nil
+	}
+	slog.Info("Clean complete", "found", len(artifacts), "removed", removed, "freed", lib.FormatSize(freed), "failed", failed)
+	if failed > 0 {
+		return &partialFailureError{fmt.Sprintf("%d of %d files could not be removed", failed, len(artifacts))}
+	}
+	return nil
+}
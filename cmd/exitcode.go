@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"log/slog"
+	"media-mgmt/lib"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Process exit codes, so automation can tell a partly failed batch from one that never ran
+const (
+	ExitSuccess = 0 // Every file was processed
+	ExitFatal   = 1 // The command failed or was misused
+	ExitPartial = 2 // The command finished, but some files failed
+)
+
+// partialFailureError reports a run that finished with failed files
+type partialFailureError struct {
+	message string
+}
+
+func (e *partialFailureError) Error() string {
+	return e.message
+}
+
+// ExitCode maps an error returned by a command to the process exit code
+func ExitCode(err error) int {
+	var partial *partialFailureError
+	switch {
+	case err == nil:
+		return ExitSuccess
+	case errors.As(err, &partial):
+		return ExitPartial
+	default:
+		return ExitFatal
+	}
+}
+
+// recordSettings records the value of every flag of cmd, so a run summary shows exactly how
+// the command was configured, defaults included
+func recordSettings(cmd *cobra.Command, report *lib.RunReport) {
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if flag.Name == "help" {
+			return
+		}
+		report.Settings[flag.Name] = redactFlag(flag.Name, flag.Value.String())
+	})
+}
+
+// writeRunReport finishes report with the command's outcome and writes run_summary.json and
+// run_summary.md into dir, and the JSON to summaryPath if set. Failures are logged rather than
+// returned so the summary never changes the run's outcome.
+func writeRunReport(dir, summaryPath string, report *lib.RunReport, runErr, cmdErr error) {
+	report.Finish(runErr, ExitCode(cmdErr))
+	if err := report.WriteArtifacts(dir); err != nil {
+		slog.Error("Failed to write run summary", "dir", dir, "error", err)
+	} else {
+		slog.Debug("Run summary written", "dir", dir)
+	}
+	if summaryPath == "" {
+		return
+	}
+	if err := report.WriteFile(summaryPath); err != nil {
+		slog.Error("Failed to write run summary", "path", summaryPath, "error", err)
+		return
+	}
+	slog.Info("Run summary written", "path", summaryPath)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"log/slog"
+	"media-mgmt/lib"
+
+	"github.com/spf13/cobra"
+)
+
+var filtersCmd = &cobra.Command{
+	Use:   "filters",
+	Short: "Manage saved filters shown as views in HTML reports",
+	Long: `Save filter expressions under a name so every HTML report offers the same triage
+views, such as "h264 over 8Mbps" or "missing subs". Saved filters are kept in
+filters.json in the state directory (~/.media-mgmt, or MEDIA_MGMT_STATE_DIR).
+
+Filters can also be listed in the "filters" section of the config file, which
+take precedence over saved filters of the same name:
+
+  filters:
+    - name: h264 over 8Mbps
+      expression: codec = 'h264' AND bitrate > 8M
+    - name: missing subs
+      expression: subtitle_tracks = 0
+
+Run a saved filter against the analysis cache with query --saved.`,
+}
+
+var filtersSaveCmd = &cobra.Command{
+	Use:   "save <name> <expression>",
+	Short: "Save a filter expression under a name, replacing any filter with that name",
+	Example: `  media-mgmt filters save "h264 over 8Mbps" "codec = 'h264' AND bitrate > 8M"
+  media-mgmt filters save "missing subs" "subtitle_tracks = 0" --description "Files without subtitles"`,
+	Args: cobra.ExactArgs(2),
+	RunE: runFiltersSave,
+}
+
+var filtersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show saved filters and those from the config file",
+	Args:  cobra.NoArgs,
+	RunE:  runFiltersList,
+}
+
+var filtersDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a saved filter",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFiltersDelete,
+}
+
+var (
+	filterDescription string
+	filtersFormat     string
+)
+
+func init() {
+	filtersSaveCmd.Flags().StringVar(&filterDescription, "description", "", "Description shown with the view in reports")
+
+	filtersListCmd.Flags().StringVarP(&filtersFormat, "format", "f", lib.TableFormatText, "Output format: table or tsv")
+
+	filtersCmd.AddCommand(filtersSaveCmd)
+	filtersCmd.AddCommand(filtersListCmd)
+	filtersCmd.AddCommand(filtersDeleteCmd)
+}
+
+func runFiltersSave(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	filter := lib.SavedFilter{Name: args[0], Expression: args[1], Description: filterDescription}
+	if err := lib.NewSavedFilterStore(lib.DefaultSavedFiltersPath()).Save(filter); err != nil {
+		return err
+	}
+	slog.Info("Saved filter", "name", filter.Name, "expression", filter.Expression)
+	return nil
+}
+
+func runFiltersList(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(filtersFormat, lib.TableFormatText, lib.TableFormatTSV); err != nil {
+		return err
+	}
+
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	stored, err := lib.NewSavedFilterStore(lib.DefaultSavedFiltersPath()).Load()
+	if err != nil {
+		return err
+	}
+
+	configured := make(map[string]bool, len(config.Filters))
+	for _, filter := range config.Filters {
+		configured[filter.Name] = true
+	}
+
+	table := lib.NewTable("NAME", "SOURCE", "EXPRESSION", "DESCRIPTION")
+	for _, filter := range lib.MergeSavedFilters(config.Filters, stored) {
+		source := "saved"
+		if configured[filter.Name] {
+			source = "config"
+		}
+		table.AddRow(filter.Name, source, filter.Expression, filter.Description)
+	}
+	return renderTable(table, filtersFormat)
+}
+
+func runFiltersDelete(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := lib.NewSavedFilterStore(lib.DefaultSavedFiltersPath()).Delete(args[0]); err != nil {
+		return err
+	}
+	slog.Info("Deleted saved filter", "name", args[0])
+	return nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"media-mgmt/lib"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <file>",
+	Short: "Show what the tool has done to a file over time",
+	Long: `Show the operation history recorded for a file: analyses, size estimates, skips,
+transcodes, verifications, replacements, and archival, with their timestamps and
+parameters. Actions on outputs derived from the file are included.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+var (
+	historyPath   string
+	historyJSON   bool
+	historyFormat string
+)
+
+func init() {
+	historyCmd.Flags().StringVar(&historyPath, "db", lib.DefaultHistoryPath(), "Path to the history ledger")
+	historyCmd.Flags().StringVarP(&historyFormat, "format", "f", lib.TableFormatText, "Output format: table, tsv, or json (JSON lines)")
+	historyCmd.Flags().BoolVar(&historyJSON, "json", false, "Print records as JSON lines")
+	historyCmd.Flags().MarkDeprecated("json", "use --format json instead")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if historyJSON {
+		historyFormat = "json"
+	}
+	if err := validateOutputFormat(historyFormat, lib.TableFormatText, lib.TableFormatTSV, "json"); err != nil {
+		return err
+	}
+
+	store := lib.NewHistoryStore(historyPath)
+	records, err := store.RecordsForFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	if historyFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(records) == 0 && historyFormat == lib.TableFormatText {
+		fmt.Println(lib.T(lib.MsgNoHistory, args[0]))
+		return nil
+	}
+
+	table := lib.NewTable("TIME", "ACTION", "DETAILS")
+	for _, record := range records {
+		table.AddRow(
+			record.Timestamp.Local().Format(time.DateTime),
+			string(record.Action),
+			formatHistoryDetails(record))
+	}
+	return renderTable(table, historyFormat)
+}
+
+// formatHistoryDetails renders the notable fields of a history record as key=value pairs
+func formatHistoryDetails(record lib.HistoryRecord) string {
+	var details []string
+	if record.Encoder != "" {
+		details = append(details, "encoder="+record.Encoder)
+	}
+	if record.Quality > 0 && record.Action != lib.HistoryActionAnalyzed {
+		details = append(details, fmt.Sprintf("quality=%d", record.Quality))
+	}
+	if record.Width > 0 && record.Height > 0 {
+		details = append(details, fmt.Sprintf("resolution=%dx%d", record.Width, record.Height))
+	}
+	if record.ElapsedSeconds > 0 {
+		details = append(details, "elapsed="+lib.FormatDuration(record.ElapsedSeconds))
+	}
+	if record.OriginalSize > 0 {
+		details = append(details, "size="+lib.FormatSize(record.OriginalSize))
+	}
+	if record.OutputSize > 0 {
+		details = append(details, "output_size="+lib.FormatSize(record.OutputSize))
+	}
+	if record.OutputPath != "" {
+		details = append(details, "output="+record.OutputPath)
+	}
+
+	keys := make([]string, 0, len(record.Params))
+	for key := range record.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		details = append(details, key+"="+record.Params[key])
+	}
+	return strings.Join(details, " ")
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"media-mgmt/lib"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var hwCmd = &cobra.Command{
+	Use:   "hw",
+	Short: "Show the hardware encoders and decoders the installed tools support",
+	Long: `Probe HandBrakeCLI and ffmpeg for the hardware acceleration they were built with:
+VideoToolbox, NVENC, QSV, VAAPI, and AMF (VCE in HandBrake). For each tool and
+API, list the encoders, the codecs they produce, whether any can encode 10-bit
+video, and whether the tool can decode with it.
+
+A tool built with an API does not mean the hardware is present: an encoder listed
+here can still fail if the GPU or its driver is missing. Results are cached in the
+state directory along with the other tool capabilities, and detected again when a
+tool is upgraded.`,
+	Args: cobra.NoArgs,
+	RunE: runHW,
+}
+
+var hwFormat string
+
+func init() {
+	hwCmd.Flags().StringVarP(&hwFormat, "format", "f", lib.TableFormatText, "Output format: table, tsv, or json")
+}
+
+func runHW(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(hwFormat, lib.TableFormatText, lib.TableFormatTSV, "json"); err != nil {
+		return err
+	}
+
+	caps := lib.DetectCapabilities(cmd.Context(), lib.DefaultCapabilitiesPath(), "HandBrakeCLI", "ffmpeg")
+	report := lib.HardwareReport(caps)
+
+	if hwFormat == "json" {
+		if report == nil {
+			report = []lib.HardwareSupport{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	if caps.Tool("HandBrakeCLI") == nil && caps.Tool("ffmpeg") == nil {
+		return fmt.Errorf("neither HandBrakeCLI nor ffmpeg was found in PATH")
+	}
+	yesNo := func(value bool) string {
+		if value {
+			return "yes"
+		}
+		return "no"
+	}
+	table := lib.NewTable("TOOL", "API", "CODECS", "10-BIT", "DECODE", "ENCODERS")
+	listed := make(map[string]bool)
+	for _, support := range report {
+		listed[support.Tool] = true
+		codecs, encoders := "-", "-"
+		if len(support.Codecs) > 0 {
+			codecs = strings.Join(support.Codecs, ", ")
+			encoders = strings.Join(support.Encoders, ", ")
+		}
+		table.AddRow(support.Tool, support.API, codecs, yesNo(support.TenBit), yesNo(support.Decoding), encoders)
+	}
+	for _, name := range []string{"HandBrakeCLI", "ffmpeg"} {
+		if caps.Tool(name) == nil {
+			table.AddRow(name, "-", "-", "-", "-", "not found")
+		} else if !listed[name] {
+			table.AddRow(name, "-", "-", "-", "-", "no hardware support")
+		}
+	}
+	return renderTable(table, hwFormat)
+}
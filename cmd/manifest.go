@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"path/filepath"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Export a signed integrity manifest of a media library",
+	Long: `Scan a directory for video files and write a manifest containing each file's
+relative path, size, SHA-256 checksum, and key media attributes. The manifest is
+signed with an ed25519 key so it can be archived alongside offline backups and
+later used with verify-manifest to validate a restored library. Files that cannot
+be read are left out of the manifest, logged, and make the command exit with
+status 2.`,
+	RunE: runManifest,
+}
+
+var verifyManifestCmd = &cobra.Command{
+	Use:   "verify-manifest",
+	Short: "Validate a library against a signed integrity manifest",
+	Long: `Check the manifest signature and verify that every file listed in the manifest
+exists under the library root with the recorded size and SHA-256 checksum.`,
+	RunE: runVerifyManifest,
+}
+
+var (
+	manifestInputDir    string
+	manifestOutputPath  string
+	manifestSignKeyPath string
+	manifestParallelism int
+	manifestVerbose     bool
+	manifestSkipProbe   bool
+
+	verifyManifestPath        string
+	verifyManifestRoot        string
+	verifyManifestPublicKey   string
+	verifyManifestParallelism int
+	verifyManifestVerbose     bool
+)
+
+func init() {
+	manifestCmd.Flags().StringVarP(&manifestInputDir, "input", "i", "", "Library directory to include in the manifest (required)")
+	manifestCmd.Flags().StringVarP(&manifestOutputPath, "output", "o", "", "Path to write the manifest JSON (required)")
+	manifestCmd.Flags().StringVarP(&manifestSignKeyPath, "sign-key", "k", filepath.Join(lib.DefaultStateDir(), "manifest.key"), "Signing key file (created if missing)")
+	manifestCmd.Flags().IntVarP(&manifestParallelism, "parallelism", "p", runtime.NumCPU(), "Number of parallel workers")
+	manifestCmd.Flags().BoolVarP(&manifestVerbose, "verbose", "v", false, "Enable verbose logging")
+	manifestCmd.Flags().BoolVar(&manifestSkipProbe, "no-probe", false, "Skip ffprobe analysis and record only sizes and checksums")
+
+	manifestCmd.MarkFlagRequired("input")
+	manifestCmd.MarkFlagRequired("output")
+
+	verifyManifestCmd.Flags().StringVarP(&verifyManifestPath, "manifest", "m", "", "Manifest JSON to verify against (required)")
+	verifyManifestCmd.Flags().StringVarP(&verifyManifestRoot, "root", "r", "", "Library root to verify (defaults to the root recorded in the manifest)")
+	verifyManifestCmd.Flags().StringVar(&verifyManifestPublicKey, "public-key", "", "Hex-encoded public key the manifest must be signed with")
+	verifyManifestCmd.Flags().IntVarP(&verifyManifestParallelism, "parallelism", "p", runtime.NumCPU(), "Number of parallel workers")
+	verifyManifestCmd.Flags().BoolVarP(&verifyManifestVerbose, "verbose", "v", false, "Enable verbose logging")
+
+	verifyManifestCmd.MarkFlagRequired("manifest")
+}
+
+func runManifest(cmd *cobra.Command, args []string) error {
+	setupLogging(manifestVerbose)
+
+	ctx := context.Background()
+
+	root, err := filepath.Abs(manifestInputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve input directory: %w", err)
+	}
+
+	privateKey, created, err := lib.LoadOrCreateSigningKey(manifestSignKeyPath)
+	if err != nil {
+		return err
+	}
+	if created {
+		slog.Info("Generated new manifest signing key", "path", manifestSignKeyPath)
+	}
+
+	scanner := lib.NewFileScanner(root)
+	videoFiles, err := scanner.ScanVideoFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan video files: %w", err)
+	}
+
+	var mediaInfos []*lib.MediaInfo
+	if !manifestSkipProbe {
+		if err := lib.CheckFFprobeAvailable(); err != nil {
+			return err
+		}
+		processor := lib.NewMediaProcessor(manifestParallelism)
+		mediaInfos, err = processor.ProcessFiles(ctx, videoFiles)
+		if err != nil {
+			return fmt.Errorf("failed to process video files: %w", err)
+		}
+	}
+
+	slog.Info("Computing checksums", "files", len(videoFiles))
+	manifest, problems, err := lib.BuildManifest(ctx, root, videoFiles, mediaInfos, manifestParallelism)
+	if err != nil {
+		return fmt.Errorf("failed to build manifest: %w", err)
+	}
+	for _, problem := range problems {
+		slog.Error("File left out of manifest", "file", problem.Path, "reason", problem.Reason)
+	}
+
+	if err := manifest.Sign(privateKey); err != nil {
+		return fmt.Errorf("failed to sign manifest: %w", err)
+	}
+
+	if err := lib.WriteManifest(manifest, manifestOutputPath); err != nil {
+		return err
+	}
+
+	slog.Info("Manifest written",
+		"path", manifestOutputPath,
+		"entries", len(manifest.Entries),
+		"public_key", manifest.PublicKey)
+	if len(problems) > 0 {
+		return &partialFailureError{fmt.Sprintf("%d of %d files could not be read and were left out of the manifest", len(problems), len(videoFiles))}
+	}
+	return nil
+}
+
+func runVerifyManifest(cmd *cobra.Command, args []string) error {
+	setupLogging(verifyManifestVerbose)
+
+	ctx := context.Background()
+
+	manifest, err := lib.ReadManifest(verifyManifestPath)
+	if err != nil {
+		return err
+	}
+
+	var trustedKey ed25519.PublicKey
+	if verifyManifestPublicKey != "" {
+		key, err := hex.DecodeString(verifyManifestPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid --public-key: expected %d hex-encoded bytes", ed25519.PublicKeySize)
+		}
+		trustedKey = key
+	}
+
+	if err := manifest.VerifySignature(trustedKey); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	if trustedKey == nil {
+		slog.Warn("Signature is valid but the signing key was not pinned (use --public-key)", "public_key", manifest.PublicKey)
+	} else {
+		slog.Info("Manifest signature verified", "public_key", manifest.PublicKey)
+	}
+
+	root := verifyManifestRoot
+	if root == "" {
+		root = manifest.Root
+	}
+
+	slog.Info("Verifying files", "root", root, "entries", len(manifest.Entries))
+	problems, err := manifest.VerifyFiles(ctx, root, verifyManifestParallelism)
+	if err != nil {
+		return fmt.Errorf("failed to verify files: %w", err)
+	}
+
+	failed := make(map[string]string, len(problems))
+	for _, problem := range problems {
+		slog.Error("Integrity problem", "file", problem.Path, "reason", problem.Reason)
+		failed[problem.Path] = problem.Reason
+	}
+
+	history := lib.NewHistoryStore(lib.DefaultHistoryPath())
+	for _, entry := range manifest.Entries {
+		result := "ok"
+		if reason, ok := failed[entry.Path]; ok {
+			result = reason
+		}
+		history.Record(lib.HistoryRecord{
+			FilePath: filepath.Join(root, filepath.FromSlash(entry.Path)),
+			Action:   lib.HistoryActionVerified,
+			Params:   map[string]string{"result": result, "manifest": verifyManifestPath},
+		})
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d of %d files failed verification", len(problems), len(manifest.Entries))
+	}
+
+	slog.Info("All files verified successfully", "entries", len(manifest.Entries))
+	return nil
+}
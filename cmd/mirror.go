@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var mirrorCmd = &cobra.Command{
+	Use:   "mirror",
+	Short: "Keep masters untouched and derive a parallel streaming library",
+	Long: `Transcode every file in a master library into a parallel "streaming" tree with the
+same directory layout, leaving the masters untouched. The mapping between each master
+and its streaming copy is kept in the mirror database, so subsequent runs only
+re-transcode masters that are new or have changed, and streaming copies whose master
+was deleted are removed.
+
+Subtitle, .nfo, and artwork files named after a master, such as Movie.en.srt or
+Movie-poster.jpg, are copied next to its streaming copy and renamed after it, and
+directory artwork such as poster.jpg and fanart.jpg is copied into the streaming
+directory, so Kodi and Jellyfin show the same metadata for both libraries. They are
+removed with the streaming copy when the master is deleted. --sidecars off
+leaves them out.`,
+	RunE: runMirror,
+}
+
+var (
+	mirrorInputDir     string
+	mirrorOutputDir    string
+	mirrorOutputSuffix string
+	mirrorQuality      int
+	mirrorDatabase     string
+	mirrorNoPrune      bool
+	mirrorDryRun       bool
+	mirrorNoHistory    bool
+	mirrorVerbose      bool
+	mirrorSidecars     string
+)
+
+func init() {
+	mirrorCmd.Flags().StringVarP(&mirrorInputDir, "input", "i", "", "Master library directory (required)")
+	mirrorCmd.Flags().StringVarP(&mirrorOutputDir, "output", "o", "", "Streaming library directory to write transcodes into (required)")
+	mirrorCmd.Flags().StringVarP(&mirrorOutputSuffix, "suffix", "s", "", "Output file suffix for streaming copies")
+	mirrorCmd.Flags().IntVarP(&mirrorQuality, "quality", "q", 70, "Video quality (0-100, higher is better quality)")
+	mirrorCmd.Flags().StringVar(&mirrorDatabase, "db", lib.DefaultMirrorPath(), "Path to the mirror database")
+	mirrorCmd.Flags().BoolVar(&mirrorNoPrune, "no-prune", false, "Keep streaming copies whose master no longer exists")
+	mirrorCmd.Flags().BoolVarP(&mirrorDryRun, "dry-run", "n", false, "Report what would be transcoded or pruned without changing anything")
+	mirrorCmd.Flags().BoolVar(&mirrorNoHistory, "no-history", false, "Disable recording encode history used for ETA prediction")
+	mirrorCmd.Flags().StringVar(&mirrorSidecars, "sidecars", handbrake.SidecarsAuto, "Copy the subtitle, .nfo, and artwork files of each master to its streaming copy: auto or off")
+	mirrorCmd.Flags().BoolVarP(&mirrorVerbose, "verbose", "v", false, "Enable verbose logging")
+
+	mirrorCmd.MarkFlagRequired("input")
+	mirrorCmd.MarkFlagRequired("output")
+}
+
+func runMirror(cmd *cobra.Command, args []string) error {
+	setupLogging(mirrorVerbose)
+
+	masterRoot, err := filepath.Abs(mirrorInputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve input directory: %w", err)
+	}
+	streamingRoot, err := filepath.Abs(mirrorOutputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	switch mirrorSidecars {
+	case handbrake.SidecarsAuto, handbrake.SidecarsOff:
+	default:
+		return fmt.Errorf("invalid --sidecars value %q: must be auto or off", mirrorSidecars)
+	}
+	if streamingRoot == masterRoot || strings.HasPrefix(streamingRoot, masterRoot+string(filepath.Separator)) {
+		return fmt.Errorf("streaming directory must not be inside the master directory")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		slog.Info("Received signal, shutting down gracefully", "signal", sig)
+		cancel()
+	}()
+
+	store := lib.NewMirrorStore(mirrorDatabase)
+	transcoder := &handbrake.HandBrakeTranscoder{
+		OutputSuffix:    mirrorOutputSuffix,
+		OutputDir:       streamingRoot,
+		InputRoot:       masterRoot,
+		Overwrite:       true,
+		Quality:         mirrorQuality,
+		Sidecars:        mirrorSidecars,
+		StaleTempPolicy: handbrake.StaleTempClean,
+		StaleTempAge:    time.Hour,
+	}
+	if !mirrorNoHistory {
+		transcoder.History = lib.NewHistoryStore(lib.DefaultHistoryPath())
+	}
+
+	scanner := lib.NewFileScanner(masterRoot)
+	masters, err := scanner.ScanVideoFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan master library: %w", err)
+	}
+
+	present := make(map[string]bool, len(masters))
+	var pending []string
+	for _, master := range masters {
+		present[master] = true
+		entry, ok, err := store.Get(master)
+		if err != nil {
+			return err
+		}
+		if ok && entry.Streaming == transcoder.OutputPath(master) && entry.InSync() {
+			continue
+		}
+		pending = append(pending, master)
+	}
+
+	if !mirrorNoPrune {
+		if err := pruneMirror(store, masterRoot, present); err != nil {
+			return err
+		}
+	}
+
+	slog.Info("Mirror status", "masters", len(masters), "out_of_sync", len(pending))
+	if mirrorDryRun {
+		for _, master := range pending {
+			slog.Info("Would transcode", "master", master, "streaming", transcoder.OutputPath(master))
+		}
+		return nil
+	}
+	if len(pending) == 0 {
+		slog.Info("Streaming library is up to date")
+		return nil
+	}
+
+	transcoder.Files = pending
+	transcoder.OnProgress = func(progress handbrake.Progress) {
+		if progress.Stage != handbrake.StageDone {
+			return
+		}
+		auditAffected(transcoder.OutputPath(progress.File))
+		if err := store.Record(progress.File, transcoder.OutputPath(progress.File)); err != nil {
+			slog.Warn("Failed to update mirror database", "master", progress.File, "error", err)
+		}
+	}
+
+	if err := transcoder.Run(ctx); err != nil {
+		if ctx.Err() == context.Canceled {
+			slog.Info("Mirroring was cancelled by user")
+			return nil
+		}
+		return fmt.Errorf("mirroring failed: %w", err)
+	}
+
+	result := transcoder.Result()
+	slog.Info("Mirroring completed", "transcoded", result.Transcoded, "failed", result.Failed)
+	return nil
+}
+
+// pruneMirror removes streaming copies and mappings for masters under root that no longer exist
+func pruneMirror(store *lib.MirrorStore, root string, present map[string]bool) error {
+	entries, err := store.Entries()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if present[entry.Master] || !strings.HasPrefix(entry.Master, root+string(filepath.Separator)) {
+			continue
+		}
+		if _, err := os.Stat(entry.Master); err == nil {
+			continue
+		}
+
+		if mirrorDryRun {
+			slog.Info("Would remove orphaned streaming copy", "streaming", entry.Streaming)
+			continue
+		}
+		// Sidecars are looked up while the streaming copy still claims them by name
+		sidecars, _ := lib.FindMetadataSidecars(entry.Streaming)
+		if err := os.Remove(entry.Streaming); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove orphaned streaming copy", "streaming", entry.Streaming, "error", err)
+			continue
+		}
+		slog.Info("Removed orphaned streaming copy", "streaming", entry.Streaming)
+		auditAffected(entry.Streaming)
+		for _, sidecar := range sidecars {
+			if err := os.Remove(sidecar); err != nil {
+				slog.Warn("Failed to remove orphaned sidecar file", "file", sidecar, "error", err)
+				continue
+			}
+			auditAffected(sidecar)
+		}
+		if err := store.Remove(entry.Master); err != nil {
+			return err
+		}
+	}
+	return nil
+}
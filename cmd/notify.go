@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"log/slog"
+	"media-mgmt/lib"
+)
+
+// emailSummary sends a run summary using the SMTP settings from the config file.
+// Failures are logged rather than returned so a notification problem never fails the run.
+func emailSummary(summary *lib.RunSummary) {
+	if summary == nil {
+		return
+	}
+
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		slog.Error("Failed to load config for summary email", "error", err)
+		return
+	}
+
+	if err := lib.SendSummaryEmail(config.SMTP, summary); err != nil {
+		slog.Error("Failed to send summary email", "error", err)
+		return
+	}
+	slog.Info("Summary email sent", "to", config.SMTP.To)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"fmt"
+	"media-mgmt/lib"
+	"os"
+)
+
+// useColor reports whether terminal output should be colored, honoring --no-color and NO_COLOR
+func useColor() bool {
+	return !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// renderTable prints a table to stdout in the given format (table or tsv)
+func renderTable(table *lib.Table, format string) error {
+	return table.Render(os.Stdout, format, useColor())
+}
+
+// validateOutputFormat checks a --format value against the formats a command supports
+func validateOutputFormat(format string, allowed ...string) error {
+	for _, candidate := range allowed {
+		if format == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --format %q: must be one of %v", format, allowed)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"media-mgmt/lib"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var probeCmd = &cobra.Command{
+	Use:   "probe FILE...",
+	Short: "Analyze individual files and print their media info as JSON",
+	Long: `Analyze the given files with ffprobe and print their media info as a JSON array,
+without scanning a library or writing reports.
+
+With --bitrate-graph, every packet is read to chart the video bitrate over time,
+for spotting starved sections and VBR spikes. The same graph is embedded in the
+HTML report for files selected with analyze --bitrate-graph.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runProbe,
+}
+
+var (
+	probeBitrateGraph    bool
+	probeAccurateBitrate bool
+)
+
+func init() {
+	probeCmd.Flags().BoolVar(&probeBitrateGraph, "bitrate-graph", false, "Include the video bitrate over time, measured from every packet")
+	probeCmd.Flags().BoolVar(&probeAccurateBitrate, "accurate-bitrate", false, "Measure true per-stream bitrates and bitrate variability by reading every packet")
+}
+
+func runProbe(cmd *cobra.Command, args []string) error {
+	if err := lib.CheckFFprobeAvailable(); err != nil {
+		return err
+	}
+
+	analyzer := lib.NewMediaAnalyzer()
+	analyzer.AccurateBitrate = probeAccurateBitrate
+	if probeBitrateGraph {
+		analyzer.GraphPatterns = []string{"*"}
+	}
+
+	ctx := context.Background()
+	infos := make([]*lib.MediaInfo, 0, len(args))
+	for _, path := range args {
+		info, err := analyzer.AnalyzeFile(ctx, path)
+		if err != nil {
+			return fmt.Errorf("failed to probe %s: %w", path, err)
+		}
+		infos = append(infos, info)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(infos)
+}
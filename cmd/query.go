@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query [expression]",
+	Short: "Filter cached analysis results or run SQL against a media.db report",
+	Long: `Filter the analysis cache of a report directory with a small expression language,
+without re-running ffprobe. Conditions compare a field with a value and combine with
+AND, OR, NOT, and parentheses. Text comparisons are case-insensitive and "~" matches
+a substring. Numbers accept K/M/G suffixes, and durations accept values like 90m.
+
+Fields: ` + strings.Join(lib.FilterFieldNames(), ", ") + `
+
+Alternatively, query a SQLite report written by "analyze --format sqlite" with
+--canned (list built-in queries with --list) or --sql for ad-hoc SQL over the
+media, audio_tracks, and subtitle_tracks tables.
+
+Examples:
+  media-mgmt query -o reports "codec = 'h264' AND height >= 1080 AND bitrate > 8M"
+  media-mgmt query -o reports "size > 8G AND NOT audio_language = eng" --format paths
+  media-mgmt query -o reports --saved "h264 over 8Mbps"
+  media-mgmt query -o reports --canned large-h264`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runQuery,
+}
+
+var (
+	queryOutputDir string
+	queryCacheDir  string
+	queryDBPath    string
+	querySQL       string
+	queryCanned    string
+	queryList      bool
+	querySaved     string
+	queryFormat    string
+)
+
+func init() {
+	queryCmd.Flags().StringVarP(&queryOutputDir, "output", "o", ".", "Report directory used with analyze -o, containing the analysis cache and media.db")
+	queryCmd.Flags().StringVar(&queryCacheDir, "cache-dir", "", "Analysis cache directory used with analyze --cache-dir (default $"+lib.CacheDirEnv+", then cache.dir in the config file)")
+	queryCmd.Flags().StringVar(&queryDBPath, "db", "", "Path to the SQLite report database (default <output>/media.db)")
+	queryCmd.Flags().StringVar(&querySQL, "sql", "", "Ad-hoc SQL query to run")
+	queryCmd.Flags().StringVar(&queryCanned, "canned", "", "Name of a built-in SQL query to run")
+	queryCmd.Flags().BoolVar(&queryList, "list", false, "List the built-in SQL queries")
+	queryCmd.Flags().StringVar(&querySaved, "saved", "", "Name of a saved filter to run (see the filters command)")
+	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", "table", "Output format: table, tsv, json, or paths")
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(queryFormat, lib.TableFormatText, lib.TableFormatTSV, "json", "paths"); err != nil {
+		return err
+	}
+
+	if queryList {
+		table := lib.NewTable("NAME", "DESCRIPTION")
+		for _, query := range lib.CannedQueries() {
+			table.AddRow(query.Name, query.Description)
+		}
+		return renderTable(table, tableFormat(queryFormat))
+	}
+
+	statement := querySQL
+	if queryCanned != "" {
+		if statement != "" {
+			return fmt.Errorf("--sql and --canned cannot be combined")
+		}
+		query, ok := lib.FindCannedQuery(queryCanned)
+		if !ok {
+			return fmt.Errorf("unknown canned query %q (see --list)", queryCanned)
+		}
+		statement = query.SQL
+	}
+
+	if querySaved != "" {
+		if statement != "" || len(args) > 0 {
+			return fmt.Errorf("--saved cannot be combined with a filter expression, --sql, or --canned")
+		}
+		config, err := lib.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+		filters, err := lib.LoadSavedFilters(config)
+		if err != nil {
+			return err
+		}
+		filter, ok := lib.FindSavedFilter(filters, querySaved)
+		if !ok {
+			return fmt.Errorf("unknown saved filter %q (see filters list)", querySaved)
+		}
+		return runFilterQuery(filter.Expression)
+	}
+
+	switch {
+	case statement != "" && len(args) > 0:
+		return fmt.Errorf("a filter expression cannot be combined with --sql or --canned")
+	case statement != "":
+		return runSQLQuery(statement)
+	case len(args) > 0:
+		return runFilterQuery(args[0])
+	}
+	return fmt.Errorf("must specify a filter expression, --saved, --sql, --canned, or --list")
+}
+
+// runFilterQuery prints cached media info matching a filter expression
+func runFilterQuery(expression string) error {
+	filter, err := lib.ParseFilterExpr(expression)
+	if err != nil {
+		return fmt.Errorf("invalid filter expression: %w", err)
+	}
+
+	cacheDir, err := resolveCacheDir(queryCacheDir)
+	if err != nil {
+		return err
+	}
+	cache, err := lib.OpenCache(queryOutputDir, lib.CacheOptions{Dir: cacheDir})
+	if err != nil {
+		return err
+	}
+	defer cache.Close()
+	mediaInfos, err := lib.LoadCachedMediaInfos(cache)
+	if err != nil {
+		return err
+	}
+	if len(mediaInfos) == 0 {
+		return fmt.Errorf("no cached analysis results in %s, run analyze -o %s first", cache.Location(), queryOutputDir)
+	}
+
+	matches := make([]*lib.MediaInfo, 0)
+	for _, info := range mediaInfos {
+		if filter.Match(info) {
+			matches = append(matches, info)
+		}
+	}
+
+	switch queryFormat {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(matches)
+	case "paths":
+		for _, info := range matches {
+			fmt.Println(info.FilePath)
+		}
+		return nil
+	}
+
+	table := lib.NewTable("PATH", "CODEC", "RESOLUTION", "BITRATE", "SIZE", "DURATION")
+	for _, info := range matches {
+		table.AddRow(
+			info.FilePath,
+			info.VideoCodec,
+			fmt.Sprintf("%dx%d", info.VideoWidth, info.VideoHeight),
+			fmt.Sprintf("%.1f Mbps", float64(info.VideoBitrate)/1000000),
+			lib.FormatSize(info.FileSize),
+			lib.FormatDuration(info.Duration))
+	}
+	if err := renderTable(table, queryFormat); err != nil {
+		return err
+	}
+	if queryFormat == lib.TableFormatText {
+		fmt.Fprintln(os.Stderr, lib.T(lib.MsgQueryMatched, len(matches), len(mediaInfos)))
+	}
+	return nil
+}
+
+// runSQLQuery runs a statement against the SQLite report database
+func runSQLQuery(statement string) error {
+	dbPath := queryDBPath
+	if dbPath == "" {
+		dbPath = filepath.Join(queryOutputDir, "media.db")
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("media database not found, generate one with analyze --format sqlite: %w", err)
+	}
+	db, err := lib.OpenMediaDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.DB().QueryContext(context.Background(), statement)
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	return printRows(rows, queryFormat)
+}
+
+// printRows writes SQL results as a table, a JSON array of objects, or the first column
+func printRows(rows *sql.Rows, format string) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = strings.ToUpper(column)
+	}
+	table := lib.NewTable(headers...)
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	objects := make([]map[string]interface{}, 0)
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		count++
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+
+		switch format {
+		case "json":
+			row := make(map[string]interface{}, len(columns))
+			for i, column := range columns {
+				row[column] = values[i]
+			}
+			objects = append(objects, row)
+		case "paths":
+			fmt.Println(formatCell(values[0]))
+		default:
+			cells := make([]string, len(values))
+			for i, value := range values {
+				cells[i] = formatCell(value)
+			}
+			table.AddRow(cells...)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(objects)
+	case lib.TableFormatText, lib.TableFormatTSV:
+		if err := renderTable(table, format); err != nil {
+			return err
+		}
+		if format == lib.TableFormatText {
+			fmt.Fprintln(os.Stderr, lib.T(lib.MsgQueryRows, count))
+		}
+	}
+	return nil
+}
+
+// tableFormat maps a command's --format value to a table format, defaulting to aligned text
+func tableFormat(format string) string {
+	if format == lib.TableFormatTSV {
+		return lib.TableFormatTSV
+	}
+	return lib.TableFormatText
+}
+
+// formatCell renders a scanned SQL value for plain-text output
+func formatCell(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
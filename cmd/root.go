@@ -1,8 +1,89 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"fmt"
+	"media-mgmt/lib"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	configPath string
+	logFormat  string
+	noColor    bool
+	language   string
+)
+
+// configFreeCommands are the top-level commands that never classify streams, so they run
+// without loading the config file; a broken config does not stop tools install or help
+var configFreeCommands = map[string]bool{
+	"audit": true, "cache": true, "clean": true, "completion": true, "help": true, "history": true, "hw": true, "tools": true,
+}
+
+// topLevelCommand returns the command directly under the root that cmd belongs to
+func topLevelCommand(cmd *cobra.Command) *cobra.Command {
+	for cmd.HasParent() && cmd.Parent().HasParent() {
+		cmd = cmd.Parent()
+	}
+	return cmd
+}
 
 func AddCommands(rootCmd *cobra.Command) {
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", lib.DefaultConfigPath(), "Path to the YAML config file")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "auto", "Log output format: auto (color on terminals), text, or json")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output (also honors NO_COLOR)")
+	rootCmd.PersistentFlags().StringVar(&language, "lang", "", "Language for CLI and summary messages: "+strings.Join(lib.SupportedLanguages(), ", ")+" (default from MEDIA_MGMT_LANG or the locale)")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		startAudit(cmd, args)
+		if language == "" {
+			language = lib.DetectLanguage()
+		}
+		if err := lib.SetLanguage(language); err != nil {
+			return fmt.Errorf("invalid --lang value: %w", err)
+		}
+		if !configFreeCommands[topLevelCommand(cmd).Name()] {
+			config, err := lib.LoadConfig(configPath)
+			if err != nil {
+				return err
+			}
+			lib.SetHeuristicWeights(config.Heuristics)
+		}
+		switch logFormat {
+		case "auto", "text", "json":
+			return nil
+		}
+		return fmt.Errorf("invalid --log-format value %q: must be auto, text, or json", logFormat)
+	}
+
 	rootCmd.AddCommand(analyzeCmd)
 	rootCmd.AddCommand(transcodeCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(manifestCmd)
+	rootCmd.AddCommand(verifyManifestCmd)
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(mirrorCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(probeCmd)
+	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(filtersCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(toolsCmd)
+	rootCmd.AddCommand(capabilitiesCmd)
+	rootCmd.AddCommand(hwCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(webOptCmd)
+	rootCmd.AddCommand(cleanCmd)
+}
+
+// resolveCacheDir applies $MEDIA_MGMT_CACHE_DIR and cache.dir in the config file to a
+// --cache-dir value
+func resolveCacheDir(flag string) (string, error) {
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		return "", err
+	}
+	return lib.ResolveCacheDir(flag, config), nil
 }
@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"media-mgmt/lib/server"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the interactive report UI against live library data",
+	Long: `Host the same React-based report UI as the HTML report, backed by a live API
+instead of baked-in data. The input directory is rescanned periodically and new or
+changed files are analyzed automatically, so the UI refreshes as the library changes.
+
+POST /analyze probes a single file on demand and returns its media info as JSON.
+Send {"path": "..."} for a file in the library, or upload one as multipart form
+field "file".
+
+GET /api/report?format=csv downloads a report of the live library in any report
+format (csv, json, md, html, or sqlite).
+
+GET /api/capabilities describes what the host supports, as printed by
+"media-mgmt capabilities --json".
+
+Recurring analyze runs that regenerate reports for other libraries can be scheduled
+with cron expressions under "schedules" in the config file. Each run records a
+library snapshot, and a run is skipped if the previous one for that library is
+still in progress.
+
+To restrict access, list API tokens under "tokens" in the config file. Viewer
+tokens may browse and query; only operator tokens may analyze files or enqueue
+transcodes. Clients
+send "Authorization: Bearer <token>"; browsers can open /?token=<token> once.`,
+	RunE: runServe,
+}
+
+var (
+	serveInputDir        string
+	serveOutputDir       string
+	serveAddr            string
+	serveParallelism     int
+	serveVerbose         bool
+	serveNoCache         bool
+	serveCacheDir        string
+	serveCacheBackend    string
+	serveCacheKey        string
+	serveWatchInterval   time.Duration
+	serveRefreshInterval time.Duration
+	serveTranscode       bool
+	serveQuality         int
+	serveMaxSizeRatio    float64
+	serveOutputSuffix    string
+	serveMaxUpload       string
+)
+
+func init() {
+	serveCmd.Flags().StringVarP(&serveInputDir, "input", "i", "", "Input directory to scan for video files (required)")
+	serveCmd.Flags().StringVarP(&serveOutputDir, "output", "o", "", "Directory for the analysis cache (caching disabled if empty and no cache directory is set)")
+	serveCmd.Flags().StringVarP(&serveAddr, "addr", "a", ":8080", "Address to listen on")
+	serveCmd.Flags().IntVarP(&serveParallelism, "parallelism", "p", runtime.NumCPU(), "Number of parallel workers")
+	serveCmd.Flags().BoolVarP(&serveVerbose, "verbose", "v", false, "Enable verbose logging")
+	serveCmd.Flags().BoolVar(&serveNoCache, "no-cache", false, "Disable caching of analysis results")
+	serveCmd.Flags().StringVar(&serveCacheDir, "cache-dir", "", "Directory for the analysis cache, shared with scheduled runs (default $"+lib.CacheDirEnv+", then cache.dir in the config file, then the output directory)")
+	serveCmd.Flags().StringVar(&serveCacheBackend, "cache-backend", lib.CacheBackendAuto, "Analysis cache storage: json, sqlite, or auto (see analyze --help)")
+	serveCmd.Flags().StringVar(&serveCacheKey, "cache-key", lib.CacheKeyPath, "Key analysis cache entries by file path, or by content so moved and renamed files are not analyzed again (see analyze --help)")
+	serveCmd.Flags().DurationVar(&serveWatchInterval, "watch-interval", time.Minute, "How often to rescan the input directory (0 disables)")
+	serveCmd.Flags().DurationVar(&serveRefreshInterval, "refresh-interval", 30*time.Second, "How often the UI polls for updated data (0 disables)")
+	serveCmd.Flags().BoolVar(&serveTranscode, "enable-transcode", false, "Allow queueing transcode jobs via the API, with live progress events")
+	serveCmd.Flags().IntVarP(&serveQuality, "quality", "q", 70, "Video quality for queued transcodes (0-100, higher is better quality)")
+	serveCmd.Flags().Float64VarP(&serveMaxSizeRatio, "max-size-ratio", "m", 0.8, "Maximum output size as fraction of input for queued transcodes (0.0 disables)")
+	serveCmd.Flags().StringVarP(&serveOutputSuffix, "suffix", "s", "-optimized", "Output file suffix for queued transcodes")
+
+	serveCmd.Flags().StringVar(&serveMaxUpload, "max-upload-size", "4G", "Largest upload accepted by POST /analyze (0 disables uploads)")
+
+	serveCmd.MarkFlagRequired("input")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	setupLogging(serveVerbose)
+
+	if err := lib.CheckFFprobeAvailable(); err != nil {
+		return err
+	}
+
+	if err := lib.ValidateCacheBackend(serveCacheBackend); err != nil {
+		return err
+	}
+	if err := lib.ValidateCacheKey(serveCacheKey); err != nil {
+		return err
+	}
+
+	maxUpload, err := lib.ParseSize(serveMaxUpload)
+	if err != nil {
+		return fmt.Errorf("invalid --max-upload-size: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		slog.Info("Received signal, shutting down gracefully", "signal", sig)
+		cancel()
+	}()
+
+	cacheDir, err := resolveCacheDir(serveCacheDir)
+	if err != nil {
+		return err
+	}
+	cacheOptions := lib.CacheOptions{Dir: cacheDir, Backend: serveCacheBackend, Key: serveCacheKey}
+
+	var processor *lib.MediaProcessor
+	if serveNoCache || (serveOutputDir == "" && cacheDir == "") {
+		slog.Debug("Caching disabled, using direct processor")
+		processor = lib.NewMediaProcessor(serveParallelism)
+	} else {
+		cache, err := lib.OpenCache(serveOutputDir, cacheOptions)
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
+		if err := cache.EnsureCacheDir(); err != nil {
+			return fmt.Errorf("failed to create cache directory: %w", err)
+		}
+		slog.Debug("Caching enabled", "cache", cache.Location())
+		processor = lib.NewMediaProcessorWithCache(serveParallelism, cache)
+	}
+
+	history := lib.NewHistoryStore(lib.DefaultHistoryPath())
+	processor.History = history
+
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	filters, err := lib.LoadSavedFilters(config)
+	if err != nil {
+		return err
+	}
+
+	srv := &server.Server{
+		Addr:            serveAddr,
+		InputDir:        serveInputDir,
+		Processor:       processor,
+		WatchInterval:   serveWatchInterval,
+		RefreshInterval: serveRefreshInterval,
+		Schedules:       config.Schedules,
+		Snapshots:       lib.NewSnapshotStore(lib.DefaultSnapshotPath()),
+		Parallelism:     serveParallelism,
+		History:         history,
+		MaxUploadSize:   maxUpload,
+		Audit:           lib.NewAuditLog(lib.DefaultAuditPath()),
+		Tokens:          config.Tokens,
+		Branding:        config.Report,
+		Filters:         filters,
+		Hooks:           config.Hooks,
+		Features:        lib.DetectFeatures(ctx, config),
+		Cache:           cacheOptions,
+	}
+	handbrake.AddFeatures(srv.Features)
+	if len(config.Tokens) == 0 && serveTranscode {
+		slog.Warn("Transcoding is enabled without API tokens, anyone who can reach the server can enqueue jobs")
+	}
+	if len(config.Schedules) > 0 {
+		slog.Info("Scheduled analysis enabled", "schedules", len(config.Schedules))
+	}
+	if serveTranscode {
+		srv.NewTranscoder = func(files []string) *handbrake.HandBrakeTranscoder {
+			return &handbrake.HandBrakeTranscoder{
+				Files:           files,
+				OutputSuffix:    serveOutputSuffix,
+				Quality:         serveQuality,
+				MaxSizeRatio:    serveMaxSizeRatio,
+				Presets:         config.Transcode.Presets,
+				History:         history,
+				StaleTempPolicy: handbrake.StaleTempKeep,
+				StaleTempAge:    time.Hour,
+			}
+		}
+	}
+
+	if err := srv.Run(ctx); err != nil {
+		return fmt.Errorf("serve failed: %w", err)
+	}
+
+	slog.Info("Server stopped")
+	return nil
+}
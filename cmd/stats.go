@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"fmt"
+	"media-mgmt/lib"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Print quick library statistics from cached analysis results",
+	Long: `Summarize a library in the terminal using the analysis cache of a report directory,
+without re-running ffprobe or generating full reports.`,
+}
+
+var statsCodecsCmd = &cobra.Command{
+	Use:   "codecs",
+	Short: "File count and size per video codec",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGroupStats("CODEC", func(info *lib.MediaInfo) string { return info.VideoCodec })
+	},
+}
+
+var statsResolutionsCmd = &cobra.Command{
+	Use:   "resolutions",
+	Short: "File count and size per resolution class",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runGroupStats("RESOLUTION", func(info *lib.MediaInfo) string { return lib.ResolutionClass(info.VideoHeight) })
+	},
+}
+
+var statsBitrateCmd = &cobra.Command{
+	Use:   "bitrate",
+	Short: "Video bitrate distribution",
+	Args:  cobra.NoArgs,
+	RunE:  runBitrateStats,
+}
+
+var statsGrowthCmd = &cobra.Command{
+	Use:   "growth",
+	Short: "Files added per month, by source modification time",
+	Args:  cobra.NoArgs,
+	RunE:  runGrowthStats,
+}
+
+var statsSavingsCmd = &cobra.Command{
+	Use:   "savings",
+	Short: "Files ranked by predicted transcode savings",
+	Long: `Rank files by the space transcoding them is predicted to reclaim at a quality target,
+based on codec, resolution, and video bitrate per pixel. Use --format paths to write a
+file list for transcode --file-list.`,
+	Args: cobra.NoArgs,
+	RunE: runSavingsStats,
+}
+
+var (
+	statsOutputDir   string
+	statsCacheDir    string
+	statsPercentiles bool
+	statsBuckets     int
+	statsFormat      string
+
+	statsSavingsQuality int
+	statsMinSavings     float64
+	statsSavingsLimit   int
+)
+
+// statsBarWidth is the width in characters of the proportional bars in stats tables
+const statsBarWidth = 24
+
+func init() {
+	statsCmd.PersistentFlags().StringVarP(&statsOutputDir, "output", "o", ".", "Report directory used with analyze -o, containing the analysis cache")
+	statsCmd.PersistentFlags().StringVar(&statsCacheDir, "cache-dir", "", "Analysis cache directory used with analyze --cache-dir (default $"+lib.CacheDirEnv+", then cache.dir in the config file)")
+	statsCmd.PersistentFlags().StringVarP(&statsFormat, "format", "f", lib.TableFormatText, "Output format: table or tsv (savings also supports paths)")
+	statsBitrateCmd.Flags().BoolVar(&statsPercentiles, "percentiles", false, "Print bitrate percentiles instead of a histogram")
+	statsBitrateCmd.Flags().IntVar(&statsBuckets, "buckets", 10, "Number of histogram buckets")
+	statsSavingsCmd.Flags().IntVarP(&statsSavingsQuality, "quality", "q", lib.DefaultSavingsQuality, "Quality target (0-100) to predict transcode sizes at")
+	statsSavingsCmd.Flags().Float64Var(&statsMinSavings, "min-savings", lib.DefaultMinSavingsRatio, "Only list files predicted to shrink by at least this fraction")
+	statsSavingsCmd.Flags().IntVarP(&statsSavingsLimit, "limit", "n", 0, "List at most this many files (0 for all)")
+
+	statsCmd.AddCommand(statsCodecsCmd)
+	statsCmd.AddCommand(statsResolutionsCmd)
+	statsCmd.AddCommand(statsBitrateCmd)
+	statsCmd.AddCommand(statsGrowthCmd)
+	statsCmd.AddCommand(statsSavingsCmd)
+}
+
+// loadStatsEntries reads the analysis cache, failing if it is empty.
+// extraFormats are output formats the subcommand supports beyond table and tsv.
+func loadStatsEntries(extraFormats ...string) ([]*lib.CacheEntry, error) {
+	setupLogging(false)
+
+	if err := validateOutputFormat(statsFormat, append([]string{lib.TableFormatText, lib.TableFormatTSV}, extraFormats...)...); err != nil {
+		return nil, err
+	}
+
+	cacheDir, err := resolveCacheDir(statsCacheDir)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := lib.OpenCache(statsOutputDir, lib.CacheOptions{Dir: cacheDir})
+	if err != nil {
+		return nil, err
+	}
+	defer cache.Close()
+	entries, err := cache.LoadEntries()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no cached analysis results in %s, run analyze -o %s first", cache.Location(), statsOutputDir)
+	}
+	return entries, nil
+}
+
+func runGroupStats(column string, key func(info *lib.MediaInfo) string) error {
+	entries, err := loadStatsEntries()
+	if err != nil {
+		return err
+	}
+	mediaInfos := make([]*lib.MediaInfo, len(entries))
+	for i, entry := range entries {
+		mediaInfos[i] = entry.MediaInfo
+	}
+
+	groups := lib.GroupStats(mediaInfos, key)
+	table := statsTable(column, "FILES", "SHARE", "SIZE")
+	for _, group := range groups {
+		share := float64(group.Files) / float64(len(mediaInfos))
+		table.AddRow(group.Key, strconv.Itoa(group.Files), fmt.Sprintf("%.1f%%", share*100), lib.FormatSize(group.Size), bar(share))
+	}
+	return renderTable(table, statsFormat)
+}
+
+func runBitrateStats(cmd *cobra.Command, args []string) error {
+	entries, err := loadStatsEntries()
+	if err != nil {
+		return err
+	}
+
+	var bitrates []float64
+	for _, entry := range entries {
+		if entry.MediaInfo.VideoBitrate > 0 {
+			bitrates = append(bitrates, float64(entry.MediaInfo.VideoBitrate)/1000000)
+		}
+	}
+	if len(bitrates) == 0 {
+		return fmt.Errorf("no cached files have a known video bitrate")
+	}
+	sort.Float64s(bitrates)
+
+	if statsPercentiles {
+		table := lib.NewTable("PERCENTILE", "MBPS")
+		for _, p := range []float64{0, 10, 25, 50, 75, 90, 95, 99, 100} {
+			table.AddRow(fmt.Sprintf("p%g", p), fmt.Sprintf("%.1f", lib.Percentile(bitrates, p)))
+		}
+		return renderTable(table, statsFormat)
+	}
+
+	counts, bounds := lib.Histogram(bitrates, statsBuckets)
+	maxCount := 0
+	for _, count := range counts {
+		maxCount = max(maxCount, count)
+	}
+	table := statsTable("MIN MBPS", "FILES")
+	for i, count := range counts {
+		table.AddRow(fmt.Sprintf("%.1f", bounds[i]), strconv.Itoa(count), bar(float64(count)/float64(maxCount)))
+	}
+	if err := renderTable(table, statsFormat); err != nil {
+		return err
+	}
+	if statsFormat == lib.TableFormatText {
+		fmt.Printf("\n%s\n", lib.T(lib.MsgStatsMedianBitrate, lib.Percentile(bitrates, 50), len(bitrates)))
+	}
+	return nil
+}
+
+func runGrowthStats(cmd *cobra.Command, args []string) error {
+	entries, err := loadStatsEntries()
+	if err != nil {
+		return err
+	}
+
+	points := lib.LibraryGrowth(entries)
+	totals := make([]float64, len(points))
+	table := lib.NewTable("MONTH", "ADDED", "ADDED SIZE", "TOTAL FILES", "TOTAL SIZE")
+	for i, point := range points {
+		totals[i] = float64(point.TotalSize)
+		table.AddRow(point.Month, strconv.Itoa(point.Added), lib.FormatSize(point.AddedSize),
+			strconv.Itoa(point.TotalFiles), lib.FormatSize(point.TotalSize))
+	}
+	if err := renderTable(table, statsFormat); err != nil {
+		return err
+	}
+	if statsFormat == lib.TableFormatText {
+		fmt.Printf("\n%s\n", lib.T(lib.MsgStatsTotalSize, lib.Sparkline(totals)))
+	}
+	return nil
+}
+
+func runSavingsStats(cmd *cobra.Command, args []string) error {
+	entries, err := loadStatsEntries("paths")
+	if err != nil {
+		return err
+	}
+	mediaInfos := make([]*lib.MediaInfo, len(entries))
+	for i, entry := range entries {
+		mediaInfos[i] = entry.MediaInfo
+	}
+
+	report := lib.RecommendTranscodes(mediaInfos, statsSavingsQuality, statsMinSavings)
+	candidates := report.Candidates
+	if statsSavingsLimit > 0 && len(candidates) > statsSavingsLimit {
+		candidates = candidates[:statsSavingsLimit]
+	}
+
+	if statsFormat == "paths" {
+		for _, candidate := range candidates {
+			fmt.Println(candidate.FilePath)
+		}
+		return nil
+	}
+
+	table := lib.NewTable("FILE", "CODEC", "RESOLUTION", "BPP", "SIZE", "PREDICTED", "SAVINGS")
+	for _, candidate := range candidates {
+		table.AddRow(candidate.FilePath, candidate.VideoCodec, candidate.Resolution,
+			fmt.Sprintf("%.2f", candidate.BitsPerPixel), lib.FormatSize(candidate.FileSize),
+			lib.FormatSize(candidate.PredictedSize), lib.FormatSize(candidate.PredictedSavings))
+	}
+	if err := renderTable(table, statsFormat); err != nil {
+		return err
+	}
+	if statsFormat == lib.TableFormatText {
+		fmt.Printf("\n%s\n", lib.T(lib.MsgStatsSavings,
+			len(report.Candidates), lib.FormatSize(report.ReclaimableSize), lib.FormatSize(report.CandidateSize), report.Quality))
+	}
+	return nil
+}
+
+// statsTable creates a table with a trailing bar column when rendering aligned text
+func statsTable(headers ...string) *lib.Table {
+	if statsFormat == lib.TableFormatText {
+		headers = append(headers, "")
+	}
+	return lib.NewTable(headers...)
+}
+
+// bar renders a fraction between 0 and 1 as a horizontal bar of block characters
+func bar(fraction float64) string {
+	return strings.Repeat("█", int(fraction*statsBarWidth+0.5))
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var toolsCmd = &cobra.Command{
+	Use:   "tools",
+	Short: "Manage pinned ffprobe and ffmpeg builds",
+	Long: `Download pinned static builds of external tools into a tools directory
+(~/.media-mgmt/tools, or MEDIA_MGMT_TOOLS_DIR). Binaries in the tools directory are
+preferred over PATH, so every machine and container runs the same builds.
+
+HandBrakeCLI is also picked up from the tools directory if placed there by hand.`,
+}
+
+var toolsInstallCmd = &cobra.Command{
+	Use:   "install [tool...]",
+	Short: "Download pinned tool builds into the tools directory",
+	Long: `Download pinned builds of ffprobe and ffmpeg (or only the named tools) into the
+tools directory. The SHA-256 of each download is recorded in tools.json; reinstalling
+the same version must match the recorded digest, and --sha256 pins a digest up front.
+The digest of each extracted binary is recorded too, and tools list reports binaries
+that no longer match it as modified.`,
+	RunE: runToolsInstall,
+}
+
+var toolsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Show installed tools and where each tool resolves from",
+	Args:  cobra.NoArgs,
+	RunE:  runToolsList,
+}
+
+var toolsRemoveCmd = &cobra.Command{
+	Use:   "remove <tool...>",
+	Short: "Remove managed tools so they resolve from PATH again",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runToolsRemove,
+}
+
+var (
+	toolsVersion string
+	toolsBaseURL string
+	toolsSHA256  []string
+	toolsVerbose bool
+	toolsFormat  string
+)
+
+func init() {
+	toolsInstallCmd.Flags().StringVar(&toolsVersion, "version", lib.PinnedFFmpegVersion, "FFmpeg release to install")
+	toolsInstallCmd.Flags().StringVar(&toolsBaseURL, "base-url", lib.DefaultToolsBaseURL, "Download location of release zips, e.g. an internal mirror")
+	toolsInstallCmd.Flags().StringSliceVar(&toolsSHA256, "sha256", []string{}, "Expected archive digest per tool, as tool=sha256")
+	toolsInstallCmd.Flags().BoolVarP(&toolsVerbose, "verbose", "v", false, "Enable verbose logging")
+
+	toolsListCmd.Flags().StringVarP(&toolsFormat, "format", "f", lib.TableFormatText, "Output format: table or tsv")
+
+	toolsCmd.AddCommand(toolsInstallCmd)
+	toolsCmd.AddCommand(toolsListCmd)
+	toolsCmd.AddCommand(toolsRemoveCmd)
+}
+
+func runToolsInstall(cmd *cobra.Command, args []string) error {
+	setupLogging(toolsVerbose)
+
+	names := args
+	if len(names) == 0 {
+		names = lib.ManagedTools
+	}
+
+	digests := make(map[string]string, len(toolsSHA256))
+	for _, pin := range toolsSHA256 {
+		name, digest, ok := strings.Cut(pin, "=")
+		if !ok || digest == "" {
+			return fmt.Errorf("invalid --sha256 %q: expected tool=sha256", pin)
+		}
+		digests[name] = digest
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	manager := lib.NewToolManager(lib.DefaultToolsDir())
+	for _, name := range names {
+		spec, err := lib.PinnedToolSpec(toolsBaseURL, name, toolsVersion)
+		if err != nil {
+			return err
+		}
+		spec.SHA256 = digests[name]
+
+		slog.Info("Downloading tool", "tool", name, "version", spec.Version, "url", spec.URL)
+		tool, err := manager.Install(ctx, spec)
+		if err != nil {
+			return fmt.Errorf("failed to install %s: %w", name, err)
+		}
+		slog.Info("Installed tool", "tool", tool.Name, "version", tool.Version, "sha256", tool.SHA256, "dir", manager.Dir)
+	}
+	return nil
+}
+
+func runToolsList(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	if err := validateOutputFormat(toolsFormat, lib.TableFormatText, lib.TableFormatTSV); err != nil {
+		return err
+	}
+
+	manager := lib.NewToolManager(lib.DefaultToolsDir())
+	installed, err := manager.Installed()
+	if err != nil {
+		return err
+	}
+	versions := make(map[string]lib.InstalledTool, len(installed))
+	for _, tool := range installed {
+		versions[tool.Name] = tool
+	}
+
+	table := lib.NewTable("TOOL", "SOURCE", "VERSION", "PATH", "SHA256")
+	for _, name := range append(append([]string{}, lib.ManagedTools...), "HandBrakeCLI") {
+		path, managed, err := lib.ResolveTool(name)
+		source := "PATH"
+		switch {
+		case err != nil:
+			source, path = "missing", "-"
+		case managed:
+			source = "managed"
+		}
+
+		version, digest := "-", "-"
+		if tool, ok := versions[name]; ok && managed {
+			version, digest = tool.Version, tool.SHA256
+			if err := manager.Verify(tool); err != nil {
+				slog.Warn("Managed tool does not match its install", "tool", name, "error", err)
+				source = "modified"
+			}
+		} else if ok {
+			slog.Warn("Pinned tool build is missing", "tool", name, "path", manager.BinaryPath(name))
+		}
+		table.AddRow(name, source, version, path, digest)
+	}
+	return renderTable(table, toolsFormat)
+}
+
+func runToolsRemove(cmd *cobra.Command, args []string) error {
+	setupLogging(false)
+
+	manager := lib.NewToolManager(lib.DefaultToolsDir())
+	for _, name := range args {
+		if err := manager.Remove(name); err != nil {
+			return err
+		}
+		slog.Info("Removed tool", "tool", name)
+	}
+	return nil
+}
@@ -3,11 +3,16 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
+	"media-mgmt/lib"
 	"media-mgmt/lib/handbrake"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -18,7 +23,210 @@ var transcodeCmd = &cobra.Command{
 	Long: `Convert one or more video files using HandBrakeCLI with VideoToolbox hardware acceleration.
 Automatically detects HDR content and applies appropriate encoding settings.
 Uses H.265 10-bit for HDR content and H.265 8-bit for SDR content.
-Files are transcoded in-place using temporary .tmp files for safety.`,
+Files are transcoded in-place using temporary .tmp files for safety.
+
+Outputs are Matroska (.mkv) unless --container mp4 is given. MP4 cannot hold PGS
+and other bitmap subtitles, or the lossless audio --passthrough-lossless keeps, so
+files with those are written as Matroska instead, or with --container-fallback
+convert, written as MP4 without the bitmap subtitles and with AAC audio. With
+--container-fallback extract they are converted the same way, and the bitmap
+subtitles are first copied next to the output, PGS as Movie.en.sup and DVD
+subtitles as Movie.en.mks, for players that load external subtitles.
+
+--mux-external-subs adds SRT and SSA/ASS files named after a video to its output
+as subtitle tracks, taking each track's language from the file name, as in
+Movie.en.srt or Movie.ger.forced.ass, and leaving out forced-only or SDH files as
+--subtitles does for embedded tracks. SRT files are read as UTF-8. Files added
+this way are not copied by --sidecars, and --remove-external-subs deletes them
+once the output is in place.
+
+Subtitle, .nfo, and artwork files kept next to a video for Kodi or Jellyfin, such
+as Movie.en.srt, Movie.idx and Movie.sub, Movie.nfo, or Movie-poster.jpg, belong
+to the source by name, so a media server showing the output would not find them.
+--sidecars always copies them next to each output, renamed after it, as
+Movie-optimized.en.srt and so on.
+
+Video is encoded at constant quality, so file sizes vary with the content. For
+devices with fixed capacity, --mode abr --bitrate 4000k encodes at an average
+bitrate instead, making sizes predictable: software encoders make two passes, the
+first a fast analysis pass, and hardware encoders hold the average in one pass.
+Presets' quality settings do not apply to average bitrate encodes.
+
+--encoder-preset, --encoder-tune, and --encoder-profile are passed to HandBrake's
+options of the same names, for example --encoder-preset slow --encoder-tune grain
+for a slower, grain-preserving x265 encode. Valid values depend on the encoder;
+HandBrakeCLI --encoder-preset-list x265 lists them. They apply to every file,
+including HDR10+ and Dolby Vision files that VideoToolbox hands to x265, so choose
+values every encoder in the batch accepts.
+
+Grain takes most of the bits of older films released on disc, so removing it
+before encoding saves the most space on them. Sources are taken for grainy film
+when they run at 24 or 25 fps, were released in 2005 or earlier (by the year in
+their tags or name, such as "Film (1975).mkv"), and carry at least 0.3 bits per
+pixel per frame of video. --denoise nlmeans or hqdn3d filters those sources
+(--denoise-all filters every file). --film-grain 8 instead encodes them as AV1
+with SVT-AV1, which removes the grain and has the player add back synthetic
+grain of the given level; other files are encoded as usual.
+
+Options media-mgmt does not model can be passed with --handbrake-args, appended to
+every HandBrakeCLI encode, including size estimation, and --ffmpeg-args, added as
+output options to the ffmpeg runs that write outputs. Each is split like a shell
+command line. Options that set the input, output, or container are rejected, as
+are ffmpeg codec options, since those runs copy streams, and arguments ffmpeg
+would read as another output file. Commands with extra arguments are logged in
+full.
+
+Decoding 4K HEVC in software can hold back a hardware encoder. --hw-decode auto,
+the default, decodes sources on the same hardware when encoding with VideoToolbox
+or low-power Quick Sync; videotoolbox, nvdec, or vaapi ask for that decoder with
+any encoder, and off decodes in software. HandBrakeCLI and ffmpeg, which decodes
+sources for VMAF scoring, each get it only if their build supports it (see
+media-mgmt hw); HandBrakeCLI cannot decode with VAAPI. --verify decode always
+decodes in software.
+
+Hardware encoders occasionally fail on sources x265 handles fine. With
+--fallback-software, a file whose VideoToolbox or Quick Sync encode fails is
+encoded once more with x265 before it is marked failed, decoding in software
+unless --hw-decode names a decoder and without the --encoder-preset,
+--encoder-tune, and --encoder-profile values, which name the hardware encoder's
+settings. Failures x265 would hit as well, such as a corrupt or copy-protected
+source or a full disk, are not retried.
+
+--chunked-encode 30G is an experimental mode for very large sources on machines
+whose encoder a single HandBrake run cannot keep busy. Sources that large are cut
+with ffmpeg at keyframes, near chapter starts where there are chapters, into
+--chunk-jobs pieces of at least 5 minutes, which are encoded at the same time on
+this machine and joined with mkvmerge, keeping the source's chapters. While they
+exist, the pieces take about as much space next to the source as the source and
+its output together. The crop is found once for the whole source, so every piece
+is cropped the same. The joined output fails the file if its audio and video are
+more than 0.3 seconds apart at any cut or at the end, or its running time differs
+from the source's. MP4 outputs, split titles, and files with --mux-external-subs
+are encoded whole.
+
+Sources of 40 GB or more (see --sample-gate) first get a 60-second sample from
+the middle encoded with the same settings. The full encode only starts if the
+sample decodes cleanly and scores at least --sample-min-vmaf, so a bad quality
+setting or an incompatible stream fails the file in minutes, not hours.
+
+To audit whether a quality setting is transparent, --compare-quality psnr,ssim,vmaf
+scores each output against its source on --compare-samples segments of 20 seconds
+spread over it, once the output is in place. The mean scores are added to the
+file's history record, its entry in the run summary, and the batch summary.
+Scoring takes ffmpeg, with libvmaf for vmaf, and a failed comparison only logs a
+warning.
+
+When an encode fails, the log only shows the last lines HandBrakeCLI printed. With
+--save-encode-logs DIR, the complete output of every HandBrakeCLI and ffmpeg run
+for a file, estimates and verification included, is saved to a compressed log in
+DIR named after the file and when it was started, such as
+Movie.mkv.20261016-111250.log.gz. Read it with zcat or zless.
+
+Rips that concatenate several disc titles, or a playlist of episodes, into one
+file are recognized by chapter numbering that starts over, chapters that are each
+at least 15 minutes long and close to the same length, or a running time of 4
+hours or more. Such files are logged with the reasons; --multi-title skip leaves
+them alone, and --multi-title split encodes each title to its own output, such as
+movie-optimized-title2.mkv, skipping files whose chapters do not show where the
+titles are. A split file is done once every title's output exists; one that
+stopped partway is split again on the next run.
+
+Each file is tagged as a TV episode (named like Show.S01E02 or 1x02, or in a
+season directory), a movie (running an hour or more), or unknown. Presets in the
+transcode section of the config file apply settings by content type and by source
+resolution (2160p, 1080p, 720p, or sd), the first matching preset winning:
+
+  transcode:
+    presets:
+      - name: uhd
+        resolution: 2160p
+        quality: 65
+      - name: tv
+        content_type: episode
+        quality: 62
+      - name: film
+        content_type: movie
+        quality: 72
+      - name: sd
+        resolution: sd
+        quality: 75
+
+A preset setting both content_type and resolution only applies to files matching
+both.
+
+After the batch, the directories it touched are read again to confirm that every
+input is still in place, every transcoded file has a non-empty output, and no
+temporary files were left behind; anything else is logged and listed in the run
+summary as a discrepancy.
+
+Once a file has been transcoded, the progress bar also shows an ETA for the whole
+batch, estimated from how fast the finished files were processed relative to their
+size, and the ETA is logged as each file finishes. A summary of files transcoded,
+skipped, and failed, the space saved, and the wall time is printed at the end;
+each file's time and sizes are recorded in the run summary.
+
+--tui replaces the progress line with a full-screen dashboard showing the queue,
+the current file's progress, recent log lines, and the space saved so far. It
+takes over the terminal when the first file starts and gives it back, followed
+by the summary, when the batch ends or is cancelled with Ctrl-C.
+
+Ctrl-C stops the whole batch. To give up on just the file being processed, press
+a in the dashboard or send the process SIGUSR1 (kill -USR1 PID; the PID is in
+--status-file). Its encode is stopped, its unfinished output removed, along with
+the titles of a split file already written, and the batch moves on to the next
+file, counting the aborted one as skipped.
+
+--interactive analyzes and estimates every file before anything is encoded, then
+lists the files the batch would encode with their size, video codec, estimated
+output size, predicted savings, and predicted encode time. Toggle files by number
+or range (such as 2 4-6), select all or none, then press Enter to start encoding
+the selected files, or q to quit without encoding. Deselected files are counted
+as skipped.
+
+Exits with status 0 when every file was transcoded or skipped, 2 when the batch
+finished but some files failed or discrepancies were found, and 1 when the batch
+could not run.
+Every run writes run_summary.json and run_summary.md, with the inputs, settings,
+tool versions, each file's outcome, and totals, to runs/transcode in the state
+directory. --summary-json also writes the JSON to another path for cron wrappers
+and CI jobs.
+
+For dashboards and scripts watching a long batch, --status-file keeps the batch's
+live progress in a JSON file, replaced atomically so readers never see a partial
+write, and --status-socket answers each connection with the same JSON:
+
+  {"state": "running", "files_done": 3, "files_total": 12, "eta_seconds": 9120,
+   "current": {"file": "...", "stage": "encoding", "percent": 41.2, "fps": 38.5,
+   "eta": "00h21m07s"}}
+
+When the batch ends the file is left with its final state: finished, failed, or
+cancelled.
+
+Each file is leased in leases/ in the state directory while it is estimated and
+encoded, so overlapping runs such as cron jobs over the same file list skip files
+another run is working on. Leases are renewed every 30 seconds; a lease left
+behind by a crashed run is taken over after two minutes.
+
+--file-list - reads the list from stdin, and with -0 entries are separated by NUL
+characters rather than newlines and taken verbatim, so file names with newlines,
+leading spaces, or a leading # survive pipelines such as:
+
+  find /media -name '*.mkv' -size +10G -print0 | media-mgmt transcode -0 --file-list -
+
+Failed files are recorded in the encode history. --retry-failed queues every file
+whose latest attempt failed, optionally only those that failed within --since
+(such as 7d or 12h), alongside any --files or --file-list given. Files that have
+been transcoded or skipped since, and failures retrying cannot fix, such as Dolby
+Vision sources without a base layer, are left out. Files whose output fails
+--verify count as failed, but the output is kept for inspection, so a retry skips
+them until it is removed or --overwrite is given.
+
+HandBrake failures are classified from its exit status and the last lines of its
+output as no_title, drm, disk_full, corrupt_input, invalid_input, crashed, or
+unknown, recorded as the reason in the encode history and as the kind of each
+failure in the run summary and serve mode jobs. Sources without a usable title,
+copy-protected sources, and sources that cannot be opened count as failures
+retrying cannot fix; a full disk and decode errors do not.`,
 	RunE: runTranscode,
 }
 
@@ -27,26 +235,318 @@ var (
 	transcodeFileListPath string
 	transcodeOutputSuffix string
 	transcodeOverwrite    bool
+	transcodePreserveTime bool
+	transcodePreserveOwn  bool
 	transcodeVerbose      bool
 	transcodeQuality      int
+	transcodeRateMode     string
+	transcodeBitrate      string
+	transcodeEncPreset    string
+	transcodeEncTune      string
+	transcodeEncProfile   string
+	transcodeDenoise      string
+	transcodeDenoiseStr   string
+	transcodeDenoiseAll   bool
+	transcodeFilmGrain    int
+	transcodeHBArgs       string
+	transcodeFFmpegArgs   string
 	transcodeMaxSizeRatio float64
+	transcodeNoHistory    bool
+	transcodeStaleTmp     string
+	transcodeStaleTmpAge  time.Duration
+	transcodeEmail        bool
+	transcodeDropComment  bool
+	transcodeMuxSidecars  bool
+	transcodeSidecars     string
+	transcodeExtSubs      bool
+	transcodeRmExtSubs    bool
+	transcodeSubtitles    string
+	transcodeCFR          bool
+	transcodeLossless     bool
+	transcodeLookahead    int
+	transcodeSingleEst    bool
+	transcodeExportQueue  string
+	transcodeVerify       string
+	transcodeMinVMAF      float64
+	transcodeCompare      []string
+	transcodeCompareN     int
+	transcodeEncodeLogs   string
+	transcodeEngine       string
+	transcodeContainer    string
+	transcodeContainerFb  string
+	transcodeSampleGate   string
+	transcodeChunked      string
+	transcodeChunkJobs    int
+	transcodeSampleVMAF   float64
+	transcodeLowPower     bool
+	transcodeLowPowerThr  int
+	transcodeHWDecode     string
+	transcodeFallbackSW   bool
+	transcodeSummaryJSON  string
+	transcodeRetryFailed  bool
+	transcodeRetrySince   string
+	transcodeNullList     bool
+	transcodeStatusFile   string
+	transcodeStatusSocket string
+	transcodeTUI          bool
+	transcodeInteractive  bool
+	transcodeMultiTitle   string
+	transcodeAudioLangs   []string
 )
 
 func init() {
 	transcodeCmd.Flags().StringSliceVarP(&transcodeFiles, "files", "f", []string{}, "Comma-separated list of video files to transcode")
-	transcodeCmd.Flags().StringVarP(&transcodeFileListPath, "file-list", "l", "", "Path to text file containing list of video files (one per line)")
+	transcodeCmd.Flags().StringVarP(&transcodeFileListPath, "file-list", "l", "", "Path to text file containing list of video files (one per line), or - to read the list from stdin")
+	transcodeCmd.Flags().BoolVarP(&transcodeNullList, "null", "0", false, "File list entries are separated by NUL characters instead of newlines, as written by find -print0")
 	transcodeCmd.Flags().StringVarP(&transcodeOutputSuffix, "suffix", "s", "-optimized", "Output file suffix")
 	transcodeCmd.Flags().BoolVarP(&transcodeOverwrite, "overwrite", "o", false, "Overwrite existing output files")
+	transcodeCmd.Flags().BoolVar(&transcodePreserveTime, "preserve-times", false, "Give each output its source's modification and access times, so libraries sorted by date added keep their order")
+	transcodeCmd.Flags().BoolVar(&transcodePreserveOwn, "preserve-owner", false, "Give each output its source's owner, group, permissions, and extended attributes (changing the owner requires root)")
 	transcodeCmd.Flags().BoolVarP(&transcodeVerbose, "verbose", "v", false, "Enable verbose logging")
 	transcodeCmd.Flags().IntVarP(&transcodeQuality, "quality", "q", 70, "Video quality (0-100, higher is better quality)")
+	transcodeCmd.Flags().StringVar(&transcodeRateMode, "mode", handbrake.RateModeQuality, "Rate control: quality (constant quality set by --quality) or abr (average bitrate set by --bitrate, for predictable file sizes)")
+	transcodeCmd.Flags().StringVar(&transcodeBitrate, "bitrate", "", "Average video bitrate for --mode abr, such as 4000k or 4M")
+	transcodeCmd.Flags().StringVar(&transcodeEncPreset, "encoder-preset", "", "Video encoder speed preset passed to HandBrake, such as slow or veryslow for x265, or quality for VideoToolbox (default: the encoder's)")
+	transcodeCmd.Flags().StringVar(&transcodeEncTune, "encoder-tune", "", "Video encoder tuning passed to HandBrake, such as grain or animation for x265")
+	transcodeCmd.Flags().StringVar(&transcodeEncProfile, "encoder-profile", "", "Video encoder profile passed to HandBrake, such as main or main10")
+	transcodeCmd.Flags().StringVar(&transcodeDenoise, "denoise", handbrake.DenoiseOff, "Denoise filter for grainy film sources: off, nlmeans (slow, keeps detail), or hqdn3d (fast)")
+	transcodeCmd.Flags().StringVar(&transcodeDenoiseStr, "denoise-strength", "medium", "Denoise strength: ultralight, light, medium, or strong")
+	transcodeCmd.Flags().BoolVar(&transcodeDenoiseAll, "denoise-all", false, "Denoise every file with --denoise, not only sources detected as grainy film")
+	transcodeCmd.Flags().IntVar(&transcodeFilmGrain, "film-grain", 0, "Encode sources detected as grainy film as AV1 with SVT-AV1 film grain synthesis at this level, 1-50 (0 disables; requires HandBrakeCLI with SVT-AV1)")
+	transcodeCmd.Flags().StringVar(&transcodeHBArgs, "handbrake-args", "", "Extra arguments appended to each HandBrakeCLI encode, quoted as in a shell, such as \"--encopts aq-mode=3\"")
+	transcodeCmd.Flags().StringVar(&transcodeFFmpegArgs, "ffmpeg-args", "", "Extra output arguments for the ffmpeg runs that write outputs (audio sidecar muxing and subtitle extraction), quoted as in a shell")
 	transcodeCmd.Flags().Float64VarP(&transcodeMaxSizeRatio, "max-size-ratio", "m", 0.8, "Maximum output size as fraction of input (0.0 disables)")
+	transcodeCmd.Flags().BoolVar(&transcodeSingleEst, "single-estimate", false, "Cut the size estimation segments into one clip with ffmpeg and encode it in a single HandBrakeCLI run, about 3x faster than encoding them separately")
+	transcodeCmd.Flags().IntVar(&transcodeLookahead, "lookahead", 0, "Files to probe and estimate in the background while the current file encodes, such as 1 (0, the default, processes files one at a time)")
+	transcodeCmd.Flags().BoolVar(&transcodeNoHistory, "no-history", false, "Disable recording encode history used for ETA prediction")
+	transcodeCmd.Flags().StringVar(&transcodeStaleTmp, "stale-tmp", handbrake.StaleTempPrompt, "How to handle stale .tmp outputs and size-test files from interrupted runs: prompt, clean, resume (promote complete outputs, remove the rest), or keep")
+	transcodeCmd.Flags().DurationVar(&transcodeStaleTmpAge, "stale-tmp-age", time.Hour, "Minimum age before a .tmp output is considered stale")
+	transcodeCmd.Flags().BoolVar(&transcodeDropComment, "drop-commentary", false, "Leave out audio tracks detected as commentary (descriptive audio is kept)")
+	transcodeCmd.Flags().StringSliceVar(&transcodeAudioLangs, "keep-audio-langs", nil, "Keep only audio tracks in these languages, such as en,ja (ISO 639 codes); the first track and untagged tracks are always kept")
+	transcodeCmd.Flags().BoolVar(&transcodeLossless, "passthrough-lossless", false, "Copy TrueHD, DTS-HD MA, and FLAC audio instead of re-encoding it, failing the file if the output loses it")
+	transcodeCmd.Flags().BoolVar(&transcodeCFR, "cfr-convert", false, "Produce constant frame rate output for editing applications, snapping to the nearest standard rate")
+	transcodeCmd.Flags().StringVar(&transcodeSubtitles, "subtitles", handbrake.SubtitlePolicyAll, "Subtitle tracks to keep: all, no-sdh (drop SDH), or forced (only forced); forced subtitles are always kept")
+	transcodeCmd.Flags().BoolVar(&transcodeMuxSidecars, "mux-audio-sidecars", false, "Add external audio files named after the video, such as movie.commentary.ac3, to the output (requires ffmpeg)")
+	transcodeCmd.Flags().BoolVar(&transcodeExtSubs, "mux-external-subs", false, "Add SRT and SSA/ASS files named after the video, such as movie.en.srt or movie.en.forced.ass, to the output as subtitle tracks")
+	transcodeCmd.Flags().BoolVar(&transcodeRmExtSubs, "remove-external-subs", false, "With --mux-external-subs, delete the subtitle files once the output holds them")
+	transcodeCmd.Flags().StringVar(&transcodeSidecars, "sidecars", handbrake.SidecarsAuto, "Copy subtitle, .nfo, and artwork files named after each input, such as movie.en.srt or movie-poster.jpg, next to its output, renamed after it: auto (only when the output is in another directory, as with mirror), always, or off")
+	transcodeCmd.Flags().StringVar(&transcodeVerify, "verify", handbrake.VerifyNone, "Check each output in the background while the next file encodes: none, decode (full decode for errors), or vmaf (decode plus a VMAF score; requires ffmpeg with libvmaf)")
+	transcodeCmd.Flags().Float64Var(&transcodeMinVMAF, "min-vmaf", 0, "With --verify vmaf, count files whose output scores below this as failed (0 records the score only)")
+	transcodeCmd.Flags().StringSliceVar(&transcodeCompare, "compare-quality", []string{}, "Score each output against its source with these metrics, recorded in history and reports: psnr, ssim, vmaf (requires ffmpeg; vmaf requires libvmaf)")
+	transcodeCmd.Flags().IntVar(&transcodeCompareN, "compare-samples", 3, "Segments of 20 seconds spread over each output that --compare-quality scores")
+	transcodeCmd.Flags().StringVar(&transcodeEncodeLogs, "save-encode-logs", "", "Save the complete HandBrakeCLI and ffmpeg output of each file to a gzip-compressed log in this directory")
+	transcodeCmd.Flags().StringVar(&transcodeExportQueue, "export-handbrake-queue", "", "Write the planned encodes, after skip checks and size estimation, to a HandBrake GUI queue file instead of encoding")
+	transcodeCmd.Flags().BoolVar(&transcodeLowPower, "low-power", false, "Trade speed for lower heat and fan noise: Quick Sync's low-power encoder where available, VideoToolbox's speed preset on macOS, otherwise x265 capped to --low-power-threads")
+	transcodeCmd.Flags().IntVar(&transcodeLowPowerThr, "low-power-threads", 0, "Encoder threads for x265 in --low-power mode (0 uses half the cores)")
+	transcodeCmd.Flags().StringVar(&transcodeHWDecode, "hw-decode", handbrake.HWDecodeAuto, "Hardware decoding of sources: auto (the hardware of a VideoToolbox or low-power Quick Sync encoder), videotoolbox, nvdec, vaapi (ffmpeg only), or off")
+	transcodeCmd.Flags().BoolVar(&transcodeFallbackSW, "fallback-software", false, "Retry a file whose hardware encode fails once with the x265 software encoder before marking it failed")
+	transcodeCmd.Flags().StringVar(&transcodeEngine, "engine", handbrake.EngineHandBrake, "Encoding engine: handbrake, or avfoundation for simple H.265 MP4 exports with macOS's built-in avconvert when HandBrake isn't installed (reads MP4 and MOV only; --quality is replaced by a fixed preset, and audio and subtitle options are unavailable)")
+	transcodeCmd.Flags().StringVar(&transcodeContainer, "container", handbrake.ContainerMKV, "Output container: mkv, or mp4 for devices that cannot play Matroska")
+	transcodeCmd.Flags().StringVar(&transcodeContainerFb, "container-fallback", handbrake.ContainerFallbackMKV, "With --container mp4, how to handle files with PGS or other bitmap subtitles, or lossless audio kept by --passthrough-lossless: mkv (write them as Matroska), convert (drop bitmap subtitles and re-encode the audio to AAC), or extract (convert, saving bitmap subtitles as separate files)")
+	transcodeCmd.Flags().StringVar(&transcodeChunked, "chunked-encode", "0", "Experimental: encode sources at least this large, such as 30G, as parallel chunks joined with mkvmerge (0 disables; requires ffmpeg and mkvmerge)")
+	transcodeCmd.Flags().IntVar(&transcodeChunkJobs, "chunk-jobs", 2, "Chunks to split each --chunked-encode source into and encode at the same time (at least 2)")
+	transcodeCmd.Flags().StringVar(&transcodeSampleGate, "sample-gate", "40G", "Before encoding sources at least this large, encode a 60-second sample and require it to decode cleanly and reach --sample-min-vmaf (0 disables)")
+	transcodeCmd.Flags().Float64Var(&transcodeSampleVMAF, "sample-min-vmaf", handbrake.DefaultSampleMinVMAF, "VMAF score samples from --sample-gate must reach (0 checks decoding only; scoring requires ffmpeg with libvmaf)")
+	transcodeCmd.Flags().BoolVar(&transcodeRetryFailed, "retry-failed", false, "Also transcode the files whose latest attempt failed, according to the encode history")
+	transcodeCmd.Flags().StringVar(&transcodeRetrySince, "since", "", "With --retry-failed, only retry files that failed within this long, such as 7d or 12h (default all)")
+	transcodeCmd.Flags().StringVar(&transcodeSummaryJSON, "summary-json", "", "Write a machine-readable run summary (status, exit code, counts, bytes, failures, duration) to this path")
+	transcodeCmd.Flags().StringVar(&transcodeStatusFile, "status-file", "", "Keep live progress (current file, percent, fps, ETA, files done and total) as JSON in this file, rewritten about once a second")
+	transcodeCmd.Flags().StringVar(&transcodeStatusSocket, "status-socket", "", "Answer each connection to this UNIX socket with the live progress as one line of JSON")
+	transcodeCmd.Flags().BoolVar(&transcodeTUI, "tui", false, "Show a full-screen dashboard of the queue, the current file's progress, recent log lines, and the space saved, instead of line-by-line progress")
+	transcodeCmd.Flags().StringVar(&transcodeMultiTitle, "multi-title", handbrake.MultiTitleWarn, "What to do with files that look like several disc titles or a playlist in one: warn (transcode whole), skip, or split (encode each title found by chapter to its own output)")
+	transcodeCmd.Flags().BoolVar(&transcodeInteractive, "interactive", false, "Analyze and estimate every file first, then list them with their size, codec, and predicted savings to confirm or deselect before encoding starts")
+	transcodeCmd.Flags().BoolVar(&transcodeEmail, "email", false, "Email a summary of savings and failures when done using the SMTP settings in the config file")
 }
 
 func runTranscode(cmd *cobra.Command, args []string) error {
 	setupLogging(transcodeVerbose)
 
-	if len(transcodeFiles) == 0 && transcodeFileListPath == "" {
-		return fmt.Errorf("must specify either --files or --file-list")
+	if len(transcodeFiles) == 0 && transcodeFileListPath == "" && !transcodeRetryFailed {
+		return fmt.Errorf("must specify --files, --file-list, or --retry-failed")
+	}
+	if transcodeRetryFailed && transcodeNoHistory {
+		return fmt.Errorf("--retry-failed reads the encode history and cannot be used with --no-history")
+	}
+	if transcodeNullList && transcodeFileListPath == "" {
+		return fmt.Errorf("--null requires --file-list")
+	}
+	if transcodeRetrySince != "" && !transcodeRetryFailed {
+		return fmt.Errorf("--since requires --retry-failed")
+	}
+	if transcodeRmExtSubs && !transcodeExtSubs {
+		return fmt.Errorf("--remove-external-subs requires --mux-external-subs")
+	}
+	if transcodeTUI && !isTerminal(os.Stdout) {
+		return fmt.Errorf("--tui needs a terminal on stdout")
+	}
+	if transcodeExportQueue != "" {
+		for _, name := range []string{"handbrake-args", "denoise", "film-grain", "mux-external-subs", "hw-decode", "fallback-software", "compare-quality"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--%s cannot be used with --export-handbrake-queue", name)
+			}
+		}
+	}
+	if transcodeInteractive {
+		if transcodeFileListPath == "-" || !isTerminal(os.Stdin) {
+			return fmt.Errorf("--interactive needs a terminal on stdin")
+		}
+		if transcodeExportQueue != "" {
+			return fmt.Errorf("--interactive cannot be used with --export-handbrake-queue")
+		}
+	}
+
+	switch transcodeStaleTmp {
+	case handbrake.StaleTempPrompt, handbrake.StaleTempClean, handbrake.StaleTempResume, handbrake.StaleTempKeep:
+	default:
+		return fmt.Errorf("invalid --stale-tmp value %q: must be prompt, clean, resume, or keep", transcodeStaleTmp)
+	}
+	switch transcodeVerify {
+	case handbrake.VerifyNone, handbrake.VerifyDecode, handbrake.VerifyVMAF:
+	default:
+		return fmt.Errorf("invalid --verify value %q: must be none, decode, or vmaf", transcodeVerify)
+	}
+	if transcodeMinVMAF < 0 || transcodeMinVMAF > 100 {
+		return fmt.Errorf("invalid --min-vmaf value %g: must be between 0 and 100", transcodeMinVMAF)
+	}
+	for _, metric := range transcodeCompare {
+		if !slices.Contains(handbrake.QualityMetrics, metric) {
+			return fmt.Errorf("invalid --compare-quality value %q: must be psnr, ssim, or vmaf", metric)
+		}
+	}
+	if transcodeCompareN < 1 {
+		return fmt.Errorf("invalid --compare-samples value %d: must be 1 or more", transcodeCompareN)
+	}
+	// Each metric is computed once, in a fixed order, however it was given
+	var compareMetrics []string
+	for _, metric := range handbrake.QualityMetrics {
+		if slices.Contains(transcodeCompare, metric) {
+			compareMetrics = append(compareMetrics, metric)
+		}
+	}
+	if transcodeLookahead < 0 {
+		return fmt.Errorf("invalid --lookahead value %d: must be 0 or more", transcodeLookahead)
+	}
+	switch transcodeSubtitles {
+	case handbrake.SubtitlePolicyAll, handbrake.SubtitlePolicyNoSDH, handbrake.SubtitlePolicyForced:
+	default:
+		return fmt.Errorf("invalid --subtitles value %q: must be all, no-sdh, or forced", transcodeSubtitles)
+	}
+	if transcodeLowPowerThr < 0 {
+		return fmt.Errorf("invalid --low-power-threads value %d: must be 0 or more", transcodeLowPowerThr)
+	}
+	switch transcodeHWDecode {
+	case handbrake.HWDecodeAuto, handbrake.HWDecodeVideoToolbox, handbrake.HWDecodeNVDec, handbrake.HWDecodeVAAPI, handbrake.HWDecodeOff:
+	default:
+		return fmt.Errorf("invalid --hw-decode value %q: must be auto, videotoolbox, nvdec, vaapi, or off", transcodeHWDecode)
+	}
+	sampleGateSize, err := lib.ParseSize(transcodeSampleGate)
+	if err != nil {
+		return fmt.Errorf("invalid --sample-gate value: %w", err)
+	}
+	chunkMinSize, err := lib.ParseSize(transcodeChunked)
+	if err != nil {
+		return fmt.Errorf("invalid --chunked-encode value: %w", err)
+	}
+	if transcodeChunkJobs < 2 {
+		return fmt.Errorf("invalid --chunk-jobs value %d: must be 2 or more", transcodeChunkJobs)
+	}
+	if transcodeSampleVMAF < 0 || transcodeSampleVMAF > 100 {
+		return fmt.Errorf("invalid --sample-min-vmaf value %g: must be between 0 and 100", transcodeSampleVMAF)
+	}
+	switch transcodeRateMode {
+	case handbrake.RateModeQuality:
+		if transcodeBitrate != "" {
+			return fmt.Errorf("--bitrate requires --mode abr")
+		}
+	case handbrake.RateModeABR:
+		if transcodeBitrate == "" {
+			return fmt.Errorf("--mode abr requires --bitrate")
+		}
+	default:
+		return fmt.Errorf("invalid --mode value %q: must be quality or abr", transcodeRateMode)
+	}
+	var bitrate int
+	if transcodeBitrate != "" {
+		if bitrate, err = lib.ParseBitrate(transcodeBitrate); err != nil {
+			return fmt.Errorf("invalid --bitrate value: %w", err)
+		}
+	}
+	switch transcodeDenoise {
+	case handbrake.DenoiseOff, handbrake.DenoiseNLMeans, handbrake.DenoiseHQDN3D:
+	default:
+		return fmt.Errorf("invalid --denoise value %q: must be off, nlmeans, or hqdn3d", transcodeDenoise)
+	}
+	switch transcodeDenoiseStr {
+	case "ultralight", "light", "medium", "strong":
+	default:
+		return fmt.Errorf("invalid --denoise-strength value %q: must be ultralight, light, medium, or strong", transcodeDenoiseStr)
+	}
+	if transcodeFilmGrain < 0 || transcodeFilmGrain > 50 {
+		return fmt.Errorf("invalid --film-grain value %d: must be between 0 and 50", transcodeFilmGrain)
+	}
+	handBrakeArgs, err := lib.SplitArgs(transcodeHBArgs)
+	if err == nil {
+		err = handbrake.ValidateHandBrakeArgs(handBrakeArgs)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid --handbrake-args value: %w", err)
+	}
+	ffmpegArgs, err := lib.SplitArgs(transcodeFFmpegArgs)
+	if err == nil {
+		err = handbrake.ValidateFFmpegArgs(ffmpegArgs)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid --ffmpeg-args value: %w", err)
+	}
+	switch transcodeMultiTitle {
+	case handbrake.MultiTitleWarn, handbrake.MultiTitleSkip, handbrake.MultiTitleSplit:
+	default:
+		return fmt.Errorf("invalid --multi-title value %q: must be warn, skip, or split", transcodeMultiTitle)
+	}
+	switch transcodeSidecars {
+	case handbrake.SidecarsAuto, handbrake.SidecarsAlways, handbrake.SidecarsOff:
+	default:
+		return fmt.Errorf("invalid --sidecars value %q: must be auto, always, or off", transcodeSidecars)
+	}
+	switch transcodeContainer {
+	case handbrake.ContainerMKV, handbrake.ContainerMP4:
+	default:
+		return fmt.Errorf("invalid --container value %q: must be mkv or mp4", transcodeContainer)
+	}
+	switch transcodeContainerFb {
+	case handbrake.ContainerFallbackMKV, handbrake.ContainerFallbackConvert, handbrake.ContainerFallbackExtract:
+	default:
+		return fmt.Errorf("invalid --container-fallback value %q: must be mkv, convert, or extract", transcodeContainerFb)
+	}
+	switch transcodeEngine {
+	case handbrake.EngineHandBrake:
+	case handbrake.EngineAVFoundation:
+		if err := checkAVFoundationFlags(cmd); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid --engine value %q: must be handbrake or avfoundation", transcodeEngine)
+	}
+
+	if transcodeRetryFailed {
+		var since time.Time
+		if transcodeRetrySince != "" {
+			age, err := lib.ParseAge(transcodeRetrySince)
+			if err != nil {
+				return fmt.Errorf("invalid --since value: %w", err)
+			}
+			since = time.Now().Add(-age)
+		}
+		failed, err := retryableFailures(since, transcodeFiles)
+		if err != nil {
+			return err
+		}
+		slog.Info("Retrying failed files", "files", len(failed), "since", transcodeRetrySince)
+		transcodeFiles = append(transcodeFiles, failed...)
+		if len(transcodeFiles) == 0 && transcodeFileListPath == "" {
+			slog.Info("No failed files to retry")
+			return nil
+		}
 	}
 
 	slog.Info("Starting video transcoding with HandBrake",
@@ -55,6 +555,11 @@ func runTranscode(cmd *cobra.Command, args []string) error {
 		"suffix", transcodeOutputSuffix,
 		"overwrite", transcodeOverwrite)
 
+	config, err := lib.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -68,22 +573,226 @@ func runTranscode(cmd *cobra.Command, args []string) error {
 	}()
 
 	transcoder := &handbrake.HandBrakeTranscoder{
-		Files:        transcodeFiles,
-		FileListPath: transcodeFileListPath,
-		OutputSuffix: transcodeOutputSuffix,
-		Overwrite:    transcodeOverwrite,
-		Quality:      transcodeQuality,
-		MaxSizeRatio: transcodeMaxSizeRatio,
+		Files:               transcodeFiles,
+		FileListPath:        transcodeFileListPath,
+		FileListNUL:         transcodeNullList,
+		OutputSuffix:        transcodeOutputSuffix,
+		Overwrite:           transcodeOverwrite,
+		PreserveTimes:       transcodePreserveTime,
+		PreserveOwner:       transcodePreserveOwn,
+		Quality:             transcodeQuality,
+		RateMode:            transcodeRateMode,
+		Bitrate:             bitrate,
+		EncoderPreset:       transcodeEncPreset,
+		EncoderTune:         transcodeEncTune,
+		EncoderProfile:      transcodeEncProfile,
+		Denoise:             transcodeDenoise,
+		DenoiseStrength:     transcodeDenoiseStr,
+		DenoiseAll:          transcodeDenoiseAll,
+		FilmGrain:           transcodeFilmGrain,
+		HandBrakeArgs:       handBrakeArgs,
+		FFmpegArgs:          ffmpegArgs,
+		MaxSizeRatio:        transcodeMaxSizeRatio,
+		SingleEstimate:      transcodeSingleEst,
+		ExportQueuePath:     transcodeExportQueue,
+		Engine:              transcodeEngine,
+		Container:           transcodeContainer,
+		ContainerFallback:   transcodeContainerFb,
+		ChunkMinSize:        chunkMinSize,
+		ChunkJobs:           transcodeChunkJobs,
+		SampleGateSize:      sampleGateSize,
+		SampleMinVMAF:       transcodeSampleVMAF,
+		LowPower:            transcodeLowPower,
+		LowPowerThreads:     transcodeLowPowerThr,
+		HWDecode:            transcodeHWDecode,
+		FallbackSoftware:    transcodeFallbackSW,
+		Lookahead:           transcodeLookahead,
+		DropCommentary:      transcodeDropComment,
+		KeepAudioLangs:      transcodeAudioLangs,
+		PassthroughLossless: transcodeLossless,
+		CFRConvert:          transcodeCFR,
+		SubtitlePolicy:      transcodeSubtitles,
+		MuxAudioSidecars:    transcodeMuxSidecars,
+		MuxExternalSubs:     transcodeExtSubs,
+		RemoveExternalSubs:  transcodeRmExtSubs,
+		Sidecars:            transcodeSidecars,
+		Verify:              transcodeVerify,
+		MinVMAF:             transcodeMinVMAF,
+		CompareQuality:      compareMetrics,
+		QualitySamples:      transcodeCompareN,
+		EncodeLogDir:        transcodeEncodeLogs,
+		StaleTempPolicy:     transcodeStaleTmp,
+		StaleTempAge:        transcodeStaleTmpAge,
+		StatusFile:          transcodeStatusFile,
+		StatusSocket:        transcodeStatusSocket,
+		Interactive:         transcodeInteractive,
+		MultiTitle:          transcodeMultiTitle,
+		Presets:             config.Transcode.Presets,
+	}
+	if !transcodeNoHistory {
+		transcoder.History = lib.NewHistoryStore(lib.DefaultHistoryPath())
+	}
+	transcoder.Leases = lib.NewLeaseStore(lib.DefaultLeaseDir(), "transcode")
+	// The dashboard starts with the first file, after any prompt for stale temporary outputs
+	var dashboard *handbrake.Dashboard
+	var startDashboard sync.Once
+	logger := slog.Default()
+	if transcodeTUI {
+		dashboard = handbrake.NewDashboard(transcoder, os.Stdout)
+		dashboard.ReadKeys(os.Stdin)
 	}
 
-	if err := transcoder.Run(ctx); err != nil {
-		if ctx.Err() == context.Canceled {
-			slog.Info("Transcoding was cancelled by user")
-			return nil
+	abortChan := make(chan os.Signal, 1)
+	signal.Notify(abortChan, syscall.SIGUSR1)
+	defer signal.Stop(abortChan)
+	go func() {
+		for range abortChan {
+			if !transcoder.AbortCurrentFile() {
+				slog.Info("Received SIGUSR1, but no file is being processed")
+			}
+		}
+	}()
+	transcoder.OnProgress = func(progress handbrake.Progress) {
+		if dashboard != nil {
+			startDashboard.Do(func() {
+				slog.SetDefault(dashboardLogger(dashboard))
+				dashboard.Start()
+			})
+		}
+		if progress.Stage == handbrake.StageDone {
+			auditAffected(transcoder.OutputPath(progress.File))
 		}
-		return fmt.Errorf("transcoding failed: %w", err)
 	}
 
-	slog.Info("Transcoding completed successfully")
+	report := lib.NewRunReport("transcode")
+	recordSettings(cmd, report)
+	report.RecordVersions(ctx, "HandBrakeCLI", "ffmpeg")
+	report.Inputs = append(report.Inputs, transcodeFiles...)
+	if transcodeFileListPath != "" {
+		report.Inputs = append(report.Inputs, transcodeFileListPath)
+	}
+	err = transcoder.Run(ctx)
+	if dashboard != nil {
+		dashboard.Stop()
+		slog.SetDefault(logger)
+	}
+	result := transcoder.Result()
+	summary := result.Summary(lib.T(lib.MsgTranscodeTitle))
+	if err != nil {
+		summary.Title = lib.T(lib.MsgTranscodeFailedTitle)
+	}
+	if transcodeExportQueue == "" && len(result.Files) > 0 {
+		fmt.Println()
+		renderTable(summary.Table(), lib.TableFormatText)
+	}
+	if transcodeEmail && ctx.Err() == nil {
+		if err != nil {
+			summary.Failures = append(summary.Failures, lib.FileFailure{File: "batch", Error: err.Error()})
+		}
+		emailSummary(summary)
+	}
+
+	cancelled := err != nil && ctx.Err() == context.Canceled
+	var cmdErr error
+	switch {
+	case cancelled:
+		slog.Info("Transcoding was cancelled by user")
+	case err != nil:
+		cmdErr = fmt.Errorf("transcoding failed: %w", err)
+	case len(result.Failures) > 0:
+		cmdErr = &partialFailureError{fmt.Sprintf("%d files failed to transcode or verify", len(result.Failures))}
+	case len(result.Discrepancies) > 0:
+		cmdErr = &partialFailureError{fmt.Sprintf("%d files on disk do not match the batch's record (see the run summary)", len(result.Discrepancies))}
+	default:
+		slog.Info("Transcoding completed successfully")
+	}
+
+	result.FillRunReport(report)
+	writeRunReport(lib.DefaultRunSummaryDir("transcode"), transcodeSummaryJSON, report, err, cmdErr)
+	return cmdErr
+}
+
+// avfoundationUnsupportedFlags are transcode flags that need HandBrakeCLI or a Matroska output
+var avfoundationUnsupportedFlags = []string{
+	"drop-commentary", "keep-audio-langs", "passthrough-lossless", "cfr-convert", "subtitles",
+	"mux-audio-sidecars", "single-estimate", "export-handbrake-queue", "low-power",
+	"container", "container-fallback", "mode", "bitrate", "encoder-preset", "encoder-tune",
+	"encoder-profile", "handbrake-args", "ffmpeg-args", "denoise", "denoise-strength", "denoise-all",
+	"film-grain", "mux-external-subs", "remove-external-subs", "chunked-encode", "chunk-jobs",
+	"hw-decode", "fallback-software",
+}
+
+// checkAVFoundationFlags rejects options the avfoundation engine cannot honor, rather than
+// silently producing output that ignores them
+func checkAVFoundationFlags(cmd *cobra.Command) error {
+	for _, name := range avfoundationUnsupportedFlags {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s is not supported with --engine avfoundation", name)
+		}
+	}
+	if cmd.Flags().Changed("quality") {
+		slog.Warn("--quality is ignored with --engine avfoundation, which encodes with a fixed-quality preset")
+	}
 	return nil
 }
+
+// retryableFailures lists the files the encode history says failed since the given time and
+// can be retried, leaving out those that no longer exist. Each file is listed once, even when
+// the history names it by several paths, and files already in queued are left out.
+func retryableFailures(since time.Time, queued []string) ([]string, error) {
+	failed, err := lib.NewHistoryStore(lib.DefaultHistoryPath()).RetryableFailures(since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encode history: %w", err)
+	}
+	var seen []os.FileInfo
+	for _, file := range queued {
+		if info, err := os.Stat(file); err == nil {
+			seen = append(seen, info)
+		}
+	}
+	var files []string
+	for _, file := range failed {
+		info, err := os.Stat(file)
+		if err != nil {
+			slog.Warn("Not retrying failed file that no longer exists", "file", file)
+			continue
+		}
+		if containsFile(seen, info) {
+			continue
+		}
+		seen = append(seen, info)
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// containsFile reports whether infos includes the file info describes
+func containsFile(infos []os.FileInfo, info os.FileInfo) bool {
+	for _, other := range infos {
+		if os.SameFile(other, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// dashboardLogger logs to a dashboard at the level of the current logger, leaving out the time,
+// which the dashboard adds
+func dashboardLogger(dashboard io.Writer) *slog.Logger {
+	level := slog.LevelError
+	for _, candidate := range []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn} {
+		if slog.Default().Enabled(context.Background(), candidate) {
+			level = candidate
+			break
+		}
+	}
+	return slog.New(slog.NewTextHandler(dashboard, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var webOptCmd = &cobra.Command{
+	Use:   "webopt PATH...",
+	Short: "Move the metadata of MP4 files to the front so streaming playback starts immediately",
+	Long: `Find MP4, M4V, and MOV files whose moov atom, the index players need before they can
+start, is stored after the media data, and remux them with ffmpeg so it comes first
+(known as faststart or web optimization). Streams are copied without re-encoding.
+
+Paths may be files or directories, which are scanned recursively. Files that are
+already optimized are left untouched, and remuxed files keep their permissions and
+modification times. Requires ffmpeg unless --dry-run is given.
+
+New MP4 outputs from transcode --container mp4 are already optimized.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runWebOpt,
+}
+
+var (
+	webOptDryRun  bool
+	webOptVerbose bool
+)
+
+func init() {
+	webOptCmd.Flags().BoolVarP(&webOptDryRun, "dry-run", "n", false, "List the files that need optimizing without changing them")
+	webOptCmd.Flags().BoolVarP(&webOptVerbose, "verbose", "v", false, "Enable verbose logging")
+}
+
+func runWebOpt(cmd *cobra.Command, args []string) error {
+	setupLogging(webOptVerbose)
+
+	if !webOptDryRun {
+		if _, err := exec.LookPath(lib.ToolCommand("ffmpeg")); err != nil {
+			return fmt.Errorf("webopt requires ffmpeg, which was not found in the tools directory or PATH")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		slog.Info("Received signal, shutting down gracefully", "signal", sig)
+		cancel()
+	}()
+
+	files, err := webOptFiles(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	optimized, failed := 0, 0
+	for _, file := range files {
+		if ctx.Err() != nil {
+			slog.Info("Optimization was cancelled by user")
+			return nil
+		}
+
+		atEnd, err := lib.MoovAtEnd(file)
+		if err != nil {
+			slog.Error("Failed to read MP4 structure", "file", file, "error", err)
+			failed++
+			continue
+		}
+		if !atEnd {
+			slog.Debug("Already optimized", "file", file)
+			continue
+		}
+		if webOptDryRun {
+			slog.Info("Would optimize", "file", file)
+			optimized++
+			continue
+		}
+
+		if err := lib.Faststart(ctx, file); err != nil {
+			if ctx.Err() != nil {
+				slog.Info("Optimization was cancelled by user")
+				return nil
+			}
+			slog.Error("Failed to optimize file", "file", file, "error", err)
+			failed++
+			continue
+		}
+		auditAffected(file)
+		slog.Info("Optimized for streaming", "file", filepath.Base(file))
+		optimized++
+	}
+
+	slog.Info("Web optimization complete", "checked", len(files), "optimized", optimized, "failed", failed, "dry_run", webOptDryRun)
+	if failed > 0 {
+		return &partialFailureError{fmt.Sprintf("%d of %d files could not be optimized", failed, len(files))}
+	}
+	return nil
+}
+
+// webOptFiles expands the arguments into the MP4 files they name or contain
+func webOptFiles(ctx context.Context, paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if !lib.IsMP4(path) {
+				return nil, fmt.Errorf("%s is not an MP4, M4V, or MOV file", path)
+			}
+			files = append(files, path)
+			continue
+		}
+
+		found, err := lib.NewFileScanner(path).ScanVideoFiles(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", path, err)
+		}
+		for _, file := range found {
+			if lib.IsMP4(file) {
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
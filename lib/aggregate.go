@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// rootGroupName labels files stored directly in the library root rather than a subdirectory
+const rootGroupName = "(root)"
+
+var (
+	// episodePattern matches episode markers such as S01E02, s1e2, or 1x02, capturing the text before them
+	episodePattern = regexp.MustCompile(`(?i)^(.*?)[\s._-]*(?:s\d{1,2}\s*e\d{1,3}|\d{1,2}x\d{2,3})`)
+	// seasonDirPattern matches season directories such as "Season 1", "Series 02", or "S03"
+	seasonDirPattern = regexp.MustCompile(`(?i)^(?:season|series|s)[\s._-]*\d+$`)
+)
+
+// LibraryGroup summarizes the files in one top-level directory or show
+type LibraryGroup struct {
+	Name          string  `json:"name"`
+	Files         int     `json:"files"`
+	TotalSize     int64   `json:"total_size"`
+	TotalDuration float64 `json:"total_duration"`
+	AvgBitrate    int64   `json:"avg_bitrate,omitempty"` // Mean video bitrate of files with a known bitrate
+}
+
+// GroupByDirectory aggregates files by their top-level directory under root, largest first
+func GroupByDirectory(root string, mediaInfos []*MediaInfo) []LibraryGroup {
+	return groupLibrary(mediaInfos, func(info *MediaInfo) string {
+		relPath, err := filepath.Rel(root, info.FilePath)
+		if err != nil || IsOutsideRel(relPath) {
+			return rootGroupName
+		}
+		parts := strings.SplitN(filepath.ToSlash(relPath), "/", 2)
+		if len(parts) < 2 {
+			return rootGroupName
+		}
+		return parts[0]
+	})
+}
+
+// IsOutsideRel reports whether a path made relative with filepath.Rel leads out of its base,
+// unlike names inside it that merely start with "..", such as "..extras"
+func IsOutsideRel(relPath string) bool {
+	return relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator))
+}
+
+// GroupByShow aggregates episodes by their parsed show name, largest first.
+// Files that don't look like episodes are omitted.
+func GroupByShow(mediaInfos []*MediaInfo) []LibraryGroup {
+	return groupLibrary(mediaInfos, func(info *MediaInfo) string {
+		return ParseShowName(info.FilePath)
+	})
+}
+
+// ParseShowName extracts a show name from an episode's path, or returns "" if it isn't an episode.
+// Uses the file name before an SxxEyy or NxNN marker, falling back to the directory above a
+// season directory when the file name has no show name of its own.
+func ParseShowName(path string) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	match := episodePattern.FindStringSubmatch(name)
+
+	dir := filepath.Dir(path)
+	inSeasonDir := seasonDirPattern.MatchString(filepath.Base(dir))
+	if match == nil && !inSeasonDir {
+		return ""
+	}
+
+	if match != nil {
+		if show := cleanShowName(match[1]); show != "" {
+			return show
+		}
+	}
+	if inSeasonDir {
+		dir = filepath.Dir(dir)
+	}
+	if base := filepath.Base(dir); base != "." && base != string(filepath.Separator) {
+		return cleanShowName(base)
+	}
+	return ""
+}
+
+// cleanShowName turns dotted or underscored release names into spaced titles
+func cleanShowName(name string) string {
+	name = strings.NewReplacer(".", " ", "_", " ").Replace(name)
+	return strings.Trim(strings.Join(strings.Fields(name), " "), " -")
+}
+
+// groupLibrary aggregates files by key, skipping empty keys, sorted by total size descending
+func groupLibrary(mediaInfos []*MediaInfo, key func(info *MediaInfo) string) []LibraryGroup {
+	index := make(map[string]int)
+	bitrateTotals := make(map[string][2]int64)
+	groups := []LibraryGroup{}
+
+	for _, info := range mediaInfos {
+		k := key(info)
+		if k == "" {
+			continue
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, LibraryGroup{Name: k})
+		}
+		groups[i].Files++
+		groups[i].TotalSize += info.FileSize
+		groups[i].TotalDuration += info.Duration
+		if info.VideoBitrate > 0 {
+			totals := bitrateTotals[k]
+			bitrateTotals[k] = [2]int64{totals[0] + info.VideoBitrate, totals[1] + 1}
+		}
+	}
+
+	for i := range groups {
+		if totals := bitrateTotals[groups[i].Name]; totals[1] > 0 {
+			groups[i].AvgBitrate = totals[0] / totals[1]
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].TotalSize != groups[j].TotalSize {
+			return groups[i].TotalSize > groups[j].TotalSize
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	return groups
+}
@@ -0,0 +1,68 @@
+package lib
+
+import "testing"
+
+func TestParseShowName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tv/The.Expanse.S02E05.1080p.mkv", "The Expanse"},
+		{"/tv/Show Name - 1x02 - Pilot.mkv", "Show Name"},
+		{"/tv/Severance/Season 1/01 - Good News About Hell.mkv", "Severance"},
+		{"/tv/Severance/S01/S01E02.mkv", "Severance"},
+		{"/tv/Andor/S01E03.mkv", "Andor"},
+		{"/movies/Heat (1995).mkv", ""},
+	}
+
+	for _, tt := range tests {
+		if got := ParseShowName(tt.path); got != tt.want {
+			t.Errorf("ParseShowName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGroupByDirectory(t *testing.T) {
+	mediaInfos := []*MediaInfo{
+		{FilePath: "/media/tv/a/e1.mkv", FileSize: 100, Duration: 60, VideoBitrate: 2000},
+		{FilePath: "/media/tv/b/e1.mkv", FileSize: 200, Duration: 60, VideoBitrate: 4000},
+		{FilePath: "/media/movies/m.mkv", FileSize: 250},
+		{FilePath: "/media/loose.mkv", FileSize: 10},
+		{FilePath: "/media/..extras/x.mkv", FileSize: 5},
+		{FilePath: "/elsewhere/y.mkv", FileSize: 1},
+	}
+
+	groups := GroupByDirectory("/media", mediaInfos)
+	if len(groups) != 4 {
+		t.Fatalf("Expected 3 groups, got %+v", groups)
+	}
+	tv := groups[0]
+	if tv.Name != "tv" || tv.Files != 2 || tv.TotalSize != 300 || tv.TotalDuration != 120 || tv.AvgBitrate != 3000 {
+		t.Errorf("Unexpected tv group %+v", tv)
+	}
+	if groups[1].Name != "movies" || groups[1].AvgBitrate != 0 {
+		t.Errorf("Expected movies second without a bitrate, got %+v", groups[1])
+	}
+	if groups[2].Name != rootGroupName || groups[2].Files != 2 {
+		t.Errorf("Expected the loose file and the file outside the root grouped under %s, got %+v", rootGroupName, groups[2])
+	}
+	if groups[3].Name != "..extras" {
+		t.Errorf("Expected ..extras grouped as a directory, got %+v", groups[3])
+	}
+
+	// Grouping is by the library root, even when every file is under one directory of it
+	if groups := GroupByDirectory("/media", mediaInfos[:2]); len(groups) != 1 || groups[0].Name != "tv" {
+		t.Errorf("Expected a single tv group, got %+v", groups)
+	}
+}
+
+func TestGroupByShowOmitsNonEpisodes(t *testing.T) {
+	groups := GroupByShow([]*MediaInfo{
+		{FilePath: "/tv/Andor.S01E01.mkv", FileSize: 1},
+		{FilePath: "/tv/Andor.S01E02.mkv", FileSize: 1},
+		{FilePath: "/movies/Heat.mkv", FileSize: 5},
+	})
+	if len(groups) != 1 || groups[0].Name != "Andor" || groups[0].Files != 2 {
+		t.Errorf("Expected a single Andor group, got %+v", groups)
+	}
+}
@@ -5,49 +5,132 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math"
 	"os"
 	"os/exec"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type MediaInfo struct {
-	FilePath       string          `json:"file_path"`
-	FileSize       int64           `json:"file_size"`
-	Duration       float64         `json:"duration"`
-	VideoCodec     string          `json:"video_codec"`
-	VideoBitrate   int64           `json:"video_bitrate"`
-	VideoWidth     int             `json:"video_width"`
-	VideoHeight    int             `json:"video_height"`
-	VideoProfile   string          `json:"video_profile"`
-	VideoLevel     string          `json:"video_level"`
-	PixelFormat    string          `json:"pixel_format"`
-	IsVBR          bool            `json:"is_vbr"`
-	ColorSpace     string          `json:"color_space"`
-	ColorTransfer  string          `json:"color_transfer"`
-	HasDolbyVision bool            `json:"has_dolby_vision"`
-	AudioTracks    []AudioTrack    `json:"audio_tracks"`
-	SubtitleTracks []SubtitleTrack `json:"subtitle_tracks"`
-	AnalyzedAt     time.Time       `json:"analyzed_at"`
+	FilePath       string             `json:"file_path"`
+	FileSize       int64              `json:"file_size"`
+	Duration       float64            `json:"duration"`
+	ContentType    string             `json:"content_type,omitempty"` // episode, movie, or unknown, from the file name and duration
+	VideoCodec     string             `json:"video_codec"`
+	VideoBitrate   int64              `json:"video_bitrate"`
+	VideoWidth     int                `json:"video_width"`
+	VideoHeight    int                `json:"video_height"`
+	VideoProfile   string             `json:"video_profile"`
+	VideoLevel     string             `json:"video_level"`
+	PixelFormat    string             `json:"pixel_format"`
+	FrameRate      float64            `json:"frame_rate"`          // Average frames per second of the primary stream (0 if unknown)
+	BitDepth       int                `json:"bit_depth"`           // Bits per color sample (0 if unknown)
+	ScanType       string             `json:"scan_type,omitempty"` // progressive, interlaced, or telecined ("" if unknown)
+	IsVBR          bool               `json:"is_vbr"`
+	IsVFR          bool               `json:"is_vfr,omitempty"` // Variable frame rate, which many editing applications handle poorly
+	ColorSpace     string             `json:"color_space"`
+	ColorTransfer  string             `json:"color_transfer"`
+	HasDolbyVision bool               `json:"has_dolby_vision"`
+	HDR            *HDRInfo           `json:"hdr,omitempty"` // HDR format and metadata of the primary stream (nil for SDR)
+	AudioTracks    []AudioTrack       `json:"audio_tracks"`
+	AudioSidecars  []AudioSidecar     `json:"audio_sidecars,omitempty"` // Standalone audio files kept next to the video
+	SubtitleTracks []SubtitleTrack    `json:"subtitle_tracks"`
+	VideoStreams   []VideoStream      `json:"video_streams"` // Every video stream, including the primary, in file order
+	AnalyzedAt     time.Time          `json:"analyzed_at"`
+	ArchivedTo     string             `json:"archived_to,omitempty"`
+	Container      *ContainerMetadata `json:"container,omitempty"`     // Container tags recording the file's provenance
+	BitrateStats   *BitrateStats      `json:"bitrate_stats,omitempty"` // Bitrates measured from packets, with --accurate-bitrate
+	BitrateGraph   *BitrateGraph      `json:"bitrate_graph,omitempty"` // Video bitrate over time, for files selected with --bitrate-graph
+	Thumbnails     []string           `json:"thumbnails,omitempty"`    // Keyframe images relative to the report directory, with --thumbnails
 }
 
 type AudioTrack struct {
 	Index    int    `json:"index"`
 	Codec    string `json:"codec"`
+	Profile  string `json:"profile,omitempty"` // Codec profile, e.g. "DTS-HD MA"
 	Bitrate  int64  `json:"bitrate"`
 	Language string `json:"language"`
 	Channels int    `json:"channels"`
+	Title    string `json:"title,omitempty"`
+	Role     string `json:"role,omitempty"` // main, commentary, or descriptive
+}
+
+// VideoStream describes one video stream in a file, such as the main content, an alternate
+// angle, an embedded trailer, or cover art
+type VideoStream struct {
+	Index       int      `json:"index"`
+	Codec       string   `json:"codec"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	FrameRate   float64  `json:"frame_rate"`            // Average frames per second (0 if unknown)
+	Disposition []string `json:"disposition,omitempty"` // Set disposition flags, e.g. default or attached_pic
+	Title       string   `json:"title,omitempty"`
+	Kind        string   `json:"kind"` // primary, cover_art, thumbnail, or video
+}
+
+// IsLossless reports whether the track is a bit-exact copy of its master: TrueHD, DTS-HD Master
+// Audio, FLAC, ALAC, or PCM. DTS cores and DTS-HD High Resolution Audio are lossy.
+func (track AudioTrack) IsLossless() bool {
+	switch {
+	case track.Codec == "truehd", track.Codec == "mlp", track.Codec == "flac", track.Codec == "alac":
+		return true
+	case strings.HasPrefix(track.Codec, "pcm_"):
+		return true
+	case track.Codec == "dts":
+		return strings.HasPrefix(track.Profile, "DTS-HD MA")
+	}
+	return false
+}
+
+// AudioTracksWithRole counts the audio tracks classified with role
+func (info *MediaInfo) AudioTracksWithRole(role string) int {
+	count := 0
+	for _, track := range info.AudioTracks {
+		if track.Role == role {
+			count++
+		}
+	}
+	return count
+}
+
+// SubtitleTracksOfKind counts the subtitle tracks classified as kind
+func (info *MediaInfo) SubtitleTracksOfKind(kind string) int {
+	count := 0
+	for _, track := range info.SubtitleTracks {
+		if track.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// ExtraVideoStreams returns the non-primary streams that carry real video, such as alternate
+// angles or embedded trailers, which are worth stripping unlike cover art
+func (info *MediaInfo) ExtraVideoStreams() []VideoStream {
+	var extra []VideoStream
+	for _, stream := range info.VideoStreams {
+		if stream.Kind == VideoStreamVideo {
+			extra = append(extra, stream)
+		}
+	}
+	return extra
 }
 
 type SubtitleTrack struct {
 	Index    int    `json:"index"`
 	Codec    string `json:"codec"`
 	Language string `json:"language"`
+	Title    string `json:"title,omitempty"`
+	Kind     string `json:"kind,omitempty"` // full, forced, or sdh
 }
 
 type FFProbeOutput struct {
-	Streams []Stream `json:"streams"`
-	Format  Format   `json:"format"`
+	Streams  []Stream       `json:"streams"`
+	Format   Format         `json:"format"`
+	Chapters []ProbeChapter `json:"chapters,omitempty"` // Only requested with -show_chapters
 }
 
 type Stream struct {
@@ -62,41 +145,80 @@ type Stream struct {
 	Bitrate       string            `json:"bit_rate,omitempty"`
 	Width         int               `json:"width,omitempty"`
 	Height        int               `json:"height,omitempty"`
+	AvgFrameRate  string            `json:"avg_frame_rate,omitempty"`
+	RFrameRate    string            `json:"r_frame_rate,omitempty"`
+	FieldOrder    string            `json:"field_order,omitempty"`
+	BitsPerSample string            `json:"bits_per_raw_sample,omitempty"`
 	Channels      int               `json:"channels,omitempty"`
 	Tags          map[string]string `json:"tags,omitempty"`
+	Disposition   map[string]int    `json:"disposition,omitempty"`
 	SideDataList  []SideData        `json:"side_data_list,omitempty"`
 }
 
 type SideData struct {
 	SideDataType string `json:"side_data_type"`
+
+	// DOVI configuration record
+	DVProfile         int `json:"dv_profile,omitempty"`
+	DVLevel           int `json:"dv_level,omitempty"`
+	DVCompatibilityID int `json:"dv_bl_signal_compatibility_id,omitempty"`
+
+	// Mastering display metadata, as rationals such as "34000/50000"
+	RedX         string `json:"red_x,omitempty"`
+	RedY         string `json:"red_y,omitempty"`
+	GreenX       string `json:"green_x,omitempty"`
+	GreenY       string `json:"green_y,omitempty"`
+	BlueX        string `json:"blue_x,omitempty"`
+	BlueY        string `json:"blue_y,omitempty"`
+	WhitePointX  string `json:"white_point_x,omitempty"`
+	WhitePointY  string `json:"white_point_y,omitempty"`
+	MinLuminance string `json:"min_luminance,omitempty"`
+	MaxLuminance string `json:"max_luminance,omitempty"`
+
+	// Content light level metadata
+	MaxContent int `json:"max_content,omitempty"`
+	MaxAverage int `json:"max_average,omitempty"`
+}
+
+type ProbeChapter struct {
+	StartTime string            `json:"start_time"`
+	EndTime   string            `json:"end_time"`
+	Tags      map[string]string `json:"tags,omitempty"`
 }
 
 type Format struct {
-	Filename string            `json:"filename"`
-	Size     string            `json:"size"`
-	Duration string            `json:"duration"`
-	Bitrate  string            `json:"bit_rate"`
-	Tags     map[string]string `json:"tags,omitempty"`
+	Filename   string            `json:"filename"`
+	FormatName string            `json:"format_name"`
+	Size       string            `json:"size"`
+	Duration   string            `json:"duration"`
+	Bitrate    string            `json:"bit_rate"`
+	Tags       map[string]string `json:"tags,omitempty"`
 }
 
-type MediaAnalyzer struct{}
+type MediaAnalyzer struct {
+	AccurateBitrate bool          // Measure bitrates from every packet instead of trusting container headers
+	GraphPatterns   []string      // Glob patterns, matched against the path or file name, of files to graph bitrate over time for
+	ProbeTimeout    time.Duration // Time limit for each ffprobe attempt (0 waits indefinitely)
+	ProbeRetries    int           // Times a failed or timed out ffprobe is repeated, with backoff
+	Remote          RemoteLibrary // Library the files are in, when not local
+}
 
 func NewMediaAnalyzer() *MediaAnalyzer {
-	return &MediaAnalyzer{}
+	return &MediaAnalyzer{ProbeTimeout: DefaultProbeTimeout, ProbeRetries: DefaultProbeRetries}
 }
 
 // AnalyzeFile analyzes a single video file using FFprobe
 func (ma *MediaAnalyzer) AnalyzeFile(ctx context.Context, filePath string) (*MediaInfo, error) {
 	slog.Debug("Analyzing file", "path", filePath)
 
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := ma.stat(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, err)
+		return nil, &AnalysisError{File: filePath, Stage: FailureStageStat, Err: err}
 	}
 
 	probeData, err := ma.runFFprobe(ctx, filePath)
 	if err != nil {
-		return nil, fmt.Errorf("ffprobe failed for %s: %w", filePath, err)
+		return nil, &AnalysisError{File: filePath, Stage: FailureStageProbe, Err: err}
 	}
 
 	mediaInfo := &MediaInfo{
@@ -105,10 +227,30 @@ func (ma *MediaAnalyzer) AnalyzeFile(ctx context.Context, filePath string) (*Med
 		AnalyzedAt:     time.Now(),
 		AudioTracks:    make([]AudioTrack, 0),
 		SubtitleTracks: make([]SubtitleTrack, 0),
+		VideoStreams:   make([]VideoStream, 0),
 	}
 
 	if err := ma.parseFFprobeOutput(probeData, mediaInfo); err != nil {
-		return nil, fmt.Errorf("failed to parse ffprobe output for %s: %w", filePath, err)
+		return nil, &AnalysisError{File: filePath, Stage: FailureStageParse, Err: err}
+	}
+	if ma.Remote == nil {
+		mediaInfo.AudioSidecars = ma.AnalyzeAudioSidecars(ctx, filePath)
+	}
+
+	if graph := ma.WantsBitrateGraph(filePath); ma.AccurateBitrate || graph {
+		primaryIndex := -1
+		if primary := ClassifyVideoStreams(probeData.Streams, mediaInfo.Duration).Primary; primary != nil {
+			primaryIndex = primary.Index
+		}
+		scan, err := ma.measureBitrates(ctx, filePath, probeData, primaryIndex, mediaInfo.Duration)
+		if err != nil {
+			slog.Warn("Failed to measure bitrates, keeping estimates", "file", filePath, "error", err)
+		} else {
+			mediaInfo.applyBitrateStats(scan.stats, primaryIndex)
+			if graph {
+				mediaInfo.BitrateGraph = newBitrateGraph(scan.videoSeconds)
+			}
+		}
 	}
 
 	slog.Debug("File analysis completed",
@@ -116,18 +258,54 @@ func (ma *MediaAnalyzer) AnalyzeFile(ctx context.Context, filePath string) (*Med
 		"codec", mediaInfo.VideoCodec,
 		"duration", mediaInfo.Duration,
 		"audioTracks", len(mediaInfo.AudioTracks),
+		"audioSidecars", len(mediaInfo.AudioSidecars),
 		"subtitleTracks", len(mediaInfo.SubtitleTracks))
 
 	return mediaInfo, nil
 }
 
 func (ma *MediaAnalyzer) runFFprobe(ctx context.Context, filePath string) (*FFProbeOutput, error) {
-	cmd := exec.CommandContext(ctx, "ffprobe",
+	var probeOutput *FFProbeOutput
+	err := ma.retryProbe(ctx, filePath, func(ctx context.Context) error {
+		var err error
+		if ma.Remote != nil {
+			probeOutput, err = ma.Remote.Probe(ctx, filePath)
+		} else {
+			probeOutput, err = probeFormatAndStreams(ctx, filePath)
+		}
+		return err
+	})
+	return probeOutput, err
+}
+
+// ExplainStreams probes a file and scores its video streams as classification does
+func (ma *MediaAnalyzer) ExplainStreams(ctx context.Context, filePath string) ([]StreamScore, error) {
+	probe, err := ma.runFFprobe(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	duration, _ := strconv.ParseFloat(probe.Format.Duration, 64)
+	return ExplainVideoStreams(probe.Streams, duration), nil
+}
+
+// stat returns a file's size and modification time, from the remote listing for remote files
+func (ma *MediaAnalyzer) stat(filePath string) (os.FileInfo, error) {
+	if ma.Remote != nil {
+		return ma.Remote.Stat(filePath)
+	}
+	return os.Stat(filePath)
+}
+
+// probeFormatAndStreams runs ffprobe once for a file's container and stream metadata
+func probeFormatAndStreams(ctx context.Context, filePath string) (*FFProbeOutput, error) {
+	cmd := exec.CommandContext(ctx, ToolCommand("ffprobe"),
 		"-v", "quiet",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
 		filePath)
+	// Don't wait on output pipes after a timeout kill, in case a child still holds them
+	cmd.WaitDelay = 5 * time.Second
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -149,6 +327,7 @@ func (ma *MediaAnalyzer) parseFFprobeOutput(probe *FFProbeOutput, info *MediaInf
 	if duration, err := strconv.ParseFloat(probe.Format.Duration, 64); err == nil {
 		info.Duration = duration
 	}
+	info.ContentType = DetectContentType(info.FilePath, info.Duration)
 
 	var overallBitrate int64
 	if probe.Format.Bitrate != "" {
@@ -158,6 +337,7 @@ func (ma *MediaAnalyzer) parseFFprobeOutput(probe *FFProbeOutput, info *MediaInf
 	}
 
 	classification := ClassifyVideoStreams(probe.Streams, info.Duration)
+	info.Container = ParseContainerMetadata(info.FilePath, probe.Format, classification.Primary)
 	if classification.Primary != nil {
 		stream := *classification.Primary
 		info.VideoCodec = stream.CodecName
@@ -167,17 +347,17 @@ func (ma *MediaAnalyzer) parseFFprobeOutput(probe *FFProbeOutput, info *MediaInf
 		info.PixelFormat = stream.PixelFormat
 		info.ColorSpace = stream.ColorSpace
 		info.ColorTransfer = stream.ColorTransfer
+		info.FrameRate = parseFrameRate(stream.AvgFrameRate)
+		info.BitDepth = streamBitDepth(stream)
+		info.ScanType = streamScanType(stream)
+		info.IsVFR = streamIsVFR(stream)
 
 		if stream.Level > 0 {
 			info.VideoLevel = formatLevel(stream.Level)
 		}
 
-		for _, sideData := range stream.SideDataList {
-			if sideData.SideDataType == "DOVI configuration record" {
-				info.HasDolbyVision = true
-				break
-			}
-		}
+		info.HDR = ParseHDRInfo(stream)
+		info.HasDolbyVision = info.HDR != nil && info.HDR.Format == HDRFormatDolbyVision
 
 		if stream.Bitrate != "" {
 			if bitrate, err := strconv.ParseInt(stream.Bitrate, 10, 64); err == nil {
@@ -192,40 +372,30 @@ func (ma *MediaAnalyzer) parseFFprobeOutput(probe *FFProbeOutput, info *MediaInf
 			}
 		}
 	}
-
 	for _, stream := range probe.Streams {
-		switch stream.CodecType {
-		case "audio":
-			track := AudioTrack{
-				Index:    stream.Index,
-				Codec:    stream.CodecName,
-				Channels: stream.Channels,
-			}
-
-			if bitrate, err := strconv.ParseInt(stream.Bitrate, 10, 64); err == nil {
-				track.Bitrate = bitrate
-			}
-
-			if lang, exists := stream.Tags["language"]; exists {
-				track.Language = lang
-			}
-
-			info.AudioTracks = append(info.AudioTracks, track)
-
-		case "subtitle":
-			track := SubtitleTrack{
-				Index: stream.Index,
-				Codec: stream.CodecName,
-			}
-
-			if lang, exists := stream.Tags["language"]; exists {
-				track.Language = lang
-			}
-
-			info.SubtitleTracks = append(info.SubtitleTracks, track)
+		if stream.CodecType != "video" {
+			continue
 		}
+		kind := AuxiliaryStreamKind(stream)
+		if classification.Primary != nil && stream.Index == classification.Primary.Index {
+			kind = VideoStreamPrimary
+		}
+		info.VideoStreams = append(info.VideoStreams, VideoStream{
+			Index:       stream.Index,
+			Codec:       stream.CodecName,
+			Width:       stream.Width,
+			Height:      stream.Height,
+			FrameRate:   parseFrameRate(stream.AvgFrameRate),
+			Disposition: dispositionFlags(stream.Disposition),
+			Title:       stream.Tags["title"],
+			Kind:        kind,
+		})
 	}
 
+	info.AudioTracks = parseAudioTracks(probe.Streams)
+
+	info.SubtitleTracks = append(info.SubtitleTracks, parseSubtitleTracks(probe.Streams)...)
+
 	if info.VideoBitrate == 0 {
 		if overallBitrate > 0 {
 			estimatedAudioBitrate := int64(len(info.AudioTracks)) * 256000 // 256kbps per track estimate
@@ -246,6 +416,66 @@ func (ma *MediaAnalyzer) parseFFprobeOutput(probe *FFProbeOutput, info *MediaInf
 	return nil
 }
 
+// Scan types recorded in MediaInfo.ScanType
+const (
+	ScanProgressive = "progressive"
+	ScanInterlaced  = "interlaced"
+	ScanTelecined   = "telecined" // Film frames flagged for 3:2 pulldown to NTSC field rates
+)
+
+// pixelFormatDepthPattern finds the bit depth suffix of high bit depth pixel formats such as yuv420p10le or p010le
+var pixelFormatDepthPattern = regexp.MustCompile(`p0?(\d{2})(?:le|be)?$`)
+
+// streamBitDepth returns the bits per sample reported by ffprobe, falling back to the pixel format
+func streamBitDepth(stream Stream) int {
+	if depth, err := strconv.Atoi(stream.BitsPerSample); err == nil && depth > 0 {
+		return depth
+	}
+	if stream.PixelFormat == "" {
+		return 0
+	}
+	if match := pixelFormatDepthPattern.FindStringSubmatch(stream.PixelFormat); match != nil {
+		depth, _ := strconv.Atoi(match[1])
+		return depth
+	}
+	return 8
+}
+
+// streamScanType classifies a stream from its field order. Soft-telecined film is detected by a
+// 29.97 fps container rate carrying 23.976 fps of real frames.
+func streamScanType(stream Stream) string {
+	realRate, avgRate := parseFrameRate(stream.RFrameRate), parseFrameRate(stream.AvgFrameRate)
+	if avgRate > 0 && math.Abs(realRate/avgRate-1.25) < 0.01 {
+		return ScanTelecined
+	}
+	switch stream.FieldOrder {
+	case "progressive":
+		return ScanProgressive
+	case "tt", "bb", "tb", "bt":
+		return ScanInterlaced
+	}
+	return ""
+}
+
+// vfrTolerance is how far a stream's average frame rate may stray from its base rate before it
+// is considered variable, allowing for rounding in container timestamps
+const vfrTolerance = 0.01
+
+// streamIsVFR reports whether a stream has a variable frame rate, as phone and screen recordings
+// often do: its average rate falls well short of the base rate its timestamps use. Field-rate
+// interlaced and soft-telecined streams have fixed ratios between the two and are not VFR.
+func streamIsVFR(stream Stream) bool {
+	realRate, avgRate := parseFrameRate(stream.RFrameRate), parseFrameRate(stream.AvgFrameRate)
+	if realRate <= 0 || avgRate <= 0 {
+		return false
+	}
+	ratio := realRate / avgRate
+	if math.Abs(ratio-1.25) < 0.01 || math.Abs(ratio-2) < 0.01 {
+		return false
+	}
+	return math.Abs(ratio-1) > vfrTolerance
+}
+
 // formatLevel converts numeric level to readable format
 func formatLevel(level int) string {
 	// HEVC levels: 30=1, 60=2, 63=2.1, 90=3, 93=3.1, 120=4, 123=4.1, 150=5, 153=5.1, 156=5.2, 180=6, 183=6.1, 186=6.2
@@ -281,11 +511,59 @@ func formatLevel(level int) string {
 	}
 }
 
-// CheckFFprobeAvailable verifies that ffprobe is available in PATH
+// CheckFFprobeAvailable verifies that ffprobe is installed in the tools directory or available in PATH
 func CheckFFprobeAvailable() error {
-	_, err := exec.LookPath("ffprobe")
+	_, err := exec.LookPath(ToolCommand("ffprobe"))
 	if err != nil {
-		return fmt.Errorf("ffprobe not found in PATH - please install FFmpeg")
+		return ToolNotFoundError("ffprobe", "please install FFmpeg or run media-mgmt tools install")
 	}
 	return nil
 }
+
+// parseSubtitleTracks lists the subtitle streams in order, classified by kind
+func parseSubtitleTracks(streams []Stream) []SubtitleTrack {
+	var tracks []SubtitleTrack
+	for _, stream := range streams {
+		if stream.CodecType != "subtitle" {
+			continue
+		}
+		tracks = append(tracks, SubtitleTrack{
+			Index:    stream.Index,
+			Codec:    stream.CodecName,
+			Language: stream.Tags["language"],
+			Title:    stream.Tags["title"],
+			Kind:     ClassifySubtitleTrack(stream),
+		})
+	}
+	return tracks
+}
+
+// parseAudioTracks lists the audio streams in order, classified by role
+func parseAudioTracks(streams []Stream) []AudioTrack {
+	var tracks []AudioTrack
+	for _, stream := range streams {
+		if stream.CodecType != "audio" {
+			continue
+		}
+		track := AudioTrack{
+			Index:    stream.Index,
+			Codec:    stream.CodecName,
+			Profile:  stream.Profile,
+			Channels: stream.Channels,
+			Language: stream.Tags["language"],
+			Title:    stream.Tags["title"],
+		}
+
+		bitrate := stream.Bitrate
+		if bitrate == "" {
+			bitrate = stream.Tags["BPS"]
+		}
+		if value, err := strconv.ParseInt(bitrate, 10, 64); err == nil {
+			track.Bitrate = value
+		}
+
+		tracks = append(tracks, track)
+	}
+	ClassifyAudioTracks(tracks, streams)
+	return tracks
+}
@@ -0,0 +1,73 @@
+package lib
+
+import "testing"
+
+func TestStreamBitDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   Stream
+		expected int
+	}{
+		{"reported by ffprobe", Stream{BitsPerSample: "10", PixelFormat: "yuv420p"}, 10},
+		{"8-bit pixel format", Stream{PixelFormat: "yuv420p"}, 8},
+		{"10-bit pixel format", Stream{PixelFormat: "yuv420p10le"}, 10},
+		{"12-bit planar RGB", Stream{PixelFormat: "gbrp12le"}, 12},
+		{"semi-planar 10-bit", Stream{PixelFormat: "p010le"}, 10},
+		{"unknown", Stream{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamBitDepth(tt.stream); got != tt.expected {
+				t.Errorf("streamBitDepth() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamScanType(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   Stream
+		expected string
+	}{
+		{"progressive", Stream{FieldOrder: "progressive", RFrameRate: "24000/1001", AvgFrameRate: "24000/1001"}, ScanProgressive},
+		{"top field first", Stream{FieldOrder: "tt", RFrameRate: "30000/1001", AvgFrameRate: "30000/1001"}, ScanInterlaced},
+		{"bottom field first", Stream{FieldOrder: "bb"}, ScanInterlaced},
+		{"soft telecine", Stream{FieldOrder: "tt", RFrameRate: "30000/1001", AvgFrameRate: "24000/1001"}, ScanTelecined},
+		{"60 fps progressive", Stream{FieldOrder: "progressive", RFrameRate: "60/1", AvgFrameRate: "60/1"}, ScanProgressive},
+		{"unknown", Stream{RFrameRate: "25/1", AvgFrameRate: "25/1"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamScanType(tt.stream); got != tt.expected {
+				t.Errorf("streamScanType() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStreamIsVFR(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   Stream
+		expected bool
+	}{
+		{"constant", Stream{RFrameRate: "24000/1001", AvgFrameRate: "24000/1001"}, false},
+		{"phone recording", Stream{RFrameRate: "30/1", AvgFrameRate: "17783/600"}, true},
+		{"screen recording", Stream{RFrameRate: "60/1", AvgFrameRate: "2500/103"}, true},
+		{"timestamp rounding", Stream{RFrameRate: "25/1", AvgFrameRate: "24997/1000"}, false},
+		{"field rate", Stream{RFrameRate: "60000/1001", AvgFrameRate: "30000/1001"}, false},
+		{"soft telecine", Stream{RFrameRate: "30000/1001", AvgFrameRate: "24000/1001"}, false},
+		{"unknown", Stream{RFrameRate: "0/0", AvgFrameRate: "0/0"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := streamIsVFR(tt.stream); got != tt.expected {
+				t.Errorf("streamIsVFR() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
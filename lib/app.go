@@ -4,30 +4,80 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"sort"
 	"time"
 )
 
 type App struct {
-	InputDir    string
-	OutputDir   string
-	Parallelism int
-	NoCache     bool
+	InputDir        string // Local directory, or the URL of a remote library (see OpenRemoteLibrary)
+	OutputDir       string
+	Parallelism     int
+	NoCache         bool
+	Cache           CacheOptions   // Storage and keying of the analysis cache
+	ScanWorkers     int            // Directories read at once while scanning (0 uses DefaultScanWorkers)
+	AccurateBitrate bool           // Measure bitrates from packets with an extra demux pass per file
+	GraphPatterns   []string       // Files to embed a bitrate-over-time graph for in the HTML report
+	ProbeTimeout    time.Duration  // Time limit for each ffprobe attempt (0 waits indefinitely)
+	ProbeRetries    int            // Times a failed or timed out ffprobe is repeated
+	Thumbnails      int            // Keyframe thumbnails to extract per file for the HTML report (0 disables)
+	Formats         []string       // Report formats to generate (defaults to DefaultReportFormats)
+	History         *HistoryStore  // Ledger for fresh analyses (nil disables)
+	Snapshots       *SnapshotStore // Library snapshots the report trends are built from (nil disables)
+	Library         string         // Name the run's snapshot is recorded under (defaults to the input directory's name)
+	SavingsQuality  int            // Quality target for transcode recommendations (defaults to DefaultSavingsQuality)
+	Branding        ReportBranding // Title, logo, and notes heading the reports
+	Filters         []SavedFilter  // Saved filters offered as views in the HTML report
+	Hooks           []ReportHook   // External commands run after the reports are written
+	Summary         *RunSummary    // Outcome of the last Run, set once analysis completes
+	Failures        []FileFailure  // Files the last Run could not analyze, listed in every report
+	Scanned         int            // Video files found by the last Run
+	CacheHits       int            // Files the last Run took from the analysis cache
+	CacheMisses     int            // Files the last Run looked up in the analysis cache and analyzed
+	MediaInfos      []*MediaInfo   // Files analyzed by the last Run, including archived stubs
+
+	started time.Time // When the last Run began, for the duration recorded in its snapshot
 }
 
 func (a *App) Run(ctx context.Context) error {
 	slog.Debug("Application starting", "config", fmt.Sprintf("%+v", a))
+	a.started = time.Now()
 
-	if err := CheckFFprobeAvailable(); err != nil {
-		return err
-	}
+	var remote RemoteLibrary
+	var ffprobeErr error
+	var videoFiles []string
+	var archived []*MediaInfo
+	if IsRemoteInput(a.InputDir) {
+		var err error
+		if remote, err = OpenRemoteLibrary(ctx, a.InputDir); err != nil {
+			return err
+		}
+		defer remote.Close()
+		if videoFiles, err = remote.ScanVideoFiles(ctx); err != nil {
+			return fmt.Errorf("failed to scan video files: %w", err)
+		}
+	} else {
+		// Without ffprobe, reports can still be generated when every file has a valid cache entry
+		ffprobeErr = CheckFFprobeAvailable()
+		if ffprobeErr != nil && a.NoCache {
+			return ffprobeErr
+		}
 
-	scanner := NewFileScanner(a.InputDir)
-	videoFiles, err := scanner.ScanVideoFiles(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to scan video files: %w", err)
+		scanner := NewFileScanner(a.InputDir)
+		if a.ScanWorkers > 0 {
+			scanner.Workers = a.ScanWorkers
+		}
+		var err error
+		if videoFiles, err = scanner.ScanVideoFiles(ctx); err != nil {
+			return fmt.Errorf("failed to scan video files: %w", err)
+		}
+		if archived, err = LoadArchiveStubs(ctx, a.InputDir); err != nil {
+			return fmt.Errorf("failed to load archive stubs: %w", err)
+		}
 	}
+	a.Scanned = len(videoFiles)
 
-	if len(videoFiles) == 0 {
+	if len(videoFiles) == 0 && len(archived) == 0 {
 		slog.Warn("No video files found in directory", "dir", a.InputDir)
 		return nil
 	}
@@ -37,7 +87,11 @@ func (a *App) Run(ctx context.Context) error {
 		slog.Debug("Caching disabled, using direct processor")
 		processor = NewMediaProcessor(a.Parallelism)
 	} else {
-		cache := NewCacheManager(a.OutputDir)
+		cache, err := OpenCache(a.OutputDir, a.Cache)
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
 		if err := cache.EnsureCacheDir(); err != nil {
 			return fmt.Errorf("failed to create cache directory: %w", err)
 		}
@@ -46,14 +100,41 @@ func (a *App) Run(ctx context.Context) error {
 			slog.Warn("Failed to clean old cache files", "error", err)
 		}
 
-		slog.Debug("Caching enabled", "cacheDir", cache.CacheDir)
+		if ffprobeErr != nil {
+			if uncached := UncachedFiles(cache, videoFiles); len(uncached) > 0 {
+				return fmt.Errorf("%w (%d of %d files have no cached analysis)", ffprobeErr, len(uncached), len(videoFiles))
+			}
+			slog.Warn("ffprobe not found, generating reports from cached analysis only", "files", len(videoFiles))
+		}
+
+		slog.Debug("Caching enabled", "cache", cache.Location())
 		processor = NewMediaProcessorWithCache(a.Parallelism, cache)
 	}
+	processor.History = a.History
+	processor.analyzer.AccurateBitrate = a.AccurateBitrate
+	processor.analyzer.GraphPatterns = a.GraphPatterns
+	processor.analyzer.ProbeTimeout = a.ProbeTimeout
+	processor.analyzer.ProbeRetries = a.ProbeRetries
+	processor.analyzer.Remote = remote
 
 	mediaInfos, err := processor.ProcessFiles(ctx, videoFiles)
 	if err != nil {
 		return fmt.Errorf("failed to process video files: %w", err)
 	}
+	a.Failures = processor.Failures
+	a.CacheHits, a.CacheMisses = processor.CacheCounts()
+	a.Summary = AnalysisSummary(a.InputDir, videoFiles, mediaInfos, a.Failures)
+	if a.Thumbnails > 0 {
+		slog.Info("Extracting thumbnails", "files", len(mediaInfos), "per_file", a.Thumbnails)
+		if err := GenerateThumbnails(ctx, a.OutputDir, mediaInfos, a.Thumbnails, a.Parallelism); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			slog.Warn("Skipping thumbnails", "error", err)
+		}
+	}
+	mediaInfos = append(mediaInfos, archived...)
+	a.MediaInfos = mediaInfos
 
 	if len(mediaInfos) == 0 {
 		slog.Warn("No files were successfully analyzed")
@@ -61,9 +142,108 @@ func (a *App) Run(ctx context.Context) error {
 	}
 
 	reporter := NewReportGenerator(a.OutputDir)
-	if err := reporter.GenerateAllReports(mediaInfos); err != nil {
+	reporter.Formats = a.Formats
+	reporter.Branding = a.Branding
+	reporter.Filters = a.Filters
+	reporter.Hooks = a.Hooks
+	reporter.Failures = a.Failures
+	if !IsRemoteInput(a.InputDir) {
+		reporter.InputDir = a.InputDir
+	}
+	reporter.Trends, reporter.Previous = a.recordTrend(mediaInfos)
+	quality := a.SavingsQuality
+	if quality == 0 {
+		quality = DefaultSavingsQuality
+	}
+	reporter.Savings = RecommendTranscodes(mediaInfos, quality, DefaultMinSavingsRatio)
+	if a.History != nil {
+		comparisons, err := a.History.TranscodeComparisons(a.InputDir)
+		if err != nil {
+			slog.Warn("Failed to load transcode history for report", "error", err)
+		}
+		reporter.Comparisons = comparisons
+	}
+	if err := reporter.GenerateAllReports(ctx, mediaInfos); err != nil {
 		return fmt.Errorf("failed to generate reports: %w", err)
 	}
 
 	return nil
 }
+
+// recordTrend appends a snapshot of this run to the snapshot store and returns the weekly
+// trend for the input directory, including the new snapshot, along with the input directory's
+// previous snapshot (nil on the first run).
+func (a *App) recordTrend(mediaInfos []*MediaInfo) ([]LibrarySnapshot, *LibrarySnapshot) {
+	if a.Snapshots == nil {
+		return nil, nil
+	}
+	inputDir, err := filepath.Abs(a.InputDir)
+	if err != nil || IsRemoteInput(a.InputDir) {
+		inputDir = a.InputDir
+	}
+
+	failures := 0
+	if a.Summary != nil {
+		failures = len(a.Summary.Failures)
+	}
+	name := a.Library
+	if name == "" {
+		name = filepath.Base(inputDir)
+	}
+	snapshot := NewLibrarySnapshot(name, inputDir, mediaInfos, failures)
+	if !a.started.IsZero() {
+		snapshot.DurationMS = time.Since(a.started).Milliseconds()
+	}
+
+	snapshots, err := a.Snapshots.Snapshots("")
+	if err != nil {
+		slog.Warn("Failed to read trend snapshots", "error", err)
+	}
+	if err := a.Snapshots.Append(snapshot); err != nil {
+		slog.Warn("Failed to record trend snapshot", "error", err)
+	}
+
+	var library []LibrarySnapshot
+	var previous *LibrarySnapshot
+	for i, s := range snapshots {
+		if s.InputDir != inputDir {
+			continue
+		}
+		library = append(library, s)
+		if previous == nil || s.Timestamp.After(previous.Timestamp) {
+			previous = &snapshots[i]
+		}
+	}
+	return WeeklyTrend(append(library, snapshot)), previous
+}
+
+// FillRunReport records the last Run's input, per-file outcomes, counts, bytes read, and failures in report
+func (a *App) FillRunReport(report *RunReport) {
+	report.Inputs = append(report.Inputs, a.InputDir)
+	analyzed, archived := 0, 0
+	for _, info := range a.MediaInfos {
+		if info.ArchivedTo != "" {
+			archived++
+			report.Files = append(report.Files, FileOutcome{File: info.FilePath, Outcome: "archived"})
+			continue
+		}
+		analyzed++
+		report.BytesProcessed += info.FileSize
+		report.Files = append(report.Files, FileOutcome{File: info.FilePath, Outcome: "analyzed"})
+	}
+	for _, failure := range a.Failures {
+		report.Files = append(report.Files, FileOutcome{File: failure.File, Outcome: "failed", Error: failure.Error})
+	}
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].File < report.Files[j].File
+	})
+	report.Counts["scanned"] = a.Scanned
+	report.Counts["analyzed"] = analyzed
+	report.Counts["archived"] = archived
+	report.Counts["failed"] = len(a.Failures)
+	if !a.NoCache {
+		report.Counts["cache_hits"] = a.CacheHits
+		report.Counts["cache_misses"] = a.CacheMisses
+	}
+	report.Failures = append(report.Failures, a.Failures...)
+}
@@ -0,0 +1,322 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// stubExtension is appended to a media file's path to name its archive stub
+const stubExtension = ".stub"
+
+// ArchiveStub records where an archived file now lives.
+// Left in place of the original so reports still show the file and its location.
+type ArchiveStub struct {
+	OriginalPath string     `json:"original_path"`
+	ArchivedTo   string     `json:"archived_to"`
+	ArchivedAt   time.Time  `json:"archived_at"`
+	ArchivedSize int64      `json:"archived_size"`
+	SHA256       string     `json:"sha256"`
+	Transcoded   bool       `json:"transcoded"`
+	MediaInfo    *MediaInfo `json:"media_info,omitempty"`
+}
+
+// ArchiveRules selects which files are eligible for archival.
+// All configured rules must match; zero values disable a rule.
+type ArchiveRules struct {
+	OlderThan time.Duration // Minimum time since last modification
+	MinSize   int64         // Minimum file size in bytes
+	Match     []string      // Glob patterns matched against the path relative to the root
+}
+
+// SelectArchiveCandidates returns the files under root that satisfy all archive rules
+func SelectArchiveCandidates(root string, files []string, rules ArchiveRules) []string {
+	cutoff := time.Now().Add(-rules.OlderThan)
+	var selected []string
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			slog.Debug("Failed to stat archive candidate", "file", file, "error", err)
+			continue
+		}
+		if rules.OlderThan > 0 && info.ModTime().After(cutoff) {
+			continue
+		}
+		if rules.MinSize > 0 && info.Size() < rules.MinSize {
+			continue
+		}
+		if len(rules.Match) > 0 && !matchesAnyGlob(root, file, rules.Match) {
+			continue
+		}
+		selected = append(selected, file)
+	}
+
+	return selected
+}
+
+// matchesAnyGlob reports whether the file's root-relative path or base name matches a pattern
+func matchesAnyGlob(root, file string, patterns []string) bool {
+	relPath, err := filepath.Rel(root, file)
+	if err != nil {
+		relPath = file
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, relPath); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, filepath.Base(file)); ok {
+			return true
+		}
+		if strings.HasSuffix(pattern, "/**") && strings.HasPrefix(relPath, strings.TrimSuffix(pattern, "**")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveDestination stores files in cold storage
+type ArchiveDestination interface {
+	// Store copies a local file to the destination under relPath and returns its location
+	Store(ctx context.Context, localPath, relPath string) (string, error)
+}
+
+// storedChecksummer is implemented by destinations that can read back a stored copy, so
+// ArchiveFile can check it before removing the original and remove it if it does not match.
+// Upload tools check their own transfers.
+type storedChecksummer interface {
+	storedChecksum(location string) (fileChecksum, error)
+	removeStored(location string) error
+}
+
+// NewArchiveDestination parses a destination spec: a local directory, s3://bucket/prefix
+// (uploaded with the aws CLI), or rclone:remote:path (uploaded with rclone).
+func NewArchiveDestination(spec string) (ArchiveDestination, error) {
+	switch {
+	case spec == "":
+		return nil, fmt.Errorf("archive destination is required")
+	case strings.HasPrefix(spec, "s3://"):
+		if _, err := exec.LookPath("aws"); err != nil {
+			return nil, fmt.Errorf("aws CLI not found in PATH, required for S3 destinations")
+		}
+		return &commandDestination{base: strings.TrimSuffix(spec, "/"), tool: "aws", args: []string{"s3", "cp", "--only-show-errors"}}, nil
+	case strings.HasPrefix(spec, "rclone:"):
+		if _, err := exec.LookPath("rclone"); err != nil {
+			return nil, fmt.Errorf("rclone not found in PATH, required for rclone destinations")
+		}
+		return &commandDestination{base: strings.TrimSuffix(strings.TrimPrefix(spec, "rclone:"), "/"), tool: "rclone", args: []string{"copyto"}}, nil
+	default:
+		return &localDestination{root: spec}, nil
+	}
+}
+
+// localDestination archives files to a directory on a local or mounted filesystem
+type localDestination struct {
+	root string
+}
+
+func (d *localDestination) Store(ctx context.Context, localPath, relPath string) (string, error) {
+	target := filepath.Join(d.root, filepath.FromSlash(relPath))
+	if _, err := os.Lstat(target); err == nil {
+		return "", fmt.Errorf("archive already has a file at %s", target)
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to check archive location: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	if err := copyFile(localPath, target); err != nil {
+		os.Remove(target)
+		return "", fmt.Errorf("failed to copy to archive: %w", err)
+	}
+	return target, nil
+}
+
+func (d *localDestination) storedChecksum(location string) (fileChecksum, error) {
+	return checksumFile(location)
+}
+
+func (d *localDestination) removeStored(location string) error {
+	return os.Remove(location)
+}
+
+// commandDestination archives files by invoking an external upload tool
+type commandDestination struct {
+	base string
+	tool string
+	args []string
+}
+
+func (d *commandDestination) Store(ctx context.Context, localPath, relPath string) (string, error) {
+	target := d.base + "/" + relPath
+	args := append(append([]string{}, d.args...), localPath, target)
+
+	cmd := exec.CommandContext(ctx, d.tool, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("%s upload failed: %w: %s", d.tool, err, strings.TrimSpace(string(output)))
+	}
+
+	if d.tool == "rclone" {
+		return "rclone:" + target, nil
+	}
+	return target, nil
+}
+
+// copyFile copies a file's contents and syncs them to disk
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// ArchiveStubPath returns the stub path used for an archived media file
+func ArchiveStubPath(filePath string) string {
+	return filePath + stubExtension
+}
+
+// WriteArchiveStub saves a stub next to the original file's location
+func WriteArchiveStub(stub *ArchiveStub) error {
+	data, err := json.MarshalIndent(stub, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal archive stub: %w", err)
+	}
+	if err := os.WriteFile(ArchiveStubPath(stub.OriginalPath), data, 0644); err != nil {
+		return fmt.Errorf("failed to write archive stub: %w", err)
+	}
+	return nil
+}
+
+// LoadArchiveStubs finds archive stubs under root and returns media info for the archived files.
+// Each returned MediaInfo has ArchivedTo set so reports can show where the file now lives.
+// Stubs excluded by .mediamgmtignore files are left out, as their videos would be.
+func LoadArchiveStubs(ctx context.Context, root string) ([]*MediaInfo, error) {
+	var mediaInfos []*MediaInfo
+	ignore := NewIgnoreMatcher(root)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if ignore.Ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			ignore.Enter(path)
+			return nil
+		}
+		if !strings.HasSuffix(path, stubExtension) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			slog.Warn("Failed to read archive stub", "path", path, "error", err)
+			return nil
+		}
+
+		var stub ArchiveStub
+		if err := json.Unmarshal(data, &stub); err != nil {
+			slog.Warn("Failed to parse archive stub", "path", path, "error", err)
+			return nil
+		}
+
+		info := stub.MediaInfo
+		if info == nil {
+			info = &MediaInfo{FilePath: stub.OriginalPath, FileSize: stub.ArchivedSize}
+		}
+		info.ArchivedTo = stub.ArchivedTo
+		mediaInfos = append(mediaInfos, info)
+		return nil
+	})
+
+	return mediaInfos, err
+}
+
+// ArchiveFile uploads uploadPath to the destination, writes a stub for originalPath, and
+// removes the local copies. uploadPath is either the original or an archival transcode of it.
+// The local copies are kept if the stored copy does not match the checksum recorded in the stub,
+// and the stored copy is removed so the file can be archived again.
+func ArchiveFile(ctx context.Context, dest ArchiveDestination, root, originalPath, uploadPath string, info *MediaInfo) (*ArchiveStub, error) {
+	sum, err := checksumFile(uploadPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum %s: %w", uploadPath, err)
+	}
+
+	relPath, err := filepath.Rel(root, filepath.Join(filepath.Dir(originalPath), filepath.Base(uploadPath)))
+	if err != nil || IsOutsideRel(relPath) {
+		relPath = filepath.Base(uploadPath)
+	}
+
+	location, err := dest.Store(ctx, uploadPath, filepath.ToSlash(relPath))
+	if err != nil {
+		return nil, err
+	}
+	if checksummer, ok := dest.(storedChecksummer); ok {
+		stored, err := checksummer.storedChecksum(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to checksum archived copy %s: %w", location, err)
+		}
+		if stored != sum {
+			if err := checksummer.removeStored(location); err != nil {
+				slog.Warn("Failed to remove mismatched archived copy", "location", location, "error", err)
+			}
+			return nil, fmt.Errorf("archived copy %s does not match %s (sha256 %s, expected %s)", location, uploadPath, stored.sha256, sum.sha256)
+		}
+	}
+
+	stub := &ArchiveStub{
+		OriginalPath: originalPath,
+		ArchivedTo:   location,
+		ArchivedAt:   time.Now().UTC(),
+		ArchivedSize: sum.size,
+		SHA256:       sum.sha256,
+		Transcoded:   uploadPath != originalPath,
+		MediaInfo:    info,
+	}
+	if err := WriteArchiveStub(stub); err != nil {
+		return nil, err
+	}
+
+	for _, path := range []string{uploadPath, originalPath} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove archived file", "file", path, "error", err)
+		}
+	}
+
+	return stub, nil
+}
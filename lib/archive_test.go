@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveFile_SelectsStoresAndStubs(t *testing.T) {
+	root := t.TempDir()
+	oldFile := filepath.Join(root, "old", "movie.mkv")
+	newFile := filepath.Join(root, "new.mkv")
+	for _, file := range []string{oldFile, newFile} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("video data"), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(oldFile, past, past); err != nil {
+		t.Fatalf("Failed to set file time: %v", err)
+	}
+
+	selected := SelectArchiveCandidates(root, []string{oldFile, newFile}, ArchiveRules{OlderThan: 24 * time.Hour, Match: []string{"old/**"}})
+	if len(selected) != 1 || selected[0] != oldFile {
+		t.Fatalf("Expected only %s to be selected, got %v", oldFile, selected)
+	}
+
+	archiveDir := t.TempDir()
+	dest, err := NewArchiveDestination(archiveDir)
+	if err != nil {
+		t.Fatalf("NewArchiveDestination failed: %v", err)
+	}
+
+	ctx := context.Background()
+	info := &MediaInfo{FilePath: oldFile, VideoCodec: "h264"}
+	stub, err := ArchiveFile(ctx, dest, root, oldFile, oldFile, info)
+	if err != nil {
+		t.Fatalf("ArchiveFile failed: %v", err)
+	}
+
+	if stub.ArchivedTo != filepath.Join(archiveDir, "old", "movie.mkv") {
+		t.Errorf("Unexpected archive location %s", stub.ArchivedTo)
+	}
+	if _, err := os.Stat(stub.ArchivedTo); err != nil {
+		t.Errorf("Archived copy missing: %v", err)
+	}
+	if _, err := os.Stat(oldFile); !os.IsNotExist(err) {
+		t.Errorf("Expected original to be removed, got %v", err)
+	}
+
+	loaded, err := LoadArchiveStubs(ctx, root)
+	if err != nil {
+		t.Fatalf("LoadArchiveStubs failed: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].FilePath != oldFile || loaded[0].VideoCodec != "h264" || loaded[0].ArchivedTo != stub.ArchivedTo {
+		t.Errorf("Unexpected stub media info: %+v", loaded)
+	}
+}
+
+// corruptingDestination stores files locally, then damages the stored copy
+type corruptingDestination struct {
+	*localDestination
+}
+
+func (d corruptingDestination) Store(ctx context.Context, localPath, relPath string) (string, error) {
+	location, err := d.localDestination.Store(ctx, localPath, relPath)
+	if err != nil {
+		return "", err
+	}
+	return location, os.WriteFile(location, []byte("damaged"), 0644)
+}
+
+func TestArchiveFile_KeepsOriginalUnlessStoredCopyMatches(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		existing bool // Whether the archive already has a file at the target
+		corrupt  bool // Whether the stored copy is damaged after Store
+	}{
+		{"target already exists", true, false},
+		{"stored copy does not match", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			file := filepath.Join(root, "movie.mkv")
+			if err := os.WriteFile(file, []byte("video data"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			archiveDir := t.TempDir()
+			target := filepath.Join(archiveDir, "movie.mkv")
+			if tt.existing {
+				if err := os.WriteFile(target, []byte("other video"), 0644); err != nil {
+					t.Fatalf("Failed to write file: %v", err)
+				}
+			}
+			var dest ArchiveDestination = &localDestination{root: archiveDir}
+			if tt.corrupt {
+				dest = corruptingDestination{&localDestination{root: archiveDir}}
+			}
+
+			if _, err := ArchiveFile(ctx, dest, root, file, file, nil); err == nil {
+				t.Fatal("Expected ArchiveFile to fail")
+			}
+			if data, err := os.ReadFile(file); err != nil || string(data) != "video data" {
+				t.Errorf("Expected the original to be kept, got %q, %v", data, err)
+			}
+			if _, err := os.Stat(ArchiveStubPath(file)); !os.IsNotExist(err) {
+				t.Errorf("Expected no stub to be written, got %v", err)
+			}
+			if tt.existing {
+				if data, _ := os.ReadFile(target); string(data) != "other video" {
+					t.Errorf("Expected the existing archived file to be left alone, got %q", data)
+				}
+			}
+			if tt.corrupt {
+				if _, err := os.Stat(target); !os.IsNotExist(err) {
+					t.Errorf("Expected the mismatched archived copy to be removed, got %v", err)
+				}
+				if _, err := ArchiveFile(ctx, &localDestination{root: archiveDir}, root, file, file, nil); err != nil {
+					t.Errorf("Expected archiving again to succeed, got %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit entry sources
+const (
+	AuditSourceCLI       = "cli"
+	AuditSourceAPI       = "api"
+	AuditSourceScheduler = "scheduler"
+)
+
+// AuditEntry records one invocation of a tool action: who ran it, how, and what it changed
+type AuditEntry struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	User       string            `json:"user"`
+	Host       string            `json:"host,omitempty"`
+	Source     string            `json:"source"`
+	Command    string            `json:"command"`
+	Flags      map[string]string `json:"flags,omitempty"`
+	Args       []string          `json:"args,omitempty"`
+	Files      []string          `json:"files,omitempty"` // Files created, replaced, moved, or removed
+	Error      string            `json:"error,omitempty"`
+	DurationMS int64             `json:"duration_ms"`
+}
+
+// AuditLog persists audit entries as append-only JSON lines shared by every command
+type AuditLog struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{Path: path}
+}
+
+// DefaultAuditPath returns the location of the audit log in the state directory
+func DefaultAuditPath() string {
+	return filepath.Join(DefaultStateDir(), "audit.jsonl")
+}
+
+// CurrentUser returns the name of the user running the tool, preferring the invoking user under sudo
+func CurrentUser() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" {
+		return sudoUser
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Append adds an entry to the audit log, filling in the timestamp and host if unset
+func (al *AuditLog) Append(entry AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	if entry.Host == "" {
+		entry.Host, _ = os.Hostname()
+	}
+
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	if err := appendJSONLine(al.Path, entry); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Record appends an entry, logging rather than returning failures. Safe to call on a nil log.
+func (al *AuditLog) Record(entry AuditEntry) {
+	if al == nil {
+		return
+	}
+	if err := al.Append(entry); err != nil {
+		slog.Warn("Failed to record audit entry", "command", entry.Command, "error", err)
+	}
+}
+
+// AuditFilter selects audit entries; zero values match everything
+type AuditFilter struct {
+	Since   time.Time
+	Command string // Matches entries whose command contains this text
+	User    string
+	Limit   int // Keep only the most recent entries
+}
+
+// Entries reads audit entries matching the filter, oldest first
+func (al *AuditLog) Entries(filter AuditFilter) ([]AuditEntry, error) {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+
+	var entries []AuditEntry
+	err := readJSONLines(al.Path, func(line []byte) {
+		var entry AuditEntry
+		if json.Unmarshal(line, &entry) != nil {
+			return
+		}
+		if !filter.Since.IsZero() && entry.Timestamp.Before(filter.Since) {
+			return
+		}
+		if filter.Command != "" && !strings.Contains(entry.Command, filter.Command) {
+			return
+		}
+		if filter.User != "" && entry.User != filter.User {
+			return
+		}
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[len(entries)-filter.Limit:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditLog_Entries(t *testing.T) {
+	log := NewAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+
+	entries, err := log.Entries(AuditFilter{})
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("Entries on a missing log = %v, %v; want none", entries, err)
+	}
+
+	now := time.Now()
+	for _, entry := range []AuditEntry{
+		{Timestamp: now.Add(-48 * time.Hour), User: "alice", Command: "transcode"},
+		{Timestamp: now.Add(-time.Hour), User: "bob", Command: "archive", Files: []string{"/media/a.mkv"}},
+		{Timestamp: now, User: "alice", Command: "stats codecs"},
+	} {
+		if err := log.Append(entry); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []string
+	}{
+		{"all", AuditFilter{}, []string{"transcode", "archive", "stats codecs"}},
+		{"since", AuditFilter{Since: now.Add(-2 * time.Hour)}, []string{"archive", "stats codecs"}},
+		{"user", AuditFilter{User: "alice"}, []string{"transcode", "stats codecs"}},
+		{"command", AuditFilter{Command: "stats"}, []string{"stats codecs"}},
+		{"limit keeps most recent", AuditFilter{Limit: 1}, []string{"stats codecs"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := log.Entries(tt.filter)
+			if err != nil {
+				t.Fatalf("Entries failed: %v", err)
+			}
+			if len(entries) != len(tt.want) {
+				t.Fatalf("Entries returned %d entries, want %d", len(entries), len(tt.want))
+			}
+			for i, entry := range entries {
+				if entry.Command != tt.want[i] {
+					t.Errorf("entry %d command = %q, want %q", i, entry.Command, tt.want[i])
+				}
+				if entry.Host == "" {
+					t.Errorf("entry %d host was not filled in", i)
+				}
+			}
+		})
+	}
+}
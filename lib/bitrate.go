@@ -0,0 +1,304 @@
+package lib
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BitrateStats are bitrates measured from every packet in a file, rather than estimated from
+// container headers
+type BitrateStats struct {
+	Streams          []StreamBitrate `json:"streams"`
+	VideoPeakBitrate int64           `json:"video_peak_bitrate"` // Highest one-second bitrate of the primary video stream
+	VideoVariability float64         `json:"video_variability"`  // Coefficient of variation of the primary video's one-second bitrates
+}
+
+// StreamBitrate is the measured average bitrate of one stream
+type StreamBitrate struct {
+	Index     int    `json:"index"`
+	CodecType string `json:"codec_type"`
+	Bitrate   int64  `json:"bitrate"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// StreamBitrate returns the measured bitrate of a stream, or 0 if it was not measured
+func (bs *BitrateStats) StreamBitrate(index int) int64 {
+	if bs == nil {
+		return 0
+	}
+	for _, stream := range bs.Streams {
+		if stream.Index == index {
+			return stream.Bitrate
+		}
+	}
+	return 0
+}
+
+// PeakVideoBitrate returns the primary video's peak bitrate, or 0 if it was not measured
+func (bs *BitrateStats) PeakVideoBitrate() int64 {
+	if bs == nil {
+		return 0
+	}
+	return bs.VideoPeakBitrate
+}
+
+// Variability returns the primary video's coefficient of variation, or 0 if it was not measured
+func (bs *BitrateStats) Variability() float64 {
+	if bs == nil {
+		return 0
+	}
+	return bs.VideoVariability
+}
+
+// streamPackets accumulates the packets of one stream
+type streamPackets struct {
+	bytes      int64
+	start, end float64
+	timed      bool
+}
+
+// BitrateGraph is the primary video's bitrate over time, for spotting starved sections and spikes
+type BitrateGraph struct {
+	IntervalSeconds int     `json:"interval_seconds"` // Length of each point
+	Bitrates        []int64 `json:"bitrates"`         // Average bits per second of each interval, from the start
+}
+
+// maxGraphPoints bounds the points in a bitrate graph, so long files widen the interval instead
+// of bloating reports
+const maxGraphPoints = 240
+
+// packetScan is the result of reading every packet header in a file
+type packetScan struct {
+	stats        *BitrateStats
+	videoSeconds map[int]int64 // Primary video bytes per second of presentation time
+}
+
+// packetScanTimeout bounds a packet scan, which reads the whole file: the header probe timeout
+// plus the file's running time, since reading stays well ahead of playback on any storage
+// that can stream the file at all. A zero probe timeout leaves the scan unbounded too.
+func packetScanTimeout(probeTimeout time.Duration, duration float64) time.Duration {
+	if probeTimeout <= 0 {
+		return 0
+	}
+	return probeTimeout + time.Duration(duration*float64(time.Second))
+}
+
+// measureBitrates reads every packet header in a file with a demux-only ffprobe pass. This takes
+// roughly as long as reading the file from disk, so it is only done on request.
+func (ma *MediaAnalyzer) measureBitrates(ctx context.Context, filePath string, probe *FFProbeOutput, primaryIndex int, duration float64) (*packetScan, error) {
+	var scan *packetScan
+	err := probeWithin(ctx, packetScanTimeout(ma.ProbeTimeout, duration), func(ctx context.Context) error {
+		var err error
+		scan, err = scanPackets(ctx, filePath, probe, primaryIndex, duration)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return scan, nil
+}
+
+// scanPackets runs the ffprobe packet listing and parses it
+func scanPackets(ctx context.Context, filePath string, probe *FFProbeOutput, primaryIndex int, duration float64) (*packetScan, error) {
+	cmd := exec.CommandContext(ctx, ToolCommand("ffprobe"),
+		"-v", "error",
+		"-show_entries", "packet=stream_index,pts_time,dts_time,duration_time,size",
+		"-of", "compact=p=0",
+		filePath)
+	// Don't wait on output pipes after a timeout kill, in case a child still holds them
+	cmd.WaitDelay = 5 * time.Second
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffprobe: %w", err)
+	}
+
+	scan, parseErr := parsePacketBitrates(stdout, probe.Streams, primaryIndex, duration)
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("ffprobe packet scan failed: %w", err)
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if len(scan.stats.Streams) == 0 {
+		return nil, fmt.Errorf("ffprobe listed no packets")
+	}
+	return scan, nil
+}
+
+// parsePacketBitrates computes stream bitrates from ffprobe's compact packet listing, in which
+// each line looks like stream_index=0|pts_time=1.001000|dts_time=0.959000|duration_time=0.041708|size=18391.
+// Streams are timed by their own packets, falling back to the file duration.
+func parsePacketBitrates(r io.Reader, streams []Stream, primaryIndex int, duration float64) (*packetScan, error) {
+	packets := make(map[int]*streamPackets)
+	videoSeconds := make(map[int]int64)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := make(map[string]string, 5)
+		for _, field := range strings.Split(scanner.Text(), "|") {
+			if key, value, ok := strings.Cut(field, "="); ok {
+				fields[key] = value
+			}
+		}
+		index, err := strconv.Atoi(fields["stream_index"])
+		if err != nil {
+			continue
+		}
+		size, err := strconv.ParseInt(fields["size"], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		stream := packets[index]
+		if stream == nil {
+			stream = &streamPackets{}
+			packets[index] = stream
+		}
+		stream.bytes += size
+
+		timestamp, err := strconv.ParseFloat(fields["pts_time"], 64)
+		if err != nil {
+			if timestamp, err = strconv.ParseFloat(fields["dts_time"], 64); err != nil {
+				continue
+			}
+		}
+		end := timestamp
+		if packetDuration, err := strconv.ParseFloat(fields["duration_time"], 64); err == nil {
+			end += packetDuration
+		}
+		if !stream.timed || timestamp < stream.start {
+			stream.start = timestamp
+		}
+		if !stream.timed || end > stream.end {
+			stream.end = end
+		}
+		stream.timed = true
+
+		if index == primaryIndex {
+			videoSeconds[int(math.Floor(timestamp))] += size
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read packets: %w", err)
+	}
+
+	stats := &BitrateStats{}
+	for _, stream := range streams {
+		counted := packets[stream.Index]
+		if counted == nil {
+			continue
+		}
+		seconds := duration
+		if counted.timed && counted.end > counted.start {
+			seconds = counted.end - counted.start
+		}
+		var bitrate int64
+		if seconds > 0 {
+			bitrate = int64(float64(counted.bytes*8) / seconds)
+		}
+		stats.Streams = append(stats.Streams, StreamBitrate{
+			Index:     stream.Index,
+			CodecType: stream.CodecType,
+			Bitrate:   bitrate,
+			Bytes:     counted.bytes,
+		})
+	}
+	stats.VideoPeakBitrate, stats.VideoVariability = bitrateVariability(videoSeconds)
+	return &packetScan{stats: stats, videoSeconds: videoSeconds}, nil
+}
+
+// newBitrateGraph averages per-second byte counts into at most maxGraphPoints intervals.
+// Seconds without packets, such as a gap before the first frame, count as zero.
+func newBitrateGraph(bytesPerSecond map[int]int64) *BitrateGraph {
+	seconds := 0
+	for second := range bytesPerSecond {
+		if second >= 0 {
+			seconds = max(seconds, second+1)
+		}
+	}
+	if seconds == 0 {
+		return nil
+	}
+
+	interval := (seconds + maxGraphPoints - 1) / maxGraphPoints
+	graph := &BitrateGraph{IntervalSeconds: interval}
+	for start := 0; start < seconds; start += interval {
+		end := min(start+interval, seconds)
+		var bytes int64
+		for second := start; second < end; second++ {
+			bytes += bytesPerSecond[second]
+		}
+		graph.Bitrates = append(graph.Bitrates, bytes*8/int64(end-start))
+	}
+	return graph
+}
+
+// bitrateVariability returns the peak and the coefficient of variation of per-second byte
+// counts, as bits per second
+func bitrateVariability(bytesPerSecond map[int]int64) (peak int64, variability float64) {
+	if len(bytesPerSecond) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, bytes := range bytesPerSecond {
+		bits := bytes * 8
+		peak = max(peak, bits)
+		sum += float64(bits)
+	}
+	mean := sum / float64(len(bytesPerSecond))
+	if mean == 0 {
+		return peak, 0
+	}
+	var squares float64
+	for _, bytes := range bytesPerSecond {
+		diff := float64(bytes*8) - mean
+		squares += diff * diff
+	}
+	return peak, math.Sqrt(squares/float64(len(bytesPerSecond))) / mean
+}
+
+// applyBitrateStats replaces header-derived bitrates with measured ones
+func (info *MediaInfo) applyBitrateStats(stats *BitrateStats, primaryIndex int) {
+	info.BitrateStats = stats
+	if bitrate := stats.StreamBitrate(primaryIndex); bitrate > 0 {
+		info.VideoBitrate = bitrate
+	}
+	for i, track := range info.AudioTracks {
+		if bitrate := stats.StreamBitrate(track.Index); bitrate > 0 {
+			info.AudioTracks[i].Bitrate = bitrate
+		}
+	}
+}
+
+// WantsBitrateGraph reports whether a file matches one of the analyzer's graph patterns
+func (ma *MediaAnalyzer) WantsBitrateGraph(filePath string) bool {
+	for _, pattern := range ma.GraphPatterns {
+		if matched, _ := filepath.Match(pattern, filePath); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(pattern, filepath.Base(filePath)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// missingFromCache reports whether a cached analysis lacks bitrate measurements this analyzer
+// was asked for, so the file must be analyzed again
+func (ma *MediaAnalyzer) missingFromCache(filePath string, cached *MediaInfo) bool {
+	if ma.AccurateBitrate && cached.BitrateStats == nil {
+		return true
+	}
+	return ma.WantsBitrateGraph(filePath) && cached.BitrateGraph == nil
+}
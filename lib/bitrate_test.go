@@ -0,0 +1,124 @@
+package lib
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePacketBitrates(t *testing.T) {
+	streams := []Stream{
+		{Index: 0, CodecType: "video"},
+		{Index: 1, CodecType: "audio"},
+		{Index: 2, CodecType: "subtitle"},
+	}
+	// Video: 1000 bytes in the first second, 3000 in the second; audio: 500 bytes over 2 seconds
+	packets := strings.Join([]string{
+		"stream_index=0|pts_time=0.000000|dts_time=0.000000|duration_time=0.500000|size=600",
+		"stream_index=1|pts_time=0.000000|dts_time=0.000000|duration_time=1.000000|size=250",
+		"stream_index=0|pts_time=0.500000|dts_time=0.500000|duration_time=0.500000|size=400",
+		"stream_index=0|pts_time=1.000000|dts_time=1.000000|duration_time=1.000000|size=3000",
+		"stream_index=1|pts_time=1.000000|dts_time=1.000000|duration_time=1.000000|size=250",
+		"stream_index=0|pts_time=N/A|dts_time=N/A|duration_time=N/A|size=0",
+		"malformed line",
+	}, "\n")
+
+	scan, err := parsePacketBitrates(strings.NewReader(packets), streams, 0, 10)
+	if err != nil {
+		t.Fatalf("parsePacketBitrates() error = %v", err)
+	}
+	stats := scan.stats
+
+	if len(stats.Streams) != 2 {
+		t.Fatalf("expected 2 measured streams, got %+v", stats.Streams)
+	}
+	if got := stats.StreamBitrate(0); got != 16000 {
+		t.Errorf("video bitrate = %d, want 16000", got)
+	}
+	if got := stats.StreamBitrate(1); got != 2000 {
+		t.Errorf("audio bitrate = %d, want 2000", got)
+	}
+	if got := stats.StreamBitrate(2); got != 0 {
+		t.Errorf("subtitle bitrate = %d, want 0 for a stream without packets", got)
+	}
+	if stats.VideoPeakBitrate != 24000 {
+		t.Errorf("peak bitrate = %d, want 24000", stats.VideoPeakBitrate)
+	}
+	if math.Abs(stats.VideoVariability-0.5) > 1e-9 {
+		t.Errorf("variability = %v, want 0.5", stats.VideoVariability)
+	}
+}
+
+func TestApplyBitrateStats(t *testing.T) {
+	info := &MediaInfo{
+		VideoBitrate: 8500000,
+		AudioTracks:  []AudioTrack{{Index: 1, Bitrate: 0}, {Index: 2, Bitrate: 640000}},
+	}
+	stats := &BitrateStats{Streams: []StreamBitrate{{Index: 0, Bitrate: 9100000}, {Index: 1, Bitrate: 1500000}}}
+
+	info.applyBitrateStats(stats, 0)
+
+	if info.VideoBitrate != 9100000 {
+		t.Errorf("VideoBitrate = %d, want the measured 9100000", info.VideoBitrate)
+	}
+	if info.AudioTracks[0].Bitrate != 1500000 {
+		t.Errorf("audio track 1 bitrate = %d, want the measured 1500000", info.AudioTracks[0].Bitrate)
+	}
+	if info.AudioTracks[1].Bitrate != 640000 {
+		t.Errorf("audio track 2 bitrate = %d, want the header value kept", info.AudioTracks[1].Bitrate)
+	}
+}
+
+func TestNewBitrateGraph(t *testing.T) {
+	if graph := newBitrateGraph(map[int]int64{}); graph != nil {
+		t.Errorf("expected no graph without packets, got %+v", graph)
+	}
+
+	short := newBitrateGraph(map[int]int64{0: 100, 2: 300})
+	if short.IntervalSeconds != 1 || len(short.Bitrates) != 3 {
+		t.Fatalf("expected 3 one-second points, got %+v", short)
+	}
+	if short.Bitrates[0] != 800 || short.Bitrates[1] != 0 || short.Bitrates[2] != 2400 {
+		t.Errorf("unexpected bitrates %v", short.Bitrates)
+	}
+
+	// 500 seconds at 1000 bytes each need 3-second intervals to fit in maxGraphPoints
+	long := make(map[int]int64)
+	for second := 0; second < 500; second++ {
+		long[second] = 1000
+	}
+	graph := newBitrateGraph(long)
+	if graph.IntervalSeconds != 3 || len(graph.Bitrates) != 167 {
+		t.Fatalf("expected 167 three-second points, got interval %d with %d points", graph.IntervalSeconds, len(graph.Bitrates))
+	}
+	for i, bitrate := range graph.Bitrates {
+		if bitrate != 8000 {
+			t.Errorf("point %d = %d, want 8000 including the partial last interval", i, bitrate)
+		}
+	}
+}
+
+func TestMeasureBitratesTimesOut(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MEDIA_MGMT_TOOLS_DIR", dir)
+	if err := os.WriteFile(filepath.Join(dir, "ffprobe"), []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatalf("Failed to write fake tool: %v", err)
+	}
+
+	ma := &MediaAnalyzer{ProbeTimeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err := ma.measureBitrates(context.Background(), "movie.mkv", &FFProbeOutput{}, 0, 0.1)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("measureBitrates() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("measureBitrates() took %s, want it stopped at the deadline", elapsed)
+	}
+	if got := packetScanTimeout(time.Minute, 5400); got != time.Minute+90*time.Minute {
+		t.Errorf("packetScanTimeout() = %s, want 1h31m0s", got)
+	}
+}
@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultReportTitle heads reports when no title is configured
+const DefaultReportTitle = "Media Analysis Report"
+
+// ReportBranding customizes the header of Markdown and HTML reports so archived reports describe themselves
+type ReportBranding struct {
+	Title string `yaml:"title" json:"title"`
+	Logo  string `yaml:"logo" json:"logo,omitempty"`   // Image file path or http(s) URL
+	Notes string `yaml:"notes" json:"notes,omitempty"` // Free-form text shown under the title, e.g. "Post-migration snapshot"
+}
+
+// ReportTitle returns the configured title or the default
+func (b ReportBranding) ReportTitle() string {
+	if b.Title == "" {
+		return DefaultReportTitle
+	}
+	return b.Title
+}
+
+// isRemoteLogo reports whether the logo is a URL rather than a local file
+func (b ReportBranding) isRemoteLogo() bool {
+	return strings.HasPrefix(b.Logo, "http://") || strings.HasPrefix(b.Logo, "https://") || strings.HasPrefix(b.Logo, "data:")
+}
+
+// LogoSource returns an image src for the logo. Local files are embedded as data URIs so the HTML
+// report stays viewable after it is moved or archived; URLs are used as-is.
+func (b ReportBranding) LogoSource() (string, error) {
+	if b.Logo == "" || b.isRemoteLogo() {
+		return b.Logo, nil
+	}
+
+	data, err := os.ReadFile(b.Logo)
+	if err != nil {
+		return "", fmt.Errorf("failed to read report logo: %w", err)
+	}
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(b.Logo)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// ForHTML returns the branding as shown in the HTML UI: the title defaulted and the logo
+// as an embeddable src. A logo that cannot be read is omitted with a warning.
+func (b ReportBranding) ForHTML() ReportBranding {
+	b.Title = b.ReportTitle()
+	logo, err := b.LogoSource()
+	if err != nil {
+		slog.Warn("Omitting logo from report", "error", err)
+	}
+	b.Logo = logo
+	return b
+}
+
+// resolveLogo makes a relative logo path relative to the directory of the config file that set it
+func (b *ReportBranding) resolveLogo(configDir string) {
+	if b.Logo != "" && !b.isRemoteLogo() && !filepath.IsAbs(b.Logo) {
+		b.Logo = filepath.Join(configDir, b.Logo)
+	}
+}
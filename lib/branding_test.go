@@ -0,0 +1,67 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReportBranding(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := `report:
+  title: Family NAS
+  logo: logo.png
+  notes: Post-migration snapshot, March 2025
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "logo.png"), []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatalf("Failed to write logo: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	branding := config.Report
+	if branding.Logo != filepath.Join(dir, "logo.png") {
+		t.Errorf("Expected logo resolved relative to the config file, got %q", branding.Logo)
+	}
+
+	html := branding.ForHTML()
+	if html.Title != "Family NAS" || !strings.HasPrefix(html.Logo, "data:image/png;base64,") {
+		t.Errorf("Unexpected HTML branding %+v", html)
+	}
+
+	if title := (ReportBranding{}).ForHTML().Title; title != DefaultReportTitle {
+		t.Errorf("Expected default title, got %q", title)
+	}
+	remote := ReportBranding{Logo: "https://example.com/logo.svg"}
+	if src, err := remote.LogoSource(); err != nil || src != remote.Logo {
+		t.Errorf("Expected remote logo used as-is, got %q, err %v", src, err)
+	}
+	if logo := (ReportBranding{Logo: filepath.Join(dir, "missing.png")}).ForHTML().Logo; logo != "" {
+		t.Errorf("Expected unreadable logo to be omitted, got %q", logo)
+	}
+}
+
+func TestGenerateMarkdownBranding(t *testing.T) {
+	dir := t.TempDir()
+	rg := NewReportGenerator(dir)
+	rg.Branding = ReportBranding{Title: "Family NAS", Notes: "Post-migration snapshot"}
+	if err := rg.GenerateMarkdown([]*MediaInfo{{FilePath: "/media/a.mkv"}}, "report.md"); err != nil {
+		t.Fatalf("GenerateMarkdown failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "report.md"))
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	report := string(data)
+	if !strings.HasPrefix(report, "# Family NAS\n") || !strings.Contains(report, "## Notes\n\nPost-migration snapshot\n") {
+		t.Errorf("Markdown report is missing branding:\n%s", report)
+	}
+}
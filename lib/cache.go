@@ -8,11 +8,142 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
 	"time"
 )
 
-type CacheManager struct {
+// Cache backends selectable with --cache-backend
+const (
+	CacheBackendAuto   = "auto"   // SQLite if the output directory already has a SQLite cache, otherwise JSON
+	CacheBackendJSON   = "json"   // One JSON file per analyzed file
+	CacheBackendSQLite = "sqlite" // A single indexed SQLite database, for libraries of 100k+ files
+)
+
+// ValidateCacheBackend checks a --cache-backend value
+func ValidateCacheBackend(backend string) error {
+	switch backend {
+	case CacheBackendAuto, CacheBackendJSON, CacheBackendSQLite:
+		return nil
+	}
+	return fmt.Errorf("invalid --cache-backend value %q: must be auto, json, or sqlite", backend)
+}
+
+// CacheSchemaVersion is the version of the analysis stored in new cache entries. Bump it when
+// MediaInfo gains or changes fields, adding a migration to cacheMigrations if older entries hold
+// enough to fill them in; entries that cannot be upgraded are analyzed again.
+const CacheSchemaVersion = 2
+
+// cacheMigrations upgrade an entry's analysis from the version it is keyed by to the next.
+// Version 0 entries predate versioning and lack fields such as the frame rate and HDR metadata,
+// which only ffprobe can supply, so they have no migration.
+var cacheMigrations = map[int]func(*MediaInfo){
+	// Version 2 added the content type, which depends only on the path and duration
+	1: func(info *MediaInfo) { info.ContentType = DetectContentType(info.FilePath, info.Duration) },
+}
+
+// cacheMaxAge is how long an analysis stays valid before the file is analyzed again
+const cacheMaxAge = 30 * 24 * time.Hour
+
+// CacheManager stores analysis results between runs so unchanged files are not probed again.
+// Implementations are safe for concurrent use by analysis workers.
+type CacheManager interface {
+	// EnsureCacheDir creates the cache's storage if it doesn't exist
+	EnsureCacheDir() error
+	// HasValidCache returns the cached analysis of a file, if one exists and the file's size and
+	// modification time still match it
+	HasValidCache(filePath string, fileInfo os.FileInfo) (bool, *MediaInfo, error)
+	// SaveCache stores the analysis of a file
+	SaveCache(filePath string, fileInfo os.FileInfo, mediaInfo *MediaInfo) error
+	// LoadEntries returns every cache entry, sorted by file path
+	LoadEntries() ([]*CacheEntry, error)
+	// CleanOldCache removes entries saved longer ago than maxAge
+	CleanOldCache(maxAge time.Duration) error
+	// Location describes where the cache is stored, for messages
+	Location() string
+	// Close releases the cache's resources
+	Close() error
+}
+
+// CacheDirEnv names the environment variable that overrides the cache location, such as a
+// volume mounted into a container
+const CacheDirEnv = "MEDIA_MGMT_CACHE_DIR"
+
+// CacheOptions select where and how an analysis cache is stored
+type CacheOptions struct {
+	Dir     string // Cache directory shared between output directories (empty uses <output>/.cache)
+	Backend string // One of the CacheBackend values (empty is auto)
+	Key     string // One of the CacheKey values (empty is path)
+}
+
+// ResolveCacheDir picks the cache directory from the --cache-dir flag, then $MEDIA_MGMT_CACHE_DIR,
+// then cache.dir in the config file. An empty result keeps the cache in the output directory.
+func ResolveCacheDir(flag string, config *Config) string {
+	if flag != "" {
+		return flag
+	}
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir
+	}
+	return config.Cache.Dir
+}
+
+// OpenCache opens the analysis cache in options.Dir, or in the .cache directory of an output
+// directory if it is empty. Opening a SQLite cache moves any JSON cache entries into it.
+func OpenCache(outputDir string, options CacheOptions) (CacheManager, error) {
+	cacheDir := options.Dir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(outputDir, ".cache")
+	}
+	store, err := openCacheBackend(cacheDir, options.Backend)
+	if err != nil {
+		return nil, err
+	}
+	switch options.Key {
+	case CacheKeyPath, "":
+		return store, nil
+	case CacheKeyContent:
+		return keyCacheByContent(store), nil
+	}
+	store.Close()
+	return nil, ValidateCacheKey(options.Key)
+}
+
+// openCacheBackend opens the storage of the cache in cacheDir
+func openCacheBackend(cacheDir, backend string) (entryStore, error) {
+	jsonCache := &JSONCache{CacheDir: cacheDir}
+	switch backend {
+	case CacheBackendAuto, "":
+		if _, err := os.Stat(sqliteCachePath(jsonCache.CacheDir)); err != nil {
+			return jsonCache, nil
+		}
+	case CacheBackendJSON:
+		if _, err := os.Stat(sqliteCachePath(jsonCache.CacheDir)); err == nil {
+			slog.Warn("Ignoring the SQLite cache in the cache directory", "path", sqliteCachePath(jsonCache.CacheDir))
+		}
+		return jsonCache, nil
+	case CacheBackendSQLite:
+	default:
+		return nil, ValidateCacheBackend(backend)
+	}
+
+	cache, err := OpenSQLiteCache(jsonCache.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := cache.importJSON(jsonCache); err != nil {
+		cache.Close()
+		return nil, err
+	}
+	return cache, nil
+}
+
+// JSONCache is a CacheManager storing each file's analysis as a JSON file named by the hash
+// of its path
+type JSONCache struct {
 	CacheDir string
+	schemaTally
 }
 
 type CacheEntry struct {
@@ -20,104 +151,349 @@ type CacheEntry struct {
 	FileModTime time.Time  `json:"file_mod_time"`
 	FileSize    int64      `json:"file_size"`
 	AnalyzedAt  time.Time  `json:"analyzed_at"`
+	ContentID   string     `json:"content_id,omitempty"` // Content identity the entry is keyed by, with CacheKeyContent
+	Schema      int        `json:"schema_version"`       // CacheSchemaVersion of the analysis (0 if it predates versioning)
 	MediaInfo   *MediaInfo `json:"media_info"`
+
+	key string // Key the entry is stored under
 }
 
-func NewCacheManager(outputDir string) *CacheManager {
+// newCacheEntry creates an entry recording a file's analysis
+func newCacheEntry(filePath string, fileInfo os.FileInfo, mediaInfo *MediaInfo) *CacheEntry {
+	return &CacheEntry{
+		FilePath:    filePath,
+		FileModTime: fileInfo.ModTime(),
+		FileSize:    fileInfo.Size(),
+		AnalyzedAt:  time.Now(),
+		Schema:      CacheSchemaVersion,
+		MediaInfo:   mediaInfo,
+	}
+}
+
+// entryStore is a cache backend's storage of entries under keys. Keys are the hash of a file's
+// path, or its content identity with CacheKeyContent.
+type entryStore interface {
+	CacheManager
+	readEntry(key string) (*CacheEntry, error) // Returns nil if there is no entry
+	writeEntry(key string, entry *CacheEntry) error
+	deleteEntry(key string) error
+	// scanEntries calls fn with every entry, or with why an entry is unreadable
+	scanEntries(fn func(key string, entry *CacheEntry, err error)) error
+	// diskUsage returns the space the cache takes on disk
+	diskUsage() (int64, error)
+	// clear removes every entry, returning how many were removed
+	clear() (int, error)
+	// schemaCounts returns the tally of outdated entries met since the cache was opened
+	schemaCounts() *schemaTally
+}
+
+// schemaTally tallies the entries of an older schema a cache upgraded or had to discard, which
+// are logged when it is closed
+type schemaTally struct {
+	upgraded  atomic.Int64
+	discarded atomic.Int64
+}
+
+func (counts *schemaTally) schemaCounts() *schemaTally {
+	return counts
+}
+
+// log reports the entries upgraded and discarded during a run, if any
+func (counts *schemaTally) log(location string) {
+	upgraded, discarded := counts.upgraded.Load(), counts.discarded.Load()
+	if upgraded > 0 || discarded > 0 {
+		slog.Info("Migrated cache entries to the current analysis schema", "upgraded", upgraded,
+			"reanalyzed", discarded, "schema", CacheSchemaVersion, "cache", location)
+	}
+}
+
+// upgradeEntry brings an entry read from store up to CacheSchemaVersion, saving the upgraded
+// entry. It returns false if the entry is from a schema that cannot be upgraded, so the file
+// must be analyzed again.
+func upgradeEntry(store entryStore, entry *CacheEntry) bool {
+	if entry.Schema == CacheSchemaVersion {
+		return true
+	}
+	counts := store.schemaCounts()
+	if entry.Schema > CacheSchemaVersion {
+		slog.Debug("Cache entry is from a newer version, will re-analyze", "file", entry.FilePath, "schema", entry.Schema)
+		counts.discarded.Add(1)
+		return false
+	}
+	for version := entry.Schema; version < CacheSchemaVersion; version++ {
+		if cacheMigrations[version] == nil {
+			slog.Debug("Cache entry predates fields of the current analysis, will re-analyze", "file", entry.FilePath, "schema", entry.Schema)
+			counts.discarded.Add(1)
+			return false
+		}
+	}
+
+	for version := entry.Schema; version < CacheSchemaVersion; version++ {
+		cacheMigrations[version](entry.MediaInfo)
+	}
+	slog.Debug("Upgraded cache entry", "file", entry.FilePath, "from", entry.Schema, "to", CacheSchemaVersion)
+	entry.Schema = CacheSchemaVersion
+	counts.upgraded.Add(1)
+	if err := store.writeEntry(entry.key, entry); err != nil {
+		slog.Warn("Failed to save upgraded cache entry", "file", entry.FilePath, "error", err)
+	}
+	return true
+}
+
+// lookupEntry returns the analysis stored under key if it is still valid for the file
+func lookupEntry(store entryStore, key string, fileInfo os.FileInfo) (bool, *MediaInfo, error) {
+	entry, err := store.readEntry(key)
+	if entry == nil {
+		return false, nil, err
+	}
+	if !upgradeEntry(store, entry) || !entry.validFor(fileInfo) {
+		return false, nil, nil
+	}
+	return true, entry.MediaInfo, nil
+}
+
+// NewJSONCache creates a JSON cache in the .cache directory of an output directory
+func NewJSONCache(outputDir string) *JSONCache {
 	cacheDir := filepath.Join(outputDir, ".cache")
-	return &CacheManager{CacheDir: cacheDir}
+	return &JSONCache{CacheDir: cacheDir}
+}
+
+// Location returns the cache directory
+func (cm *JSONCache) Location() string {
+	return cm.CacheDir
+}
+
+// Close logs the entries migrated to the current schema; JSON cache files are not held open
+func (cm *JSONCache) Close() error {
+	cm.schemaTally.log(cm.CacheDir)
+	return nil
 }
 
 // EnsureCacheDir creates the cache directory if it doesn't exist
-func (cm *CacheManager) EnsureCacheDir() error {
+func (cm *JSONCache) EnsureCacheDir() error {
 	if err := os.MkdirAll(cm.CacheDir, 0755); err != nil {
 		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
 	return nil
 }
 
-// getCacheFileName generates a cache file name from the file path
-func (cm *CacheManager) getCacheFileName(filePath string) string {
+// pathHash is the key of a file's cache entry, the hash of its absolute path so runs from
+// different working directories find the same entries in a shared cache
+func pathHash(filePath string) string {
+	if !IsRemoteInput(filePath) {
+		if abs, err := filepath.Abs(filePath); err == nil {
+			filePath = abs
+		}
+	}
 	hash := sha256.Sum256([]byte(filePath))
-	return hex.EncodeToString(hash[:]) + ".json"
+	return hex.EncodeToString(hash[:])
 }
 
-// getCacheFilePath returns the full path to the cache file
-func (cm *CacheManager) getCacheFilePath(filePath string) string {
-	return filepath.Join(cm.CacheDir, cm.getCacheFileName(filePath))
+// cacheFilePath returns the path of the cache file for an entry key
+func (cm *JSONCache) cacheFilePath(key string) string {
+	return filepath.Join(cm.CacheDir, key+".json")
 }
 
 // HasValidCache checks if a valid cache entry exists for the file
-func (cm *CacheManager) HasValidCache(filePath string, fileInfo os.FileInfo) (bool, *MediaInfo, error) {
-	cacheFilePath := cm.getCacheFilePath(filePath)
+func (cm *JSONCache) HasValidCache(filePath string, fileInfo os.FileInfo) (bool, *MediaInfo, error) {
+	return lookupEntry(cm, pathHash(filePath), fileInfo)
+}
+
+// readEntry reads the cache file for key, returning nil if there is none or it is unreadable
+func (cm *JSONCache) readEntry(key string) (*CacheEntry, error) {
+	cacheFilePath := cm.cacheFilePath(key)
 
 	_, err := os.Stat(cacheFilePath)
 	if os.IsNotExist(err) {
-		return false, nil, nil
+		return nil, nil
 	}
 	if err != nil {
-		return false, nil, fmt.Errorf("failed to stat cache file: %w", err)
+		return nil, fmt.Errorf("failed to stat cache file: %w", err)
 	}
 
 	data, err := os.ReadFile(cacheFilePath)
 	if err != nil {
-		slog.Warn("Failed to read cache file, will re-analyze", "file", filePath, "error", err)
-		return false, nil, nil
+		slog.Warn("Failed to read cache file, will re-analyze", "cacheFile", cacheFilePath, "error", err)
+		return nil, nil
 	}
 
 	var entry CacheEntry
 	if err := json.Unmarshal(data, &entry); err != nil {
-		slog.Warn("Failed to parse cache file, will re-analyze", "file", filePath, "error", err)
-		return false, nil, nil
+		slog.Warn("Failed to parse cache file, will re-analyze", "cacheFile", cacheFilePath, "error", err)
+		return nil, nil
 	}
+	entry.key = key
+	return &entry, nil
+}
 
+// validFor reports whether an entry still describes a file, logging why if it does not
+func (entry *CacheEntry) validFor(fileInfo os.FileInfo) bool {
 	if fileInfo.ModTime().After(entry.FileModTime) {
-		slog.Debug("Source file modified since cache, will re-analyze", "file", filePath,
+		slog.Debug("Source file modified since cache, will re-analyze", "file", entry.FilePath,
 			"sourceModTime", fileInfo.ModTime(), "cacheModTime", entry.FileModTime)
-		return false, nil, nil
+		return false
 	}
 
 	if fileInfo.Size() != entry.FileSize {
-		slog.Debug("Source file size changed since cache, will re-analyze", "file", filePath,
+		slog.Debug("Source file size changed since cache, will re-analyze", "file", entry.FilePath,
 			"sourceSize", fileInfo.Size(), "cacheSize", entry.FileSize)
-		return false, nil, nil
+		return false
 	}
 
-	if time.Since(entry.AnalyzedAt) > 30*24*time.Hour {
-		slog.Debug("Cache entry too old, will re-analyze", "file", filePath, "age", time.Since(entry.AnalyzedAt))
-		return false, nil, nil
+	if time.Since(entry.AnalyzedAt) > cacheMaxAge {
+		slog.Debug("Cache entry too old, will re-analyze", "file", entry.FilePath, "age", time.Since(entry.AnalyzedAt))
+		return false
 	}
 
-	slog.Debug("Using cached analysis", "file", filePath, "cachedAt", entry.AnalyzedAt)
-	return true, entry.MediaInfo, nil
+	slog.Debug("Using cached analysis", "file", entry.FilePath, "cachedAt", entry.AnalyzedAt)
+	return true
 }
 
-// SaveCache stores the analysis result in a cache file
-func (cm *CacheManager) SaveCache(filePath string, fileInfo os.FileInfo, mediaInfo *MediaInfo) error {
-	entry := CacheEntry{
-		FilePath:    filePath,
-		FileModTime: fileInfo.ModTime(),
-		FileSize:    fileInfo.Size(),
-		AnalyzedAt:  time.Now(),
-		MediaInfo:   mediaInfo,
+// UncachedFiles returns the files that have no valid cache entry and would need fresh analysis
+func UncachedFiles(cm CacheManager, filePaths []string) []string {
+	var uncached []string
+	for _, filePath := range filePaths {
+		fileInfo, err := os.Stat(filePath)
+		if err != nil {
+			uncached = append(uncached, filePath)
+			continue
+		}
+		if ok, mediaInfo, _ := cm.HasValidCache(filePath, fileInfo); !ok || mediaInfo == nil {
+			uncached = append(uncached, filePath)
+		}
 	}
+	return uncached
+}
 
+// SaveCache stores the analysis result in a cache file
+func (cm *JSONCache) SaveCache(filePath string, fileInfo os.FileInfo, mediaInfo *MediaInfo) error {
+	return cm.writeEntry(pathHash(filePath), newCacheEntry(filePath, fileInfo, mediaInfo))
+}
+
+// writeEntry writes the cache file for key
+func (cm *JSONCache) writeEntry(key string, entry *CacheEntry) error {
 	data, err := json.MarshalIndent(entry, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal cache entry: %w", err)
 	}
 
-	cacheFilePath := cm.getCacheFilePath(filePath)
+	cacheFilePath := cm.cacheFilePath(key)
 	if err := os.WriteFile(cacheFilePath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write cache file: %w", err)
 	}
 
-	slog.Debug("Saved analysis to cache", "file", filePath, "cacheFile", cacheFilePath)
+	slog.Debug("Saved analysis to cache", "file", entry.FilePath, "cacheFile", cacheFilePath)
+	return nil
+}
+
+// deleteEntry removes the cache file for key, if any
+func (cm *JSONCache) deleteEntry(key string) error {
+	if err := os.Remove(cm.cacheFilePath(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove cache file: %w", err)
+	}
+	return nil
+}
+
+// LoadEntries returns every cache entry, sorted by file path.
+// Unreadable entries are skipped so a partially corrupt cache can still be read.
+func (cm *JSONCache) LoadEntries() ([]*CacheEntry, error) {
+	var entries []*CacheEntry
+	err := cm.scanEntries(func(key string, entry *CacheEntry, err error) {
+		if err != nil {
+			slog.Warn("Failed to parse cache file", "file", key+".json", "error", err)
+			return
+		}
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].FilePath < entries[j].FilePath })
+	return entries, nil
+}
+
+// scanEntries calls fn with the key of every cache file and its entry, or why it is unreadable
+func (cm *JSONCache) scanEntries(fn func(key string, entry *CacheEntry, err error)) error {
+	dirEntries, err := os.ReadDir(cm.CacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read cache directory: %w", err)
+	}
+
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() || filepath.Ext(dirEntry.Name()) != ".json" {
+			continue
+		}
+		key := strings.TrimSuffix(dirEntry.Name(), ".json")
+
+		data, err := os.ReadFile(filepath.Join(cm.CacheDir, dirEntry.Name()))
+		if err != nil {
+			fn(key, nil, err)
+			continue
+		}
+		var entry CacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			fn(key, nil, err)
+			continue
+		}
+		if entry.MediaInfo == nil {
+			fn(key, nil, fmt.Errorf("entry has no media info"))
+			continue
+		}
+		entry.key = key
+		fn(key, &entry, nil)
+	}
 	return nil
 }
 
+// diskUsage returns the total size of the cache files
+func (cm *JSONCache) diskUsage() (int64, error) {
+	dirEntries, err := os.ReadDir(cm.CacheDir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read cache directory: %w", err)
+	}
+	var total int64
+	for _, dirEntry := range dirEntries {
+		if info, err := dirEntry.Info(); err == nil && info.Mode().IsRegular() && filepath.Ext(dirEntry.Name()) == ".json" {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
+
+// clear removes every cache file, returning how many were removed
+func (cm *JSONCache) clear() (int, error) {
+	var keys []string
+	if err := cm.scanEntries(func(key string, entry *CacheEntry, err error) { keys = append(keys, key) }); err != nil {
+		return 0, err
+	}
+	for i, key := range keys {
+		if err := cm.deleteEntry(key); err != nil {
+			return i, err
+		}
+	}
+	return len(keys), nil
+}
+
+// LoadCachedMediaInfos returns the media info of every cache entry, sorted by file path
+func LoadCachedMediaInfos(cm CacheManager) ([]*MediaInfo, error) {
+	entries, err := cm.LoadEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	mediaInfos := make([]*MediaInfo, len(entries))
+	for i, entry := range entries {
+		mediaInfos[i] = entry.MediaInfo
+	}
+	return mediaInfos, nil
+}
+
 // CleanOldCache removes cache files older than the specified duration
-func (cm *CacheManager) CleanOldCache(maxAge time.Duration) error {
+func (cm *JSONCache) CleanOldCache(maxAge time.Duration) error {
 	entries, err := os.ReadDir(cm.CacheDir)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -0,0 +1,131 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// CacheStats summarizes the contents of an analysis cache
+type CacheStats struct {
+	Location string
+	Entries  int       // Readable entries
+	Invalid  int       // Entries that could not be read or parsed
+	Outdated int       // Readable entries of an older CacheSchemaVersion, upgraded or re-analyzed when next used
+	Bytes    int64     // Space the cache takes on disk
+	Oldest   time.Time // Earliest analysis in the cache (zero if empty)
+	Newest   time.Time // Latest analysis in the cache (zero if empty)
+}
+
+// CacheProblem is a cache entry that could not be read or parsed
+type CacheProblem struct {
+	Key   string // Name of the entry's cache file, or its key in the database
+	Error string
+}
+
+// maintainedStore returns the storage of a cache opened with OpenCache
+func maintainedStore(cm CacheManager) (entryStore, error) {
+	store, ok := cm.(entryStore)
+	if !ok {
+		return nil, fmt.Errorf("cache %s does not support maintenance", cm.Location())
+	}
+	return store, nil
+}
+
+// InspectCache counts a cache's entries and measures its size
+func InspectCache(cm CacheManager) (*CacheStats, error) {
+	store, err := maintainedStore(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CacheStats{Location: cm.Location()}
+	err = store.scanEntries(func(key string, entry *CacheEntry, err error) {
+		if err != nil {
+			stats.Invalid++
+			return
+		}
+		stats.Entries++
+		if entry.Schema != CacheSchemaVersion {
+			stats.Outdated++
+		}
+		if stats.Oldest.IsZero() || entry.AnalyzedAt.Before(stats.Oldest) {
+			stats.Oldest = entry.AnalyzedAt
+		}
+		if entry.AnalyzedAt.After(stats.Newest) {
+			stats.Newest = entry.AnalyzedAt
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stats.Bytes, err = store.diskUsage(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ClearCache removes every entry from a cache, returning how many were removed
+func ClearCache(cm CacheManager) (int, error) {
+	store, err := maintainedStore(cm)
+	if err != nil {
+		return 0, err
+	}
+	return store.clear()
+}
+
+// PruneCache removes the entries of files that no longer exist, returning their paths. Entries
+// of remote libraries are kept, since their files cannot be checked from here. With dryRun,
+// the entries are only listed.
+func PruneCache(cm CacheManager, dryRun bool) ([]string, error) {
+	store, err := maintainedStore(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []*CacheEntry
+	err = store.scanEntries(func(key string, entry *CacheEntry, err error) {
+		if err != nil || IsRemoteInput(entry.FilePath) {
+			return
+		}
+		if _, err := os.Stat(entry.FilePath); os.IsNotExist(err) {
+			stale = append(stale, entry)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []string
+	for _, entry := range stale {
+		if !dryRun {
+			if err := store.deleteEntry(entry.key); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, entry.FilePath)
+	}
+	return pruned, nil
+}
+
+// VerifyCache reads and parses every entry of a cache, returning how many were checked and
+// those that are unreadable
+func VerifyCache(cm CacheManager) (int, []CacheProblem, error) {
+	store, err := maintainedStore(cm)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	checked := 0
+	var problems []CacheProblem
+	err = store.scanEntries(func(key string, entry *CacheEntry, err error) {
+		checked++
+		if err == nil && entry.FilePath == "" {
+			err = fmt.Errorf("entry has no file path")
+		}
+		if err != nil {
+			problems = append(problems, CacheProblem{Key: key, Error: err.Error()})
+		}
+	})
+	return checked, problems, err
+}
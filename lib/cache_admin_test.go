@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheMaintenance(t *testing.T) {
+	for _, backend := range []string{CacheBackendJSON, CacheBackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := OpenCache(dir, CacheOptions{Backend: backend})
+			if err != nil {
+				t.Fatalf("OpenCache failed: %v", err)
+			}
+			defer cache.Close()
+			cache.EnsureCacheDir()
+
+			kept := filepath.Join(dir, "kept.mkv")
+			deleted := filepath.Join(dir, "deleted.mkv")
+			for _, file := range []string{kept, deleted} {
+				if err := os.WriteFile(file, []byte("video"), 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", file, err)
+				}
+				info, _ := os.Stat(file)
+				if err := cache.SaveCache(file, info, &MediaInfo{FilePath: file}); err != nil {
+					t.Fatalf("SaveCache failed: %v", err)
+				}
+			}
+			os.Remove(deleted)
+
+			// Corrupt an entry behind the cache's back
+			store := cache.(entryStore)
+			switch c := cache.(type) {
+			case *JSONCache:
+				os.WriteFile(c.cacheFilePath("corrupt"), []byte("{not json"), 0644)
+			case *SQLiteCache:
+				if _, err := c.db.Exec(`INSERT INTO cache_entries (` + cacheEntryColumns + `) VALUES ('corrupt', 'x.mkv', 0, 0, 0, '', '{not json', 1)`); err != nil {
+					t.Fatalf("Failed to insert corrupt entry: %v", err)
+				}
+			}
+
+			stats, err := InspectCache(cache)
+			if err != nil || stats.Entries != 2 || stats.Invalid != 1 || stats.Bytes == 0 {
+				t.Errorf("Unexpected stats %+v (err %v)", stats, err)
+			}
+
+			checked, problems, err := VerifyCache(cache)
+			if err != nil || checked != 3 || len(problems) != 1 || problems[0].Key != "corrupt" {
+				t.Errorf("Expected one corrupt entry of 3, got %d %+v (err %v)", checked, problems, err)
+			}
+
+			pruned, err := PruneCache(cache, true)
+			if err != nil || len(pruned) != 1 || pruned[0] != deleted {
+				t.Errorf("Expected dry run to list %s, got %v (err %v)", deleted, pruned, err)
+			}
+			if entries, _ := cache.LoadEntries(); len(entries) != 2 {
+				t.Errorf("Expected dry run to keep every entry, got %d", len(entries))
+			}
+			if pruned, err := PruneCache(cache, false); err != nil || len(pruned) != 1 {
+				t.Errorf("Expected one pruned entry, got %v (err %v)", pruned, err)
+			}
+			if entries, _ := cache.LoadEntries(); len(entries) != 1 || entries[0].FilePath != kept {
+				t.Errorf("Expected only %s to remain, got %v", kept, entries)
+			}
+
+			if cleared, err := ClearCache(cache); err != nil || cleared != 2 {
+				t.Errorf("Expected the kept and corrupt entries to be cleared, got %d (err %v)", cleared, err)
+			}
+			if entry, _ := store.readEntry(pathHash(kept)); entry != nil {
+				t.Error("Expected no entries after clearing")
+			}
+		})
+	}
+}
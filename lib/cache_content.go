@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// Cache key modes selectable with --cache-key
+const (
+	CacheKeyPath    = "path"    // Entries are keyed by file path, so a moved file is analyzed again (default)
+	CacheKeyContent = "content" // Entries are keyed by content identity, so moved and renamed files reuse their analysis
+)
+
+// contentSampleSize is how much of the start and end of a file is hashed into its content identity
+const contentSampleSize = 64 * 1024
+
+// ValidateCacheKey checks a --cache-key value
+func ValidateCacheKey(mode string) error {
+	switch mode {
+	case CacheKeyPath, CacheKeyContent:
+		return nil
+	}
+	return fmt.Errorf("invalid --cache-key value %q: must be path or content", mode)
+}
+
+// contentIdentity identifies a file by its size, its modification time, and a hash of its first
+// and last 64 KiB, which a move or rename within a library leaves unchanged
+func contentIdentity(filePath string, fileInfo os.FileInfo) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for content identity: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	binary.Write(hash, binary.BigEndian, fileInfo.Size())
+	binary.Write(hash, binary.BigEndian, fileInfo.ModTime().UnixNano())
+	if _, err := io.Copy(hash, io.LimitReader(file, contentSampleSize)); err != nil {
+		return "", fmt.Errorf("failed to read file for content identity: %w", err)
+	}
+	if tail := fileInfo.Size() - contentSampleSize; tail > contentSampleSize {
+		if _, err := io.Copy(hash, io.NewSectionReader(file, tail, contentSampleSize)); err != nil {
+			return "", fmt.Errorf("failed to read file for content identity: %w", err)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// contentKeyedCache keys a backend's entries by content identity instead of path, so a library
+// that is reorganized reuses its analysis.
+//
+// Distinct files can share an identity, either as true duplicates or, if they differ only in
+// the middle, as a collision. The two cannot be told apart without reading whole files, so an
+// entry is only adopted by another path once the file it was made for no longer has that
+// identity. While both files exist, the second is stored under a key combining the identity
+// with its path, and is analyzed on its own.
+type contentKeyedCache struct {
+	entryStore
+	mutex sync.Mutex // Serializes the choice and write of a file's key
+}
+
+// keyCacheByContent wraps a cache so entries are keyed by content identity
+func keyCacheByContent(store entryStore) CacheManager {
+	return &contentKeyedCache{entryStore: store}
+}
+
+// sharedKey is the key of a file whose identity is held by another existing file
+func sharedKey(contentID, filePath string) string {
+	hash := sha256.Sum256([]byte(contentID + pathHash(filePath)))
+	return hex.EncodeToString(hash[:])
+}
+
+// HasValidCache returns the analysis stored for the file's content identity, adopting the entry
+// of a file that was moved or renamed
+func (c *contentKeyedCache) HasValidCache(filePath string, fileInfo os.FileInfo) (bool, *MediaInfo, error) {
+	contentID, err := contentIdentity(filePath, fileInfo)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if ok, mediaInfo, err := lookupEntry(c.entryStore, sharedKey(contentID, filePath), fileInfo); ok || err != nil {
+		return ok, mediaInfo, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, err := c.readEntry(contentID)
+	if err != nil {
+		return false, nil, err
+	}
+	if entry == nil {
+		return c.adoptPathEntry(filePath, fileInfo, contentID)
+	}
+	if entry.ContentID != contentID || !upgradeEntry(c.entryStore, entry) || !entry.validFor(fileInfo) {
+		return false, nil, nil
+	}
+	if entry.FilePath == filePath {
+		return true, entry.MediaInfo, nil
+	}
+
+	if c.heldBy(entry.FilePath, contentID) {
+		slog.Debug("Another file has the same content identity, will analyze separately", "file", filePath, "other", entry.FilePath)
+		return false, nil, nil
+	}
+	// Audio sidecars are found next to the video, so a move may have gained or lost some
+	if sidecars, _ := FindAudioSidecars(filePath); len(sidecars) > 0 || len(entry.MediaInfo.AudioSidecars) > 0 {
+		slog.Debug("Moved file has audio sidecars, will re-analyze", "file", filePath, "from", entry.FilePath)
+		return false, nil, nil
+	}
+
+	slog.Debug("Reusing analysis of moved file", "file", filePath, "from", entry.FilePath)
+	mediaInfo := *entry.MediaInfo
+	mediaInfo.FilePath = filePath
+	entry.FilePath = filePath
+	entry.MediaInfo = &mediaInfo
+	if err := c.writeEntry(contentID, entry); err != nil {
+		slog.Warn("Failed to save moved file to cache", "file", filePath, "error", err)
+	}
+	return true, &mediaInfo, nil
+}
+
+// adoptPathEntry moves a file's path-keyed entry, written before content keys were enabled, to
+// its content identity
+func (c *contentKeyedCache) adoptPathEntry(filePath string, fileInfo os.FileInfo, contentID string) (bool, *MediaInfo, error) {
+	entry, err := c.readEntry(pathHash(filePath))
+	if entry == nil || err != nil || entry.FilePath != filePath || !upgradeEntry(c.entryStore, entry) || !entry.validFor(fileInfo) {
+		return false, nil, err
+	}
+	entry.ContentID = contentID
+	if err := c.writeEntry(contentID, entry); err != nil {
+		return false, nil, err
+	}
+	if err := c.deleteEntry(pathHash(filePath)); err != nil {
+		slog.Warn("Failed to remove path-keyed cache entry", "file", filePath, "error", err)
+	}
+	return true, entry.MediaInfo, nil
+}
+
+// heldBy reports whether the file at path still exists with the content identity
+func (c *contentKeyedCache) heldBy(path, contentID string) bool {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	id, err := contentIdentity(path, fileInfo)
+	return err == nil && id == contentID
+}
+
+// SaveCache stores the analysis under the file's content identity, or under a key of its own if
+// another existing file holds that identity
+func (c *contentKeyedCache) SaveCache(filePath string, fileInfo os.FileInfo, mediaInfo *MediaInfo) error {
+	contentID, err := contentIdentity(filePath, fileInfo)
+	if err != nil {
+		return err
+	}
+	entry := newCacheEntry(filePath, fileInfo, mediaInfo)
+	entry.ContentID = contentID
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := contentID
+	if existing, _ := c.readEntry(contentID); existing != nil && existing.FilePath != filePath && c.heldBy(existing.FilePath, contentID) {
+		key = sharedKey(contentID, filePath)
+	}
+	if err := c.writeEntry(key, entry); err != nil {
+		return err
+	}
+	if err := c.deleteEntry(pathHash(filePath)); err != nil {
+		slog.Warn("Failed to remove path-keyed cache entry", "file", filePath, "error", err)
+	}
+	return nil
+}
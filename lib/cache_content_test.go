@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeVideo writes a fake video file with a fixed modification time
+func writeVideo(t *testing.T, path, content string, modTime time.Time) os.FileInfo {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set times of %s: %v", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	return info
+}
+
+func TestContentKeyedCache(t *testing.T) {
+	for _, backend := range []string{CacheBackendJSON, CacheBackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := OpenCache(dir, CacheOptions{Backend: backend, Key: CacheKeyContent})
+			if err != nil {
+				t.Fatalf("OpenCache failed: %v", err)
+			}
+			defer cache.Close()
+			if err := cache.EnsureCacheDir(); err != nil {
+				t.Fatalf("EnsureCacheDir failed: %v", err)
+			}
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+			original := filepath.Join(dir, "old", "movie.mkv")
+			info := writeVideo(t, original, "movie content", modTime)
+			if err := cache.SaveCache(original, info, &MediaInfo{FilePath: original, VideoCodec: "hevc"}); err != nil {
+				t.Fatalf("SaveCache failed: %v", err)
+			}
+
+			// A copy with the same identity is analyzed separately while the original exists
+			copied := filepath.Join(dir, "copy.mkv")
+			copyInfo := writeVideo(t, copied, "movie content", modTime)
+			if ok, _, _ := cache.HasValidCache(copied, copyInfo); ok {
+				t.Error("Expected a copy of an existing file not to reuse its analysis")
+			}
+			if err := cache.SaveCache(copied, copyInfo, &MediaInfo{FilePath: copied, VideoCodec: "h264"}); err != nil {
+				t.Fatalf("SaveCache failed: %v", err)
+			}
+			for path, codec := range map[string]string{original: "hevc", copied: "h264"} {
+				info, _ := os.Stat(path)
+				if ok, mediaInfo, err := cache.HasValidCache(path, info); !ok || err != nil || mediaInfo.VideoCodec != codec {
+					t.Errorf("Expected %s to keep its own analysis, got ok=%v info=%+v err=%v", path, ok, mediaInfo, err)
+				}
+			}
+
+			// Once the original is moved, the new path adopts its analysis
+			moved := filepath.Join(dir, "new", "Movie (2020).mkv")
+			if err := os.MkdirAll(filepath.Dir(moved), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Rename(original, moved); err != nil {
+				t.Fatal(err)
+			}
+			movedInfo, _ := os.Stat(moved)
+			ok, mediaInfo, err := cache.HasValidCache(moved, movedInfo)
+			if !ok || err != nil || mediaInfo.VideoCodec != "hevc" || mediaInfo.FilePath != moved {
+				t.Errorf("Expected moved file to reuse its analysis, got ok=%v info=%+v err=%v", ok, mediaInfo, err)
+			}
+
+			// A changed file no longer matches
+			changedInfo := writeVideo(t, moved, "edited movie content", modTime.Add(time.Minute))
+			if ok, _, _ := cache.HasValidCache(moved, changedInfo); ok {
+				t.Error("Expected a modified file not to reuse its analysis")
+			}
+		})
+	}
+}
+
+func TestContentKeyedCache_AdoptsPathEntries(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "movie.mkv")
+	info := writeVideo(t, file, "movie content", time.Now().Add(-time.Hour))
+
+	pathCache, err := OpenCache(dir, CacheOptions{})
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	pathCache.EnsureCacheDir()
+	if err := pathCache.SaveCache(file, info, &MediaInfo{FilePath: file}); err != nil {
+		t.Fatalf("SaveCache failed: %v", err)
+	}
+
+	cache, err := OpenCache(dir, CacheOptions{Key: CacheKeyContent})
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	if ok, _, err := cache.HasValidCache(file, info); !ok || err != nil {
+		t.Errorf("Expected the path-keyed entry to be reused, got ok=%v err=%v", ok, err)
+	}
+	entries, err := cache.LoadEntries()
+	if err != nil || len(entries) != 1 || entries[0].ContentID == "" {
+		t.Errorf("Expected the entry to be rekeyed by content, got %+v (err %v)", entries, err)
+	}
+}
@@ -0,0 +1,273 @@
+package lib
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sqliteCacheSchema holds one row per analyzed file, keyed as JSON cache files are named, with
+// the analysis stored as JSON
+const sqliteCacheSchema = `
+CREATE TABLE IF NOT EXISTS cache_entries (
+	cache_key     TEXT PRIMARY KEY,
+	file_path     TEXT NOT NULL,
+	file_mod_time INTEGER NOT NULL,
+	file_size     INTEGER NOT NULL,
+	analyzed_at   INTEGER NOT NULL,
+	content_id    TEXT NOT NULL,
+	media_info    TEXT NOT NULL,
+	schema        INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS cache_entries_analyzed_at ON cache_entries(analyzed_at);
+`
+
+// cacheEntryColumns are the columns of cache_entries, in the order scanCacheEntry reads them
+const cacheEntryColumns = "cache_key, file_path, file_mod_time, file_size, analyzed_at, content_id, media_info, schema"
+
+// sqliteCachePath is the database file of a SQLite cache in cacheDir
+func sqliteCachePath(cacheDir string) string {
+	return filepath.Join(cacheDir, "cache.db")
+}
+
+// SQLiteCache is a CacheManager storing every analysis in one SQLite database, which stays
+// fast and uses a single inode however many files the library holds
+type SQLiteCache struct {
+	Path string
+	db   *sql.DB
+	schemaTally
+}
+
+// sqliteDSN builds the driver's file: URI for a database at path with the given parameters. The
+// path is percent-encoded, since SQLite decodes URI filenames and a "?" or "#" in a directory
+// name would otherwise end the path. ":memory:" is passed through for in-memory databases.
+func sqliteDSN(path, params string) string {
+	if path != ":memory:" {
+		path = (&url.URL{Path: path}).EscapedPath()
+	}
+	return "file:" + path + "?" + params
+}
+
+// OpenSQLiteCache opens or creates the SQLite cache in cacheDir
+func OpenSQLiteCache(cacheDir string) (*SQLiteCache, error) {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	path := sqliteCachePath(cacheDir)
+	db, err := sql.Open("sqlite3", sqliteDSN(path, "_busy_timeout=5000&_journal_mode=WAL"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+	// Analysis workers share one connection, which serializes their writes
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteCacheSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create cache database schema: %w", err)
+	}
+	// Databases created before entries were versioned lack the schema column; their rows are version 0
+	var hasSchema bool
+	if err := db.QueryRow(`SELECT COUNT(*) > 0 FROM pragma_table_info('cache_entries') WHERE name = 'schema'`).Scan(&hasSchema); err == nil && !hasSchema {
+		if _, err := db.Exec(`ALTER TABLE cache_entries ADD COLUMN schema INTEGER NOT NULL DEFAULT 0`); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to upgrade cache database schema: %w", err)
+		}
+	}
+	return &SQLiteCache{Path: path, db: db}, nil
+}
+
+// EnsureCacheDir does nothing; the database is created when the cache is opened
+func (c *SQLiteCache) EnsureCacheDir() error {
+	return nil
+}
+
+// Location returns the database path
+func (c *SQLiteCache) Location() string {
+	return c.Path
+}
+
+// Close logs the entries migrated to the current schema and closes the database
+func (c *SQLiteCache) Close() error {
+	c.schemaTally.log(c.Path)
+	return c.db.Close()
+}
+
+// HasValidCache checks if a valid cache entry exists for the file
+func (c *SQLiteCache) HasValidCache(filePath string, fileInfo os.FileInfo) (bool, *MediaInfo, error) {
+	return lookupEntry(c, pathHash(filePath), fileInfo)
+}
+
+// readEntry reads the entry stored under key, returning nil if there is none or it is unreadable
+func (c *SQLiteCache) readEntry(key string) (*CacheEntry, error) {
+	row := c.db.QueryRow(`SELECT `+cacheEntryColumns+` FROM cache_entries WHERE cache_key = ?`, key)
+	entry, err := scanCacheEntry(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		slog.Warn("Failed to read cache entry, will re-analyze", "key", key, "error", err)
+		return nil, nil
+	}
+	return entry, nil
+}
+
+// SaveCache stores the analysis result, replacing any earlier entry for the file
+func (c *SQLiteCache) SaveCache(filePath string, fileInfo os.FileInfo, mediaInfo *MediaInfo) error {
+	return c.writeEntry(pathHash(filePath), newCacheEntry(filePath, fileInfo, mediaInfo))
+}
+
+// writeEntry stores an entry under key, replacing any earlier one
+func (c *SQLiteCache) writeEntry(key string, entry *CacheEntry) error {
+	return c.insertEntry(c.db, key, entry)
+}
+
+// insertEntry stores an entry under key with db, which may be a transaction
+func (c *SQLiteCache) insertEntry(db interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, key string, entry *CacheEntry) error {
+	data, err := json.Marshal(entry.MediaInfo)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache entry: %w", err)
+	}
+	_, err = db.Exec(`INSERT OR REPLACE INTO cache_entries (`+cacheEntryColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		key, entry.FilePath, entry.FileModTime.UnixNano(), entry.FileSize, entry.AnalyzedAt.UnixNano(), entry.ContentID, string(data), entry.Schema)
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	slog.Debug("Saved analysis to cache", "file", entry.FilePath, "cacheFile", c.Path)
+	return nil
+}
+
+// deleteEntry removes the entry stored under key, if any
+func (c *SQLiteCache) deleteEntry(key string) error {
+	if _, err := c.db.Exec(`DELETE FROM cache_entries WHERE cache_key = ?`, key); err != nil {
+		return fmt.Errorf("failed to remove cache entry: %w", err)
+	}
+	return nil
+}
+
+// LoadEntries returns every cache entry, sorted by file path.
+// Unreadable entries are skipped so a partially corrupt cache can still be read.
+func (c *SQLiteCache) LoadEntries() ([]*CacheEntry, error) {
+	var entries []*CacheEntry
+	err := c.scanEntries(func(key string, entry *CacheEntry, err error) {
+		if err != nil {
+			slog.Warn("Failed to parse cache entry", "key", key, "error", err)
+			return
+		}
+		entries = append(entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// scanEntries calls fn with every entry in file path order, or with why an entry is unreadable
+func (c *SQLiteCache) scanEntries(fn func(key string, entry *CacheEntry, err error)) error {
+	rows, err := c.db.Query(`SELECT ` + cacheEntryColumns + ` FROM cache_entries ORDER BY file_path`)
+	if err != nil {
+		return fmt.Errorf("failed to read cache database: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanCacheEntry(rows)
+		if entry == nil {
+			return fmt.Errorf("failed to read cache database: %w", err)
+		}
+		if err != nil {
+			fn(entry.key, nil, err)
+			continue
+		}
+		fn(entry.key, entry, nil)
+	}
+	return rows.Err()
+}
+
+// diskUsage returns the size of the database and its write-ahead log
+func (c *SQLiteCache) diskUsage() (int64, error) {
+	var total int64
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if info, err := os.Stat(c.Path + suffix); err == nil {
+			total += info.Size()
+		}
+	}
+	return total, nil
+}
+
+// clear removes every entry and shrinks the database, returning how many entries were removed
+func (c *SQLiteCache) clear() (int, error) {
+	result, err := c.db.Exec(`DELETE FROM cache_entries`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear cache database: %w", err)
+	}
+	cleared, _ := result.RowsAffected()
+	if _, err := c.db.Exec(`VACUUM`); err != nil {
+		slog.Warn("Failed to shrink cache database", "path", c.Path, "error", err)
+	}
+	return int(cleared), nil
+}
+
+// CleanOldCache removes entries saved longer ago than maxAge
+func (c *SQLiteCache) CleanOldCache(maxAge time.Duration) error {
+	result, err := c.db.Exec(`DELETE FROM cache_entries WHERE analyzed_at < ?`, time.Now().Add(-maxAge).UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to clean cache database: %w", err)
+	}
+	if cleaned, _ := result.RowsAffected(); cleaned > 0 {
+		slog.Info("Cleaned old cache entries", "count", cleaned, "maxAge", maxAge)
+	}
+	return nil
+}
+
+// importJSON moves the entries of a JSON cache into the database in one transaction, removing
+// the JSON files once they are committed
+func (c *SQLiteCache) importJSON(jsonCache *JSONCache) error {
+	entries, err := jsonCache.LoadEntries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin cache migration: %w", err)
+	}
+	defer tx.Rollback()
+	for _, entry := range entries {
+		if err := c.insertEntry(tx, entry.key, entry); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit cache migration: %w", err)
+	}
+
+	for _, entry := range entries {
+		jsonCache.deleteEntry(entry.key)
+	}
+	slog.Info("Migrated JSON cache to SQLite", "entries", len(entries), "path", c.Path)
+	return nil
+}
+
+// scanCacheEntry reads a cache entry from a row of cacheEntryColumns. If the row was read but
+// its analysis cannot be parsed, the entry is returned along with the error.
+func scanCacheEntry(row interface{ Scan(dest ...any) error }) (*CacheEntry, error) {
+	var entry CacheEntry
+	var modTime, analyzedAt int64
+	var data string
+	if err := row.Scan(&entry.key, &entry.FilePath, &modTime, &entry.FileSize, &analyzedAt, &entry.ContentID, &data, &entry.Schema); err != nil {
+		return nil, err
+	}
+	entry.FileModTime = time.Unix(0, modTime)
+	entry.AnalyzedAt = time.Unix(0, analyzedAt)
+	if err := json.Unmarshal([]byte(data), &entry.MediaInfo); err != nil || entry.MediaInfo == nil {
+		return &entry, fmt.Errorf("failed to parse cached analysis of %s: %v", entry.FilePath, err)
+	}
+	return &entry, nil
+}
@@ -0,0 +1,242 @@
+package lib
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUncachedFiles(t *testing.T) {
+	for _, backend := range []string{CacheBackendJSON, CacheBackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := OpenCache(dir, CacheOptions{Backend: backend})
+			if err != nil {
+				t.Fatalf("OpenCache failed: %v", err)
+			}
+			defer cache.Close()
+			if err := cache.EnsureCacheDir(); err != nil {
+				t.Fatalf("EnsureCacheDir failed: %v", err)
+			}
+
+			cached := filepath.Join(dir, "cached.mkv")
+			uncached := filepath.Join(dir, "uncached.mkv")
+			missing := filepath.Join(dir, "missing.mkv")
+			for _, file := range []string{cached, uncached} {
+				if err := os.WriteFile(file, []byte("video"), 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", file, err)
+				}
+			}
+
+			fileInfo, err := os.Stat(cached)
+			if err != nil {
+				t.Fatalf("Stat failed: %v", err)
+			}
+			if err := cache.SaveCache(cached, fileInfo, &MediaInfo{FilePath: cached}); err != nil {
+				t.Fatalf("SaveCache failed: %v", err)
+			}
+
+			got := UncachedFiles(cache, []string{cached, uncached, missing})
+			if len(got) != 2 || got[0] != uncached || got[1] != missing {
+				t.Errorf("Expected uncached and missing files, got %v", got)
+			}
+
+			if err := os.WriteFile(cached, []byte("changed video"), 0644); err != nil {
+				t.Fatalf("Failed to modify %s: %v", cached, err)
+			}
+			if got := UncachedFiles(cache, []string{cached}); len(got) != 1 {
+				t.Errorf("Expected modified file to be uncached, got %v", got)
+			}
+		})
+	}
+}
+
+func TestOpenCache_MigratesJSONToSQLite(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(file, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", file, err)
+	}
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	jsonCache := NewJSONCache(dir)
+	if err := jsonCache.EnsureCacheDir(); err != nil {
+		t.Fatalf("EnsureCacheDir failed: %v", err)
+	}
+	if err := jsonCache.SaveCache(file, fileInfo, &MediaInfo{FilePath: file, VideoCodec: "h264"}); err != nil {
+		t.Fatalf("SaveCache failed: %v", err)
+	}
+
+	cache, err := OpenCache(dir, CacheOptions{Backend: CacheBackendSQLite})
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	valid, info, err := cache.HasValidCache(file, fileInfo)
+	if err != nil || !valid || info.VideoCodec != "h264" {
+		t.Errorf("Expected migrated entry, got valid=%v info=%+v err=%v", valid, info, err)
+	}
+	cache.Close()
+
+	if _, err := os.Stat(jsonCache.cacheFilePath(pathHash(file))); !os.IsNotExist(err) {
+		t.Errorf("Expected JSON cache file to be removed after migration, got %v", err)
+	}
+
+	// Once a database exists, auto opens it
+	cache, err = OpenCache(dir, CacheOptions{})
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	defer cache.Close()
+	if _, ok := cache.(*SQLiteCache); !ok {
+		t.Errorf("Expected auto to open the SQLite cache, got %T", cache)
+	}
+	entries, err := cache.LoadEntries()
+	if err != nil || len(entries) != 1 || entries[0].FilePath != file {
+		t.Errorf("Expected one entry for %s, got %v (err %v)", file, entries, err)
+	}
+}
+
+func TestResolveCacheDir(t *testing.T) {
+	config := &Config{Cache: CacheConfig{Dir: "/config/cache"}}
+
+	t.Setenv(CacheDirEnv, "")
+	if got := ResolveCacheDir("", &Config{}); got != "" {
+		t.Errorf("Expected no cache directory by default, got %q", got)
+	}
+	if got := ResolveCacheDir("", config); got != "/config/cache" {
+		t.Errorf("Expected the config file's cache directory, got %q", got)
+	}
+	t.Setenv(CacheDirEnv, "/env/cache")
+	if got := ResolveCacheDir("", config); got != "/env/cache" {
+		t.Errorf("Expected the environment to override the config file, got %q", got)
+	}
+	if got := ResolveCacheDir("/flag/cache", config); got != "/flag/cache" {
+		t.Errorf("Expected the flag to override the environment, got %q", got)
+	}
+}
+
+func TestOpenCache_SharedDir(t *testing.T) {
+	shared := filepath.Join(t.TempDir(), "shared")
+	file := filepath.Join(t.TempDir(), "movie.mkv")
+	if err := os.WriteFile(file, []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", file, err)
+	}
+	fileInfo, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+
+	first, err := OpenCache(t.TempDir(), CacheOptions{Dir: shared})
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	first.EnsureCacheDir()
+	if err := first.SaveCache(file, fileInfo, &MediaInfo{FilePath: file}); err != nil {
+		t.Fatalf("SaveCache failed: %v", err)
+	}
+
+	// A run writing reports elsewhere finds the entry
+	second, err := OpenCache(t.TempDir(), CacheOptions{Dir: shared})
+	if err != nil {
+		t.Fatalf("OpenCache failed: %v", err)
+	}
+	if second.Location() != shared {
+		t.Errorf("Expected cache in %s, got %s", shared, second.Location())
+	}
+	if ok, _, err := second.HasValidCache(file, fileInfo); !ok || err != nil {
+		t.Errorf("Expected shared cache entry, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestCacheSchemaUpgrade(t *testing.T) {
+	for _, backend := range []string{CacheBackendJSON, CacheBackendSQLite} {
+		t.Run(backend, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "movie.mkv")
+			if err := os.WriteFile(file, []byte("video"), 0644); err != nil {
+				t.Fatalf("Failed to write %s: %v", file, err)
+			}
+			fileInfo, _ := os.Stat(file)
+
+			cache, err := OpenCache(dir, CacheOptions{Backend: backend})
+			if err != nil {
+				t.Fatalf("OpenCache failed: %v", err)
+			}
+			defer cache.Close()
+			cache.EnsureCacheDir()
+			store := cache.(entryStore)
+
+			saveOutdated := func(schema int) {
+				entry := newCacheEntry(file, fileInfo, &MediaInfo{FilePath: file, Duration: 7200, VideoCodec: "h264"})
+				entry.Schema = schema
+				if err := store.writeEntry(pathHash(file), entry); err != nil {
+					t.Fatalf("writeEntry failed: %v", err)
+				}
+			}
+
+			// Without a migration the entry is discarded
+			saveOutdated(0)
+			if ok, _, _ := cache.HasValidCache(file, fileInfo); ok {
+				t.Error("Expected an entry without a migration to be re-analyzed")
+			}
+
+			// Version 1 entries are upgraded through every later version
+			cacheMigrations[CacheSchemaVersion] = func(mediaInfo *MediaInfo) { t.Error("Migration past the current version applied") }
+			defer delete(cacheMigrations, CacheSchemaVersion)
+			saveOutdated(1)
+			ok, mediaInfo, err := cache.HasValidCache(file, fileInfo)
+			if !ok || err != nil || mediaInfo.ContentType != ContentTypeMovie {
+				t.Fatalf("Expected the entry to be upgraded, got %v %+v (err %v)", ok, mediaInfo, err)
+			}
+			if entry, _ := store.readEntry(pathHash(file)); entry == nil || entry.Schema != CacheSchemaVersion {
+				t.Errorf("Expected the upgraded entry to be saved, got %+v", entry)
+			}
+
+			counts := store.schemaCounts()
+			if counts.upgraded.Load() != 1 || counts.discarded.Load() != 1 {
+				t.Errorf("Expected 1 upgraded and 1 discarded entry, got %d and %d", counts.upgraded.Load(), counts.discarded.Load())
+			}
+		})
+	}
+}
+
+func TestOpenSQLiteCache_AddsSchemaColumn(t *testing.T) {
+	dir := t.TempDir()
+	db, err := sql.Open("sqlite3", sqliteCachePath(dir))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	_, err = db.Exec(`CREATE TABLE cache_entries (cache_key TEXT PRIMARY KEY, file_path TEXT NOT NULL, file_mod_time INTEGER NOT NULL,
+		file_size INTEGER NOT NULL, analyzed_at INTEGER NOT NULL, content_id TEXT NOT NULL, media_info TEXT NOT NULL);
+		INSERT INTO cache_entries VALUES ('key', 'movie.mkv', 0, 5, 0, '', '{}')`)
+	db.Close()
+	if err != nil {
+		t.Fatalf("Failed to create unversioned database: %v", err)
+	}
+
+	cache, err := OpenSQLiteCache(dir)
+	if err != nil {
+		t.Fatalf("OpenSQLiteCache failed: %v", err)
+	}
+	defer cache.Close()
+	entry, err := cache.readEntry("key")
+	if err != nil || entry == nil || entry.Schema != 0 {
+		t.Fatalf("Expected the existing entry to read as version 0, got %+v (err %v)", entry, err)
+	}
+}
+
+func TestOpenSQLiteCache_SpecialCharacters(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Movies? #1 100%")
+	cache, err := OpenSQLiteCache(dir)
+	if err != nil {
+		t.Fatalf("OpenSQLiteCache failed: %v", err)
+	}
+	cache.Close()
+	if _, err := os.Stat(sqliteCachePath(dir)); err != nil {
+		t.Errorf("Expected the database in %q: %v", dir, err)
+	}
+}
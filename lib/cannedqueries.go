@@ -0,0 +1,126 @@
+package lib
+
+import "sort"
+
+// CannedQuery is a named SQL query over a media database report
+type CannedQuery struct {
+	Name        string
+	Description string
+	SQL         string
+}
+
+// cannedQueries are common questions answered by the query command without writing SQL
+var cannedQueries = []CannedQuery{
+	{
+		Name:        "large-h264",
+		Description: "H.264 files over 15 Mbps and larger than 8 GB",
+		SQL: `SELECT file_path, ROUND(file_size / 1073741824.0, 2) AS size_gb, video_bitrate / 1000000 AS mbps
+FROM media
+WHERE video_codec = 'h264' AND video_bitrate > 15000000 AND file_size > 8 * 1073741824
+ORDER BY file_size DESC`,
+	},
+	{
+		Name:        "codecs",
+		Description: "File count, total size, and average bitrate per video codec",
+		SQL: `SELECT video_codec, COUNT(*) AS files, ROUND(SUM(file_size) / 1073741824.0, 2) AS total_gb,
+	ROUND(AVG(video_bitrate) / 1000000.0, 2) AS avg_mbps
+FROM media GROUP BY video_codec ORDER BY total_gb DESC`,
+	},
+	{
+		Name:        "largest",
+		Description: "The 25 largest files",
+		SQL: `SELECT file_path, ROUND(file_size / 1073741824.0, 2) AS size_gb, video_codec, video_width || 'x' || video_height AS resolution
+FROM media ORDER BY file_size DESC LIMIT 25`,
+	},
+	{
+		Name:        "resolutions",
+		Description: "File count and total size per video height",
+		SQL: `SELECT video_height, COUNT(*) AS files, ROUND(SUM(file_size) / 1073741824.0, 2) AS total_gb
+FROM media GROUP BY video_height ORDER BY video_height DESC`,
+	},
+	{
+		Name:        "high-frame-rate",
+		Description: "Files above 30 fps, such as 50/60 fps sports recordings",
+		SQL: `SELECT file_path, ROUND(frame_rate, 3) AS fps, video_width || 'x' || video_height AS resolution, scan_type
+FROM media WHERE frame_rate > 31 ORDER BY frame_rate DESC, file_path`,
+	},
+	{
+		Name:        "interlaced",
+		Description: "Interlaced and telecined files that need deinterlacing or inverse telecine",
+		SQL: `SELECT file_path, scan_type, ROUND(frame_rate, 3) AS fps, video_codec
+FROM media WHERE scan_type IN ('interlaced', 'telecined') ORDER BY scan_type, file_path`,
+	},
+	{
+		Name:        "hdr-formats",
+		Description: "File count and total size per HDR format, with Dolby Vision profiles",
+		SQL: `SELECT CASE WHEN hdr_format = '' THEN 'sdr' ELSE hdr_format END AS hdr_format,
+CASE WHEN dv_profile > 0 THEN dv_profile END AS dv_profile, COUNT(*) AS files, ROUND(SUM(file_size) / 1073741824.0, 2) AS total_gb
+FROM media GROUP BY 1, 2 ORDER BY files DESC`,
+	},
+	{
+		Name:        "audio-languages",
+		Description: "Number of files with an audio track in each language",
+		SQL: `SELECT CASE WHEN language = '' THEN 'unknown' ELSE language END AS language, COUNT(DISTINCT file_path) AS files
+FROM audio_tracks GROUP BY 1 ORDER BY files DESC`,
+	},
+	{
+		Name:        "commentary-tracks",
+		Description: "Commentary and audio description tracks, which transcode --drop-commentary can remove",
+		SQL: `SELECT file_path, idx, role, language, channels, ROUND(bitrate / 1000.0) AS kbps, title
+FROM audio_tracks WHERE role IN ('commentary', 'descriptive') ORDER BY file_path, idx`,
+	},
+	{
+		Name:        "audio-sidecars",
+		Description: "External audio files found next to videos, which transcode --mux-audio-sidecars can add",
+		SQL: `SELECT file_path, path, role, language, codec, channels, ROUND(bitrate / 1000.0) AS kbps
+FROM audio_sidecars ORDER BY file_path, path`,
+	},
+	{
+		Name:        "subtitle-kinds",
+		Description: "Number of files with full, forced, and SDH subtitles in each language",
+		SQL: `SELECT kind, CASE WHEN language = '' THEN 'unknown' ELSE language END AS language, COUNT(DISTINCT file_path) AS files
+FROM subtitle_tracks GROUP BY 1, 2 ORDER BY kind, files DESC`,
+	},
+	{
+		Name:        "variable-frame-rate",
+		Description: "Files with a variable frame rate, which transcode --cfr-convert makes safe for editing",
+		SQL:         `SELECT file_path, ROUND(frame_rate, 3) AS avg_fps, ROUND(duration / 60.0, 1) AS minutes FROM media WHERE is_vfr ORDER BY file_path`,
+	},
+	{
+		Name:        "release-groups",
+		Description: "File count, total size, and newest file per release group and muxing application",
+		SQL: `SELECT CASE WHEN release_group = '' THEN 'unknown' ELSE release_group END AS release_group,
+CASE WHEN encoder = '' THEN 'unknown' ELSE encoder END AS encoder, COUNT(*) AS files,
+ROUND(SUM(file_size) / 1073741824.0, 2) AS total_gb, MAX(NULLIF(creation_time, '')) AS newest
+FROM media GROUP BY 1, 2 ORDER BY files DESC`,
+	},
+	{
+		Name:        "no-subtitles",
+		Description: "Files without any subtitle tracks",
+		SQL:         `SELECT file_path FROM media WHERE subtitle_tracks = 0 ORDER BY file_path`,
+	},
+	{
+		Name:        "extra-video-streams",
+		Description: "Files with additional video streams, such as alternate angles or trailers, besides cover art",
+		SQL: `SELECT file_path, COUNT(*) AS extra_streams,
+	GROUP_CONCAT(codec || ' ' || width || 'x' || height || CASE WHEN title = '' THEN '' ELSE ' "' || title || '"' END, '; ') AS streams
+FROM video_streams WHERE kind = 'video' GROUP BY file_path ORDER BY extra_streams DESC, file_path`,
+	},
+}
+
+// CannedQueries returns the built-in queries sorted by name
+func CannedQueries() []CannedQuery {
+	queries := append([]CannedQuery{}, cannedQueries...)
+	sort.Slice(queries, func(i, j int) bool { return queries[i].Name < queries[j].Name })
+	return queries
+}
+
+// FindCannedQuery looks up a built-in query by name
+func FindCannedQuery(name string) (CannedQuery, bool) {
+	for _, query := range cannedQueries {
+		if query.Name == name {
+			return query, true
+		}
+	}
+	return CannedQuery{}, false
+}
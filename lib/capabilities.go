@@ -0,0 +1,318 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// capabilitiesFilename caches detected tool capabilities in the state directory
+const capabilitiesFilename = "capabilities.json"
+
+// capabilitiesSchema is the version of the capability cache's layout. Caches of another
+// version are discarded, so tools are probed again for what older versions did not record.
+const capabilitiesSchema = 2
+
+// capabilityProbeTimeout bounds each tool invocation made while detecting capabilities
+const capabilityProbeTimeout = 15 * time.Second
+
+var (
+	// versionPattern finds a dotted version number such as 6.1.1 or 1.7.3
+	versionPattern = regexp.MustCompile(`\d+(?:\.\d+)+`)
+	// handBrakeEncoders are the video encoders HandBrakeCLI may list in its help output
+	handBrakeEncoders = []string{
+		"x264", "x264_10bit", "x265", "x265_10bit", "x265_12bit", "svt_av1", "svt_av1_10bit",
+		"vt_h264", "vt_h265", "vt_h265_10bit", "nvenc_h264", "nvenc_h265", "nvenc_h265_10bit",
+		"nvenc_av1", "nvenc_av1_10bit", "qsv_h264", "qsv_h265", "qsv_h265_10bit", "qsv_av1",
+		"qsv_av1_10bit", "vce_h264", "vce_h265", "vce_h265_10bit", "vce_av1",
+		"mpeg4", "mpeg2", "VP8", "VP9", "VP9_10bit", "theora",
+	}
+)
+
+// ToolCapabilities describes the version and features of one external tool.
+// An empty Version or Encoders list means it could not be determined, not that it is absent.
+type ToolCapabilities struct {
+	Name           string    `json:"name"`
+	Path           string    `json:"path"`
+	Version        string    `json:"version,omitempty"`
+	Encoders       []string  `json:"encoders,omitempty"`
+	TenBitEncoders []string  `json:"ten_bit_encoders,omitempty"` // Hardware encoders that accept 10-bit input (ffmpeg only)
+	HWDecoders     []string  `json:"hw_decoders,omitempty"`      // Hardware decoding methods, as ffmpeg -hwaccels or HandBrakeCLI --enable-hw-decoding names them
+	Filters        []string  `json:"filters,omitempty"`
+	Size           int64     `json:"size"`     // Binary size, used to invalidate the cache
+	ModTime        time.Time `json:"mod_time"` // Binary modification time, used to invalidate the cache
+	DetectedAt     time.Time `json:"detected_at"`
+}
+
+// HasEncoder reports whether the tool lists the encoder
+func (tc *ToolCapabilities) HasEncoder(name string) bool {
+	return slices.Contains(tc.Encoders, name)
+}
+
+// HasFilter reports whether the tool lists the filter
+func (tc *ToolCapabilities) HasFilter(name string) bool {
+	return slices.Contains(tc.Filters, name)
+}
+
+// Capabilities is the matrix of detected external tools; missing tools have no entry
+type Capabilities struct {
+	Schema int                          `json:"schema"`
+	Tools  map[string]*ToolCapabilities `json:"tools"`
+}
+
+// Tool returns a tool's capabilities, or nil if it is not installed
+func (c *Capabilities) Tool(name string) *ToolCapabilities {
+	return c.Tools[name]
+}
+
+// DefaultCapabilitiesPath returns the capability cache location in the state directory
+func DefaultCapabilitiesPath() string {
+	return filepath.Join(DefaultStateDir(), capabilitiesFilename)
+}
+
+// DetectCapabilities detects the named tools, reusing cached results for binaries that have not
+// changed since they were last probed. Tools that cannot be found are left out of the result.
+func DetectCapabilities(ctx context.Context, cachePath string, names ...string) *Capabilities {
+	cached := loadCapabilities(cachePath)
+	caps := &Capabilities{Tools: make(map[string]*ToolCapabilities, len(names))}
+	changed := false
+
+	for _, name := range names {
+		path, _, err := ResolveTool(name)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+
+		if previous := cached.Tools[name]; previous != nil && previous.Path == path &&
+			previous.Size == info.Size() && previous.ModTime.Equal(info.ModTime()) {
+			caps.Tools[name] = previous
+			continue
+		}
+
+		tool := &ToolCapabilities{Name: name, Path: path, Size: info.Size(), ModTime: info.ModTime(), DetectedAt: time.Now().UTC()}
+		probeTool(ctx, tool)
+		slog.Debug("Detected tool capabilities", "tool", name, "version", tool.Version,
+			"encoders", len(tool.Encoders), "filters", len(tool.Filters))
+		caps.Tools[name] = tool
+		changed = true
+	}
+
+	if changed {
+		cached.Schema = capabilitiesSchema
+		for name, tool := range caps.Tools {
+			cached.Tools[name] = tool
+		}
+		if err := saveCapabilities(cachePath, cached); err != nil {
+			slog.Warn("Failed to cache tool capabilities", "error", err)
+		}
+	}
+	return caps
+}
+
+// probeTool fills in the version and features of a tool by running it
+func probeTool(ctx context.Context, tool *ToolCapabilities) {
+	switch tool.Name {
+	case "HandBrakeCLI":
+		tool.Version = parseToolVersion(runProbe(ctx, tool.Path, "--version"), "HandBrake")
+		help := runProbe(ctx, tool.Path, "--help")
+		words := strings.FieldsFunc(help, func(r rune) bool {
+			return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+		})
+		for _, encoder := range handBrakeEncoders {
+			if slices.Contains(words, encoder) {
+				tool.Encoders = append(tool.Encoders, encoder)
+			}
+		}
+		tool.HWDecoders = parseHandBrakeDecoders(help)
+	case "ffmpeg":
+		tool.Version = parseToolVersion(runProbe(ctx, tool.Path, "-hide_banner", "-version"), "ffmpeg version")
+		tool.Encoders = parseFFmpegList(runProbe(ctx, tool.Path, "-hide_banner", "-encoders"))
+		tool.Filters = parseFFmpegList(runProbe(ctx, tool.Path, "-hide_banner", "-filters"))
+		tool.HWDecoders = parseHWAccels(runProbe(ctx, tool.Path, "-hide_banner", "-hwaccels"))
+		for _, encoder := range hardwareEncoders(tool.Encoders) {
+			if isTenBitEncoder(runProbe(ctx, tool.Path, "-hide_banner", "-h", "encoder="+encoder)) {
+				tool.TenBitEncoders = append(tool.TenBitEncoders, encoder)
+			}
+		}
+	case "mkvmerge":
+		tool.Version = parseToolVersion(runProbe(ctx, tool.Path, "--version"), "mkvmerge v")
+	default:
+		tool.Version = parseToolVersion(runProbe(ctx, tool.Path, "-version"), tool.Name+" version")
+	}
+}
+
+// runProbe runs a tool and returns its combined output, or "" if it could not be run
+func runProbe(ctx context.Context, path string, args ...string) string {
+	ctx, cancel := context.WithTimeout(ctx, capabilityProbeTimeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil && len(output) == 0 {
+		slog.Debug("Failed to probe tool", "tool", path, "args", args, "error", err)
+	}
+	return string(output)
+}
+
+// parseToolVersion finds the version on the first output line starting with prefix
+func parseToolVersion(output, prefix string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, prefix) {
+			continue
+		}
+		return versionPattern.FindString(strings.TrimPrefix(line, prefix))
+	}
+	return ""
+}
+
+// parseFFmpegList extracts names from ffmpeg -encoders or -filters output, whose entries are
+// a column of capability flags followed by the name, after a legend of "flag = meaning" lines.
+func parseFFmpegList(output string) []string {
+	var names []string
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[1] == "=" || strings.HasPrefix(fields[0], "-") || strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+		if strings.Trim(fields[0], ".ABCDFSTVX|") != "" {
+			continue
+		}
+		names = append(names, fields[1])
+	}
+	return names
+}
+
+// CompareVersions compares dotted versions numerically, returning -1, 0, or 1
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Requirement is a tool version and feature set a feature depends on
+type Requirement struct {
+	Feature    string   // Flag or feature shown in errors, such as "--tonemap-sdr"
+	Tool       string   // Tool name, such as "ffmpeg"
+	MinVersion string   // Minimum dotted version (empty for any)
+	Encoders   []string // Encoders that must all be available
+	Filters    []string // Filters that must all be available
+}
+
+// String describes the requirement, e.g. "ffmpeg ≥ 6.0 with zscale"
+func (r Requirement) String() string {
+	desc := r.Tool
+	if r.MinVersion != "" {
+		desc += " ≥ " + r.MinVersion
+	}
+	if features := append(append([]string{}, r.Encoders...), r.Filters...); len(features) > 0 {
+		desc += " with " + strings.Join(features, ", ")
+	}
+	return desc
+}
+
+// Check verifies requirements against the detected tools, so unsupported features fail before
+// any work starts. Versions and feature lists that could not be detected are assumed to satisfy
+// the requirement, with a warning, rather than blocking tools with unusual output.
+func (c *Capabilities) Check(requirements ...Requirement) error {
+	for _, req := range requirements {
+		tool := c.Tool(req.Tool)
+		if tool == nil {
+			return fmt.Errorf("%s requires %s, which was not found in the tools directory or PATH", req.Feature, req)
+		}
+
+		var problems []string
+		if req.MinVersion != "" {
+			if tool.Version == "" {
+				slog.Warn("Could not detect tool version, assuming it is recent enough", "tool", req.Tool, "required", req.MinVersion)
+			} else if CompareVersions(tool.Version, req.MinVersion) < 0 {
+				problems = append(problems, fmt.Sprintf("found %s %s", req.Tool, tool.Version))
+			}
+		}
+		problems = append(problems, missingFeatures(req.Tool, "encoder", req.Encoders, tool.Encoders)...)
+		problems = append(problems, missingFeatures(req.Tool, "filter", req.Filters, tool.Filters)...)
+
+		if len(problems) > 0 {
+			return fmt.Errorf("%s requires %s (%s)", req.Feature, req, strings.Join(problems, "; "))
+		}
+	}
+	return nil
+}
+
+// missingFeatures lists required features a tool lacks; an empty detected list is treated as unknown
+func missingFeatures(toolName, kind string, required, detected []string) []string {
+	if len(required) == 0 {
+		return nil
+	}
+	if len(detected) == 0 {
+		slog.Warn("Could not detect tool features, assuming they are available", "tool", toolName, "kind", kind, "required", required)
+		return nil
+	}
+
+	var problems []string
+	for _, name := range required {
+		if !slices.Contains(detected, name) {
+			problems = append(problems, fmt.Sprintf("%s %s is missing", kind, name))
+		}
+	}
+	return problems
+}
+
+// loadCapabilities reads the capability cache, returning an empty matrix if it is missing or invalid
+func loadCapabilities(path string) *Capabilities {
+	caps := &Capabilities{Tools: map[string]*ToolCapabilities{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return caps
+	}
+	if err := json.Unmarshal(data, caps); err != nil || caps.Tools == nil {
+		slog.Debug("Ignoring invalid capability cache", "path", path, "error", err)
+		return &Capabilities{Tools: map[string]*ToolCapabilities{}}
+	}
+	if caps.Schema != capabilitiesSchema {
+		slog.Debug("Ignoring capability cache of another version", "path", path, "schema", caps.Schema)
+		return &Capabilities{Tools: map[string]*ToolCapabilities{}}
+	}
+	return caps
+}
+
+// saveCapabilities writes the capability cache
+func saveCapabilities(path string, caps *Capabilities) error {
+	data, err := json.MarshalIndent(caps, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal capabilities: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write capabilities: %w", err)
+	}
+	return nil
+}
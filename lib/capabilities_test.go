@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"6.1.1", "6.0", 1},
+		{"6.0", "6.0.0", 0},
+		{"5.1", "6.0", -1},
+		{"1.10.0", "1.9.2", 1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestParseFFmpegList(t *testing.T) {
+	encoders := `Encoders:
+ V..... = Video
+ A..... = Audio
+ ------
+ V....D libx264              libx264 H.264 / AVC / MPEG-4 AVC (codec h264)
+ V..X.. libx265              libx265 H.265 / HEVC (codec hevc)
+ A....D aac                  AAC (Advanced Audio Coding)
+`
+	if got := strings.Join(parseFFmpegList(encoders), ","); got != "libx264,libx265,aac" {
+		t.Errorf("Unexpected encoders %s", got)
+	}
+
+	filters := `Filters:
+  T.. = Timeline support
+  | = Source or sink filter
+ ... scale             V->V       Scale the input video size and/or convert the image format.
+ .S. zscale            V->V       Apply resizing, colorspace and bit depth conversion.
+`
+	if got := strings.Join(parseFFmpegList(filters), ","); got != "scale,zscale" {
+		t.Errorf("Unexpected filters %s", got)
+	}
+}
+
+func TestCapabilitiesCheck(t *testing.T) {
+	caps := &Capabilities{Tools: map[string]*ToolCapabilities{
+		"ffmpeg":       {Name: "ffmpeg", Version: "5.1.2", Filters: []string{"scale"}},
+		"HandBrakeCLI": {Name: "HandBrakeCLI", Version: "1.7.3", Encoders: []string{"x265", "x265_10bit"}},
+	}}
+
+	tonemap := Requirement{Feature: "--tonemap-sdr", Tool: "ffmpeg", MinVersion: "6.0", Filters: []string{"zscale"}}
+	err := caps.Check(tonemap)
+	if err == nil || !strings.HasPrefix(err.Error(), "--tonemap-sdr requires ffmpeg ≥ 6.0 with zscale (found ffmpeg 5.1.2; filter zscale is missing)") {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if err := caps.Check(Requirement{Feature: "HDR", Tool: "HandBrakeCLI", Encoders: []string{"x265_10bit"}}); err != nil {
+		t.Errorf("Expected available encoder to pass, got %v", err)
+	}
+	if err := caps.Check(Requirement{Feature: "x", Tool: "missing"}); err == nil {
+		t.Error("Expected error for missing tool")
+	}
+
+	// Undetectable versions and feature lists don't block features
+	caps.Tools["ffmpeg"] = &ToolCapabilities{Name: "ffmpeg"}
+	if err := caps.Check(tonemap); err != nil {
+		t.Errorf("Expected unknown capabilities to pass, got %v", err)
+	}
+}
+
+func TestDetectCapabilitiesCaches(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MEDIA_MGMT_TOOLS_DIR", dir)
+	counter := filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho x >> " + counter + "\necho 'fakeprobe version 2.3.4-static'\n"
+	if err := os.WriteFile(filepath.Join(dir, "fakeprobe"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write fake tool: %v", err)
+	}
+
+	cachePath := filepath.Join(dir, capabilitiesFilename)
+	caps := DetectCapabilities(context.Background(), cachePath, "fakeprobe", "not-installed-tool")
+	if tool := caps.Tool("fakeprobe"); tool == nil || tool.Version != "2.3.4" {
+		t.Fatalf("Expected detected version 2.3.4, got %+v", tool)
+	}
+	if caps.Tool("not-installed-tool") != nil {
+		t.Error("Expected missing tool to be left out")
+	}
+
+	DetectCapabilities(context.Background(), cachePath, "fakeprobe")
+	if calls, _ := os.ReadFile(counter); strings.Count(string(calls), "x") != 1 {
+		t.Errorf("Expected cached capabilities to be reused, tool ran %d times", strings.Count(string(calls), "x"))
+	}
+}
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected []string
+		wantErr  bool
+	}{
+		{"", nil, false},
+		{"--encopts  aq-mode=3", []string{"--encopts", "aq-mode=3"}, false},
+		{`--filter "deband=1 strength" -x`, []string{"--filter", "deband=1 strength", "-x"}, false},
+		{`-metadata 'title=It\'s'`, nil, true},
+		{`-metadata title=It\'s`, []string{"-metadata", "title=It's"}, false},
+		{`--empty ""`, []string{"--empty", ""}, false},
+		{`"unterminated`, nil, true},
+		{`trailing\`, nil, true},
+	}
+
+	for _, tt := range tests {
+		result, err := SplitArgs(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitArgs(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if strings.Join(result, "|") != strings.Join(tt.expected, "|") || len(result) != len(tt.expected) {
+			t.Errorf("SplitArgs(%q) = %q, want %q", tt.line, result, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds settings loaded from the YAML config file
+type Config struct {
+	SMTP       SMTPConfig       `yaml:"smtp"`
+	Schedules  []ScheduleConfig `yaml:"schedules"`
+	Tokens     []APIToken       `yaml:"tokens"`
+	Report     ReportBranding   `yaml:"report"`
+	Filters    []SavedFilter    `yaml:"filters"`      // Views offered in every HTML report, alongside those saved with the filters command
+	Hooks      []ReportHook     `yaml:"report_hooks"` // Commands run after reports are generated
+	Heuristics HeuristicWeights `yaml:"heuristics"`   // Video stream classification weights, merged over the defaults
+	Cache      CacheConfig      `yaml:"cache"`
+	Transcode  TranscodeConfig  `yaml:"transcode"`
+}
+
+// TranscodeConfig holds settings for transcode runs and serve-mode transcode jobs
+type TranscodeConfig struct {
+	Presets []Preset `yaml:"presets"` // Settings for particular kinds of content; the first that matches a file applies
+}
+
+// Preset overrides transcode settings for the files it matches. A file must meet every
+// condition the preset sets.
+type Preset struct {
+	Name        string `yaml:"name"`
+	ContentType string `yaml:"content_type"` // episode, movie, or unknown ("" matches every file)
+	Resolution  string `yaml:"resolution"`   // A ResolutionClass: 2160p, 1080p, 720p, or sd ("" matches every file)
+	Quality     int    `yaml:"quality"`      // Replaces --quality (0 keeps it)
+}
+
+// Matches reports whether the preset applies to content of the given type and resolution class
+func (p Preset) Matches(contentType, resolution string) bool {
+	return (p.ContentType == "" || p.ContentType == contentType) && (p.Resolution == "" || p.Resolution == resolution)
+}
+
+// validate checks a preset's settings
+func (p Preset) validate() error {
+	switch p.ContentType {
+	case "", ContentTypeEpisode, ContentTypeMovie, ContentTypeUnknown:
+	default:
+		return fmt.Errorf("invalid content_type %q: must be episode, movie, or unknown", p.ContentType)
+	}
+	switch p.Resolution {
+	case "", "2160p", "1080p", "720p", "sd":
+	default:
+		return fmt.Errorf("invalid resolution %q: must be 2160p, 1080p, 720p, or sd", p.Resolution)
+	}
+	if p.Quality < 0 || p.Quality > 100 {
+		return fmt.Errorf("invalid quality %d: must be between 0 and 100", p.Quality)
+	}
+	return nil
+}
+
+// CacheConfig locates the analysis cache
+type CacheConfig struct {
+	Dir string `yaml:"dir"` // Cache shared by every run, instead of one in each output directory
+}
+
+// API token roles for serve mode
+const (
+	RoleViewer   = "viewer"   // May browse reports and query media
+	RoleOperator = "operator" // May also analyze files, enqueue transcodes, and other operations that use the host
+)
+
+// APIToken grants a role to serve-mode API clients presenting the token.
+// When no tokens are configured the API is open to everyone.
+type APIToken struct {
+	Name  string `yaml:"name"`
+	Token string `yaml:"token"`
+	Role  string `yaml:"role"` // viewer (default) or operator
+}
+
+// ScheduleConfig describes a recurring analyze run for one library in serve mode
+type ScheduleConfig struct {
+	Name    string `yaml:"name" json:"name"`
+	Input   string `yaml:"input" json:"input"`       // Library directory to analyze
+	Output  string `yaml:"output" json:"output"`     // Directory for reports and the analysis cache
+	Cron    string `yaml:"cron" json:"cron"`         // Standard 5-field cron expression or descriptor such as @daily
+	NoCache bool   `yaml:"no_cache" json:"no_cache"` // Re-analyze every file instead of using the cache
+}
+
+// SMTPConfig holds the mail server settings used to send summary emails
+type SMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"` // Falls back to $MEDIA_MGMT_SMTP_PASSWORD if empty
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	TLS      bool     `yaml:"tls"` // Use implicit TLS (usually port 465) instead of STARTTLS
+}
+
+// DefaultConfigPath returns the config file location inside the state directory
+func DefaultConfigPath() string {
+	return filepath.Join(DefaultStateDir(), "config.yaml")
+}
+
+// LoadConfig reads the YAML config file at path.
+// A missing file yields an empty config so the tool works without one.
+func LoadConfig(path string) (*Config, error) {
+	config := &Config{Heuristics: DefaultHeuristicWeights()}
+	defaultCodecScores := config.Heuristics.CodecScores
+	config.Heuristics.CodecScores = nil
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		config.Heuristics.CodecScores = defaultCodecScores
+		return config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if config.SMTP.Password == "" {
+		config.SMTP.Password = os.Getenv("MEDIA_MGMT_SMTP_PASSWORD")
+	}
+	if config.SMTP.Port == 0 {
+		config.SMTP.Port = 587
+		if config.SMTP.TLS {
+			config.SMTP.Port = 465
+		}
+	}
+	config.Report.resolveLogo(filepath.Dir(path))
+	for i, schedule := range config.Schedules {
+		if schedule.Name == "" {
+			config.Schedules[i].Name = filepath.Base(schedule.Input)
+		}
+		if schedule.Input == "" || schedule.Output == "" || schedule.Cron == "" {
+			return nil, fmt.Errorf("schedule %d in %s must set input, output, and cron", i+1, path)
+		}
+	}
+	for i := range config.Hooks {
+		hook := &config.Hooks[i]
+		if hook.Name == "" {
+			hook.Name = fmt.Sprintf("hook-%d", i+1)
+		}
+		if err := hook.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		hook.resolveCommand(filepath.Dir(path))
+	}
+	filterNames := make(map[string]bool, len(config.Filters))
+	for i, filter := range config.Filters {
+		if err := filter.Validate(); err != nil {
+			return nil, fmt.Errorf("filter %d in %s: %w", i+1, path, err)
+		}
+		if filterNames[filter.Name] {
+			return nil, fmt.Errorf("filter %d in %s duplicates the name %q", i+1, path, filter.Name)
+		}
+		filterNames[filter.Name] = true
+	}
+	for i := range config.Transcode.Presets {
+		preset := &config.Transcode.Presets[i]
+		if preset.Name == "" {
+			preset.Name = fmt.Sprintf("preset-%d", i+1)
+		}
+		if err := preset.validate(); err != nil {
+			return nil, fmt.Errorf("transcode preset %q in %s: %w", preset.Name, path, err)
+		}
+	}
+	codecScores, err := mergeCodecScores(defaultCodecScores, config.Heuristics.CodecScores)
+	if err != nil {
+		return nil, fmt.Errorf("heuristics in %s: %w", path, err)
+	}
+	config.Heuristics.CodecScores = codecScores
+	if err := config.Heuristics.Validate(); err != nil {
+		return nil, fmt.Errorf("heuristics in %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(config.Tokens))
+	for i, token := range config.Tokens {
+		if token.Token == "" {
+			return nil, fmt.Errorf("token %d in %s must set token", i+1, path)
+		}
+		if seen[token.Token] {
+			return nil, fmt.Errorf("token %d in %s duplicates another token", i+1, path)
+		}
+		seen[token.Token] = true
+		if token.Name == "" {
+			config.Tokens[i].Name = fmt.Sprintf("token-%d", i+1)
+		}
+		switch token.Role {
+		case "":
+			config.Tokens[i].Role = RoleViewer
+		case RoleViewer, RoleOperator:
+		default:
+			return nil, fmt.Errorf("token %d in %s has invalid role %q: must be viewer or operator", i+1, path, token.Role)
+		}
+	}
+	return config, nil
+}
+
+// Validate checks that the settings required to send mail are present
+func (c SMTPConfig) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("smtp.host is not set in the config file")
+	}
+	if c.From == "" {
+		return fmt.Errorf("smtp.from is not set in the config file")
+	}
+	if len(c.To) == 0 {
+		return fmt.Errorf("smtp.to is not set in the config file")
+	}
+	return nil
+}
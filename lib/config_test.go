@@ -0,0 +1,195 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	config, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+	if err != nil || len(config.Schedules) != 0 || config.SMTP.Host != "" {
+		t.Fatalf("Expected empty config for missing file, got %+v, err %v", config, err)
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	data := `smtp:
+  host: mail.example.com
+  tls: true
+  from: media@example.com
+  to: [admin@example.com]
+schedules:
+  - input: /media/movies
+    output: /reports/movies
+    cron: "0 3 * * *"
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.SMTP.Port != 465 {
+		t.Errorf("Expected implicit TLS default port 465, got %d", config.SMTP.Port)
+	}
+	if err := config.SMTP.Validate(); err != nil {
+		t.Errorf("Expected valid SMTP config, got %v", err)
+	}
+	if len(config.Schedules) != 1 || config.Schedules[0].Name != "movies" {
+		t.Errorf("Expected schedule named after its input directory, got %+v", config.Schedules)
+	}
+
+	if err := os.WriteFile(path, []byte("schedules:\n  - input: /media\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("Expected error for schedule without output and cron")
+	}
+}
+
+func TestLoadConfigTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+		role    string
+	}{
+		{"defaults to viewer", "tokens:\n  - name: family\n    token: abc\n", false, RoleViewer},
+		{"operator", "tokens:\n  - token: abc\n    role: operator\n", false, RoleOperator},
+		{"missing token", "tokens:\n  - name: family\n", true, ""},
+		{"invalid role", "tokens:\n  - token: abc\n    role: admin\n", true, ""},
+		{"duplicate token", "tokens:\n  - token: abc\n  - token: abc\n    role: operator\n", true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			config, err := LoadConfig(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", config.Tokens)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if config.Tokens[0].Role != tt.role || config.Tokens[0].Name == "" {
+				t.Errorf("Expected role %s with a name, got %+v", tt.role, config.Tokens[0])
+			}
+		})
+	}
+}
+
+func TestLoadConfigHeuristics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `heuristics:
+  codec_scores:
+    mjpeg: 90
+  low_bitrate: 20000
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	defaults := DefaultHeuristicWeights()
+	weights := config.Heuristics
+	if weights.CodecScores["mjpeg"] != 90 || weights.CodecScores["hevc"] != defaults.CodecScores["hevc"] {
+		t.Errorf("codec scores were not merged over the defaults: %v", weights.CodecScores)
+	}
+	if weights.LowBitrate != 20000 || weights.SmallResolutionPixels != defaults.SmallResolutionPixels {
+		t.Errorf("unexpected weights: %+v", weights)
+	}
+
+	if err := os.WriteFile(path, []byte("heuristics:\n  short_duration_ratio: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for an out-of-range short_duration_ratio")
+	}
+}
+
+func TestLoadConfigHeuristicsCodecCase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("heuristics:\n  codec_scores:\n    MJPEG: 60\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer SetHeuristicWeights(DefaultHeuristicWeights())
+
+	// The default lowercase mjpeg score must lose to the configured MJPEG score on every run,
+	// not only when map iteration happens to visit them in a favorable order
+	for run := 0; run < 50; run++ {
+		config, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig failed: %v", err)
+		}
+		if score := config.Heuristics.CodecScores["mjpeg"]; score != 60 {
+			t.Fatalf("run %d: expected the configured mjpeg score 60, got %v", run, score)
+		}
+		if _, ok := config.Heuristics.CodecScores["MJPEG"]; ok {
+			t.Fatalf("run %d: expected codec score keys to be lowercased: %v", run, config.Heuristics.CodecScores)
+		}
+
+		SetHeuristicWeights(config.Heuristics)
+		if score := heuristicWeights.Load().CodecScores["mjpeg"]; score != 60 {
+			t.Fatalf("run %d: expected the mjpeg score in use to be 60, got %v", run, score)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("heuristics:\n  codec_scores:\n    MJPEG: 60\n    mjpeg: 20\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for a codec listed in two cases")
+	}
+}
+
+func TestLoadConfigTranscodePresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"content types", "transcode:\n  presets:\n    - name: tv\n      content_type: episode\n      quality: 62\n    - content_type: movie\n      quality: 72\n", false},
+		{"invalid content type", "transcode:\n  presets:\n    - content_type: documentary\n", true},
+		{"invalid quality", "transcode:\n  presets:\n    - content_type: movie\n      quality: 120\n", true},
+		{"invalid resolution", "transcode:\n  presets:\n    - resolution: 4k\n      quality: 65\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			config, err := LoadConfig(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", config.Transcode.Presets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			presets := config.Transcode.Presets
+			if len(presets) != 2 || presets[0].Name != "tv" || presets[1].Name != "preset-2" || presets[1].Quality != 72 {
+				t.Errorf("Unexpected presets %+v", presets)
+			}
+			if !presets[0].Matches(ContentTypeEpisode, "1080p") || presets[0].Matches(ContentTypeMovie, "1080p") {
+				t.Errorf("Preset %+v matched the wrong content types", presets[0])
+			}
+		})
+	}
+}
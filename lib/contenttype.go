@@ -0,0 +1,30 @@
+package lib
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Content types recorded in MediaInfo.ContentType
+const (
+	ContentTypeEpisode = "episode"
+	ContentTypeMovie   = "movie"
+	ContentTypeUnknown = "unknown" // Too short for a feature and not named like an episode, such as extras and clips
+)
+
+// movieMinDuration is the shortest running time, in seconds, taken for a feature film
+const movieMinDuration = 60 * 60
+
+// DetectContentType tags a file as a TV episode, a movie, or unknown. Files named with an
+// episode marker such as S01E02 or 1x02, or kept in a season directory, are episodes; others
+// running at least an hour are movies.
+func DetectContentType(path string, duration float64) string {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if episodePattern.MatchString(name) || seasonDirPattern.MatchString(filepath.Base(filepath.Dir(path))) {
+		return ContentTypeEpisode
+	}
+	if duration >= movieMinDuration {
+		return ContentTypeMovie
+	}
+	return ContentTypeUnknown
+}
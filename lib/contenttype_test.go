@@ -0,0 +1,24 @@
+package lib
+
+import "testing"
+
+func TestDetectContentType(t *testing.T) {
+	tests := []struct {
+		path     string
+		duration float64
+		expected string
+	}{
+		{"/tv/Show Name/Show.Name.S01E02.mkv", 2640, ContentTypeEpisode},
+		{"/tv/Show Name/show 1x02.mkv", 2640, ContentTypeEpisode},
+		{"/tv/Show Name/Season 1/Pilot.mkv", 3900, ContentTypeEpisode},
+		{"/movies/Film (1999)/Film (1999).mkv", 7200, ContentTypeMovie},
+		{"/movies/Film (1999)/Trailer.mkv", 150, ContentTypeUnknown},
+		{"/movies/unprobed.mkv", 0, ContentTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := DetectContentType(tt.path, tt.duration); got != tt.expected {
+			t.Errorf("DetectContentType(%q, %v) = %s, want %s", tt.path, tt.duration, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SendSummaryEmail emails a run summary as a multipart message with Markdown and HTML parts
+func SendSummaryEmail(config SMTPConfig, summary *RunSummary) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	htmlBody, err := summary.HTML()
+	if err != nil {
+		return err
+	}
+	message := buildSummaryMessage(config, summary.Title, summary.Markdown(), htmlBody)
+
+	addr := net.JoinHostPort(config.Host, strconv.Itoa(config.Port))
+	var auth smtp.Auth
+	if config.Username != "" {
+		auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	if !config.TLS {
+		// SendMail upgrades to STARTTLS when the server supports it
+		if err := smtp.SendMail(addr, auth, config.From, config.To, message); err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: config.Host})
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(config.From); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	for _, recipient := range config.To {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", recipient, err)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if _, err := writer.Write(message); err != nil {
+		writer.Close()
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return client.Quit()
+}
+
+// buildSummaryMessage assembles a multipart/alternative email with plain text and HTML bodies
+func buildSummaryMessage(config SMTPConfig, subject, text, html string) []byte {
+	boundaryBytes := make([]byte, 12)
+	rand.Read(boundaryBytes)
+	boundary := "media-mgmt-" + hex.EncodeToString(boundaryBytes)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", config.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(config.To, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", text},
+		{"text/html", html},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n\r\n", part.contentType)
+		buf.WriteString(strings.ReplaceAll(part.body, "\n", "\r\n"))
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes()
+}
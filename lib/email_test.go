@@ -0,0 +1,109 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestBuildSummaryMessage(t *testing.T) {
+	config := SMTPConfig{From: "media@example.com", To: []string{"a@example.com", "b@example.com"}}
+
+	tests := []struct {
+		name    string
+		summary *RunSummary
+		html    []string // Substrings expected in the HTML part
+	}{
+		{
+			name:    "ASCII subject",
+			summary: &RunSummary{Title: "Analysis of movies", Stats: []SummaryStat{{"Files analyzed", "3 of 3"}}},
+			html:    []string{"<h1>Analysis of movies</h1>"},
+		},
+		{
+			name:    "non-ASCII subject",
+			summary: &RunSummary{Title: "Análisis de películas ✓"},
+			html:    []string{"<h1>Análisis de películas ✓</h1>"},
+		},
+		{
+			name: "failures with markup in file names",
+			summary: &RunSummary{Title: "Analysis of movies", Failures: []FileFailure{
+				{File: "/movies/<b>bold</b>.mkv", Error: "ffprobe failed"},
+			}},
+			html: []string{"<h2>" + T(MsgFailures, 1) + "</h2>", "<code>/movies/&lt;b&gt;bold&lt;/b&gt;.mkv</code>: ffprobe failed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			htmlBody, err := tt.summary.HTML()
+			if err != nil {
+				t.Fatalf("HTML failed: %v", err)
+			}
+			raw := buildSummaryMessage(config, tt.summary.Title, tt.summary.Markdown(), htmlBody)
+
+			msg, err := mail.ReadMessage(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("Failed to parse message: %v", err)
+			}
+			if got := msg.Header.Get("To"); got != "a@example.com, b@example.com" {
+				t.Errorf("Unexpected To header %q", got)
+			}
+
+			rawSubject := msg.Header.Get("Subject")
+			for _, r := range rawSubject {
+				if r > 127 {
+					t.Fatalf("Subject header is not encoded: %q", rawSubject)
+				}
+			}
+			subject, err := new(mime.WordDecoder).DecodeHeader(rawSubject)
+			if err != nil || subject != tt.summary.Title {
+				t.Errorf("Expected subject %q, got %q (%v)", tt.summary.Title, subject, err)
+			}
+
+			mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != "multipart/alternative" {
+				t.Fatalf("Expected multipart/alternative, got %q (%v)", mediaType, err)
+			}
+			reader := multipart.NewReader(msg.Body, params["boundary"])
+
+			var parts []string
+			bodies := make(map[string]string)
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Failed to read part: %v", err)
+				}
+				contentType, partParams, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+				if partParams["charset"] != "utf-8" {
+					t.Errorf("Expected utf-8 charset for %s, got %q", contentType, partParams["charset"])
+				}
+				body, err := io.ReadAll(part)
+				if err != nil {
+					t.Fatalf("Failed to read part body: %v", err)
+				}
+				parts = append(parts, contentType)
+				bodies[contentType] = strings.ReplaceAll(string(body), "\r\n", "\n")
+			}
+
+			// Clients show the last alternative they support, so HTML must come after plain text
+			if strings.Join(parts, ",") != "text/plain,text/html" {
+				t.Fatalf("Expected text/plain then text/html parts, got %v", parts)
+			}
+			if want := strings.TrimSuffix(tt.summary.Markdown(), "\n"); !strings.HasPrefix(bodies["text/plain"], want) {
+				t.Errorf("Plain text part does not match the Markdown summary:\n%s", bodies["text/plain"])
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(bodies["text/html"], want) {
+					t.Errorf("HTML part missing %q:\n%s", want, bodies["text/html"])
+				}
+			}
+		})
+	}
+}
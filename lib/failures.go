@@ -0,0 +1,65 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Stages at which analyzing a file can fail
+const (
+	FailureStageStat    = "stat"    // The file could not be read from disk
+	FailureStageProbe   = "probe"   // ffprobe failed or timed out
+	FailureStageParse   = "parse"   // ffprobe's output could not be interpreted
+	FailureStageAnalyze = "analyze" // Any other failure
+)
+
+// AnalysisError is a failure to analyze a file, tagged with the stage that failed
+type AnalysisError struct {
+	File  string
+	Stage string
+	Err   error
+}
+
+func (e *AnalysisError) Error() string {
+	return fmt.Sprintf("%s failed for %s: %v", e.Stage, e.File, e.Err)
+}
+
+func (e *AnalysisError) Unwrap() error {
+	return e.Err
+}
+
+// analysisFailure describes why a file could not be analyzed, for reports
+func analysisFailure(file string, err error) FileFailure {
+	var analysisErr *AnalysisError
+	if errors.As(err, &analysisErr) {
+		return FileFailure{File: file, Stage: analysisErr.Stage, Error: strings.TrimSpace(analysisErr.Err.Error())}
+	}
+	return FileFailure{File: file, Stage: FailureStageAnalyze, Error: strings.TrimSpace(err.Error())}
+}
+
+// sortFailures orders failures by file path, matching the order of analyzed files in reports
+func sortFailures(failures []FileFailure) {
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].File < failures[j].File
+	})
+}
+
+// writeMarkdownFailures writes the files that could not be analyzed
+func writeMarkdownFailures(w io.Writer, failures []FileFailure) {
+	fmt.Fprintf(w, "\n## Failed Files\n\n")
+	fmt.Fprintf(w, "Files missing from this report because they could not be analyzed: %d\n\n", len(failures))
+	fmt.Fprintf(w, "| File | Stage | Error |\n")
+	fmt.Fprintf(w, "|------|-------|-------|\n")
+	for _, failure := range failures {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", failure.File, failure.Stage, markdownCell(failure.Error))
+	}
+}
+
+// markdownCell keeps free-form text such as ffprobe errors from breaking a Markdown table row
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.Join(strings.Fields(text), " ")
+}
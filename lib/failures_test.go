@@ -0,0 +1,101 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnalysisFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantStage string
+		wantError string
+	}{
+		{
+			name:      "tagged probe error",
+			err:       &AnalysisError{File: "/media/a.mkv", Stage: FailureStageProbe, Err: errors.New("timed out after 1m0s")},
+			wantStage: FailureStageProbe,
+			wantError: "timed out after 1m0s",
+		},
+		{
+			name:      "untagged error",
+			err:       errors.New("disk on fire"),
+			wantStage: FailureStageAnalyze,
+			wantError: "disk on fire",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := analysisFailure("/media/a.mkv", tt.err)
+			if failure.File != "/media/a.mkv" || failure.Stage != tt.wantStage || failure.Error != tt.wantError {
+				t.Errorf("analysisFailure() = %+v, want stage %q and error %q", failure, tt.wantStage, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFileStatFailure(t *testing.T) {
+	_, err := NewMediaAnalyzer().AnalyzeFile(context.Background(), filepath.Join(t.TempDir(), "missing.mkv"))
+	var analysisErr *AnalysisError
+	if !errors.As(err, &analysisErr) || analysisErr.Stage != FailureStageStat {
+		t.Errorf("AnalyzeFile() error = %v, want a stat stage AnalysisError", err)
+	}
+}
+
+func TestReportsListFailures(t *testing.T) {
+	rg := NewReportGenerator(t.TempDir())
+	rg.Failures = []FileFailure{{File: "/media/bad.mkv", Stage: FailureStageProbe, Error: "Invalid data | found"}}
+	mediaInfos := []*MediaInfo{{FilePath: "/media/good.mkv", VideoCodec: "h264"}}
+
+	var csvOut bytes.Buffer
+	if err := rg.WriteCSV(&csvOut, mediaInfos); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(&csvOut).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	last := rows[len(rows)-1]
+	if len(rows) != 3 || last[0] != "/media/bad.mkv" || last[len(last)-2] != FailureStageProbe || last[len(last)-1] != "Invalid data | found" {
+		t.Errorf("CSV is missing the failure row: %v", rows)
+	}
+
+	var markdown bytes.Buffer
+	if err := rg.WriteMarkdown(&markdown, mediaInfos); err != nil {
+		t.Fatalf("WriteMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "## Failed Files") || !strings.Contains(markdown.String(), `| /media/bad.mkv | probe | Invalid data \| found |`) {
+		t.Errorf("Markdown is missing the failure:\n%s", markdown.String())
+	}
+
+	var jsonOut bytes.Buffer
+	if err := rg.WriteJSON(&jsonOut, mediaInfos); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if !strings.Contains(jsonOut.String(), `"failed_files"`) || !strings.Contains(jsonOut.String(), `"stage": "probe"`) {
+		t.Errorf("JSON is missing the failure:\n%s", jsonOut.String())
+	}
+
+	dbPath := filepath.Join(t.TempDir(), "media.db")
+	if err := writeSQLiteFile(dbPath, mediaInfos, rg.Failures); err != nil {
+		t.Fatalf("writeSQLiteFile failed: %v", err)
+	}
+	defer os.Remove(dbPath)
+	db, err := OpenMediaDB(dbPath)
+	if err != nil {
+		t.Fatalf("OpenMediaDB failed: %v", err)
+	}
+	defer db.Close()
+	var stage string
+	if err := db.DB().QueryRow("SELECT stage FROM failed_files WHERE file_path = ?", "/media/bad.mkv").Scan(&stage); err != nil || stage != FailureStageProbe {
+		t.Errorf("failed_files stage = %q, %v; want probe", stage, err)
+	}
+}
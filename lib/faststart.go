@@ -0,0 +1,120 @@
+package lib
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// mp4Extensions are the ISO base media file types faststart applies to
+var mp4Extensions = map[string]bool{".mp4": true, ".m4v": true, ".mov": true}
+
+// IsMP4 reports whether path has an MP4 or QuickTime extension
+func IsMP4(path string) bool {
+	return mp4Extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// MoovAtEnd reports whether an MP4's moov atom, which players need before they can start, comes
+// after the media data. Such files must be downloaded in full before streaming playback starts.
+// Only the top-level atom headers are read.
+func MoovAtEnd(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+	return moovAfterMdat(file)
+}
+
+// moovAfterMdat walks top-level atoms until it finds moov or mdat
+func moovAfterMdat(r io.ReadSeeker) (bool, error) {
+	header := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return false, fmt.Errorf("no moov atom found")
+			}
+			return false, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			size = -1 // Extends to the end of the file
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return false, fmt.Errorf("truncated atom header: %w", err)
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		switch string(header[4:8]) {
+		case "moov":
+			return false, nil
+		case "mdat":
+			return true, nil
+		}
+		if size < 0 {
+			return false, fmt.Errorf("no moov atom found")
+		}
+		if size < headerSize {
+			return false, fmt.Errorf("invalid atom size %d", size)
+		}
+		if _, err := r.Seek(size-headerSize, io.SeekCurrent); err != nil {
+			return false, err
+		}
+	}
+}
+
+// faststartArgs builds the ffmpeg arguments that remux path into tmpPath with the moov atom
+// first. The temporary name hides the extension, so the format is named: QuickTime for .mov
+// files, which the mp4 muxer would rewrite with MP4 brands, and MP4 for the rest.
+func faststartArgs(path, tmpPath string) []string {
+	format := "mp4"
+	if strings.EqualFold(filepath.Ext(path), ".mov") {
+		format = "mov"
+	}
+	return []string{
+		"-v", "error", "-y", "-nostdin",
+		"-i", path,
+		"-map", "0", "-c", "copy", "-map_metadata", "0",
+		"-movflags", "+faststart",
+		"-f", format, tmpPath,
+	}
+}
+
+// Faststart remuxes an MP4 with its moov atom ahead of the media data, copying every stream
+// without re-encoding. The file is replaced only once the remux succeeds, keeping its permissions
+// and modification time.
+func Faststart(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".webopt.tmp"
+	cmd := exec.CommandContext(ctx, ToolCommand("ffmpeg"), faststartArgs(path, tmpPath)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg remux failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// atom builds an MP4 atom with a 32-bit size, or the 64-bit form when large is set
+func atom(kind string, payload int, large bool) []byte {
+	var buf bytes.Buffer
+	if large {
+		binary.Write(&buf, binary.BigEndian, uint32(1))
+		buf.WriteString(kind)
+		binary.Write(&buf, binary.BigEndian, uint64(16+payload))
+	} else {
+		binary.Write(&buf, binary.BigEndian, uint32(8+payload))
+		buf.WriteString(kind)
+	}
+	buf.Write(make([]byte, payload))
+	return buf.Bytes()
+}
+
+func TestMoovAfterMdat(t *testing.T) {
+	join := func(atoms ...[]byte) []byte { return bytes.Join(atoms, nil) }
+	tests := []struct {
+		name    string
+		data    []byte
+		atEnd   bool
+		wantErr bool
+	}{
+		{"faststart", join(atom("ftyp", 16, false), atom("moov", 32, false), atom("mdat", 64, false)), false, false},
+		{"moov at end", join(atom("ftyp", 16, false), atom("free", 8, false), atom("mdat", 64, false), atom("moov", 32, false)), true, false},
+		{"64-bit sizes", join(atom("ftyp", 16, false), atom("free", 8, true), atom("mdat", 64, true), atom("moov", 32, false)), true, false},
+		{"no moov", atom("ftyp", 16, false), false, true},
+		{"invalid size", []byte{0, 0, 0, 4, 'f', 't', 'y', 'p'}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atEnd, err := moovAfterMdat(bytes.NewReader(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("moovAfterMdat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if atEnd != tt.atEnd {
+				t.Errorf("moovAfterMdat() = %v, want %v", atEnd, tt.atEnd)
+			}
+		})
+	}
+}
+
+func TestFaststartArgsFormat(t *testing.T) {
+	for path, want := range map[string]string{"movie.mp4": "mp4", "movie.m4v": "mp4", "clip.MOV": "mov"} {
+		args := faststartArgs(path, path+".webopt.tmp")
+		if got := args[len(args)-2]; got != want {
+			t.Errorf("faststartArgs(%q) format = %q, want %q", path, got, want)
+		}
+	}
+}
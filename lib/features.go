@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+// ReportFormats are every report format the report generator supports
+var ReportFormats = []string{ReportFormatCSV, ReportFormatJSON, ReportFormatMarkdown, ReportFormatHTML, ReportFormatSQLite}
+
+// FeatureTools are the external tools whose capabilities are included in a FeatureReport
+var FeatureTools = []string{"ffprobe", "ffmpeg", "HandBrakeCLI"}
+
+// hardwareEncoderMarkers identify hardware-accelerated encoders in HandBrakeCLI and ffmpeg
+// encoder lists, by prefix and suffix respectively
+var hardwareEncoderMarkers = struct{ prefixes, suffixes []string }{
+	prefixes: []string{"vt_", "nvenc_", "qsv_", "vce_"},
+	suffixes: []string{"_videotoolbox", "_nvenc", "_qsv", "_vaapi", "_amf", "_v4l2m2m", "_mf"},
+}
+
+// Feature describes whether an optional integration can be used, and why not if it can't
+type Feature struct {
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"` // What is missing when unavailable
+}
+
+// FeatureReport describes what this build and host support, so orchestration layers and the
+// web UI can adapt without trial invocations. Transcode options are filled in by the caller,
+// since they are defined by the encoding engines.
+type FeatureReport struct {
+	Version          string                       `json:"version"`
+	GoVersion        string                       `json:"go_version"`
+	OS               string                       `json:"os"`
+	Arch             string                       `json:"arch"`
+	ReportFormats    []string                     `json:"report_formats"`
+	CacheBackends    []string                     `json:"cache_backends"`
+	RemoteInputs     []string                     `json:"remote_inputs"` // URL schemes accepted as analyze --input
+	Languages        []string                     `json:"languages"`
+	Engines          []string                     `json:"engines,omitempty"`
+	Containers       []string                     `json:"containers,omitempty"`
+	Verification     []string                     `json:"verification,omitempty"`
+	HardwareEncoders []string                     `json:"hardware_encoders"` // Built into the installed tools; the hardware itself may be absent
+	Integrations     map[string]Feature           `json:"integrations"`
+	Tools            map[string]*ToolCapabilities `json:"tools"` // Installed tools only
+}
+
+// DetectFeatures builds the feature report for this build, the installed tools, and config
+func DetectFeatures(ctx context.Context, config *Config) *FeatureReport {
+	report := &FeatureReport{
+		Version:       "unknown",
+		GoVersion:     runtime.Version(),
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		ReportFormats: ReportFormats,
+		CacheBackends: []string{CacheBackendAuto, CacheBackendJSON, CacheBackendSQLite},
+		Languages:     SupportedLanguages(),
+		Integrations:  make(map[string]Feature),
+	}
+
+	// The SQLite driver needs cgo; without it, it is compiled in as a stub that fails on use
+	cgo := true
+	if info, ok := debug.ReadBuildInfo(); ok {
+		report.Version = info.Main.Version
+		for _, setting := range info.Settings {
+			if setting.Key == "CGO_ENABLED" {
+				cgo = setting.Value == "1"
+			}
+		}
+	}
+	report.Integrations["sqlite"] = feature(cgo, "built without cgo")
+	if !cgo {
+		report.ReportFormats = slices.DeleteFunc(slices.Clone(report.ReportFormats), func(f string) bool { return f == ReportFormatSQLite })
+		report.CacheBackends = []string{CacheBackendAuto, CacheBackendJSON}
+	}
+
+	_, sshErr := exec.LookPath("ssh")
+	report.Integrations["ssh"] = feature(sshErr == nil, "ssh not found in PATH")
+	report.Integrations["s3"] = Feature{Available: true}
+	report.Integrations["email"] = feature(config.SMTP.Host != "", "smtp.host not set in the config file")
+	report.Integrations["report_hooks"] = feature(len(config.Hooks) > 0, "no report_hooks in the config file")
+	report.RemoteInputs = []string{"s3"}
+	if sshErr == nil {
+		report.RemoteInputs = []string{"ssh", "sftp", "s3"}
+	}
+
+	report.Tools = DetectCapabilities(ctx, DefaultCapabilitiesPath(), FeatureTools...).Tools
+	report.HardwareEncoders = []string{}
+	for _, name := range FeatureTools {
+		if tool := report.Tools[name]; tool != nil {
+			report.HardwareEncoders = append(report.HardwareEncoders, hardwareEncoders(tool.Encoders)...)
+		}
+	}
+	slices.Sort(report.HardwareEncoders)
+	report.HardwareEncoders = slices.Compact(report.HardwareEncoders)
+	return report
+}
+
+// feature returns an available feature, or an unavailable one explained by reason
+func feature(available bool, reason string) Feature {
+	if available {
+		return Feature{Available: true}
+	}
+	return Feature{Reason: reason}
+}
+
+// hardwareEncoders filters an encoder list down to the hardware-accelerated encoders
+func hardwareEncoders(encoders []string) []string {
+	var hardware []string
+	for _, encoder := range encoders {
+		if IsHardwareEncoder(encoder) {
+			hardware = append(hardware, encoder)
+		}
+	}
+	return hardware
+}
+
+// IsHardwareEncoder reports whether a HandBrakeCLI or ffmpeg encoder is hardware-accelerated
+func IsHardwareEncoder(encoder string) bool {
+	for _, prefix := range hardwareEncoderMarkers.prefixes {
+		if strings.HasPrefix(encoder, prefix) {
+			return true
+		}
+	}
+	for _, suffix := range hardwareEncoderMarkers.suffixes {
+		if strings.HasSuffix(encoder, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHardwareEncoders(t *testing.T) {
+	encoders := []string{"x265", "vt_h265", "nvenc_h265_10bit", "libx264", "hevc_nvenc", "h264_vaapi", "hevc_videotoolbox", "aac"}
+	got := strings.Join(hardwareEncoders(encoders), ",")
+	if want := "vt_h265,nvenc_h265_10bit,hevc_nvenc,h264_vaapi,hevc_videotoolbox"; got != want {
+		t.Errorf("hardwareEncoders = %s, want %s", got, want)
+	}
+}
+
+func TestDetectFeatures(t *testing.T) {
+	t.Setenv("MEDIA_MGMT_STATE_DIR", t.TempDir())
+	t.Setenv("MEDIA_MGMT_TOOLS_DIR", t.TempDir())
+	t.Setenv("PATH", t.TempDir())
+
+	report := DetectFeatures(context.Background(), &Config{SMTP: SMTPConfig{Host: "smtp.example.com"}})
+	if len(report.Tools) != 0 || len(report.HardwareEncoders) != 0 {
+		t.Errorf("Expected no tools without any installed, got %v and %v", report.Tools, report.HardwareEncoders)
+	}
+	if got := strings.Join(report.RemoteInputs, ","); got != "s3" {
+		t.Errorf("Expected only s3 input without ssh, got %s", got)
+	}
+	if !report.Integrations["email"].Available {
+		t.Error("Expected email to be available with an SMTP host")
+	}
+	if hooks := report.Integrations["report_hooks"]; hooks.Available || hooks.Reason == "" {
+		t.Errorf("Expected report hooks to be unavailable with a reason, got %+v", hooks)
+	}
+}
@@ -1,6 +1,7 @@
 package lib
 
 import (
+	"encoding/json"
 	"fmt"
 	"os/exec"
 	"regexp"
@@ -14,21 +15,30 @@ var (
 
 // VideoInfo contains metadata about a video file extracted from ffprobe.
 type VideoInfo struct {
-	Path     string  // Full path to the video file
-	IsHDR    bool    // Whether the video contains HDR content
-	Width    int     // Video width in pixels
-	Height   int     // Video height in pixels
-	Duration float64 // Duration in seconds
+	Path           string          // Full path to the video file
+	IsHDR          bool            // Whether the video contains HDR content
+	Width          int             // Video width in pixels
+	Height         int             // Video height in pixels
+	Duration       float64         // Duration in seconds
+	FrameRate      float64         // Average frame rate of the primary video stream (0 if unknown)
+	IsVFR          bool            // Whether the primary video stream has a variable frame rate
+	HDR            *HDRInfo        // HDR format and metadata of the primary video stream (nil for SDR)
+	AudioTracks    []AudioTrack    // Audio tracks in order, classified by role
+	SubtitleTracks []SubtitleTrack // Subtitle tracks in order, classified by kind
+	Chapters       []Chapter       // Chapters in order (empty if the file has none)
+	VideoBitrate   int64           // Overall bitrate less the audio tracks', in bits per second (0 if unknown)
+	Year           int             // Release year from the container tags or file name (0 if unknown)
 }
 
 // GetVideoInfo extracts video metadata from a file using ffprobe.
-// Returns VideoInfo with duration and HDR detection, or an error if ffprobe fails.
+// Returns VideoInfo with duration, dimensions, frame rate and HDR detection, or an error if ffprobe fails.
 func GetVideoInfo(filePath string) (*VideoInfo, error) {
-	cmd := exec.Command("ffprobe",
+	cmd := exec.Command(ToolCommand("ffprobe"),
 		"-v", "quiet",
 		"-print_format", "json",
 		"-show_format",
 		"-show_streams",
+		"-show_chapters",
 		filePath)
 
 	output, err := cmd.Output()
@@ -43,11 +53,67 @@ func GetVideoInfo(filePath string) (*VideoInfo, error) {
 		return nil, fmt.Errorf("failed to parse video duration: %w", err)
 	}
 
-	return &VideoInfo{
+	videoInfo := &VideoInfo{
 		Path:     filePath,
 		IsHDR:    isHDR,
 		Duration: duration,
-	}, nil
+	}
+
+	var probe FFProbeOutput
+	if err := json.Unmarshal(output, &probe); err == nil {
+		videoInfo.AudioTracks = parseAudioTracks(probe.Streams)
+		videoInfo.SubtitleTracks = parseSubtitleTracks(probe.Streams)
+		videoInfo.Chapters = parseChapters(probe.Chapters)
+		videoInfo.Year = releaseYear(filePath, probe.Format.Tags)
+		videoInfo.VideoBitrate = videoBitrate(probe.Format.Bitrate, videoInfo.AudioTracks)
+		classification := ClassifyVideoStreams(probe.Streams, duration)
+		if classification.Primary != nil {
+			videoInfo.Width = classification.Primary.Width
+			videoInfo.Height = classification.Primary.Height
+			videoInfo.FrameRate = parseFrameRate(classification.Primary.AvgFrameRate)
+			videoInfo.IsVFR = streamIsVFR(*classification.Primary)
+			videoInfo.HDR = ParseHDRInfo(*classification.Primary)
+			videoInfo.IsHDR = videoInfo.IsHDR || videoInfo.HDR != nil
+		}
+	}
+
+	return videoInfo, nil
+}
+
+// videoBitrate estimates the video's share of a file's overall bitrate. Returns 0 if the
+// overall bitrate is unknown.
+func videoBitrate(formatBitrate string, audioTracks []AudioTrack) int64 {
+	total, err := strconv.ParseInt(formatBitrate, 10, 64)
+	if err != nil {
+		return 0
+	}
+	for _, track := range audioTracks {
+		total -= track.Bitrate
+	}
+	return max(total, 0)
+}
+
+// parseFrameRate converts an ffprobe rational frame rate (e.g. "24000/1001") to frames per second.
+// Returns 0 if the value is missing or malformed.
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	if !found {
+		fps, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return 0
+		}
+		return fps
+	}
+
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0
+	}
+	return n / d
 }
 
 // parseDuration extracts the duration from ffprobe JSON output.
@@ -86,4 +152,4 @@ func DetectHDR(ffprobeOutput string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// PreserveOptions chooses which of a source file's attributes a file made from it inherits
+type PreserveOptions struct {
+	Times bool // Access and modification times
+	Owner bool // Owner, group, permissions, and extended attributes
+}
+
+// CopyFileAttributes gives dst the attributes of src that opts chooses. Every attribute is
+// attempted; the returned error joins those that could not be copied, such as the owner when
+// not running as root.
+func CopyFileAttributes(src, dst string, opts PreserveOptions) error {
+	if !opts.Times && !opts.Owner {
+		return nil
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(src, &stat); err != nil {
+		return fmt.Errorf("failed to read attributes of %s: %w", src, err)
+	}
+
+	var errs []error
+	if opts.Owner {
+		// Owner first, since changing it can clear setuid and setgid bits set by Chmod
+		if err := os.Chown(dst, int(stat.Uid), int(stat.Gid)); err != nil {
+			errs = append(errs, fmt.Errorf("owner: %w", err))
+		}
+		if err := os.Chmod(dst, os.FileMode(stat.Mode).Perm()); err != nil {
+			errs = append(errs, fmt.Errorf("permissions: %w", err))
+		}
+		if err := copyXattrs(src, dst); err != nil {
+			errs = append(errs, fmt.Errorf("extended attributes: %w", err))
+		}
+	}
+	if opts.Times {
+		atime := time.Unix(stat.Atim.Unix())
+		mtime := time.Unix(stat.Mtim.Unix())
+		if err := os.Chtimes(dst, atime, mtime); err != nil {
+			errs = append(errs, fmt.Errorf("times: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// copyXattrs copies src's extended attributes to dst, such as Finder tags and comments on
+// macOS. Attributes dst's filesystem or the user's privileges do not allow are reported.
+func copyXattrs(src, dst string) error {
+	size, err := unix.Listxattr(src, nil)
+	if err != nil || size == 0 {
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+	names := make([]byte, size)
+	if size, err = unix.Listxattr(src, names); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range splitXattrNames(names[:size]) {
+		valueSize, err := unix.Getxattr(src, name, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		value := make([]byte, valueSize)
+		if valueSize, err = unix.Getxattr(src, name, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		if err := unix.Setxattr(dst, name, value[:valueSize], 0); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// splitXattrNames splits the NUL-terminated names Listxattr returns
+func splitXattrNames(buf []byte) []string {
+	var names []string
+	start := 0
+	for i, b := range buf {
+		if b == 0 {
+			if i > start {
+				names = append(names, string(buf[start:i]))
+			}
+			start = i + 1
+		}
+	}
+	return names
+}
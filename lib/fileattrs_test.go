@@ -0,0 +1,87 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCopyFileAttributes(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "movie.mkv")
+	dst := filepath.Join(dir, "movie-optimized.mkv")
+	for _, path := range []string{src, dst} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	added := time.Date(2019, 3, 14, 9, 26, 53, 0, time.UTC)
+	if err := os.Chtimes(src, added.Add(time.Hour), added); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CopyFileAttributes(src, dst, PreserveOptions{Times: true}); err != nil {
+		t.Fatalf("CopyFileAttributes failed: %v", err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(added) {
+		t.Errorf("modification time = %v, want %v", info.ModTime(), added)
+	}
+	if info.Mode().Perm() != 0644 {
+		t.Errorf("permissions = %v, want 0644 without Owner", info.Mode().Perm())
+	}
+
+	// Owner and group are the test's own, so only the permissions visibly change
+	if err := CopyFileAttributes(src, dst, PreserveOptions{Owner: true}); err != nil {
+		t.Fatalf("CopyFileAttributes failed: %v", err)
+	}
+	if info, err = os.Stat(dst); err != nil {
+		t.Fatalf("Failed to stat %s: %v", dst, err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("permissions = %v, want 0640", info.Mode().Perm())
+	}
+}
+
+func TestCopyXattrs(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "movie.mkv")
+	dst := filepath.Join(dir, "movie-optimized.mkv")
+	for _, path := range []string{src, dst} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := unix.Setxattr(src, "user.comment", []byte("director's cut"), 0); err != nil {
+		t.Skipf("Filesystem does not support extended attributes: %v", err)
+	}
+
+	if err := copyXattrs(src, dst); err != nil {
+		t.Fatalf("copyXattrs failed: %v", err)
+	}
+	value := make([]byte, 64)
+	size, err := unix.Getxattr(dst, "user.comment", value)
+	if err != nil || string(value[:size]) != "director's cut" {
+		t.Errorf("user.comment = %q, %v; want \"director's cut\"", value[:max(size, 0)], err)
+	}
+}
+
+func TestSplitXattrNames(t *testing.T) {
+	names := splitXattrNames([]byte("user.comment\x00com.apple.FinderInfo\x00"))
+	if strings.Join(names, ",") != "user.comment,com.apple.FinderInfo" {
+		t.Errorf("splitXattrNames() = %q", names)
+	}
+	if names := splitXattrNames(nil); names != nil {
+		t.Errorf("splitXattrNames(nil) = %q, want nil", names)
+	}
+}
@@ -0,0 +1,419 @@
+package lib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// FilterExpr is a parsed media filter such as "codec = 'h264' AND height >= 1080 AND bitrate > 8M".
+// Conditions compare a field with a value and combine with AND, OR, NOT, and parentheses.
+type FilterExpr interface {
+	Match(info *MediaInfo) bool
+}
+
+// filterFieldKind determines how a field's values are parsed and compared
+type filterFieldKind int
+
+const (
+	filterNumber filterFieldKind = iota
+	filterSize
+	filterDuration
+	filterText
+)
+
+// filterField describes a field that can be referenced in a filter expression
+type filterField struct {
+	kind   filterFieldKind
+	number func(info *MediaInfo) float64
+	text   func(info *MediaInfo) []string // Multi-valued fields match if any value matches
+}
+
+// filterFields lists the fields accepted in filter expressions, named like the /media query fields
+var filterFields = map[string]filterField{
+	"path":         {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.FilePath} }},
+	"codec":        {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.VideoCodec} }},
+	"profile":      {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.VideoProfile} }},
+	"pixel_format": {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.PixelFormat} }},
+	"scan_type":    {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.ScanType} }},
+	"content_type": {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.ContentType} }},
+	"frame_rate_mode": {kind: filterText, text: func(i *MediaInfo) []string {
+		if i.IsVFR {
+			return []string{"vfr"}
+		}
+		return []string{"cfr"}
+	}},
+	"hdr_format": {kind: filterText, text: func(i *MediaInfo) []string {
+		if i.HDR == nil {
+			return []string{"sdr"}
+		}
+		return []string{i.HDR.Format}
+	}},
+	"audio_language": {kind: filterText, text: func(i *MediaInfo) []string {
+		languages := make([]string, len(i.AudioTracks))
+		for n, track := range i.AudioTracks {
+			languages[n] = track.Language
+		}
+		return languages
+	}},
+	"audio_codec": {kind: filterText, text: func(i *MediaInfo) []string {
+		codecs := make([]string, len(i.AudioTracks))
+		for n, track := range i.AudioTracks {
+			codecs[n] = track.Codec
+		}
+		return codecs
+	}},
+	"audio_role": {kind: filterText, text: func(i *MediaInfo) []string {
+		roles := make([]string, len(i.AudioTracks))
+		for n, track := range i.AudioTracks {
+			roles[n] = track.Role
+		}
+		return roles
+	}},
+	"subtitle_language": {kind: filterText, text: func(i *MediaInfo) []string {
+		languages := make([]string, len(i.SubtitleTracks))
+		for n, track := range i.SubtitleTracks {
+			languages[n] = track.Language
+		}
+		return languages
+	}},
+	"subtitle_kind": {kind: filterText, text: func(i *MediaInfo) []string {
+		kinds := make([]string, len(i.SubtitleTracks))
+		for n, track := range i.SubtitleTracks {
+			kinds[n] = track.Kind
+		}
+		return kinds
+	}},
+	"release_group": {kind: filterText, text: func(i *MediaInfo) []string { return []string{i.Container.ReleaseGroupName()} }},
+	"encoder": {kind: filterText, text: func(i *MediaInfo) []string {
+		if i.Container == nil {
+			return []string{""}
+		}
+		return []string{i.Container.Encoder, i.Container.VideoEncoder}
+	}},
+	"size":            {kind: filterSize, number: func(i *MediaInfo) float64 { return float64(i.FileSize) }},
+	"duration":        {kind: filterDuration, number: func(i *MediaInfo) float64 { return i.Duration }},
+	"bitrate":         {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(i.VideoBitrate) }},
+	"width":           {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(i.VideoWidth) }},
+	"height":          {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(i.VideoHeight) }},
+	"frame_rate":      {kind: filterNumber, number: func(i *MediaInfo) float64 { return i.FrameRate }},
+	"bit_depth":       {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(i.BitDepth) }},
+	"audio_tracks":    {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(len(i.AudioTracks)) }},
+	"audio_sidecars":  {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(len(i.AudioSidecars)) }},
+	"subtitle_tracks": {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(len(i.SubtitleTracks)) }},
+	"video_streams":   {kind: filterNumber, number: func(i *MediaInfo) float64 { return float64(len(i.VideoStreams)) }},
+	"extra_video_streams": {kind: filterNumber, number: func(i *MediaInfo) float64 {
+		return float64(len(i.ExtraVideoStreams()))
+	}},
+}
+
+// ParseFilterExpr parses a filter expression.
+// Text values may be quoted or bare and compare case-insensitively; "~" tests for a substring.
+// Numbers accept K/M/G suffixes (binary units for size), and durations accept Go durations like 90m.
+func ParseFilterExpr(input string) (FilterExpr, error) {
+	tokens, err := tokenizeFilter(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty filter expression")
+	}
+
+	p := &filterParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].offset+1)
+	}
+	return expr, nil
+}
+
+type filterTokenKind int
+
+const (
+	tokenWord filterTokenKind = iota
+	tokenString
+	tokenOperator
+	tokenOpenParen
+	tokenCloseParen
+)
+
+type filterToken struct {
+	kind   filterTokenKind
+	text   string
+	offset int
+}
+
+// tokenizeFilter splits a filter expression into words, quoted strings, operators, and parentheses
+func tokenizeFilter(input string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: tokenOpenParen, text: "(", offset: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: tokenCloseParen, text: ")", offset: i})
+			i++
+		case r == '\'' || r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, filterToken{kind: tokenString, text: string(runes[i+1 : end]), offset: i})
+			i = end + 1
+		case strings.ContainsRune("=!<>~", r):
+			op := string(r)
+			if i+1 < len(runes) && runes[i+1] == '=' && r != '=' && r != '~' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected \"!\" at position %d", i+1)
+			}
+			tokens = append(tokens, filterToken{kind: tokenOperator, text: op, offset: i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("()'\"=!<>~", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: tokenWord, text: string(runes[start:i]), offset: start})
+		}
+	}
+	return tokens, nil
+}
+
+// filterParser is a recursive descent parser over filter tokens.
+// Precedence from lowest to highest: OR, AND, NOT.
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenWord && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *filterParser) parseOr() (FilterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (FilterExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseNot() (FilterExpr, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (FilterExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	token := p.tokens[p.pos]
+	if token.kind == tokenOpenParen {
+		p.pos++
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenCloseParen {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", token.offset+1)
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	if token.kind != tokenWord {
+		return nil, fmt.Errorf("expected a field name at position %d, found %q", token.offset+1, token.text)
+	}
+	name := strings.ToLower(token.text)
+	field, ok := filterFields[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", token.text)
+	}
+	p.pos++
+
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != tokenOperator {
+		return nil, fmt.Errorf("expected an operator after %q", token.text)
+	}
+	op := p.tokens[p.pos].text
+	p.pos++
+
+	if p.pos >= len(p.tokens) || (p.tokens[p.pos].kind != tokenWord && p.tokens[p.pos].kind != tokenString) {
+		return nil, fmt.Errorf("expected a value after %s %s", token.text, op)
+	}
+	value := p.tokens[p.pos]
+	p.pos++
+
+	if field.kind == filterText {
+		if op != "=" && op != "!=" && op != "~" {
+			return nil, fmt.Errorf("operator %s is not supported for text field %s", op, name)
+		}
+		return textCondition{field: field, op: op, value: strings.ToLower(value.text)}, nil
+	}
+
+	if op == "~" {
+		return nil, fmt.Errorf("operator ~ is only supported for text fields")
+	}
+	if value.kind == tokenString {
+		return nil, fmt.Errorf("field %s expects a number, found quoted %q", name, value.text)
+	}
+	number, err := parseFilterNumber(field.kind, value.text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+	return numberCondition{field: field, op: op, value: number}, nil
+}
+
+// parseFilterNumber parses a numeric literal for a field of the given kind
+func parseFilterNumber(kind filterFieldKind, literal string) (float64, error) {
+	switch kind {
+	case filterSize:
+		size, err := ParseSize(literal)
+		return float64(size), err
+	case filterDuration:
+		if seconds, err := strconv.ParseFloat(literal, 64); err == nil {
+			return seconds, nil
+		}
+		duration, err := time.ParseDuration(literal)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", literal)
+		}
+		return duration.Seconds(), nil
+	}
+
+	multiplier := 1.0
+	switch strings.ToUpper(literal[len(literal)-1:]) {
+	case "K":
+		multiplier = 1e3
+	case "M":
+		multiplier = 1e6
+	case "G":
+		multiplier = 1e9
+	}
+	if multiplier > 1 {
+		literal = literal[:len(literal)-1]
+	}
+	number, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", literal)
+	}
+	return number * multiplier, nil
+}
+
+type andExpr struct{ left, right FilterExpr }
+
+func (e andExpr) Match(info *MediaInfo) bool { return e.left.Match(info) && e.right.Match(info) }
+
+type orExpr struct{ left, right FilterExpr }
+
+func (e orExpr) Match(info *MediaInfo) bool { return e.left.Match(info) || e.right.Match(info) }
+
+type notExpr struct{ inner FilterExpr }
+
+func (e notExpr) Match(info *MediaInfo) bool { return !e.inner.Match(info) }
+
+// numberCondition compares a numeric field against a constant
+type numberCondition struct {
+	field filterField
+	op    string
+	value float64
+}
+
+func (c numberCondition) Match(info *MediaInfo) bool {
+	actual := c.field.number(info)
+	switch c.op {
+	case "=":
+		return actual == c.value
+	case "!=":
+		return actual != c.value
+	case "<":
+		return actual < c.value
+	case "<=":
+		return actual <= c.value
+	case ">":
+		return actual > c.value
+	case ">=":
+		return actual >= c.value
+	}
+	return false
+}
+
+// textCondition compares a text field case-insensitively; value is already lowercased
+type textCondition struct {
+	field filterField
+	op    string
+	value string
+}
+
+func (c textCondition) Match(info *MediaInfo) bool {
+	matched := false
+	for _, actual := range c.field.text(info) {
+		actual = strings.ToLower(actual)
+		if (c.op == "~" && strings.Contains(actual, c.value)) || (c.op != "~" && actual == c.value) {
+			matched = true
+			break
+		}
+	}
+	if c.op == "!=" {
+		return !matched
+	}
+	return matched
+}
+
+// FilterFieldNames returns the field names accepted in filter expressions
+func FilterFieldNames() []string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
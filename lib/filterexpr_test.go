@@ -0,0 +1,67 @@
+package lib
+
+import "testing"
+
+func TestParseFilterExpr(t *testing.T) {
+	info := &MediaInfo{
+		FilePath:     "/media/Movies/Heat (1995).mkv",
+		FileSize:     9 << 30,
+		Duration:     10200,
+		VideoCodec:   "h264",
+		VideoBitrate: 16000000,
+		VideoWidth:   1920,
+		VideoHeight:  1080,
+		AudioTracks:  []AudioTrack{{Codec: "ac3", Language: "eng"}, {Codec: "aac", Language: "fra"}},
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"codec = 'h264' AND height >= 1080 AND bitrate > 8M", true},
+		{"codec = H264", true},
+		{"codec != h264", false},
+		{"bitrate > 16M", false},
+		{"size > 8G AND size <= 9G", true},
+		{"duration > 2h", true},
+		{"duration < 170m", false},
+		{"path ~ 'heat'", true},
+		{"audio_language = fra", true},
+		{"audio_language != eng", false},
+		{"subtitle_tracks = 0", true},
+		{"codec = hevc OR width = 1920", true},
+		{"NOT (codec = hevc OR width = 1920)", false},
+		{"codec = hevc OR codec = h264 AND height < 720", false},
+		{"(codec = hevc OR codec = h264) AND NOT height < 720", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			filter, err := ParseFilterExpr(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseFilterExpr() error = %v", err)
+			}
+			if got := filter.Match(info); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterExpr_Errors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"resolution = 1080p",
+		"codec > h264",
+		"height >= '1080'",
+		"path ~ 'unterminated",
+		"(codec = h264",
+		"codec = h264 height = 1080",
+		"bitrate ~ 8M",
+		"height >= tall",
+	} {
+		if _, err := ParseFilterExpr(expression); err == nil {
+			t.Errorf("ParseFilterExpr(%q) expected an error", expression)
+		}
+	}
+}
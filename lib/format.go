@@ -1,6 +1,11 @@
 package lib
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // FormatDuration formats a duration in seconds to a human-readable string.
 // Returns format like "2:12:55" for hours:minutes:seconds or "12:55" for minutes:seconds
@@ -15,3 +20,84 @@ func FormatDuration(seconds float64) string {
 	}
 	return fmt.Sprintf("%d:%02d", minutes, secs)
 }
+
+// ParseSize parses a human-readable size like "500M", "1.5G", or "2TB" into bytes.
+// Units are binary (1K = 1024 bytes); a bare number is treated as bytes.
+func ParseSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "IB"), "B")
+
+	multiplier := int64(1)
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// ParseBitrate parses a bitrate like "4000k", "4.5M", or "4500" into kilobits per second.
+// Units are decimal, as bitrates are; a bare number is treated as kbps.
+func ParseBitrate(value string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "BPS"), "B")
+
+	multiplier := 1.0
+	if s != "" {
+		switch s[len(s)-1] {
+		case 'K':
+			s = s[:len(s)-1]
+		case 'M':
+			multiplier = 1000
+			s = s[:len(s)-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	kbps := int(number * multiplier)
+	if err != nil || kbps <= 0 {
+		return 0, fmt.Errorf("invalid bitrate %q: use kbps such as 4000k, or 4M", value)
+	}
+	return kbps, nil
+}
+
+// ParseAge parses a duration that may also be given in days or weeks, such as "7d" or "2w",
+// in addition to Go durations such as "36h"
+func ParseAge(value string) (time.Duration, error) {
+	s := strings.TrimSpace(value)
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit == 0 {
+		age, err := time.ParseDuration(s)
+		if err != nil || age < 0 {
+			return 0, fmt.Errorf("invalid age %q: use a duration such as 36h, 7d, or 2w", value)
+		}
+		return age, nil
+	}
+
+	number, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid age %q: use a duration such as 36h, 7d, or 2w", value)
+	}
+	return time.Duration(number * float64(unit)), nil
+}
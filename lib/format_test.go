@@ -1,6 +1,9 @@
 package lib
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestFormatDuration(t *testing.T) {
 	tests := []struct {
@@ -25,3 +28,90 @@ func TestFormatDuration(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int64
+		wantErr  bool
+	}{
+		{"0", 0, false},
+		{"1024", 1024, false},
+		{"1K", 1024, false},
+		{"500M", 500 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"2TB", 2 << 40, false},
+		{"4gib", 4 << 30, false},
+		{"", 0, true},
+		{"abc", 0, true},
+		{"-1G", 0, true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSize(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.value, result, tt.expected)
+		}
+	}
+}
+
+func TestParseBitrate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+		wantErr  bool
+	}{
+		{"4000k", 4000, false},
+		{"4000", 4000, false},
+		{"4.5M", 4500, false},
+		{"2500kbps", 2500, false},
+		{"8mb", 8000, false},
+		{"0", 0, true},
+		{"", 0, true},
+		{"fast", 0, true},
+		{"-4M", 0, true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseBitrate(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBitrate(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("ParseBitrate(%q) = %d, want %d", tt.value, result, tt.expected)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"36h", 36 * time.Hour, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1.5d", 36 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"0", 0, false},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"week", 0, true},
+	}
+
+	for _, tt := range tests {
+		result, err := ParseAge(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAge(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("ParseAge(%q) = %s, want %s", tt.value, result, tt.expected)
+		}
+	}
+}
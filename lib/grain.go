@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// Thresholds for recognizing grainy film sources
+const (
+	grainyBitsPerPixel = 0.3  // Video bits per pixel per frame of a very high bitrate source, spent mostly on grain
+	grainyMaxYear      = 2005 // Latest release year counted as older film, shot and scanned with visible grain
+)
+
+// yearPattern matches a release year in a file name, such as "Film (1975)" or "Film.1975.1080p"
+var yearPattern = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)[0-9]{2})(?:[^0-9]|$)`)
+
+// releaseYear finds a file's release year in its container tags or, failing that, its name.
+// Returns 0 if neither gives one.
+func releaseYear(path string, tags map[string]string) int {
+	for _, key := range []string{"date", "DATE", "year", "YEAR", "DATE_RELEASED"} {
+		if value := tags[key]; len(value) >= 4 {
+			if year, err := strconv.Atoi(value[:4]); err == nil && year >= 1880 {
+				return year
+			}
+		}
+	}
+	// The last year in the name, since titles such as "2001 A Space Odyssey (1968)" may start with one
+	matches := yearPattern.FindAllStringSubmatch(filepath.Base(path), -1)
+	if len(matches) == 0 {
+		return 0
+	}
+	year, _ := strconv.Atoi(matches[len(matches)-1][1])
+	return year
+}
+
+// GrainAssessment is the evidence for whether a source is grainy film
+type GrainAssessment struct {
+	BitsPerPixel float64 // Video bits per pixel per frame (0 if the bitrate is unknown)
+	Year         int     // Release year (0 if unknown)
+	Film         bool    // Whether the frame rate is a film or PAL rate
+	Grainy       bool    // Whether the source is very high bitrate, older film
+}
+
+// AssessGrain judges whether a source is grainy film, whose grain denoising or grain synthesis
+// can remove before encoding: older film, at a frame rate of 24 or 25 fps, encoded at a very
+// high bitrate for its resolution
+func AssessGrain(info *VideoInfo) GrainAssessment {
+	assessment := GrainAssessment{
+		Year: info.Year,
+		Film: info.FrameRate >= 23.9 && info.FrameRate <= 25.1,
+	}
+	if pixels := float64(info.Width*info.Height) * info.FrameRate; pixels > 0 && info.VideoBitrate > 0 {
+		assessment.BitsPerPixel = float64(info.VideoBitrate) / pixels
+	}
+	assessment.Grainy = assessment.Film && assessment.BitsPerPixel >= grainyBitsPerPixel &&
+		assessment.Year > 0 && assessment.Year <= grainyMaxYear
+	return assessment
+}
@@ -0,0 +1,48 @@
+package lib
+
+import "testing"
+
+func TestReleaseYear(t *testing.T) {
+	tests := []struct {
+		path     string
+		tags     map[string]string
+		expected int
+	}{
+		{"/movies/Chinatown (1974)/Chinatown (1974).mkv", nil, 1974},
+		{"/movies/Heat.1995.1080p.BluRay.mkv", nil, 1995},
+		{"/movies/2001 A Space Odyssey (1968).mkv", nil, 1968},
+		{"/movies/Film.2160p.mkv", nil, 0},
+		{"/movies/film.mkv", map[string]string{"DATE_RELEASED": "1982-06-25"}, 1982},
+		{"/movies/film (2010).mkv", map[string]string{"date": "1959"}, 1959},
+	}
+	for _, tt := range tests {
+		if got := releaseYear(tt.path, tt.tags); got != tt.expected {
+			t.Errorf("releaseYear(%q) = %d, want %d", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestAssessGrain(t *testing.T) {
+	// 1080p at 23.976 fps is about 50 million pixels a second
+	grainy := &VideoInfo{Width: 1920, Height: 1080, FrameRate: 23.976, VideoBitrate: 30_000_000, Year: 1974}
+	tests := []struct {
+		name   string
+		modify func(info VideoInfo) VideoInfo
+		grainy bool
+	}{
+		{"old high bitrate film", func(info VideoInfo) VideoInfo { return info }, true},
+		{"recent film", func(info VideoInfo) VideoInfo { info.Year = 2019; return info }, false},
+		{"unknown year", func(info VideoInfo) VideoInfo { info.Year = 0; return info }, false},
+		{"modest bitrate", func(info VideoInfo) VideoInfo { info.VideoBitrate = 8_000_000; return info }, false},
+		{"video frame rate", func(info VideoInfo) VideoInfo { info.FrameRate = 29.97; return info }, false},
+		{"unknown bitrate", func(info VideoInfo) VideoInfo { info.VideoBitrate = 0; return info }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.modify(*grainy)
+			if got := AssessGrain(&info); got.Grainy != tt.grainy {
+				t.Errorf("AssessGrain() = %+v, want grainy %v", got, tt.grainy)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package handbrake
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+)
+
+// errFileAborted is the cause of a file's context once AbortCurrentFile stops it
+var errFileAborted = errors.New("file aborted by request")
+
+// AbortCurrentFile stops the file being processed, removing its unfinished output, and moves
+// on to the next file of the batch, counting the aborted file as skipped. Returns false if no
+// file is being processed. Safe to call from any goroutine.
+func (t *HandBrakeTranscoder) AbortCurrentFile() bool {
+	t.progressMux.Lock()
+	abort, file := t.batch.abortFile, t.batch.abortPath
+	t.progressMux.Unlock()
+	if abort == nil {
+		return false
+	}
+	slog.Warn("Aborting current file", "file", filepath.Base(file))
+	abort(errFileAborted)
+	return true
+}
+
+// abortableFile returns a context for processing file that AbortCurrentFile cancels, and a
+// function to call once the file is finished with
+func (t *HandBrakeTranscoder) abortableFile(ctx context.Context, file string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t.progressMux.Lock()
+	t.batch.abortFile, t.batch.abortPath = cancel, file
+	t.progressMux.Unlock()
+	return ctx, func() {
+		t.progressMux.Lock()
+		t.batch.abortFile, t.batch.abortPath = nil, ""
+		t.progressMux.Unlock()
+		cancel(nil)
+	}
+}
+
+// fileAborted reports whether ctx was cancelled by AbortCurrentFile, rather than with the batch
+func fileAborted(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errFileAborted)
+}
+
+// removeTitleOutputs removes the outputs of the first count titles of a split file, and the
+// subtitles extracted next to them, once the file is aborted while encoding a later title
+func (t *HandBrakeTranscoder) removeTitleOutputs(prepared *preparedFile, finalOutputPath string, count int) {
+	container := t.containerFor(prepared.videoInfo)
+	for i := 1; i <= count; i++ {
+		outputPath := titleOutputPath(finalOutputPath, i)
+		paths := []string{outputPath}
+		if container == ContainerMP4 && t.extractsSubtitles() {
+			for _, subtitle := range t.extractedSubtitles(outputPath, prepared.videoInfo.SubtitleTracks) {
+				paths = append(paths, subtitle.path)
+			}
+		}
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				slog.Warn("Failed to remove output of aborted file", "file", path, "error", err)
+			} else if err == nil {
+				slog.Info("Removed output of aborted file", "file", path)
+			}
+		}
+	}
+}
+
+// handleFileAborted records a file whose processing was aborted as skipped
+func (t *HandBrakeTranscoder) handleFileAborted(file string, fileNum, totalFiles int) {
+	slog.Info("File aborted, moving to the next file", "file", filepath.Base(file), "stage", StageSkipped)
+	t.setProgressStage(file, fileNum, totalFiles, StageSkipped)
+	t.recordAction(file, lib.HistoryActionSkipped, map[string]string{"reason": "aborted"})
+}
@@ -0,0 +1,76 @@
+package handbrake
+
+import (
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Kinds of files interrupted transcode runs leave behind
+const (
+	ArtifactOutput = "output" // Unfinished encode, such as movie-optimized.mkv.tmp
+	ArtifactWork   = "work"   // Size estimation or sample check encode, or an unfinished sidecar mux
+)
+
+// Artifact is a file an interrupted transcode run left behind
+type Artifact struct {
+	Path    string
+	Kind    string // ArtifactOutput or ArtifactWork
+	Size    int64
+	ModTime time.Time
+}
+
+// ArtifactKind classifies a file name as one a transcode run leaves behind, returning "" for
+// any other file
+func ArtifactKind(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".mkv.tmp") || strings.HasSuffix(name, ".mp4.tmp"):
+		return ArtifactOutput
+	case strings.HasSuffix(name, ".tmp.mux"):
+		return ArtifactWork
+	}
+	if _, ok := workFileSource(name); ok {
+		return ArtifactWork
+	}
+	return ""
+}
+
+// FindArtifacts walks root for files left behind by interrupted transcode runs that have not
+// been modified within minAge, so files a running transcode is writing are left alone.
+// Directories that cannot be read are logged and skipped.
+func FindArtifacts(root string, minAge time.Duration) ([]Artifact, error) {
+	cutoff := time.Now().Add(-minAge)
+	var artifacts []Artifact
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			slog.Warn("Failed to read directory, skipping", "dir", path, "error", err)
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		kind := ArtifactKind(entry.Name())
+		if kind == "" {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		artifacts = append(artifacts, Artifact{Path: path, Kind: kind, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		return artifacts[i].Path < artifacts[j].Path
+	})
+	return artifacts, nil
+}
@@ -0,0 +1,120 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Encoding engines
+const (
+	EngineHandBrake    = "handbrake"    // HandBrakeCLI, with every feature (default)
+	EngineAVFoundation = "avfoundation" // macOS's built-in avconvert, for simple H.265 exports without HandBrake
+)
+
+// avfoundationEncoder identifies AVFoundation encodes in history and predictions
+const avfoundationEncoder = "avfoundation_hevc"
+
+// avfoundationInputs are the containers AVFoundation can read; it cannot open Matroska or AVI
+var avfoundationInputs = map[string]bool{".mp4": true, ".m4v": true, ".mov": true}
+
+// usesAVFoundation reports whether encodes run through avconvert instead of HandBrakeCLI
+func (t *HandBrakeTranscoder) usesAVFoundation() bool {
+	return t.Engine == EngineAVFoundation
+}
+
+// outputExtension is the extension of the requested container. AVFoundation cannot write Matroska.
+func (t *HandBrakeTranscoder) outputExtension() string {
+	if t.usesAVFoundation() || t.Container == ContainerMP4 {
+		return ".mp4"
+	}
+	return ".mkv"
+}
+
+// checkAVConvert verifies that avconvert, which ships with macOS, is available
+func checkAVConvert() error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("the avfoundation engine requires macOS")
+	}
+	if _, err := exec.LookPath("avconvert"); err != nil {
+		return fmt.Errorf("avconvert not found in PATH")
+	}
+	return nil
+}
+
+// checkAVFoundationInput rejects sources AVFoundation cannot open before any work is done on them
+func checkAVFoundationInput(path string) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !avfoundationInputs[ext] {
+		return fmt.Errorf("the avfoundation engine cannot read %s files; use --engine handbrake", ext)
+	}
+	return nil
+}
+
+// avconvertPreset picks the HEVC export preset sized for the source. AVFoundation presets have a
+// fixed quality rather than a quality setting, and scale down but never up.
+func avconvertPreset(videoInfo *lib.VideoInfo) string {
+	if videoInfo.Width > 1920 || videoInfo.Height > 1080 {
+		return "PresetHEVC3840x2160"
+	}
+	return "PresetHEVC1920x1080"
+}
+
+// avconvertArgs builds avconvert arguments exporting inputPath with preset. A positive duration
+// limits the export to that many seconds from start, for size estimation.
+func avconvertArgs(inputPath, outputPath, preset string, start, duration float64) []string {
+	args := []string{"--source", inputPath, "--output", outputPath, "--preset", preset, "--replace"}
+	if duration > 0 {
+		args = append(args, "--start", fmt.Sprintf("%.0f", start), "--duration", fmt.Sprintf("%.0f", duration))
+	}
+	return args
+}
+
+// exportWithAVConvert encodes inputPath to outputPath with avconvert. avconvert picks the file
+// type from the output extension, so outputs such as movie.mp4.tmp are exported under a .mp4
+// name and renamed afterwards.
+func (t *HandBrakeTranscoder) exportWithAVConvert(ctx context.Context, inputPath, outputPath string, videoInfo *lib.VideoInfo, start, duration float64) error {
+	exportPath := outputPath
+	if strings.ToLower(filepath.Ext(outputPath)) != ".mp4" {
+		exportPath = outputPath + ".mp4"
+	}
+
+	args := avconvertArgs(inputPath, exportPath, avconvertPreset(videoInfo), start, duration)
+	slog.Debug("Executing avconvert", "args", strings.Join(args, " "))
+	output, err := combinedOutput(ctx, exec.CommandContext(ctx, "avconvert", args...))
+	if err != nil {
+		os.Remove(exportPath)
+		return fmt.Errorf("avconvert failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if exportPath != outputPath {
+		if err := os.Rename(exportPath, outputPath); err != nil {
+			os.Remove(exportPath)
+			return fmt.Errorf("failed to rename avconvert output: %w", err)
+		}
+	}
+	if duration == 0 {
+		optimizeAVConvertOutput(ctx, outputPath)
+	}
+	return nil
+}
+
+// optimizeAVConvertOutput moves the moov atom of a full export to the front, which avconvert
+// has no option for, when ffmpeg is available to do it
+func optimizeAVConvertOutput(ctx context.Context, path string) {
+	if atEnd, err := lib.MoovAtEnd(path); err != nil || !atEnd {
+		return
+	}
+	if _, err := exec.LookPath(lib.ToolCommand("ffmpeg")); err != nil {
+		slog.Warn("Output is not optimized for streaming; install ffmpeg or run webopt on it", "file", filepath.Base(path))
+		return
+	}
+	if err := lib.Faststart(ctx, path); err != nil {
+		slog.Warn("Failed to optimize output for streaming", "file", filepath.Base(path), "error", err)
+	}
+}
@@ -0,0 +1,155 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultBenchSeconds is the length of the segment bench encodes with each setting
+const DefaultBenchSeconds = 60
+
+// BenchOptions sets the matrix of settings a benchmark encodes
+type BenchOptions struct {
+	Encoders  []string // HandBrake encoders to compare (empty uses the one transcode would select)
+	Qualities []int    // Qualities to compare (empty uses Quality)
+	Seconds   float64  // Length of the segment from the middle of the file (0 uses DefaultBenchSeconds)
+	VMAF      bool     // Score each encode against the source (requires ffmpeg with libvmaf)
+}
+
+// BenchRun is how one encoder and quality did on the benchmark segment
+type BenchRun struct {
+	Encoder       string  `json:"encoder"`
+	Quality       int     `json:"quality"`
+	Seconds       float64 `json:"seconds"`         // Wall time of the encode, including HandBrakeCLI's startup
+	FPS           float64 `json:"fps"`             // Frames encoded per second of wall time (0 if the frame rate is unknown)
+	OutputBytes   int64   `json:"output_bytes"`    // Size of the encoded segment
+	EstimatedSize int64   `json:"estimated_size"`  // Output size projected to the whole file
+	SizeRatio     float64 `json:"size_ratio"`      // EstimatedSize as a fraction of the source's size
+	VMAF          float64 `json:"vmaf,omitempty"`  // Score against the source, with BenchOptions.VMAF
+	Error         string  `json:"error,omitempty"` // Why the encode or scoring failed
+}
+
+// benchPath is the work file of a benchmark encode next to the file, such as
+// movie.mkv.bench-3.mkv
+func benchPath(filePath string, index int, container string) string {
+	return fmt.Sprintf("%s.bench-%d.%s", filePath, index, container)
+}
+
+// Bench encodes the same segment from the middle of a file with every combination of the
+// options' encoders and qualities, with the transcoder's other settings, and reports each
+// encode's speed, size, and optionally VMAF score. A failed encode is reported in its run
+// rather than stopping the benchmark.
+func (t *HandBrakeTranscoder) Bench(ctx context.Context, filePath string, options BenchOptions) ([]BenchRun, error) {
+	if err := t.checkHandBrakeCLI(); err != nil {
+		return nil, fmt.Errorf("HandBrakeCLI not available: %w", err)
+	}
+	tools := []string{"HandBrakeCLI"}
+	if options.VMAF {
+		tools = append(tools, "ffmpeg")
+	}
+	t.capabilities = lib.DetectCapabilities(ctx, lib.DefaultCapabilitiesPath(), tools...)
+	hasVideoToolbox := t.detectVideoToolbox()
+	t.detectHWDecode(hasVideoToolbox)
+	if options.VMAF {
+		if err := t.capabilities.Check(lib.Requirement{Feature: "bench --vmaf", Tool: "ffmpeg", Filters: []string{"libvmaf"}}); err != nil {
+			return nil, err
+		}
+	}
+
+	fileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+	videoInfo, err := lib.GetVideoInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+	if videoInfo.Duration <= 0 {
+		return nil, fmt.Errorf("file has no duration to benchmark")
+	}
+
+	encoders := options.Encoders
+	if len(encoders) == 0 {
+		encoders = []string{t.selectEncoder(videoInfo, hasVideoToolbox)}
+	}
+	for _, encoder := range encoders {
+		if err := t.capabilities.Check(encoderRequirement("bench", encoder)); err != nil {
+			return nil, err
+		}
+	}
+	qualities := options.Qualities
+	if len(qualities) == 0 {
+		qualities = []int{t.Quality}
+	}
+	seconds := options.Seconds
+	if seconds <= 0 {
+		seconds = DefaultBenchSeconds
+	}
+	duration := min(seconds, videoInfo.Duration)
+	start := max(videoInfo.Duration/2-duration/2, 0)
+
+	var runs []BenchRun
+	total := len(encoders) * len(qualities)
+	for _, encoder := range encoders {
+		for _, quality := range qualities {
+			if ctx.Err() != nil {
+				return runs, ctx.Err()
+			}
+			slog.Info("Benchmarking", "run", len(runs)+1, "of", total, "encoder", encoder, "quality", quality)
+			run := t.benchRun(ctx, filePath, benchPath(filePath, len(runs)+1, t.containerFor(videoInfo)), videoInfo, encoder, quality, start, duration, options.VMAF)
+			if run.OutputBytes > 0 {
+				run.EstimatedSize = int64(float64(run.OutputBytes) / duration * videoInfo.Duration)
+				run.SizeRatio = float64(run.EstimatedSize) / float64(fileInfo.Size())
+			}
+			if run.Error != "" && ctx.Err() == nil {
+				slog.Warn("Benchmark encode failed", "encoder", encoder, "quality", quality, "error", run.Error)
+			}
+			runs = append(runs, run)
+		}
+	}
+	return runs, ctx.Err()
+}
+
+// benchRun encodes the benchmark segment with one encoder and quality, removing the encode
+// once it has been measured
+func (t *HandBrakeTranscoder) benchRun(ctx context.Context, filePath, outputPath string, videoInfo *lib.VideoInfo, encoder string, quality int, start, duration float64, vmaf bool) BenchRun {
+	run := BenchRun{Encoder: encoder, Quality: quality}
+	defer removeTestFile(outputPath)
+
+	args := t.segmentArgs(filePath, outputPath, start, duration, videoInfo, encoder, quality)
+	began := time.Now()
+	if err := t.runHandBrakeCLI(withQuietHandBrake(ctx), args); err != nil {
+		run.Error = fmt.Sprintf("HandBrakeCLI failed: %v", err)
+		return run
+	}
+	run.Seconds = time.Since(began).Seconds()
+	if videoInfo.FrameRate > 0 && run.Seconds > 0 {
+		run.FPS = duration * videoInfo.FrameRate / run.Seconds
+	}
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		run.Error = fmt.Sprintf("failed to stat output file: %v", err)
+		return run
+	}
+	run.OutputBytes = info.Size()
+
+	if vmaf {
+		args := withHWAccel(vmafSegmentArgs(filePath, outputPath, videoInfo, start, duration), t.hwAccel)
+		output, err := combinedOutput(ctx, lowPriorityCommand(ctx, lib.ToolCommand("ffmpeg"), args...))
+		if err == nil {
+			run.VMAF, err = parseVMAFScore(string(output))
+		} else {
+			err = fmt.Errorf("VMAF scoring failed: %w: %s", err, firstLines(string(output), 3))
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+	}
+	slog.Debug("Benchmark encode measured", "file", filepath.Base(outputPath), "seconds", run.Seconds, "bytes", run.OutputBytes, "vmaf", run.VMAF)
+	return run
+}
@@ -0,0 +1,377 @@
+package handbrake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"media-mgmt/lib"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Chunked encodes cut the source with ffmpeg and join the encoded chunks with mkvmerge
+var (
+	chunkSplitRequirement = lib.Requirement{Feature: "--chunked-encode", Tool: "ffmpeg"}
+	chunkJoinRequirement  = lib.Requirement{Feature: "--chunked-encode", Tool: "mkvmerge"}
+)
+
+// minChunkSeconds is the shortest chunk worth a HandBrake run of its own
+const minChunkSeconds = 5 * 60
+
+// maxSyncDrift is how far apart, in seconds, the video and audio of a joined output may be at
+// each cut and at the end. Each chunk's audio encoder adds some padding, so a little drift is
+// expected.
+const maxSyncDrift = 0.3
+
+// autocropPattern matches the crop, top/bottom/left/right, that a HandBrake scan picks, such as
+// "+ autocrop: 132/132/0/0"
+var autocropPattern = regexp.MustCompile(`autocrop[:=]\s*(\d+)/(\d+)/(\d+)/(\d+)`)
+
+// maxDurationDrift is how far, in seconds, a joined output's video may end from the source's
+// running time, which catches chunks lost or duplicated by the split or join
+const maxDurationDrift = 2.0
+
+// sourceChunk is one piece of a source cut for a chunked encode
+type sourceChunk struct {
+	source   string  // Stream copy of the piece, cut at keyframes
+	encoded  string  // HandBrake's encode of the piece
+	duration float64 // Planned length in seconds
+}
+
+// chunkCount returns how many chunks to encode a file in, or 0 to encode it whole. Files are
+// chunked when ChunkMinSize is set and they are at least that large, are encoded whole to
+// Matroska by HandBrake, and run long enough for each of ChunkJobs chunks to be worthwhile.
+func (t *HandBrakeTranscoder) chunkCount(prepared *preparedFile, title *lib.TitleRange) int {
+	if t.ChunkMinSize <= 0 || prepared.originalSize < t.ChunkMinSize || title != nil || t.usesAVFoundation() {
+		return 0
+	}
+	file := filepath.Base(prepared.path)
+	switch {
+	case t.containerFor(prepared.videoInfo) != ContainerMKV:
+		slog.Info("Encoding whole: chunks can only be joined into Matroska", "file", file)
+		return 0
+	case len(prepared.subtitles) > 0:
+		slog.Info("Encoding whole: external subtitles cannot be added to chunks", "file", file)
+		return 0
+	}
+	count := min(max(t.ChunkJobs, 2), int(prepared.videoInfo.Duration/minChunkSeconds))
+	if count < 2 {
+		slog.Info("Encoding whole: too short to split into chunks", "file", file, "duration", lib.FormatDuration(prepared.videoInfo.Duration))
+		return 0
+	}
+	return count
+}
+
+// chunkBoundaries returns the times to cut a source into count chunks of about equal length.
+// Each cut moves to the nearest chapter start within a quarter of a chunk, so chunks follow the
+// source's scenes where it has chapters; ffmpeg then moves it to the next keyframe.
+func chunkBoundaries(videoInfo *lib.VideoInfo, count int) []float64 {
+	length := videoInfo.Duration / float64(count)
+	var boundaries []float64
+	for i := 1; i < count; i++ {
+		cut := length * float64(i)
+		best := length / 4
+		for _, chapter := range videoInfo.Chapters {
+			if distance := math.Abs(chapter.Start - length*float64(i)); chapter.Start > 0 && distance <= best {
+				cut, best = chapter.Start, distance
+			}
+		}
+		if len(boundaries) == 0 || cut > boundaries[len(boundaries)-1] {
+			boundaries = append(boundaries, cut)
+		}
+	}
+	return boundaries
+}
+
+// chunkPath is the work file of a chunk next to the input, such as movie.mkv.chunk-002.mkv.
+// Encoded chunks add .encoded before the extension.
+func chunkPath(inputPath string, index int, encoded bool) string {
+	if encoded {
+		return fmt.Sprintf("%s.chunk-%03d.encoded.mkv", inputPath, index)
+	}
+	return fmt.Sprintf("%s.chunk-%03d.mkv", inputPath, index)
+}
+
+// chunkSplitArgs builds the ffmpeg arguments that cut a source at the boundaries, copying every
+// stream Matroska can hold, into chunks numbered from 0 that each start at time zero
+func chunkSplitArgs(inputPath string, boundaries []float64) []string {
+	times := make([]string, len(boundaries))
+	for i, boundary := range boundaries {
+		times[i] = strconv.FormatFloat(boundary, 'f', 3, 64)
+	}
+	// The segment muxer numbers its outputs with a printf pattern
+	pattern := strings.ReplaceAll(inputPath, "%", "%%") + ".chunk-%03d.mkv"
+	return []string{
+		"-v", "error", "-y", "-i", inputPath,
+		"-map", "0", "-map", "-0:d?", "-c", "copy",
+		"-f", "segment", "-segment_times", strings.Join(times, ","), "-segment_format", "matroska",
+		"-reset_timestamps", "1", pattern,
+	}
+}
+
+// chunkJoinArgs builds the mkvmerge arguments that append the encoded chunks into outputPath.
+// The chapters HandBrake gives each chunk are dropped in favor of the source's, taken from
+// sourcePath when it is set, and attachments such as fonts are taken from the first chunk only.
+func chunkJoinArgs(outputPath string, chunks []sourceChunk, sourcePath string) []string {
+	args := []string{"--quiet", "-o", outputPath}
+	for i, chunk := range chunks {
+		if i > 0 {
+			args = append(args, "+", "--no-attachments")
+		}
+		args = append(args, "--no-chapters", chunk.encoded)
+	}
+	if sourcePath != "" {
+		args = append(args, "--no-audio", "--no-video", "--no-subtitles", "--no-buttons", "--no-attachments",
+			"--no-global-tags", "--no-track-tags", sourcePath)
+	}
+	return args
+}
+
+// encodeChunked encodes a file by cutting it into chunks at keyframes, encoding the chunks
+// in parallel with the file's HandBrake settings, joining them into outputPath, and checking
+// that audio and video are still in sync. Chunk work files are removed when it returns.
+func (t *HandBrakeTranscoder) encodeChunked(ctx context.Context, prepared *preparedFile, outputPath string, count int, encoder string) error {
+	inputPath, videoInfo := prepared.path, prepared.videoInfo
+	boundaries := chunkBoundaries(videoInfo, count)
+	cuts := make([]string, len(boundaries))
+	for i, boundary := range boundaries {
+		cuts[i] = lib.FormatDuration(boundary)
+	}
+	slog.Info("Encoding in chunks", "file", filepath.Base(inputPath), "chunks", len(boundaries)+1, "cuts", strings.Join(cuts, ","))
+
+	var chunks []sourceChunk
+	defer func() {
+		for _, chunk := range chunks {
+			removeTestFile(chunk.source)
+			removeTestFile(chunk.encoded)
+		}
+	}()
+	starts := append([]float64{0}, boundaries...)
+	for i, start := range starts {
+		end := videoInfo.Duration
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		chunks = append(chunks, sourceChunk{source: chunkPath(inputPath, i, false), encoded: chunkPath(inputPath, i, true), duration: end - start})
+	}
+
+	splitArgs := chunkSplitArgs(inputPath, boundaries)
+	slog.Debug("Executing ffmpeg", "args", strings.Join(splitArgs, " "))
+	if output, err := combinedOutput(ctx, exec.CommandContext(ctx, lib.ToolCommand("ffmpeg"), splitArgs...)); err != nil {
+		return fmt.Errorf("failed to split source into chunks: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	for _, chunk := range chunks {
+		if _, err := os.Stat(chunk.source); err != nil {
+			return fmt.Errorf("source was not split into %d chunks: %w", len(chunks), err)
+		}
+	}
+
+	if err := t.encodeChunks(ctx, inputPath, videoInfo, chunks, encoder, t.chunkCrop(ctx, inputPath)); err != nil {
+		return err
+	}
+
+	sourcePath := ""
+	if len(videoInfo.Chapters) > 0 {
+		sourcePath = inputPath
+	}
+	joinArgs := chunkJoinArgs(outputPath, chunks, sourcePath)
+	slog.Debug("Executing mkvmerge", "args", strings.Join(joinArgs, " "))
+	output, err := combinedOutput(ctx, exec.CommandContext(ctx, lib.ToolCommand("mkvmerge"), joinArgs...))
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 1:
+		// mkvmerge exits with 1 when it finished with warnings
+		slog.Warn("mkvmerge warned while joining chunks", "file", filepath.Base(inputPath), "output", strings.TrimSpace(string(output)))
+	case err != nil:
+		return fmt.Errorf("failed to join chunks: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return checkChunkedSync(ctx, outputPath, chunks, videoInfo.Duration)
+}
+
+// parseAutocrop returns the --crop value, top:bottom:left:right, of the crop in a HandBrake
+// scan's output
+func parseAutocrop(output []byte) (string, bool) {
+	match := autocropPattern.FindSubmatch(output)
+	if match == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%s:%s:%s:%s", match[1], match[2], match[3], match[4]), true
+}
+
+// chunkCrop scans the whole source for the crop HandBrake picks, so every chunk is cropped the
+// same; left to crop itself, each chunk could come out a different size and fail to join.
+// Returns no crop if the scan does not report one.
+func (t *HandBrakeTranscoder) chunkCrop(ctx context.Context, inputPath string) string {
+	output, err := combinedOutput(ctx, exec.CommandContext(ctx, lib.ToolCommand("HandBrakeCLI"), "-i", inputPath, "--scan"))
+	crop, ok := parseAutocrop(output)
+	if !ok {
+		slog.Warn("HandBrake did not report a crop for the source, so chunks are not cropped", "file", filepath.Base(inputPath), "error", err)
+		return "0:0:0:0"
+	}
+	slog.Info("Cropping every chunk the same", "file", filepath.Base(inputPath), "crop", crop)
+	return crop
+}
+
+// encodeChunks encodes the chunks in parallel with the same crop, updating the file's progress
+// as each finishes. The first failure stops the others.
+func (t *HandBrakeTranscoder) encodeChunks(ctx context.Context, inputPath string, videoInfo *lib.VideoInfo, chunks []sourceChunk, encoder, crop string) error {
+	args := t.transcodeArgs(ctx, inputPath, chunks[0].encoded, videoInfo, nil, nil, encoder)
+	// The crop goes before HandBrakeArgs so a --crop there still wins
+	args = slices.Insert(args, len(args)-len(t.HandBrakeArgs), "--crop", crop)
+	t.logHandBrakeCommand(ctx, args)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	quiet := withQuietHandBrake(ctx)
+
+	var mutex sync.Mutex
+	var firstErr error
+	var encoded float64
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// transcodeArgs starts with -i input -o output
+			chunkArgs := slices.Clone(args)
+			chunkArgs[1], chunkArgs[3] = chunk.source, chunk.encoded
+			err := t.runHandBrakeCLI(quiet, chunkArgs)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to encode chunk %d of %d: %w", i+1, len(chunks), err)
+					cancel()
+				}
+				return
+			}
+			encoded += chunk.duration
+			percent := 100 * encoded / videoInfo.Duration
+			slog.Info("Encoded chunk", "file", filepath.Base(inputPath), "chunk", i+1, "of", len(chunks), "percent", fmt.Sprintf("%.0f", percent))
+			t.updateProgress(fmt.Sprintf("%.2f", percent), "", "", "")
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// streamEndProbe is the part of ffprobe's output checkChunkedSync reads
+type streamEndProbe struct {
+	Streams []struct {
+		Index     int    `json:"index"`
+		CodecType string `json:"codec_type"`
+	} `json:"streams"`
+	Packets []struct {
+		StreamIndex  int    `json:"stream_index"`
+		PTSTime      string `json:"pts_time"`
+		DurationTime string `json:"duration_time"`
+	} `json:"packets"`
+}
+
+// streamEnds finds when the first video and first audio stream end, from ffprobe's listing of
+// the packets near the end of a file. audio is -1 if the file has no audio.
+func streamEnds(data []byte) (video, audio float64, err error) {
+	var probe streamEndProbe
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return 0, 0, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	videoIndex, audioIndex := -1, -1
+	for _, stream := range probe.Streams {
+		switch {
+		case stream.CodecType == "video" && videoIndex < 0:
+			videoIndex = stream.Index
+		case stream.CodecType == "audio" && audioIndex < 0:
+			audioIndex = stream.Index
+		}
+	}
+	video, audio = -1, -1
+	for _, packet := range probe.Packets {
+		pts, err := strconv.ParseFloat(packet.PTSTime, 64)
+		if err != nil {
+			continue
+		}
+		duration, _ := strconv.ParseFloat(packet.DurationTime, 64)
+		switch packet.StreamIndex {
+		case videoIndex:
+			video = max(video, pts+duration)
+		case audioIndex:
+			audio = max(audio, pts+duration)
+		}
+	}
+	if video < 0 {
+		return 0, 0, fmt.Errorf("no video packets found")
+	}
+	return video, audio, nil
+}
+
+// probeStreamEnds finds when a file's video and audio end with ffprobe, reading only the last
+// minute of its expected duration
+func probeStreamEnds(ctx context.Context, path string, duration float64) (video, audio float64, err error) {
+	args := []string{
+		"-v", "error", "-read_intervals", fmt.Sprintf("%.0f%%", max(duration-60, 0)),
+		"-show_entries", "stream=index,codec_type:packet=stream_index,pts_time,duration_time",
+		"-of", "json", path,
+	}
+	output, err := exec.CommandContext(ctx, lib.ToolCommand("ffprobe"), args...).Output()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to probe %s: %w", filepath.Base(path), err)
+	}
+	return streamEnds(output)
+}
+
+// checkCutDrift checks the drift between audio and video at each cut of a joined output, given
+// how far each chunk's audio ends from its video. mkvmerge appends each track where the same track
+// of the chunk before ended, so the drift at a cut is the sum over the chunks before it.
+func checkCutDrift(drifts []float64) error {
+	total := 0.0
+	for i, drift := range drifts {
+		total += drift
+		if math.Abs(total) > maxSyncDrift {
+			return fmt.Errorf("audio is out of sync after joining chunks: it is %.2fs from the video at the cut after chunk %d", total, i+1)
+		}
+	}
+	return nil
+}
+
+// checkChunkedSync checks that a joined output's audio and video stay together at each cut and
+// at the end, and that its video runs as long as the source's
+func checkChunkedSync(ctx context.Context, outputPath string, chunks []sourceChunk, sourceDuration float64) error {
+	var drifts []float64
+	for _, chunk := range chunks[:len(chunks)-1] {
+		video, audio, err := probeStreamEnds(ctx, chunk.encoded, chunk.duration)
+		if err != nil {
+			return fmt.Errorf("failed to check encoded chunk: %w", err)
+		}
+		if audio >= 0 {
+			drifts = append(drifts, audio-video)
+		}
+	}
+	if err := checkCutDrift(drifts); err != nil {
+		return err
+	}
+
+	video, audio, err := probeStreamEnds(ctx, outputPath, sourceDuration)
+	if err != nil {
+		return fmt.Errorf("failed to check joined chunks: %w", err)
+	}
+
+	slog.Debug("Checked chunked encode", "file", filepath.Base(outputPath), "video_end", video, "audio_end", audio, "source_duration", sourceDuration)
+	if math.Abs(video-sourceDuration) > maxDurationDrift {
+		return fmt.Errorf("joined chunks run %s, but the source runs %s", lib.FormatDuration(video), lib.FormatDuration(sourceDuration))
+	}
+	if audio >= 0 && math.Abs(audio-video) > maxSyncDrift {
+		return fmt.Errorf("audio is out of sync after joining chunks: it ends %.2fs from the video", audio-video)
+	}
+	return nil
+}
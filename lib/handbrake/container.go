@@ -0,0 +1,243 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Output containers
+const (
+	ContainerMKV = "mkv" // Matroska, which holds every stream HandBrake can write (default)
+	ContainerMP4 = "mp4" // MP4, for devices and players that cannot read Matroska
+)
+
+// Policies for files whose streams MP4 cannot hold
+const (
+	ContainerFallbackMKV     = "mkv"     // Write those files as Matroska, keeping every stream (default)
+	ContainerFallbackConvert = "convert" // Write MP4 anyway, dropping bitmap subtitles and re-encoding lossless audio to AAC
+	ContainerFallbackExtract = "extract" // As convert, but copy the bitmap subtitles to files next to the output first
+)
+
+// subtitleExtractRequirement is needed to copy bitmap subtitles out of the source
+var subtitleExtractRequirement = lib.Requirement{Feature: "--container-fallback extract", Tool: "ffmpeg"}
+
+// bitmapSubtitleCodecs are image-based subtitle formats. MP4 only carries text subtitles, and
+// HandBrake can convert text formats such as SRT and ASS but not these.
+var bitmapSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+}
+
+// convertsForMP4 reports whether streams MP4 cannot hold are dropped or re-encoded rather than
+// sending the file to Matroska
+func (t *HandBrakeTranscoder) convertsForMP4() bool {
+	return t.Container == ContainerMP4 && (t.ContainerFallback == ContainerFallbackConvert || t.extractsSubtitles())
+}
+
+// extractsSubtitles reports whether bitmap subtitles dropped to fit MP4 are saved as separate files
+func (t *HandBrakeTranscoder) extractsSubtitles() bool {
+	return t.Container == ContainerMP4 && t.ContainerFallback == ContainerFallbackExtract && !t.usesAVFoundation()
+}
+
+// mp4Incompatibilities describes the streams an encode would keep that MP4 cannot hold: bitmap
+// subtitles, and lossless audio that PassthroughLossless would copy
+func (t *HandBrakeTranscoder) mp4Incompatibilities(videoInfo *lib.VideoInfo) []string {
+	var problems []string
+	for _, number := range trackNumbers(t.subtitleSelection(videoInfo.SubtitleTracks, false), len(videoInfo.SubtitleTracks)) {
+		if track := videoInfo.SubtitleTracks[number-1]; bitmapSubtitleCodecs[track.Codec] {
+			problems = append(problems, fmt.Sprintf("subtitle %d is %s", number, track.Codec))
+		}
+	}
+	if t.PassthroughLossless {
+		for i, track := range t.selectedAudioTracks(videoInfo.AudioTracks) {
+			if track.IsLossless() {
+				problems = append(problems, fmt.Sprintf("audio %d is lossless %s", i+1, describeAudioCodec(track)))
+			}
+		}
+	}
+	return problems
+}
+
+// containerFor picks the container a file is written in. MP4 output falls back to Matroska for
+// files with streams MP4 cannot hold, unless ContainerFallback converts them.
+func (t *HandBrakeTranscoder) containerFor(videoInfo *lib.VideoInfo) string {
+	if t.usesAVFoundation() {
+		return ContainerMP4
+	}
+	if t.Container != ContainerMP4 {
+		return ContainerMKV
+	}
+	if t.convertsForMP4() {
+		return ContainerMP4
+	}
+	if len(t.mp4Incompatibilities(videoInfo)) > 0 {
+		return ContainerMKV
+	}
+	return ContainerMP4
+}
+
+// logContainerChoice explains files written in a different container than requested, or
+// converted to fit MP4
+func (t *HandBrakeTranscoder) logContainerChoice(filePath string, videoInfo *lib.VideoInfo, container string) {
+	if t.Container != ContainerMP4 || t.usesAVFoundation() {
+		return
+	}
+	problems := t.mp4Incompatibilities(videoInfo)
+	if len(problems) == 0 {
+		return
+	}
+	if container == ContainerMKV {
+		slog.Warn("Writing Matroska instead of MP4, which cannot hold some streams", "file", filepath.Base(filePath), "streams", strings.Join(problems, "; "))
+		return
+	}
+	slog.Warn("Converting streams MP4 cannot hold", "file", filepath.Base(filePath), "streams", strings.Join(problems, "; "))
+}
+
+// containerFormat is HandBrakeCLI's --format value for a container
+func containerFormat(container string) string {
+	return "av_" + container
+}
+
+// outputPathFor is generateOutputPath for a file written in container
+func (t *HandBrakeTranscoder) outputPathFor(inputPath, container string) string {
+	path := t.generateOutputPath(inputPath)
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + container
+}
+
+// outputCandidates lists the paths a file's output may have: the requested container's, then
+// Matroska's when MP4 output can fall back to it
+func (t *HandBrakeTranscoder) outputCandidates(inputPath string) []string {
+	candidates := []string{t.generateOutputPath(inputPath)}
+	if t.Container == ContainerMP4 && !t.convertsForMP4() && !t.usesAVFoundation() {
+		candidates = append(candidates, t.outputPathFor(inputPath, ContainerMKV))
+	}
+	return candidates
+}
+
+// existingOutput returns the first of a file's output candidates that exists. A split file has
+// no single output; whether every one of its titles was written is only known once the file is
+// probed, and is checked by splitOutput.
+func (t *HandBrakeTranscoder) existingOutput(inputPath string) (string, bool) {
+	for _, path := range t.outputCandidates(inputPath) {
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// splitOutput returns the first title's output of a file split into titles, if the output of
+// every title exists for one of the file's output candidates
+func (t *HandBrakeTranscoder) splitOutput(inputPath string, titles int) (string, bool) {
+	for _, path := range t.outputCandidates(inputPath) {
+		written := true
+		for title := 1; title <= titles && written; title++ {
+			_, err := os.Stat(titleOutputPath(path, title))
+			written = err == nil
+		}
+		if written {
+			return titleOutputPath(path, 1), true
+		}
+	}
+	return "", false
+}
+
+// writtenOutput returns the output a transcoded file has: existingOutput, or the first title's
+// output of a file that was split
+func (t *HandBrakeTranscoder) writtenOutput(inputPath string) (string, bool) {
+	if path, ok := t.existingOutput(inputPath); ok {
+		return path, true
+	}
+	for _, path := range t.outputCandidates(inputPath) {
+		if _, err := os.Stat(titleOutputPath(path, 1)); err == nil {
+			return titleOutputPath(path, 1), true
+		}
+	}
+	return "", false
+}
+
+// extractedSubtitle is a bitmap subtitle track saved next to an MP4 output
+type extractedSubtitle struct {
+	track lib.SubtitleTrack
+	path  string
+}
+
+// extractedSubtitles plans the files for the bitmap subtitles SubtitlePolicy keeps, named after
+// the output with the track's language, such as "Movie.en.forced.sup". PGS is saved as .sup;
+// DVD and DVB subtitles, which have no standalone format, as Matroska subtitle files.
+func (t *HandBrakeTranscoder) extractedSubtitles(outputPath string, tracks []lib.SubtitleTrack) []extractedSubtitle {
+	stem := strings.TrimSuffix(outputPath, filepath.Ext(outputPath))
+	used := make(map[string]bool)
+	var extracted []extractedSubtitle
+	for _, number := range trackNumbers(t.subtitleSelection(tracks, false), len(tracks)) {
+		track := tracks[number-1]
+		if !bitmapSubtitleCodecs[track.Codec] {
+			continue
+		}
+		name := "und"
+		if !lib.IsUndeterminedLanguage(track.Language) {
+			name = lib.NormalizeLanguage(track.Language)
+		}
+		if track.Kind == lib.SubtitleKindForced {
+			name += ".forced"
+		}
+		if used[name] {
+			name += fmt.Sprintf(".%d", number)
+		}
+		used[name] = true
+
+		ext := ".sup"
+		if track.Codec != "hdmv_pgs_subtitle" {
+			ext = ".mks"
+		}
+		extracted = append(extracted, extractedSubtitle{track: track, path: stem + "." + name + ext})
+	}
+	return extracted
+}
+
+// subtitleExtractArgs builds the ffmpeg arguments that copy a subtitle track to its own file,
+// limited to a title's time range when the source is split
+func subtitleExtractArgs(inputPath string, subtitle extractedSubtitle, title *lib.TitleRange) []string {
+	args := []string{"-v", "error", "-y"}
+	if title != nil {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", title.Start), "-t", fmt.Sprintf("%.3f", title.End-title.Start))
+	}
+	format := "sup"
+	if filepath.Ext(subtitle.path) == ".mks" {
+		format = "matroska"
+	}
+	return append(args, "-i", inputPath, "-map", fmt.Sprintf("0:%d", subtitle.track.Index), "-c", "copy", "-f", format, subtitle.path)
+}
+
+// removeExtractedSubtitles removes subtitle files saved for an output that was not kept
+func removeExtractedSubtitles(extracted []extractedSubtitle) {
+	for _, subtitle := range extracted {
+		if err := os.Remove(subtitle.path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove extracted subtitle", "file", subtitle.path, "error", err)
+		}
+	}
+}
+
+// extractSubtitles saves the bitmap subtitles an MP4 output cannot hold next to it. Files
+// already written are removed if one fails.
+func (t *HandBrakeTranscoder) extractSubtitles(ctx context.Context, inputPath, outputPath string, tracks []lib.SubtitleTrack, title *lib.TitleRange) error {
+	extracted := t.extractedSubtitles(outputPath, tracks)
+	for i, subtitle := range extracted {
+		args := t.withFFmpegArgs(subtitleExtractArgs(inputPath, subtitle, title))
+		t.logFFmpegCommand(ctx, args)
+		output, err := combinedOutput(ctx, exec.CommandContext(ctx, lib.ToolCommand("ffmpeg"), args...))
+		if err != nil {
+			removeExtractedSubtitles(extracted[:i+1])
+			return fmt.Errorf("ffmpeg failed on subtitle track %d: %w: %s", subtitle.track.Index, err, strings.TrimSpace(string(output)))
+		}
+		slog.Info("Extracted bitmap subtitle", "file", filepath.Base(subtitle.path), "codec", subtitle.track.Codec)
+	}
+	return nil
+}
@@ -0,0 +1,363 @@
+package handbrake
+
+import (
+	"bytes"
+	"fmt"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// Dashboard escape sequences
+const (
+	enterAltScreen = "\x1b[?1049h\x1b[?25l" // Switch to the alternate screen and hide the cursor
+	leaveAltScreen = "\x1b[?25h\x1b[?1049l" // Show the cursor and return to the main screen
+	cursorHome     = "\x1b[H"
+	clearLine      = "\x1b[K"
+	clearBelow     = "\x1b[J"
+)
+
+// dashboardRefresh is how often the dashboard is redrawn
+const dashboardRefresh = 250 * time.Millisecond
+
+// dashboardLogLines is how many recent log lines the dashboard keeps
+const dashboardLogLines = 100
+
+// keyPollInterval is how long readKeys waits for a key press before checking whether the
+// dashboard has stopped
+const keyPollInterval = 100 * time.Millisecond
+
+// Keys the dashboard acts on
+const (
+	keyAbortFile = 'a'
+	keyCtrlC     = 0x03 // Read as a key while the terminal is in raw mode, rather than raising SIGINT
+)
+
+// Dashboard is a full-screen terminal view of a batch: the queue, the current file's progress,
+// recent log lines, and the space saved so far. It replaces the progress bar the transcoder
+// would otherwise draw, and is an io.Writer so a log handler can write its lines to it.
+type Dashboard struct {
+	transcoder *HandBrakeTranscoder
+	out        *os.File
+	mutex      sync.Mutex
+	logs       []string // Most recent log lines, oldest first
+	partial    []byte   // Log output not yet ended by a newline
+	started    bool
+	stop       chan struct{}
+	done       chan struct{}
+	in         *os.File      // Terminal key presses are read from (nil ignores keys)
+	inState    *term.State   // in's mode before it was made raw (nil when keys are not read)
+	keysDone   chan struct{} // Closed once readKeys has stopped reading from in
+}
+
+// NewDashboard creates a dashboard for the transcoder's batch, drawn on out. The transcoder's
+// own progress bar is turned off.
+func NewDashboard(t *HandBrakeTranscoder, out *os.File) *Dashboard {
+	t.HideProgressBar = true
+	return &Dashboard{transcoder: t, out: out}
+}
+
+// Start switches the terminal to the dashboard. Calls after the first do nothing.
+func (d *Dashboard) Start() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.started {
+		return
+	}
+	d.started = true
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+	if d.in != nil && term.IsTerminal(int(d.in.Fd())) {
+		if state, err := term.MakeRaw(int(d.in.Fd())); err == nil {
+			d.inState = state
+			d.keysDone = make(chan struct{})
+			go d.readKeys()
+		}
+	}
+	fmt.Fprint(d.out, enterAltScreen)
+	go d.run()
+}
+
+// ReadKeys has the dashboard act on key presses read from in, a terminal, while it runs: a
+// aborts the file being processed, and Ctrl-C interrupts the batch as it would without the
+// dashboard. Must be called before Start.
+func (d *Dashboard) ReadKeys(in *os.File) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.in = in
+}
+
+// readKeys acts on key presses until the dashboard stops. It only reads once a key is waiting,
+// so it is not left blocked reading in after Stop, where it would swallow the next key typed at
+// whatever reads the terminal after the dashboard.
+func (d *Dashboard) readKeys() {
+	defer close(d.keysDone)
+	fds := []unix.PollFd{{Fd: int32(d.in.Fd()), Events: unix.POLLIN}}
+	key := make([]byte, 1)
+	for {
+		select {
+		case <-d.stop:
+			return
+		default:
+		}
+		ready, err := unix.Poll(fds, int(keyPollInterval.Milliseconds()))
+		if err == unix.EINTR || err == nil && ready == 0 {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		if _, err := d.in.Read(key); err != nil {
+			return
+		}
+		switch key[0] {
+		case keyAbortFile:
+			d.transcoder.AbortCurrentFile()
+		case keyCtrlC:
+			syscall.Kill(os.Getpid(), syscall.SIGINT)
+		}
+	}
+}
+
+// Stop draws the dashboard a final time and restores the terminal. Safe to call if the
+// dashboard never started.
+func (d *Dashboard) Stop() {
+	d.mutex.Lock()
+	started := d.started
+	d.mutex.Unlock()
+	if !started {
+		return
+	}
+	close(d.stop)
+	<-d.done
+	if d.inState != nil {
+		<-d.keysDone
+		term.Restore(int(d.in.Fd()), d.inState)
+	}
+	fmt.Fprint(d.out, leaveAltScreen)
+}
+
+// Write adds log output to the recent log lines
+func (d *Dashboard) Write(p []byte) (int, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.partial = append(d.partial, p...)
+	for {
+		end := bytes.IndexByte(d.partial, '\n')
+		if end < 0 {
+			break
+		}
+		d.logs = append(d.logs, time.Now().Format(time.TimeOnly)+" "+string(d.partial[:end]))
+		d.partial = d.partial[end+1:]
+	}
+	if len(d.logs) > dashboardLogLines {
+		d.logs = d.logs[len(d.logs)-dashboardLogLines:]
+	}
+	return len(p), nil
+}
+
+// run redraws the dashboard until Stop is called
+func (d *Dashboard) run() {
+	defer close(d.done)
+	ticker := time.NewTicker(dashboardRefresh)
+	defer ticker.Stop()
+	for {
+		d.draw()
+		select {
+		case <-d.stop:
+			d.draw()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// draw renders the dashboard to fit the terminal and writes it in one go to avoid flicker
+func (d *Dashboard) draw() {
+	width, height, err := term.GetSize(int(d.out.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for _, line := range d.render(width, height) {
+		b.WriteString(line + clearLine + "\r\n")
+	}
+	b.WriteString(clearBelow)
+	fmt.Fprint(d.out, b.String())
+}
+
+// queueEntry is a file of the batch as shown in the dashboard's queue
+type queueEntry struct {
+	file  string
+	stage string // A Stage value, or "" for files not started
+	lib.FileOutcome
+}
+
+// queue returns the batch's files with their stages, and the index of the current file
+func (d *Dashboard) queue() ([]queueEntry, int) {
+	t := d.transcoder
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+
+	outcomes := make(map[string]lib.FileOutcome, len(t.result.Files))
+	for _, outcome := range t.result.Files {
+		outcomes[outcome.File] = outcome
+	}
+	entries := make([]queueEntry, len(t.batch.files))
+	current := -1
+	for i, file := range t.batch.files {
+		entries[i] = queueEntry{file: file}
+		if outcome, ok := outcomes[file]; ok {
+			entries[i].stage = outcome.Outcome
+			entries[i].FileOutcome = outcome
+		} else if file == t.progress.File {
+			entries[i].stage = t.progress.Stage
+			current = i
+		}
+	}
+	return entries, current
+}
+
+// render lays out the dashboard as lines of at most width characters, filling height lines
+func (d *Dashboard) render(width, height int) []string {
+	status := d.transcoder.Status()
+	result := d.transcoder.Result()
+	entries, current := d.queue()
+	d.mutex.Lock()
+	readingKeys := d.inState != nil
+	d.mutex.Unlock()
+
+	var lines []string
+	add := func(format string, args ...any) {
+		lines = append(lines, truncateLine(fmt.Sprintf(format, args...), width))
+	}
+
+	header := fmt.Sprintf("media-mgmt transcode  %d/%d files", status.FilesDone, status.FilesTotal)
+	if !status.StartedAt.IsZero() {
+		header += "  elapsed " + lib.FormatDuration(time.Since(status.StartedAt).Seconds())
+	}
+	if status.ETASeconds > 0 {
+		header += "  batch ETA " + lib.FormatDuration(status.ETASeconds)
+	}
+	add("%s", header)
+	saved := "nothing yet"
+	if result.OriginalBytes > 0 {
+		savedBytes := result.OriginalBytes - result.OutputBytes
+		saved = fmt.Sprintf("%s of %s (%.1f%%)", lib.FormatSize(savedBytes), lib.FormatSize(result.OriginalBytes),
+			float64(savedBytes)/float64(result.OriginalBytes)*100)
+	}
+	add("Saved %s   transcoded %d  skipped %d  failed %d", saved, result.Transcoded, result.Skipped, result.Failed)
+	add("")
+
+	if progress := status.Current; progress != nil {
+		add("%s  (%s)", filepath.Base(progress.File), progress.Stage)
+		detail := fmt.Sprintf(" %5.1f%%", progress.Percent)
+		if progress.FPS > 0 {
+			detail += fmt.Sprintf("  %.1f fps", progress.FPS)
+		}
+		if progress.ETA != "" {
+			detail += "  ETA " + progress.ETA
+		}
+		add("%s%s", progressBar(progress.Percent, width-utf8.RuneCountInString(detail)), detail)
+	} else if status.State != BatchRunning {
+		add("Batch %s", status.State)
+		add("")
+	} else {
+		add("%s", "Waiting for the next file")
+		add("")
+	}
+	add("")
+
+	// The queue and the log share the rest of the screen, the queue getting up to half, below
+	// their headings and the keys line
+	reserved := 2
+	if readingKeys {
+		reserved++
+	}
+	logRows := max(height-len(lines)-reserved, 0) / 2
+	queueRows := max(height-len(lines)-logRows-reserved, 0)
+	add("Queue")
+	first := 0
+	if current >= 0 && len(entries) > queueRows {
+		first = min(max(current-queueRows/3, 0), len(entries)-queueRows)
+	}
+	for i := first; i < len(entries) && i < first+queueRows; i++ {
+		add("  %s", queueLine(entries[i]))
+	}
+	for i := len(entries) - first; i < queueRows; i++ {
+		add("")
+	}
+
+	add("Recent log")
+	d.mutex.Lock()
+	logs := d.logs[max(len(d.logs)-logRows, 0):]
+	for _, line := range logs {
+		add("  %s", line)
+	}
+	d.mutex.Unlock()
+	if readingKeys {
+		for len(lines) < height-1 {
+			add("")
+		}
+		add("%s", "a: abort the current file and move on   Ctrl-C: stop the batch")
+	}
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+// queueLine describes a file in the queue
+func queueLine(entry queueEntry) string {
+	name := filepath.Base(entry.file)
+	switch entry.stage {
+	case "":
+		return "  " + name
+	case StageDone:
+		line := "✓ " + name + "  done"
+		if entry.InputBytes > 0 && entry.OutputBytes > 0 {
+			line += fmt.Sprintf("  %s → %s", lib.FormatSize(entry.InputBytes), lib.FormatSize(entry.OutputBytes))
+		}
+		if entry.DurationSeconds > 0 {
+			line += "  " + lib.FormatDuration(entry.DurationSeconds)
+		}
+		return line
+	case StageSkipped:
+		return "- " + name + "  skipped"
+	case StageFailed:
+		return "✗ " + name + "  failed"
+	}
+	return "▶ " + name + "  " + entry.stage
+}
+
+// progressBar draws a bar of width cells, including its brackets, filled to percent
+func progressBar(percent float64, width int) string {
+	inner := width - 2
+	if inner < 10 {
+		return ""
+	}
+	filled := min(int(percent/100*float64(inner)), inner)
+	return "[" + strings.Repeat("█", filled) + strings.Repeat(" ", inner-filled) + "]"
+}
+
+// truncateLine shortens a line to width characters
+func truncateLine(line string, width int) string {
+	if utf8.RuneCountInString(line) <= width {
+		return line
+	}
+	runes := []rune(line)
+	if width <= 1 {
+		return string(runes[:max(width, 0)])
+	}
+	return string(runes[:width-1]) + "…"
+}
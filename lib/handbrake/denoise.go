@@ -0,0 +1,77 @@
+package handbrake
+
+import (
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"path/filepath"
+)
+
+// Denoise filters
+const (
+	DenoiseOff     = "off"     // Leave grain and noise in (default)
+	DenoiseNLMeans = "nlmeans" // HandBrake's NLMeans, slow but keeps fine detail
+	DenoiseHQDN3D  = "hqdn3d"  // HandBrake's hqdn3d, fast but softer
+)
+
+// defaultDenoiseStrength is used when DenoiseStrength is empty
+const defaultDenoiseStrength = "medium"
+
+// synthesizesGrain reports whether a file is encoded with SVT-AV1, which removes its grain and
+// signals synthetic grain of level FilmGrain for the player to add back
+func (t *HandBrakeTranscoder) synthesizesGrain(videoInfo *lib.VideoInfo) bool {
+	return t.FilmGrain > 0 && !t.usesAVFoundation() && lib.AssessGrain(videoInfo).Grainy
+}
+
+// filmGrainEncoder is the SVT-AV1 encoder used for grain synthesis
+func filmGrainEncoder(videoInfo *lib.VideoInfo) string {
+	if videoInfo.IsHDR {
+		return "svt_av1_10bit"
+	}
+	return "svt_av1"
+}
+
+// filmGrainArgs returns the SVT-AV1 options for grain synthesis, or nil if a file does not get it
+func (t *HandBrakeTranscoder) filmGrainArgs(videoInfo *lib.VideoInfo) []string {
+	if !t.synthesizesGrain(videoInfo) {
+		return nil
+	}
+	return []string{"--encopts", fmt.Sprintf("film-grain=%d:film-grain-denoise=1", t.FilmGrain)}
+}
+
+// denoiseArgs returns the HandBrakeCLI denoise filter for a file: grainy sources get Denoise
+// unless grain synthesis already removes their grain, and with DenoiseAll every file does.
+// NLMeans is tuned for film, which suits grain.
+func (t *HandBrakeTranscoder) denoiseArgs(videoInfo *lib.VideoInfo) []string {
+	if t.Denoise == "" || t.Denoise == DenoiseOff || t.synthesizesGrain(videoInfo) {
+		return nil
+	}
+	if !t.DenoiseAll && !lib.AssessGrain(videoInfo).Grainy {
+		return nil
+	}
+	strength := t.DenoiseStrength
+	if strength == "" {
+		strength = defaultDenoiseStrength
+	}
+	args := []string{"--" + t.Denoise + "=" + strength}
+	if t.Denoise == DenoiseNLMeans {
+		args = append(args, "--nlmeans-tune", "film")
+	}
+	return args
+}
+
+// logGrain explains how a file's grain is handled when denoising or grain synthesis is enabled
+func (t *HandBrakeTranscoder) logGrain(videoInfo *lib.VideoInfo) {
+	if (t.Denoise == "" || t.Denoise == DenoiseOff) && t.FilmGrain == 0 {
+		return
+	}
+	grain := lib.AssessGrain(videoInfo)
+	slog.Debug("Grain assessment", "file", filepath.Base(videoInfo.Path), "bits_per_pixel", fmt.Sprintf("%.3f", grain.BitsPerPixel),
+		"year", grain.Year, "film", grain.Film, "grainy", grain.Grainy)
+	switch {
+	case t.synthesizesGrain(videoInfo):
+		slog.Info("Synthesizing film grain", "file", filepath.Base(videoInfo.Path), "encoder", filmGrainEncoder(videoInfo), "level", t.FilmGrain)
+	case t.denoiseArgs(videoInfo) != nil:
+		slog.Info("Denoising", "file", filepath.Base(videoInfo.Path), "filter", t.Denoise, "grainy", grain.Grainy)
+	}
+}
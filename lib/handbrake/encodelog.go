@@ -0,0 +1,150 @@
+package handbrake
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// encodeLogTimeFormat stamps encode log names, so each run's log of a file is kept
+const encodeLogTimeFormat = "20060102-150405"
+
+// encodeLog saves the complete output of every tool run for one file in a gzip-compressed log
+// in EncodeLogDir. Each run is appended as its own gzip member once it ends, so runs in
+// parallel do not interleave and the file is only created when a tool runs; zcat and gunzip
+// read the members as one log.
+type encodeLog struct {
+	path  string
+	mutex sync.Mutex
+}
+
+// encodeLogKey carries a file's encode log in the contexts of its tool runs
+type encodeLogKey struct{}
+
+// withEncodeLog returns a context under which tool runs are saved to log. A nil log leaves
+// ctx unchanged.
+func withEncodeLog(ctx context.Context, log *encodeLog) context.Context {
+	if log == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, encodeLogKey{}, log)
+}
+
+// encodeLogFrom returns the encode log carried by ctx, or nil if there is none
+func encodeLogFrom(ctx context.Context) *encodeLog {
+	log, _ := ctx.Value(encodeLogKey{}).(*encodeLog)
+	return log
+}
+
+// newEncodeLog returns the encode log of a file being prepared now, or nil without EncodeLogDir
+func (t *HandBrakeTranscoder) newEncodeLog(filePath string) *encodeLog {
+	if t.EncodeLogDir == "" {
+		return nil
+	}
+	return &encodeLog{path: encodeLogPath(t.EncodeLogDir, filePath, time.Now())}
+}
+
+// encodeLogPath names a file's encode log after the file and when it was prepared, such as
+// logs/Movie.mkv.20261016-111250.log.gz
+func encodeLogPath(dir, filePath string, now time.Time) string {
+	return filepath.Join(dir, filepath.Base(filePath)+"."+now.Format(encodeLogTimeFormat)+".log.gz")
+}
+
+// encodeLogRun collects the output of one tool run until it ends
+type encodeLogRun struct {
+	log     *encodeLog
+	args    []string
+	started time.Time
+	mutex   sync.Mutex
+	output  bytes.Buffer
+}
+
+// start begins saving a tool run with command line args. Safe to call on a nil log.
+func (l *encodeLog) start(args []string) *encodeLogRun {
+	if l == nil {
+		return nil
+	}
+	return &encodeLogRun{log: l, args: args, started: time.Now()}
+}
+
+// Write adds output of the run
+func (r *encodeLogRun) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.output.Write(p)
+}
+
+// writer returns where the run's output should be copied: the run, or io.Discard for a nil run
+func (r *encodeLogRun) writer() io.Writer {
+	if r == nil {
+		return io.Discard
+	}
+	return r
+}
+
+// finish appends the run, with how it ended, to the log. Failures to write the log are logged
+// rather than failing the file. Safe to call on a nil run.
+func (r *encodeLogRun) finish(err error) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	var entry bytes.Buffer
+	fmt.Fprintf(&entry, "=== %s %s\n", r.started.Format(time.DateTime), strings.Join(r.args, " "))
+	entry.Write(r.output.Bytes())
+	if r.output.Len() > 0 && !bytes.HasSuffix(r.output.Bytes(), []byte("\n")) {
+		entry.WriteByte('\n')
+	}
+	r.mutex.Unlock()
+	result := "ok"
+	if err != nil {
+		result = err.Error()
+	}
+	fmt.Fprintf(&entry, "=== exit: %s after %s\n\n", result, time.Since(r.started).Round(time.Millisecond))
+
+	if err := r.log.append(entry.Bytes()); err != nil {
+		slog.Warn("Failed to write encode log", "path", r.log.path, "error", err)
+	}
+}
+
+// append writes data to the log as a new gzip member, creating the log and its directory if needed
+func (l *encodeLog) append(data []byte) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(file)
+	if _, err := writer.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// combinedOutput runs cmd and returns its combined stdout and stderr, as cmd.CombinedOutput
+// does, saving the run to the encode log carried by ctx
+func combinedOutput(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	run := encodeLogFrom(ctx).start(cmd.Args)
+	output, err := cmd.CombinedOutput()
+	run.writer().Write(output)
+	run.finish(err)
+	return output, err
+}
@@ -2,63 +2,259 @@ package handbrake
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
+	"math"
 	"media-mgmt/lib"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 // selectEncoder chooses the appropriate HandBrake encoder based on video characteristics and hardware support.
 // Uses VideoToolbox hardware encoders on macOS when available, falls back to software encoders.
-// Selects 10-bit encoders for HDR content, 8-bit for SDR content.
+// Selects 10-bit encoders for HDR content, 8-bit for SDR content. Sources with Dolby Vision or
+// HDR10+ dynamic metadata use x265, since VideoToolbox encoders cannot carry it through.
+// In low-power mode, Intel Quick Sync is used instead when HandBrakeCLI supports it.
 func (t *HandBrakeTranscoder) selectEncoder(videoInfo *lib.VideoInfo, hasVideoToolbox bool) string {
-	if hasVideoToolbox {
+	if t.usesAVFoundation() {
+		return avfoundationEncoder
+	}
+	if t.synthesizesGrain(videoInfo) {
+		return filmGrainEncoder(videoInfo)
+	}
+	if t.lowPowerQSV && !videoInfo.HDR.HasDynamicMetadata() {
+		if videoInfo.IsHDR {
+			return "qsv_h265_10bit"
+		}
+		return "qsv_h265"
+	}
+	if hasVideoToolbox && !videoInfo.HDR.HasDynamicMetadata() {
 		if videoInfo.IsHDR {
 			return "vt_h265_10bit"
 		} else {
 			return "vt_h265"
 		}
 	} else {
-		if videoInfo.IsHDR {
-			return "x265_10bit"
-		} else {
-			return "x265"
+		return softwareEncoder(videoInfo)
+	}
+}
+
+// softwareEncoder returns the x265 encoder for a source, used without hardware encoding and
+// when a hardware encode falls back to software
+func softwareEncoder(videoInfo *lib.VideoInfo) string {
+	if videoInfo.IsHDR {
+		return "x265_10bit"
+	}
+	return "x265"
+}
+
+// dynamicMetadataEncoders are the HandBrake encoders that can pass through HDR dynamic metadata
+var dynamicMetadataEncoders = map[string]bool{"x265_10bit": true, "x265_12bit": true, "svt_av1_10bit": true}
+
+// dynamicMetadataRequirement is the HandBrakeCLI version that added --hdr-dynamic-metadata
+var dynamicMetadataRequirement = lib.Requirement{Feature: "HDR dynamic metadata passthrough", Tool: "HandBrakeCLI", MinVersion: "1.7"}
+
+// hdrMetadataArgs returns the HandBrakeCLI arguments that carry a source's Dolby Vision or HDR10+
+// metadata into the output, or nil if the source has none or the encoder cannot write it
+func hdrMetadataArgs(hdr *lib.HDRInfo, encoder string) []string {
+	if !hdr.HasDynamicMetadata() || !dynamicMetadataEncoders[encoder] {
+		return nil
+	}
+	if hdr.Format == lib.HDRFormatDolbyVision {
+		return []string{"--hdr-dynamic-metadata", "dolbyvision"}
+	}
+	return []string{"--hdr-dynamic-metadata", "hdr10plus"}
+}
+
+// audioArgs selects the audio tracks to keep: all of them, or with DropCommentary every track not
+// classified as commentary, and with KeepAudioLangs only tracks in those languages or without a
+// language tag. The first track is always kept with KeepAudioLangs, so a release in none of the
+// languages keeps its original audio. HandBrake numbers audio tracks from 1 in stream order.
+func (t *HandBrakeTranscoder) audioArgs(tracks []lib.AudioTrack) []string {
+	if !t.DropCommentary && len(t.KeepAudioLangs) == 0 {
+		return []string{"--all-audio"}
+	}
+
+	var keep []string
+	for i, track := range tracks {
+		if t.DropCommentary && track.Role == lib.AudioRoleCommentary {
+			continue
+		}
+		if len(t.KeepAudioLangs) > 0 && i > 0 && !lib.IsUndeterminedLanguage(track.Language) && !lib.MatchesLanguage(track.Language, t.KeepAudioLangs) {
+			continue
 		}
+		keep = append(keep, strconv.Itoa(i+1))
 	}
+	// Keep everything rather than produce a silent file when every track looks like commentary
+	if len(keep) == len(tracks) || len(keep) == 0 {
+		return []string{"--all-audio"}
+	}
+	return []string{"--audio", strings.Join(keep, ",")}
+}
+
+// standardFrameRates are the rates CFR conversion snaps to, as NLE timelines expect them
+var standardFrameRates = []string{"23.976", "24", "25", "29.97", "30", "48", "50", "59.94", "60", "120"}
+
+// cfrRate picks the output rate for CFR conversion: the standard rate within 2% of the source's
+// average rate, or the average rate itself rounded to three decimals. Returns "" if the rate is unknown.
+func cfrRate(avgFrameRate float64) string {
+	if avgFrameRate <= 0 {
+		return ""
+	}
+	for _, rate := range standardFrameRates {
+		value, _ := strconv.ParseFloat(rate, 64)
+		if math.Abs(avgFrameRate-value)/value < 0.02 {
+			return rate
+		}
+	}
+	return strconv.FormatFloat(avgFrameRate, 'f', 3, 64)
+}
+
+// frameRateArgs requests constant frame rate output when CFRConvert is set. HandBrake otherwise
+// keeps the source's timing, including any variable frame rate.
+func (t *HandBrakeTranscoder) frameRateArgs(videoInfo *lib.VideoInfo) []string {
+	if !t.CFRConvert {
+		return nil
+	}
+	if rate := cfrRate(videoInfo.FrameRate); rate != "" {
+		return []string{"--rate", rate, "--cfr"}
+	}
+	return []string{"--cfr"}
+}
+
+// Policies for choosing which subtitle tracks to keep. Forced subtitles are kept under every
+// policy, since they translate foreign dialogue the audio alone leaves unintelligible.
+const (
+	SubtitlePolicyAll    = "all"    // Keep every subtitle track
+	SubtitlePolicyNoSDH  = "no-sdh" // Drop SDH tracks, keeping full and forced subtitles
+	SubtitlePolicyForced = "forced" // Keep only forced subtitles
+)
+
+// subtitleArgs selects the subtitle tracks to keep under SubtitlePolicy, leaving out bitmap
+// subtitles when they are dropped to fit MP4. HandBrake numbers subtitle tracks from 1 in
+// stream order.
+func (t *HandBrakeTranscoder) subtitleArgs(tracks []lib.SubtitleTrack) []string {
+	return t.subtitleSelection(tracks, t.convertsForMP4())
+}
+
+// subtitleSelection is subtitleArgs, optionally dropping bitmap subtitles
+func (t *HandBrakeTranscoder) subtitleSelection(tracks []lib.SubtitleTrack, dropBitmap bool) []string {
+	keepAll := t.SubtitlePolicy == "" || t.SubtitlePolicy == SubtitlePolicyAll
+	if keepAll && !dropBitmap {
+		return []string{"--all-subtitles"}
+	}
+
+	var keep []string
+	for i, track := range tracks {
+		if dropBitmap && bitmapSubtitleCodecs[track.Codec] {
+			continue
+		}
+		switch {
+		case keepAll, track.Kind == lib.SubtitleKindForced,
+			t.SubtitlePolicy == SubtitlePolicyNoSDH && track.Kind != lib.SubtitleKindSDH:
+			keep = append(keep, strconv.Itoa(i+1))
+		}
+	}
+	switch len(keep) {
+	case len(tracks):
+		return []string{"--all-subtitles"}
+	case 0:
+		return []string{"--subtitle", "none"}
+	}
+	return []string{"--subtitle", strings.Join(keep, ",")}
 }
 
 // generateOutputPath creates the output file path by adding the configured suffix.
-// Replaces the original extension with .mkv and inserts the suffix before the extension.
+// Replaces the original extension with the container's (.mkv by default) and inserts the suffix before the extension.
 // Example: "movie.mp4" with suffix "-optimized" becomes "movie-optimized.mkv"
+// When OutputDir is set, the path relative to InputRoot is recreated under OutputDir.
 func (t *HandBrakeTranscoder) generateOutputPath(inputPath string) string {
 	dir := filepath.Dir(inputPath)
+	if t.OutputDir != "" {
+		if rel, err := filepath.Rel(t.InputRoot, dir); err == nil && !lib.IsOutsideRel(rel) {
+			dir = filepath.Join(t.OutputDir, rel)
+		} else {
+			dir = t.OutputDir
+		}
+	}
 	ext := filepath.Ext(inputPath)
 	base := strings.TrimSuffix(filepath.Base(inputPath), ext)
 
-	return filepath.Join(dir, base+t.OutputSuffix+".mkv")
+	return filepath.Join(dir, base+t.OutputSuffix+t.outputExtension())
+}
+
+// OutputPath returns the path the transcoder writes for the given input file
+func (t *HandBrakeTranscoder) OutputPath(inputPath string) string {
+	if path, ok := t.writtenOutput(inputPath); ok {
+		return path
+	}
+	return t.generateOutputPath(inputPath)
 }
 
 // executeTranscode performs the actual video transcoding using HandBrakeCLI.
 // Builds command arguments, selects encoder, and executes the transcoding process.
+// External subtitle files are added as subtitle tracks. A non-nil title limits the encode to
+// that title's chapters.
 // Returns an error if the transcoding process fails.
-func (t *HandBrakeTranscoder) executeTranscode(ctx context.Context, inputPath, outputPath string, videoInfo *lib.VideoInfo, hasVideoToolbox bool) error {
+func (t *HandBrakeTranscoder) executeTranscode(ctx context.Context, inputPath, outputPath string, videoInfo *lib.VideoInfo, subtitles []lib.ExternalSubtitle, title *lib.TitleRange, encoder string) error {
+	if t.usesAVFoundation() {
+		slog.Info("Using encoder", "encoder", avfoundationEncoder, "preset", avconvertPreset(videoInfo))
+		if title != nil {
+			return t.exportWithAVConvert(ctx, inputPath, outputPath, videoInfo, title.Start, title.End-title.Start)
+		}
+		return t.exportWithAVConvert(ctx, inputPath, outputPath, videoInfo, 0, 0)
+	}
+
+	args := t.transcodeArgs(ctx, inputPath, outputPath, videoInfo, subtitles, title, encoder)
+	t.logHandBrakeCommand(ctx, args)
+
+	return t.runHandBrakeCLI(ctx, args)
+}
+
+// transcodeArgs builds the HandBrakeCLI arguments of a file's encode, logging the choices made
+// for it. The arguments start with -i inputPath -o outputPath.
+func (t *HandBrakeTranscoder) transcodeArgs(ctx context.Context, inputPath, outputPath string, videoInfo *lib.VideoInfo, subtitles []lib.ExternalSubtitle, title *lib.TitleRange, encoder string) []string {
 	args := []string{
 		"-i", inputPath,
 		"-o", outputPath,
 		"--verbose", "1",
 	}
-
-	encoder := t.selectEncoder(videoInfo, hasVideoToolbox)
+	args = append(args, titleArgs(title)...)
 
 	slog.Info("Using encoder", "encoder", encoder)
 	args = append(args, "--encoder", encoder)
+	args = append(args, hdrMetadataArgs(videoInfo.HDR, encoder)...)
+	args = append(args, t.lowPowerArgs(encoder)...)
+	args = append(args, t.hwDecodeArgs(encoder)...)
+	if !isSoftwareFallback(ctx) {
+		args = append(args, t.encoderOptionArgs()...)
+	}
+	args = append(args, t.filmGrainArgs(videoInfo)...)
+	args = append(args, t.denoiseArgs(videoInfo)...)
 
-	args = append(args, "--quality", fmt.Sprintf("%d", t.Quality))
-	args = append(args, "--all-audio", "--all-subtitles")
-	args = append(args, "--format", "av_mkv")
-
-	slog.Debug("Executing HandBrakeCLI", "args", strings.Join(args, " "))
-
-	return t.runHandBrakeCLI(ctx, args)
+	t.logRateControl(encoder)
+	args = append(args, t.rateControlArgs(encoder, t.qualityFor(videoInfo))...)
+	if frameRateArgs := t.frameRateArgs(videoInfo); frameRateArgs != nil {
+		slog.Info("Converting to constant frame rate", "source_fps", videoInfo.FrameRate, "source_vfr", videoInfo.IsVFR, "args", strings.Join(frameRateArgs, " "))
+		args = append(args, frameRateArgs...)
+	}
+	audioArgs := t.audioArgs(videoInfo.AudioTracks)
+	if audioArgs[0] == "--audio" {
+		slog.Info("Selecting audio tracks", "kept_tracks", audioArgs[1], "of", len(videoInfo.AudioTracks))
+	}
+	args = append(args, audioArgs...)
+	args = append(args, t.audioEncoderArgs(videoInfo.AudioTracks)...)
+	subtitleArgs := t.subtitleArgs(videoInfo.SubtitleTracks)
+	if subtitleArgs[0] == "--subtitle" {
+		slog.Info("Selecting subtitles", "policy", t.SubtitlePolicy, "kept_tracks", subtitleArgs[1])
+	}
+	args = append(args, subtitleArgs...)
+	args = append(args, externalSubtitleArgs(subtitles)...)
+	container := t.containerFor(videoInfo)
+	args = append(args, "--format", containerFormat(container))
+	if container == ContainerMP4 {
+		// Put the moov atom first so players can start streaming before the whole file arrives
+		args = append(args, "--optimize")
+	}
+	return append(args, t.HandBrakeArgs...)
 }
\ No newline at end of file
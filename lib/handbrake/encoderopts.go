@@ -0,0 +1,48 @@
+package handbrake
+
+import (
+	"context"
+	"log/slog"
+)
+
+// encoderOptionArgs returns the HandBrakeCLI arguments passing EncoderPreset, EncoderTune, and
+// EncoderProfile to the video encoder. Which values are valid depends on the encoder, so they
+// are left to HandBrakeCLI to check.
+func (t *HandBrakeTranscoder) encoderOptionArgs() []string {
+	var args []string
+	if t.EncoderPreset != "" {
+		args = append(args, "--encoder-preset", t.EncoderPreset)
+	}
+	if t.EncoderTune != "" {
+		args = append(args, "--encoder-tune", t.EncoderTune)
+	}
+	if t.EncoderProfile != "" {
+		args = append(args, "--encoder-profile", t.EncoderProfile)
+	}
+	return args
+}
+
+// softwareFallbackKey marks a context whose encode retries a failed hardware encode with the
+// software encoder
+type softwareFallbackKey struct{}
+
+// withSoftwareFallback returns a context under which encodes leave out the encoder options.
+// They were chosen for the hardware encoder, whose presets, tunes, and profiles the software
+// encoder rejects, so the retry uses the software encoder's defaults instead.
+func withSoftwareFallback(ctx context.Context) context.Context {
+	return context.WithValue(ctx, softwareFallbackKey{}, true)
+}
+
+// isSoftwareFallback reports whether ctx was made by withSoftwareFallback
+func isSoftwareFallback(ctx context.Context) bool {
+	fallback, _ := ctx.Value(softwareFallbackKey{}).(bool)
+	return fallback
+}
+
+// logEncoderOptions describes the encoder options set for this run
+func (t *HandBrakeTranscoder) logEncoderOptions() {
+	if t.EncoderPreset == "" && t.EncoderTune == "" && t.EncoderProfile == "" {
+		return
+	}
+	slog.Info("Encoder options", "preset", t.EncoderPreset, "tune", t.EncoderTune, "profile", t.EncoderProfile)
+}
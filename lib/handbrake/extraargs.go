@@ -0,0 +1,90 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// reservedHandBrakeArgs are HandBrakeCLI options the transcoder sets itself: the input and
+// output, the container the output's extension depends on, and the title a split encodes
+var reservedHandBrakeArgs = []string{"-i", "--input", "-o", "--output", "-f", "--format", "-t", "--title", "--chapters"}
+
+// reservedFFmpegArgs are ffmpeg options that would change which files a run reads or writes,
+// or re-encode the streams it copies
+var reservedFFmpegArgs = []string{"-i", "-y", "-n", "-f", "-map", "-c", "-codec"}
+
+// ffmpegFlagOptions are common ffmpeg output options that take no value, so an argument after
+// one is not its value
+var ffmpegFlagOptions = map[string]bool{
+	"-shortest": true, "-an": true, "-vn": true, "-sn": true, "-dn": true, "-copyts": true,
+	"-start_at_zero": true, "-bitexact": true, "-nostdin": true, "-stats": true, "-nostats": true, "-hide_banner": true,
+}
+
+// ValidateHandBrakeArgs checks extra HandBrakeCLI arguments, rejecting options the transcoder
+// sets itself
+func ValidateHandBrakeArgs(args []string) error {
+	return checkReservedArgs(args, reservedHandBrakeArgs, "HandBrakeCLI")
+}
+
+// ValidateFFmpegArgs checks extra ffmpeg output arguments, rejecting options that change the
+// inputs, outputs, stream mapping, or codecs. Every argument must be an option or the value of
+// one that takes a value, since ffmpeg would take a bare argument as another output file.
+func ValidateFFmpegArgs(args []string) error {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("%q is not an option: ffmpeg would treat it as an output file", args[0])
+	}
+	for i := 1; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		if previous := args[i-1]; !strings.HasPrefix(previous, "-") || ffmpegFlagOptions[previous] {
+			return fmt.Errorf("%q is not the value of an option: ffmpeg would treat it as an output file", args[i])
+		}
+	}
+	return checkReservedArgs(args, reservedFFmpegArgs, "ffmpeg")
+}
+
+// checkReservedArgs rejects args that set any of the reserved options, given alone, as
+// --option=value, or for a stream as -option:specifier
+func checkReservedArgs(args, reserved []string, tool string) error {
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		for _, option := range reserved {
+			if name == option || strings.HasPrefix(name, option+":") {
+				return fmt.Errorf("%s is set by media-mgmt and cannot be passed to %s", option, tool)
+			}
+		}
+	}
+	return nil
+}
+
+// withFFmpegArgs inserts FFmpegArgs into an ffmpeg command line before its output file, the
+// last argument, where ffmpeg reads them as options of that output
+func (t *HandBrakeTranscoder) withFFmpegArgs(args []string) []string {
+	if len(t.FFmpegArgs) == 0 || len(args) == 0 {
+		return args
+	}
+	last := len(args) - 1
+	return append(append(append([]string{}, args[:last]...), t.FFmpegArgs...), args[last])
+}
+
+// commandLogLevel is the level a tool's command line is logged at: info when it carries extra
+// arguments from the user, so the final command shows in normal output, otherwise debug
+func commandLogLevel(extra []string) slog.Level {
+	if len(extra) > 0 {
+		return slog.LevelInfo
+	}
+	return slog.LevelDebug
+}
+
+// logHandBrakeCommand logs a HandBrakeCLI command line
+func (t *HandBrakeTranscoder) logHandBrakeCommand(ctx context.Context, args []string) {
+	slog.Log(ctx, commandLogLevel(t.HandBrakeArgs), "Executing HandBrakeCLI", "args", strings.Join(args, " "))
+}
+
+// logFFmpegCommand logs an ffmpeg command line built with withFFmpegArgs
+func (t *HandBrakeTranscoder) logFFmpegCommand(ctx context.Context, args []string) {
+	slog.Log(ctx, commandLogLevel(t.FFmpegArgs), "Executing ffmpeg", "args", strings.Join(args, " "))
+}
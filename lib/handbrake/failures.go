@@ -0,0 +1,167 @@
+package handbrake
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// Classes of encode failure, recorded with failed files in history, the run summary, and serve
+// mode jobs
+const (
+	FailureNoTitle      = "no_title"      // HandBrake found no title it can encode in the source
+	FailureDRM          = "drm"           // The source is copy-protected
+	FailureDiskFull     = "disk_full"     // The output or temporary directory ran out of space
+	FailureCorruptInput = "corrupt_input" // The source is damaged or truncated
+	FailureInvalidInput = "invalid_input" // HandBrakeCLI rejected its input or options (exit status 2)
+	FailureCrashed      = "crashed"       // HandBrakeCLI was killed by a signal
+	FailureUnknown      = "unknown"
+)
+
+// failureOutputLimit is how much of the end of HandBrakeCLI's stderr is kept to classify a failure
+const failureOutputLimit = 64 * 1024
+
+// handBrakeExitInvalidInput is the exit status HandBrakeCLI documents for invalid input
+const handBrakeExitInvalidInput = 2
+
+// failureLines is how many lines at the end of HandBrake's output are searched for the cause of
+// a failure. HandBrake logs recoverable problems, such as a damaged frame, throughout the
+// encode; only what it printed as it gave up explains why it failed.
+const failureLines = 20
+
+// failurePatterns are lowercase messages HandBrake prints as it fails identifying each class of
+// failure, checked in order so running out of space wins over the errors it causes. Decode
+// errors are not among them: hardware decoders and damaged frames report them without the
+// source being unusable, so they are left unclassified and retried.
+var failurePatterns = []struct {
+	kind     string
+	patterns []string
+}{
+	{FailureDiskFull, []string{"no space left on device", "disk full", "not enough space"}},
+	{FailureDRM, []string{"libdvdcss", "encrypted dvd", "aacs", "bd+", "copy protect", "css authentication", "failed to decrypt"}},
+	{FailureNoTitle, []string{"no title found", "0 valid title", "unrecognized file type", "no valid title"}},
+	{FailureCorruptInput, []string{"invalid data found when processing input", "moov atom not found", "end of file reached unexpectedly"}},
+}
+
+// permanentFailures are the classes retrying cannot fix, which transcode --retry-failed leaves out
+var permanentFailures = map[string]bool{
+	FailureNoTitle:      true,
+	FailureDRM:          true,
+	FailureCorruptInput: true,
+}
+
+// HandBrakeError is a failed HandBrakeCLI run, classified from its exit status and output
+type HandBrakeError struct {
+	Kind     string // One of the Failure classes
+	ExitCode int    // HandBrakeCLI's exit status (-1 if it did not exit normally)
+	Message  string // The ERROR lines of HandBrake's output, or the line the failure was classified by
+	Err      error
+}
+
+func (e *HandBrakeError) Error() string {
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v: %s", e.Err, e.Message)
+}
+
+func (e *HandBrakeError) Unwrap() error { return e.Err }
+
+// Permanent reports whether retrying the encode cannot succeed without the source changing
+func (e *HandBrakeError) Permanent() bool { return permanentFailures[e.Kind] }
+
+// classifyHandBrake turns the error of a HandBrakeCLI run into a HandBrakeError, using its exit
+// status and the end of its stderr
+func classifyHandBrake(err error, output string) error {
+	if err == nil {
+		return nil
+	}
+	classified := &HandBrakeError{Kind: FailureUnknown, ExitCode: -1, Message: handBrakeErrors(output), Err: err}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		classified.ExitCode = exitErr.ExitCode()
+	}
+
+	if kind, line := matchFailure(output); kind != "" {
+		classified.Kind = kind
+		if classified.Message == "" {
+			classified.Message = line
+		}
+		return classified
+	}
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		classified.Kind = FailureDiskFull
+	case classified.ExitCode == handBrakeExitInvalidInput:
+		classified.Kind = FailureInvalidInput
+	case exitErr != nil && classified.ExitCode == -1:
+		classified.Kind = FailureCrashed
+	}
+	return classified
+}
+
+// matchFailure returns the class of failure the final lines of the output show and the line
+// showing it, or "" if no line matches
+func matchFailure(output string) (kind, line string) {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > failureLines {
+		lines = lines[len(lines)-failureLines:]
+	}
+	for _, failure := range failurePatterns {
+		for _, line := range lines {
+			lower := strings.ToLower(line)
+			for _, pattern := range failure.patterns {
+				if strings.Contains(lower, pattern) {
+					return failure.kind, strings.TrimSpace(line)
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// failureClass returns the class of a file's failure and whether it is permanent. Failures that
+// were not classified, such as those of ffmpeg or the checks before encoding, return "".
+func failureClass(err error) (kind string, permanent bool) {
+	var permanentErr *permanentError
+	var handBrakeErr *HandBrakeError
+	switch {
+	case errors.As(err, &permanentErr):
+		return permanentErr.reason, true
+	case errors.As(err, &handBrakeErr):
+		return handBrakeErr.Kind, handBrakeErr.Permanent()
+	case errors.Is(err, syscall.ENOSPC):
+		return FailureDiskFull, false
+	}
+	return "", false
+}
+
+// outputTail keeps the last failureOutputLimit bytes written to it
+type outputTail struct {
+	mutex sync.Mutex
+	data  []byte
+}
+
+func (o *outputTail) Write(p []byte) (int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.data = append(o.data, p...)
+	if excess := len(o.data) - failureOutputLimit; excess > 0 {
+		// Drop whole lines where possible, so the first line kept is not cut short
+		if end := bytes.IndexByte(o.data[excess:], '\n'); end >= 0 {
+			excess += end + 1
+		}
+		o.data = append(o.data[:0], o.data[excess:]...)
+	}
+	return len(p), nil
+}
+
+func (o *outputTail) String() string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return string(o.data)
+}
@@ -0,0 +1,27 @@
+package handbrake
+
+import "media-mgmt/lib"
+
+// AddFeatures fills in the transcode options of a feature report: the encoding engines, output
+// containers, and verification levels usable with the tools it found
+func AddFeatures(report *lib.FeatureReport) {
+	caps := &lib.Capabilities{Tools: report.Tools}
+
+	report.Engines = []string{}
+	if caps.Tool("HandBrakeCLI") != nil {
+		report.Engines = append(report.Engines, EngineHandBrake)
+	}
+	if checkAVConvert() == nil {
+		report.Engines = append(report.Engines, EngineAVFoundation)
+	}
+	report.Containers = []string{ContainerMKV, ContainerMP4}
+
+	// Unlike the checks made before a transcode, features that could not be detected are left out
+	report.Verification = []string{VerifyNone}
+	if ffmpeg := caps.Tool("ffmpeg"); ffmpeg != nil {
+		report.Verification = append(report.Verification, VerifyDecode)
+		if ffmpeg.HasFilter("libvmaf") {
+			report.Verification = append(report.Verification, VerifyVMAF)
+		}
+	}
+}
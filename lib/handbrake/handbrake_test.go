@@ -1,10 +1,26 @@
 package handbrake
 
 import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"media-mgmt/lib"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
 )
 
 func TestGenerateOutputPath(t *testing.T) {
@@ -12,6 +28,8 @@ func TestGenerateOutputPath(t *testing.T) {
 		name         string
 		inputPath    string
 		outputSuffix string
+		outputDir    string
+		inputRoot    string
 		expected     string
 	}{
 		{
@@ -32,12 +50,35 @@ func TestGenerateOutputPath(t *testing.T) {
 			outputSuffix: "",
 			expected:     "/path/to/video.mkv",
 		},
+		{
+			name:      "mirrored output tree",
+			inputPath: "/masters/shows/video.mp4",
+			outputDir: "/streaming",
+			inputRoot: "/masters",
+			expected:  "/streaming/shows/video.mkv",
+		},
+		{
+			name:      "directory starting with dots",
+			inputPath: "/masters/..extras/video.mp4",
+			outputDir: "/streaming",
+			inputRoot: "/masters",
+			expected:  "/streaming/..extras/video.mkv",
+		},
+		{
+			name:      "input outside root",
+			inputPath: "/elsewhere/video.mp4",
+			outputDir: "/streaming",
+			inputRoot: "/masters",
+			expected:  "/streaming/video.mkv",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			transcoder := &HandBrakeTranscoder{
 				OutputSuffix: tt.outputSuffix,
+				OutputDir:    tt.outputDir,
+				InputRoot:    tt.inputRoot,
 			}
 
 			result := transcoder.generateOutputPath(tt.inputPath)
@@ -146,6 +187,32 @@ file5.avi`
 	}
 }
 
+func TestReadFileList(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		nul      bool
+		expected []string
+	}{
+		{"lines", "  a.mkv \n# comment\n\nb c.mp4\r\n", false, []string{"a.mkv", "b c.mp4"}},
+		{"nul", "./a.mkv\x00./# not a comment .mkv\x00./line\nbreak.mkv\x00 spaced .mkv\x00", true, []string{"./a.mkv", "./# not a comment .mkv", "./line\nbreak.mkv", " spaced .mkv"}},
+		{"nul without terminator", "a.mkv\x00b.mkv", true, []string{"a.mkv", "b.mkv"}},
+		{"nul empty entries", "\x00\x00a.mkv\x00", true, []string{"a.mkv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := readFileList(strings.NewReader(tt.input), tt.nul)
+			if err != nil {
+				t.Fatalf("readFileList failed: %v", err)
+			}
+			if fmt.Sprint(files) != fmt.Sprint(tt.expected) || len(files) != len(tt.expected) {
+				t.Errorf("readFileList() = %q, want %q", files, tt.expected)
+			}
+		})
+	}
+}
+
 func TestCheckHandBrakeCLI(t *testing.T) {
 	transcoder := &HandBrakeTranscoder{}
 
@@ -159,17 +226,1670 @@ func TestCheckHandBrakeCLI(t *testing.T) {
 }
 
 func TestDetectVideoToolbox(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{capabilities: &lib.Capabilities{Tools: map[string]*lib.ToolCapabilities{}}}
+	if transcoder.detectVideoToolbox() {
+		t.Error("Expected no VideoToolbox without HandBrakeCLI")
+	}
+
+	// VideoToolbox encoders are only usable on macOS, even if listed
+	transcoder.capabilities.Tools["HandBrakeCLI"] = &lib.ToolCapabilities{Encoders: []string{"x265", "vt_h265"}}
+	if got := transcoder.detectVideoToolbox(); got != (runtime.GOOS == "darwin") {
+		t.Errorf("Expected VideoToolbox %v on %s, got %v", runtime.GOOS == "darwin", runtime.GOOS, got)
+	}
+}
+
+func TestSelectEncoderHDR(t *testing.T) {
 	transcoder := &HandBrakeTranscoder{}
+	tests := []struct {
+		name            string
+		videoInfo       *lib.VideoInfo
+		hasVideoToolbox bool
+		expectedEncoder string
+		expectedArgs    []string
+	}{
+		{"SDR", &lib.VideoInfo{}, false, "x265", nil},
+		{"HDR10 on VideoToolbox", &lib.VideoInfo{IsHDR: true, HDR: &lib.HDRInfo{Format: lib.HDRFormatHDR10}}, true, "vt_h265_10bit", nil},
+		{"HDR10+", &lib.VideoInfo{IsHDR: true, HDR: &lib.HDRInfo{Format: lib.HDRFormatHDR10Plus}}, false, "x265_10bit", []string{"--hdr-dynamic-metadata", "hdr10plus"}},
+		{"Dolby Vision avoids VideoToolbox", &lib.VideoInfo{IsHDR: true, HDR: &lib.HDRInfo{Format: lib.HDRFormatDolbyVision, DVProfile: 8}}, true, "x265_10bit", []string{"--hdr-dynamic-metadata", "dolbyvision"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoder := transcoder.selectEncoder(tt.videoInfo, tt.hasVideoToolbox)
+			if encoder != tt.expectedEncoder {
+				t.Errorf("selectEncoder() = %q, want %q", encoder, tt.expectedEncoder)
+			}
+			if args := hdrMetadataArgs(tt.videoInfo.HDR, encoder); strings.Join(args, " ") != strings.Join(tt.expectedArgs, " ") {
+				t.Errorf("hdrMetadataArgs() = %v, want %v", args, tt.expectedArgs)
+			}
+		})
+	}
+}
+
+func TestAudioArgs(t *testing.T) {
+	tracks := []lib.AudioTrack{
+		{Index: 1, Role: lib.AudioRoleMain},
+		{Index: 2, Role: lib.AudioRoleCommentary},
+		{Index: 3, Role: lib.AudioRoleDescriptive},
+	}
+	dubs := []lib.AudioTrack{
+		{Index: 1, Language: "fre", Role: lib.AudioRoleMain},
+		{Index: 2, Language: "eng", Role: lib.AudioRoleMain},
+		{Index: 3, Language: "ger", Role: lib.AudioRoleMain},
+		{Index: 4, Language: "jpn", Role: lib.AudioRoleMain},
+		{Index: 5, Language: "und", Role: lib.AudioRoleMain},
+		{Index: 6, Language: "eng", Role: lib.AudioRoleCommentary},
+	}
+	tests := []struct {
+		name           string
+		dropCommentary bool
+		languages      []string
+		tracks         []lib.AudioTrack
+		expected       string
+	}{
+		{"keep all", false, nil, tracks, "--all-audio"},
+		{"drop commentary", true, nil, tracks, "--audio 1,3"},
+		{"nothing to drop", true, nil, tracks[:1], "--all-audio"},
+		{"only commentary", true, nil, tracks[1:2], "--all-audio"},
+		{"languages keep first and untagged", false, []string{"en", "ja"}, dubs, "--audio 1,2,4,5,6"},
+		{"languages and commentary", true, []string{"en"}, dubs, "--audio 1,2,5"},
+		{"languages all match", false, []string{"fr", "en", "de", "ja"}, dubs, "--all-audio"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{DropCommentary: tt.dropCommentary, KeepAudioLangs: tt.languages}
+			if got := strings.Join(transcoder.audioArgs(tt.tracks), " "); got != tt.expected {
+				t.Errorf("audioArgs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFrameRateArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfrConvert bool
+		frameRate  float64
+		expected   string
+	}{
+		{"disabled", false, 29.6, ""},
+		{"snaps to NTSC", true, 29.64, "--rate 29.97 --cfr"},
+		{"snaps to film", true, 23.9, "--rate 23.976 --cfr"},
+		{"nonstandard", true, 15.2, "--rate 15.200 --cfr"},
+		{"unknown rate", true, 0, "--cfr"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{CFRConvert: tt.cfrConvert}
+			if got := strings.Join(transcoder.frameRateArgs(&lib.VideoInfo{FrameRate: tt.frameRate}), " "); got != tt.expected {
+				t.Errorf("frameRateArgs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSubtitleArgs(t *testing.T) {
+	tracks := []lib.SubtitleTrack{
+		{Index: 3, Kind: lib.SubtitleKindFull},
+		{Index: 4, Kind: lib.SubtitleKindSDH},
+		{Index: 5, Kind: lib.SubtitleKindForced},
+	}
+	tests := []struct {
+		policy   string
+		tracks   []lib.SubtitleTrack
+		expected string
+	}{
+		{SubtitlePolicyAll, tracks, "--all-subtitles"},
+		{SubtitlePolicyNoSDH, tracks, "--subtitle 1,3"},
+		{SubtitlePolicyForced, tracks, "--subtitle 3"},
+		{SubtitlePolicyForced, tracks[:2], "--subtitle none"},
+		{SubtitlePolicyNoSDH, tracks[:1], "--all-subtitles"},
+		{SubtitlePolicyForced, nil, "--all-subtitles"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{SubtitlePolicy: tt.policy}
+			if got := strings.Join(transcoder.subtitleArgs(tt.tracks), " "); got != tt.expected {
+				t.Errorf("subtitleArgs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContainerFor(t *testing.T) {
+	pgs := &lib.VideoInfo{
+		AudioTracks:    []lib.AudioTrack{{Codec: "aac"}},
+		SubtitleTracks: []lib.SubtitleTrack{{Codec: "subrip"}, {Codec: "hdmv_pgs_subtitle"}},
+	}
+	trueHD := &lib.VideoInfo{AudioTracks: []lib.AudioTrack{{Codec: "truehd"}}}
+	plain := &lib.VideoInfo{
+		AudioTracks:    []lib.AudioTrack{{Codec: "ac3"}},
+		SubtitleTracks: []lib.SubtitleTrack{{Codec: "subrip"}},
+	}
+	tests := []struct {
+		name       string
+		transcoder *HandBrakeTranscoder
+		videoInfo  *lib.VideoInfo
+		container  string
+		subtitles  string
+	}{
+		{"default", &HandBrakeTranscoder{}, pgs, ContainerMKV, "--all-subtitles"},
+		{"mp4", &HandBrakeTranscoder{Container: ContainerMP4}, plain, ContainerMP4, "--all-subtitles"},
+		{"pgs falls back", &HandBrakeTranscoder{Container: ContainerMP4}, pgs, ContainerMKV, "--all-subtitles"},
+		{"pgs dropped", &HandBrakeTranscoder{Container: ContainerMP4, ContainerFallback: ContainerFallbackConvert}, pgs, ContainerMP4, "--subtitle 1"},
+		{"pgs extracted", &HandBrakeTranscoder{Container: ContainerMP4, ContainerFallback: ContainerFallbackExtract}, pgs, ContainerMP4, "--subtitle 1"},
+		{"pgs not kept", &HandBrakeTranscoder{Container: ContainerMP4, SubtitlePolicy: SubtitlePolicyForced}, pgs, ContainerMP4, "--subtitle none"},
+		{"truehd re-encoded", &HandBrakeTranscoder{Container: ContainerMP4}, trueHD, ContainerMP4, "--all-subtitles"},
+		{"truehd passthrough falls back", &HandBrakeTranscoder{Container: ContainerMP4, PassthroughLossless: true}, trueHD, ContainerMKV, "--all-subtitles"},
+		{"avfoundation", &HandBrakeTranscoder{Engine: EngineAVFoundation}, pgs, ContainerMP4, "--all-subtitles"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.transcoder.containerFor(tt.videoInfo); got != tt.container {
+				t.Errorf("containerFor() = %q, want %q", got, tt.container)
+			}
+			if got := strings.Join(tt.transcoder.subtitleArgs(tt.videoInfo.SubtitleTracks), " "); got != tt.subtitles {
+				t.Errorf("subtitleArgs() = %q, want %q", got, tt.subtitles)
+			}
+		})
+	}
+
+	converting := &HandBrakeTranscoder{Container: ContainerMP4, ContainerFallback: ContainerFallbackConvert, PassthroughLossless: true}
+	if args := converting.audioEncoderArgs(trueHD.AudioTracks); args != nil {
+		t.Errorf("audioEncoderArgs() converting for MP4 = %v, want nil", args)
+	}
+	if got := converting.outputPathFor("/m/movie.mkv", ContainerMP4); got != "/m/movie.mp4" {
+		t.Errorf("outputPathFor() = %q, want /m/movie.mp4", got)
+	}
+}
+
+func TestExtractedSubtitles(t *testing.T) {
+	tracks := []lib.SubtitleTrack{
+		{Index: 2, Codec: "subrip", Language: "eng"},
+		{Index: 3, Codec: "hdmv_pgs_subtitle", Language: "eng"},
+		{Index: 4, Codec: "hdmv_pgs_subtitle", Language: "eng", Kind: lib.SubtitleKindForced},
+		{Index: 5, Codec: "hdmv_pgs_subtitle", Language: "eng"},
+		{Index: 6, Codec: "dvd_subtitle"},
+	}
+	transcoder := &HandBrakeTranscoder{Container: ContainerMP4, ContainerFallback: ContainerFallbackExtract}
+
+	var paths []string
+	extracted := transcoder.extractedSubtitles("/m/Movie.mp4", tracks)
+	for _, subtitle := range extracted {
+		paths = append(paths, subtitle.path)
+	}
+	want := "/m/Movie.en.sup /m/Movie.en.forced.sup /m/Movie.en.4.sup /m/Movie.und.mks"
+	if got := strings.Join(paths, " "); got != want {
+		t.Errorf("extractedSubtitles() = %q, want %q", got, want)
+	}
+
+	forcedOnly := &HandBrakeTranscoder{Container: ContainerMP4, ContainerFallback: ContainerFallbackExtract, SubtitlePolicy: SubtitlePolicyForced}
+	if got := forcedOnly.extractedSubtitles("/m/Movie.mp4", tracks); len(got) != 1 || got[0].track.Index != 4 {
+		t.Errorf("extractedSubtitles() with forced policy = %+v, want track 4 only", got)
+	}
+
+	args := strings.Join(subtitleExtractArgs("/m/Movie.mkv", extracted[3], &lib.TitleRange{Start: 60, End: 90}), " ")
+	if want := "-v error -y -ss 60.000 -t 30.000 -i /m/Movie.mkv -map 0:6 -c copy -f matroska /m/Movie.und.mks"; args != want {
+		t.Errorf("subtitleExtractArgs() = %q, want %q", args, want)
+	}
+
+	dir := t.TempDir()
+	written := transcoder.extractedSubtitles(filepath.Join(dir, "Movie.mp4"), tracks)
+	for _, subtitle := range written[:2] {
+		if err := os.WriteFile(subtitle.path, []byte("sub"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	removeExtractedSubtitles(written)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("removeExtractedSubtitles() left %d files", len(entries))
+	}
+}
+
+func TestAudioEncoderArgs(t *testing.T) {
+	tracks := []lib.AudioTrack{
+		{Codec: "truehd", Role: lib.AudioRoleMain},
+		{Codec: "dts", Profile: "DTS-HD MA", Role: lib.AudioRoleMain},
+		{Codec: "ac3", Role: lib.AudioRoleCommentary},
+		{Codec: "pcm_s24le", Role: lib.AudioRoleMain},
+	}
+	tests := []struct {
+		name       string
+		transcoder *HandBrakeTranscoder
+		tracks     []lib.AudioTrack
+		expected   string
+	}{
+		{"disabled", &HandBrakeTranscoder{}, tracks, ""},
+		{"all tracks", &HandBrakeTranscoder{PassthroughLossless: true}, tracks,
+			"--aencoder copy:truehd,copy:dtshd,av_aac,flac24 --audio-fallback av_aac"},
+		{"commentary dropped", &HandBrakeTranscoder{PassthroughLossless: true, DropCommentary: true}, tracks,
+			"--aencoder copy:truehd,copy:dtshd,flac24 --audio-fallback av_aac"},
+		{"no lossless tracks", &HandBrakeTranscoder{PassthroughLossless: true}, []lib.AudioTrack{{Codec: "dts", Profile: "DTS"}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.transcoder.audioEncoderArgs(tt.tracks), " "); got != tt.expected {
+				t.Errorf("audioEncoderArgs() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLosslessDegradations(t *testing.T) {
+	source := []lib.AudioTrack{{Codec: "truehd"}, {Codec: "dts", Profile: "DTS-HD MA"}, {Codec: "ac3"}, {Codec: "flac"}}
+	output := []lib.AudioTrack{{Codec: "truehd"}, {Codec: "dts", Profile: "DTS"}, {Codec: "aac"}}
+
+	problems := losslessDegradations(source, output)
+	want := []string{
+		"track 2 was degraded from dts (DTS-HD MA) to dts (DTS)",
+		"track 4 (flac) is missing from the output",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("losslessDegradations() = %q, want %q", problems, want)
+	}
+	if problems := losslessDegradations(source[:1], output[:1]); len(problems) != 0 {
+		t.Errorf("Expected passthrough to verify, got %q", problems)
+	}
+}
+
+func TestSidecarMuxArgs(t *testing.T) {
+	tracks := []lib.AudioTrack{{Role: lib.AudioRoleMain}, {Role: lib.AudioRoleCommentary}, {Role: lib.AudioRoleMain}}
+	transcoder := &HandBrakeTranscoder{DropCommentary: true}
+	existing := len(transcoder.selectedAudioTracks(tracks))
+	if existing != 2 {
+		t.Fatalf("Expected 2 kept audio tracks, got %d", existing)
+	}
+
+	sidecars := []lib.AudioSidecar{
+		{Path: "/m/movie.en.commentary.ac3", Label: "commentary", Language: "en", Role: lib.AudioRoleCommentary},
+		{Path: "/m/movie.de.dts", Language: "de", Role: lib.AudioRoleMain},
+	}
+	got := strings.Join(sidecarMuxArgs("/m/out.mkv.tmp", "/m/out.mkv.tmp.mux", "matroska", existing, sidecars), " ")
+	want := "-v error -y -i /m/out.mkv.tmp -i /m/movie.en.commentary.ac3 -i /m/movie.de.dts " +
+		"-map 0 -map 1:a:0 -map 2:a:0 -c copy " +
+		"-metadata:s:a:2 language=en -metadata:s:a:2 title=commentary -disposition:a:2 comment " +
+		"-metadata:s:a:3 language=de -disposition:a:3 0 -f matroska /m/out.mkv.tmp.mux"
+	if got != want {
+		t.Errorf("sidecarMuxArgs() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestProgressRegex(t *testing.T) {
+	line := "Encoding: task 1 of 1, 4.50 % (224.12 fps, avg 226.07 fps, ETA 00h02m48s)"
+	matches := progressRegex.FindStringSubmatch(line)
+	if matches == nil {
+		t.Fatalf("Expected progress line to match")
+	}
+	if matches[1] != "4.50" || matches[2] != "224.12" || matches[3] != "226.07" || matches[4] != "00h02m48s" {
+		t.Errorf("Unexpected matches: %v", matches[1:])
+	}
+
+	matches = progressRegex.FindStringSubmatch("Encoding: task 1 of 1, 2.31 %")
+	if matches == nil || matches[1] != "2.31" || matches[2] != "" {
+		t.Errorf("Expected percent-only progress line to match, got %v", matches)
+	}
+
+	if got := taskPercent("Encoding: task 1 of 1, 40.00 %", "40.00"); got != "40.00" {
+		t.Errorf("taskPercent() single pass = %q, want 40.00", got)
+	}
+	if got := taskPercent("Encoding: task 2 of 2, 40.00 %", "40.00"); got != "70.00" {
+		t.Errorf("taskPercent() second pass = %q, want 70.00", got)
+	}
+}
+
+func TestFindStaleTempFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "movie.mp4")
+	staleOwned := filepath.Join(tmpDir, "movie-optimized.mkv.tmp")
+	staleOther := filepath.Join(tmpDir, "other-optimized.mkv.tmp")
+	fresh := filepath.Join(tmpDir, "fresh-optimized.mkv.tmp")
+
+	for _, path := range []string{source, staleOwned, staleOther, fresh} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{staleOwned, staleOther} {
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Failed to set times on %s: %v", path, err)
+		}
+	}
+
+	transcoder := &HandBrakeTranscoder{OutputSuffix: "-optimized"}
+	stale := transcoder.findStaleTempFiles([]string{source}, time.Hour)
+
+	if len(stale) != 2 {
+		t.Fatalf("Expected 2 stale temp files, got %d", len(stale))
+	}
+	if stale[0].path != staleOwned || stale[0].source != source {
+		t.Errorf("Expected %s owned by %s, got %+v", staleOwned, source, stale[0])
+	}
+	if stale[1].path != staleOther || stale[1].source != "" {
+		t.Errorf("Expected %s without source, got %+v", staleOther, stale[1])
+	}
+}
+
+func TestFindArtifacts(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "Show", "Season 01")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(path string, stale bool) {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if stale {
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(filepath.Join(root, "movie.mkv"), true)
+	write(filepath.Join(root, "movie-optimized.mkv.tmp"), true)
+	write(filepath.Join(root, "movie.mkv.size-test-2.mkv"), true)
+	write(filepath.Join(nested, "ep.mkv.sample-gate.mp4"), true)
+	write(filepath.Join(nested, "ep-optimized.mkv.tmp.mux"), true)
+	write(filepath.Join(nested, "ep-optimized.mp4.tmp"), false)
+	write(filepath.Join(nested, "notes.tmp"), true)
+	write(filepath.Join(nested, "ep.mkv.chunk-002.encoded.mkv"), true)
+	write(filepath.Join(root, "movie.mkv.bench-3.mp4"), true)
+	// Videos whose names merely contain the work file markers are left alone
+	for _, name := range []string{"Workout.bench-press.mkv", "Show.chunk-2.mp4", "Talk.size-sample.mp4", "notes.txt.size-test.mkv", "Cake.sample-gate.mkv"} {
+		write(filepath.Join(root, name), true)
+	}
 
-	// This test checks VideoToolbox detection
-	// Results will vary based on platform
-	hasVT, err := transcoder.detectVideoToolbox()
+	artifacts, err := FindArtifacts(root, time.Hour)
 	if err != nil {
-		t.Logf("VideoToolbox detection error: %v", err)
+		t.Fatalf("FindArtifacts failed: %v", err)
+	}
+	var got []string
+	for _, artifact := range artifacts {
+		rel, _ := filepath.Rel(root, artifact.Path)
+		got = append(got, rel+":"+artifact.Kind)
+	}
+	want := []string{
+		"Show/Season 01/ep-optimized.mkv.tmp.mux:work",
+		"Show/Season 01/ep.mkv.chunk-002.encoded.mkv:work",
+		"Show/Season 01/ep.mkv.sample-gate.mp4:work",
+		"movie-optimized.mkv.tmp:output",
+		"movie.mkv.bench-3.mp4:work",
+		"movie.mkv.size-test-2.mkv:work",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("FindArtifacts() = %v, want %v", got, want)
+	}
+}
+
+func TestPrepareAhead(t *testing.T) {
+	files := []string{"a.mkv", "b.mkv", "c.mkv", "d.mkv"}
+	for _, lookahead := range []int{1, 2} {
+		var mu sync.Mutex
+		prepared := 0
+		prepare := func(ctx context.Context, file string) (*preparedFile, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			prepared++
+			if file == "b.mkv" {
+				return nil, errors.New("probe failed")
+			}
+			return &preparedFile{path: file}, nil
+		}
+
+		results := prepareAhead(context.Background(), files, lookahead, prepare)
+		for i, file := range files {
+			// Before the consumer takes file i, at most lookahead files past it may be prepared
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			if limit := i + 1 + lookahead; prepared > limit {
+				t.Errorf("lookahead %d: prepared %d files before consuming file %d, want at most %d", lookahead, prepared, i+1, limit)
+			}
+			mu.Unlock()
+
+			result := <-results
+			if result.file != file {
+				t.Fatalf("lookahead %d: result %d is %s, want %s", lookahead, i, result.file, file)
+			}
+			if (result.err != nil) != (file == "b.mkv") {
+				t.Errorf("lookahead %d: %s error = %v", lookahead, file, result.err)
+			}
+		}
+		if _, ok := <-results; ok {
+			t.Errorf("lookahead %d: results not closed after the last file", lookahead)
+		}
+	}
+}
+
+func TestVMAFArgs(t *testing.T) {
+	args := strings.Join(vmafArgs("src.mkv", "out.mkv", &lib.VideoInfo{Width: 1920, Height: 1080}), " ")
+	if !strings.Contains(args, "-i out.mkv -i src.mkv") {
+		t.Errorf("vmafArgs should pass the output as the distorted input first, got %q", args)
+	}
+	if !strings.Contains(args, "[0:v]scale=1920:1080:flags=bicubic") {
+		t.Errorf("vmafArgs should scale the output to the source size, got %q", args)
+	}
+
+	unscaled := strings.Join(vmafArgs("src.mkv", "out.mkv", nil), " ")
+	if strings.Contains(unscaled, "scale=") {
+		t.Errorf("vmafArgs without source dimensions should not scale, got %q", unscaled)
+	}
+}
+
+func TestVMAFSegmentArgs(t *testing.T) {
+	videoInfo := &lib.VideoInfo{Width: 3840, Height: 2160}
+	got := strings.Join(vmafSegmentArgs("/m/in.mkv", "/m/in.mkv.sample-gate.mkv", videoInfo, 3570, 60), " ")
+	want := "-hide_banner -nostats -nostdin -i /m/in.mkv.sample-gate.mkv -ss 3570.000 -t 60.000 -i /m/in.mkv " +
+		"-lavfi [0:v]scale=3840:2160:flags=bicubic,setpts=PTS-STARTPTS[distorted];[1:v]setpts=PTS-STARTPTS[reference];[distorted][reference]libvmaf=n_threads=2 -f null -"
+	if got != want {
+		t.Errorf("vmafSegmentArgs() =\n%s\nwant\n%s", got, want)
+	}
+
+	transcoder := &HandBrakeTranscoder{SampleGateSize: 40 << 30}
+	if transcoder.needsSample(39<<30) || !transcoder.needsSample(40<<30) {
+		t.Error("needsSample() should gate sources at or above SampleGateSize")
+	}
+	if (&HandBrakeTranscoder{}).needsSample(100 << 30) {
+		t.Error("needsSample() should be disabled when SampleGateSize is 0")
+	}
+}
+
+func TestParseVMAFScore(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected float64
+		wantErr  bool
+	}{
+		{"score line", "[Parsed_libvmaf_4 @ 0x7f8] VMAF score: 94.215307\n", 94.215307, false},
+		{"last score wins", "VMAF score: 10.0\nVMAF score: 93.5\n", 93.5, false},
+		{"no score", "Error initializing filter 'libvmaf'\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			score, err := parseVMAFScore(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVMAFScore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if score != tt.expected {
+				t.Errorf("parseVMAFScore() = %v, want %v", score, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSampleConcatList(t *testing.T) {
+	got := sampleConcatList("/movies/Bob's Movie.mkv", []float64{900, 1800}, 10)
+	expected := "ffconcat version 1.0\n" +
+		"file '/movies/Bob'\\''s Movie.mkv'\ninpoint 900.000\noutpoint 910.000\n" +
+		"file '/movies/Bob'\\''s Movie.mkv'\ninpoint 1800.000\noutpoint 1810.000\n"
+	if got != expected {
+		t.Errorf("sampleConcatList() =\n%s\nwant\n%s", got, expected)
+	}
+}
+
+func TestQueueJob(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{
+		OutputSuffix:        "-optimized",
+		Quality:             65,
+		DropCommentary:      true,
+		PassthroughLossless: true,
+		SubtitlePolicy:      SubtitlePolicyNoSDH,
+	}
+	videoInfo := &lib.VideoInfo{
+		AudioTracks: []lib.AudioTrack{
+			{Codec: "truehd", Role: lib.AudioRoleMain},
+			{Codec: "ac3", Role: lib.AudioRoleCommentary},
+			{Codec: "ac3", Role: lib.AudioRoleMain},
+		},
+		SubtitleTracks: []lib.SubtitleTrack{
+			{Kind: lib.SubtitleKindFull},
+			{Kind: lib.SubtitleKindSDH},
+			{Kind: lib.SubtitleKindForced},
+		},
+	}
+
+	job := transcoder.queueJob(1, "/movies/Movie.mp4", videoInfo, false)
+
+	if job.Destination.File != "/movies/Movie-optimized.mkv" || job.Destination.Mux != "av_mkv" {
+		t.Errorf("unexpected destination %+v", job.Destination)
+	}
+	if job.Video.Encoder != "x265" || job.Video.Quality != 65 {
+		t.Errorf("unexpected video settings %+v", job.Video)
+	}
+	expectedAudio := []queueAudioItem{{Track: 0, Encoder: "copy:truehd"}, {Track: 2, Encoder: "av_aac"}}
+	if fmt.Sprint(job.Audio.AudioList) != fmt.Sprint(expectedAudio) {
+		t.Errorf("AudioList = %+v, want %+v", job.Audio.AudioList, expectedAudio)
+	}
+	expectedSubtitles := []queueSubtitleItem{{Track: 0}, {Track: 2}}
+	if fmt.Sprint(job.Subtitle.SubtitleList) != fmt.Sprint(expectedSubtitles) {
+		t.Errorf("SubtitleList = %+v, want %+v", job.Subtitle.SubtitleList, expectedSubtitles)
+	}
+
+	none := &HandBrakeTranscoder{SubtitlePolicy: SubtitlePolicyForced}
+	if list := none.queueJob(2, "/movies/Movie.mp4", videoInfo, false).Subtitle.SubtitleList; len(list) != 1 || list[0].Track != 2 {
+		t.Errorf("forced policy SubtitleList = %+v, want only track 2", list)
+	}
+}
+
+func TestAVConvertArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		videoInfo *lib.VideoInfo
+		start     float64
+		duration  float64
+		expected  []string
+	}{
+		{
+			name:      "1080p full export",
+			videoInfo: &lib.VideoInfo{Width: 1920, Height: 1080},
+			expected:  []string{"--source", "in.mov", "--output", "out.mp4", "--preset", "PresetHEVC1920x1080", "--replace"},
+		},
+		{
+			name:      "4K segment",
+			videoInfo: &lib.VideoInfo{Width: 3840, Height: 2160},
+			start:     120.4,
+			duration:  10,
+			expected:  []string{"--source", "in.mov", "--output", "out.mp4", "--preset", "PresetHEVC3840x2160", "--replace", "--start", "120", "--duration", "10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := avconvertArgs("in.mov", "out.mp4", avconvertPreset(tt.videoInfo), tt.start, tt.duration)
+			if strings.Join(args, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("avconvertArgs() = %v, want %v", args, tt.expected)
+			}
+		})
+	}
+
+	transcoder := &HandBrakeTranscoder{OutputSuffix: "-optimized", Engine: EngineAVFoundation}
+	if output := transcoder.generateOutputPath("/movies/Movie.mov"); output != "/movies/Movie-optimized.mp4" {
+		t.Errorf("generateOutputPath() = %q, want MP4 output", output)
+	}
+	if err := checkAVFoundationInput("/movies/Movie.mkv"); err == nil {
+		t.Error("expected Matroska input to be rejected")
+	}
+}
+
+func TestLowPowerArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		lowPower bool
+		encoder  string
+		expected []string
+	}{
+		{name: "disabled", encoder: "x265", expected: nil},
+		{name: "quick sync", lowPower: true, encoder: "qsv_h265_10bit", expected: []string{"--encopts", "lowpower=1"}},
+		{name: "videotoolbox", lowPower: true, encoder: "vt_h265", expected: []string{"--encoder-preset", "speed"}},
+		{name: "x265 thread cap", lowPower: true, encoder: "x265_10bit", expected: []string{"--encopts", "pools=3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{LowPower: tt.lowPower, LowPowerThreads: 3}
+			args := transcoder.lowPowerArgs(tt.encoder)
+			if strings.Join(args, " ") != strings.Join(tt.expected, " ") {
+				t.Errorf("lowPowerArgs(%q) = %v, want %v", tt.encoder, args, tt.expected)
+			}
+		})
+	}
+
+	transcoder := &HandBrakeTranscoder{lowPowerQSV: true}
+	if encoder := transcoder.selectEncoder(&lib.VideoInfo{IsHDR: true}, false); encoder != "qsv_h265_10bit" {
+		t.Errorf("selectEncoder() = %q, want qsv_h265_10bit in low-power Quick Sync mode", encoder)
+	}
+}
+
+func TestEncoderOptionArgs(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{EncoderPreset: "slow", EncoderTune: "grain", LowPower: true}
+	if got := strings.Join(transcoder.encoderOptionArgs(), " "); got != "--encoder-preset slow --encoder-tune grain" {
+		t.Errorf("encoderOptionArgs() = %q", got)
 	}
+	if args := transcoder.lowPowerArgs("vt_h265"); args != nil {
+		t.Errorf("lowPowerArgs() = %v, want nil when --encoder-preset is set", args)
+	}
+	if args := (&HandBrakeTranscoder{}).encoderOptionArgs(); args != nil {
+		t.Errorf("encoderOptionArgs() = %v, want nil without options", args)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.mp4", 10)
+	write("good-optimized.mkv", 10)
+	empty := write("empty.mp4", 10)
+	write("empty-optimized.mkv", 0)
+	lost := filepath.Join(dir, "lost.mp4")
+	failed := write("failed.mp4", 10)
+	write("failed-optimized.mkv.tmp", 10)
+	write("failed.mp4.size-test-2.mkv", 10)
+	leased := write("leased.mp4", 10)
+	write("leased-optimized.mkv.tmp", 10)
+	unprocessed := write("unprocessed.mp4", 10)
 
-	t.Logf("VideoToolbox available: %v", hasVT)
+	transcoder := &HandBrakeTranscoder{OutputSuffix: "-optimized"}
+	transcoder.result.Files = []lib.FileOutcome{
+		{File: good, Outcome: StageDone},
+		{File: empty, Outcome: StageDone},
+		{File: lost, Outcome: StageDone},
+		{File: failed, Outcome: StageFailed},
+		{File: leased, Outcome: StageSkipped},
+	}
+	transcoder.reconcile([]string{good, empty, lost, failed, leased, unprocessed})
 
-	// On macOS, VideoToolbox should be available if HandBrake is installed
-	// On other platforms, it should be false
+	var got []string
+	for _, d := range transcoder.Result().Discrepancies {
+		got = append(got, filepath.Base(d.File)+": "+strings.ReplaceAll(d.Problem, dir+string(filepath.Separator), ""))
+	}
+	want := []string{
+		"empty.mp4: output empty-optimized.mkv is empty",
+		"lost.mp4: input is missing: stat lost.mp4: no such file or directory",
+		"lost.mp4: transcoded, but no output was found",
+		"failed.mp4: temporary file failed.mp4.size-test-2.mkv was left behind",
+		"failed.mp4: temporary file failed-optimized.mkv.tmp was left behind",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("reconcile() discrepancies =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStatusReporter(t *testing.T) {
+	dir := t.TempDir()
+	transcoder := &HandBrakeTranscoder{
+		StatusFile:   filepath.Join(dir, "status.json"),
+		StatusSocket: filepath.Join(dir, "status.sock"),
+	}
+	transcoder.startBatch([]string{"a.mkv", "b.mkv"})
+	reporter, err := transcoder.startStatusReporter()
+	if err != nil {
+		t.Fatalf("startStatusReporter failed: %v", err)
+	}
+	transcoder.status = reporter
+
+	transcoder.setProgressStage("a.mkv", 1, 2, StageDone)
+	transcoder.setProgressStage("b.mkv", 2, 2, StageEncoding)
+	transcoder.updateProgress("41.5", "30.0", "28.0", "00h01m00s")
+
+	readFile := func() BatchStatus {
+		data, err := os.ReadFile(transcoder.StatusFile)
+		if err != nil {
+			t.Fatalf("Failed to read status file: %v", err)
+		}
+		var status BatchStatus
+		if err := json.Unmarshal(data, &status); err != nil {
+			t.Fatalf("Failed to parse status file: %v", err)
+		}
+		return status
+	}
+
+	// The file is rewritten on stage changes but may lag percent updates by up to statusInterval
+	status := readFile()
+	if status.State != BatchRunning || status.FilesDone != 1 || status.FilesTotal != 2 || status.Current == nil || status.Current.File != "b.mkv" {
+		t.Errorf("Unexpected status file %+v", status)
+	}
+
+	conn, err := net.Dial("unix", transcoder.StatusSocket)
+	if err != nil {
+		t.Fatalf("Failed to connect to status socket: %v", err)
+	}
+	var live BatchStatus
+	if err := json.NewDecoder(conn).Decode(&live); err != nil {
+		t.Fatalf("Failed to read status socket: %v", err)
+	}
+	conn.Close()
+	if live.Current == nil || live.Current.Percent != 41.5 || live.Current.ETA != "00h01m00s" {
+		t.Errorf("Expected the socket to report the latest progress, got %+v", live.Current)
+	}
+
+	transcoder.finishBatch(context.Background(), nil)
+	if status := readFile(); status.State != BatchFinished || status.Current != nil {
+		t.Errorf("Expected a finished status without a current file, got %+v", status)
+	}
+	if _, err := os.Stat(transcoder.StatusSocket); !os.IsNotExist(err) {
+		t.Errorf("Expected the status socket to be removed, got %v", err)
+	}
+}
+
+func TestBatchETA(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{}
+	transcoder.startBatch([]string{"a.mkv", "b.mkv", "c.mkv"})
+	transcoder.batch.sizes = map[string]int64{"a.mkv": 1000, "b.mkv": 2000, "c.mkv": 1000}
+
+	if _, ok := transcoder.BatchETA(); ok {
+		t.Error("Expected no ETA before a file has been transcoded")
+	}
+
+	// a.mkv took 10 seconds for 1000 bytes, so the remaining 3000 bytes should take 30
+	transcoder.setProgressStage("a.mkv", 1, 3, StageEncoding)
+	transcoder.batch.fileStarted["a.mkv"] = time.Now().Add(-10 * time.Second)
+	transcoder.recordSavings("a.mkv", 1000, 400)
+	transcoder.setProgressStage("a.mkv", 1, 3, StageDone)
+	eta, ok := transcoder.BatchETA()
+	if !ok || eta.Round(time.Second) != 30*time.Second {
+		t.Errorf("Expected an ETA of 30s, got %v (%v)", eta, ok)
+	}
+
+	// Half of b.mkv is already encoded
+	transcoder.setProgressStage("b.mkv", 2, 3, StageEncoding)
+	transcoder.updateProgress("50.0", "", "", "")
+	if eta, _ := transcoder.BatchETA(); eta.Round(time.Second) != 20*time.Second {
+		t.Errorf("Expected an ETA of 20s, got %v", eta)
+	}
+
+	outcome := transcoder.Result().Files[0]
+	if outcome.InputBytes != 1000 || outcome.OutputBytes != 400 || outcome.DurationSeconds < 10 {
+		t.Errorf("Unexpected outcome %+v", outcome)
+	}
+}
+
+func TestDashboardRender(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{}
+	dashboard := NewDashboard(transcoder, os.Stdout)
+	if !transcoder.HideProgressBar {
+		t.Error("Expected the dashboard to hide the transcoder's progress bar")
+	}
+	transcoder.startBatch([]string{"/media/a.mkv", "/media/b.mkv", "/media/c.mkv"})
+	transcoder.batch.sizes = map[string]int64{"/media/a.mkv": 2 << 30}
+
+	transcoder.setProgressStage("/media/a.mkv", 1, 3, StageEncoding)
+	transcoder.recordSavings("/media/a.mkv", 2<<30, 1<<30)
+	transcoder.setProgressStage("/media/a.mkv", 1, 3, StageDone)
+	transcoder.setProgressStage("/media/b.mkv", 2, 3, StageEncoding)
+	transcoder.updateProgress("50.0", "30.0", "29.0", "00h10m00s")
+	fmt.Fprintln(dashboard, `level=INFO msg="Successfully transcoded" file=a-optimized.mkv`)
+
+	lines := dashboard.render(60, 20)
+	if len(lines) > 20 {
+		t.Errorf("Expected at most 20 lines, got %d", len(lines))
+	}
+	screen := strings.Join(lines, "\n")
+	for _, want := range []string{"1/3 files", "Saved 1.0 GB of 2.0 GB (50.0%)", "b.mkv  (encoding)", " 50.0%  30.0 fps  ETA 00h10m00s",
+		"✓ a.mkv  done  2.0 GB → 1.0 GB", "▶ b.mkv  encoding", "    c.mkv", `msg="Successfully transcoded"`} {
+		if !strings.Contains(screen, want) {
+			t.Errorf("Expected the dashboard to contain %q, got:\n%s", want, screen)
+		}
+	}
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n > 60 {
+			t.Errorf("Line is %d characters wide: %q", n, line)
+		}
+	}
+
+	dashboard.inState = &term.State{}
+	lines = dashboard.render(80, 20)
+	if len(lines) != 20 || !strings.HasPrefix(lines[19], "a: abort the current file") {
+		t.Errorf("Expected the keys on the last of 20 lines, got %d lines:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+}
+
+func TestPromptSelection(t *testing.T) {
+	newPlan := func() []*plannedFile {
+		var planned []*plannedFile
+		for _, name := range []string{"a", "b", "c", "d", "e"} {
+			planned = append(planned, &plannedFile{path: "/media/" + name + ".mkv", size: 1 << 30, codec: "h264", estimatedSize: 1 << 29, selected: true})
+		}
+		return planned
+	}
+	selection := func(planned []*plannedFile) string {
+		var marks string
+		for _, plan := range planned {
+			if plan.selected {
+				marks += "x"
+			} else {
+				marks += "-"
+			}
+		}
+		return marks
+	}
+
+	tests := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"\n", "xxxxx", nil},
+		{"2 4-5\n\n", "x-x--", nil},
+		{"1,3\n3\n\n", "-xxxx", nil},
+		{"n\n2\n\n", "-x---", nil},
+		{"n\na\n\n", "xxxxx", nil},
+		{"7\n0-2\nx\n1\n\n", "-xxxx", nil},
+		{"2\nq\n", "x-xxx", errSelectionQuit},
+	}
+	for _, tt := range tests {
+		planned := newPlan()
+		var out strings.Builder
+		err := promptSelection(planned, strings.NewReader(tt.input), &out)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Input %q: expected error %v, got %v", tt.input, tt.err, err)
+		}
+		if got := selection(planned); got != tt.want {
+			t.Errorf("Input %q: expected selection %s, got %s", tt.input, tt.want, got)
+		}
+	}
+
+	planned := newPlan()
+	var out strings.Builder
+	if err := promptSelection(planned, strings.NewReader("2\n"), &out); err == nil {
+		t.Error("Expected an error when input ends without starting the batch")
+	}
+	for _, want := range []string{"[ ]  2  b.mkv", "1.0 GB  h264   512.0 MB      50%", "4 of 5 files selected, estimated to save 2.0 GB of 4.0 GB"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the selection list to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestReportDeselected(t *testing.T) {
+	var reported []Progress
+	transcoder := &HandBrakeTranscoder{HideProgressBar: true, OnProgress: func(p Progress) { reported = append(reported, p) }}
+	transcoder.reportDeselected([]string{"/m/a.mkv", "/m/b.mkv", "/m/c.mkv"}, []string{"/m/c.mkv", "/m/b.mkv"})
+
+	if len(reported) != 2 {
+		t.Fatalf("Expected two skipped files, got %+v", reported)
+	}
+	for i, want := range []int{2, 3} {
+		if reported[i].FileNum != want || reported[i].TotalFiles != 3 || reported[i].Stage != StageSkipped {
+			t.Errorf("Progress %d = %+v, want file %d of 3 skipped", i, reported[i], want)
+		}
+	}
+}
+
+func TestCheckMultiTitle(t *testing.T) {
+	playlist := &lib.VideoInfo{Duration: 3600, Chapters: []lib.Chapter{
+		{Start: 0, End: 1200, Title: "Episode 1"}, {Start: 1200, End: 2400, Title: "Episode 2"}, {Start: 2400, End: 3600, Title: "Episode 3"},
+	}}
+	long := &lib.VideoInfo{Duration: 5 * 3600}
+
+	tests := []struct {
+		policy    string
+		videoInfo *lib.VideoInfo
+		skip      bool
+		titles    int
+	}{
+		{MultiTitleWarn, playlist, false, 0},
+		{MultiTitleSkip, playlist, true, 0},
+		{MultiTitleSplit, playlist, false, 3},
+		{MultiTitleSplit, long, true, 0},
+		{MultiTitleSkip, &lib.VideoInfo{Duration: 5400}, false, 0},
+	}
+	for _, tt := range tests {
+		transcoder := &HandBrakeTranscoder{MultiTitle: tt.policy}
+		prepared := &preparedFile{path: "/media/show.mkv", videoInfo: tt.videoInfo}
+		if skip := transcoder.checkMultiTitle(prepared); skip != tt.skip {
+			t.Errorf("%s with %d chapters: expected skip %v, got %v", tt.policy, len(tt.videoInfo.Chapters), tt.skip, skip)
+		}
+		if len(prepared.titles) != tt.titles {
+			t.Errorf("%s with %d chapters: expected %d titles, got %d", tt.policy, len(tt.videoInfo.Chapters), tt.titles, len(prepared.titles))
+		}
+	}
+
+	dir := t.TempDir()
+	transcoder := &HandBrakeTranscoder{MultiTitle: MultiTitleSplit}
+	input := filepath.Join(dir, "show.mkv")
+	output := transcoder.generateOutputPath(input)
+	for title := 1; title <= 3; title++ {
+		if err := os.WriteFile(titleOutputPath(output, title), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		prepared := &preparedFile{path: input, videoInfo: playlist}
+		if skip, done := transcoder.checkMultiTitle(prepared), title == 3; skip != done {
+			t.Errorf("split with %d of 3 titles written: expected skip %v, got %v", title, done, skip)
+		}
+	}
+	if _, ok := transcoder.existingOutput(input); ok {
+		t.Error("existingOutput() found the output of a split file")
+	}
+	if got, _ := transcoder.writtenOutput(input); got != titleOutputPath(output, 1) {
+		t.Errorf("writtenOutput() = %q", got)
+	}
+
+	if got := titleOutputPath("/media/show-optimized.mkv", 2); got != "/media/show-optimized-title2.mkv" {
+		t.Errorf("titleOutputPath() = %q", got)
+	}
+	if got := strings.Join(titleArgs(&lib.TitleRange{FirstChapter: 4, LastChapter: 7}), " "); got != "--chapters 4-7" {
+		t.Errorf("titleArgs() = %q", got)
+	}
+}
+
+func TestQualityFor(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{Quality: 70, Presets: []lib.Preset{
+		{Name: "uhd", Resolution: "2160p", Quality: 65},
+		{Name: "tv", ContentType: lib.ContentTypeEpisode, Quality: 62},
+		{Name: "film", ContentType: lib.ContentTypeMovie, Quality: 75},
+		{Name: "sd", Resolution: "sd", Quality: 78},
+		{Name: "fallback"},
+	}}
+	tests := []struct {
+		videoInfo *lib.VideoInfo
+		quality   int
+	}{
+		{&lib.VideoInfo{Path: "/tv/Show/Show.S02E03.mkv", Duration: 2700, Height: 1080}, 62},
+		{&lib.VideoInfo{Path: "/movies/Film (2001).mkv", Duration: 7000, Height: 1080}, 75},
+		{&lib.VideoInfo{Path: "/movies/Film (2001) 2160p.mkv", Duration: 7000, Height: 2160}, 65},
+		{&lib.VideoInfo{Path: "/movies/Extras/Interview.mkv", Duration: 600, Height: 480}, 78},
+		{&lib.VideoInfo{Path: "/movies/Extras/Trailer.mkv", Duration: 150, Height: 1080}, 70},
+	}
+	for _, tt := range tests {
+		if got := transcoder.qualityFor(tt.videoInfo); got != tt.quality {
+			t.Errorf("qualityFor(%s) = %d, want %d", tt.videoInfo.Path, got, tt.quality)
+		}
+	}
+}
+
+func TestRateControlArgs(t *testing.T) {
+	abr := &HandBrakeTranscoder{RateMode: RateModeABR, Bitrate: 4000}
+	tests := []struct {
+		name       string
+		transcoder *HandBrakeTranscoder
+		encoder    string
+		want       string
+	}{
+		{"quality", &HandBrakeTranscoder{}, "x265", "--quality 68"},
+		{"abr software", abr, "x265_10bit", "--vb 4000 --multi-pass --turbo"},
+		{"abr videotoolbox", abr, "vt_h265", "--vb 4000"},
+		{"abr quick sync", abr, "qsv_h265", "--vb 4000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.transcoder.rateControlArgs(tt.encoder, 68), " "); got != tt.want {
+				t.Errorf("rateControlArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	video := abr.queueVideo("x265", &lib.VideoInfo{})
+	if video.Bitrate != 4000 || !video.MultiPass || !video.Turbo || video.Quality != 0 {
+		t.Errorf("queueVideo() = %+v, want a two-pass 4000 kbps encode", video)
+	}
+}
+
+func TestExtraArgs(t *testing.T) {
+	if err := ValidateHandBrakeArgs([]string{"--encopts", "aq-mode=3", "--comb-detect"}); err != nil {
+		t.Errorf("ValidateHandBrakeArgs() error = %v", err)
+	}
+	for _, args := range [][]string{{"-o", "/tmp/other.mkv"}, {"--format=av_mp4"}} {
+		if err := ValidateHandBrakeArgs(args); err == nil {
+			t.Errorf("ValidateHandBrakeArgs(%q) accepted an option media-mgmt sets", args)
+		}
+	}
+
+	if err := ValidateFFmpegArgs([]string{"-metadata", "comment=archived", "-shortest"}); err != nil {
+		t.Errorf("ValidateFFmpegArgs() error = %v", err)
+	}
+	for _, args := range [][]string{{"extra.mkv"}, {"-shortest", "-metadata", "a=b", "extra.mkv"}, {"-shortest", "extra.mkv"}, {"-map", "0:1"}, {"-c", "libx264"}, {"-c:s", "mov_text"}} {
+		if err := ValidateFFmpegArgs(args); err == nil {
+			t.Errorf("ValidateFFmpegArgs(%q) accepted arguments that change the outputs", args)
+		}
+	}
+
+	transcoder := &HandBrakeTranscoder{FFmpegArgs: []string{"-metadata", "comment=archived"}}
+	got := strings.Join(transcoder.withFFmpegArgs([]string{"-i", "in.mkv", "-c", "copy", "out.mkv"}), " ")
+	if want := "-i in.mkv -c copy -metadata comment=archived out.mkv"; got != want {
+		t.Errorf("withFFmpegArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestDenoiseArgs(t *testing.T) {
+	grainy := &lib.VideoInfo{Width: 1920, Height: 1080, FrameRate: 23.976, VideoBitrate: 30_000_000, Year: 1974}
+	clean := &lib.VideoInfo{Width: 1920, Height: 1080, FrameRate: 23.976, VideoBitrate: 8_000_000, Year: 2019}
+	tests := []struct {
+		name       string
+		transcoder *HandBrakeTranscoder
+		videoInfo  *lib.VideoInfo
+		encoder    string
+		args       string
+	}{
+		{"off", &HandBrakeTranscoder{}, grainy, "x265", ""},
+		{"nlmeans grainy", &HandBrakeTranscoder{Denoise: DenoiseNLMeans}, grainy, "x265", "--nlmeans=medium --nlmeans-tune film"},
+		{"nlmeans clean", &HandBrakeTranscoder{Denoise: DenoiseNLMeans}, clean, "x265", ""},
+		{"hqdn3d all", &HandBrakeTranscoder{Denoise: DenoiseHQDN3D, DenoiseStrength: "light", DenoiseAll: true}, clean, "x265", "--hqdn3d=light"},
+		{"film grain", &HandBrakeTranscoder{Denoise: DenoiseNLMeans, FilmGrain: 8}, grainy, "svt_av1", "--encopts film-grain=8:film-grain-denoise=1"},
+		{"film grain clean", &HandBrakeTranscoder{FilmGrain: 8}, clean, "x265", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(tt.transcoder.filmGrainArgs(tt.videoInfo), tt.transcoder.denoiseArgs(tt.videoInfo)...)
+			if got := strings.Join(args, " "); got != tt.args {
+				t.Errorf("grain args = %q, want %q", got, tt.args)
+			}
+			if got := tt.transcoder.selectEncoder(tt.videoInfo, false); got != tt.encoder {
+				t.Errorf("selectEncoder() = %q, want %q", got, tt.encoder)
+			}
+		})
+	}
+}
+
+func TestSidecarCopies(t *testing.T) {
+	masters, streaming := t.TempDir(), t.TempDir()
+	for _, name := range []string{"movie.mkv", "movie.en.srt", "movie.nfo", "poster.jpg"} {
+		if err := os.WriteFile(filepath.Join(masters, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(streaming, "poster.jpg"), nil, 0644); err != nil {
+		t.Fatalf("Failed to create poster.jpg: %v", err)
+	}
+	input := filepath.Join(masters, "movie.mkv")
+
+	tests := []struct {
+		name       string
+		transcoder *HandBrakeTranscoder
+		output     string
+		want       []string
+	}{
+		{"other directory", &HandBrakeTranscoder{}, filepath.Join(streaming, "movie.mkv"),
+			[]string{filepath.Join(streaming, "movie.en.srt"), filepath.Join(streaming, "movie.nfo")}},
+		{"other directory overwrite", &HandBrakeTranscoder{Overwrite: true}, filepath.Join(streaming, "movie.mkv"),
+			[]string{filepath.Join(streaming, "movie.en.srt"), filepath.Join(streaming, "movie.nfo"), filepath.Join(streaming, "poster.jpg")}},
+		{"same directory", &HandBrakeTranscoder{}, filepath.Join(masters, "movie-optimized.mkv"), nil},
+		{"same directory always", &HandBrakeTranscoder{Sidecars: SidecarsAlways}, filepath.Join(masters, "movie-optimized.mkv"),
+			[]string{filepath.Join(masters, "movie-optimized.en.srt"), filepath.Join(masters, "movie-optimized.nfo")}},
+		{"off", &HandBrakeTranscoder{Sidecars: SidecarsOff}, filepath.Join(streaming, "movie.mkv"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			copies, err := tt.transcoder.sidecarCopies(input, tt.output, nil)
+			if err != nil {
+				t.Fatalf("sidecarCopies failed: %v", err)
+			}
+			var got []string
+			for _, c := range copies {
+				got = append(got, c.dst)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("sidecarCopies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExternalSubtitleArgs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"movie.mkv", "movie.en.srt", "movie.en.sdh.srt", "movie.ger.forced.ass"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	input := filepath.Join(dir, "movie.mkv")
+	srt, sdh, ass := filepath.Join(dir, "movie.en.srt"), filepath.Join(dir, "movie.en.sdh.srt"), filepath.Join(dir, "movie.ger.forced.ass")
+
+	tests := []struct {
+		policy string
+		args   []string
+	}{
+		{SubtitlePolicyAll, []string{"--srt-file", sdh + "," + srt, "--srt-lang", "eng,eng", "--srt-codeset", "UTF-8,UTF-8", "--ssa-file", ass, "--ssa-lang", "deu"}},
+		{SubtitlePolicyNoSDH, []string{"--srt-file", srt, "--srt-lang", "eng", "--srt-codeset", "UTF-8", "--ssa-file", ass, "--ssa-lang", "deu"}},
+		{SubtitlePolicyForced, []string{"--ssa-file", ass, "--ssa-lang", "deu"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{SubtitlePolicy: tt.policy}
+			args := externalSubtitleArgs(transcoder.externalSubtitles(input))
+			if strings.Join(args, " ") != strings.Join(tt.args, " ") {
+				t.Errorf("externalSubtitleArgs() = %v, want %v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestChunkedEncode(t *testing.T) {
+	hour := &lib.VideoInfo{Duration: 3600}
+	chaptered := &lib.VideoInfo{Duration: 3600, Chapters: []lib.Chapter{
+		{Start: 0, End: 1000}, {Start: 1000, End: 1750}, {Start: 1750, End: 2500}, {Start: 2500, End: 3600},
+	}}
+	boundaryTests := []struct {
+		name      string
+		videoInfo *lib.VideoInfo
+		count     int
+		want      []float64
+	}{
+		{"even", hour, 3, []float64{1200, 2400}},
+		{"chapters", chaptered, 2, []float64{1750}},
+		{"chapters too far", chaptered, 3, []float64{1000, 2500}},
+		{"chapter shared by two cuts", &lib.VideoInfo{Duration: 3600, Chapters: []lib.Chapter{{Start: 1800}}}, 4, []float64{900, 1800, 2700}},
+	}
+	for _, tt := range boundaryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := chunkBoundaries(tt.videoInfo, tt.count); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("chunkBoundaries() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	countTests := []struct {
+		name     string
+		prepared *preparedFile
+		title    *lib.TitleRange
+		want     int
+	}{
+		{"large", &preparedFile{path: "/m/a.mkv", originalSize: 40 << 30, videoInfo: hour}, nil, 3},
+		{"small", &preparedFile{path: "/m/a.mkv", originalSize: 10 << 30, videoInfo: hour}, nil, 0},
+		{"short", &preparedFile{path: "/m/a.mkv", originalSize: 40 << 30, videoInfo: &lib.VideoInfo{Duration: 400}}, nil, 0},
+		{"title", &preparedFile{path: "/m/a.mkv", originalSize: 40 << 30, videoInfo: hour}, &lib.TitleRange{}, 0},
+		{"external subtitles", &preparedFile{path: "/m/a.mkv", originalSize: 40 << 30, videoInfo: hour, subtitles: []lib.ExternalSubtitle{{Path: "/m/a.srt"}}}, nil, 0},
+	}
+	transcoder := &HandBrakeTranscoder{ChunkMinSize: 30 << 30, ChunkJobs: 3}
+	for _, tt := range countTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transcoder.chunkCount(tt.prepared, tt.title); got != tt.want {
+				t.Errorf("chunkCount() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	split := strings.Join(chunkSplitArgs("/m/100% film.mkv", []float64{1200, 2400}), " ")
+	if !strings.Contains(split, "-segment_times 1200.000,2400.000") || !strings.HasSuffix(split, "/m/100%% film.mkv.chunk-%03d.mkv") {
+		t.Errorf("chunkSplitArgs() = %q", split)
+	}
+	if got := chunkPath("/m/100% film.mkv", 2, true); got != "/m/100% film.mkv.chunk-002.encoded.mkv" {
+		t.Errorf("chunkPath() = %q", got)
+	}
+	if kind := ArtifactKind("film.mkv.chunk-002.encoded.mkv"); kind != ArtifactWork {
+		t.Errorf("ArtifactKind() of an encoded chunk = %q, want %q", kind, ArtifactWork)
+	}
+
+	chunks := []sourceChunk{{encoded: "a.0.mkv"}, {encoded: "a.1.mkv"}}
+	join := strings.Join(chunkJoinArgs("out.mkv", chunks, "a.mkv"), " ")
+	want := "--quiet -o out.mkv --no-chapters a.0.mkv + --no-attachments --no-chapters a.1.mkv " +
+		"--no-audio --no-video --no-subtitles --no-buttons --no-attachments --no-global-tags --no-track-tags a.mkv"
+	if join != want {
+		t.Errorf("chunkJoinArgs() = %q, want %q", join, want)
+	}
+}
+
+func TestStreamEnds(t *testing.T) {
+	probe := `{
+		"packets": [
+			{"stream_index": 0, "pts_time": "3599.958", "duration_time": "0.042"},
+			{"stream_index": 1, "pts_time": "3599.936", "duration_time": "0.021"},
+			{"stream_index": 2, "pts_time": "3601.000", "duration_time": "0.021"},
+			{"stream_index": 1, "pts_time": "N/A"}
+		],
+		"streams": [{"index": 0, "codec_type": "video"}, {"index": 1, "codec_type": "audio"}, {"index": 2, "codec_type": "audio"}]
+	}`
+	video, audio, err := streamEnds([]byte(probe))
+	if err != nil {
+		t.Fatalf("streamEnds failed: %v", err)
+	}
+	if fmt.Sprintf("%.3f %.3f", video, audio) != "3600.000 3599.957" {
+		t.Errorf("streamEnds() = %.3f, %.3f", video, audio)
+	}
+
+	if _, audio, _ := streamEnds([]byte(`{"packets": [{"stream_index": 0, "pts_time": "10"}], "streams": [{"index": 0, "codec_type": "video"}]}`)); audio != -1 {
+		t.Errorf("Expected no audio end for a file without audio, got %.3f", audio)
+	}
+	if _, _, err := streamEnds([]byte(`{"packets": [], "streams": []}`)); err == nil {
+		t.Error("Expected an error for a file without video packets")
+	}
+}
+
+func TestChunkedSyncAndCrop(t *testing.T) {
+	tests := []struct {
+		drifts []float64
+		ok     bool
+	}{
+		{nil, true},
+		{[]float64{0.1, -0.05, 0.2}, true},
+		{[]float64{0.2, 0.2}, false},
+		{[]float64{-0.4}, false},
+	}
+	for _, tt := range tests {
+		if err := checkCutDrift(tt.drifts); (err == nil) != tt.ok {
+			t.Errorf("checkCutDrift(%v) = %v", tt.drifts, err)
+		}
+	}
+
+	scan := "[12:00:01] scan: 10 previews, 1920x1080, 23.976 fps, autocrop = 132/132/0/0, aspect 2.40:1\n  + autocrop: 132/132/0/0\n"
+	if crop, ok := parseAutocrop([]byte(scan)); !ok || crop != "132:132:0:0" {
+		t.Errorf("parseAutocrop() = %q, %v", crop, ok)
+	}
+	if _, ok := parseAutocrop([]byte("No title found.")); ok {
+		t.Error("Expected no crop from a failed scan")
+	}
+}
+
+func TestHWDecode(t *testing.T) {
+	caps := &lib.Capabilities{Tools: map[string]*lib.ToolCapabilities{
+		"HandBrakeCLI": {Name: "HandBrakeCLI", HWDecoders: []string{"nvdec", "videotoolbox"}},
+		"ffmpeg":       {Name: "ffmpeg", HWDecoders: []string{"videotoolbox", "vaapi"}},
+	}}
+	tests := []struct {
+		name            string
+		hwDecode        string
+		hasVideoToolbox bool
+		handBrake       string
+		ffmpeg          string
+	}{
+		{name: "auto with videotoolbox", hwDecode: HWDecodeAuto, hasVideoToolbox: true, handBrake: "videotoolbox", ffmpeg: "videotoolbox"},
+		{name: "auto with x265", hwDecode: HWDecodeAuto},
+		{name: "nvdec without ffmpeg cuda", hwDecode: HWDecodeNVDec, handBrake: "nvdec"},
+		{name: "vaapi is ffmpeg only", hwDecode: HWDecodeVAAPI, ffmpeg: "vaapi"},
+		{name: "off", hwDecode: HWDecodeOff, hasVideoToolbox: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{HWDecode: tt.hwDecode, capabilities: caps}
+			transcoder.detectHWDecode(tt.hasVideoToolbox)
+			if transcoder.hwDecoder != tt.handBrake || transcoder.hwAccel != tt.ffmpeg {
+				t.Errorf("detectHWDecode() chose HandBrakeCLI %q and ffmpeg %q, want %q and %q", transcoder.hwDecoder, transcoder.hwAccel, tt.handBrake, tt.ffmpeg)
+			}
+		})
+	}
+
+	transcoder := &HandBrakeTranscoder{HWDecode: HWDecodeNVDec, hwDecoder: "nvdec"}
+	if got := strings.Join(transcoder.hwDecodeArgs("x265"), " "); got != "--enable-hw-decoding nvdec" {
+		t.Errorf("hwDecodeArgs() = %q", got)
+	}
+	if args := (&HandBrakeTranscoder{}).hwDecodeArgs("x265"); args != nil {
+		t.Errorf("hwDecodeArgs() = %v, want nil when decoding in software", args)
+	}
+	transcoder = &HandBrakeTranscoder{HWDecode: HWDecodeAuto, hwDecoder: "videotoolbox"}
+	if got := strings.Join(transcoder.hwDecodeArgs("vt_h265"), " "); got != "--enable-hw-decoding videotoolbox" {
+		t.Errorf("hwDecodeArgs() with auto = %q", got)
+	}
+	if args := transcoder.hwDecodeArgs("x265"); args != nil {
+		t.Errorf("hwDecodeArgs() with auto = %v, want nil for a software encoder", args)
+	}
+
+	args := []string{"-i", "out.mkv", "-i", "src.mkv", "-lavfi", "libvmaf", "-f", "null", "-"}
+	if got := strings.Join(withHWAccel(args, "cuda"), " "); got != "-hwaccel cuda -i out.mkv -hwaccel cuda -i src.mkv -lavfi libvmaf -f null -" {
+		t.Errorf("withHWAccel() = %q", got)
+	}
+	if got := withHWAccel(args, ""); strings.Join(got, " ") != strings.Join(args, " ") {
+		t.Errorf("withHWAccel() without hwaccel = %v, want args unchanged", got)
+	}
+}
+
+func TestBenchSegmentArgs(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{Quality: 70, EncoderPreset: "slow"}
+	videoInfo := &lib.VideoInfo{Duration: 3600}
+	args := strings.Join(transcoder.segmentArgs("/m/in.mkv", benchPath("/m/in.mkv", 2, "mkv"), 1770, 60, videoInfo, "x265_10bit", 62), " ")
+	for _, want := range []string{"-o /m/in.mkv.bench-2.mkv", "--start-at duration:1770", "--stop-at duration:60", "--encoder x265_10bit", "--encoder-preset slow", "--quality 62"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("segmentArgs() = %q, missing %q", args, want)
+		}
+	}
+	if kind := ArtifactKind("in.mkv.bench-2.mkv"); kind != ArtifactWork {
+		t.Errorf("ArtifactKind() of a benchmark encode = %q, want %q", kind, ArtifactWork)
+	}
+}
+
+func TestCompareQuality(t *testing.T) {
+	starts := qualitySampleStarts(600, 3, 20)
+	if want := []float64{140, 290, 440}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("qualitySampleStarts() = %v, want %v", starts, want)
+	}
+	if starts := qualitySampleStarts(50, 3, 20); !reflect.DeepEqual(starts, []float64{0}) {
+		t.Errorf("qualitySampleStarts() of a short file = %v, want [0]", starts)
+	}
+
+	videoInfo := &lib.VideoInfo{Width: 1920, Height: 1080}
+	got := strings.Join(qualityCompareArgs("/m/in.mkv", "/m/out.mkv", videoInfo, 3740, 140, 20, []string{MetricSSIM, MetricVMAF}), " ")
+	want := "-hide_banner -nostats -nostdin -ss 140.000 -t 20.000 -i /m/out.mkv -ss 3740.000 -t 20.000 -i /m/in.mkv -lavfi " +
+		"[0:v]scale=1920:1080:flags=bicubic,format=yuv420p,setpts=PTS-STARTPTS,split=2[distorted0][distorted1];" +
+		"[1:v]format=yuv420p,setpts=PTS-STARTPTS,split=2[reference0][reference1];" +
+		"[distorted0][reference0]ssim;[distorted1][reference1]libvmaf=n_threads=2 -f null -"
+	if got != want {
+		t.Errorf("qualityCompareArgs() =\n%s\nwant\n%s", got, want)
+	}
+
+	output := `[Parsed_psnr_0 @ 0x1] PSNR y:44.10 u:47.20 v:47.90 average:45.03 min:38.21 max:52.90
+[Parsed_ssim_1 @ 0x2] SSIM Y:0.987612 (19.071) U:0.991 (20.5) V:0.992 (20.9) All:0.989100 (19.62)
+[Parsed_libvmaf_2 @ 0x3] VMAF score: 95.671234`
+	scores, err := parseQualityScores(output, QualityMetrics)
+	if err != nil {
+		t.Fatalf("parseQualityScores() error = %v", err)
+	}
+	if want := map[string]float64{MetricPSNR: 45.03, MetricSSIM: 0.9891, MetricVMAF: 95.671234}; !reflect.DeepEqual(scores, want) {
+		t.Errorf("parseQualityScores() = %v, want %v", scores, want)
+	}
+	if scores, _ := parseQualityScores("PSNR y:inf u:inf v:inf average:inf min:inf max:inf", []string{MetricPSNR}); scores[MetricPSNR] != maxPSNR {
+		t.Errorf("parseQualityScores() of identical frames = %v, want PSNR %d", scores, maxPSNR)
+	}
+	if _, err := parseQualityScores(output, []string{MetricPSNR, "other"}); err == nil {
+		t.Error("expected an error for a metric missing from the output")
+	}
+
+	result := BatchResult{Files: []lib.FileOutcome{
+		{File: "a.mkv", Quality: map[string]float64{MetricVMAF: 96, MetricSSIM: 0.99}},
+		{File: "b.mkv", Quality: map[string]float64{MetricVMAF: 92, MetricSSIM: 0.98}},
+		{File: "c.mkv"},
+	}}
+	means, compared := meanQuality(result.Files)
+	if strings.Join(means, ", ") != "ssim 0.9850, vmaf 94.00" || compared != 2 {
+		t.Errorf("meanQuality() = %v over %d files", means, compared)
+	}
+}
+
+func TestEncodeLog(t *testing.T) {
+	now := time.Date(2026, 10, 16, 11, 12, 50, 0, time.Local)
+	if got := encodeLogPath("/logs", "/media/Movie.mkv", now); got != "/logs/Movie.mkv.20261016-111250.log.gz" {
+		t.Errorf("encodeLogPath() = %q", got)
+	}
+
+	// Without a log in the context, runs are not saved
+	if output, err := combinedOutput(context.Background(), exec.Command("sh", "-c", "echo plain")); err != nil || string(output) != "plain\n" {
+		t.Errorf("combinedOutput() without a log = %q, %v", output, err)
+	}
+
+	log := &encodeLog{path: filepath.Join(t.TempDir(), "logs", "a.mkv.log.gz")}
+	ctx := withEncodeLog(context.Background(), log)
+	if output, err := combinedOutput(ctx, exec.Command("sh", "-c", "echo first; echo oops >&2")); err != nil || string(output) != "first\noops\n" {
+		t.Errorf("combinedOutput() = %q, %v", output, err)
+	}
+	if _, err := combinedOutput(ctx, exec.Command("sh", "-c", "printf second; exit 3")); err == nil {
+		t.Error("expected the failing run's error")
+	}
+
+	// Each run is its own gzip member, read back as one log
+	file, err := os.Open(log.path)
+	if err != nil {
+		t.Fatalf("encode log not written: %v", err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("encode log is not gzip: %v", err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read encode log: %v", err)
+	}
+	text := string(data)
+	for _, want := range []string{"sh -c echo first; echo oops >&2\nfirst\noops\n=== exit: ok after ", "second\n=== exit: exit status 3 after "} {
+		if !strings.Contains(text, want) {
+			t.Errorf("encode log missing %q:\n%s", want, text)
+		}
+	}
+	if strings.Index(text, "first") > strings.Index(text, "second") {
+		t.Errorf("encode log runs out of order:\n%s", text)
+	}
+}
+
+func TestRunHandBrakeCLIReadsFinalError(t *testing.T) {
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor i in $(seq 1 500); do echo \"[12:00:00] libhb: line $i\" >&2; done\necho 'mkv: ERROR: write failed: No space left on device' >&2\nexit 4\n"
+	if err := os.WriteFile(filepath.Join(bin, "HandBrakeCLI"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write HandBrakeCLI: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("MEDIA_MGMT_TOOLS_DIR", t.TempDir())
+
+	// The error is the last thing HandBrake prints, so it must be read before the run ends
+	transcoder := &HandBrakeTranscoder{HideProgressBar: true}
+	for run := 0; run < 5; run++ {
+		err := transcoder.runHandBrakeCLI(context.Background(), []string{"-i", "in.mkv"})
+		var classified *HandBrakeError
+		if !errors.As(err, &classified) || classified.Kind != FailureDiskFull {
+			t.Fatalf("run %d: runHandBrakeCLI() = %v, want a disk_full failure", run, err)
+		}
+	}
+}
+
+func TestVerifyOutputFailure(t *testing.T) {
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho '[hevc] error while decoding MB 7 2' >&2\n"
+	if err := os.WriteFile(filepath.Join(bin, "ffmpeg"), []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write ffmpeg: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("MEDIA_MGMT_TOOLS_DIR", t.TempDir())
+
+	dir := t.TempDir()
+	history := lib.NewHistoryStore(filepath.Join(dir, "history.jsonl"))
+	transcoder := &HandBrakeTranscoder{Verify: VerifyDecode, History: history, HideProgressBar: true}
+	source := filepath.Join(dir, "show.mkv")
+	transcoder.setProgressStage(source, 1, 1, StageDone)
+
+	// Both titles of a split file fail, but the file only counts once
+	for title := 1; title <= 2; title++ {
+		transcoder.verifyOutput(context.Background(), verifyJob{source: source, output: titleOutputPath(filepath.Join(dir, "show-optimized.mkv"), title)})
+	}
+	result := transcoder.Result()
+	if result.Transcoded != 0 || result.Failed != 1 || len(result.Failures) != 1 || result.Files[0].Outcome != StageFailed {
+		t.Errorf("Expected the file to count as failed once, got %d transcoded, %d failed, %d failures", result.Transcoded, result.Failed, len(result.Failures))
+	}
+	if failed, err := history.RetryableFailures(time.Time{}); err != nil || len(failed) != 1 || failed[0] != source {
+		t.Errorf("RetryableFailures() = %v, %v, want the file", failed, err)
+	}
+}
+
+func TestRecordTranscodeMediaInfo(t *testing.T) {
+	dir := t.TempDir()
+	history := lib.NewHistoryStore(filepath.Join(dir, "history.jsonl"))
+	transcoder := &HandBrakeTranscoder{History: history}
+	before := &lib.MediaInfo{FilePath: "/m/movie.mkv", VideoCodec: "h264"}
+	after := &lib.MediaInfo{FilePath: "/m/movie-optimized.mkv", VideoCodec: "hevc"}
+	transcoder.recordTranscode(before.FilePath, after.FilePath, before, after, &lib.VideoInfo{}, "x265", 100, time.Second, nil)
+
+	records, err := history.Records()
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Records() = %v, %v, want one record", records, err)
+	}
+	if records[0].Before == nil || records[0].Before.VideoCodec != "h264" || records[0].After == nil || records[0].After.VideoCodec != "hevc" {
+		t.Errorf("Expected the given media info to be recorded, got before %+v, after %+v", records[0].Before, records[0].After)
+	}
+}
+
+func TestCheckDolbyVisionBase(t *testing.T) {
+	profile5 := &lib.VideoInfo{HDR: &lib.HDRInfo{Format: lib.HDRFormatDolbyVision, DVProfile: 5}}
+	err := checkDolbyVisionBase("/m/movie.mkv", profile5)
+	if err == nil || !strings.Contains(err.Error(), "/m/movie.mkv") || !strings.Contains(err.Error(), "Dolby Vision 5") {
+		t.Errorf("checkDolbyVisionBase() = %v, want an error naming the file and format", err)
+	}
+
+	profile8 := &lib.VideoInfo{HDR: &lib.HDRInfo{Format: lib.HDRFormatDolbyVision, DVProfile: 8, DVCompatibilityID: 1}}
+	if err := checkDolbyVisionBase("/m/movie.mkv", profile8); err != nil {
+		t.Errorf("checkDolbyVisionBase() with an HDR10 base layer = %v, want nil", err)
+	}
+}
+
+func TestClassifyHandBrake(t *testing.T) {
+	exitStatus := func(code int) error {
+		return exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+	}
+	tests := []struct {
+		name      string
+		err       error
+		output    string
+		kind      string
+		permanent bool
+		message   string
+	}{
+		{"no title", exitStatus(3), "[12:00:01] libhb: scan thread found 0 valid title(s)\nNo title found.\n", FailureNoTitle, true, "[12:00:01] libhb: scan thread found 0 valid title(s)"},
+		{"drm", exitStatus(3), "libdvdread: Encrypted DVD support unavailable.\n", FailureDRM, true, "libdvdread: Encrypted DVD support unavailable."},
+		{"disk full over decode errors", exitStatus(4), "[h264] error while decoding MB 3 4\nmkv: ERROR: write failed: No space left on device\n", FailureDiskFull, false, "mkv: ERROR: write failed: No space left on device"},
+		{"corrupt input", exitStatus(4), "[mov,mp4] moov atom not found\n", FailureCorruptInput, true, "[mov,mp4] moov atom not found"},
+		{"invalid input", exitStatus(2), "", FailureInvalidInput, false, ""},
+		{"decode errors", exitStatus(4), "[hevc] hardware accelerator failed to decode picture\n[hevc] error while decoding MB 7 2\n", FailureUnknown, false, ""},
+		{"early warnings", exitStatus(4), "[mkv] corrupt block, moov atom not found in side data\n" + strings.Repeat("Encoding: task 1 of 1, 50.00 %\n", failureLines) + "Encode failed (error 4).\n", FailureUnknown, false, ""},
+		{"unknown", exitStatus(4), "Encode done!\n", FailureUnknown, false, ""},
+		{"crashed", exec.Command("sh", "-c", "kill -9 $$").Run(), "", FailureCrashed, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := fmt.Errorf("encode failed: %w", classifyHandBrake(tt.err, tt.output))
+			var classified *HandBrakeError
+			if !errors.As(err, &classified) {
+				t.Fatalf("classifyHandBrake() = %v, want a *HandBrakeError", err)
+			}
+			if classified.Kind != tt.kind || classified.Message != tt.message {
+				t.Errorf("classifyHandBrake() = %q, %q, want %q, %q", classified.Kind, classified.Message, tt.kind, tt.message)
+			}
+			if kind, permanent := failureClass(err); kind != tt.kind || permanent != tt.permanent {
+				t.Errorf("failureClass() = %q, %v, want %q, %v", kind, permanent, tt.kind, tt.permanent)
+			}
+		})
+	}
+
+	if err := classifyHandBrake(nil, "ERROR: ignored"); err != nil {
+		t.Errorf("classifyHandBrake() of a successful run = %v", err)
+	}
+	if kind, permanent := failureClass(&permanentError{reason: "dv_no_base_layer", err: errors.New("no base layer")}); kind != "dv_no_base_layer" || !permanent {
+		t.Errorf("failureClass() of a permanent error = %q, %v", kind, permanent)
+	}
+	if kind, _ := failureClass(errors.New("ffprobe failed")); kind != "" {
+		t.Errorf("failureClass() of an unclassified error = %q", kind)
+	}
+
+	var tail outputTail
+	tail.Write([]byte(strings.Repeat("x", failureOutputLimit) + "\nlast line\n"))
+	if got := tail.String(); got != "last line\n" {
+		t.Errorf("outputTail kept %d bytes, want only the last line", len(got))
+	}
+}
+
+func TestSoftwareFallback(t *testing.T) {
+	hdr := &lib.VideoInfo{IsHDR: true}
+	crashed := fmt.Errorf("failed to execute transcode: %w", &HandBrakeError{Kind: FailureCrashed, Err: errors.New("signal: segmentation fault")})
+	tests := []struct {
+		name     string
+		fallback bool
+		encoder  string
+		err      error
+		want     string
+	}{
+		{name: "hardware failure", fallback: true, encoder: "vt_h265_10bit", err: crashed, want: "x265_10bit"},
+		{name: "disabled", encoder: "vt_h265_10bit", err: crashed},
+		{name: "succeeded", fallback: true, encoder: "vt_h265_10bit"},
+		{name: "software encoder", fallback: true, encoder: "x265_10bit", err: crashed},
+		{name: "corrupt source", fallback: true, encoder: "qsv_h265", err: &HandBrakeError{Kind: FailureCorruptInput, Err: errors.New("exit status 3")}},
+		{name: "disk full", fallback: true, encoder: "qsv_h265", err: &HandBrakeError{Kind: FailureDiskFull, Err: errors.New("exit status 4")}},
+		{name: "not a HandBrake failure", fallback: true, encoder: "qsv_h265", err: errors.New("failed to join chunks")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcoder := &HandBrakeTranscoder{FallbackSoftware: tt.fallback}
+			got, ok := transcoder.softwareFallback(context.Background(), tt.encoder, hdr, tt.err)
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("softwareFallback() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := (&HandBrakeTranscoder{FallbackSoftware: true}).softwareFallback(ctx, "vt_h265", hdr, crashed); ok {
+		t.Error("softwareFallback() retried a cancelled encode")
+	}
+
+	// VideoToolbox presets such as quality are not x265 presets, so the retry leaves them out
+	transcoder := &HandBrakeTranscoder{FallbackSoftware: true, EncoderPreset: "quality", EncoderProfile: "main10"}
+	videoInfo := &lib.VideoInfo{IsHDR: true, AudioTracks: []lib.AudioTrack{{Index: 0}}}
+	args := strings.Join(transcoder.transcodeArgs(context.Background(), "/m/in.mkv", "/m/out.mkv", videoInfo, nil, nil, "vt_h265_10bit"), " ")
+	if !strings.Contains(args, "--encoder-preset quality --encoder-profile main10") {
+		t.Errorf("transcodeArgs() = %q, want the encoder options for the hardware encoder", args)
+	}
+	args = strings.Join(transcoder.transcodeArgs(withSoftwareFallback(context.Background()), "/m/in.mkv", "/m/out.mkv", videoInfo, nil, nil, "x265_10bit"), " ")
+	if strings.Contains(args, "--encoder-preset") || strings.Contains(args, "--encoder-profile") || !strings.Contains(args, "--encoder x265_10bit") {
+		t.Errorf("transcodeArgs() = %q, want the software encoder without the encoder options", args)
+	}
+}
+
+func TestAbortCurrentFile(t *testing.T) {
+	transcoder := &HandBrakeTranscoder{}
+	if transcoder.AbortCurrentFile() {
+		t.Error("AbortCurrentFile() = true with no file being processed")
+	}
+
+	batch, cancelBatch := context.WithCancel(context.Background())
+	defer cancelBatch()
+	ctx, done := transcoder.abortableFile(batch, "/m/show.mkv")
+	if transcoder.batch.abortPath != "/m/show.mkv" {
+		t.Errorf("abortableFile() is stopping %q", transcoder.batch.abortPath)
+	}
+	if !transcoder.AbortCurrentFile() {
+		t.Error("AbortCurrentFile() = false while a file is being processed")
+	}
+	if ctx.Err() == nil || !fileAborted(ctx) {
+		t.Errorf("file context not aborted: err %v, cause %v", ctx.Err(), context.Cause(ctx))
+	}
+	if batch.Err() != nil {
+		t.Error("aborting the file cancelled the batch")
+	}
+	done()
+	if transcoder.AbortCurrentFile() {
+		t.Error("AbortCurrentFile() = true once the file was finished with")
+	}
+
+	ctx, done = transcoder.abortableFile(batch, "/m/show.mkv")
+	defer done()
+	cancelBatch()
+	if ctx.Err() == nil || fileAborted(ctx) {
+		t.Errorf("cancelling the batch: err %v, aborted %v", ctx.Err(), fileAborted(ctx))
+	}
+
+	// An aborted split file loses the titles already written and their extracted subtitles
+	dir := t.TempDir()
+	mp4 := &HandBrakeTranscoder{Container: ContainerMP4, ContainerFallback: ContainerFallbackExtract}
+	prepared := &preparedFile{videoInfo: &lib.VideoInfo{SubtitleTracks: []lib.SubtitleTrack{{Index: 2, Codec: "hdmv_pgs_subtitle", Language: "eng"}}}}
+	output := filepath.Join(dir, "show-optimized.mp4")
+	written := []string{titleOutputPath(output, 1), filepath.Join(dir, "show-optimized-title1.en.sup"), titleOutputPath(output, 2)}
+	for _, path := range written {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mp4.removeTitleOutputs(prepared, output, 2)
+	for _, path := range written {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestDashboardReadKeysStops(t *testing.T) {
+	in, keys, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer in.Close()
+	defer keys.Close()
+
+	dashboard := NewDashboard(&HandBrakeTranscoder{}, os.Stdout)
+	dashboard.in = in
+	dashboard.stop = make(chan struct{})
+	dashboard.keysDone = make(chan struct{})
+	go dashboard.readKeys()
+
+	// With the batch running and no file to abort, a key press is read and ignored
+	if _, err := keys.Write([]byte{keyAbortFile}); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	time.Sleep(2 * keyPollInterval)
+
+	close(dashboard.stop)
+	select {
+	case <-dashboard.keysDone:
+	case <-time.After(5 * keyPollInterval):
+		t.Fatal("Expected readKeys to stop without waiting for another key press")
+	}
+
+	// A key typed after the dashboard stopped is left for the next reader of the terminal
+	if _, err := keys.Write([]byte("y")); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	next := make([]byte, 1)
+	if _, err := in.Read(next); err != nil || next[0] != 'y' {
+		t.Errorf("Expected the next reader to get the key, got %q, %v", next, err)
+	}
 }
@@ -0,0 +1,107 @@
+package handbrake
+
+import (
+	"log/slog"
+	"media-mgmt/lib"
+	"slices"
+	"strings"
+)
+
+// Hardware decoding options
+const (
+	HWDecodeAuto         = "auto"         // Decode with the hardware of the selected encoder, when it is hardware (default)
+	HWDecodeVideoToolbox = "videotoolbox" // Apple VideoToolbox
+	HWDecodeNVDec        = "nvdec"        // NVIDIA NVDEC
+	HWDecodeVAAPI        = "vaapi"        // VAAPI on Linux, which HandBrakeCLI cannot decode with
+	HWDecodeOff          = "off"          // Decode in software
+
+	hwDecodeQSV = "qsv" // Intel Quick Sync, chosen by auto for low-power Quick Sync encodes
+)
+
+// ffmpegHWAccels are ffmpeg's -hwaccel names for the hardware decoding options
+var ffmpegHWAccels = map[string]string{
+	HWDecodeVideoToolbox: "videotoolbox",
+	HWDecodeNVDec:        "cuda",
+	HWDecodeVAAPI:        "vaapi",
+	hwDecodeQSV:          "qsv",
+}
+
+// hwDecodeMethod resolves HWDecode to the decoding to ask for: the hardware matching the
+// selected encoder with auto, so sources decode on the same chip they encode on. Returns "" to
+// decode in software.
+func (t *HandBrakeTranscoder) hwDecodeMethod(hasVideoToolbox bool) string {
+	switch t.HWDecode {
+	case "", HWDecodeAuto:
+		encoder := t.selectEncoder(&lib.VideoInfo{}, hasVideoToolbox)
+		switch {
+		case strings.HasPrefix(encoder, "vt_"):
+			return HWDecodeVideoToolbox
+		case strings.HasPrefix(encoder, "qsv_"):
+			return hwDecodeQSV
+		}
+		return ""
+	case HWDecodeOff:
+		return ""
+	}
+	return t.HWDecode
+}
+
+// detectHWDecode chooses, once per Run, the hardware decoding HandBrakeCLI and ffmpeg are
+// given. Each tool only gets a method its build supports; decoding a method was explicitly
+// asked for but a tool lacks is logged, and that tool decodes in software.
+func (t *HandBrakeTranscoder) detectHWDecode(hasVideoToolbox bool) {
+	t.hwDecoder, t.hwAccel = "", ""
+	method := t.hwDecodeMethod(hasVideoToolbox)
+	if method == "" {
+		return
+	}
+	explicit := t.HWDecode != "" && t.HWDecode != HWDecodeAuto
+
+	if handBrake := t.capabilities.Tool("HandBrakeCLI"); handBrake != nil && !t.usesAVFoundation() {
+		if slices.Contains(handBrake.HWDecoders, method) {
+			t.hwDecoder = method
+		} else if explicit {
+			slog.Warn("HandBrakeCLI cannot decode with this hardware, decoding in software", "hw_decode", method)
+		}
+	}
+	if ffmpeg := t.capabilities.Tool("ffmpeg"); ffmpeg != nil {
+		if slices.Contains(ffmpeg.HWDecoders, ffmpegHWAccels[method]) {
+			t.hwAccel = ffmpegHWAccels[method]
+		} else if explicit {
+			slog.Warn("ffmpeg cannot decode with this hardware, decoding in software", "hw_decode", method)
+		}
+	}
+	if t.hwDecoder != "" || t.hwAccel != "" {
+		slog.Info("Hardware decoding", "handbrake", t.hwDecoder, "ffmpeg", t.hwAccel)
+	}
+}
+
+// hwDecodeArgs returns the HandBrakeCLI arguments that decode sources in hardware for an encode
+// with encoder, if any. With auto, decoding follows the encoder onto the hardware, so encodes
+// that use a software encoder, such as a fallback from a failed hardware encode, decode in
+// software too.
+func (t *HandBrakeTranscoder) hwDecodeArgs(encoder string) []string {
+	if t.hwDecoder == "" {
+		return nil
+	}
+	if (t.HWDecode == "" || t.HWDecode == HWDecodeAuto) && !lib.IsHardwareEncoder(encoder) {
+		return nil
+	}
+	return []string{"--enable-hw-decoding", t.hwDecoder}
+}
+
+// withHWAccel has ffmpeg decode each input of args with hwaccel, leaving args unchanged if it
+// is empty. Decoded frames are copied back to memory, so filters such as libvmaf still work.
+func withHWAccel(args []string, hwaccel string) []string {
+	if hwaccel == "" {
+		return args
+	}
+	var withAccel []string
+	for _, arg := range args {
+		if arg == "-i" {
+			withAccel = append(withAccel, "-hwaccel", hwaccel)
+		}
+		withAccel = append(withAccel, arg)
+	}
+	return withAccel
+}
@@ -0,0 +1,232 @@
+package handbrake
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// errSelectionQuit is returned by promptSelection when the user quits instead of starting the batch
+var errSelectionQuit = errors.New("file selection quit")
+
+// plannedFile is a file reviewed before an interactive batch, with what its encode is expected
+// to produce
+type plannedFile struct {
+	path          string
+	size          int64
+	codec         string  // Video codec ("" if the file could not be analyzed)
+	estimatedSize int64   // Estimated output size (0 without size estimation)
+	seconds       float64 // Predicted encode time from history (0 if there is none)
+	selected      bool
+}
+
+// plannedEstimate returns the output size estimated for a file while planning the batch
+func (t *HandBrakeTranscoder) plannedEstimate(filePath string) (int64, bool) {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	size, ok := t.estimates[filePath]
+	return size, ok
+}
+
+// recordEstimate keeps a file's estimated output size while planning an interactive batch, so
+// the encode does not estimate it again
+func (t *HandBrakeTranscoder) recordEstimate(filePath string, size int64) {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	if t.estimates != nil {
+		t.estimates[filePath] = size
+	}
+}
+
+// selectFiles analyzes and estimates the files, then asks which of them to encode. Files the
+// batch would skip anyway are left out of the list. Returns the files to encode and those that
+// were skipped or deselected, in batch order. Returns errSelectionQuit if the user quits.
+func (t *HandBrakeTranscoder) selectFiles(ctx context.Context, files []string, hasVideoToolbox bool) (selected, skipped []string, err error) {
+	t.estimates = make(map[string]int64)
+
+	var planned []*plannedFile
+	for i, file := range files {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+		slog.Info("Reviewing file", "current", i+1, "total", len(files), "file", filepath.Base(file))
+		plan, skip := t.planFile(ctx, file, hasVideoToolbox)
+		if skip {
+			skipped = append(skipped, file)
+			continue
+		}
+		planned = append(planned, plan)
+	}
+	if len(planned) == 0 {
+		return nil, skipped, nil
+	}
+
+	if err := promptSelection(planned, os.Stdin, os.Stdout); err != nil {
+		return nil, nil, err
+	}
+	for _, plan := range planned {
+		if plan.selected {
+			selected = append(selected, plan.path)
+		} else {
+			slog.Info("File deselected, skipping", "file", filepath.Base(plan.path), "stage", StageSkipped)
+			skipped = append(skipped, plan.path)
+		}
+	}
+	return selected, skipped, nil
+}
+
+// reportDeselected records the files left out during selection as skipped, numbered by their
+// place in the batch
+func (t *HandBrakeTranscoder) reportDeselected(files, skipped []string) {
+	deselected := make(map[string]bool, len(skipped))
+	for _, file := range skipped {
+		deselected[file] = true
+	}
+	for i, file := range files {
+		if deselected[file] {
+			t.setProgressStage(file, i+1, len(files), StageSkipped)
+		}
+	}
+}
+
+// planFile gathers what the selection list shows about a file: its size and codec, and unless
+// the batch skips it, its estimated output size and encode time. Skip decisions are recorded as
+// they are when encoding.
+func (t *HandBrakeTranscoder) planFile(ctx context.Context, file string, hasVideoToolbox bool) (*plannedFile, bool) {
+	plan := &plannedFile{path: file, selected: true}
+	if !t.Overwrite {
+		if output, ok := t.existingOutput(file); ok {
+			slog.Info("Output file already exists, skipping", "file", output, "stage", StageSkipped)
+			t.recordAction(file, lib.HistoryActionSkipped, map[string]string{"reason": "output_exists", "output_path": output})
+			return nil, true
+		}
+	}
+	if t.MaxSizeRatio > 0.0 && t.checkSkipFile(file) {
+		slog.Info("Skipping media with skip file", "file", filepath.Base(file), "stage", StageSkipped)
+		t.recordAction(file, lib.HistoryActionSkipped, map[string]string{"reason": "skip_file"})
+		return nil, true
+	}
+
+	// Files that cannot be analyzed stay in the list and fail when encoded, as without review
+	if info, err := os.Stat(file); err == nil {
+		plan.size = info.Size()
+	}
+	if mediaInfo, err := lib.NewMediaAnalyzer().AnalyzeFile(ctx, file); err == nil {
+		plan.codec = mediaInfo.VideoCodec
+	}
+	videoInfo, err := lib.GetVideoInfo(file)
+	if err != nil || plan.size == 0 {
+		return plan, false
+	}
+	plan.seconds, _ = t.predictEncodeSeconds(videoInfo, t.selectEncoder(videoInfo, hasVideoToolbox))
+
+	if t.MaxSizeRatio > 0.0 {
+		shouldSkip, err := t.checkSizeSavings(withQuietHandBrake(ctx), file, plan.size, videoInfo, hasVideoToolbox)
+		if err != nil {
+			slog.Warn("Size check failed, proceeding with full encode", "file", file, "error", err)
+		} else if shouldSkip {
+			return nil, true
+		}
+		plan.estimatedSize, _ = t.plannedEstimate(file)
+	}
+	return plan, false
+}
+
+// promptSelection shows the planned files and lets the user toggle them until they start the
+// batch. Returns errSelectionQuit if the user quits.
+func promptSelection(planned []*plannedFile, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	for {
+		writeSelection(planned, out)
+		fmt.Fprint(out, "Toggle files by number or range (e.g. 2 4-6), [a]ll, [n]one, Enter to start, or [q]uit: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return fmt.Errorf("no selection made: %w", err)
+		}
+
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		switch answer {
+		case "":
+			return nil
+		case "q", "quit":
+			return errSelectionQuit
+		case "a", "all", "n", "none":
+			for _, plan := range planned {
+				plan.selected = answer[0] == 'a'
+			}
+			continue
+		}
+		if err := toggleSelection(planned, answer); err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+		}
+	}
+}
+
+// toggleSelection flips the files numbered in answer, such as "2 4-6" or "1,3"
+func toggleSelection(planned []*plannedFile, answer string) error {
+	var toggle []int
+	for _, field := range strings.FieldsFunc(answer, func(r rune) bool { return r == ' ' || r == ',' }) {
+		first, last, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(first)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(last)
+		}
+		if err != nil || start < 1 || end > len(planned) || start > end {
+			return fmt.Errorf("%q is not a file number or range between 1 and %d", field, len(planned))
+		}
+		for i := start; i <= end; i++ {
+			toggle = append(toggle, i-1)
+		}
+	}
+	for _, i := range toggle {
+		planned[i].selected = !planned[i].selected
+	}
+	return nil
+}
+
+// writeSelection lists the planned files with their selection state and the expected savings
+func writeSelection(planned []*plannedFile, out io.Writer) {
+	table := lib.NewTable("", "#", "FILE", "SIZE", "CODEC", "ESTIMATE", "SAVINGS", "TIME")
+	var count int
+	var before, after int64
+	for i, plan := range planned {
+		mark := "[ ]"
+		if plan.selected {
+			mark = "[x]"
+			count++
+		}
+		codec, estimate, savings, seconds := "-", "-", "-", "-"
+		if plan.codec != "" {
+			codec = plan.codec
+		}
+		if plan.estimatedSize > 0 && plan.size > 0 {
+			estimate = lib.FormatSize(plan.estimatedSize)
+			savings = fmt.Sprintf("%.0f%%", 100*(1-float64(plan.estimatedSize)/float64(plan.size)))
+			if plan.selected {
+				before += plan.size
+				after += plan.estimatedSize
+			}
+		}
+		if plan.seconds > 0 {
+			seconds = lib.FormatDuration(plan.seconds)
+		}
+		table.AddRow(mark, strconv.Itoa(i+1), filepath.Base(plan.path), lib.FormatSize(plan.size), codec, estimate, savings, seconds)
+	}
+
+	fmt.Fprintln(out)
+	table.Render(out, lib.TableFormatText, false)
+	summary := fmt.Sprintf("%d of %d files selected", count, len(planned))
+	if before > 0 {
+		summary += fmt.Sprintf(", estimated to save %s of %s", lib.FormatSize(before-after), lib.FormatSize(before))
+	}
+	fmt.Fprintln(out, summary)
+}
@@ -0,0 +1,60 @@
+package handbrake
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+)
+
+// detectLowPowerQSV reports whether low-power mode can encode with Intel Quick Sync, which
+// HandBrakeCLI exposes on Linux and Windows builds with QSV support
+func (t *HandBrakeTranscoder) detectLowPowerQSV() bool {
+	if !t.LowPower || runtime.GOOS == "darwin" {
+		return false
+	}
+	handBrake := t.capabilities.Tool("HandBrakeCLI")
+	return handBrake != nil && handBrake.HasEncoder("qsv_h265")
+}
+
+// lowPowerThreads is the encoder thread cap in low-power mode, half the cores unless configured
+func (t *HandBrakeTranscoder) lowPowerThreads() int {
+	if t.LowPowerThreads > 0 {
+		return t.LowPowerThreads
+	}
+	return max(runtime.NumCPU()/2, 1)
+}
+
+// lowPowerArgs returns the HandBrakeCLI arguments that make encoder run cooler and quieter:
+// Quick Sync's fixed-function low-power path, VideoToolbox's speed-prioritized preset, which
+// keeps the media engine's clocks down, or a thread pool cap for x265. A preset given in
+// EncoderPreset takes the place of VideoToolbox's.
+func (t *HandBrakeTranscoder) lowPowerArgs(encoder string) []string {
+	if !t.LowPower {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(encoder, "qsv_"):
+		return []string{"--encopts", "lowpower=1"}
+	case strings.HasPrefix(encoder, "vt_") && t.EncoderPreset == "":
+		return []string{"--encoder-preset", "speed"}
+	case strings.HasPrefix(encoder, "x265"):
+		return []string{"--encopts", fmt.Sprintf("pools=%d", t.lowPowerThreads())}
+	}
+	return nil
+}
+
+// logLowPower describes the low-power settings chosen for this run
+func (t *HandBrakeTranscoder) logLowPower(hasVideoToolbox bool) {
+	if !t.LowPower {
+		return
+	}
+	switch {
+	case t.lowPowerQSV:
+		slog.Info("Low-power mode", "encoder", "Quick Sync low-power")
+	case hasVideoToolbox:
+		slog.Info("Low-power mode", "encoder", "VideoToolbox speed preset")
+	default:
+		slog.Info("Low-power mode", "encoder", "x265", "threads", t.lowPowerThreads())
+	}
+}
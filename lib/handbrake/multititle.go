@@ -0,0 +1,78 @@
+package handbrake
+
+import (
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"path/filepath"
+	"strings"
+)
+
+// Policies for inputs that look like several disc titles or a playlist ripped into one file
+const (
+	MultiTitleWarn  = "warn"  // Log the diagnosis and transcode the file whole (default)
+	MultiTitleSkip  = "skip"  // Log the diagnosis and leave the file alone
+	MultiTitleSplit = "split" // Encode each title found by chapter to its own output, skipping files whose titles cannot be found
+)
+
+// titleOutputPath is the output of one title of a split file, such as movie-optimized-title2.mkv
+func titleOutputPath(outputPath string, title int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s-title%d%s", strings.TrimSuffix(outputPath, ext), title, ext)
+}
+
+// titleArgs limits a HandBrakeCLI encode to a title's chapters
+func titleArgs(title *lib.TitleRange) []string {
+	if title == nil {
+		return nil
+	}
+	return []string{"--chapters", fmt.Sprintf("%d-%d", title.FirstChapter, title.LastChapter)}
+}
+
+// checkMultiTitle diagnoses a probed file that looks like several titles in one and applies
+// MultiTitle to it. Returns true if the file should be skipped; with split, the titles to encode
+// are set on prepared.
+func (t *HandBrakeTranscoder) checkMultiTitle(prepared *preparedFile) bool {
+	diagnosis := lib.DetectMultiTitle(prepared.videoInfo)
+	if diagnosis == nil {
+		return false
+	}
+	filePath := prepared.path
+	slog.Warn("File looks like several titles in one", "file", filepath.Base(filePath), "reasons", strings.Join(diagnosis.Reasons, "; "), "titles", len(diagnosis.Titles))
+
+	switch t.MultiTitle {
+	case MultiTitleSkip:
+	case MultiTitleSplit:
+		if len(diagnosis.Titles) > 1 {
+			if output, ok := t.splitOutput(filePath, len(diagnosis.Titles)); ok && !t.Overwrite {
+				slog.Info("Output files already exist for every title, skipping", "file", output, "titles", len(diagnosis.Titles), "stage", StageSkipped)
+				t.recordAction(filePath, lib.HistoryActionSkipped, map[string]string{"reason": "output_exists", "output_path": output})
+				return true
+			}
+			for i, title := range diagnosis.Titles {
+				slog.Info("Splitting title", "file", filepath.Base(filePath), "title", i+1, "chapters", fmt.Sprintf("%d-%d", title.FirstChapter, title.LastChapter),
+					"start", lib.FormatDuration(title.Start), "length", lib.FormatDuration(title.End-title.Start))
+			}
+			prepared.titles = diagnosis.Titles
+			return false
+		}
+		slog.Warn("Chapters do not show where the titles are, so the file cannot be split", "file", filepath.Base(filePath))
+	default:
+		return false
+	}
+
+	slog.Info("Skipping file that looks like several titles", "file", filepath.Base(filePath), "stage", StageSkipped)
+	t.recordAction(filePath, lib.HistoryActionSkipped, map[string]string{"reason": "multi_title", "diagnosis": strings.Join(diagnosis.Reasons, "; ")})
+	return true
+}
+
+// titleVideoInfo describes one title of a split file for prediction and history, which use the
+// source's running time
+func titleVideoInfo(videoInfo *lib.VideoInfo, title *lib.TitleRange) *lib.VideoInfo {
+	if title == nil {
+		return videoInfo
+	}
+	info := *videoInfo
+	info.Duration = title.End - title.Start
+	return &info
+}
@@ -0,0 +1,92 @@
+package handbrake
+
+import (
+	"fmt"
+	"media-mgmt/lib"
+	"strconv"
+	"strings"
+)
+
+// lossyAudioEncoder is used for tracks that are not passed through, matching HandBrakeCLI's default
+const lossyAudioEncoder = "av_aac"
+
+// selectedAudioTracks returns the source tracks audioArgs keeps, in output order
+func (t *HandBrakeTranscoder) selectedAudioTracks(tracks []lib.AudioTrack) []lib.AudioTrack {
+	args := t.audioArgs(tracks)
+	if args[0] != "--audio" {
+		return tracks
+	}
+	var selected []lib.AudioTrack
+	for _, number := range strings.Split(args[1], ",") {
+		if n, err := strconv.Atoi(number); err == nil && n >= 1 && n <= len(tracks) {
+			selected = append(selected, tracks[n-1])
+		}
+	}
+	return selected
+}
+
+// passthroughEncoder picks the HandBrake audio encoder that keeps a lossless track lossless.
+// TrueHD, DTS-HD MA, and FLAC are copied as-is; PCM and ALAC, which Matroska passthrough does
+// not support, are re-encoded to 24-bit FLAC without loss.
+func passthroughEncoder(track lib.AudioTrack) string {
+	switch {
+	case !track.IsLossless():
+		return lossyAudioEncoder
+	case track.Codec == "truehd", track.Codec == "mlp":
+		return "copy:truehd"
+	case track.Codec == "dts":
+		return "copy:dtshd"
+	case track.Codec == "flac":
+		return "copy:flac"
+	}
+	return "flac24"
+}
+
+// audioEncoderArgs requests lossless passthrough for each kept lossless track when
+// PassthroughLossless is set. Returns nil if passthrough is off or no kept track is lossless.
+// Lossless tracks converted to fit MP4 are re-encoded like any other track.
+func (t *HandBrakeTranscoder) audioEncoderArgs(tracks []lib.AudioTrack) []string {
+	if !t.PassthroughLossless || t.convertsForMP4() {
+		return nil
+	}
+
+	selected := t.selectedAudioTracks(tracks)
+	encoders := make([]string, len(selected))
+	hasLossless := false
+	for i, track := range selected {
+		encoders[i] = passthroughEncoder(track)
+		hasLossless = hasLossless || track.IsLossless()
+	}
+	if !hasLossless {
+		return nil
+	}
+	return []string{"--aencoder", strings.Join(encoders, ","), "--audio-fallback", lossyAudioEncoder}
+}
+
+// losslessDegradations compares the kept source tracks with the output's audio tracks by position
+// and describes each lossless source track that did not come out lossless. HandBrake falls back to
+// a lossy encoder without failing when it cannot pass a track through.
+func losslessDegradations(source, output []lib.AudioTrack) []string {
+	var problems []string
+	for i, track := range source {
+		if !track.IsLossless() {
+			continue
+		}
+		if i >= len(output) {
+			problems = append(problems, fmt.Sprintf("track %d (%s) is missing from the output", i+1, describeAudioCodec(track)))
+			continue
+		}
+		if !output[i].IsLossless() {
+			problems = append(problems, fmt.Sprintf("track %d was degraded from %s to %s", i+1, describeAudioCodec(track), describeAudioCodec(output[i])))
+		}
+	}
+	return problems
+}
+
+// describeAudioCodec names a track's codec with its profile, e.g. "dts (DTS-HD MA)"
+func describeAudioCodec(track lib.AudioTrack) string {
+	if track.Profile == "" {
+		return track.Codec
+	}
+	return fmt.Sprintf("%s (%s)", track.Codec, track.Profile)
+}
@@ -0,0 +1,81 @@
+package handbrake
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+)
+
+// preparedResult is the outcome of preparing one file of a pipelined batch
+type preparedResult struct {
+	file     string
+	prepared *preparedFile
+	err      error
+}
+
+// runPipelined transcodes files in order while probing and estimating up to Lookahead of the
+// following files in the background, so each encode starts as soon as the previous one ends
+func (t *HandBrakeTranscoder) runPipelined(ctx context.Context, files []string, hasVideoToolbox bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	prepare := func(ctx context.Context, file string) (*preparedFile, error) {
+		return t.prepareFile(ctx, file, hasVideoToolbox, nil)
+	}
+	results := prepareAhead(ctx, files, t.Lookahead, prepare)
+	defer func() {
+		// Files prepared ahead of an early return still hold leases
+		cancel()
+		for result := range results {
+			result.prepared.release()
+		}
+	}()
+
+	totalFiles := len(files)
+	for i := range files {
+		var result preparedResult
+		select {
+		case <-ctx.Done():
+			slog.Info("Context cancelled, stopping file processing")
+			return ctx.Err()
+		case result = <-results:
+		}
+
+		fileNum := i + 1
+		slog.Info("Processing file", "current", fileNum, "total", totalFiles, "file", filepath.Base(result.file))
+		err := result.err
+		aborted := false
+		if err == nil {
+			fileCtx, done := t.abortableFile(ctx, result.file)
+			err = t.encodeFile(fileCtx, result.prepared, hasVideoToolbox, fileNum, totalFiles)
+			aborted = fileAborted(fileCtx)
+			done()
+		}
+		if err != nil && aborted && ctx.Err() == nil {
+			t.handleFileAborted(result.file, fileNum, totalFiles)
+		} else if err != nil && t.handleFileError(ctx, result.file, fileNum, totalFiles, err) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// prepareAhead prepares files in order on a background goroutine and delivers the results in
+// the same order. At most lookahead files are prepared beyond the one being consumed.
+func prepareAhead(ctx context.Context, files []string, lookahead int, prepare func(context.Context, string) (*preparedFile, error)) <-chan preparedResult {
+	results := make(chan preparedResult, lookahead-1)
+	go func() {
+		defer close(results)
+		for _, file := range files {
+			if ctx.Err() != nil {
+				return
+			}
+			prepared, err := prepare(ctx, file)
+			select {
+			case results <- preparedResult{file: file, prepared: prepared, err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
@@ -0,0 +1,160 @@
+package handbrake
+
+import (
+	"context"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"strconv"
+	"time"
+)
+
+// predictEncodeSeconds estimates the wall-clock encode time for a file from historical encoder speed.
+// Uses the average fps recorded for the same encoder and resolution class in the history store.
+// Returns false if there is no history or the file's frame count cannot be determined.
+func (t *HandBrakeTranscoder) predictEncodeSeconds(videoInfo *lib.VideoInfo, encoder string) (float64, bool) {
+	if t.History == nil || videoInfo.FrameRate <= 0 || videoInfo.Duration <= 0 {
+		return 0, false
+	}
+
+	fps, err := t.History.EncoderFPS(encoder, lib.ResolutionClass(videoInfo.Height))
+	if err != nil {
+		slog.Debug("Failed to read encoder speed history", "error", err)
+		return 0, false
+	}
+	if fps <= 0 {
+		return 0, false
+	}
+
+	frames := videoInfo.Duration * videoInfo.FrameRate
+	return frames / fps, true
+}
+
+// logBatchPrediction logs an up-front ETA for the whole batch based on historical encoder speed.
+// Files with existing outputs or skip files are excluded since they will not be encoded.
+// Files without matching history are counted but not included in the estimate.
+func (t *HandBrakeTranscoder) logBatchPrediction(files []string, hasVideoToolbox bool) {
+	if t.History == nil {
+		return
+	}
+
+	var totalSeconds float64
+	predicted := 0
+	unpredicted := 0
+
+	for _, file := range files {
+		if !t.Overwrite {
+			if _, ok := t.existingOutput(file); ok {
+				continue
+			}
+		}
+		if t.MaxSizeRatio > 0.0 && t.checkSkipFile(file) {
+			continue
+		}
+
+		videoInfo, err := lib.GetVideoInfo(file)
+		if err != nil {
+			unpredicted++
+			continue
+		}
+
+		seconds, ok := t.predictEncodeSeconds(videoInfo, t.selectEncoder(videoInfo, hasVideoToolbox))
+		if !ok {
+			unpredicted++
+			continue
+		}
+		totalSeconds += seconds
+		predicted++
+	}
+
+	if predicted == 0 {
+		slog.Info("No encoder speed history yet, batch ETA will be available after the first encode")
+		return
+	}
+
+	slog.Info("Predicted batch encode time",
+		"eta", lib.FormatDuration(totalSeconds),
+		"predicted_files", predicted,
+		"files_without_history", unpredicted)
+}
+
+// recordTranscode stores the results of a completed encode in the history store, with its
+// quality scores against the source if it was compared. The average fps reported by HandBrake
+// is preferred; otherwise it is derived from the frame count.
+func (t *HandBrakeTranscoder) recordTranscode(inputPath, outputPath string, before, after *lib.MediaInfo, videoInfo *lib.VideoInfo, encoder string, originalSize int64, elapsed time.Duration, scores map[string]float64) {
+	if t.History == nil {
+		return
+	}
+
+	avgFPS := t.getLastAvgFPS()
+	if avgFPS <= 0 && videoInfo.FrameRate > 0 && elapsed > 0 {
+		avgFPS = videoInfo.Duration * videoInfo.FrameRate / elapsed.Seconds()
+	}
+
+	var outputSize int64
+	if info, err := os.Stat(outputPath); err == nil {
+		outputSize = info.Size()
+	}
+	params := t.timingParams(videoInfo)
+	for metric, score := range qualityParams(scores) {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[metric] = score
+	}
+
+	t.History.Record(lib.HistoryRecord{
+		FilePath:       inputPath,
+		Action:         lib.HistoryActionTranscoded,
+		Encoder:        encoder,
+		Quality:        t.qualityFor(videoInfo),
+		Width:          videoInfo.Width,
+		Height:         videoInfo.Height,
+		MediaDuration:  videoInfo.Duration,
+		ElapsedSeconds: elapsed.Seconds(),
+		AvgFPS:         avgFPS,
+		OriginalSize:   originalSize,
+		OutputSize:     outputSize,
+		OutputPath:     outputPath,
+		Params:         params,
+		Before:         before,
+		After:          after,
+	})
+}
+
+// timingParams records the source's frame timing when CFR conversion replaces it, so the original
+// characteristics survive in history even when the source is later deleted
+func (t *HandBrakeTranscoder) timingParams(videoInfo *lib.VideoInfo) map[string]string {
+	if !t.CFRConvert {
+		return nil
+	}
+	params := map[string]string{
+		"source_frame_rate": strconv.FormatFloat(videoInfo.FrameRate, 'f', 3, 64),
+		"source_vfr":        strconv.FormatBool(videoInfo.IsVFR),
+	}
+	if rate := cfrRate(videoInfo.FrameRate); rate != "" {
+		params["cfr_rate"] = rate
+	}
+	return params
+}
+
+// analyzeFile probes a file for the media info logged and stored alongside a transcode record.
+// Returns nil when the file cannot be analyzed.
+func (t *HandBrakeTranscoder) analyzeFile(ctx context.Context, path string) *lib.MediaInfo {
+	info, err := lib.NewMediaAnalyzer().AnalyzeFile(ctx, path)
+	if err != nil {
+		slog.Warn("Failed to analyze media info", "file", path, "error", err)
+		return nil
+	}
+	return info
+}
+
+// recordAction stores a non-encode action such as a skip or estimate in the history store
+func (t *HandBrakeTranscoder) recordAction(inputPath, action string, params map[string]string) {
+	t.History.Record(lib.HistoryRecord{
+		FilePath: inputPath,
+		Action:   action,
+		Quality:  t.Quality,
+		Params:   params,
+	})
+}
@@ -0,0 +1,40 @@
+package handbrake
+
+import (
+	"log/slog"
+	"media-mgmt/lib"
+	"path/filepath"
+)
+
+// presetFor returns the first of Presets that matches a file, or nil if none does
+func (t *HandBrakeTranscoder) presetFor(videoInfo *lib.VideoInfo) *lib.Preset {
+	contentType := lib.DetectContentType(videoInfo.Path, videoInfo.Duration)
+	resolution := lib.ResolutionClass(videoInfo.Height)
+	for i := range t.Presets {
+		if t.Presets[i].Matches(contentType, resolution) {
+			return &t.Presets[i]
+		}
+	}
+	return nil
+}
+
+// qualityFor returns the quality a file is encoded at: its preset's if it sets one, otherwise Quality
+func (t *HandBrakeTranscoder) qualityFor(videoInfo *lib.VideoInfo) int {
+	if preset := t.presetFor(videoInfo); preset != nil && preset.Quality > 0 {
+		return preset.Quality
+	}
+	return t.Quality
+}
+
+// logPreset logs the preset a file matched and the settings it gets from it. Presets only set
+// the quality, so nothing applies to average bitrate encodes.
+func (t *HandBrakeTranscoder) logPreset(videoInfo *lib.VideoInfo) {
+	if t.RateMode == RateModeABR {
+		return
+	}
+	if preset := t.presetFor(videoInfo); preset != nil {
+		slog.Info("Applying transcode preset", "file", filepath.Base(videoInfo.Path), "preset", preset.Name,
+			"content_type", lib.DetectContentType(videoInfo.Path, videoInfo.Duration), "resolution", lib.ResolutionClass(videoInfo.Height),
+			"quality", t.qualityFor(videoInfo))
+	}
+}
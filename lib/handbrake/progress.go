@@ -0,0 +1,87 @@
+package handbrake
+
+import (
+	"log/slog"
+	"media-mgmt/lib"
+	"strconv"
+)
+
+// Progress stages reported for each file
+const (
+	StageEstimating = "estimating"
+	StageEncoding   = "encoding"
+	StageDone       = "done"
+	StageSkipped    = "skipped"
+	StageFailed     = "failed"
+)
+
+// Progress describes the state of the file currently being processed.
+// Delivered to the OnProgress callback so callers can display or stream live status.
+type Progress struct {
+	File       string  `json:"file"`
+	FileNum    int     `json:"file_num"`
+	TotalFiles int     `json:"total_files"`
+	Stage      string  `json:"stage"`
+	Percent    float64 `json:"percent"`
+	FPS        float64 `json:"fps,omitempty"`
+	AvgFPS     float64 `json:"avg_fps,omitempty"`
+	ETA        string  `json:"eta,omitempty"`
+}
+
+// setProgressStage updates the current file and stage and notifies the progress callback.
+func (t *HandBrakeTranscoder) setProgressStage(file string, fileNum, totalFiles int, stage string) {
+	t.progressMux.Lock()
+	t.progress = Progress{
+		File:       file,
+		FileNum:    fileNum,
+		TotalFiles: totalFiles,
+		Stage:      stage,
+	}
+	if stage == StageDone {
+		t.progress.Percent = 100
+	}
+	t.countStage(file, stage)
+	progress := t.progress
+	done := len(t.result.Files)
+	eta, haveETA := t.batchETA()
+	t.progressMux.Unlock()
+
+	if isTerminalStage(stage) && haveETA && done < totalFiles {
+		slog.Info("Batch progress", "done", done, "total", totalFiles, "eta", lib.FormatDuration(eta))
+	}
+	t.emitProgress(progress)
+}
+
+// updateProgress records parsed HandBrake progress for the current stage and notifies the callback.
+// Speed and ETA arguments may be empty when HandBrake has not reported them yet.
+func (t *HandBrakeTranscoder) updateProgress(percentStr, fpsStr, avgFPSStr, eta string) {
+	t.progressMux.Lock()
+	if percent, err := strconv.ParseFloat(percentStr, 64); err == nil {
+		t.progress.Percent = percent
+	}
+	if fps, err := strconv.ParseFloat(fpsStr, 64); err == nil {
+		t.progress.FPS = fps
+	}
+	if avgFPS, err := strconv.ParseFloat(avgFPSStr, 64); err == nil {
+		t.progress.AvgFPS = avgFPS
+		t.lastAvgFPS = avgFPS
+	}
+	if eta != "" {
+		t.progress.ETA = eta
+	}
+	progress := t.progress
+	t.progressMux.Unlock()
+
+	t.emitProgress(progress)
+}
+
+// emitProgress delivers a progress snapshot to the status file and the OnProgress callback, if configured.
+func (t *HandBrakeTranscoder) emitProgress(progress Progress) {
+	if progress.File == "" {
+		return
+	}
+	t.status.update(progress)
+	if t.OnProgress != nil {
+		t.OnProgress(progress)
+	}
+}
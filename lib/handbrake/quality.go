@@ -0,0 +1,242 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Quality metrics comparing outputs to their sources
+const (
+	MetricPSNR = "psnr" // Peak signal-to-noise ratio in dB, averaged over the planes
+	MetricSSIM = "ssim" // Structural similarity, from 0 to 1
+	MetricVMAF = "vmaf" // Netflix's perceptual score, from 0 to 100
+)
+
+// QualityMetrics are the metrics --compare-quality accepts, in the order they are computed
+var QualityMetrics = []string{MetricPSNR, MetricSSIM, MetricVMAF}
+
+// qualitySampleSeconds is the length of each segment compared with --compare-quality
+const qualitySampleSeconds = 20
+
+// maxPSNR stands in for the infinite PSNR of identical frames, so scores can be averaged
+const maxPSNR = 100
+
+var (
+	// psnrPattern finds the average PSNR ffmpeg's psnr filter logs when it finishes
+	psnrPattern = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+	// ssimPattern finds the combined SSIM ffmpeg's ssim filter logs when it finishes
+	ssimPattern = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+)
+
+// qualityFilters are the ffmpeg filters that compute each metric
+var qualityFilters = map[string]string{
+	MetricPSNR: "psnr",
+	MetricSSIM: "ssim",
+	MetricVMAF: "libvmaf",
+}
+
+// compareQualityRequirement returns the ffmpeg features needed to compute metrics
+func compareQualityRequirement(metrics []string) lib.Requirement {
+	req := lib.Requirement{Feature: "--compare-quality", Tool: "ffmpeg"}
+	for _, metric := range metrics {
+		req.Filters = append(req.Filters, qualityFilters[metric])
+	}
+	return req
+}
+
+// qualitySampleStarts spreads count segments of seconds evenly over duration seconds,
+// returning where each starts. A duration too short for them all is compared whole.
+func qualitySampleStarts(duration float64, count int, seconds float64) []float64 {
+	if count < 1 || duration <= float64(count)*seconds {
+		return []float64{0}
+	}
+	starts := make([]float64, count)
+	for i := range starts {
+		starts[i] = duration*float64(i+1)/float64(count+1) - seconds/2
+	}
+	return starts
+}
+
+// qualityCompareArgs builds ffmpeg arguments that compute metrics for duration seconds of
+// output from outputStart against the source from sourceStart (a zero duration compares the
+// whole files). Both are scaled to the source's dimensions and converted to one pixel format,
+// since the filters compare frames of equal size and format.
+func qualityCompareArgs(source, output string, videoInfo *lib.VideoInfo, sourceStart, outputStart, duration float64, metrics []string) []string {
+	format := "yuv420p"
+	if videoInfo != nil && videoInfo.IsHDR {
+		format = "yuv420p10le"
+	}
+	scale := ""
+	if videoInfo != nil && videoInfo.Width > 0 && videoInfo.Height > 0 {
+		scale = fmt.Sprintf("scale=%d:%d:flags=bicubic,", videoInfo.Width, videoInfo.Height)
+	}
+	count := len(metrics)
+	filter := fmt.Sprintf("[0:v]%sformat=%s,setpts=PTS-STARTPTS,split=%d", scale, format, count)
+	for i := range metrics {
+		filter += fmt.Sprintf("[distorted%d]", i)
+	}
+	filter += fmt.Sprintf(";[1:v]format=%s,setpts=PTS-STARTPTS,split=%d", format, count)
+	for i := range metrics {
+		filter += fmt.Sprintf("[reference%d]", i)
+	}
+	for i, metric := range metrics {
+		filter += fmt.Sprintf(";[distorted%d][reference%d]%s", i, i, qualityFilters[metric])
+		if metric == MetricVMAF {
+			filter += fmt.Sprintf("=n_threads=%d", vmafThreads)
+		}
+	}
+
+	args := []string{"-hide_banner", "-nostats", "-nostdin"}
+	if duration > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", outputStart), "-t", fmt.Sprintf("%.3f", duration))
+	}
+	args = append(args, "-i", output)
+	if duration > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", sourceStart), "-t", fmt.Sprintf("%.3f", duration))
+	}
+	return append(args, "-i", source, "-lavfi", filter, "-f", "null", "-")
+}
+
+// parseQualityScores extracts each metric's pooled score from ffmpeg's log output
+func parseQualityScores(output string, metrics []string) (map[string]float64, error) {
+	scores := make(map[string]float64, len(metrics))
+	for _, metric := range metrics {
+		var matches [][]string
+		switch metric {
+		case MetricPSNR:
+			matches = psnrPattern.FindAllStringSubmatch(output, -1)
+		case MetricSSIM:
+			matches = ssimPattern.FindAllStringSubmatch(output, -1)
+		case MetricVMAF:
+			matches = vmafScorePattern.FindAllStringSubmatch(output, -1)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no %s score in ffmpeg output", strings.ToUpper(metric))
+		}
+		value := matches[len(matches)-1][1]
+		if value == "inf" {
+			scores[metric] = maxPSNR
+			continue
+		}
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s score %q: %w", strings.ToUpper(metric), value, err)
+		}
+		scores[metric] = score
+	}
+	return scores, nil
+}
+
+// compareQuality scores an output against its source with QualityMetrics on QualitySamples
+// segments spread over it, returning each metric's mean over the segments. A split title's
+// output is compared with the part of the source it was encoded from.
+func (t *HandBrakeTranscoder) compareQuality(ctx context.Context, source, output string, videoInfo *lib.VideoInfo, title *lib.TitleRange) (map[string]float64, error) {
+	offset, duration := 0.0, videoInfo.Duration
+	if title != nil {
+		offset, duration = title.Start, title.End-title.Start
+	}
+	starts := qualitySampleStarts(duration, t.QualitySamples, qualitySampleSeconds)
+	length := float64(qualitySampleSeconds)
+	if len(starts) == 1 && starts[0] == 0 {
+		length = duration
+	}
+
+	slog.Info("Comparing output quality to the source", "file", filepath.Base(output), "metrics", strings.Join(t.CompareQuality, ","), "samples", len(starts))
+	totals := make(map[string]float64)
+	for _, start := range starts {
+		args := withHWAccel(qualityCompareArgs(source, output, videoInfo, offset+start, start, length, t.CompareQuality), t.hwAccel)
+		out, err := combinedOutput(ctx, lowPriorityCommand(ctx, lib.ToolCommand("ffmpeg"), args...))
+		if err != nil {
+			return nil, fmt.Errorf("quality comparison failed: %w: %s", err, firstLines(strings.TrimSpace(string(out)), 3))
+		}
+		scores, err := parseQualityScores(string(out), t.CompareQuality)
+		if err != nil {
+			return nil, err
+		}
+		for metric, score := range scores {
+			totals[metric] += score
+		}
+	}
+	for metric := range totals {
+		totals[metric] /= float64(len(starts))
+	}
+	return totals, nil
+}
+
+// qualityAttrs lists quality scores as log attributes, in QualityMetrics order
+func qualityAttrs(scores map[string]float64) []any {
+	var attrs []any
+	for _, metric := range QualityMetrics {
+		if score, ok := scores[metric]; ok {
+			attrs = append(attrs, metric, formatQualityScore(metric, score))
+		}
+	}
+	return attrs
+}
+
+// meanQuality averages each metric over the files that were compared, returning the means in
+// QualityMetrics order as "vmaf 95.10"-style strings and the number of files
+func meanQuality(files []lib.FileOutcome) ([]string, int) {
+	totals := make(map[string]float64)
+	counts := make(map[string]int)
+	compared := 0
+	for _, file := range files {
+		if len(file.Quality) > 0 {
+			compared++
+		}
+		for metric, score := range file.Quality {
+			totals[metric] += score
+			counts[metric]++
+		}
+	}
+	var means []string
+	for _, metric := range QualityMetrics {
+		if counts[metric] > 0 {
+			means = append(means, metric+" "+formatQualityScore(metric, totals[metric]/float64(counts[metric])))
+		}
+	}
+	return means, compared
+}
+
+// qualityParams formats quality scores for a history record's params
+func qualityParams(scores map[string]float64) map[string]string {
+	params := make(map[string]string, len(scores))
+	for metric, score := range scores {
+		params[metric] = formatQualityScore(metric, score)
+	}
+	return params
+}
+
+// formatQualityScore formats a score with the precision its metric is read at
+func formatQualityScore(metric string, score float64) string {
+	if metric == MetricSSIM {
+		return strconv.FormatFloat(score, 'f', 4, 64)
+	}
+	return strconv.FormatFloat(score, 'f', 2, 64)
+}
+
+// recordQuality keeps the lowest score of each metric among a file's outputs for the batch's
+// per-file outcomes, so a split title that lost quality is not hidden by the others
+func (t *HandBrakeTranscoder) recordQuality(file string, scores map[string]float64) {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	if t.batch.qualityScores == nil {
+		return
+	}
+	lowest, ok := t.batch.qualityScores[file]
+	if !ok {
+		lowest = make(map[string]float64, len(scores))
+		t.batch.qualityScores[file] = lowest
+	}
+	for metric, score := range scores {
+		if current, ok := lowest[metric]; !ok || score < current {
+			lowest[metric] = score
+		}
+	}
+}
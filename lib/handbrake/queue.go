@@ -0,0 +1,187 @@
+package handbrake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// queueItem is one entry of a HandBrake queue file, the format read by the HandBrake GUI's
+// Import Queue and by HandBrakeCLI --queue-import-file
+type queueItem struct {
+	Job queueJob `json:"Job"`
+}
+
+// queueJob is the subset of HandBrake's JSON job description media-mgmt controls. HandBrake
+// fills in every omitted setting from its defaults.
+type queueJob struct {
+	SequenceID  int              `json:"SequenceID"`
+	Source      queueSource      `json:"Source"`
+	Destination queueDestination `json:"Destination"`
+	Video       queueVideo       `json:"Video"`
+	Audio       queueAudio       `json:"Audio"`
+	Subtitle    queueSubtitle    `json:"Subtitle"`
+}
+
+type queueSource struct {
+	Path  string      `json:"Path"`
+	Title int         `json:"Title"`
+	Angle int         `json:"Angle"`
+	Range *queueRange `json:"Range,omitempty"` // Part of the title to encode (omitted for all of it)
+}
+
+type queueRange struct {
+	Type  string `json:"Type"` // "chapter" for a range of chapters numbered from 1
+	Start int    `json:"Start"`
+	End   int    `json:"End"`
+}
+
+type queueDestination struct {
+	File           string `json:"File"`
+	Mux            string `json:"Mux"`
+	ChapterMarkers bool   `json:"ChapterMarkers"`
+}
+
+type queueVideo struct {
+	Encoder   string  `json:"Encoder"`
+	Quality   float64 `json:"Quality,omitempty"`
+	Bitrate   int     `json:"Bitrate,omitempty"`
+	MultiPass bool    `json:"MultiPass,omitempty"`
+	Turbo     bool    `json:"Turbo,omitempty"`
+	Preset    string  `json:"Preset,omitempty"`
+	Tune      string  `json:"Tune,omitempty"`
+	Profile   string  `json:"Profile,omitempty"`
+}
+
+type queueAudio struct {
+	FallbackEncoder string           `json:"FallbackEncoder"`
+	AudioList       []queueAudioItem `json:"AudioList"`
+}
+
+type queueAudioItem struct {
+	Track   int    `json:"Track"` // Source audio track, numbered from 0
+	Encoder string `json:"Encoder"`
+}
+
+type queueSubtitle struct {
+	SubtitleList []queueSubtitleItem `json:"SubtitleList"`
+}
+
+type queueSubtitleItem struct {
+	Track int `json:"Track"` // Source subtitle track, numbered from 0
+}
+
+// exportQueue plans the batch as a transcode would, skipping files that already have output or
+// would not save enough space, and writes the remaining encodes to a HandBrake queue file
+// instead of running them
+func (t *HandBrakeTranscoder) exportQueue(ctx context.Context, files []string, hasVideoToolbox bool) error {
+	var items []queueItem
+	for i, file := range files {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fileNum := i + 1
+		prepared, err := t.prepareFile(ctx, file, hasVideoToolbox, func() {
+			t.setProgressStage(file, fileNum, len(files), StageEstimating)
+		})
+		prepared.release()
+		if err != nil {
+			if t.handleFileError(ctx, file, fileNum, len(files), err) {
+				return ctx.Err()
+			}
+			continue
+		}
+		if prepared.skipped {
+			t.setProgressStage(file, fileNum, len(files), StageSkipped)
+			continue
+		}
+
+		if t.CFRConvert || t.MuxAudioSidecars || hdrMetadataArgs(prepared.videoInfo.HDR, t.selectEncoder(prepared.videoInfo, hasVideoToolbox)) != nil {
+			slog.Warn("Queue entries carry only encoder, quality, and track selection; set frame rate, HDR metadata, and sidecars in HandBrake", "file", filepath.Base(file))
+		}
+		if prepared.titles == nil {
+			items = append(items, queueItem{Job: t.queueJob(len(items)+1, file, prepared.videoInfo, hasVideoToolbox)})
+			continue
+		}
+		for i, title := range prepared.titles {
+			job := t.queueJob(len(items)+1, file, prepared.videoInfo, hasVideoToolbox)
+			job.Source.Range = &queueRange{Type: "chapter", Start: title.FirstChapter, End: title.LastChapter}
+			job.Destination.File = titleOutputPath(job.Destination.File, i+1)
+			items = append(items, queueItem{Job: job})
+		}
+	}
+
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode HandBrake queue: %w", err)
+	}
+	if err := os.WriteFile(t.ExportQueuePath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write HandBrake queue: %w", err)
+	}
+	slog.Info("Exported HandBrake queue", "file", t.ExportQueuePath, "jobs", len(items), "files", len(files))
+	return nil
+}
+
+// queueJob describes the encode executeTranscode would run for a file
+func (t *HandBrakeTranscoder) queueJob(sequenceID int, inputPath string, videoInfo *lib.VideoInfo, hasVideoToolbox bool) queueJob {
+	container := t.containerFor(videoInfo)
+	job := queueJob{
+		SequenceID:  sequenceID,
+		Source:      queueSource{Path: inputPath, Title: 1, Angle: 1},
+		Destination: queueDestination{File: t.outputPathFor(inputPath, container), Mux: containerFormat(container), ChapterMarkers: true},
+		Video:       t.queueVideo(t.selectEncoder(videoInfo, hasVideoToolbox), videoInfo),
+		Audio:       queueAudio{FallbackEncoder: lossyAudioEncoder, AudioList: []queueAudioItem{}},
+		Subtitle:    queueSubtitle{SubtitleList: []queueSubtitleItem{}},
+	}
+
+	for _, number := range trackNumbers(t.audioArgs(videoInfo.AudioTracks), len(videoInfo.AudioTracks)) {
+		encoder := lossyAudioEncoder
+		if t.PassthroughLossless {
+			encoder = passthroughEncoder(videoInfo.AudioTracks[number-1])
+		}
+		job.Audio.AudioList = append(job.Audio.AudioList, queueAudioItem{Track: number - 1, Encoder: encoder})
+	}
+	for _, number := range trackNumbers(t.subtitleArgs(videoInfo.SubtitleTracks), len(videoInfo.SubtitleTracks)) {
+		job.Subtitle.SubtitleList = append(job.Subtitle.SubtitleList, queueSubtitleItem{Track: number - 1})
+	}
+	return job
+}
+
+// trackNumbers expands HandBrakeCLI track selection arguments, such as --all-audio or
+// --subtitle 1,3, into the selected track numbers counted from 1
+func trackNumbers(args []string, count int) []int {
+	var numbers []int
+	if len(args) < 2 {
+		for n := 1; n <= count; n++ {
+			numbers = append(numbers, n)
+		}
+		return numbers
+	}
+	for _, field := range strings.Split(args[1], ",") {
+		if n, err := strconv.Atoi(field); err == nil && n >= 1 && n <= count {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// queueVideo describes a job's video encode, at constant quality or at an average bitrate, with
+// the encoder options
+func (t *HandBrakeTranscoder) queueVideo(encoder string, videoInfo *lib.VideoInfo) queueVideo {
+	video := queueVideo{Encoder: encoder, Preset: t.EncoderPreset, Tune: t.EncoderTune, Profile: t.EncoderProfile}
+	if t.RateMode == RateModeABR {
+		video.Bitrate = t.Bitrate
+		video.MultiPass = encodesTwoPass(encoder)
+		video.Turbo = video.MultiPass
+		return video
+	}
+	video.Quality = float64(t.qualityFor(videoInfo))
+	return video
+}
@@ -0,0 +1,49 @@
+package handbrake
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Rate control modes
+const (
+	RateModeQuality = "quality" // Constant quality at Quality, letting file sizes vary (default)
+	RateModeABR     = "abr"     // Average bitrate at Bitrate, for predictable file sizes
+)
+
+// twoPassEncoders are the encoder families HandBrake can run in two passes. Hardware encoders
+// make a single pass and hold the average bitrate themselves.
+var twoPassEncoders = []string{"x265", "x264", "svt_av1"}
+
+// encodesTwoPass reports whether an average bitrate encode with encoder runs in two passes
+func encodesTwoPass(encoder string) bool {
+	for _, prefix := range twoPassEncoders {
+		if strings.HasPrefix(encoder, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// rateControlArgs returns the HandBrakeCLI arguments that set how an encode spends bits:
+// constant quality, or an average bitrate, made in two passes with a fast first pass when
+// encoder supports it
+func (t *HandBrakeTranscoder) rateControlArgs(encoder string, quality int) []string {
+	if t.RateMode != RateModeABR {
+		return []string{"--quality", fmt.Sprintf("%d", quality)}
+	}
+	args := []string{"--vb", fmt.Sprintf("%d", t.Bitrate)}
+	if encodesTwoPass(encoder) {
+		args = append(args, "--multi-pass", "--turbo")
+	}
+	return args
+}
+
+// logRateControl describes an average bitrate encode; constant quality is the default and
+// is not logged
+func (t *HandBrakeTranscoder) logRateControl(encoder string) {
+	if t.RateMode == RateModeABR {
+		slog.Info("Encoding at average bitrate", "bitrate", fmt.Sprintf("%dk", t.Bitrate), "two_pass", encodesTwoPass(encoder))
+	}
+}
@@ -0,0 +1,103 @@
+package handbrake
+
+import (
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// workFilePattern matches the names of the temporary files a file's estimation, sample check,
+// chunked encode, and benchmark create next to it, such as movie.mkv.size-test-2.mkv or
+// movie.mkv.chunk-002.encoded.mkv, capturing the file's name. Each is removed once it has
+// served its purpose.
+var workFilePattern = regexp.MustCompile(`^(.+)\.(?:size-test(?:-\d+)?\.mkv|size-sample\.mkv|sample-gate\.(?:mkv|mp4)|chunk-\d{3,}(?:\.encoded)?\.mkv|bench-\d+\.(?:mkv|mp4))$`)
+
+// workFileSource returns the name of the video a work file was created for, or false if name
+// is not a work file
+func workFileSource(name string) (string, bool) {
+	matches := workFilePattern.FindStringSubmatch(name)
+	if matches == nil || !lib.IsVideoFile(matches[1]) {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// reconcile re-reads the directories the batch touched once it is done and compares what is on
+// disk with what the batch recorded: every input still in place, every transcoded file's output
+// present and non-empty, and no temporary files left behind. Discrepancies, which point to a
+// filesystem that lost or failed writes mid-run, are logged and added to the batch result.
+func (t *HandBrakeTranscoder) reconcile(files []string) {
+	outcomes := make(map[string]string)
+	for _, outcome := range t.Result().Files {
+		outcomes[outcome.File] = outcome.Outcome
+	}
+
+	listings := make(map[string][]string)
+	list := func(dir string) []string {
+		if names, ok := listings[dir]; ok {
+			return names
+		}
+		var names []string
+		if entries, err := os.ReadDir(dir); err == nil {
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+		}
+		listings[dir] = names
+		return names
+	}
+
+	var discrepancies []lib.Discrepancy
+	add := func(file, problem string, args ...any) {
+		discrepancies = append(discrepancies, lib.Discrepancy{File: file, Problem: fmt.Sprintf(problem, args...)})
+	}
+
+	for _, file := range files {
+		outcome, processed := outcomes[file]
+		if !processed {
+			continue // Not reached before the batch stopped
+		}
+
+		if _, err := os.Stat(file); err != nil {
+			add(file, "input is missing: %v", err)
+		}
+		if outcome == StageDone {
+			if output, ok := t.writtenOutput(file); !ok {
+				add(file, "transcoded, but no output was found")
+			} else if info, err := os.Stat(output); err == nil && info.Size() == 0 {
+				add(file, "output %s is empty", output)
+			}
+		}
+
+		if outcome == StageSkipped {
+			// Temporary files next to a skipped file may belong to another run holding its lease
+			continue
+		}
+		dir, base := filepath.Split(file)
+		for _, name := range list(filepath.Clean(dir)) {
+			if source, ok := workFileSource(name); ok && source == base {
+				add(file, "temporary file %s was left behind", filepath.Join(dir, name))
+			}
+		}
+		for _, output := range t.outputCandidates(file) {
+			outputDir, outputBase := filepath.Split(output)
+			for _, name := range list(filepath.Clean(outputDir)) {
+				if name == outputBase+".tmp" || name == outputBase+".tmp.mux" {
+					add(file, "temporary file %s was left behind", filepath.Join(outputDir, name))
+				}
+			}
+		}
+	}
+
+	for _, d := range discrepancies {
+		slog.Warn("Filesystem does not match the batch", "file", d.File, "problem", d.Problem)
+	}
+	slog.Info("Reconciled batch with the filesystem", "files", len(outcomes), "discrepancies", len(discrepancies))
+
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	t.result.Discrepancies = discrepancies
+}
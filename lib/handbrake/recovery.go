@@ -0,0 +1,197 @@
+package handbrake
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"math"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Policies for handling stale temporary outputs found at startup
+const (
+	StaleTempPrompt = "prompt" // Ask interactively (falls back to keep when not a terminal)
+	StaleTempClean  = "clean"  // Remove all stale temporary outputs
+	StaleTempResume = "resume" // Promote complete outputs to their final name, remove the rest
+	StaleTempKeep   = "keep"   // Leave stale temporary outputs untouched
+)
+
+// staleTempFile describes an in-progress output or work file left behind by an interrupted run.
+type staleTempFile struct {
+	path    string    // Path to the .tmp or work file
+	kind    string    // ArtifactOutput or ArtifactWork
+	source  string    // Input file an output belongs to (empty if not part of this batch, and for work files)
+	modTime time.Time // Last modification time of the file
+}
+
+// findStaleTempFiles scans the input and output directories of the given input files for
+// temporary outputs and work files. Only files that have not been modified within minAge are
+// reported, so files actively being written by another run are left alone.
+func (t *HandBrakeTranscoder) findStaleTempFiles(files []string, minAge time.Duration) []staleTempFile {
+	sources := make(map[string]string, len(files))
+	dirs := make(map[string]bool)
+	for _, file := range files {
+		dirs[filepath.Dir(file)] = true
+		for _, outputPath := range t.outputCandidates(file) {
+			sources[outputPath+".tmp"] = file
+			dirs[filepath.Dir(outputPath)] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	var stale []staleTempFile
+
+	for dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			slog.Debug("Failed to read directory for stale temp files", "dir", dir, "error", err)
+			continue
+		}
+
+		for _, entry := range entries {
+			kind := ArtifactKind(entry.Name())
+			if !entry.Type().IsRegular() || kind == "" {
+				continue
+			}
+
+			info, err := entry.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+			stale = append(stale, staleTempFile{
+				path:    path,
+				kind:    kind,
+				source:  sources[path],
+				modTime: info.ModTime(),
+			})
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool {
+		return stale[i].path < stale[j].path
+	})
+	return stale
+}
+
+// recoverStaleTempFiles handles temporary outputs left behind by crashed or interrupted runs.
+// Depending on the configured policy the files are removed, promoted if complete, or kept.
+func (t *HandBrakeTranscoder) recoverStaleTempFiles(files []string) {
+	stale := t.findStaleTempFiles(files, t.StaleTempAge)
+	if len(stale) == 0 {
+		return
+	}
+
+	slog.Warn("Found stale temporary outputs from a previous run", "count", len(stale))
+	for _, tmp := range stale {
+		slog.Warn("Stale temporary output", "file", tmp.path, "kind", tmp.kind, "age", time.Since(tmp.modTime).Round(time.Second))
+	}
+
+	policy := t.StaleTempPolicy
+	if policy == "" || policy == StaleTempPrompt {
+		policy = promptStaleTempPolicy()
+	}
+
+	switch policy {
+	case StaleTempClean:
+		for _, tmp := range stale {
+			removeStaleTempFile(tmp.path)
+		}
+	case StaleTempResume:
+		for _, tmp := range stale {
+			t.resumeStaleTempFile(tmp)
+		}
+	default:
+		slog.Info("Keeping stale temporary outputs")
+	}
+}
+
+// resumeStaleTempFile promotes a temporary output to its final name if the encode had finished.
+// HandBrake cannot continue a partial encode, so incomplete outputs are removed instead, as are
+// work files, which are only of use to the run that made them.
+func (t *HandBrakeTranscoder) resumeStaleTempFile(tmp staleTempFile) {
+	if tmp.kind == ArtifactWork {
+		removeStaleTempFile(tmp.path)
+		return
+	}
+	if tmp.source == "" {
+		slog.Info("Keeping stale temporary output not belonging to this batch", "file", tmp.path)
+		return
+	}
+
+	if !isCompleteOutput(tmp.path, tmp.source) {
+		slog.Info("Stale temporary output is incomplete, removing", "file", tmp.path)
+		removeStaleTempFile(tmp.path)
+		return
+	}
+
+	finalPath := strings.TrimSuffix(tmp.path, ".tmp")
+	if _, err := os.Stat(finalPath); err == nil {
+		slog.Info("Final output already exists, removing stale temporary output", "file", tmp.path)
+		removeStaleTempFile(tmp.path)
+		return
+	}
+
+	if err := os.Rename(tmp.path, finalPath); err != nil {
+		slog.Warn("Failed to promote completed temporary output", "file", tmp.path, "error", err)
+		return
+	}
+	slog.Info("Recovered completed output from interrupted run", "file", finalPath)
+	t.recordAction(tmp.source, lib.HistoryActionVerified, map[string]string{"reason": "recovered_stale_output", "output_path": finalPath})
+}
+
+// isCompleteOutput reports whether an output's duration matches its source, within 1% or one second.
+func isCompleteOutput(outputPath, sourcePath string) bool {
+	output, err := lib.GetVideoInfo(outputPath)
+	if err != nil {
+		return false
+	}
+	source, err := lib.GetVideoInfo(sourcePath)
+	if err != nil || source.Duration <= 0 {
+		return false
+	}
+
+	tolerance := math.Max(1.0, source.Duration*0.01)
+	return math.Abs(output.Duration-source.Duration) <= tolerance
+}
+
+// removeStaleTempFile deletes a stale temporary output, logging the outcome.
+func removeStaleTempFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to remove stale temporary output", "file", path, "error", err)
+		return
+	}
+	slog.Info("Removed stale temporary output", "file", path)
+}
+
+// promptStaleTempPolicy asks the user how to handle stale temporary outputs.
+// Returns StaleTempKeep when stdin is not a terminal or the answer is not recognized.
+func promptStaleTempPolicy() string {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		slog.Info("Not running interactively, keeping stale temporary outputs (use --stale-tmp to choose)")
+		return StaleTempKeep
+	}
+
+	fmt.Print("Stale temporary outputs found: [c]lean, [r]esume completed, or [k]eep? ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return StaleTempKeep
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "c", "clean":
+		return StaleTempClean
+	case "r", "resume":
+		return StaleTempResume
+	default:
+		return StaleTempKeep
+	}
+}
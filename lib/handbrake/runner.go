@@ -4,21 +4,65 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
+	"media-mgmt/lib"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 var (
-	progressRegex = regexp.MustCompile(`Encoding: task \d+ of \d+, (\d+\.\d+) %(?:\s+\((\d+\.\d+) fps,.*ETA (\d+h\d+m\d+s)\))?`)
+	progressRegex = regexp.MustCompile(`Encoding: task \d+ of \d+, (\d+\.\d+) %(?:\s+\((\d+\.\d+) fps, avg (\d+\.\d+) fps, ETA (\d+h\d+m\d+s)\))?`)
+	taskRegex     = regexp.MustCompile(`Encoding: task (\d+) of (\d+),`)
 )
 
+// taskPercent converts the percent of the current task in a progress line to the percent of
+// the whole encode. Two-pass encodes report each pass as a task running from 0 to 100%.
+func taskPercent(line, percent string) string {
+	matches := taskRegex.FindStringSubmatch(line)
+	if matches == nil || matches[2] == "1" {
+		return percent
+	}
+	task, _ := strconv.Atoi(matches[1])
+	tasks, _ := strconv.Atoi(matches[2])
+	value, err := strconv.ParseFloat(percent, 64)
+	if err != nil || task < 1 || task > tasks {
+		return percent
+	}
+	return fmt.Sprintf("%.2f", (float64(task-1)*100+value)/float64(tasks))
+}
+
+// quietHandBrakeKey marks a context whose HandBrake runs happen in the background
+type quietHandBrakeKey struct{}
+
+// withQuietHandBrake returns a context under which HandBrake output is discarded rather than
+// drawn as progress, so background work does not disturb the progress of the file encoding
+func withQuietHandBrake(ctx context.Context) context.Context {
+	return context.WithValue(ctx, quietHandBrakeKey{}, true)
+}
+
+// isQuietHandBrake reports whether ctx was made by withQuietHandBrake
+func isQuietHandBrake(ctx context.Context) bool {
+	quiet, _ := ctx.Value(quietHandBrakeKey{}).(bool)
+	return quiet
+}
+
 // runHandBrakeCLI executes HandBrakeCLI with the provided arguments.
 // Handles output filtering, progress parsing, and provides a consistent interface
 // for all HandBrake command execution throughout the application.
+// Failures are returned as a *HandBrakeError classifying them, unless ctx was cancelled.
 func (t *HandBrakeTranscoder) runHandBrakeCLI(ctx context.Context, args []string) error {
-	cmd := exec.CommandContext(ctx, "HandBrakeCLI", args...)
+	cmd := exec.CommandContext(ctx, lib.ToolCommand("HandBrakeCLI"), args...)
+	run := encodeLogFrom(ctx).start(cmd.Args)
+	var stderr outputTail
+	if isQuietHandBrake(ctx) {
+		cmd.Stderr = io.MultiWriter(&stderr, run.writer())
+		err := cmd.Run()
+		run.finish(err)
+		return handBrakeFailure(ctx, err, &stderr)
+	}
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -30,14 +74,55 @@ func (t *HandBrakeTranscoder) runHandBrakeCLI(ctx context.Context, args []string
 		return fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
-	go t.filterHandBrakeOutput(stdoutPipe)
-	go t.filterHandBrakeOutput(stderrPipe)
+	// Only stderr is saved to the encode log; stdout carries nothing but progress
+	var filtering sync.WaitGroup
+	filtering.Add(2)
+	go func() {
+		defer filtering.Done()
+		t.filterHandBrakeOutput(stdoutPipe)
+	}()
+	go func() {
+		defer filtering.Done()
+		t.filterHandBrakeOutput(readCloser{io.TeeReader(stderrPipe, io.MultiWriter(&stderr, run.writer())), stderrPipe})
+	}()
 
 	if err := cmd.Start(); err != nil {
+		run.finish(err)
 		return fmt.Errorf("failed to start HandBrakeCLI: %w", err)
 	}
 
-	return cmd.Wait()
+	// Wait closes the pipes once the process exits, discarding anything not yet read, so both
+	// are drained first; the last lines of stderr hold the error that classifies a failure
+	filtering.Wait()
+	err = cmd.Wait()
+	run.finish(err)
+	return handBrakeFailure(ctx, err, &stderr)
+}
+
+// handBrakeFailure classifies the error of a HandBrakeCLI run from its stderr. Runs stopped by
+// cancelling ctx keep their error as is.
+func handBrakeFailure(ctx context.Context, err error, stderr *outputTail) error {
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	return classifyHandBrake(err, stderr.String())
+}
+
+// readCloser pairs a reader with the closer of the stream it reads from
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// handBrakeErrors returns the ERROR lines of HandBrake's output, joined for an error message
+func handBrakeErrors(output string) string {
+	var errors []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.Contains(line, "ERROR") {
+			errors = append(errors, strings.TrimSpace(line))
+		}
+	}
+	return strings.Join(errors, "; ")
 }
 
 // filterHandBrakeOutput processes HandBrake's output stream to extract progress information.
@@ -68,14 +153,28 @@ func (t *HandBrakeTranscoder) filterHandBrakeOutput(pipe io.ReadCloser) {
 
 		char := buf[0]
 
+		if t.HideProgressBar {
+			if char == '\r' || char == '\n' {
+				t.logHandBrakeLine(currentLine.String())
+				currentLine.Reset()
+			} else {
+				currentLine.WriteByte(char)
+			}
+			continue
+		}
+
 		if char == '\r' {
 			line := currentLine.String()
 			if matches := progressRegex.FindStringSubmatch(line); matches != nil {
-				percent := matches[1]
-				if len(matches) > 3 && matches[2] != "" {
+				percent := taskPercent(line, matches[1])
+				if len(matches) > 4 && matches[2] != "" {
 					fps := matches[2]
-					eta := matches[3]
+					eta := matches[4]
+					t.updateProgress(percent, fps, matches[3], eta)
 					extraText := fmt.Sprintf(" (%s fps, ETA %s)", fps, eta)
+					if batchETA, ok := t.BatchETA(); ok && t.batchRemaining() > 1 {
+						extraText = fmt.Sprintf(" (%s fps, ETA %s, batch %s)", fps, eta, lib.FormatDuration(batchETA.Seconds()))
+					}
 					progressBar := t.createProgressBarWithText(percent, extraText)
 					if progressBar != "" {
 						fmt.Printf("\r%s %s%%%s", progressBar, percent, extraText)
@@ -83,6 +182,7 @@ func (t *HandBrakeTranscoder) filterHandBrakeOutput(pipe io.ReadCloser) {
 						fmt.Printf("\r%s%%%s", percent, extraText)
 					}
 				} else {
+					t.updateProgress(percent, "", "", "")
 					progressBar := t.createProgressBar(percent)
 					if progressBar != "" {
 						fmt.Printf("\r%s %s%%", progressBar, percent)
@@ -113,7 +213,21 @@ func (t *HandBrakeTranscoder) filterHandBrakeOutput(pipe io.ReadCloser) {
 
 	if currentLine.Len() > 0 {
 		line := currentLine.String()
-		fmt.Printf("%s\n", line)
+		if t.HideProgressBar {
+			t.logHandBrakeLine(line)
+		} else {
+			fmt.Printf("%s\n", line)
+		}
+	}
+}
+
+// logHandBrakeLine records a line of HandBrake output when the progress bar is hidden: progress
+// updates the transcoder's progress, and errors and warnings are logged
+func (t *HandBrakeTranscoder) logHandBrakeLine(line string) {
+	if matches := progressRegex.FindStringSubmatch(line); matches != nil {
+		t.updateProgress(taskPercent(line, matches[1]), matches[2], matches[3], matches[4])
+	} else if strings.Contains(line, "ERROR") || strings.Contains(line, "WARNING") {
+		slog.Warn("HandBrake reported a problem", "message", strings.TrimSpace(line))
 	}
 }
 
@@ -173,4 +287,18 @@ func (t *HandBrakeTranscoder) createProgressBarWithText(percentStr, extraText st
 
 	bar.WriteRune(']')
 	return bar.String()
-}
\ No newline at end of file
+}
+
+// setLastAvgFPS records the most recent average encode speed reported by HandBrake.
+func (t *HandBrakeTranscoder) setLastAvgFPS(fps float64) {
+	t.progressMux.Lock()
+	t.lastAvgFPS = fps
+	t.progressMux.Unlock()
+}
+
+// getLastAvgFPS returns the most recent average encode speed reported by HandBrake.
+func (t *HandBrakeTranscoder) getLastAvgFPS() float64 {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	return t.lastAvgFPS
+}
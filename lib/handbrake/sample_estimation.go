@@ -0,0 +1,101 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// singleEstimateRequirement is needed to cut the estimation sample, since HandBrakeCLI encodes
+// only one range per run
+var singleEstimateRequirement = lib.Requirement{Feature: "--single-estimate", Tool: "ffmpeg"}
+
+// estimateFromSample cuts every test segment into one clip with a stream copy and encodes the
+// clip in a single HandBrakeCLI run, so HandBrake's startup and source scan are paid once per
+// file instead of once per segment
+func (t *HandBrakeTranscoder) estimateFromSample(ctx context.Context, inputPath string, videoInfo *lib.VideoInfo, hasVideoToolbox bool) (int64, error) {
+	starts := make([]float64, len(estimationPositions))
+	for i, pos := range estimationPositions {
+		starts[i] = videoInfo.Duration * pos
+	}
+
+	samplePath := inputPath + ".size-sample.mkv"
+	testOutputPath := inputPath + ".size-test.mkv"
+	defer removeTestFile(samplePath)
+	defer removeTestFile(testOutputPath)
+
+	if err := cutSample(ctx, inputPath, samplePath, starts, estimationSegmentSeconds); err != nil {
+		return 0, err
+	}
+	// Stream copies cut at keyframes, so the sample usually runs a little longer than requested
+	sampleInfo, err := lib.GetVideoInfo(samplePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to probe estimation sample: %w", err)
+	}
+	if sampleInfo.Duration <= 0 {
+		return 0, fmt.Errorf("estimation sample has no duration")
+	}
+
+	sampleSize, err := t.encodeSegment(ctx, samplePath, testOutputPath, 0, sampleInfo.Duration, videoInfo, hasVideoToolbox)
+	if err != nil {
+		return 0, err
+	}
+
+	avgBytesPerSecond := float64(sampleSize) / sampleInfo.Duration
+	estimatedSize := int64(avgBytesPerSecond * videoInfo.Duration)
+
+	slog.Debug("Size estimation",
+		"sample_seconds", sampleInfo.Duration,
+		"avg_bytes_per_second", int64(avgBytesPerSecond),
+		"estimated_size_bytes", estimatedSize)
+
+	return estimatedSize, nil
+}
+
+// cutSample joins duration seconds from each start into one file using ffmpeg's concat demuxer
+// with a stream copy, keeping every stream so track selection matches the source
+func cutSample(ctx context.Context, inputPath, samplePath string, starts []float64, duration float64) error {
+	list, err := os.CreateTemp("", "media-mgmt-sample-*.txt")
+	if err != nil {
+		return fmt.Errorf("failed to create sample list: %w", err)
+	}
+	defer os.Remove(list.Name())
+	if _, err := list.WriteString(sampleConcatList(inputPath, starts, duration)); err != nil {
+		list.Close()
+		return fmt.Errorf("failed to write sample list: %w", err)
+	}
+	if err := list.Close(); err != nil {
+		return fmt.Errorf("failed to write sample list: %w", err)
+	}
+
+	args := []string{"-v", "error", "-y", "-f", "concat", "-safe", "0", "-i", list.Name(), "-map", "0", "-c", "copy", "-f", "matroska", samplePath}
+	slog.Debug("Executing ffmpeg", "args", strings.Join(args, " "))
+	output, err := combinedOutput(ctx, exec.CommandContext(ctx, lib.ToolCommand("ffmpeg"), args...))
+	if err != nil {
+		return fmt.Errorf("failed to cut estimation sample: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// sampleConcatList builds a concat demuxer script that plays duration seconds of inputPath
+// from each start
+func sampleConcatList(inputPath string, starts []float64, duration float64) string {
+	quoted := "'" + strings.ReplaceAll(inputPath, "'", `'\''`) + "'"
+	var b strings.Builder
+	b.WriteString("ffconcat version 1.0\n")
+	for _, start := range starts {
+		fmt.Fprintf(&b, "file %s\ninpoint %.3f\noutpoint %.3f\n", quoted, start, start+duration)
+	}
+	return b.String()
+}
+
+// removeTestFile deletes a temporary estimation file, ignoring files that were never created
+func removeTestFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		slog.Warn("Failed to clean up test file", "file", path, "error", err)
+	}
+}
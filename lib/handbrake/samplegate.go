@@ -0,0 +1,102 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultSampleMinVMAF is the VMAF score a sample must reach
+	DefaultSampleMinVMAF = 90.0
+	// sampleGateSeconds is the length of the sample, taken from the middle of the source
+	sampleGateSeconds = 60.0
+)
+
+// sampleGateChecks are the sample checks the detected tools allow, decided once per Run. Without
+// ffmpeg the sample only has to encode; without libvmaf it also has to decode cleanly.
+type sampleGateChecks struct {
+	decode bool
+	vmaf   bool
+}
+
+// detectSampleGateChecks decides which sample checks can run, warning about those that cannot
+func (t *HandBrakeTranscoder) detectSampleGateChecks() sampleGateChecks {
+	if t.SampleGateSize <= 0 {
+		return sampleGateChecks{}
+	}
+	feature := fmt.Sprintf("Sample checks for files over %s", lib.FormatSize(t.SampleGateSize))
+	if err := t.capabilities.Check(lib.Requirement{Feature: feature, Tool: "ffmpeg"}); err != nil {
+		slog.Warn("Samples of large files will only be test-encoded", "reason", err)
+		return sampleGateChecks{}
+	}
+	checks := sampleGateChecks{decode: true}
+	if t.SampleMinVMAF > 0 {
+		if err := t.capabilities.Check(lib.Requirement{Feature: feature, Tool: "ffmpeg", Filters: []string{"libvmaf"}}); err != nil {
+			slog.Warn("Samples of large files will be checked for decode errors but not scored", "reason", err)
+		} else {
+			checks.vmaf = true
+		}
+	}
+	return checks
+}
+
+// needsSample reports whether a source is large enough that a sample must pass before its full encode
+func (t *HandBrakeTranscoder) needsSample(size int64) bool {
+	return t.SampleGateSize > 0 && size >= t.SampleGateSize && t.ExportQueuePath == ""
+}
+
+// checkSample encodes a sample from the middle of a large source with the full encode's settings
+// and checks that it decodes cleanly and reaches SampleMinVMAF, so quality and compatibility
+// problems surface in minutes rather than after a multi-hour encode
+func (t *HandBrakeTranscoder) checkSample(ctx context.Context, filePath string, videoInfo *lib.VideoInfo, hasVideoToolbox bool) error {
+	duration := min(sampleGateSeconds, videoInfo.Duration)
+	start := max(videoInfo.Duration/2-duration/2, 0)
+	samplePath := filePath + ".sample-gate." + t.containerFor(videoInfo)
+	defer os.Remove(samplePath)
+
+	slog.Info("Encoding a sample before the full encode", "file", filepath.Base(filePath), "seconds", duration)
+	params := map[string]string{"check": "sample"}
+	if _, err := t.encodeSegment(ctx, filePath, samplePath, start, duration, videoInfo, hasVideoToolbox); err != nil {
+		return t.sampleFailed(ctx, filePath, params, fmt.Errorf("sample encode failed: %w", err))
+	}
+	if t.sampleChecks.decode {
+		if err := t.checkDecode(ctx, samplePath); err != nil {
+			return t.sampleFailed(ctx, filePath, params, fmt.Errorf("sample failed to decode: %w", err))
+		}
+	}
+	if t.sampleChecks.vmaf {
+		args := withHWAccel(vmafSegmentArgs(filePath, samplePath, videoInfo, start, duration), t.hwAccel)
+		output, err := combinedOutput(ctx, lowPriorityCommand(ctx, lib.ToolCommand("ffmpeg"), args...))
+		if err != nil {
+			return t.sampleFailed(ctx, filePath, params, fmt.Errorf("sample VMAF scoring failed: %w: %s", err, firstLines(strings.TrimSpace(string(output)), 3)))
+		}
+		score, err := parseVMAFScore(string(output))
+		if err != nil {
+			return t.sampleFailed(ctx, filePath, params, err)
+		}
+		params["vmaf"] = strconv.FormatFloat(score, 'f', 2, 64)
+		if score < t.SampleMinVMAF {
+			return t.sampleFailed(ctx, filePath, params, fmt.Errorf("sample VMAF score %.2f is below the minimum of %.2f; raise --quality or lower --sample-min-vmaf", score, t.SampleMinVMAF))
+		}
+	}
+
+	slog.Info("Sample passed", "file", filepath.Base(filePath), "vmaf", params["vmaf"])
+	t.recordAction(filePath, lib.HistoryActionVerified, params)
+	return nil
+}
+
+// sampleFailed records a failed sample check and returns its error, unless the run was cancelled
+func (t *HandBrakeTranscoder) sampleFailed(ctx context.Context, filePath string, params map[string]string, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	params["error"] = err.Error()
+	t.recordAction(filePath, lib.HistoryActionVerified, params)
+	return err
+}
@@ -0,0 +1,167 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sidecarMuxRequirement is needed to add audio sidecars, since HandBrakeCLI reads a single input
+var sidecarMuxRequirement = lib.Requirement{Feature: "--mux-audio-sidecars", Tool: "ffmpeg"}
+
+// sidecarMuxArgs builds the ffmpeg arguments that copy every stream of the encoded file and
+// append each sidecar's audio after its existingAudio tracks, tagged with language, label, and role.
+// format is ffmpeg's name for the output container.
+func sidecarMuxArgs(inputPath, outputPath, format string, existingAudio int, sidecars []lib.AudioSidecar) []string {
+	args := []string{"-v", "error", "-y", "-i", inputPath}
+	for _, sidecar := range sidecars {
+		args = append(args, "-i", sidecar.Path)
+	}
+
+	args = append(args, "-map", "0")
+	for i := range sidecars {
+		args = append(args, "-map", fmt.Sprintf("%d:a:0", i+1))
+	}
+	args = append(args, "-c", "copy")
+
+	for i, sidecar := range sidecars {
+		stream := strconv.Itoa(existingAudio + i)
+		if sidecar.Language != "" {
+			args = append(args, "-metadata:s:a:"+stream, "language="+sidecar.Language)
+		}
+		if sidecar.Label != "" {
+			args = append(args, "-metadata:s:a:"+stream, "title="+sidecar.Label)
+		}
+		switch sidecar.Role {
+		case lib.AudioRoleCommentary:
+			args = append(args, "-disposition:a:"+stream, "comment")
+		case lib.AudioRoleDescriptive:
+			args = append(args, "-disposition:a:"+stream, "visual_impaired+descriptions")
+		default:
+			args = append(args, "-disposition:a:"+stream, "0")
+		}
+	}
+
+	if format == "mp4" {
+		args = append(args, "-movflags", "+faststart")
+	}
+	return append(args, "-f", format, outputPath)
+}
+
+// muxAudioSidecars adds the sidecars to the encoded file at path, replacing it in place
+func (t *HandBrakeTranscoder) muxAudioSidecars(ctx context.Context, path, container string, existingAudio int, sidecars []lib.AudioSidecar) error {
+	muxPath := path + ".mux"
+	format := "matroska"
+	if container == ContainerMP4 {
+		format = "mp4"
+	}
+	args := t.withFFmpegArgs(sidecarMuxArgs(path, muxPath, format, existingAudio, sidecars))
+	t.logFFmpegCommand(ctx, args)
+
+	output, err := combinedOutput(ctx, exec.CommandContext(ctx, lib.ToolCommand("ffmpeg"), args...))
+	if err != nil {
+		os.Remove(muxPath)
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := os.Rename(muxPath, path); err != nil {
+		os.Remove(muxPath)
+		return fmt.Errorf("failed to replace encoded file: %w", err)
+	}
+	return nil
+}
+
+// Sidecar copy policies
+const (
+	SidecarsAuto   = "auto"   // Copy metadata sidecars when the output is written to another directory
+	SidecarsAlways = "always" // Also copy them next to outputs in the input's directory
+	SidecarsOff    = "off"    // Leave metadata sidecars alone
+)
+
+// sidecarCopy is a subtitle, metadata, or artwork file to copy for an output
+type sidecarCopy struct {
+	src, dst string
+}
+
+// sidecarCopies plans the metadata sidecars to copy for an output under the Sidecars policy.
+// Files named after the input are renamed after the output; directory artwork such as
+// poster.jpg only goes to other directories, keeping its name. Files already at their
+// destination are left alone unless Overwrite is set, and subtitles muxed into the output are
+// not copied.
+func (t *HandBrakeTranscoder) sidecarCopies(inputPath, outputPath string, muxed []lib.ExternalSubtitle) ([]sidecarCopy, error) {
+	sameDir := filepath.Dir(inputPath) == filepath.Dir(outputPath)
+	if t.Sidecars == SidecarsOff || (sameDir && t.Sidecars != SidecarsAlways) {
+		return nil, nil
+	}
+
+	sidecars, err := lib.FindMetadataSidecars(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	var copies []sidecarCopy
+	handled := make(map[string]bool)
+	for _, subtitle := range muxed {
+		handled[subtitle.Path] = true
+	}
+	for _, sidecar := range sidecars {
+		if handled[sidecar] {
+			continue
+		}
+		if dst := lib.SidecarDestination(sidecar, inputPath, outputPath); dst != sidecar {
+			copies = append(copies, sidecarCopy{sidecar, dst})
+			handled[sidecar] = true
+		}
+	}
+	if !sameDir {
+		folderSidecars, err := lib.FindFolderSidecars(filepath.Dir(inputPath))
+		if err != nil {
+			return nil, err
+		}
+		for _, sidecar := range folderSidecars {
+			// movie.nfo is both Kodi's directory-level name and the sidecar of a movie.mkv
+			if handled[sidecar] {
+				continue
+			}
+			copies = append(copies, sidecarCopy{sidecar, filepath.Join(filepath.Dir(outputPath), filepath.Base(sidecar))})
+		}
+	}
+
+	if t.Overwrite {
+		return copies, nil
+	}
+	var missing []sidecarCopy
+	for _, c := range copies {
+		if _, err := os.Stat(c.dst); os.IsNotExist(err) {
+			missing = append(missing, c)
+		}
+	}
+	return missing, nil
+}
+
+// copySidecars copies the input's subtitle, .nfo, and artwork files next to its output, so
+// media servers keep their metadata for it. The encode has succeeded by now, so files that
+// cannot be copied are logged rather than failing the file.
+func (t *HandBrakeTranscoder) copySidecars(inputPath, outputPath string, muxed []lib.ExternalSubtitle) {
+	copies, err := t.sidecarCopies(inputPath, outputPath, muxed)
+	if err != nil {
+		slog.Warn("Failed to look for sidecar files", "file", filepath.Base(inputPath), "error", err)
+		return
+	}
+	copied := 0
+	for _, c := range copies {
+		if err := lib.CopySidecar(c.src, c.dst); err != nil {
+			slog.Warn("Failed to copy sidecar file", "file", filepath.Base(c.src), "error", err)
+			continue
+		}
+		slog.Debug("Copied sidecar file", "from", c.src, "to", c.dst)
+		copied++
+	}
+	if copied > 0 {
+		slog.Info("Copied sidecar files", "file", filepath.Base(outputPath), "count", copied)
+	}
+}
@@ -36,23 +36,37 @@ func (t *HandBrakeTranscoder) checkSkipFile(filePath string) bool {
 // Performs size estimation and compares against the minimum savings threshold.
 // Returns true if the file should be skipped (insufficient savings), false to proceed.
 func (t *HandBrakeTranscoder) checkSizeSavings(ctx context.Context, filePath string, originalFileSize int64, videoInfo *lib.VideoInfo, hasVideoToolbox bool) (bool, error) {
-	slog.Info("Estimating output size", "file", filepath.Base(filePath))
-
-	estimatedSize, err := t.estimateOutputSize(ctx, filePath, videoInfo, hasVideoToolbox)
-	if err != nil {
-		return false, err
+	// Files reviewed with Interactive were estimated before the batch started
+	estimatedSize, planned := t.plannedEstimate(filePath)
+	if !planned {
+		slog.Info("Estimating output size", "file", filepath.Base(filePath))
+		var err error
+		estimatedSize, err = t.estimateOutputSize(ctx, filePath, videoInfo, hasVideoToolbox)
+		if err != nil {
+			return false, err
+		}
 	}
 
 	sizeRatio := float64(estimatedSize) / float64(originalFileSize)
+	if !planned {
+		t.recordEstimate(filePath, estimatedSize)
+		t.recordAction(filePath, lib.HistoryActionEstimated, map[string]string{
+			"estimated_size": fmt.Sprintf("%d", estimatedSize),
+			"original_size":  fmt.Sprintf("%d", originalFileSize),
+			"size_ratio":     fmt.Sprintf("%.3f", sizeRatio),
+		})
+	}
 	
 	if sizeRatio > t.MaxSizeRatio {
 		encoder := t.selectEncoder(videoInfo, hasVideoToolbox)
 
 		slog.Info("Skipping file, insufficient space savings",
 			"file", filepath.Base(filePath),
+			"stage", StageSkipped,
 			"size_ratio", fmt.Sprintf("%.1f%%", sizeRatio*100),
 			"max_size_ratio", fmt.Sprintf("%.1f%%", t.MaxSizeRatio*100))
-		if err := t.createSkipFile(filePath, "insufficient_savings", originalFileSize, estimatedSize, encoder); err != nil {
+		t.recordAction(filePath, lib.HistoryActionSkipped, map[string]string{"reason": "insufficient_savings"})
+		if err := t.createSkipFile(filePath, "insufficient_savings", originalFileSize, estimatedSize, encoder, t.qualityFor(videoInfo)); err != nil {
 			slog.Warn("Failed to create skip file", "file", filePath, "error", err)
 		}
 		return true, nil
@@ -68,12 +82,12 @@ func (t *HandBrakeTranscoder) checkSizeSavings(ctx context.Context, filePath str
 // createSkipFile generates a .skip file with metadata about why the file was skipped.
 // Creates a JSON file containing size estimates, encoder settings, and skip reasons.
 // This prevents re-processing the file in future runs.
-func (t *HandBrakeTranscoder) createSkipFile(filePath string, reason string, originalSize, estimatedSize int64, encoder string) error {
+func (t *HandBrakeTranscoder) createSkipFile(filePath string, reason string, originalSize, estimatedSize int64, encoder string, quality int) error {
 	skipPath := strings.TrimSuffix(filePath, filepath.Ext(filePath)) + ".skip"
 	requiredSize := int64(float64(originalSize) * t.MaxSizeRatio)
 	skipInfo := SkipInfo{
 		Reason:             reason,
-		Quality:            t.Quality,
+		Quality:            quality,
 		Encoder:            encoder,
 		Timestamp:          time.Now(),
 		OriginalSizeBytes:  originalSize,
@@ -91,12 +105,31 @@ func (t *HandBrakeTranscoder) createSkipFile(filePath string, reason string, ori
 	return nil
 }
 
+var (
+	// estimationSegmentSeconds is the length of each test segment
+	estimationSegmentSeconds = 10.0
+	// estimationPositions are where the test segments start, as fractions of the duration
+	estimationPositions = []float64{0.25, 0.50, 0.75}
+)
+
 // estimateOutputSize calculates approximate output file size by encoding test segments.
 // Encodes 3 segments of 10 seconds each at 25%, 50%, and 75% through the video.
 // Averages the results and extrapolates to the full video duration.
+// With SingleEstimate, the segments are encoded together in one HandBrakeCLI run instead.
 func (t *HandBrakeTranscoder) estimateOutputSize(ctx context.Context, inputPath string, videoInfo *lib.VideoInfo, hasVideoToolbox bool) (int64, error) {
-	segmentDuration := 10.0                  // seconds
-	positions := []float64{0.25, 0.50, 0.75} // 25%, 50%, 75% through video
+	if t.SingleEstimate {
+		estimatedSize, err := t.estimateFromSample(ctx, inputPath, videoInfo, hasVideoToolbox)
+		if err == nil {
+			return estimatedSize, nil
+		}
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		slog.Warn("Single-run estimation failed, encoding segments separately", "file", filepath.Base(inputPath), "error", err)
+	}
+
+	segmentDuration := estimationSegmentSeconds
+	positions := estimationPositions
 
 	var totalSize int64
 	var successfulSegments int
@@ -146,20 +179,20 @@ func (t *HandBrakeTranscoder) estimateOutputSize(ctx context.Context, inputPath
 // Uses the same encoder and quality settings as the full transcode.
 // Returns the size of the encoded segment in bytes, or an error if encoding fails.
 func (t *HandBrakeTranscoder) encodeSegment(ctx context.Context, inputPath, outputPath string, startTime, duration float64, videoInfo *lib.VideoInfo, hasVideoToolbox bool) (int64, error) {
-	args := []string{
-		"-i", inputPath,
-		"-o", outputPath,
-		"--start-at", fmt.Sprintf("duration:%.0f", startTime),
-		"--stop-at", fmt.Sprintf("duration:%.0f", duration),
-		"--verbose", "1",
+	if t.usesAVFoundation() {
+		if err := t.exportWithAVConvert(ctx, inputPath, outputPath, videoInfo, startTime, duration); err != nil {
+			return 0, err
+		}
+		fileInfo, err := os.Stat(outputPath)
+		if err != nil {
+			return 0, fmt.Errorf("failed to stat output file: %w", err)
+		}
+		return fileInfo.Size(), nil
 	}
 
 	encoder := t.selectEncoder(videoInfo, hasVideoToolbox)
-	args = append(args, "--encoder", encoder)
-	args = append(args, "--quality", fmt.Sprintf("%d", t.Quality))
-	args = append(args, "--all-audio", "--all-subtitles")
-	args = append(args, "--format", "av_mkv")
-
+	args := t.segmentArgs(inputPath, outputPath, startTime, duration, videoInfo, encoder, t.qualityFor(videoInfo))
+	t.logHandBrakeCommand(ctx, args)
 	if err := t.runHandBrakeCLI(ctx, args); err != nil {
 		return 0, fmt.Errorf("HandBrakeCLI failed: %w", err)
 	}
@@ -170,3 +203,28 @@ func (t *HandBrakeTranscoder) encodeSegment(ctx context.Context, inputPath, outp
 	}
 	return fileInfo.Size(), nil
 }
+
+// segmentArgs builds the HandBrakeCLI arguments that encode duration seconds of inputPath from
+// startTime with encoder at quality, and otherwise the full transcode's settings
+func (t *HandBrakeTranscoder) segmentArgs(inputPath, outputPath string, startTime, duration float64, videoInfo *lib.VideoInfo, encoder string, quality int) []string {
+	args := []string{
+		"-i", inputPath,
+		"-o", outputPath,
+		"--start-at", fmt.Sprintf("duration:%.0f", startTime),
+		"--stop-at", fmt.Sprintf("duration:%.0f", duration),
+		"--verbose", "1",
+	}
+	args = append(args, "--encoder", encoder)
+	args = append(args, t.lowPowerArgs(encoder)...)
+	args = append(args, t.hwDecodeArgs(encoder)...)
+	args = append(args, t.encoderOptionArgs()...)
+	args = append(args, t.filmGrainArgs(videoInfo)...)
+	args = append(args, t.denoiseArgs(videoInfo)...)
+	args = append(args, t.rateControlArgs(encoder, quality)...)
+	args = append(args, t.frameRateArgs(videoInfo)...)
+	args = append(args, t.audioArgs(videoInfo.AudioTracks)...)
+	args = append(args, t.audioEncoderArgs(videoInfo.AudioTracks)...)
+	args = append(args, t.subtitleArgs(videoInfo.SubtitleTracks)...)
+	args = append(args, "--format", containerFormat(t.containerFor(videoInfo)))
+	return append(args, t.HandBrakeArgs...)
+}
@@ -0,0 +1,221 @@
+package handbrake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Batch states reported in the status file
+const (
+	BatchRunning   = "running"
+	BatchFinished  = "finished"
+	BatchFailed    = "failed"
+	BatchCancelled = "cancelled"
+	BatchIdle      = "idle" // No batch is running, reported by serve mode between jobs
+)
+
+// statusInterval is the least time between status file writes while a file encodes. Stage
+// changes are written immediately.
+const statusInterval = time.Second
+
+// BatchStatus is a snapshot of a transcode batch, written to the status file and socket for
+// external tools to poll
+type BatchStatus struct {
+	State      string    `json:"state"`
+	PID        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Current    *Progress `json:"current,omitempty"` // File being processed (nil before the first and after the last)
+	FilesDone  int       `json:"files_done"`
+	FilesTotal int       `json:"files_total"`
+	Transcoded int       `json:"transcoded"`
+	Skipped    int       `json:"skipped"`
+	Failed     int       `json:"failed"`
+	ETASeconds float64   `json:"eta_seconds,omitempty"` // Estimated time left in the batch (omitted until a file has been transcoded)
+}
+
+// Status returns a snapshot of the batch's progress
+func (t *HandBrakeTranscoder) Status() BatchStatus {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+
+	status := BatchStatus{
+		State:      t.batch.state,
+		PID:        os.Getpid(),
+		StartedAt:  t.batch.started,
+		UpdatedAt:  time.Now(),
+		FilesTotal: len(t.batch.files),
+		Transcoded: t.result.Transcoded,
+		Skipped:    t.result.Skipped,
+		Failed:     t.result.Failed,
+	}
+	status.FilesDone = status.Transcoded + status.Skipped + status.Failed
+	if t.batch.state == BatchRunning {
+		status.ETASeconds, _ = t.batchETA()
+	}
+	if t.batch.state == BatchRunning && t.progress.File != "" && !isTerminalStage(t.progress.Stage) {
+		current := t.progress
+		status.Current = &current
+	}
+	return status
+}
+
+// isTerminalStage reports whether a file is finished with once it reaches stage
+func isTerminalStage(stage string) bool {
+	return stage == StageDone || stage == StageSkipped || stage == StageFailed
+}
+
+// batchProgress tracks a batch through its files. Guarded by progressMux.
+type batchProgress struct {
+	state         string                        // One of the Batch states, once the batch has started
+	started       time.Time                     // When the batch started processing files
+	files         []string                      // Files in the batch, in processing order
+	sizes         map[string]int64              // Input size of each file
+	outputSizes   map[string]int64              // Output size of each transcoded file
+	fileStarted   map[string]time.Time          // When each file was first worked on
+	qualityScores map[string]map[string]float64 // Lowest score of each quality metric among each compared file's outputs
+	abortFile     context.CancelCauseFunc       // Stops the file being processed for AbortCurrentFile (nil between files)
+	abortPath     string                        // The file abortFile stops
+}
+
+// startBatch records the start of a batch and the sizes of its files, which the batch ETA is
+// estimated from
+func (t *HandBrakeTranscoder) startBatch(files []string) {
+	sizes := make(map[string]int64, len(files))
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			sizes[file] = info.Size()
+		}
+	}
+
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	t.batch = batchProgress{
+		state:         BatchRunning,
+		started:       time.Now(),
+		files:         files,
+		sizes:         sizes,
+		outputSizes:   make(map[string]int64),
+		fileStarted:   make(map[string]time.Time),
+		qualityScores: make(map[string]map[string]float64),
+	}
+}
+
+// statusReporter publishes the batch status to the status file and socket
+type statusReporter struct {
+	transcoder *HandBrakeTranscoder
+	path       string
+	listener   net.Listener
+	mutex      sync.Mutex // Serializes writes of the status file
+	lastWrite  time.Time
+	lastStage  string
+}
+
+// startStatusReporter begins publishing the batch status to StatusFile and StatusSocket.
+// Returns nil if neither is set.
+func (t *HandBrakeTranscoder) startStatusReporter() (*statusReporter, error) {
+	if t.StatusFile == "" && t.StatusSocket == "" {
+		return nil, nil
+	}
+	reporter := &statusReporter{transcoder: t, path: t.StatusFile}
+	if t.StatusSocket != "" {
+		// A socket left behind by a run that was killed would make Listen fail
+		if info, err := os.Lstat(t.StatusSocket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(t.StatusSocket)
+		}
+		listener, err := net.Listen("unix", t.StatusSocket)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on status socket: %w", err)
+		}
+		reporter.listener = listener
+		go reporter.serve()
+	}
+	reporter.write()
+	return reporter, nil
+}
+
+// serve answers each connection to the status socket with the current status as one line of JSON
+func (r *statusReporter) serve() {
+	for {
+		conn, err := r.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("Status socket stopped accepting connections", "socket", r.listener.Addr(), "error", err)
+			}
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		json.NewEncoder(conn).Encode(r.transcoder.Status())
+		conn.Close()
+	}
+}
+
+// update writes the status file after a progress change, at most once per statusInterval
+// unless the stage changed. Safe to call on a nil reporter.
+func (r *statusReporter) update(progress Progress) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	due := progress.Stage != r.lastStage || time.Since(r.lastWrite) >= statusInterval
+	r.lastStage = progress.Stage
+	r.mutex.Unlock()
+	if due {
+		r.write()
+	}
+}
+
+// write replaces the status file with the current status, so a reader never sees a partial file
+func (r *statusReporter) write() {
+	if r.path == "" {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastWrite = time.Now()
+
+	data, err := json.MarshalIndent(r.transcoder.Status(), "", "  ")
+	if err != nil {
+		slog.Warn("Failed to encode status file", "error", err)
+		return
+	}
+	tmpPath := r.path + ".tmp"
+	err = os.WriteFile(tmpPath, append(data, '\n'), 0644)
+	if err == nil {
+		err = os.Rename(tmpPath, r.path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		slog.Warn("Failed to write status file", "path", r.path, "error", err)
+	}
+}
+
+// finishBatch records the batch's final state, writing it to the status file and closing the
+// status socket
+func (t *HandBrakeTranscoder) finishBatch(ctx context.Context, err error) {
+	state := BatchFinished
+	switch {
+	case err != nil && ctx.Err() != nil:
+		state = BatchCancelled
+	case err != nil:
+		state = BatchFailed
+	}
+	t.progressMux.Lock()
+	t.batch.state = state
+	t.result.WallSeconds = time.Since(t.batch.started).Seconds()
+	t.progressMux.Unlock()
+
+	if r := t.status; r != nil {
+		r.write()
+		if r.listener != nil {
+			r.listener.Close()
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package handbrake
+
+import (
+	"log/slog"
+	"media-mgmt/lib"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// externalSubtitles finds the input's SRT and SSA/ASS files and keeps those SubtitlePolicy
+// allows, as it does embedded tracks. HandBrake takes its lists of subtitle files separated by
+// commas, so files whose paths contain one are left out.
+func (t *HandBrakeTranscoder) externalSubtitles(inputPath string) []lib.ExternalSubtitle {
+	found, err := lib.FindExternalSubtitles(inputPath)
+	if err != nil {
+		slog.Warn("Failed to look for external subtitles", "file", filepath.Base(inputPath), "error", err)
+		return nil
+	}
+
+	var kept []lib.ExternalSubtitle
+	for _, subtitle := range found {
+		switch {
+		case strings.Contains(subtitle.Path, ","):
+			slog.Warn("External subtitle path contains a comma, which HandBrake cannot import", "file", filepath.Base(subtitle.Path))
+		case t.SubtitlePolicy == SubtitlePolicyForced && subtitle.Kind != lib.SubtitleKindForced,
+			t.SubtitlePolicy == SubtitlePolicyNoSDH && subtitle.Kind == lib.SubtitleKindSDH:
+			slog.Debug("External subtitle left out by subtitle policy", "file", filepath.Base(subtitle.Path), "policy", t.SubtitlePolicy)
+		default:
+			kept = append(kept, subtitle)
+		}
+	}
+	if len(kept) > 0 {
+		slog.Info("Adding external subtitles", "file", filepath.Base(inputPath), "count", len(kept))
+	}
+	return kept
+}
+
+// externalSubtitleArgs builds HandBrake's options importing the subtitles, SRT files with
+// --srt-file and SSA/ASS files with --ssa-file, each with its list of ISO 639-2 languages
+func externalSubtitleArgs(subtitles []lib.ExternalSubtitle) []string {
+	var srtFiles, srtLangs, ssaFiles, ssaLangs []string
+	for _, subtitle := range subtitles {
+		language := lib.LongLanguageCode(subtitle.Language)
+		if strings.EqualFold(filepath.Ext(subtitle.Path), ".srt") {
+			srtFiles = append(srtFiles, subtitle.Path)
+			srtLangs = append(srtLangs, language)
+		} else {
+			ssaFiles = append(ssaFiles, subtitle.Path)
+			ssaLangs = append(ssaLangs, language)
+		}
+	}
+
+	var args []string
+	if len(srtFiles) > 0 {
+		// HandBrake reads SRT files as Latin-1 unless told otherwise
+		codesets := strings.TrimSuffix(strings.Repeat("UTF-8,", len(srtFiles)), ",")
+		args = append(args, "--srt-file", strings.Join(srtFiles, ","), "--srt-lang", strings.Join(srtLangs, ","), "--srt-codeset", codesets)
+	}
+	if len(ssaFiles) > 0 {
+		args = append(args, "--ssa-file", strings.Join(ssaFiles, ","), "--ssa-lang", strings.Join(ssaLangs, ","))
+	}
+	return args
+}
+
+// removeExternalSubtitles deletes subtitle files muxed into an output, when
+// RemoveExternalSubs is set. The output is in place by now, so files that cannot be removed
+// are logged rather than failing the file.
+func (t *HandBrakeTranscoder) removeExternalSubtitles(subtitles []lib.ExternalSubtitle) {
+	if !t.RemoveExternalSubs {
+		return
+	}
+	for _, subtitle := range subtitles {
+		if err := os.Remove(subtitle.Path); err != nil {
+			slog.Warn("Failed to remove muxed external subtitle", "file", subtitle.Path, "error", err)
+			continue
+		}
+		slog.Info("Removed muxed external subtitle", "file", filepath.Base(subtitle.Path))
+	}
+}
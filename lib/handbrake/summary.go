@@ -0,0 +1,185 @@
+package handbrake
+
+import (
+	"media-mgmt/lib"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BatchResult tallies the outcome of a transcode batch
+type BatchResult struct {
+	Transcoded    int               `json:"transcoded"`
+	Skipped       int               `json:"skipped"`
+	Failed        int               `json:"failed"`
+	OriginalBytes int64             `json:"original_bytes"` // Total input size of transcoded files
+	OutputBytes   int64             `json:"output_bytes"`   // Total output size of transcoded files
+	Failures      []lib.FileFailure `json:"failures,omitempty"`
+	Files         []lib.FileOutcome `json:"files,omitempty"`         // Each file's terminal stage, in processing order
+	Discrepancies []lib.Discrepancy `json:"discrepancies,omitempty"` // Differences between the batch's record and the filesystem afterwards
+	WallSeconds   float64           `json:"wall_seconds"`            // Time from the first file starting to the batch finishing
+}
+
+// Result returns the tally of files processed so far
+func (t *HandBrakeTranscoder) Result() BatchResult {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+
+	result := t.result
+	result.Failures = append([]lib.FileFailure(nil), t.result.Failures...)
+	result.Files = append([]lib.FileOutcome(nil), t.result.Files...)
+	result.Discrepancies = append([]lib.Discrepancy(nil), t.result.Discrepancies...)
+	return result
+}
+
+// countStage updates the batch tally for a file that reached a terminal stage, or notes when
+// a file is first worked on. Must be called with progressMux held.
+func (t *HandBrakeTranscoder) countStage(file, stage string) {
+	switch stage {
+	case StageDone:
+		t.result.Transcoded++
+	case StageSkipped:
+		t.result.Skipped++
+	case StageFailed:
+		t.result.Failed++
+	default:
+		if _, ok := t.batch.fileStarted[file]; !ok && t.batch.fileStarted != nil {
+			t.batch.fileStarted[file] = time.Now()
+		}
+		return
+	}
+	outcome := lib.FileOutcome{File: file, Outcome: stage, InputBytes: t.batch.sizes[file], OutputBytes: t.batch.outputSizes[file], Quality: t.batch.qualityScores[file]}
+	if started, ok := t.batch.fileStarted[file]; ok {
+		outcome.DurationSeconds = time.Since(started).Seconds()
+	}
+	t.result.Files = append(t.result.Files, outcome)
+}
+
+// batchETA estimates the seconds left in the batch from the rate, in input bytes per second, at
+// which files have been transcoded so far, assuming the files still to come are all encoded.
+// Returns false until a file has been transcoded. Must be called with progressMux held.
+func (t *HandBrakeTranscoder) batchETA() (float64, bool) {
+	var encodedBytes int64
+	var encodeSeconds float64
+	finished := make(map[string]bool, len(t.result.Files))
+	for _, outcome := range t.result.Files {
+		finished[outcome.File] = true
+		if outcome.Outcome == StageDone {
+			encodedBytes += outcome.InputBytes
+			encodeSeconds += outcome.DurationSeconds
+		}
+	}
+	if encodedBytes == 0 || encodeSeconds <= 0 {
+		return 0, false
+	}
+
+	var remaining float64
+	for _, file := range t.batch.files {
+		if !finished[file] {
+			remaining += float64(t.batch.sizes[file])
+		}
+	}
+	if current := t.progress; current.Stage == StageEncoding && !finished[current.File] {
+		remaining -= float64(t.batch.sizes[current.File]) * current.Percent / 100
+	}
+	return max(remaining, 0) * encodeSeconds / float64(encodedBytes), true
+}
+
+// batchRemaining returns how many files of the batch are not finished, including the current one
+func (t *HandBrakeTranscoder) batchRemaining() int {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	return len(t.batch.files) - len(t.result.Files)
+}
+
+// BatchETA returns the estimated time left in the batch, or false if there is no estimate yet
+func (t *HandBrakeTranscoder) BatchETA() (time.Duration, bool) {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	seconds, ok := t.batchETA()
+	return time.Duration(seconds * float64(time.Second)), ok
+}
+
+// recordFailure adds a failed file and its error to the batch tally
+func (t *HandBrakeTranscoder) recordFailure(file string, err error) {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	kind, permanent := failureClass(err)
+	t.result.Failures = append(t.result.Failures, lib.FileFailure{File: file, Error: err.Error(), Kind: kind, Permanent: permanent})
+}
+
+// failTranscoded moves a file counted as transcoded to the failed tally, for an output that
+// failed verification. Returns false if the file is not counted as transcoded, such as when
+// another title of a split file already failed.
+func (t *HandBrakeTranscoder) failTranscoded(file string) bool {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	for i := len(t.result.Files) - 1; i >= 0; i-- {
+		if outcome := &t.result.Files[i]; outcome.File == file && outcome.Outcome == StageDone {
+			outcome.Outcome = StageFailed
+			t.result.Transcoded--
+			t.result.Failed++
+			return true
+		}
+	}
+	return false
+}
+
+// recordSavings adds a completed encode's sizes to the batch tally
+func (t *HandBrakeTranscoder) recordSavings(file string, originalSize, outputSize int64) {
+	t.progressMux.Lock()
+	defer t.progressMux.Unlock()
+	t.result.OriginalBytes += originalSize
+	t.result.OutputBytes += outputSize
+	if t.batch.outputSizes != nil {
+		t.batch.outputSizes[file] = outputSize
+	}
+}
+
+// Summary describes the batch result for notifications
+func (r BatchResult) Summary(title string) *lib.RunSummary {
+	summary := &lib.RunSummary{Title: title, Failures: r.Failures}
+	summary.AddStat(lib.T(lib.MsgTranscoded), "%d", r.Transcoded)
+	summary.AddStat(lib.T(lib.MsgSkipped), "%d", r.Skipped)
+	summary.AddStat(lib.T(lib.MsgFailed), "%d", r.Failed)
+
+	if r.OriginalBytes > 0 {
+		saved := r.OriginalBytes - r.OutputBytes
+		summary.AddStat(lib.T(lib.MsgSpaceSaved), "%s", lib.T(lib.MsgSpaceSavedValue,
+			lib.FormatSize(saved), lib.FormatSize(r.OriginalBytes), float64(saved)/float64(r.OriginalBytes)*100))
+	}
+	if r.WallSeconds > 0 {
+		summary.AddStat(lib.T(lib.MsgWallTime), "%s", lib.FormatDuration(r.WallSeconds))
+	}
+	if means, compared := meanQuality(r.Files); compared > 0 {
+		summary.AddStat(lib.T(lib.MsgQuality), "%s", lib.T(lib.MsgQualityValue, strings.Join(means, ", "), compared))
+	}
+
+	for i, failure := range summary.Failures {
+		summary.Failures[i].File = filepath.Base(failure.File)
+	}
+	return summary
+}
+
+// FillRunReport records the batch's per-file outcomes, counts, sizes, and failures in report
+func (r BatchResult) FillRunReport(report *lib.RunReport) {
+	report.Counts["transcoded"] = r.Transcoded
+	report.Counts["skipped"] = r.Skipped
+	report.Counts["failed"] = r.Failed
+	if len(r.Discrepancies) > 0 {
+		report.Counts["discrepancies"] = len(r.Discrepancies)
+	}
+	report.Discrepancies = append(report.Discrepancies, r.Discrepancies...)
+	report.BytesProcessed = r.OriginalBytes
+	report.BytesWritten = r.OutputBytes
+	report.Failures = append(report.Failures, r.Failures...)
+
+	errs := make(map[string]string, len(r.Failures))
+	for _, failure := range r.Failures {
+		errs[failure.File] = failure.Error
+	}
+	for _, file := range r.Files {
+		file.Error = errs[file.File]
+		report.Files = append(report.Files, file)
+	}
+}
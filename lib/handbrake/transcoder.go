@@ -2,17 +2,22 @@ package handbrake
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"media-mgmt/lib"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -21,33 +26,156 @@ import (
 // Supports batch processing, size estimation, and intelligent skipping of files
 // that don't meet minimum space savings requirements.
 type HandBrakeTranscoder struct {
-	Files             []string // List of files to transcode
-	FileListPath      string   // Path to text file containing file list
-	OutputSuffix      string   // Suffix for output files (e.g., "-optimized")
-	Overwrite         bool     // Whether to overwrite existing output files
-	Quality           int      // Video quality setting (0-100, higher is better)
-	MaxSizeRatio      float64  // Maximum output size as fraction of input (0.0 disables)
-	termWidth         int      // Current terminal width for progress bars
-	termMux           sync.RWMutex // Mutex for terminal width access
+	Files               []string          // List of files to transcode
+	FileListPath        string            // Path to text file containing file list ("-" reads stdin)
+	FileListNUL         bool              // File list entries are NUL-terminated, as from find -print0, rather than lines
+	OutputSuffix        string            // Suffix for output files (e.g., "-optimized")
+	OutputDir           string            // Write outputs into this tree instead of next to inputs (optional)
+	InputRoot           string            // Root whose layout is mirrored under OutputDir
+	Overwrite           bool              // Whether to overwrite existing output files
+	PreserveTimes       bool              // Give outputs their source's access and modification times
+	PreserveOwner       bool              // Give outputs their source's owner, group, permissions, and extended attributes
+	Quality             int               // Video quality setting (0-100, higher is better)
+	RateMode            string            // Rate control: quality (default) or abr
+	Bitrate             int               // Average video bitrate in kbps for abr
+	EncoderPreset       string            // Encoder speed preset, such as slow for x265 (empty uses HandBrake's default)
+	EncoderTune         string            // Encoder tuning, such as grain or animation for x265 (optional)
+	EncoderProfile      string            // Encoder profile, such as main10 (optional)
+	Denoise             string            // Denoise filter for grainy sources: off (default), nlmeans, or hqdn3d
+	DenoiseStrength     string            // HandBrake's denoise preset: ultralight, light, medium (default), or strong
+	DenoiseAll          bool              // Denoise every file, not only grainy sources
+	FilmGrain           int               // Encode grainy sources with SVT-AV1 grain synthesis at this level, 1-50 (0 disables)
+	HandBrakeArgs       []string          // Extra arguments appended to every HandBrakeCLI encode (checked by ValidateHandBrakeArgs)
+	FFmpegArgs          []string          // Extra output arguments for the ffmpeg runs that write outputs (checked by ValidateFFmpegArgs)
+	MaxSizeRatio        float64           // Maximum output size as fraction of input (0.0 disables)
+	SingleEstimate      bool              // Encode all size estimation segments in one HandBrakeCLI run (requires ffmpeg)
+	Lookahead           int               // Files to probe and estimate in the background while encoding (0 processes files one at a time)
+	DropCommentary      bool              // Leave out audio tracks classified as commentary
+	KeepAudioLangs      []string          // Keep only audio tracks in these languages, such as en or jpn, plus the first track (empty keeps all)
+	PassthroughLossless bool              // Copy TrueHD, DTS-HD MA, and FLAC tracks instead of re-encoding them, and verify they survived
+	CFRConvert          bool              // Produce constant frame rate output suitable for editing applications
+	SubtitlePolicy      string            // Which subtitle tracks to keep: all (default), no-sdh, or forced
+	MuxAudioSidecars    bool              // Add external audio files such as movie.commentary.ac3 to the output
+	MuxExternalSubs     bool              // Add SRT and SSA/ASS files such as movie.en.srt to the output as subtitle tracks
+	RemoveExternalSubs  bool              // Delete external subtitle files once they are in the output
+	Sidecars            string            // Copy subtitle, .nfo, and artwork files next to outputs: auto (default, outputs in another directory), always, or off
+	Verify              string            // Post-encode check run in the background: none (default), decode, or vmaf
+	MinVMAF             float64           // Fail outputs scoring below this VMAF (0 records the score only)
+	CompareQuality      []string          // Metrics (psnr, ssim, vmaf) to score each output against its source with, recorded in history and reports (empty disables)
+	QualitySamples      int               // Segments spread over each output that CompareQuality scores (fewer than 1 compares whole files)
+	FallbackSoftware    bool              // Retry a file whose hardware encode fails once with the x265 software encoder
+	EncodeLogDir        string            // Directory to save the complete output of each file's HandBrakeCLI and ffmpeg runs in, gzip-compressed (empty disables)
+	ExportQueuePath     string            // Write the planned encodes to this HandBrake queue file instead of running them
+	Engine              string            // Encoding engine: handbrake (default) or avfoundation
+	Container           string            // Output container: mkv (default) or mp4
+	ContainerFallback   string            // For MP4 output, what to do with streams MP4 cannot hold: mkv (default), convert, or extract
+	LowPower            bool              // Prefer low-power hardware encode paths and cap encoder threads, for quiet, cool machines
+	LowPowerThreads     int               // Encoder thread cap in low-power mode (0 uses half the cores)
+	HWDecode            string            // Hardware decoding: auto (default, the selected encoder's hardware), videotoolbox, nvdec, vaapi, or off
+	ChunkMinSize        int64             // Encode sources at least this large in parallel chunks joined with mkvmerge (0 disables; experimental)
+	ChunkJobs           int               // Chunks to cut a source into and encode at once (fewer than 2 uses 2)
+	SampleGateSize      int64             // Sources at least this large must pass a sample encode before the full encode (0 disables)
+	SampleMinVMAF       float64           // VMAF score the sample must reach (0 checks decoding only)
+	History             *lib.HistoryStore // Store for encode records and speed predictions (nil disables)
+	Leases              *lib.LeaseStore   // Per-file leases keeping overlapping runs off the same file (nil disables)
+	StaleTempPolicy     string            // How to handle stale .tmp outputs found at startup
+	StaleTempAge        time.Duration     // Minimum age before a .tmp output is considered stale
+	StatusFile          string            // Keep a JSON snapshot of the batch's progress in this file (optional)
+	StatusSocket        string            // Answer connections to this UNIX socket with the batch's progress as JSON (optional)
+	MultiTitle          string            // What to do with files that look like several titles in one: warn (default), skip, or split
+	Presets             []lib.Preset      // Settings for kinds of content, overriding Quality for the files they match (unless encoding at a bitrate)
+	termWidth           int               // Current terminal width for progress bars
+	termMux             sync.RWMutex      // Mutex for terminal width access
+	OnProgress          func(Progress)    // Callback invoked on progress updates (optional)
+	HideProgressBar     bool              // Leave live progress to OnProgress or a Dashboard rather than drawing it on stdout
+	Interactive         bool              // Analyze and estimate every file, then let the user deselect files before encoding starts
+	lastAvgFPS          float64           // Most recent average fps reported by HandBrake
+	progress            Progress          // Progress of the file currently being processed
+	progressMux         sync.Mutex        // Mutex for progress state and result access
+	result              BatchResult       // Tally of processed files
+	capabilities        *lib.Capabilities // External tool features, detected once per Run
+	verifier            *verifyQueue      // Background verification of finished outputs (nil when disabled)
+	lowPowerQSV         bool              // Low-power mode encodes with Intel Quick Sync, detected once per Run
+	hwDecoder           string            // HandBrakeCLI's --enable-hw-decoding method, detected once per Run ("" decodes in software)
+	hwAccel             string            // ffmpeg's -hwaccel method, detected once per Run ("" decodes in software)
+	sampleChecks        sampleGateChecks  // Checks samples of large sources get, detected once per Run
+	status              *statusReporter   // Publishes progress to StatusFile and StatusSocket (nil when neither is set)
+	batch               batchProgress     // The batch's files and how far it has got through them
+	estimates           map[string]int64  // Output sizes estimated while planning an Interactive batch
 }
 
 // Run executes the transcoding process for all configured files.
 // Handles setup, file processing, and graceful shutdown on context cancellation.
 // Returns an error if HandBrakeCLI is unavailable or if critical failures occur.
 func (t *HandBrakeTranscoder) Run(ctx context.Context) error {
-	if err := t.checkHandBrakeCLI(); err != nil {
+	if t.usesAVFoundation() {
+		if err := checkAVConvert(); err != nil {
+			return fmt.Errorf("AVFoundation engine not available: %w", err)
+		}
+	} else if err := t.checkHandBrakeCLI(); err != nil {
 		return fmt.Errorf("HandBrakeCLI not available: %w", err)
 	}
 
 	t.initTerminalWidth()
 	t.setupWinchHandler()
 
-	hasVideoToolbox, err := t.detectVideoToolbox()
-	if err != nil {
-		slog.Warn("Failed to detect VideoToolbox", "error", err)
-		hasVideoToolbox = false
+	var tools []string
+	if !t.usesAVFoundation() {
+		tools = append(tools, "HandBrakeCLI")
+	}
+	verifying := t.Verify != "" && t.Verify != VerifyNone
+	singleEstimate := t.SingleEstimate && t.MaxSizeRatio > 0.0
+	chunked := t.ChunkMinSize > 0 && !t.usesAVFoundation()
+	comparing := len(t.CompareQuality) > 0
+	if t.MuxAudioSidecars || verifying || singleEstimate || t.SampleGateSize > 0 || t.extractsSubtitles() || chunked || comparing {
+		tools = append(tools, "ffmpeg")
 	}
+	if chunked {
+		tools = append(tools, "mkvmerge")
+	}
+	t.capabilities = lib.DetectCapabilities(ctx, lib.DefaultCapabilitiesPath(), tools...)
+	hasVideoToolbox := t.detectVideoToolbox()
 	slog.Info("VideoToolbox support", "available", hasVideoToolbox)
+	t.lowPowerQSV = t.detectLowPowerQSV()
+	t.logLowPower(hasVideoToolbox)
+	t.detectHWDecode(hasVideoToolbox)
+	t.logEncoderOptions()
+	if !t.usesAVFoundation() {
+		if err := t.capabilities.Check(encoderRequirement("transcoding", t.selectEncoder(&lib.VideoInfo{}, hasVideoToolbox))); err != nil {
+			return err
+		}
+	}
+	if t.MuxAudioSidecars {
+		if err := t.capabilities.Check(sidecarMuxRequirement); err != nil {
+			return err
+		}
+	}
+	if t.extractsSubtitles() {
+		if err := t.capabilities.Check(subtitleExtractRequirement); err != nil {
+			return err
+		}
+	}
+	if chunked {
+		if err := t.capabilities.Check(chunkSplitRequirement, chunkJoinRequirement); err != nil {
+			return err
+		}
+	}
+	if singleEstimate {
+		if err := t.capabilities.Check(singleEstimateRequirement); err != nil {
+			return err
+		}
+	}
+	if verifying {
+		if err := t.capabilities.Check(verifyRequirement(t.Verify)); err != nil {
+			return err
+		}
+	}
+	if comparing {
+		if err := t.capabilities.Check(compareQualityRequirement(t.CompareQuality)); err != nil {
+			return err
+		}
+	}
+	t.sampleChecks = t.detectSampleGateChecks()
 
 	files, err := t.getFileList()
 	if err != nil {
@@ -56,6 +184,45 @@ func (t *HandBrakeTranscoder) Run(ctx context.Context) error {
 
 	slog.Info("Processing files", "count", len(files))
 
+	if t.ExportQueuePath != "" {
+		return t.exportQueue(ctx, files, hasVideoToolbox)
+	}
+
+	t.recoverStaleTempFiles(files)
+
+	t.logBatchPrediction(files, hasVideoToolbox)
+
+	toEncode := files
+	var skipped []string
+	if t.Interactive {
+		toEncode, skipped, err = t.selectFiles(ctx, files, hasVideoToolbox)
+		if errors.Is(err, errSelectionQuit) {
+			slog.Info("File selection quit, nothing transcoded")
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	t.startBatch(files)
+	if t.status, err = t.startStatusReporter(); err != nil {
+		return err
+	}
+	t.reportDeselected(files, skipped)
+	t.verifier = t.startVerifier(ctx, len(toEncode))
+	err = t.processFiles(ctx, toEncode, hasVideoToolbox)
+	t.verifier.finish()
+	t.reconcile(files)
+	t.finishBatch(ctx, err)
+	return err
+}
+
+// processFiles transcodes the files in order, stopping early only if ctx is cancelled
+func (t *HandBrakeTranscoder) processFiles(ctx context.Context, files []string, hasVideoToolbox bool) error {
+	if t.Lookahead > 0 {
+		return t.runPipelined(ctx, files, hasVideoToolbox)
+	}
+
 	for i, file := range files {
 		select {
 		case <-ctx.Done():
@@ -66,71 +233,318 @@ func (t *HandBrakeTranscoder) Run(ctx context.Context) error {
 
 		fileNum := i + 1
 		totalFiles := len(files)
-		if err := t.transcodeFile(ctx, file, hasVideoToolbox, fileNum, totalFiles); err != nil {
-			slog.Error("Failed to transcode file", "file", file, "error", err)
-			if ctx.Err() != nil {
-				slog.Info("Context cancelled, stopping file processing")
-				return ctx.Err()
-			}
-			continue
+		fileCtx, done := t.abortableFile(ctx, file)
+		err := t.transcodeFile(fileCtx, file, hasVideoToolbox, fileNum, totalFiles)
+		aborted := fileAborted(fileCtx)
+		done()
+		if err != nil && aborted && ctx.Err() == nil {
+			t.handleFileAborted(file, fileNum, totalFiles)
+		} else if err != nil && t.handleFileError(ctx, file, fileNum, totalFiles, err) {
+			return ctx.Err()
 		}
 	}
 
 	return nil
 }
 
+// handleFileError records a file that failed to transcode. Returns true if the batch was
+// cancelled and should stop.
+func (t *HandBrakeTranscoder) handleFileError(ctx context.Context, file string, fileNum, totalFiles int, err error) bool {
+	kind, permanent := failureClass(err)
+	slog.Error("Failed to transcode file", "file", file, "stage", StageFailed, "kind", kind, "error", err)
+	t.recordFailure(file, err)
+	t.setProgressStage(file, fileNum, totalFiles, StageFailed)
+	if ctx.Err() != nil {
+		slog.Info("Context cancelled, stopping file processing")
+		return true
+	}
+
+	params := map[string]string{"error": err.Error()}
+	if kind != "" {
+		params["reason"] = kind
+		if permanent {
+			params["permanent"] = "true"
+		}
+	}
+	t.recordAction(file, lib.HistoryActionFailed, params)
+	return false
+}
+
+// permanentError marks a failure that retrying cannot fix, such as a source the encode would
+// ruin, so transcode --retry-failed leaves the file out. HandBrake failures are classified as
+// HandBrakeError instead.
+type permanentError struct {
+	reason string // Short machine-readable cause recorded in history
+	err    error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// preparedFile is a file that has been checked, probed, and, when MaxSizeRatio is set,
+// estimated, so that only the encode remains
+type preparedFile struct {
+	path         string
+	skipped      bool             // Handled without encoding: the output exists, a skip file was found, or savings were insufficient
+	titles       []lib.TitleRange // Titles to encode to separate outputs when a multi-title file is split (nil encodes the file whole)
+	videoInfo    *lib.VideoInfo
+	before       *lib.MediaInfo
+	sidecars     []lib.AudioSidecar
+	subtitles    []lib.ExternalSubtitle // External subtitle files to add as tracks
+	originalSize int64
+	lease        *lib.Lease // Held from preparation until the encode finishes
+	log          *encodeLog // Where the file's tool runs are saved (nil without EncodeLogDir)
+}
+
+// release gives up the file's lease. Safe to call on a nil preparedFile.
+func (p *preparedFile) release() {
+	if p != nil {
+		p.lease.Release()
+	}
+}
+
 // transcodeFile processes a single video file through the complete transcoding pipeline.
 // Handles output path checking, skip file validation, size estimation, and actual transcoding.
 // Returns an error if any step fails, or nil if the file is successfully processed or skipped.
 func (t *HandBrakeTranscoder) transcodeFile(ctx context.Context, filePath string, hasVideoToolbox bool, fileNum, totalFiles int) error {
 	slog.Info("Processing file", "current", fileNum, "total", totalFiles, "file", filepath.Base(filePath))
 
-	finalOutputPath := t.generateOutputPath(filePath)
+	prepared, err := t.prepareFile(ctx, filePath, hasVideoToolbox, func() {
+		t.setProgressStage(filePath, fileNum, totalFiles, StageEstimating)
+	})
+	if err != nil {
+		return err
+	}
+	return t.encodeFile(ctx, prepared, hasVideoToolbox, fileNum, totalFiles)
+}
+
+// prepareFile does everything that precedes the encode: output and skip file checks, probing,
+// capability checks, and size estimation. onEstimate is called before estimation starts; it is
+// nil when the file is prepared in the background, so progress stays with the file encoding.
+func (t *HandBrakeTranscoder) prepareFile(ctx context.Context, filePath string, hasVideoToolbox bool, onEstimate func()) (*preparedFile, error) {
+	prepared := &preparedFile{path: filePath, log: t.newEncodeLog(filePath)}
+	if t.Leases != nil {
+		lease, err := t.Leases.Acquire(filePath)
+		var held *lib.LeaseHeldError
+		if errors.As(err, &held) {
+			slog.Info("File is leased by another run, skipping", "file", filepath.Base(filePath), "holder", held.Holder.Command, "host", held.Holder.Host, "pid", held.Holder.PID, "stage", StageSkipped)
+			t.recordAction(filePath, lib.HistoryActionSkipped, map[string]string{"reason": "leased"})
+			prepared.skipped = true
+			return prepared, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		prepared.lease = lease
+	}
+
+	checked, err := t.checkAndProbe(withEncodeLog(ctx, prepared.log), prepared, hasVideoToolbox, onEstimate)
+	if err != nil {
+		prepared.release()
+		return nil, err
+	}
+	return checked, nil
+}
+
+// checkAndProbe does the work of prepareFile once the file is leased
+func (t *HandBrakeTranscoder) checkAndProbe(ctx context.Context, prepared *preparedFile, hasVideoToolbox bool, onEstimate func()) (*preparedFile, error) {
+	filePath := prepared.path
+
 	if !t.Overwrite {
-		if _, err := os.Stat(finalOutputPath); err == nil {
-			slog.Info("Output file already exists, skipping", "file", finalOutputPath)
-			return nil
+		if finalOutputPath, ok := t.existingOutput(filePath); ok {
+			slog.Info("Output file already exists, skipping", "file", finalOutputPath, "stage", StageSkipped)
+			t.recordAction(filePath, lib.HistoryActionSkipped, map[string]string{"reason": "output_exists", "output_path": finalOutputPath})
+			prepared.skipped = true
+			return prepared, nil
 		}
 	}
 
 	// Check for existing skip file first
 	if t.MaxSizeRatio > 0.0 {
 		if t.checkSkipFile(filePath) {
-			slog.Info("Skipping media with skip file", "file", filepath.Base(filePath))
-			return nil
+			slog.Info("Skipping media with skip file", "file", filepath.Base(filePath), "stage", StageSkipped)
+			t.recordAction(filePath, lib.HistoryActionSkipped, map[string]string{"reason": "skip_file"})
+			prepared.skipped = true
+			return prepared, nil
+		}
+	}
+
+	if t.usesAVFoundation() {
+		if err := checkAVFoundationInput(filePath); err != nil {
+			return nil, err
 		}
 	}
 
 	videoInfo, err := lib.GetVideoInfo(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to get video info: %w", err)
+		return nil, fmt.Errorf("failed to get video info: %w", err)
+	}
+	if videoInfo.IsHDR && !t.usesAVFoundation() {
+		if err := t.capabilities.Check(encoderRequirement("HDR transcoding", t.selectEncoder(videoInfo, hasVideoToolbox))); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDolbyVisionBase(filePath, videoInfo); err != nil {
+		return nil, err
+	}
+	if videoInfo.HDR.HasDynamicMetadata() && !t.usesAVFoundation() {
+		if err := t.capabilities.Check(dynamicMetadataRequirement); err != nil {
+			return nil, err
+		}
+	}
+	if t.synthesizesGrain(videoInfo) {
+		if err := t.capabilities.Check(encoderRequirement("film grain synthesis", filmGrainEncoder(videoInfo))); err != nil {
+			return nil, err
+		}
+	}
+	prepared.videoInfo = videoInfo
+	t.logPreset(videoInfo)
+	t.logGrain(videoInfo)
+	if t.checkMultiTitle(prepared) {
+		prepared.skipped = true
+		return prepared, nil
 	}
 
-	originalFileInfo, err := os.Stat(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to get original file info: %w", err)
+	if t.MuxAudioSidecars && prepared.titles != nil {
+		slog.Warn("Audio sidecars cover the whole file and are not added to split titles", "file", filepath.Base(filePath))
+	} else if t.MuxAudioSidecars {
+		prepared.sidecars = lib.NewMediaAnalyzer().AnalyzeAudioSidecars(ctx, filePath)
+	}
+	if t.MuxExternalSubs && prepared.titles != nil {
+		slog.Warn("External subtitles cover the whole file and are not added to split titles", "file", filepath.Base(filePath))
+	} else if t.MuxExternalSubs {
+		prepared.subtitles = t.externalSubtitles(filePath)
 	}
-	originalFileSize := originalFileInfo.Size()
 
-	if err := lib.PrintMediaInfo(filePath); err != nil {
-		slog.Warn("Failed to print media info", "file", filePath, "error", err)
+	originalFileInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get original file info: %w", err)
 	}
+	prepared.originalSize = originalFileInfo.Size()
 
 	// Perform size estimation if minimum savings threshold is set
 	if t.MaxSizeRatio > 0.0 {
-		shouldSkip, err := t.checkSizeSavings(ctx, filePath, originalFileSize, videoInfo, hasVideoToolbox)
+		if onEstimate != nil {
+			onEstimate()
+		} else {
+			ctx = withQuietHandBrake(ctx)
+		}
+		shouldSkip, err := t.checkSizeSavings(ctx, filePath, prepared.originalSize, videoInfo, hasVideoToolbox)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			slog.Warn("Size check failed, proceeding with full encode", "file", filePath, "error", err)
 		} else if shouldSkip {
-			return nil
+			prepared.skipped = true
+			return prepared, nil
+		}
+	}
+
+	if t.needsSample(prepared.originalSize) {
+		if err := t.checkSample(ctx, filePath, videoInfo, hasVideoToolbox); err != nil {
+			return nil, err
+		}
+	}
+	return prepared, nil
+}
+
+// checkDolbyVisionBase refuses Dolby Vision sources without an HDR10 or SDR base layer, such as
+// profile 5, whose colors are wrong once the Dolby Vision metadata is dropped
+func checkDolbyVisionBase(filePath string, videoInfo *lib.VideoInfo) error {
+	if hdr := videoInfo.HDR; hdr != nil && hdr.Format == lib.HDRFormatDolbyVision && hdr.DVCompatibilityID == lib.DVCompatibilityNone {
+		return &permanentError{reason: "dv_no_base_layer", err: fmt.Errorf("cannot transcode %s: its %s video has no HDR10 or SDR base layer and would lose its colors", filePath, hdr)}
+	}
+	return nil
+}
+
+// encodeFile encodes a prepared file to a temporary output and moves it into place
+func (t *HandBrakeTranscoder) encodeFile(ctx context.Context, prepared *preparedFile, hasVideoToolbox bool, fileNum, totalFiles int) error {
+	defer prepared.release()
+	ctx = withEncodeLog(ctx, prepared.log)
+	filePath, videoInfo := prepared.path, prepared.videoInfo
+	if prepared.skipped {
+		t.setProgressStage(filePath, fileNum, totalFiles, StageSkipped)
+		return nil
+	}
+
+	// The source is analyzed once, for the log and the history records of its outputs
+	if prepared.before = t.analyzeFile(ctx, filePath); prepared.before != nil {
+		lib.LogMediaInfo(prepared.before, 0)
+	}
+
+	container := t.containerFor(videoInfo)
+	t.logContainerChoice(filePath, videoInfo, container)
+	finalOutputPath := t.outputPathFor(filePath, container)
+
+	encoder := t.selectEncoder(videoInfo, hasVideoToolbox)
+	if predicted, ok := t.predictEncodeSeconds(videoInfo, encoder); ok {
+		slog.Info("Predicted encode time", "file", filepath.Base(filePath), "eta", lib.FormatDuration(predicted))
+	}
+
+	t.setProgressStage(filePath, fileNum, totalFiles, StageEncoding)
+	if prepared.titles == nil {
+		elapsed, err := t.encodeOutput(ctx, prepared, finalOutputPath, nil, hasVideoToolbox)
+		if err != nil {
+			return err
+		}
+		if outputInfo, err := os.Stat(finalOutputPath); err == nil {
+			t.recordSavings(filePath, prepared.originalSize, outputInfo.Size())
 		}
+		t.setProgressStage(filePath, fileNum, totalFiles, StageDone)
+		t.verifier.add(verifyJob{source: filePath, output: finalOutputPath, videoInfo: videoInfo, log: prepared.log})
+
+		slog.Info("Successfully transcoded",
+			"file", filepath.Base(finalOutputPath),
+			"stage", StageDone,
+			"duration_ms", elapsed.Milliseconds())
+		return nil
 	}
 
+	var outputSize int64
+	var total time.Duration
+	var verifyJobs []verifyJob
+	for i := range prepared.titles {
+		title := &prepared.titles[i]
+		outputPath := titleOutputPath(finalOutputPath, i+1)
+		slog.Info("Encoding title", "file", filepath.Base(filePath), "title", i+1, "titles", len(prepared.titles))
+		elapsed, err := t.encodeOutput(ctx, prepared, outputPath, title, hasVideoToolbox)
+		if err != nil {
+			if fileAborted(ctx) {
+				t.removeTitleOutputs(prepared, finalOutputPath, i)
+			}
+			return fmt.Errorf("title %d: %w", i+1, err)
+		}
+		if outputInfo, err := os.Stat(outputPath); err == nil {
+			outputSize += outputInfo.Size()
+		}
+		total += elapsed
+		verifyJobs = append(verifyJobs, verifyJob{source: filePath, output: outputPath, videoInfo: videoInfo, title: title, log: prepared.log})
+	}
+	// Titles are verified once all are written, as an aborted file's titles are removed
+	for _, job := range verifyJobs {
+		t.verifier.add(job)
+	}
+	t.recordSavings(filePath, prepared.originalSize, outputSize)
+	t.setProgressStage(filePath, fileNum, totalFiles, StageDone)
+	slog.Info("Successfully transcoded titles",
+		"file", filepath.Base(filePath),
+		"titles", len(prepared.titles),
+		"stage", StageDone,
+		"duration_ms", total.Milliseconds())
+	return nil
+}
+
+// encodeOutput encodes a prepared file, or one of its titles, to a temporary output, moves it
+// into place, and records it in the history. Returns how long the encode took.
+func (t *HandBrakeTranscoder) encodeOutput(ctx context.Context, prepared *preparedFile, finalOutputPath string, title *lib.TitleRange, hasVideoToolbox bool) (time.Duration, error) {
+	filePath, videoInfo := prepared.path, prepared.videoInfo
+	container := t.containerFor(videoInfo)
 	inProgressPath := finalOutputPath + ".tmp"
 	outputDir := filepath.Dir(inProgressPath)
 
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+		return 0, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	cleanupFile := true
@@ -144,27 +558,156 @@ func (t *HandBrakeTranscoder) transcodeFile(ctx context.Context, filePath string
 		}
 	}()
 
-	if err := t.executeTranscode(ctx, filePath, inProgressPath, videoInfo, hasVideoToolbox); err != nil {
-		return fmt.Errorf("failed to execute transcode: %w", err)
+	encoder := t.selectEncoder(videoInfo, hasVideoToolbox)
+	t.setLastAvgFPS(0)
+	encodeStart := time.Now()
+	err := t.encodeVideo(ctx, prepared, inProgressPath, title, encoder)
+	if fallback, ok := t.softwareFallback(ctx, encoder, videoInfo, err); ok {
+		slog.Warn("Hardware encode failed, retrying with the software encoder", "file", filepath.Base(filePath), "encoder", encoder, "fallback", fallback, "error", err)
+		if len(t.encoderOptionArgs()) > 0 {
+			slog.Info("Leaving the encoder options out of the software encode", "preset", t.EncoderPreset, "tune", t.EncoderTune, "profile", t.EncoderProfile)
+		}
+		os.Remove(inProgressPath)
+		encoder = fallback
+		t.setLastAvgFPS(0)
+		encodeStart = time.Now()
+		err = t.encodeVideo(withSoftwareFallback(ctx), prepared, inProgressPath, title, encoder)
+	}
+	if err != nil {
+		return 0, err
+	}
+	elapsed := time.Since(encodeStart)
+
+	if err := t.verifyLosslessAudio(inProgressPath, videoInfo.AudioTracks); err != nil {
+		return 0, err
+	}
+	if len(prepared.sidecars) > 0 {
+		slog.Info("Adding audio sidecars", "file", filepath.Base(filePath), "count", len(prepared.sidecars))
+		existingAudio := len(t.selectedAudioTracks(videoInfo.AudioTracks))
+		if err := t.muxAudioSidecars(ctx, inProgressPath, container, existingAudio, prepared.sidecars); err != nil {
+			return 0, fmt.Errorf("failed to add audio sidecars: %w", err)
+		}
+	}
+
+	extracting := container == ContainerMP4 && t.extractsSubtitles()
+	if extracting {
+		if err := t.extractSubtitles(ctx, filePath, finalOutputPath, videoInfo.SubtitleTracks, title); err != nil {
+			return 0, fmt.Errorf("failed to extract subtitles: %w", err)
+		}
 	}
 
+	_, statErr := os.Stat(finalOutputPath)
+	replacing := statErr == nil
+
 	if err := os.Rename(inProgressPath, finalOutputPath); err != nil {
-		return fmt.Errorf("failed to move temp file to final location: %w", err)
+		// Subtitles saved for an output that never arrived would be left without a video
+		if extracting {
+			removeExtractedSubtitles(t.extractedSubtitles(finalOutputPath, videoInfo.SubtitleTracks))
+		}
+		return 0, fmt.Errorf("failed to move temp file to final location: %w", err)
 	}
 	cleanupFile = false
+	t.preserveAttributes(filePath, finalOutputPath)
 
-	if err := lib.PrintMediaInfoWithRatio(finalOutputPath, originalFileSize); err != nil {
-		slog.Warn("Failed to print media info for converted file", "file", finalOutputPath, "error", err)
+	// The output is analyzed once, for the log and its history record. Titles are only
+	// analyzed for the history.
+	var after *lib.MediaInfo
+	if title == nil || t.History != nil {
+		after = t.analyzeFile(ctx, finalOutputPath)
+	}
+	if title == nil && after != nil {
+		lib.LogMediaInfo(after, prepared.originalSize)
+	}
+	if title == nil {
+		t.copySidecars(filePath, finalOutputPath, prepared.subtitles)
+		t.removeExternalSubtitles(prepared.subtitles)
 	}
 
-	slog.Info("Successfully transcoded", "file", filepath.Base(finalOutputPath))
+	// The output is in place by now, so a comparison that fails is logged rather than failing it
+	var scores map[string]float64
+	if len(t.CompareQuality) > 0 {
+		var err error
+		if scores, err = t.compareQuality(ctx, filePath, finalOutputPath, videoInfo, title); err != nil {
+			// Aborting the file once its output is in place only cuts the comparison short
+			if ctx.Err() != nil && !fileAborted(ctx) {
+				return 0, ctx.Err()
+			}
+			slog.Warn("Failed to compare output quality", "file", filepath.Base(finalOutputPath), "error", err)
+		} else {
+			slog.Info("Output quality", append([]any{"file", filepath.Base(finalOutputPath)}, qualityAttrs(scores)...)...)
+			t.recordQuality(filePath, scores)
+		}
+	}
+
+	originalSize := prepared.originalSize
+	if title != nil && videoInfo.Duration > 0 {
+		originalSize = int64(float64(originalSize) * (title.End - title.Start) / videoInfo.Duration)
+	}
+	t.recordTranscode(filePath, finalOutputPath, prepared.before, after, titleVideoInfo(videoInfo, title), encoder, originalSize, elapsed, scores)
+	if replacing {
+		t.recordAction(filePath, lib.HistoryActionReplaced, map[string]string{"output_path": finalOutputPath})
+	}
+	return elapsed, nil
+}
+
+// encodeVideo encodes a file, or one of its titles, to outputPath with encoder
+func (t *HandBrakeTranscoder) encodeVideo(ctx context.Context, prepared *preparedFile, outputPath string, title *lib.TitleRange, encoder string) error {
+	if count := t.chunkCount(prepared, title); count > 0 {
+		if err := t.encodeChunked(ctx, prepared, outputPath, count, encoder); err != nil {
+			return fmt.Errorf("chunked encode failed: %w", err)
+		}
+		return nil
+	}
+	if err := t.executeTranscode(ctx, prepared.path, outputPath, prepared.videoInfo, prepared.subtitles, title, encoder); err != nil {
+		return fmt.Errorf("failed to execute transcode: %w", err)
+	}
+	return nil
+}
+
+// softwareFallback returns the software encoder to retry a failed encode with when
+// FallbackSoftware is set and HandBrake failed with a hardware encoder. Failures the software
+// encoder would run into as well, such as a corrupt source or a full disk, are not retried.
+func (t *HandBrakeTranscoder) softwareFallback(ctx context.Context, encoder string, videoInfo *lib.VideoInfo, err error) (string, bool) {
+	if !t.FallbackSoftware || err == nil || ctx.Err() != nil || !lib.IsHardwareEncoder(encoder) {
+		return "", false
+	}
+	var handBrakeErr *HandBrakeError
+	if !errors.As(err, &handBrakeErr) || handBrakeErr.Permanent() || handBrakeErr.Kind == FailureDiskFull {
+		return "", false
+	}
+	return softwareEncoder(videoInfo), true
+}
+
+// preserveAttributes copies the source's attributes chosen by PreserveTimes and PreserveOwner
+// to its output. The encode has succeeded by now, so attributes that cannot be copied are
+// logged rather than failing the file.
+func (t *HandBrakeTranscoder) preserveAttributes(inputPath, outputPath string) {
+	opts := lib.PreserveOptions{Times: t.PreserveTimes, Owner: t.PreserveOwner}
+	if err := lib.CopyFileAttributes(inputPath, outputPath, opts); err != nil {
+		slog.Warn("Failed to copy file attributes to output", "file", filepath.Base(outputPath), "error", err)
+	}
+}
+
+// verifyLosslessAudio checks that lossless tracks requested for passthrough survived the encode
+func (t *HandBrakeTranscoder) verifyLosslessAudio(outputPath string, sourceTracks []lib.AudioTrack) error {
+	if t.audioEncoderArgs(sourceTracks) == nil {
+		return nil
+	}
+	output, err := lib.GetVideoInfo(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to verify lossless audio: %w", err)
+	}
+	if problems := losslessDegradations(t.selectedAudioTracks(sourceTracks), output.AudioTracks); len(problems) > 0 {
+		return fmt.Errorf("lossless audio was not preserved: %s", strings.Join(problems, "; "))
+	}
+	slog.Info("Verified lossless audio passthrough", "file", filepath.Base(outputPath))
 	return nil
 }
 
 // checkHandBrakeCLI verifies that HandBrakeCLI is available in the system PATH.
 // Returns an error with installation instructions if HandBrakeCLI is not found.
 func (t *HandBrakeTranscoder) checkHandBrakeCLI() error {
-	_, err := exec.LookPath("HandBrakeCLI")
+	_, err := exec.LookPath(lib.ToolCommand("HandBrakeCLI"))
 	if err != nil {
 		return fmt.Errorf("HandBrakeCLI not found in PATH. Install with: brew install handbrake")
 	}
@@ -172,59 +715,88 @@ func (t *HandBrakeTranscoder) checkHandBrakeCLI() error {
 }
 
 // detectVideoToolbox checks if VideoToolbox hardware acceleration is available.
-// Only available on macOS systems with compatible hardware.
-// Returns true if VideoToolbox encoders are detected in HandBrakeCLI help output.
-func (t *HandBrakeTranscoder) detectVideoToolbox() (bool, error) {
-	cmd := exec.Command("uname", "-s")
-	output, err := cmd.Output()
-	if err != nil {
-		return false, err
-	}
-
-	if strings.TrimSpace(string(output)) != "Darwin" {
-		return false, nil
+// Only available on macOS systems whose HandBrakeCLI lists the VideoToolbox encoders,
+// or always when AVFoundation, which encodes with VideoToolbox, is the engine.
+func (t *HandBrakeTranscoder) detectVideoToolbox() bool {
+	if runtime.GOOS != "darwin" {
+		return false
 	}
-
-	cmd = exec.Command("HandBrakeCLI", "--help")
-	output, err = cmd.Output()
-	if err != nil {
-		return false, err
+	if t.usesAVFoundation() {
+		return true
 	}
+	handBrake := t.capabilities.Tool("HandBrakeCLI")
+	return handBrake != nil && handBrake.HasEncoder("vt_h265")
+}
 
-	helpText := string(output)
-	return strings.Contains(helpText, "vt_h265") || strings.Contains(helpText, "VideoToolbox"), nil
+// encoderRequirement is the HandBrakeCLI capability needed to encode with encoder
+func encoderRequirement(feature, encoder string) lib.Requirement {
+	return lib.Requirement{Feature: feature, Tool: "HandBrakeCLI", Encoders: []string{encoder}}
 }
 
 // getFileList combines files from direct specification and file list into a single slice.
-// Processes the FileListPath if specified, filtering out comments and empty lines.
+// Processes the FileListPath if specified, reading stdin for "-".
 // Returns the combined list of files to process, or an error if file reading fails.
 func (t *HandBrakeTranscoder) getFileList() ([]string, error) {
 	var files []string
 
 	files = append(files, t.Files...)
 	if t.FileListPath != "" {
-		file, err := os.Open(t.FileListPath)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open file list: %w", err)
-		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			if line != "" && !strings.HasPrefix(line, "#") {
-				files = append(files, line)
+		input := os.Stdin
+		if t.FileListPath != "-" {
+			file, err := os.Open(t.FileListPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open file list: %w", err)
 			}
+			defer file.Close()
+			input = file
 		}
 
-		if err := scanner.Err(); err != nil {
+		listed, err := readFileList(input, t.FileListNUL)
+		if err != nil {
 			return nil, fmt.Errorf("failed to read file list: %w", err)
 		}
+		files = append(files, listed...)
 	}
 
 	return files, nil
 }
 
+// readFileList reads file paths one per line, skipping blank lines and "#" comments and trimming
+// surrounding whitespace. NUL-terminated entries are taken verbatim, since they come from tools
+// such as find -print0 whose output may contain any character a file name can.
+func readFileList(r io.Reader, nul bool) ([]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if nul {
+		scanner.Split(scanNUL)
+	}
+	for scanner.Scan() {
+		if nul {
+			if entry := scanner.Text(); entry != "" {
+				files = append(files, entry)
+			}
+			continue
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			files = append(files, line)
+		}
+	}
+	return files, scanner.Err()
+}
+
+// scanNUL is a bufio.SplitFunc for NUL-terminated entries
+func scanNUL(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
 // initTerminalWidth determines and stores the current terminal width.
 // Uses a default of 80 columns if terminal detection fails.
 // Thread-safe access via mutex for concurrent progress bar rendering.
@@ -260,4 +832,4 @@ func (t *HandBrakeTranscoder) getTerminalWidth() int {
 	t.termMux.RLock()
 	defer t.termMux.RUnlock()
 	return t.termWidth
-}
\ No newline at end of file
+}
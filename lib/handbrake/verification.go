@@ -0,0 +1,216 @@
+package handbrake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Post-encode verification levels
+const (
+	VerifyNone   = "none"
+	VerifyDecode = "decode" // Decode the whole output and fail on any decoder error
+	VerifyVMAF   = "vmaf"   // Decode check plus a VMAF score against the source
+)
+
+// vmafThreads keeps VMAF scoring from competing with the encoder for every core
+const vmafThreads = 2
+
+// vmafScorePattern finds the pooled score ffmpeg's libvmaf filter logs when it finishes
+var vmafScorePattern = regexp.MustCompile(`VMAF score[:=]\s*([0-9.]+)`)
+
+// verifyRequirement returns the ffmpeg features needed for a verification level
+func verifyRequirement(level string) lib.Requirement {
+	req := lib.Requirement{Feature: "--verify " + level, Tool: "ffmpeg"}
+	if level == VerifyVMAF {
+		req.Filters = []string{"libvmaf"}
+	}
+	return req
+}
+
+// verifyJob is an encoded output waiting to be checked
+type verifyJob struct {
+	source    string
+	output    string
+	videoInfo *lib.VideoInfo  // Source video, whose dimensions VMAF scores against
+	title     *lib.TitleRange // Part of the source a split title's output was encoded from (nil for the whole source)
+	log       *encodeLog      // The source's encode log, which verification runs are saved to
+}
+
+// verifyQueue checks encoded outputs one at a time on a background goroutine, so verification
+// of one file overlaps the encode of the next
+type verifyQueue struct {
+	jobs chan verifyJob
+	done sync.WaitGroup
+}
+
+// startVerifier starts the verification worker for a batch of up to size files. Returns nil if
+// verification is disabled.
+func (t *HandBrakeTranscoder) startVerifier(ctx context.Context, size int) *verifyQueue {
+	if t.Verify == "" || t.Verify == VerifyNone {
+		return nil
+	}
+	queue := &verifyQueue{jobs: make(chan verifyJob, size)}
+	queue.done.Add(1)
+	go func() {
+		defer queue.done.Done()
+		for job := range queue.jobs {
+			if ctx.Err() != nil {
+				continue
+			}
+			t.verifyOutput(ctx, job)
+		}
+	}()
+	return queue
+}
+
+// add queues an output for verification; a nil queue ignores it
+func (q *verifyQueue) add(job verifyJob) {
+	if q == nil {
+		return
+	}
+	q.jobs <- job
+}
+
+// finish waits for queued verifications to complete
+func (q *verifyQueue) finish() {
+	if q == nil {
+		return
+	}
+	close(q.jobs)
+	if pending := len(q.jobs); pending > 0 {
+		slog.Info("Waiting for verification to finish", "pending", pending)
+	}
+	q.done.Wait()
+}
+
+// verifyOutput checks an encoded output and records the result. A failure counts the file as
+// failed and records it in the history, so --retry-failed picks it up, but leaves the output in
+// place for inspection.
+func (t *HandBrakeTranscoder) verifyOutput(ctx context.Context, job verifyJob) {
+	ctx = withEncodeLog(ctx, job.log)
+	slog.Info("Verifying output", "file", filepath.Base(job.output), "check", t.Verify)
+	start := time.Now()
+	params := map[string]string{"check": t.Verify, "output_path": job.output}
+
+	err := t.checkDecode(ctx, job.output)
+	if err == nil && t.Verify == VerifyVMAF {
+		var score float64
+		score, err = t.scoreVMAF(ctx, job)
+		if err == nil {
+			params["vmaf"] = strconv.FormatFloat(score, 'f', 2, 64)
+			if t.MinVMAF > 0 && score < t.MinVMAF {
+				err = fmt.Errorf("VMAF score %.2f is below the minimum of %.2f", score, t.MinVMAF)
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	params["elapsed_seconds"] = strconv.FormatFloat(time.Since(start).Seconds(), 'f', 1, 64)
+	if err == nil {
+		slog.Info("Output verified", "file", filepath.Base(job.output), "vmaf", params["vmaf"])
+		t.recordAction(job.source, lib.HistoryActionVerified, params)
+		return
+	}
+
+	params["error"] = err.Error()
+	slog.Error("Output failed verification", "file", job.output, "error", err)
+	t.recordAction(job.source, lib.HistoryActionVerified, params)
+	if t.failTranscoded(job.source) {
+		err = fmt.Errorf("verification failed: %w", err)
+		t.recordFailure(job.source, err)
+		t.recordAction(job.source, lib.HistoryActionFailed, map[string]string{"error": err.Error(), "reason": "verification", "output_path": job.output})
+	}
+}
+
+// checkDecode decodes every video and audio stream of a file, failing if the decoder reports
+// errors. It always decodes in software: hardware decoders conceal some errors, and report
+// their own setup problems as errors.
+func (t *HandBrakeTranscoder) checkDecode(ctx context.Context, path string) error {
+	output, err := combinedOutput(ctx, lowPriorityCommand(ctx, lib.ToolCommand("ffmpeg"), decodeCheckArgs(path)...))
+	if message := strings.TrimSpace(string(output)); message != "" {
+		return fmt.Errorf("decode errors: %s", firstLines(message, 3))
+	}
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
+// scoreVMAF compares an output against its source and returns the pooled VMAF score
+func (t *HandBrakeTranscoder) scoreVMAF(ctx context.Context, job verifyJob) (float64, error) {
+	args := vmafArgs(job.source, job.output, job.videoInfo)
+	if job.title != nil {
+		args = vmafSegmentArgs(job.source, job.output, job.videoInfo, job.title.Start, job.title.End-job.title.Start)
+	}
+	output, err := combinedOutput(ctx, lowPriorityCommand(ctx, lib.ToolCommand("ffmpeg"), withHWAccel(args, t.hwAccel)...))
+	if err != nil {
+		return 0, fmt.Errorf("VMAF scoring failed: %w: %s", err, firstLines(strings.TrimSpace(string(output)), 3))
+	}
+	return parseVMAFScore(string(output))
+}
+
+// decodeCheckArgs builds ffmpeg arguments that decode a file's video and audio and discard it,
+// logging only errors
+func decodeCheckArgs(path string) []string {
+	return []string{"-v", "error", "-nostdin", "-i", path, "-map", "0:v", "-map", "0:a?", "-f", "null", "-"}
+}
+
+// vmafArgs builds ffmpeg arguments that score output against source. The output is scaled to
+// the source's dimensions, since libvmaf compares frames of equal size.
+func vmafArgs(source, output string, videoInfo *lib.VideoInfo) []string {
+	return vmafSegmentArgs(source, output, videoInfo, 0, 0)
+}
+
+// vmafSegmentArgs is vmafArgs for an output encoded from duration seconds of the source from
+// start; a zero duration compares the whole source
+func vmafSegmentArgs(source, output string, videoInfo *lib.VideoInfo, start, duration float64) []string {
+	distorted := "[0:v]setpts=PTS-STARTPTS[distorted]"
+	if videoInfo != nil && videoInfo.Width > 0 && videoInfo.Height > 0 {
+		distorted = fmt.Sprintf("[0:v]scale=%d:%d:flags=bicubic,setpts=PTS-STARTPTS[distorted]", videoInfo.Width, videoInfo.Height)
+	}
+	filter := distorted + ";[1:v]setpts=PTS-STARTPTS[reference];" +
+		fmt.Sprintf("[distorted][reference]libvmaf=n_threads=%d", vmafThreads)
+	args := []string{"-hide_banner", "-nostats", "-nostdin", "-i", output}
+	if duration > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", start), "-t", fmt.Sprintf("%.3f", duration))
+	}
+	return append(args, "-i", source, "-lavfi", filter, "-f", "null", "-")
+}
+
+// parseVMAFScore extracts the pooled VMAF score from ffmpeg's log output
+func parseVMAFScore(output string) (float64, error) {
+	matches := vmafScorePattern.FindAllStringSubmatch(output, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("no VMAF score in ffmpeg output")
+	}
+	return strconv.ParseFloat(matches[len(matches)-1][1], 64)
+}
+
+// lowPriorityCommand runs a command under nice where it is available, so background checks
+// yield the CPU to the encoder
+func lowPriorityCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if nice, err := exec.LookPath("nice"); err == nil {
+		return exec.CommandContext(ctx, nice, append([]string{"-n", "19", name}, args...)...)
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// firstLines returns up to n lines of text
+func firstLines(text string, n int) string {
+	lines := strings.SplitN(text, "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.Join(lines, "; ")
+}
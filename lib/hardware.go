@@ -0,0 +1,146 @@
+package lib
+
+import (
+	"slices"
+	"strings"
+)
+
+// hardwareAPI is a hardware video acceleration interface and the names the tools give it
+type hardwareAPI struct {
+	name            string   // As shown to users, such as "NVENC"
+	handBrakePrefix string   // Prefix of HandBrakeCLI's encoders using it ("" if it has none)
+	ffmpegSuffix    string   // Suffix of ffmpeg's encoders using it
+	decoders        []string // Names of its hardware decoding in ffmpeg -hwaccels and HandBrakeCLI --enable-hw-decoding
+}
+
+// hardwareAPIs are the hardware acceleration interfaces the hw command reports on. HandBrake
+// calls AMD's encoders VCE.
+var hardwareAPIs = []hardwareAPI{
+	{"VideoToolbox", "vt_", "_videotoolbox", []string{"videotoolbox"}},
+	{"NVENC", "nvenc_", "_nvenc", []string{"cuda", "nvdec"}},
+	{"QSV", "qsv_", "_qsv", []string{"qsv"}},
+	{"VAAPI", "", "_vaapi", []string{"vaapi"}},
+	{"AMF", "vce_", "_amf", []string{"amf"}},
+}
+
+// tenBitPixelFormats are the 10-bit pixel formats ffmpeg's hardware encoders list when they can
+// encode 10-bit video
+var tenBitPixelFormats = []string{"p010le", "p010be", "yuv420p10le", "yuv420p10be", "x2rgb10le", "p210le", "yuv444p10le", "y210le"}
+
+// HardwareSupport is what one tool can do with one hardware API. Support is built into the
+// tool; whether the hardware is present is only known once an encode runs.
+type HardwareSupport struct {
+	Tool     string   `json:"tool"`
+	API      string   `json:"api"`
+	Encoders []string `json:"encoders"` // As the tool names them
+	Codecs   []string `json:"codecs"`   // Codecs the encoders produce, such as hevc
+	TenBit   bool     `json:"ten_bit"`  // Some encoder can encode 10-bit video
+	Decoding bool     `json:"decoding"` // The tool can decode with the API
+}
+
+// HardwareReport lists the hardware APIs each detected HandBrakeCLI and ffmpeg supports,
+// leaving out APIs a tool has no encoders or decoding for
+func HardwareReport(caps *Capabilities) []HardwareSupport {
+	var report []HardwareSupport
+	for _, name := range []string{"HandBrakeCLI", "ffmpeg"} {
+		tool := caps.Tool(name)
+		if tool == nil {
+			continue
+		}
+		for _, api := range hardwareAPIs {
+			support := HardwareSupport{Tool: name, API: api.name, Encoders: []string{}, Codecs: []string{}}
+			for _, encoder := range tool.Encoders {
+				codec, tenBit, ok := api.encoderCodec(name, encoder)
+				if !ok {
+					continue
+				}
+				support.Encoders = append(support.Encoders, encoder)
+				support.Codecs = append(support.Codecs, codec)
+				support.TenBit = support.TenBit || tenBit || slices.Contains(tool.TenBitEncoders, encoder)
+			}
+			slices.Sort(support.Codecs)
+			support.Codecs = slices.Compact(support.Codecs)
+			for _, decoder := range api.decoders {
+				support.Decoding = support.Decoding || slices.Contains(tool.HWDecoders, decoder)
+			}
+			if len(support.Encoders) > 0 || support.Decoding {
+				report = append(report, support)
+			}
+		}
+	}
+	return report
+}
+
+// encoderCodec reports whether a tool's encoder uses the API, and if so which codec it produces
+// and whether its name marks it as 10-bit, as HandBrake's vt_h265_10bit does
+func (api hardwareAPI) encoderCodec(tool, encoder string) (codec string, tenBit, ok bool) {
+	if tool == "HandBrakeCLI" {
+		if api.handBrakePrefix == "" || !strings.HasPrefix(encoder, api.handBrakePrefix) {
+			return "", false, false
+		}
+		codec, tenBit = strings.CutSuffix(strings.TrimPrefix(encoder, api.handBrakePrefix), "_10bit")
+	} else {
+		if codec, ok = strings.CutSuffix(encoder, api.ffmpegSuffix); !ok {
+			return "", false, false
+		}
+	}
+	if codec == "h265" {
+		codec = "hevc"
+	}
+	return codec, tenBit, true
+}
+
+// parseHWAccels extracts the methods from ffmpeg -hwaccels output, which lists one per line
+// after a heading
+func parseHWAccels(output string) []string {
+	var methods []string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Contains(line, " ") || strings.HasSuffix(line, ":") {
+			continue
+		}
+		methods = append(methods, line)
+	}
+	return methods
+}
+
+// parseHandBrakeDecoders finds the hardware decoders HandBrakeCLI's help offers for
+// --enable-hw-decoding, returning nil if it has no such option
+func parseHandBrakeDecoders(help string) []string {
+	_, rest, ok := strings.Cut(help, "--enable-hw-decoding")
+	if !ok {
+		return nil
+	}
+	// The option's description runs until the next line that starts another option
+	lines := strings.Split(rest, "\n")
+	option := lines[0]
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(strings.TrimSpace(line), "-") {
+			break
+		}
+		option += " " + line
+	}
+	var decoders []string
+	for _, name := range []string{"nvdec", "videotoolbox", "qsv", "mf"} {
+		if strings.Contains(option, "'"+name+"'") || strings.Contains(option, " "+name+" ") {
+			decoders = append(decoders, name)
+		}
+	}
+	return decoders
+}
+
+// isTenBitEncoder reports whether ffmpeg -h encoder=NAME output lists a 10-bit pixel format
+func isTenBitEncoder(help string) bool {
+	for _, line := range strings.Split(help, "\n") {
+		_, formats, ok := strings.Cut(line, "Supported pixel formats:")
+		if !ok {
+			continue
+		}
+		for _, format := range strings.Fields(formats) {
+			if slices.Contains(tenBitPixelFormats, format) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHardwareReport(t *testing.T) {
+	caps := &Capabilities{Tools: map[string]*ToolCapabilities{
+		"HandBrakeCLI": {
+			Name:       "HandBrakeCLI",
+			Encoders:   []string{"x265", "vt_h264", "vt_h265", "vt_h265_10bit", "nvenc_h265", "nvenc_av1"},
+			HWDecoders: []string{"videotoolbox"},
+		},
+		"ffmpeg": {
+			Name:           "ffmpeg",
+			Encoders:       []string{"libx265", "hevc_vaapi", "h264_vaapi", "hevc_nvenc", "av1_qsv"},
+			TenBitEncoders: []string{"hevc_nvenc"},
+			HWDecoders:     []string{"cuda", "vaapi", "drm"},
+		},
+	}}
+	got := HardwareReport(caps)
+	want := []HardwareSupport{
+		{Tool: "HandBrakeCLI", API: "VideoToolbox", Encoders: []string{"vt_h264", "vt_h265", "vt_h265_10bit"}, Codecs: []string{"h264", "hevc"}, TenBit: true, Decoding: true},
+		{Tool: "HandBrakeCLI", API: "NVENC", Encoders: []string{"nvenc_h265", "nvenc_av1"}, Codecs: []string{"av1", "hevc"}},
+		{Tool: "ffmpeg", API: "NVENC", Encoders: []string{"hevc_nvenc"}, Codecs: []string{"hevc"}, TenBit: true, Decoding: true},
+		{Tool: "ffmpeg", API: "QSV", Encoders: []string{"av1_qsv"}, Codecs: []string{"av1"}},
+		{Tool: "ffmpeg", API: "VAAPI", Encoders: []string{"hevc_vaapi", "h264_vaapi"}, Codecs: []string{"h264", "hevc"}, Decoding: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HardwareReport() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	if got := HardwareReport(&Capabilities{Tools: map[string]*ToolCapabilities{}}); len(got) != 0 {
+		t.Errorf("HardwareReport() without tools = %+v, want nothing", got)
+	}
+}
+
+func TestParseHWAccels(t *testing.T) {
+	output := "Hardware acceleration methods:\nvdpau\ncuda\nvaapi\n\n"
+	if got, want := parseHWAccels(output), []string{"vdpau", "cuda", "vaapi"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseHWAccels() = %v, want %v", got, want)
+	}
+}
+
+func TestParseHandBrakeDecoders(t *testing.T) {
+	help := `   -e, --encoder <string>  Set video library encoder
+       --enable-hw-decoding <string>
+                           Use 'nvdec' to enable NVDec or 'qsv' to
+                           enable QSV hardware decoding
+       --disable-hw-decoding
+                           Use 'videotoolbox' never mentioned here
+`
+	if got, want := parseHandBrakeDecoders(help), []string{"nvdec", "qsv"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseHandBrakeDecoders() = %v, want %v", got, want)
+	}
+	if got := parseHandBrakeDecoders("   -e, --encoder <string>\n"); got != nil {
+		t.Errorf("parseHandBrakeDecoders() without the option = %v, want nil", got)
+	}
+}
+
+func TestIsTenBitEncoder(t *testing.T) {
+	nvenc := "Encoder hevc_nvenc [NVIDIA NVENC hevc encoder]:\n    Supported pixel formats: yuv420p nv12 p010le yuv444p cuda\n"
+	if !isTenBitEncoder(nvenc) {
+		t.Error("isTenBitEncoder() = false for an encoder accepting p010le, want true")
+	}
+	h264 := "Encoder h264_vaapi [H.264/AVC (VAAPI)]:\n    Supported pixel formats: vaapi\n"
+	if isTenBitEncoder(h264) {
+		t.Error("isTenBitEncoder() = true for an 8-bit encoder, want false")
+	}
+}
@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// HDR formats recorded in HDRInfo.Format, from most to least specific
+const (
+	HDRFormatDolbyVision = "dolby_vision"
+	HDRFormatHDR10Plus   = "hdr10plus"
+	HDRFormatHDR10       = "hdr10"
+	HDRFormatHLG         = "hlg"
+)
+
+// Dolby Vision base layer compatibility IDs, which determine what players without Dolby Vision see
+const (
+	DVCompatibilityNone  = 0 // Profile 5: IPTPQc2 base layer, wrong colors without Dolby Vision
+	DVCompatibilityHDR10 = 1
+	DVCompatibilitySDR   = 2
+	DVCompatibilityHLG   = 4
+)
+
+// HDRInfo describes the HDR format and static metadata of a video stream
+type HDRInfo struct {
+	Format            string  `json:"format"` // dolby_vision, hdr10plus, hdr10, or hlg
+	DVProfile         int     `json:"dv_profile,omitempty"`
+	DVLevel           int     `json:"dv_level,omitempty"`
+	DVCompatibilityID int     `json:"dv_compatibility_id,omitempty"` // Base layer compatibility, e.g. 1 for HDR10
+	MaxCLL            int     `json:"max_cll,omitempty"`             // Maximum content light level in cd/m²
+	MaxFALL           int     `json:"max_fall,omitempty"`            // Maximum frame-average light level in cd/m²
+	MasterDisplay     string  `json:"master_display,omitempty"`      // Mastering display in x265 form: G(x,y)B(x,y)R(x,y)WP(x,y)L(max,min)
+	MaxLuminance      float64 `json:"max_luminance,omitempty"`       // Mastering display peak luminance in cd/m²
+	MinLuminance      float64 `json:"min_luminance,omitempty"`       // Mastering display black level in cd/m²
+}
+
+// String describes the format for logs and reports, e.g. "Dolby Vision 8.1" or "HDR10".
+// A nil HDRInfo describes an SDR stream.
+func (h *HDRInfo) String() string {
+	if h == nil {
+		return "SDR"
+	}
+	switch h.Format {
+	case HDRFormatDolbyVision:
+		desc := "Dolby Vision"
+		if h.DVProfile > 0 {
+			desc += fmt.Sprintf(" %d", h.DVProfile)
+			if h.DVCompatibilityID > 0 {
+				desc += fmt.Sprintf(".%d", h.DVCompatibilityID)
+			}
+		}
+		return desc
+	case HDRFormatHDR10Plus:
+		return "HDR10+"
+	case HDRFormatHDR10:
+		return "HDR10"
+	case HDRFormatHLG:
+		return "HLG"
+	}
+	return h.Format
+}
+
+// HasDynamicMetadata reports whether the format carries per-scene metadata that an encoder must pass through
+func (h *HDRInfo) HasDynamicMetadata() bool {
+	return h != nil && (h.Format == HDRFormatDolbyVision || h.Format == HDRFormatHDR10Plus)
+}
+
+// ParseHDRInfo classifies a video stream's HDR format from its transfer function and side data.
+// Returns nil for SDR streams.
+func ParseHDRInfo(stream Stream) *HDRInfo {
+	info := &HDRInfo{}
+	var dolbyVision, hdr10Plus bool
+
+	for _, sideData := range stream.SideDataList {
+		switch {
+		case sideData.SideDataType == "DOVI configuration record":
+			dolbyVision = true
+			info.DVProfile = sideData.DVProfile
+			info.DVLevel = sideData.DVLevel
+			info.DVCompatibilityID = sideData.DVCompatibilityID
+		case strings.Contains(sideData.SideDataType, "HDR10+"), strings.Contains(sideData.SideDataType, "SMPTE2094-40"):
+			hdr10Plus = true
+		case sideData.SideDataType == "Mastering display metadata":
+			info.MasterDisplay = masterDisplayString(sideData)
+			info.MaxLuminance = roundLuminance(parseFrameRate(sideData.MaxLuminance))
+			info.MinLuminance = roundLuminance(parseFrameRate(sideData.MinLuminance))
+		case sideData.SideDataType == "Content light level metadata":
+			info.MaxCLL = sideData.MaxContent
+			info.MaxFALL = sideData.MaxAverage
+		}
+	}
+
+	switch {
+	case dolbyVision:
+		info.Format = HDRFormatDolbyVision
+	case hdr10Plus:
+		info.Format = HDRFormatHDR10Plus
+	case stream.ColorTransfer == "smpte2084":
+		info.Format = HDRFormatHDR10
+	case stream.ColorTransfer == "arib-std-b67":
+		info.Format = HDRFormatHLG
+	default:
+		return nil
+	}
+	return info
+}
+
+// masterDisplayString converts mastering display side data to x265's master-display syntax, which
+// uses units of 0.00002 for chromaticity and 0.0001 cd/m² for luminance. Returns "" if incomplete.
+func masterDisplayString(sd SideData) string {
+	chroma := func(rational string) (int64, bool) {
+		value := parseFrameRate(rational)
+		return int64(math.Round(value * 50000)), rational != "" && value >= 0
+	}
+	values := make([]int64, 0, 8)
+	for _, rational := range []string{sd.GreenX, sd.GreenY, sd.BlueX, sd.BlueY, sd.RedX, sd.RedY, sd.WhitePointX, sd.WhitePointY} {
+		value, ok := chroma(rational)
+		if !ok {
+			return ""
+		}
+		values = append(values, value)
+	}
+	maxLuminance := int64(math.Round(parseFrameRate(sd.MaxLuminance) * 10000))
+	minLuminance := int64(math.Round(parseFrameRate(sd.MinLuminance) * 10000))
+	if maxLuminance == 0 {
+		return ""
+	}
+	return fmt.Sprintf("G(%d,%d)B(%d,%d)R(%d,%d)WP(%d,%d)L(%d,%d)",
+		values[0], values[1], values[2], values[3], values[4], values[5], values[6], values[7], maxLuminance, minLuminance)
+}
+
+// roundLuminance rounds a luminance to 0.0001 cd/m², the precision of mastering display metadata
+func roundLuminance(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
@@ -0,0 +1,94 @@
+package lib
+
+import "testing"
+
+func TestParseHDRInfo(t *testing.T) {
+	masteringDisplay := SideData{
+		SideDataType: "Mastering display metadata",
+		RedX:         "35400/50000", RedY: "14600/50000",
+		GreenX: "8500/50000", GreenY: "39850/50000",
+		BlueX: "6550/50000", BlueY: "2300/50000",
+		WhitePointX: "15635/50000", WhitePointY: "16450/50000",
+		MaxLuminance: "10000000/10000", MinLuminance: "50/10000",
+	}
+	contentLight := SideData{SideDataType: "Content light level metadata", MaxContent: 1000, MaxAverage: 400}
+
+	tests := []struct {
+		name     string
+		stream   Stream
+		expected *HDRInfo
+	}{
+		{
+			name:     "SDR",
+			stream:   Stream{ColorTransfer: "bt709"},
+			expected: nil,
+		},
+		{
+			name:   "HDR10 with static metadata",
+			stream: Stream{ColorTransfer: "smpte2084", SideDataList: []SideData{masteringDisplay, contentLight}},
+			expected: &HDRInfo{
+				Format:        HDRFormatHDR10,
+				MaxCLL:        1000,
+				MaxFALL:       400,
+				MasterDisplay: "G(8500,39850)B(6550,2300)R(35400,14600)WP(15635,16450)L(10000000,50)",
+				MaxLuminance:  1000,
+				MinLuminance:  0.005,
+			},
+		},
+		{
+			name:     "HDR10+",
+			stream:   Stream{ColorTransfer: "smpte2084", SideDataList: []SideData{{SideDataType: "HDR Dynamic Metadata SMPTE2094-40 (HDR10+)"}}},
+			expected: &HDRInfo{Format: HDRFormatHDR10Plus},
+		},
+		{
+			name:     "HLG",
+			stream:   Stream{ColorTransfer: "arib-std-b67"},
+			expected: &HDRInfo{Format: HDRFormatHLG},
+		},
+		{
+			name: "Dolby Vision profile 8.1",
+			stream: Stream{ColorTransfer: "smpte2084", SideDataList: []SideData{
+				{SideDataType: "DOVI configuration record", DVProfile: 8, DVLevel: 6, DVCompatibilityID: DVCompatibilityHDR10},
+				contentLight,
+			}},
+			expected: &HDRInfo{Format: HDRFormatDolbyVision, DVProfile: 8, DVLevel: 6, DVCompatibilityID: DVCompatibilityHDR10, MaxCLL: 1000, MaxFALL: 400},
+		},
+		{
+			name:     "Dolby Vision profile 5",
+			stream:   Stream{ColorTransfer: "unknown", SideDataList: []SideData{{SideDataType: "DOVI configuration record", DVProfile: 5, DVLevel: 6}}},
+			expected: &HDRInfo{Format: HDRFormatDolbyVision, DVProfile: 5, DVLevel: 6},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseHDRInfo(tt.stream)
+			if (got == nil) != (tt.expected == nil) || got != nil && *got != *tt.expected {
+				t.Errorf("ParseHDRInfo() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHDRInfoString(t *testing.T) {
+	tests := []struct {
+		info     *HDRInfo
+		expected string
+	}{
+		{nil, "SDR"},
+		{&HDRInfo{Format: HDRFormatHDR10}, "HDR10"},
+		{&HDRInfo{Format: HDRFormatHDR10Plus}, "HDR10+"},
+		{&HDRInfo{Format: HDRFormatHLG}, "HLG"},
+		{&HDRInfo{Format: HDRFormatDolbyVision}, "Dolby Vision"},
+		{&HDRInfo{Format: HDRFormatDolbyVision, DVProfile: 8, DVCompatibilityID: DVCompatibilityHDR10}, "Dolby Vision 8.1"},
+		{&HDRInfo{Format: HDRFormatDolbyVision, DVProfile: 5}, "Dolby Vision 5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := tt.info.String(); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
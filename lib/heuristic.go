@@ -1,11 +1,114 @@
 package lib
 
 import (
+	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 )
 
+// HeuristicWeights are the scores used to pick a file's primary video stream from among cover
+// art, thumbnails, and other video streams. Set them under "heuristics" in the config file for
+// libraries the defaults misjudge, such as screen recordings or MJPEG camera footage; fields left
+// out keep their defaults, and codec_scores entries are merged over the default codec scores.
+type HeuristicWeights struct {
+	CodecScores            map[string]float64 `yaml:"codec_scores"`             // Score by lowercase codec name
+	UnknownCodecScore      float64            `yaml:"unknown_codec_score"`      // Score for codecs not in CodecScores
+	ResolutionWeight       float64            `yaml:"resolution_weight"`        // Points per power of ten of pixels
+	SmallResolutionPixels  int                `yaml:"small_resolution_pixels"`  // Pixel count below which a stream is likely a thumbnail
+	SmallResolutionPenalty float64            `yaml:"small_resolution_penalty"` // Subtracted below SmallResolutionPixels
+	BitrateWeight          float64            `yaml:"bitrate_weight"`           // Points per power of ten of kbps
+	LowBitrate             int64              `yaml:"low_bitrate"`              // Bitrate in bits per second below which a stream is likely a thumbnail
+	LowBitratePenalty      float64            `yaml:"low_bitrate_penalty"`      // Subtracted below LowBitrate
+	FullDurationBonus      float64            `yaml:"full_duration_bonus"`      // Added when a stream lasts as long as the file
+	ShortDurationRatio     float64            `yaml:"short_duration_ratio"`     // Fraction of the file's duration below which a stream is short
+	ShortDurationPenalty   float64            `yaml:"short_duration_penalty"`   // Subtracted for short streams
+	AttachedPicPenalty     float64            `yaml:"attached_pic_penalty"`     // Subtracted for streams flagged as attached pictures
+}
+
+// DefaultHeuristicWeights returns the built-in stream classification weights
+func DefaultHeuristicWeights() HeuristicWeights {
+	return HeuristicWeights{
+		CodecScores: map[string]float64{
+			"hevc": 100, "h265": 100,
+			"h264": 95, "avc": 95,
+			"av1":        90,
+			"vp9":        85,
+			"vp8":        80,
+			"mpeg4":      75,
+			"mpeg2video": 70,
+			"mjpeg":      10, // Very low priority - often thumbnails
+			"png":        5,  // Likely static images
+			"bmp":        5,
+		},
+		UnknownCodecScore:      50,
+		ResolutionWeight:       10,
+		SmallResolutionPixels:  40000, // 200x200
+		SmallResolutionPenalty: 50,
+		BitrateWeight:          15,
+		LowBitrate:             100000, // 100 kbps
+		LowBitratePenalty:      30,
+		FullDurationBonus:      20,
+		ShortDurationRatio:     0.1,
+		ShortDurationPenalty:   30,
+		AttachedPicPenalty:     200,
+	}
+}
+
+// Validate checks that thresholds and ratios are in range
+func (w HeuristicWeights) Validate() error {
+	switch {
+	case w.SmallResolutionPixels < 0:
+		return fmt.Errorf("small_resolution_pixels must be 0 or more")
+	case w.LowBitrate < 0:
+		return fmt.Errorf("low_bitrate must be 0 or more")
+	case w.ShortDurationRatio < 0 || w.ShortDurationRatio >= 1:
+		return fmt.Errorf("short_duration_ratio must be at least 0 and less than 1")
+	}
+	return nil
+}
+
+// mergeCodecScores lowercases configured codec scores, which are matched against codec names in
+// lowercase, and merges them over the defaults. A codec listed more than once in different
+// cases is an error, since either score could otherwise win.
+func mergeCodecScores(defaults, configured map[string]float64) (map[string]float64, error) {
+	merged := make(map[string]float64, len(defaults)+len(configured))
+	for codec, score := range defaults {
+		merged[codec] = score
+	}
+	seen := make(map[string]string, len(configured))
+	for codec, score := range configured {
+		lower := strings.ToLower(codec)
+		if other, ok := seen[lower]; ok {
+			return nil, fmt.Errorf("codec_scores lists %q and %q, which name the same codec", other, codec)
+		}
+		seen[lower] = codec
+		merged[lower] = score
+	}
+	return merged, nil
+}
+
+// heuristicWeights holds the *HeuristicWeights in use
+var heuristicWeights atomic.Pointer[HeuristicWeights]
+
+func init() {
+	weights := DefaultHeuristicWeights()
+	heuristicWeights.Store(&weights)
+}
+
+// SetHeuristicWeights selects the weights used to classify video streams
+func SetHeuristicWeights(weights HeuristicWeights) {
+	codecScores := make(map[string]float64, len(weights.CodecScores))
+	for codec, score := range weights.CodecScores {
+		codecScores[strings.ToLower(codec)] = score
+	}
+	weights.CodecScores = codecScores
+	heuristicWeights.Store(&weights)
+}
+
 // VideoStreamScore represents a video stream with its calculated priority score
 type VideoStreamScore struct {
 	Stream Stream
@@ -65,6 +168,102 @@ func ClassifyVideoStreams(streams []Stream, formatDuration float64) *VideoStream
 	}
 }
 
+// Video stream kinds recorded in MediaInfo.VideoStreams
+const (
+	VideoStreamPrimary   = "primary"
+	VideoStreamCoverArt  = "cover_art"
+	VideoStreamThumbnail = "thumbnail"
+	VideoStreamVideo     = "video" // Additional real video, such as an alternate angle or trailer
+)
+
+// AuxiliaryStreamKind labels a non-primary video stream as cover_art, thumbnail, or video
+func AuxiliaryStreamKind(stream Stream) string {
+	switch {
+	case stream.Disposition["attached_pic"] == 1:
+		return VideoStreamCoverArt
+	case getCodecScore(stream.CodecName) <= 10, stream.Width*stream.Height > 0 && stream.Width*stream.Height < heuristicWeights.Load().SmallResolutionPixels:
+		return VideoStreamThumbnail
+	default:
+		return VideoStreamVideo
+	}
+}
+
+// Audio track roles recorded in AudioTrack.Role
+const (
+	AudioRoleMain        = "main"
+	AudioRoleCommentary  = "commentary"
+	AudioRoleDescriptive = "descriptive" // Audio description narrating the picture for blind and low-vision viewers
+)
+
+var (
+	// commentaryTitlePattern matches track titles such as "Director's Commentary"
+	commentaryTitlePattern = regexp.MustCompile(`(?i)comment`)
+	// descriptiveTitlePattern matches track titles such as "English AD" or "Audio Description"
+	descriptiveTitlePattern = regexp.MustCompile(`(?i)\b(AD|DVS|audio[ -]?descri\w*|descriptive|described)\b`)
+)
+
+// ClassifyAudioTracks sets the Role of each track from its disposition flags and title. Tracks
+// with neither signal are main audio: a low-bitrate stereo track alongside a multichannel mix
+// is as often a downmix of it as commentary, and --drop-commentary must not remove a downmix.
+func ClassifyAudioTracks(tracks []AudioTrack, streams []Stream) {
+	dispositions := make(map[int]map[string]int, len(streams))
+	for _, stream := range streams {
+		dispositions[stream.Index] = stream.Disposition
+	}
+
+	for i := range tracks {
+		track := &tracks[i]
+		disposition := dispositions[track.Index]
+		switch {
+		case disposition["comment"] == 1, commentaryTitlePattern.MatchString(track.Title):
+			track.Role = AudioRoleCommentary
+		case disposition["visual_impaired"] == 1, disposition["descriptions"] == 1, descriptiveTitlePattern.MatchString(track.Title):
+			track.Role = AudioRoleDescriptive
+		default:
+			track.Role = AudioRoleMain
+		}
+	}
+}
+
+// Subtitle track kinds recorded in SubtitleTrack.Kind
+const (
+	SubtitleKindFull   = "full"
+	SubtitleKindForced = "forced" // Only foreign-language dialogue and signs, shown even with subtitles off
+	SubtitleKindSDH    = "sdh"    // Subtitles for the deaf and hard of hearing, with sound cues and speaker names
+)
+
+var (
+	// forcedTitlePattern matches subtitle titles such as "Forced" or "Signs & Songs"
+	forcedTitlePattern = regexp.MustCompile(`(?i)\b(forced|foreign|signs)\b`)
+	// sdhTitlePattern matches subtitle titles such as "English SDH" or "CC"
+	sdhTitlePattern = regexp.MustCompile(`(?i)\b(SDH|CC|HoH|hearing[ -]impaired|deaf)\b`)
+)
+
+// ClassifySubtitleTrack returns the kind of a subtitle stream from its disposition flags, then its
+// title. A track flagged as both forced and SDH is forced, since it cannot stand in for full subtitles.
+func ClassifySubtitleTrack(stream Stream) string {
+	title := stream.Tags["title"]
+	switch {
+	case stream.Disposition["forced"] == 1, forcedTitlePattern.MatchString(title):
+		return SubtitleKindForced
+	case stream.Disposition["hearing_impaired"] == 1, sdhTitlePattern.MatchString(title):
+		return SubtitleKindSDH
+	}
+	return SubtitleKindFull
+}
+
+// dispositionFlags lists the disposition flags ffprobe reports as set, sorted by name
+func dispositionFlags(disposition map[string]int) []string {
+	var flags []string
+	for flag, set := range disposition {
+		if set == 1 {
+			flags = append(flags, flag)
+		}
+	}
+	sort.Strings(flags)
+	return flags
+}
+
 // extractVideoStreams filters streams to only video codec types
 func extractVideoStreams(streams []Stream) []Stream {
 	var videoStreams []Stream
@@ -76,65 +275,98 @@ func extractVideoStreams(streams []Stream) []Stream {
 	return videoStreams
 }
 
+// StreamScore is a video stream's classification score broken down by what contributed to it
+type StreamScore struct {
+	Index            int     `json:"index"`
+	Codec            string  `json:"codec"`
+	Width            int     `json:"width"`
+	Height           int     `json:"height"`
+	Kind             string  `json:"kind"` // Decision: primary, cover_art, thumbnail, or video
+	CodecScore       float64 `json:"codec_score"`
+	IndexScore       float64 `json:"index_score"`
+	PixelFormatScore float64 `json:"pixel_format_score"`
+	DurationScore    float64 `json:"duration_score"`
+	AttachedPicScore float64 `json:"attached_pic_score"`
+	ResolutionScore  float64 `json:"resolution_score"` // Including the small resolution penalty
+	BitrateScore     float64 `json:"bitrate_score"`    // Including the low bitrate penalty
+	Total            float64 `json:"total"`
+}
+
+// ExplainVideoStreams scores every video stream as ClassifyVideoStreams does and records the
+// resulting decision, for understanding why a stream was or was not chosen as primary
+func ExplainVideoStreams(streams []Stream, formatDuration float64) []StreamScore {
+	classification := ClassifyVideoStreams(streams, formatDuration)
+	var scores []StreamScore
+	for _, stream := range extractVideoStreams(streams) {
+		score := scoreStream(stream, formatDuration)
+		if classification.Primary != nil && stream.Index == classification.Primary.Index {
+			score.Kind = VideoStreamPrimary
+		} else {
+			score.Kind = AuxiliaryStreamKind(stream)
+		}
+		scores = append(scores, score)
+	}
+	return scores
+}
+
 // calculateStreamScore computes a priority score for a video stream
 // Higher scores indicate more likely to be the primary video content
 func calculateStreamScore(stream Stream, formatDuration float64) float64 {
-	score := 0.0
+	return scoreStream(stream, formatDuration).Total
+}
 
-	score += getCodecScore(stream.CodecName)
-	score += getIndexScore(stream.Index)
-	score += getPixelFormatScore(stream.PixelFormat)
-	score += getDurationScore(stream, formatDuration)
+// scoreStream computes each contribution to a stream's priority score
+func scoreStream(stream Stream, formatDuration float64) StreamScore {
+	weights := heuristicWeights.Load()
+	score := StreamScore{
+		Index:            stream.Index,
+		Codec:            stream.CodecName,
+		Width:            stream.Width,
+		Height:           stream.Height,
+		CodecScore:       getCodecScore(stream.CodecName),
+		IndexScore:       getIndexScore(stream.Index),
+		PixelFormatScore: getPixelFormatScore(stream.PixelFormat),
+		DurationScore:    getDurationScore(stream, formatDuration),
+	}
+
+	// Attached pictures are cover art embedded by muxers, never the main content
+	if stream.Disposition["attached_pic"] == 1 {
+		score.AttachedPicScore = -weights.AttachedPicPenalty
+	}
 
 	pixelCount := stream.Width * stream.Height
 	if pixelCount > 0 {
 		// Logarithmic scoring to avoid extreme values
-		score += math.Log10(float64(pixelCount)) * 10
+		score.ResolutionScore = math.Log10(float64(pixelCount)) * weights.ResolutionWeight
 
 		// Penalty for very small resolutions (likely thumbnails)
-		if pixelCount < 40000 { // 200x200
-			score -= 50
+		if pixelCount < weights.SmallResolutionPixels {
+			score.ResolutionScore -= weights.SmallResolutionPenalty
 		}
 	}
 
 	if bitrate := parseBitrate(stream); bitrate > 0 {
 		// Logarithmic scoring for bitrate (in kbps)
-		score += math.Log10(float64(bitrate)/1000) * 15
+		score.BitrateScore = math.Log10(float64(bitrate)/1000) * weights.BitrateWeight
 
 		// Penalty for very low bitrates (likely thumbnails)
-		if bitrate < 100000 { // 100 kbps
-			score -= 30
+		if bitrate < weights.LowBitrate {
+			score.BitrateScore -= weights.LowBitratePenalty
 		}
 	}
 
+	score.Total = score.CodecScore + score.IndexScore + score.PixelFormatScore + score.DurationScore +
+		score.AttachedPicScore + score.ResolutionScore + score.BitrateScore
 	return score
 }
 
 // getCodecScore assigns priority scores based on codec type
 func getCodecScore(codecName string) float64 {
-	codec := strings.ToLower(codecName)
-	switch codec {
-	case "hevc", "h265":
-		return 100
-	case "h264", "avc":
-		return 95
-	case "av1":
-		return 90
-	case "vp9":
-		return 85
-	case "vp8":
-		return 80
-	case "mpeg4":
-		return 75
-	case "mpeg2video":
-		return 70
-	case "mjpeg":
-		return 10 // Very low priority - often thumbnails
-	case "png", "bmp":
-		return 5 // Likely static images
-	default:
-		return 50 // Neutral score for unknown codecs
+	weights := heuristicWeights.Load()
+	if score, ok := weights.CodecScores[strings.ToLower(codecName)]; ok {
+		return score
 	}
+	return weights.UnknownCodecScore
 }
 
 // getIndexScore provides slight preference for lower stream indices
@@ -177,13 +409,14 @@ func getDurationScore(stream Stream, formatDuration float64) float64 {
 		if durationStr, exists := stream.Tags["DURATION"]; exists {
 			if duration := parseDurationTag(durationStr); duration > 0 {
 				// Streams matching format duration get bonus
+				weights := heuristicWeights.Load()
 				durationRatio := duration / formatDuration
 				if durationRatio > 0.95 && durationRatio < 1.05 {
-					return 20
+					return weights.FullDurationBonus
 				}
 				// Very short streams compared to format are likely thumbnails
-				if durationRatio < 0.1 {
-					return -30
+				if durationRatio < weights.ShortDurationRatio {
+					return -weights.ShortDurationPenalty
 				}
 			}
 		}
@@ -77,6 +77,37 @@ var _ = Describe("Video Stream Classification", func() {
 			})
 		})
 
+		Context("with an attached picture encoded like video", func() {
+			It("never picks the cover art as primary", func() {
+				streams := []Stream{
+					{
+						Index:       0,
+						CodecType:   "video",
+						CodecName:   "h264",
+						Width:       3000,
+						Height:      3000,
+						PixelFormat: "yuv420p",
+						Disposition: map[string]int{"attached_pic": 1},
+					},
+					{
+						Index:       1,
+						CodecType:   "video",
+						CodecName:   "h264",
+						Width:       1280,
+						Height:      720,
+						Bitrate:     "3000000",
+						PixelFormat: "yuv420p",
+					},
+				}
+
+				result := ClassifyVideoStreams(streams, 3600.0)
+
+				Expect(result.Primary).NotTo(BeNil())
+				Expect(result.Primary.Index).To(Equal(1))
+				Expect(AuxiliaryStreamKind(result.Auxiliary[0])).To(Equal("cover_art"))
+			})
+		})
+
 		Context("with same codec different resolutions", func() {
 			It("prioritizes higher resolution", func() {
 				streams := []Stream{
@@ -301,6 +332,45 @@ var _ = Describe("Video Stream Classification", func() {
 	})
 
 
+	Describe("AuxiliaryStreamKind", func() {
+		It("labels images and tiny streams as thumbnails", func() {
+			Expect(AuxiliaryStreamKind(Stream{CodecName: "mjpeg", Width: 600, Height: 900})).To(Equal("thumbnail"))
+			Expect(AuxiliaryStreamKind(Stream{CodecName: "h264", Width: 160, Height: 90})).To(Equal("thumbnail"))
+			Expect(AuxiliaryStreamKind(Stream{CodecName: "h264", Width: 1920, Height: 1080})).To(Equal("video"))
+		})
+	})
+
+	Describe("parseFFprobeOutput", func() {
+		It("records every video stream with its kind, frame rate, and disposition", func() {
+			probe := &FFProbeOutput{
+				Format: Format{Duration: "7200"},
+				Streams: []Stream{
+					{Index: 0, CodecType: "video", CodecName: "hevc", Width: 3840, Height: 2160, AvgFrameRate: "24000/1001",
+						PixelFormat: "yuv420p10le", Disposition: map[string]int{"default": 1}},
+					{Index: 1, CodecType: "audio", CodecName: "eac3"},
+					{Index: 2, CodecType: "video", CodecName: "h264", Width: 1920, Height: 1080, AvgFrameRate: "25/1",
+						Tags: map[string]string{"title": "Angle 2"}},
+					{Index: 3, CodecType: "video", CodecName: "mjpeg", Width: 600, Height: 900, AvgFrameRate: "0/0",
+						Disposition: map[string]int{"attached_pic": 1, "default": 0}},
+				},
+			}
+			info := &MediaInfo{}
+
+			Expect(NewMediaAnalyzer().parseFFprobeOutput(probe, info)).To(Succeed())
+
+			Expect(info.VideoStreams).To(HaveLen(3))
+			Expect(info.VideoStreams[0].Kind).To(Equal(VideoStreamPrimary))
+			Expect(info.VideoStreams[0].FrameRate).To(BeNumerically("~", 23.976, 0.001))
+			Expect(info.VideoStreams[0].Disposition).To(Equal([]string{"default"}))
+			Expect(info.VideoStreams[1].Kind).To(Equal(VideoStreamVideo))
+			Expect(info.VideoStreams[1].Title).To(Equal("Angle 2"))
+			Expect(info.VideoStreams[2].Kind).To(Equal(VideoStreamCoverArt))
+			Expect(info.VideoStreams[2].FrameRate).To(BeZero())
+			Expect(info.ExtraVideoStreams()).To(HaveLen(1))
+			Expect(info.ExtraVideoStreams()[0].Index).To(Equal(2))
+		})
+	})
+
 	Describe("extractVideoStreams", func() {
 		It("filters only video streams", func() {
 			streams := []Stream{
@@ -318,4 +388,121 @@ var _ = Describe("Video Stream Classification", func() {
 			}
 		})
 	})
-})
\ No newline at end of file
+
+	Describe("ClassifyAudioTracks", func() {
+		It("trusts disposition flags", func() {
+			streams := []Stream{
+				{Index: 1, CodecType: "audio", Disposition: map[string]int{"default": 1}},
+				{Index: 2, CodecType: "audio", Disposition: map[string]int{"comment": 1}},
+				{Index: 3, CodecType: "audio", Disposition: map[string]int{"visual_impaired": 1}},
+			}
+			tracks := []AudioTrack{{Index: 1, Channels: 6}, {Index: 2, Channels: 6}, {Index: 3, Channels: 6}}
+
+			ClassifyAudioTracks(tracks, streams)
+
+			Expect(tracks[0].Role).To(Equal(AudioRoleMain))
+			Expect(tracks[1].Role).To(Equal(AudioRoleCommentary))
+			Expect(tracks[2].Role).To(Equal(AudioRoleDescriptive))
+		})
+
+		It("recognizes titles", func() {
+			tracks := []AudioTrack{
+				{Index: 1, Channels: 2, Title: "Stereo"},
+				{Index: 2, Channels: 2, Title: "Director's Commentary"},
+				{Index: 3, Channels: 2, Title: "English AD"},
+				{Index: 4, Channels: 2, Title: "Audio Description"},
+				{Index: 5, Channels: 2, Title: "Headphone Mix"},
+			}
+
+			ClassifyAudioTracks(tracks, nil)
+
+			roles := make([]string, len(tracks))
+			for i, track := range tracks {
+				roles[i] = track.Role
+			}
+			Expect(roles).To(Equal([]string{AudioRoleMain, AudioRoleCommentary, AudioRoleDescriptive, AudioRoleDescriptive, AudioRoleMain}))
+		})
+
+		It("keeps low-bitrate stereo downmixes alongside multichannel mains as main", func() {
+			tracks := []AudioTrack{
+				{Index: 1, Channels: 6, Bitrate: 640000},
+				{Index: 2, Channels: 2, Bitrate: 224000},
+				{Index: 3, Channels: 2, Bitrate: 96000},
+			}
+
+			ClassifyAudioTracks(tracks, nil)
+
+			Expect(tracks[0].Role).To(Equal(AudioRoleMain))
+			Expect(tracks[1].Role).To(Equal(AudioRoleMain))
+			Expect(tracks[2].Role).To(Equal(AudioRoleMain))
+		})
+
+		It("keeps low-bitrate stereo as main without multichannel audio", func() {
+			tracks := []AudioTrack{{Index: 1, Channels: 2, Bitrate: 128000}, {Index: 2, Channels: 2, Bitrate: 96000}}
+
+			ClassifyAudioTracks(tracks, nil)
+
+			Expect(tracks[0].Role).To(Equal(AudioRoleMain))
+			Expect(tracks[1].Role).To(Equal(AudioRoleMain))
+		})
+	})
+
+	Describe("ClassifySubtitleTrack", func() {
+		It("trusts disposition flags", func() {
+			Expect(ClassifySubtitleTrack(Stream{Disposition: map[string]int{"default": 1}})).To(Equal(SubtitleKindFull))
+			Expect(ClassifySubtitleTrack(Stream{Disposition: map[string]int{"forced": 1}})).To(Equal(SubtitleKindForced))
+			Expect(ClassifySubtitleTrack(Stream{Disposition: map[string]int{"hearing_impaired": 1}})).To(Equal(SubtitleKindSDH))
+			Expect(ClassifySubtitleTrack(Stream{Disposition: map[string]int{"forced": 1, "hearing_impaired": 1}})).To(Equal(SubtitleKindForced))
+		})
+
+		It("recognizes titles", func() {
+			kinds := make([]string, 0, 5)
+			for _, title := range []string{"English", "English SDH", "Forced", "Signs & Songs", "CC"} {
+				kinds = append(kinds, ClassifySubtitleTrack(Stream{Tags: map[string]string{"title": title}}))
+			}
+			Expect(kinds).To(Equal([]string{SubtitleKindFull, SubtitleKindSDH, SubtitleKindForced, SubtitleKindForced, SubtitleKindSDH}))
+		})
+	})
+	Describe("SetHeuristicWeights", func() {
+		AfterEach(func() {
+			SetHeuristicWeights(DefaultHeuristicWeights())
+		})
+
+		It("lets MJPEG camera footage win over an H.264 preview", func() {
+			streams := []Stream{
+				{Index: 0, CodecType: "video", CodecName: "h264", Width: 640, Height: 360, PixelFormat: "yuv420p"},
+				{Index: 1, CodecType: "video", CodecName: "mjpeg", Width: 1920, Height: 1080, PixelFormat: "yuvj422p"},
+			}
+			Expect(ClassifyVideoStreams(streams, 600).Primary.CodecName).To(Equal("h264"))
+
+			weights := DefaultHeuristicWeights()
+			weights.CodecScores["mjpeg"] = 95
+			SetHeuristicWeights(weights)
+
+			Expect(getCodecScore("mjpeg")).To(Equal(95.0))
+			Expect(ClassifyVideoStreams(streams, 600).Primary.CodecName).To(Equal("mjpeg"))
+		})
+	})
+	Describe("ExplainVideoStreams", func() {
+		It("breaks down each score and records the decision", func() {
+			streams := []Stream{
+				{Index: 0, CodecType: "video", CodecName: "mjpeg", Width: 600, Height: 600, Disposition: map[string]int{"attached_pic": 1}},
+				{Index: 1, CodecType: "video", CodecName: "hevc", Width: 3840, Height: 2160, PixelFormat: "yuv420p10le", Bitrate: "20000000"},
+				{Index: 2, CodecType: "audio", CodecName: "aac"},
+				{Index: 3, CodecType: "video", CodecName: "png", Width: 160, Height: 90},
+			}
+
+			scores := ExplainVideoStreams(streams, 7200)
+
+			Expect(scores).To(HaveLen(3))
+			Expect([]string{scores[0].Kind, scores[1].Kind, scores[2].Kind}).To(Equal([]string{VideoStreamCoverArt, VideoStreamPrimary, VideoStreamThumbnail}))
+			for _, score := range scores {
+				sum := score.CodecScore + score.IndexScore + score.PixelFormatScore + score.DurationScore +
+					score.AttachedPicScore + score.ResolutionScore + score.BitrateScore
+				Expect(score.Total).To(BeNumerically("~", sum, 1e-9))
+			}
+			Expect(scores[0].AttachedPicScore).To(Equal(-200.0))
+			Expect(scores[1].Total).To(Equal(calculateStreamScore(streams[1], 7200)))
+		})
+	})
+})
@@ -0,0 +1,288 @@
+package lib
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// History actions recorded by the tool
+const (
+	HistoryActionAnalyzed   = "analyzed"
+	HistoryActionEstimated  = "estimated"
+	HistoryActionSkipped    = "skipped"
+	HistoryActionTranscoded = "transcoded"
+	HistoryActionVerified   = "verified"
+	HistoryActionReplaced   = "replaced"
+	HistoryActionArchived   = "archived"
+	HistoryActionFailed     = "failed"
+)
+
+// maxSpeedSamples limits how many recent encodes feed into speed predictions,
+// so hardware or settings changes are reflected quickly
+const maxSpeedSamples = 20
+
+// HistoryRecord describes a single action the tool performed on a file
+type HistoryRecord struct {
+	Timestamp      time.Time         `json:"timestamp"`
+	FilePath       string            `json:"file_path"`
+	Action         string            `json:"action"`
+	Encoder        string            `json:"encoder,omitempty"`
+	Quality        int               `json:"quality,omitempty"`
+	Width          int               `json:"width,omitempty"`
+	Height         int               `json:"height,omitempty"`
+	MediaDuration  float64           `json:"media_duration,omitempty"`  // Source duration in seconds
+	ElapsedSeconds float64           `json:"elapsed_seconds,omitempty"` // Wall-clock time spent on the action
+	AvgFPS         float64           `json:"avg_fps,omitempty"`         // Average encode speed in frames per second
+	OriginalSize   int64             `json:"original_size,omitempty"`
+	OutputSize     int64             `json:"output_size,omitempty"`
+	OutputPath     string            `json:"output_path,omitempty"`
+	Params         map[string]string `json:"params,omitempty"` // Action-specific details such as skip reasons
+	Before         *MediaInfo        `json:"before,omitempty"` // Source analysis taken before a transcode
+	After          *MediaInfo        `json:"after,omitempty"`  // Output analysis taken after a transcode
+}
+
+// TranscodeComparison pairs a file's media info before and after its most recent transcode
+type TranscodeComparison struct {
+	FilePath     string     `json:"file_path"`
+	OutputPath   string     `json:"output_path"`
+	TranscodedAt time.Time  `json:"transcoded_at"`
+	Encoder      string     `json:"encoder,omitempty"`
+	Quality      int        `json:"quality,omitempty"`
+	Before       *MediaInfo `json:"before"`
+	After        *MediaInfo `json:"after"`
+}
+
+// HistoryStore persists history records as append-only JSON lines
+type HistoryStore struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func NewHistoryStore(path string) *HistoryStore {
+	return &HistoryStore{Path: path}
+}
+
+// DefaultHistoryPath returns the location of the history database in the state directory
+func DefaultHistoryPath() string {
+	return filepath.Join(DefaultStateDir(), "history.jsonl")
+}
+
+// Append adds a record to the end of the history file, creating it if needed.
+// Relative file paths are made absolute so records can be looked up by path later.
+func (hs *HistoryStore) Append(record HistoryRecord) error {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	if abs, err := filepath.Abs(record.FilePath); err == nil {
+		record.FilePath = abs
+	}
+
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	if err := appendJSONLine(hs.Path, record); err != nil {
+		return fmt.Errorf("failed to write history record: %w", err)
+	}
+	return nil
+}
+
+// Records reads all history records in the order they were written.
+// A missing history file yields no records; malformed lines are skipped.
+func (hs *HistoryStore) Records() ([]HistoryRecord, error) {
+	hs.mutex.Lock()
+	defer hs.mutex.Unlock()
+
+	var records []HistoryRecord
+	err := readJSONLines(hs.Path, func(line []byte) {
+		var record HistoryRecord
+		if json.Unmarshal(line, &record) == nil {
+			records = append(records, record)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+	return records, nil
+}
+
+// RecordsForFile returns the records for a file, or for outputs derived from it, oldest first
+func (hs *HistoryStore) RecordsForFile(path string) ([]HistoryRecord, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	records, err := hs.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []HistoryRecord
+	for _, record := range records {
+		if record.FilePath == path || record.OutputPath == path {
+			matching = append(matching, record)
+		}
+	}
+	return matching, nil
+}
+
+// RetryableFailures returns the files whose most recent transcode attempt failed at or after
+// since, oldest failure first. Files that have since been transcoded or skipped, and failures
+// marked permanent, such as sources the encoder cannot handle, are left out.
+func (hs *HistoryStore) RetryableFailures(since time.Time) ([]string, error) {
+	records, err := hs.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]HistoryRecord)
+	for _, record := range records {
+		switch record.Action {
+		case HistoryActionFailed, HistoryActionTranscoded, HistoryActionReplaced, HistoryActionSkipped:
+			latest[record.FilePath] = record
+		}
+	}
+
+	var failed []HistoryRecord
+	for _, record := range latest {
+		if record.Action == HistoryActionFailed && record.Params["permanent"] != "true" && !record.Timestamp.Before(since) {
+			failed = append(failed, record)
+		}
+	}
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].Timestamp.Before(failed[j].Timestamp)
+	})
+
+	files := make([]string, len(failed))
+	for i, record := range failed {
+		files[i] = record.FilePath
+	}
+	return files, nil
+}
+
+// TranscodeComparisons returns the latest before/after pair for each transcoded file under root,
+// sorted by file path. An empty root includes every file. Records without both analyses are ignored.
+func (hs *HistoryStore) TranscodeComparisons(root string) ([]TranscodeComparison, error) {
+	if root != "" {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+	}
+
+	records, err := hs.Records()
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[string]TranscodeComparison)
+	for _, record := range records {
+		if record.Action != HistoryActionTranscoded || record.Before == nil || record.After == nil {
+			continue
+		}
+		if root != "" && !strings.HasPrefix(record.FilePath, root+string(filepath.Separator)) {
+			continue
+		}
+		latest[record.FilePath] = TranscodeComparison{
+			FilePath:     record.FilePath,
+			OutputPath:   record.OutputPath,
+			TranscodedAt: record.Timestamp,
+			Encoder:      record.Encoder,
+			Quality:      record.Quality,
+			Before:       record.Before,
+			After:        record.After,
+		}
+	}
+
+	comparisons := make([]TranscodeComparison, 0, len(latest))
+	for _, comparison := range latest {
+		comparisons = append(comparisons, comparison)
+	}
+	sort.Slice(comparisons, func(i, j int) bool { return comparisons[i].FilePath < comparisons[j].FilePath })
+	return comparisons, nil
+}
+
+// Record appends a record, logging rather than returning failures.
+// Safe to call on a nil store, in which case nothing is recorded.
+func (hs *HistoryStore) Record(record HistoryRecord) {
+	if hs == nil {
+		return
+	}
+	if err := hs.Append(record); err != nil {
+		slog.Warn("Failed to record history", "file", record.FilePath, "action", record.Action, "error", err)
+	}
+}
+
+// EncoderFPS returns the average encode speed recorded for an encoder at a resolution class.
+// Only the most recent transcodes are considered. Returns 0 if there is no matching history.
+func (hs *HistoryStore) EncoderFPS(encoder, resolutionClass string) (float64, error) {
+	records, err := hs.Records()
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	samples := 0
+	for i := len(records) - 1; i >= 0 && samples < maxSpeedSamples; i-- {
+		record := records[i]
+		if record.Action != HistoryActionTranscoded || record.Encoder != encoder || record.AvgFPS <= 0 {
+			continue
+		}
+		if ResolutionClass(record.Height) != resolutionClass {
+			continue
+		}
+		total += record.AvgFPS
+		samples++
+	}
+
+	if samples == 0 {
+		return 0, nil
+	}
+	return total / float64(samples), nil
+}
+
+// appendJSONLine marshals a value and appends it as one line to a JSONL file, creating it if needed
+func appendJSONLine(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// readJSONLines calls fn with each line of a JSONL file. A missing file yields no lines.
+func readJSONLines(path string, fn func(line []byte)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
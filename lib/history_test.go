@@ -0,0 +1,163 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryStore_EncoderFPS(t *testing.T) {
+	store := NewHistoryStore(filepath.Join(t.TempDir(), "history.jsonl"))
+
+	fps, err := store.EncoderFPS("x265", "1080p")
+	if err != nil {
+		t.Fatalf("EncoderFPS on missing history should not error: %v", err)
+	}
+	if fps != 0 {
+		t.Errorf("Expected 0 fps for empty history, got %v", fps)
+	}
+
+	records := []HistoryRecord{
+		{FilePath: "a.mkv", Action: HistoryActionTranscoded, Encoder: "x265", Height: 1080, AvgFPS: 40},
+		{FilePath: "b.mkv", Action: HistoryActionTranscoded, Encoder: "x265", Height: 1080, AvgFPS: 60},
+		{FilePath: "c.mkv", Action: HistoryActionTranscoded, Encoder: "x265", Height: 2160, AvgFPS: 10},
+		{FilePath: "d.mkv", Action: HistoryActionTranscoded, Encoder: "vt_h265", Height: 1080, AvgFPS: 300},
+	}
+	for _, record := range records {
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	all, err := store.Records()
+	if err != nil {
+		t.Fatalf("Records failed: %v", err)
+	}
+	if len(all) != len(records) {
+		t.Fatalf("Expected %d records, got %d", len(records), len(all))
+	}
+	if all[0].Timestamp.IsZero() {
+		t.Errorf("Expected Append to set a timestamp")
+	}
+
+	fps, err = store.EncoderFPS("x265", "1080p")
+	if err != nil {
+		t.Fatalf("EncoderFPS failed: %v", err)
+	}
+	if fps != 50 {
+		t.Errorf("Expected average fps 50, got %v", fps)
+	}
+}
+
+func TestHistoryStore_RecordsForFile(t *testing.T) {
+	dir := t.TempDir()
+	store := NewHistoryStore(filepath.Join(dir, "history.jsonl"))
+
+	movie := filepath.Join(dir, "movie.mkv")
+	output := filepath.Join(dir, "movie-optimized.mkv")
+	store.Record(HistoryRecord{FilePath: movie, Action: HistoryActionAnalyzed})
+	store.Record(HistoryRecord{FilePath: filepath.Join(dir, "other.mkv"), Action: HistoryActionAnalyzed})
+	store.Record(HistoryRecord{FilePath: movie, Action: HistoryActionTranscoded, OutputPath: output})
+	store.Record(HistoryRecord{FilePath: movie, Action: HistoryActionSkipped, Params: map[string]string{"reason": "output_exists"}})
+
+	records, err := store.RecordsForFile(movie)
+	if err != nil {
+		t.Fatalf("RecordsForFile failed: %v", err)
+	}
+	if len(records) != 3 || records[0].Action != HistoryActionAnalyzed || records[2].Params["reason"] != "output_exists" {
+		t.Errorf("Unexpected records for %s: %+v", movie, records)
+	}
+
+	records, err = store.RecordsForFile(output)
+	if err != nil {
+		t.Fatalf("RecordsForFile failed: %v", err)
+	}
+	if len(records) != 1 || records[0].Action != HistoryActionTranscoded {
+		t.Errorf("Expected the transcode record when looking up the output, got %+v", records)
+	}
+
+	var nilStore *HistoryStore
+	nilStore.Record(HistoryRecord{FilePath: movie, Action: HistoryActionAnalyzed})
+}
+
+func TestHistoryStore_RetryableFailures(t *testing.T) {
+	dir := t.TempDir()
+	store := NewHistoryStore(filepath.Join(dir, "history.jsonl"))
+	now := time.Now()
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	store.Record(HistoryRecord{Timestamp: now.Add(-10 * 24 * time.Hour), FilePath: path("old.mkv"), Action: HistoryActionFailed})
+	store.Record(HistoryRecord{Timestamp: now.Add(-3 * time.Hour), FilePath: path("fixed.mkv"), Action: HistoryActionFailed})
+	store.Record(HistoryRecord{Timestamp: now.Add(-2 * time.Hour), FilePath: path("flaky.mkv"), Action: HistoryActionFailed})
+	store.Record(HistoryRecord{Timestamp: now.Add(-2 * time.Hour), FilePath: path("dv.mkv"), Action: HistoryActionFailed, Params: map[string]string{"permanent": "true"}})
+	store.Record(HistoryRecord{Timestamp: now.Add(-time.Hour), FilePath: path("fixed.mkv"), Action: HistoryActionTranscoded})
+	store.Record(HistoryRecord{Timestamp: now.Add(-time.Hour), FilePath: path("flaky.mkv"), Action: HistoryActionEstimated})
+	store.Record(HistoryRecord{Timestamp: now.Add(-time.Hour), FilePath: path("later.mkv"), Action: HistoryActionTranscoded})
+	store.Record(HistoryRecord{Timestamp: now.Add(-time.Minute), FilePath: path("later.mkv"), Action: HistoryActionFailed})
+
+	files, err := store.RetryableFailures(now.Add(-7 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("RetryableFailures failed: %v", err)
+	}
+	if len(files) != 2 || files[0] != path("flaky.mkv") || files[1] != path("later.mkv") {
+		t.Errorf("RetryableFailures() = %v, want flaky.mkv then later.mkv", files)
+	}
+}
+
+func TestResolutionClass(t *testing.T) {
+	tests := []struct {
+		height   int
+		expected string
+	}{
+		{2160, "2160p"},
+		{1080, "1080p"},
+		{800, "720p"},
+		{720, "720p"},
+		{480, "sd"},
+		{0, "unknown"},
+	}
+
+	for _, tt := range tests {
+		if result := ResolutionClass(tt.height); result != tt.expected {
+			t.Errorf("ResolutionClass(%d) = %q, want %q", tt.height, result, tt.expected)
+		}
+	}
+}
+
+func TestHistoryStore_TranscodeComparisons(t *testing.T) {
+	store := NewHistoryStore(filepath.Join(t.TempDir(), "history.jsonl"))
+	root := t.TempDir()
+	movie := filepath.Join(root, "movie.mkv")
+
+	records := []HistoryRecord{
+		{FilePath: movie, Action: HistoryActionTranscoded, Before: &MediaInfo{FileSize: 100}, After: &MediaInfo{FileSize: 80}},
+		{FilePath: movie, Action: HistoryActionTranscoded, Before: &MediaInfo{FileSize: 100}, After: &MediaInfo{FileSize: 40}},
+		{FilePath: filepath.Join(root, "no-analysis.mkv"), Action: HistoryActionTranscoded},
+		{FilePath: "/elsewhere/show.mkv", Action: HistoryActionTranscoded, Before: &MediaInfo{}, After: &MediaInfo{}},
+		{FilePath: movie, Action: HistoryActionSkipped},
+	}
+	for _, record := range records {
+		if err := store.Append(record); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	comparisons, err := store.TranscodeComparisons(root)
+	if err != nil {
+		t.Fatalf("TranscodeComparisons failed: %v", err)
+	}
+	if len(comparisons) != 1 {
+		t.Fatalf("Expected 1 comparison under root, got %d", len(comparisons))
+	}
+	if comparisons[0].FilePath != movie || comparisons[0].After.FileSize != 40 {
+		t.Errorf("Expected the latest transcode of %s, got %+v", movie, comparisons[0])
+	}
+
+	all, err := store.TranscodeComparisons("")
+	if err != nil {
+		t.Fatalf("TranscodeComparisons failed: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 comparisons without a root, got %d", len(all))
+	}
+}
@@ -0,0 +1,203 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultLanguage is used when no supported language is requested or found in the locale
+const DefaultLanguage = "en"
+
+// Message IDs for translated user-facing strings
+const (
+	MsgAnalysisTitle        = "analysis.title"
+	MsgAnalysisFailedTitle  = "analysis.failed_title"
+	MsgAnalysisFailed       = "analysis.failed"
+	MsgFilesAnalyzed        = "analysis.files_analyzed"
+	MsgFilesAnalyzedValue   = "analysis.files_analyzed_value"
+	MsgTotalSize            = "analysis.total_size"
+	MsgTotalDuration        = "analysis.total_duration"
+	MsgTotalDurationValue   = "analysis.total_duration_value"
+	MsgVideoCodecs          = "analysis.video_codecs"
+	MsgTranscodeTitle       = "transcode.title"
+	MsgTranscodeFailedTitle = "transcode.failed_title"
+	MsgTranscoded           = "transcode.transcoded"
+	MsgSkipped              = "transcode.skipped"
+	MsgFailed               = "transcode.failed"
+	MsgSpaceSaved           = "transcode.space_saved"
+	MsgSpaceSavedValue      = "transcode.space_saved_value"
+	MsgWallTime             = "transcode.wall_time"
+	MsgQuality              = "transcode.quality"
+	MsgQualityValue         = "transcode.quality_value"
+	MsgFailures             = "summary.failures"
+	MsgNoHistory            = "history.none"
+	MsgQueryMatched         = "query.matched"
+	MsgQueryRows            = "query.rows"
+	MsgStatsMedianBitrate   = "stats.median_bitrate"
+	MsgStatsTotalSize       = "stats.total_size"
+	MsgStatsSavings         = "stats.savings"
+)
+
+// catalogs holds each supported language's messages as fmt format strings.
+// Every catalog must define the same IDs as English, with the same verbs in the same order.
+var catalogs = map[string]map[string]string{
+	"en": {
+		MsgAnalysisTitle:        "Media analysis of %s",
+		MsgAnalysisFailedTitle:  "Media analysis failed",
+		MsgAnalysisFailed:       "analysis failed",
+		MsgFilesAnalyzed:        "Files analyzed",
+		MsgFilesAnalyzedValue:   "%d of %d",
+		MsgTotalSize:            "Total size",
+		MsgTotalDuration:        "Total duration",
+		MsgTotalDurationValue:   "%.1f hours",
+		MsgVideoCodecs:          "Video codecs",
+		MsgTranscodeTitle:       "Transcode batch complete",
+		MsgTranscodeFailedTitle: "Transcode batch failed",
+		MsgTranscoded:           "Transcoded",
+		MsgSkipped:              "Skipped",
+		MsgFailed:               "Failed",
+		MsgSpaceSaved:           "Space saved",
+		MsgSpaceSavedValue:      "%s of %s (%.1f%%)",
+		MsgWallTime:             "Wall time",
+		MsgQuality:              "Quality vs. source",
+		MsgQualityValue:         "%s (mean of %d files)",
+		MsgFailures:             "Failures (%d)",
+		MsgNoHistory:            "No history recorded for %s",
+		MsgQueryMatched:         "%d of %d files matched",
+		MsgQueryRows:            "%d rows",
+		MsgStatsMedianBitrate:   "median %.1f Mbps across %d files",
+		MsgStatsTotalSize:       "total size %s",
+		MsgStatsSavings:         "%d files could reclaim about %s of %s at quality %d",
+	},
+	"de": {
+		MsgAnalysisTitle:        "Medienanalyse von %s",
+		MsgAnalysisFailedTitle:  "Medienanalyse fehlgeschlagen",
+		MsgAnalysisFailed:       "Analyse fehlgeschlagen",
+		MsgFilesAnalyzed:        "Analysierte Dateien",
+		MsgFilesAnalyzedValue:   "%d von %d",
+		MsgTotalSize:            "Gesamtgröße",
+		MsgTotalDuration:        "Gesamtdauer",
+		MsgTotalDurationValue:   "%.1f Stunden",
+		MsgVideoCodecs:          "Video-Codecs",
+		MsgTranscodeTitle:       "Transkodierung abgeschlossen",
+		MsgTranscodeFailedTitle: "Transkodierung fehlgeschlagen",
+		MsgTranscoded:           "Transkodiert",
+		MsgSkipped:              "Übersprungen",
+		MsgFailed:               "Fehlgeschlagen",
+		MsgSpaceSaved:           "Eingesparter Speicher",
+		MsgSpaceSavedValue:      "%s von %s (%.1f%%)",
+		MsgWallTime:             "Gesamtlaufzeit",
+		MsgQuality:              "Qualität gegenüber Quelle",
+		MsgQualityValue:         "%s (Mittel über %d Dateien)",
+		MsgFailures:             "Fehler (%d)",
+		MsgNoHistory:            "Kein Verlauf für %s vorhanden",
+		MsgQueryMatched:         "%d von %d Dateien gefunden",
+		MsgQueryRows:            "%d Zeilen",
+		MsgStatsMedianBitrate:   "Median %.1f Mbps über %d Dateien",
+		MsgStatsTotalSize:       "Gesamtgröße %s",
+		MsgStatsSavings:         "%d Dateien könnten etwa %s von %s bei Qualität %d freigeben",
+	},
+	"es": {
+		MsgAnalysisTitle:        "Análisis multimedia de %s",
+		MsgAnalysisFailedTitle:  "El análisis multimedia falló",
+		MsgAnalysisFailed:       "el análisis falló",
+		MsgFilesAnalyzed:        "Archivos analizados",
+		MsgFilesAnalyzedValue:   "%d de %d",
+		MsgTotalSize:            "Tamaño total",
+		MsgTotalDuration:        "Duración total",
+		MsgTotalDurationValue:   "%.1f horas",
+		MsgVideoCodecs:          "Códecs de vídeo",
+		MsgTranscodeTitle:       "Lote de transcodificación completado",
+		MsgTranscodeFailedTitle: "Lote de transcodificación fallido",
+		MsgTranscoded:           "Transcodificados",
+		MsgSkipped:              "Omitidos",
+		MsgFailed:               "Fallidos",
+		MsgSpaceSaved:           "Espacio ahorrado",
+		MsgSpaceSavedValue:      "%s de %s (%.1f%%)",
+		MsgWallTime:             "Tiempo total",
+		MsgQuality:              "Calidad frente al original",
+		MsgQualityValue:         "%s (media de %d archivos)",
+		MsgFailures:             "Errores (%d)",
+		MsgNoHistory:            "No hay historial registrado para %s",
+		MsgQueryMatched:         "%d de %d archivos coinciden",
+		MsgQueryRows:            "%d filas",
+		MsgStatsMedianBitrate:   "mediana de %.1f Mbps en %d archivos",
+		MsgStatsTotalSize:       "tamaño total %s",
+		MsgStatsSavings:         "%d archivos podrían liberar unos %s de %s con calidad %d",
+	},
+}
+
+// currentLanguage is the language used by T
+var currentLanguage atomic.Value
+
+func init() {
+	currentLanguage.Store(DefaultLanguage)
+}
+
+// SupportedLanguages returns the codes of languages with a message catalog, sorted
+func SupportedLanguages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SetLanguage selects the language used by T
+func SetLanguage(lang string) error {
+	code := normalizeLanguage(lang)
+	if _, ok := catalogs[code]; !ok {
+		return fmt.Errorf("unsupported language %q: must be one of %s", lang, strings.Join(SupportedLanguages(), ", "))
+	}
+	currentLanguage.Store(code)
+	return nil
+}
+
+// Language returns the language currently used by T
+func Language() string {
+	return currentLanguage.Load().(string)
+}
+
+// DetectLanguage picks the language from MEDIA_MGMT_LANG, then the POSIX locale variables
+// LC_ALL, LC_MESSAGES, and LANG. Unsupported locales fall back to English.
+func DetectLanguage() string {
+	for _, env := range []string{"MEDIA_MGMT_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+		value := os.Getenv(env)
+		if value == "" {
+			continue
+		}
+		if code := normalizeLanguage(value); catalogs[code] != nil {
+			return code
+		}
+		return DefaultLanguage
+	}
+	return DefaultLanguage
+}
+
+// normalizeLanguage reduces a locale such as "de_DE.UTF-8" or "es-MX" to its language code
+func normalizeLanguage(locale string) string {
+	code := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(code, "_-.@"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// T formats a message in the current language, falling back to English for missing IDs
+func T(id string, args ...interface{}) string {
+	format, ok := catalogs[Language()][id]
+	if !ok {
+		format, ok = catalogs[DefaultLanguage][id]
+	}
+	if !ok {
+		format = id
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
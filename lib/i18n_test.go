@@ -0,0 +1,70 @@
+package lib
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestCatalogsMatchEnglish(t *testing.T) {
+	verbPattern := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for lang, catalog := range catalogs {
+		if len(catalog) != len(catalogs[DefaultLanguage]) {
+			t.Errorf("%s catalog has %d messages, want %d", lang, len(catalog), len(catalogs[DefaultLanguage]))
+		}
+		for id, english := range catalogs[DefaultLanguage] {
+			translated, ok := catalog[id]
+			if !ok {
+				t.Errorf("%s catalog is missing %s", lang, id)
+				continue
+			}
+			if got, want := verbPattern.FindAllString(translated, -1), verbPattern.FindAllString(english, -1); !slices.Equal(got, want) {
+				t.Errorf("%s %s has verbs %v, want %v", lang, id, got, want)
+			}
+		}
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{"no locale", map[string]string{}, "en"},
+		{"LANG", map[string]string{"LANG": "de_DE.UTF-8"}, "de"},
+		{"LC_ALL overrides LANG", map[string]string{"LC_ALL": "es_MX.UTF-8", "LANG": "de_DE.UTF-8"}, "es"},
+		{"LC_MESSAGES overrides LANG", map[string]string{"LC_MESSAGES": "es", "LANG": "de_DE"}, "es"},
+		{"MEDIA_MGMT_LANG overrides locale", map[string]string{"MEDIA_MGMT_LANG": "de", "LC_ALL": "es_ES"}, "de"},
+		{"unsupported locale", map[string]string{"LANG": "fr_FR.UTF-8"}, "en"},
+		{"C locale", map[string]string{"LC_ALL": "C", "LANG": "de_DE"}, "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, env := range []string{"MEDIA_MGMT_LANG", "LC_ALL", "LC_MESSAGES", "LANG"} {
+				t.Setenv(env, tt.env[env])
+			}
+			if got := DetectLanguage(); got != tt.expected {
+				t.Errorf("DetectLanguage() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	defer SetLanguage(DefaultLanguage)
+
+	if err := SetLanguage("de-AT"); err != nil {
+		t.Fatalf("SetLanguage() error = %v", err)
+	}
+	if got := T(MsgQueryMatched, 3, 10); got != "3 von 10 Dateien gefunden" {
+		t.Errorf("T() = %q", got)
+	}
+	if got := T("unknown.message"); got != "unknown.message" {
+		t.Errorf("T() for unknown ID = %q", got)
+	}
+	if err := SetLanguage("fr"); err == nil {
+		t.Error("SetLanguage(\"fr\") should fail")
+	}
+}
@@ -0,0 +1,167 @@
+package lib
+
+import (
+	"bufio"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFilename is the per-directory file listing paths for scans to leave out
+const IgnoreFilename = ".mediamgmtignore"
+
+// ignoreRule is one line of an ignore file
+type ignoreRule struct {
+	segments []string // Pattern split on "/"
+	negate   bool     // "!" re-includes paths an earlier rule ignored
+	dirOnly  bool     // Trailing "/" matches directories only
+	anchored bool     // Pattern contains a "/" and matches relative to the ignore file's directory
+}
+
+// parseIgnoreRules parses gitignore-style lines: blank lines and "#" comments are skipped, "!"
+// negates, a trailing "/" matches only directories, and a pattern containing a "/" is relative
+// to the ignore file's directory while one without matches a name at any depth. "*", "?", and
+// "[...]" match within a path segment and "**" matches any number of segments. "\#" and "\!"
+// match a literal leading "#" or "!".
+func parseIgnoreRules(lines []string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.segments = strings.Split(line, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matches reports whether the rule matches rel, a slash-separated path relative to the ignore
+// file's directory
+func (r ignoreRule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	if !r.anchored {
+		ok, _ := filepath.Match(r.segments[0], parts[len(parts)-1])
+		return ok
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where "**" spans any number of them
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		return matchSegments(pattern[1:], parts) || (len(parts) > 0 && matchSegments(pattern, parts[1:]))
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], parts[0])
+	return ok && matchSegments(pattern[1:], parts[1:])
+}
+
+// IgnoreMatcher applies the ignore files found under a root directory. Rules in deeper
+// directories take precedence, and within a file later rules take precedence, as with
+// .gitignore. Once a directory is ignored, nothing below it is scanned.
+// Safe for concurrent use.
+type IgnoreMatcher struct {
+	root  string
+	mutex sync.RWMutex
+	rules map[string][]ignoreRule // Rules by the directory holding the ignore file
+}
+
+// NewIgnoreMatcher creates a matcher for paths under root. Ignore files are loaded as their
+// directories are visited with Enter.
+func NewIgnoreMatcher(root string) *IgnoreMatcher {
+	return &IgnoreMatcher{root: filepath.Clean(root), rules: make(map[string][]ignoreRule)}
+}
+
+// Enter loads dir's ignore file, if any. Call it for each directory before matching its entries.
+func (m *IgnoreMatcher) Enter(dir string) {
+	dir = filepath.Clean(dir)
+	file, err := os.Open(filepath.Join(dir, IgnoreFilename))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to read ignore file", "dir", dir, "error", err)
+		}
+		return
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if rules := parseIgnoreRules(lines); len(rules) > 0 {
+		m.mutex.Lock()
+		m.rules[dir] = rules
+		m.mutex.Unlock()
+		slog.Debug("Loaded ignore file", "dir", dir, "rules", len(rules))
+	}
+}
+
+// Ignored reports whether path, which lies under the root, is excluded by the ignore files of
+// the directories above it
+func (m *IgnoreMatcher) Ignored(path string, isDir bool) bool {
+	path = filepath.Clean(path)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	if path == m.root || len(m.rules) == 0 {
+		return false
+	}
+
+	// Walk the ancestors from the root down, so deeper ignore files are applied last
+	var dirs []string
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == m.root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+
+	ignored := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules := m.rules[dirs[i]]
+		if len(rules) == 0 {
+			continue
+		}
+		rel, err := filepath.Rel(dirs[i], path)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, rule := range rules {
+			if rule.matches(rel, isDir) {
+				ignored = !rule.negate
+			}
+		}
+	}
+	return ignored
+}
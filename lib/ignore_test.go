@@ -0,0 +1,97 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"Home Videos", "Home Videos", true, true},
+		{"Home Videos", "family/Home Videos", true, true},
+		{"*.m2ts", "disc/stream.m2ts", false, true},
+		{"*.m2ts", "disc/stream.mkv", false, false},
+		{"work/", "work", true, true},
+		{"work/", "work", false, false},
+		{"/work", "work", true, true},
+		{"/work", "archive/work", true, false},
+		{"clips/raw", "clips/raw", true, true},
+		{"clips/raw", "old/clips/raw", true, false},
+		{"**/raw", "old/clips/raw", true, true},
+		{"footage/**/*.mov", "footage/2024/jan/a.mov", false, true},
+		{"footage/**/*.mov", "footage/a.mov", false, true},
+		{"footage/**/*.mov", "other/a.mov", false, false},
+		{`\#1.mkv`, "#1.mkv", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			rules := parseIgnoreRules([]string{tt.pattern})
+			if len(rules) != 1 {
+				t.Fatalf("parseIgnoreRules(%q) = %d rules, want 1", tt.pattern, len(rules))
+			}
+			if got := rules[0].matches(tt.path, tt.isDir); got != tt.want {
+				t.Errorf("matches(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+
+	if rules := parseIgnoreRules([]string{"", "# comment", "   ", "/"}); len(rules) != 0 {
+		t.Errorf("parseIgnoreRules() = %+v, want no rules", rules)
+	}
+}
+
+func TestScanVideoFilesIgnore(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		"movie.mkv",
+		"Home Videos/birthday.mp4",
+		"work/meeting.mov",
+		"tv/show/e01.mkv",
+		"tv/show/e01.sample.mkv",
+		"tv/show/keep.sample.mkv",
+	}
+	for _, file := range files {
+		path := filepath.Join(root, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ignores := map[string]string{
+		IgnoreFilename:                              "# personal footage\nHome Videos/\n/work\n",
+		filepath.Join("tv", IgnoreFilename):         "*.sample.mkv\n",
+		filepath.Join("tv", "show", IgnoreFilename): "!keep.sample.mkv\n",
+	}
+	for name, content := range ignores {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := NewFileScanner(root).ScanVideoFiles(context.Background())
+	if err != nil {
+		t.Fatalf("ScanVideoFiles() error = %v", err)
+	}
+	var got []string
+	for _, path := range found {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	sort.Strings(got)
+	want := []string{"movie.mkv", "tv/show/e01.mkv", "tv/show/keep.sample.mkv"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("ScanVideoFiles() = %v, want %v", got, want)
+	}
+}
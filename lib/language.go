@@ -0,0 +1,66 @@
+package lib
+
+import "strings"
+
+// languageAliases maps the ISO 639-2 codes media containers tag tracks with, including the
+// bibliographic variants such as "ger" and "fre", to the ISO 639-1 codes people usually type
+var languageAliases = map[string]string{
+	"ara": "ar", "bul": "bg", "cat": "ca", "ces": "cs", "cze": "cs", "chi": "zh", "zho": "zh",
+	"dan": "da", "deu": "de", "ger": "de", "ell": "el", "gre": "el", "eng": "en", "spa": "es",
+	"est": "et", "fas": "fa", "per": "fa", "fin": "fi", "fra": "fr", "fre": "fr", "heb": "he",
+	"hin": "hi", "hrv": "hr", "hun": "hu", "ind": "id", "isl": "is", "ice": "is", "ita": "it",
+	"jpn": "ja", "kor": "ko", "lit": "lt", "lav": "lv", "msa": "ms", "may": "ms", "nld": "nl",
+	"dut": "nl", "nor": "no", "nob": "nb", "nno": "nn", "pol": "pl", "por": "pt", "ron": "ro",
+	"rum": "ro", "rus": "ru", "slk": "sk", "slo": "sk", "slv": "sl", "srp": "sr", "swe": "sv",
+	"tam": "ta", "tel": "te", "tha": "th", "tur": "tr", "ukr": "uk", "vie": "vi",
+}
+
+// NormalizeLanguage reduces a language tag such as "eng", "EN", or "en-US" to its ISO 639-1
+// code where one is known, so tags written different ways compare equal. Unknown codes are
+// returned lowercased.
+func NormalizeLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code, _, _ = strings.Cut(code, "-")
+	if short, ok := languageAliases[code]; ok {
+		return short
+	}
+	return code
+}
+
+// LongLanguageCode returns the ISO 639-2 code of a language tag, such as "deu" for "de" or
+// "DE", for tools that only take three-letter codes. Three-letter tags are returned lowercased;
+// other tags it does not know give "und".
+func LongLanguageCode(code string) string {
+	code = NormalizeLanguage(code)
+	if len(code) == 3 {
+		return code
+	}
+	long := ""
+	for alias, short := range languageAliases {
+		// Of a language's codes, prefer the first alphabetically, so the result is stable
+		if short == code && (long == "" || alias < long) {
+			long = alias
+		}
+	}
+	if long == "" {
+		return "und"
+	}
+	return long
+}
+
+// IsUndeterminedLanguage reports whether a track's language tag is missing or "und"
+func IsUndeterminedLanguage(code string) bool {
+	code = NormalizeLanguage(code)
+	return code == "" || code == "und"
+}
+
+// MatchesLanguage reports whether a track's language tag is one of languages
+func MatchesLanguage(code string, languages []string) bool {
+	code = NormalizeLanguage(code)
+	for _, language := range languages {
+		if NormalizeLanguage(language) == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package lib
+
+import "testing"
+
+func TestMatchesLanguage(t *testing.T) {
+	tests := []struct {
+		code      string
+		languages []string
+		expected  bool
+	}{
+		{"eng", []string{"en", "ja"}, true},
+		{"jpn", []string{"en", "ja"}, true},
+		{"ger", []string{"deu"}, true},
+		{"en-US", []string{"eng"}, true},
+		{"FRE", []string{"fr"}, true},
+		{"spa", []string{"en", "ja"}, false},
+		{"", []string{"en"}, false},
+		{"tlh", []string{"tlh"}, true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchesLanguage(tt.code, tt.languages); got != tt.expected {
+			t.Errorf("MatchesLanguage(%q, %v) = %v, want %v", tt.code, tt.languages, got, tt.expected)
+		}
+	}
+	for code, want := range map[string]string{"en": "eng", "DE": "deu", "ger": "deu", "fr": "fra", "tlh": "tlh", "xx": "und", "": "und"} {
+		if got := LongLanguageCode(code); got != want {
+			t.Errorf("LongLanguageCode(%q) = %q, want %q", code, got, want)
+		}
+	}
+	if !IsUndeterminedLanguage("und") || !IsUndeterminedLanguage("") || IsUndeterminedLanguage("eng") {
+		t.Error("IsUndeterminedLanguage misclassified a tag")
+	}
+}
@@ -0,0 +1,193 @@
+package lib
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLeaseTTL is how long a lease survives without a heartbeat before another run may
+	// take it over, so a crashed run blocks its files only briefly
+	defaultLeaseTTL = 2 * time.Minute
+	// leaseHeartbeatInterval is how often a held lease is renewed
+	leaseHeartbeatInterval = 30 * time.Second
+)
+
+// LeaseHolder identifies the run holding a lease
+type LeaseHolder struct {
+	Owner      string    `json:"owner"`
+	Host       string    `json:"host"`
+	PID        int       `json:"pid"`
+	Command    string    `json:"command"`
+	File       string    `json:"file"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// LeaseHeldError reports a file leased by another run
+type LeaseHeldError struct {
+	File   string
+	Holder LeaseHolder
+}
+
+func (e *LeaseHeldError) Error() string {
+	return fmt.Sprintf("%s is being processed by %s (pid %d on %s) since %s",
+		e.File, e.Holder.Command, e.Holder.PID, e.Holder.Host, e.Holder.AcquiredAt.Format(time.RFC3339))
+}
+
+// LeaseStore hands out per-file leases kept as files in the state directory, so overlapping
+// runs, even on different machines sharing the state directory, never work on the same file at
+// once. A lease's modification time is its heartbeat.
+type LeaseStore struct {
+	Dir     string
+	TTL     time.Duration // Heartbeat age after which a lease is considered abandoned
+	command string
+	owner   string
+}
+
+// DefaultLeaseDir returns the lease directory in the state directory
+func DefaultLeaseDir() string {
+	return filepath.Join(DefaultStateDir(), "leases")
+}
+
+// NewLeaseStore creates a store whose leases are held on behalf of command
+func NewLeaseStore(dir, command string) *LeaseStore {
+	host, _ := os.Hostname()
+	return &LeaseStore{
+		Dir:     dir,
+		TTL:     defaultLeaseTTL,
+		command: command,
+		owner:   fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// Lease is a held claim on a file, renewed in the background until released
+type Lease struct {
+	path    string
+	owner   string
+	stop    chan struct{}
+	stopped sync.Once
+}
+
+// Acquire leases filePath, taking over leases whose holder stopped sending heartbeats.
+// Returns a *LeaseHeldError if another run holds a live lease.
+func (s *LeaseStore) Acquire(filePath string) (*Lease, error) {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lease directory: %w", err)
+	}
+	sum := sha1.Sum([]byte(filePath))
+	path := filepath.Join(s.Dir, hex.EncodeToString(sum[:])+".json")
+
+	host, _ := os.Hostname()
+	holder := LeaseHolder{Owner: s.owner, Host: host, PID: os.Getpid(), Command: s.command, File: filePath, AcquiredAt: time.Now().UTC()}
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return nil, err
+	}
+
+	// Two attempts: the second follows removing an abandoned lease
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, writeErr := file.Write(data)
+			if closeErr := file.Close(); writeErr == nil {
+				writeErr = closeErr
+			}
+			if writeErr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lease: %w", writeErr)
+			}
+			lease := &Lease{path: path, owner: s.owner, stop: make(chan struct{})}
+			go lease.heartbeat()
+			return lease, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lease: %w", err)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // Released in the meantime
+		}
+		if age := time.Since(info.ModTime()); age < s.TTL {
+			held := &LeaseHeldError{File: filePath}
+			if existing, err := os.ReadFile(path); err == nil {
+				json.Unmarshal(existing, &held.Holder)
+			}
+			return nil, held
+		}
+		slog.Warn("Taking over abandoned lease", "file", filePath, "lease", path)
+		if err := s.removeAbandoned(path); err != nil {
+			return nil, err
+		}
+	}
+	return nil, &LeaseHeldError{File: filePath}
+}
+
+// removeAbandoned removes the abandoned lease at path. Runs taking it over at once could otherwise
+// each remove the lease another just created, so it is first renamed to a name of this store's
+// own, which only one run can do to each lease file. A run that finds it renamed a live lease,
+// created by a run that took over first, puts that lease back.
+func (s *LeaseStore) removeAbandoned(path string) error {
+	claimed := path + ".takeover-" + s.owner
+	if err := os.Rename(path, claimed); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Taken over or released in the meantime
+		}
+		return fmt.Errorf("failed to take over abandoned lease: %w", err)
+	}
+	if info, err := os.Stat(claimed); err == nil && time.Since(info.ModTime()) < s.TTL {
+		// Linking fails if yet another run has leased the file since
+		if err := os.Link(claimed, path); err != nil {
+			slog.Warn("Failed to restore lease taken over by another run", "lease", path, "error", err)
+		}
+	}
+	if err := os.Remove(claimed); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove abandoned lease: %w", err)
+	}
+	return nil
+}
+
+// heartbeat renews the lease until it is released
+func (l *Lease) heartbeat() {
+	ticker := time.NewTicker(leaseHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			if err := os.Chtimes(l.path, now, now); err != nil {
+				slog.Warn("Failed to renew lease", "lease", l.path, "error", err)
+			}
+		}
+	}
+}
+
+// Release stops renewing the lease and removes it, unless another run has since taken it over.
+// Safe to call on a nil lease and more than once.
+func (l *Lease) Release() {
+	if l == nil {
+		return
+	}
+	l.stopped.Do(func() {
+		close(l.stop)
+		var holder LeaseHolder
+		if data, err := os.ReadFile(l.path); err != nil || json.Unmarshal(data, &holder) != nil || holder.Owner != l.owner {
+			return
+		}
+		if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to release lease", "lease", l.path, "error", err)
+		}
+	})
+}
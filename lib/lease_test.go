@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLeaseStore(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "movie.mkv")
+	first := NewLeaseStore(filepath.Join(dir, "leases"), "transcode")
+	second := NewLeaseStore(filepath.Join(dir, "leases"), "transcode")
+
+	lease, err := first.Acquire(file)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	_, err = second.Acquire(file)
+	var held *LeaseHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second Acquire() error = %v, want *LeaseHeldError", err)
+	}
+	if held.Holder.Command != "transcode" || held.Holder.PID != os.Getpid() {
+		t.Errorf("holder = %+v, want this process's transcode run", held.Holder)
+	}
+
+	lease.Release()
+	lease.Release()
+	again, err := second.Acquire(file)
+	if err != nil {
+		t.Fatalf("Acquire() after Release() error = %v", err)
+	}
+	defer again.Release()
+
+	// The first store's stale lease no longer owns the file and must not remove the new one
+	lease.Release()
+	if _, err := first.Acquire(file); err == nil {
+		t.Error("Acquire() succeeded while another store holds the lease")
+	}
+}
+
+func TestLeaseStoreTakesOverAbandonedLease(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "movie.mkv")
+	crashed := NewLeaseStore(filepath.Join(dir, "leases"), "transcode")
+	abandoned, err := crashed.Acquire(file)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	close(abandoned.stop) // Stop the heartbeat as a crash would
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(abandoned.path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewLeaseStore(filepath.Join(dir, "leases"), "transcode")
+	lease, err := store.Acquire(file)
+	if err != nil {
+		t.Fatalf("Acquire() of abandoned lease error = %v", err)
+	}
+	defer lease.Release()
+	leaseInfo, err := os.Stat(lease.path)
+	if err != nil {
+		t.Fatalf("lease file missing after takeover: %v", err)
+	}
+
+	// A run that saw the abandoned lease too, but takes it over second, leaves the new lease alone
+	late := NewLeaseStore(filepath.Join(dir, "leases"), "transcode")
+	if err := late.removeAbandoned(lease.path); err != nil {
+		t.Fatalf("removeAbandoned() error = %v", err)
+	}
+	if info, err := os.Stat(lease.path); err != nil || !os.SameFile(info, leaseInfo) {
+		t.Errorf("lease taken over first was not kept: %v", err)
+	}
+	var held *LeaseHeldError
+	if _, err := late.Acquire(file); !errors.As(err, &held) {
+		t.Errorf("Acquire() after losing the takeover = %v, want *LeaseHeldError", err)
+	}
+}
@@ -0,0 +1,332 @@
+package lib
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// manifestVersion is incremented whenever the manifest format changes incompatibly
+const manifestVersion = 1
+
+// Manifest is an integrity record of a media library suitable for archiving with backups.
+// Entries store paths relative to Root so a restored library can be verified at a new location.
+type Manifest struct {
+	Version     int             `json:"version"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Root        string          `json:"root"`
+	Entries     []ManifestEntry `json:"entries"`
+	PublicKey   string          `json:"public_key,omitempty"` // Hex-encoded ed25519 public key
+	Signature   string          `json:"signature,omitempty"`  // Hex-encoded ed25519 signature
+}
+
+// ManifestEntry records the identity and key media attributes of a single file
+type ManifestEntry struct {
+	Path           string  `json:"path"`
+	Size           int64   `json:"size"`
+	SHA256         string  `json:"sha256"`
+	Duration       float64 `json:"duration,omitempty"`
+	VideoCodec     string  `json:"video_codec,omitempty"`
+	VideoWidth     int     `json:"video_width,omitempty"`
+	VideoHeight    int     `json:"video_height,omitempty"`
+	AudioTracks    int     `json:"audio_tracks,omitempty"`
+	SubtitleTracks int     `json:"subtitle_tracks,omitempty"`
+}
+
+// ManifestProblem describes a discrepancy found while verifying a library against a manifest
+type ManifestProblem struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// BuildManifest checksums the given files and records their key media attributes.
+// mediaInfos may be nil or partial; files without media info only get size and checksum.
+// Files that cannot be read are left out of the manifest and returned as problems.
+func BuildManifest(ctx context.Context, root string, files []string, mediaInfos []*MediaInfo, parallelism int) (*Manifest, []ManifestProblem, error) {
+	infoByPath := make(map[string]*MediaInfo, len(mediaInfos))
+	for _, info := range mediaInfos {
+		infoByPath[info.FilePath] = info
+	}
+
+	checksums, err := checksumFiles(ctx, files, parallelism)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var problems []ManifestProblem
+	manifest := &Manifest{
+		Version:     manifestVersion,
+		GeneratedAt: time.Now().UTC(),
+		Root:        root,
+		Entries:     make([]ManifestEntry, 0, len(files)),
+	}
+
+	for _, file := range files {
+		relPath, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to make %s relative to %s: %w", file, root, err)
+		}
+
+		sum := checksums[file]
+		if sum.err != nil {
+			problems = append(problems, ManifestProblem{Path: filepath.ToSlash(relPath), Reason: "unreadable: " + sum.err.Error()})
+			continue
+		}
+
+		entry := ManifestEntry{
+			Path:   filepath.ToSlash(relPath),
+			Size:   sum.size,
+			SHA256: sum.sha256,
+		}
+		if info, exists := infoByPath[file]; exists {
+			entry.Duration = info.Duration
+			entry.VideoCodec = info.VideoCodec
+			entry.VideoWidth = info.VideoWidth
+			entry.VideoHeight = info.VideoHeight
+			entry.AudioTracks = len(info.AudioTracks)
+			entry.SubtitleTracks = len(info.SubtitleTracks)
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	sort.Slice(manifest.Entries, func(i, j int) bool {
+		return manifest.Entries[i].Path < manifest.Entries[j].Path
+	})
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+
+	return manifest, problems, nil
+}
+
+// signingPayload returns the canonical bytes covered by the manifest signature
+func (m *Manifest) signingPayload() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	return json.Marshal(unsigned)
+}
+
+// Sign embeds the public key and an ed25519 signature over the manifest contents
+func (m *Manifest) Sign(privateKey ed25519.PrivateKey) error {
+	m.PublicKey = hex.EncodeToString(privateKey.Public().(ed25519.PublicKey))
+
+	payload, err := m.signingPayload()
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest for signing: %w", err)
+	}
+	m.Signature = hex.EncodeToString(ed25519.Sign(privateKey, payload))
+	return nil
+}
+
+// VerifySignature checks the manifest signature. If trustedKey is non-nil, the embedded
+// public key must match it; otherwise the embedded key is used as-is.
+func (m *Manifest) VerifySignature(trustedKey ed25519.PublicKey) error {
+	if m.Signature == "" || m.PublicKey == "" {
+		return fmt.Errorf("manifest is not signed")
+	}
+
+	publicKey, err := hex.DecodeString(m.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("manifest contains an invalid public key")
+	}
+	if trustedKey != nil && !ed25519.PublicKey(publicKey).Equal(trustedKey) {
+		return fmt.Errorf("manifest was signed by an untrusted key %s", m.PublicKey)
+	}
+
+	signature, err := hex.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("manifest contains an invalid signature encoding")
+	}
+
+	payload, err := m.signingPayload()
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest for verification: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload, signature) {
+		return fmt.Errorf("manifest signature is invalid")
+	}
+	return nil
+}
+
+// VerifyFiles checks every manifest entry against the files under root.
+// Reports missing, unreadable, and resized files, and checksum mismatches.
+func (m *Manifest) VerifyFiles(ctx context.Context, root string, parallelism int) ([]ManifestProblem, error) {
+	files := make([]string, 0, len(m.Entries))
+	var problems []ManifestProblem
+
+	for _, entry := range m.Entries {
+		path := filepath.Join(root, filepath.FromSlash(entry.Path))
+		info, err := os.Stat(path)
+		if err != nil {
+			problems = append(problems, ManifestProblem{Path: entry.Path, Reason: "missing"})
+			continue
+		}
+		if info.Size() != entry.Size {
+			problems = append(problems, ManifestProblem{
+				Path:   entry.Path,
+				Reason: fmt.Sprintf("size mismatch: expected %d, found %d", entry.Size, info.Size()),
+			})
+			continue
+		}
+		files = append(files, path)
+	}
+
+	checksums, err := checksumFiles(ctx, files, parallelism)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range m.Entries {
+		path := filepath.Join(root, filepath.FromSlash(entry.Path))
+		sum, ok := checksums[path]
+		switch {
+		case !ok:
+			// Already reported as missing or resized
+		case sum.err != nil:
+			problems = append(problems, ManifestProblem{Path: entry.Path, Reason: "unreadable: " + sum.err.Error()})
+		case sum.sha256 != entry.SHA256:
+			problems = append(problems, ManifestProblem{Path: entry.Path, Reason: "checksum mismatch"})
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems, nil
+}
+
+// WriteManifest saves a manifest as indented JSON
+func WriteManifest(manifest *Manifest, path string) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest loads a manifest from a JSON file
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// LoadOrCreateSigningKey reads a hex-encoded ed25519 seed from path, generating and
+// saving a new key if the file does not exist. Returns true if a new key was created.
+func LoadOrCreateSigningKey(path string) (ed25519.PrivateKey, bool, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, false, fmt.Errorf("invalid signing key in %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), false, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, false, fmt.Errorf("failed to create signing key directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(privateKey.Seed())+"\n"), 0600); err != nil {
+		return nil, false, fmt.Errorf("failed to write signing key: %w", err)
+	}
+	return privateKey, true, nil
+}
+
+// fileChecksum holds the size and SHA-256 digest of a file, or the error reading it
+type fileChecksum struct {
+	size   int64
+	sha256 string
+	err    error
+}
+
+// checksumFiles computes SHA-256 digests of files in parallel. Every file gets a result;
+// files that cannot be read carry the error instead of a digest.
+func checksumFiles(ctx context.Context, files []string, parallelism int) (map[string]fileChecksum, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	jobs := make(chan string)
+	results := make(map[string]fileChecksum, len(files))
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				sum, err := checksumFile(path)
+				if err != nil {
+					slog.Warn("Failed to checksum file", "file", path, "error", err)
+					sum.err = err
+				}
+				mutex.Lock()
+				results[path] = sum
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, file := range files {
+		select {
+		case jobs <- file:
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// checksumFile computes the size and SHA-256 digest of a single file
+func checksumFile(path string) (fileChecksum, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fileChecksum{}, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return fileChecksum{}, err
+	}
+	return fileChecksum{size: size, sha256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
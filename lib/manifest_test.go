@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"context"
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifest_SignAndVerify(t *testing.T) {
+	root := t.TempDir()
+	files := []string{
+		filepath.Join(root, "movie.mkv"),
+		filepath.Join(root, "shows", "episode.mp4"),
+	}
+	for _, file := range files {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte("content of "+filepath.Base(file)), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+	}
+
+	mediaInfos := []*MediaInfo{{FilePath: files[0], VideoCodec: "hevc", VideoWidth: 1920, VideoHeight: 1080}}
+
+	ctx := context.Background()
+	manifest, problems, err := BuildManifest(ctx, root, files, mediaInfos, 2)
+	if err != nil {
+		t.Fatalf("BuildManifest failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+	if len(manifest.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(manifest.Entries))
+	}
+	if manifest.Entries[0].Path != "movie.mkv" || manifest.Entries[0].VideoCodec != "hevc" {
+		t.Errorf("Unexpected first entry: %+v", manifest.Entries[0])
+	}
+
+	key, created, err := LoadOrCreateSigningKey(filepath.Join(t.TempDir(), "manifest.key"))
+	if err != nil || !created {
+		t.Fatalf("Expected a new signing key, got created=%v err=%v", created, err)
+	}
+	if err := manifest.Sign(key); err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if err := WriteManifest(manifest, manifestPath); err != nil {
+		t.Fatalf("WriteManifest failed: %v", err)
+	}
+	loaded, err := ReadManifest(manifestPath)
+	if err != nil {
+		t.Fatalf("ReadManifest failed: %v", err)
+	}
+	if err := loaded.VerifySignature(key.Public().(ed25519.PublicKey)); err != nil {
+		t.Errorf("Expected valid signature, got %v", err)
+	}
+
+	problems, err = loaded.VerifyFiles(ctx, root, 2)
+	if err != nil {
+		t.Fatalf("VerifyFiles failed: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+
+	if err := os.WriteFile(files[1], []byte("CONTENT OF EPISODE.MP4"), 0644); err != nil {
+		t.Fatalf("Failed to modify file: %v", err)
+	}
+	if err := os.Remove(files[0]); err != nil {
+		t.Fatalf("Failed to remove file: %v", err)
+	}
+	problems, err = loaded.VerifyFiles(ctx, root, 2)
+	if err != nil {
+		t.Fatalf("VerifyFiles failed: %v", err)
+	}
+	if len(problems) != 2 || problems[0].Reason != "missing" || problems[1].Reason != "checksum mismatch" {
+		t.Errorf("Expected missing and checksum mismatch problems, got %v", problems)
+	}
+
+	loaded.Entries[0].Size++
+	if err := loaded.VerifySignature(nil); err == nil {
+		t.Errorf("Expected tampered manifest to fail signature verification")
+	}
+}
+
+func TestManifest_UnreadableFiles(t *testing.T) {
+	root := t.TempDir()
+	readable := filepath.Join(root, "movie.mkv")
+	if err := os.WriteFile(readable, []byte("content of movie.mkv"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	// Reading a directory fails even for root, unlike a file without read permission
+	unreadable := filepath.Join(root, "locked.mkv")
+	if err := os.Mkdir(unreadable, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	ctx := context.Background()
+	manifest, problems, err := BuildManifest(ctx, root, []string{readable, unreadable}, nil, 2)
+	if err != nil {
+		t.Fatalf("BuildManifest failed: %v", err)
+	}
+	if len(manifest.Entries) != 1 || manifest.Entries[0].Path != "movie.mkv" {
+		t.Errorf("Expected only the readable file in the manifest, got %+v", manifest.Entries)
+	}
+	if len(problems) != 1 || problems[0].Path != "locked.mkv" || !strings.HasPrefix(problems[0].Reason, "unreadable: ") {
+		t.Errorf("Expected the unreadable file to be reported, got %v", problems)
+	}
+
+	info, err := os.Stat(unreadable)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	manifest.Entries = append(manifest.Entries, ManifestEntry{Path: "locked.mkv", Size: info.Size(), SHA256: "00"})
+	problems, err = manifest.VerifyFiles(ctx, root, 2)
+	if err != nil {
+		t.Fatalf("VerifyFiles failed: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Path != "locked.mkv" || !strings.HasPrefix(problems[0].Reason, "unreadable: ") {
+		t.Errorf("Expected the unreadable file to fail verification, got %v", problems)
+	}
+}
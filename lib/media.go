@@ -18,6 +18,23 @@ func FormatSize(bytes int64) string {
 	}
 }
 
+// ResolutionClass buckets a video height into a coarse class such as "2160p" or "sd".
+// Used to group files with similar encoding cost and characteristics.
+func ResolutionClass(height int) string {
+	switch {
+	case height >= 1600:
+		return "2160p"
+	case height >= 900:
+		return "1080p"
+	case height >= 600:
+		return "720p"
+	case height > 0:
+		return "sd"
+	default:
+		return "unknown"
+	}
+}
+
 // PrintMediaInfo logs comprehensive media information for a file.
 // Uses the media analyzer to extract metadata and logs resolution, duration, size, bitrate, codec, and HDR status.
 func PrintMediaInfo(filePath string) error {
@@ -38,7 +55,13 @@ func printMediaInfoWithRatio(filePath string, originalFileSize int64) error {
 	if err != nil {
 		return err
 	}
+	LogMediaInfo(mediaInfo, originalFileSize)
+	return nil
+}
 
+// LogMediaInfo logs media information already analyzed, with a size ratio comparison to
+// originalFileSize when it is positive
+func LogMediaInfo(mediaInfo *MediaInfo, originalFileSize int64) {
 	var sizeStr string
 	if mediaInfo.FileSize >= 1024*1024*1024 {
 		sizeStr = fmt.Sprintf("%.1f GB", float64(mediaInfo.FileSize)/(1024*1024*1024))
@@ -65,6 +88,9 @@ func printMediaInfoWithRatio(filePath string, originalFileSize int64) error {
 		"codec", mediaInfo.VideoCodec,
 		"hdr", mediaInfo.HasDolbyVision || mediaInfo.ColorTransfer == "smpte2084" || mediaInfo.ColorSpace == "bt2020nc",
 	}
+	if mediaInfo.HDR != nil {
+		logFields = append(logFields, "hdr_format", mediaInfo.HDR.String())
+	}
 
 	if originalFileSize > 0 {
 		ratio := float64(mediaInfo.FileSize) / float64(originalFileSize)
@@ -72,5 +98,4 @@ func printMediaInfoWithRatio(filePath string, originalFileSize int64) error {
 	}
 
 	slog.Info("Media info", logFields...)
-	return nil
 }
\ No newline at end of file
@@ -0,0 +1,244 @@
+package lib
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// mediaDBSchema creates the tables holding analysis results. Tracks are stored as child
+// tables keyed by file path so they can be joined for per-track queries.
+const mediaDBSchema = `
+CREATE TABLE IF NOT EXISTS media (
+	file_path        TEXT PRIMARY KEY,
+	file_size        INTEGER NOT NULL,
+	duration         REAL NOT NULL,
+	video_codec      TEXT NOT NULL,
+	video_bitrate    INTEGER NOT NULL,
+	video_width      INTEGER NOT NULL,
+	video_height     INTEGER NOT NULL,
+	video_profile    TEXT NOT NULL,
+	video_level      TEXT NOT NULL,
+	pixel_format     TEXT NOT NULL,
+	frame_rate       REAL NOT NULL,
+	bit_depth        INTEGER NOT NULL,
+	scan_type        TEXT NOT NULL,
+	is_vbr           INTEGER NOT NULL,
+	is_vfr           INTEGER NOT NULL,
+	color_space      TEXT NOT NULL,
+	color_transfer   TEXT NOT NULL,
+	has_dolby_vision INTEGER NOT NULL,
+	hdr_format       TEXT NOT NULL,
+	dv_profile       INTEGER NOT NULL,
+	max_cll          INTEGER NOT NULL,
+	max_fall         INTEGER NOT NULL,
+	audio_tracks     INTEGER NOT NULL,
+	subtitle_tracks  INTEGER NOT NULL,
+	analyzed_at      TEXT NOT NULL,
+	archived_to      TEXT NOT NULL DEFAULT '',
+	release_group    TEXT NOT NULL DEFAULT '',
+	encoder          TEXT NOT NULL DEFAULT '',
+	creation_time    TEXT NOT NULL DEFAULT '',
+	peak_video_bitrate  INTEGER NOT NULL DEFAULT 0,
+	bitrate_variability REAL NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS audio_tracks (
+	file_path TEXT NOT NULL REFERENCES media(file_path) ON DELETE CASCADE,
+	idx       INTEGER NOT NULL,
+	codec     TEXT NOT NULL,
+	bitrate   INTEGER NOT NULL,
+	language  TEXT NOT NULL,
+	channels  INTEGER NOT NULL,
+	title     TEXT NOT NULL,
+	role      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS audio_sidecars (
+	file_path TEXT NOT NULL REFERENCES media(file_path) ON DELETE CASCADE,
+	path      TEXT NOT NULL,
+	label     TEXT NOT NULL,
+	language  TEXT NOT NULL,
+	codec     TEXT NOT NULL,
+	bitrate   INTEGER NOT NULL,
+	channels  INTEGER NOT NULL,
+	role      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS subtitle_tracks (
+	file_path TEXT NOT NULL REFERENCES media(file_path) ON DELETE CASCADE,
+	idx       INTEGER NOT NULL,
+	codec     TEXT NOT NULL,
+	language  TEXT NOT NULL,
+	title     TEXT NOT NULL,
+	kind      TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS video_streams (
+	file_path   TEXT NOT NULL REFERENCES media(file_path) ON DELETE CASCADE,
+	idx         INTEGER NOT NULL,
+	codec       TEXT NOT NULL,
+	width       INTEGER NOT NULL,
+	height      INTEGER NOT NULL,
+	frame_rate  REAL NOT NULL,
+	disposition TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	kind        TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS failed_files (
+	file_path TEXT PRIMARY KEY,
+	stage     TEXT NOT NULL,
+	error     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_media_codec ON media(video_codec);
+CREATE INDEX IF NOT EXISTS idx_audio_tracks_file ON audio_tracks(file_path);
+CREATE INDEX IF NOT EXISTS idx_audio_sidecars_file ON audio_sidecars(file_path);
+CREATE INDEX IF NOT EXISTS idx_subtitle_tracks_file ON subtitle_tracks(file_path);
+CREATE INDEX IF NOT EXISTS idx_video_streams_file ON video_streams(file_path);
+`
+
+// MediaDB stores media analysis results in SQLite for filtering, aggregation, and ad-hoc SQL
+type MediaDB struct {
+	db *sql.DB
+}
+
+// OpenMediaDB opens or creates a media database at path. Use ":memory:" for an in-memory database.
+func OpenMediaDB(path string) (*MediaDB, error) {
+	db, err := sql.Open("sqlite3", sqliteDSN(path, "_foreign_keys=on&_busy_timeout=5000"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open media database: %w", err)
+	}
+	// A single connection keeps in-memory databases shared and serializes writers
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(mediaDBSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create media database schema: %w", err)
+	}
+	return &MediaDB{db: db}, nil
+}
+
+// Close closes the underlying database
+func (m *MediaDB) Close() error {
+	return m.db.Close()
+}
+
+// DB exposes the underlying database for ad-hoc queries
+func (m *MediaDB) DB() *sql.DB {
+	return m.db
+}
+
+// Upsert inserts or replaces the given media and their tracks in a single transaction
+func (m *MediaDB) Upsert(ctx context.Context, mediaInfos []*MediaInfo) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, info := range mediaInfos {
+		if err := deleteMediaRows(ctx, tx, info.FilePath); err != nil {
+			return err
+		}
+
+		var hdr HDRInfo
+		if info.HDR != nil {
+			hdr = *info.HDR
+		}
+		_, err := tx.ExecContext(ctx, `INSERT INTO media (
+			file_path, file_size, duration, video_codec, video_bitrate, video_width, video_height,
+			video_profile, video_level, pixel_format, frame_rate, bit_depth, scan_type, is_vbr, is_vfr, color_space, color_transfer,
+			has_dolby_vision, hdr_format, dv_profile, max_cll, max_fall, audio_tracks, subtitle_tracks, analyzed_at, archived_to,
+			release_group, encoder, creation_time, peak_video_bitrate, bitrate_variability
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			info.FilePath, info.FileSize, info.Duration, info.VideoCodec, info.VideoBitrate,
+			info.VideoWidth, info.VideoHeight, info.VideoProfile, info.VideoLevel, info.PixelFormat,
+			info.FrameRate, info.BitDepth, info.ScanType,
+			info.IsVBR, info.IsVFR, info.ColorSpace, info.ColorTransfer, info.HasDolbyVision, hdr.Format, hdr.DVProfile, hdr.MaxCLL, hdr.MaxFALL,
+			len(info.AudioTracks), len(info.SubtitleTracks), info.AnalyzedAt.Format(time.RFC3339), info.ArchivedTo,
+			info.Container.ReleaseGroupName(), info.Container.EncoderName(), info.Container.CreatedAt(),
+			info.BitrateStats.PeakVideoBitrate(), info.BitrateStats.Variability())
+		if err != nil {
+			return fmt.Errorf("failed to insert %s: %w", info.FilePath, err)
+		}
+
+		for _, track := range info.AudioTracks {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO audio_tracks (file_path, idx, codec, bitrate, language, channels, title, role) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				info.FilePath, track.Index, track.Codec, track.Bitrate, track.Language, track.Channels, track.Title, track.Role); err != nil {
+				return fmt.Errorf("failed to insert audio track for %s: %w", info.FilePath, err)
+			}
+		}
+		for _, sidecar := range info.AudioSidecars {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO audio_sidecars (file_path, path, label, language, codec, bitrate, channels, role) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				info.FilePath, sidecar.Path, sidecar.Label, sidecar.Language, sidecar.Codec, sidecar.Bitrate, sidecar.Channels, sidecar.Role); err != nil {
+				return fmt.Errorf("failed to insert audio sidecar for %s: %w", info.FilePath, err)
+			}
+		}
+		for _, track := range info.SubtitleTracks {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO subtitle_tracks (file_path, idx, codec, language, title, kind) VALUES (?, ?, ?, ?, ?, ?)`,
+				info.FilePath, track.Index, track.Codec, track.Language, track.Title, track.Kind); err != nil {
+				return fmt.Errorf("failed to insert subtitle track for %s: %w", info.FilePath, err)
+			}
+		}
+		for _, stream := range info.VideoStreams {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO video_streams (file_path, idx, codec, width, height, frame_rate, disposition, title, kind) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				info.FilePath, stream.Index, stream.Codec, stream.Width, stream.Height, stream.FrameRate,
+				strings.Join(stream.Disposition, ","), stream.Title, stream.Kind); err != nil {
+				return fmt.Errorf("failed to insert video stream for %s: %w", info.FilePath, err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceFailures replaces the recorded analysis failures with failures
+func (m *MediaDB) ReplaceFailures(ctx context.Context, failures []FileFailure) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM failed_files"); err != nil {
+		return fmt.Errorf("failed to clear failed files: %w", err)
+	}
+	for _, failure := range failures {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO failed_files (file_path, stage, error) VALUES (?, ?, ?)`,
+			failure.File, failure.Stage, failure.Error); err != nil {
+			return fmt.Errorf("failed to insert failure for %s: %w", failure.File, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete removes the given files and their tracks
+func (m *MediaDB) Delete(ctx context.Context, paths []string) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, path := range paths {
+		if err := deleteMediaRows(ctx, tx, path); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// deleteMediaRows removes a file's media row and its tracks
+func deleteMediaRows(ctx context.Context, tx *sql.Tx, path string) error {
+	for _, table := range []string{"audio_tracks", "audio_sidecars", "subtitle_tracks", "video_streams", "media"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE file_path = ?", path); err != nil {
+			return fmt.Errorf("failed to delete %s from %s: %w", path, table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package lib
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMediaDB_Query(t *testing.T) {
+	db, err := OpenMediaDB(":memory:")
+	if err != nil {
+		t.Fatalf("OpenMediaDB failed: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	mediaInfos := []*MediaInfo{
+		{FilePath: "/media/a.mkv", FileSize: 9 << 30, VideoCodec: "h264", VideoBitrate: 16000000, VideoHeight: 1080,
+			AudioTracks: []AudioTrack{{Index: 1, Codec: "ac3", Language: "eng"}}},
+		{FilePath: "/media/b.mkv", FileSize: 2 << 30, VideoCodec: "h264", VideoBitrate: 4000000, VideoHeight: 720,
+			AudioTracks: []AudioTrack{{Index: 1, Codec: "aac", Language: "jpn"}}},
+		{FilePath: "/media/c.mkv", FileSize: 5 << 30, VideoCodec: "hevc", VideoBitrate: 12000000, VideoHeight: 2160},
+	}
+	if err := db.Upsert(ctx, mediaInfos); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	// Re-inserting must replace rows rather than duplicate tracks
+	if err := db.Upsert(ctx, mediaInfos[:1]); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+
+	result, err := db.Query(ctx, MediaQuery{Codecs: []string{"H264"}, MinBitrate: 8000000, Aggregates: []string{"count", "sum_size"}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Total != 1 || len(result.Paths) != 1 || result.Paths[0] != "/media/a.mkv" {
+		t.Errorf("Unexpected filter result: %+v", result)
+	}
+	if result.Aggregates["count"] != 1 || result.Aggregates["sum_size"] != float64(9<<30) {
+		t.Errorf("Unexpected aggregates: %+v", result.Aggregates)
+	}
+
+	result, err = db.Query(ctx, MediaQuery{Sort: "-size", Limit: 2, Aggregates: []string{"count"}, GroupBy: "codec"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Total != 3 || len(result.Paths) != 2 || result.Paths[0] != "/media/a.mkv" || result.Paths[1] != "/media/c.mkv" {
+		t.Errorf("Unexpected sorted page: %+v", result)
+	}
+	if len(result.Groups) != 2 || result.Groups[0].Key != "h264" || result.Groups[0].Aggregates["count"] != 2 {
+		t.Errorf("Unexpected groups: %+v", result.Groups)
+	}
+
+	result, err = db.Query(ctx, MediaQuery{AudioLanguage: "eng"})
+	if err != nil || result.Total != 1 {
+		t.Errorf("Expected one file with English audio, got %+v, err %v", result, err)
+	}
+
+	if err := db.Delete(ctx, []string{"/media/a.mkv"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	result, err = db.Query(ctx, MediaQuery{AudioLanguage: "eng"})
+	if err != nil || result.Total != 0 {
+		t.Errorf("Expected deleted file to be gone, got %+v, err %v", result, err)
+	}
+
+	if _, err := db.Query(ctx, MediaQuery{Aggregates: []string{"sum_codec"}}); err == nil {
+		t.Error("Expected error aggregating a text field")
+	}
+}
+
+func TestCannedQueries(t *testing.T) {
+	db, err := OpenMediaDB(":memory:")
+	if err != nil {
+		t.Fatalf("OpenMediaDB failed: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	mediaInfos := []*MediaInfo{
+		{FilePath: "/media/big.mkv", FileSize: 9 << 30, VideoCodec: "h264", VideoBitrate: 16000000},
+		{FilePath: "/media/small.mkv", FileSize: 9 << 30, VideoCodec: "h264", VideoBitrate: 8000000, VideoStreams: []VideoStream{
+			{Index: 0, Codec: "h264", Width: 1920, Height: 1080, Kind: VideoStreamPrimary},
+			{Index: 1, Codec: "h264", Width: 1920, Height: 1080, Title: "Trailer", Kind: VideoStreamVideo},
+			{Index: 2, Codec: "mjpeg", Width: 600, Height: 900, Disposition: []string{"attached_pic"}, Kind: VideoStreamCoverArt},
+		}},
+	}
+	if err := db.Upsert(ctx, mediaInfos); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+
+	for _, query := range CannedQueries() {
+		rows, err := db.DB().QueryContext(ctx, query.SQL)
+		if err != nil {
+			t.Errorf("Canned query %s failed: %v", query.Name, err)
+			continue
+		}
+		rows.Close()
+	}
+
+	query, ok := FindCannedQuery("large-h264")
+	if !ok {
+		t.Fatal("large-h264 canned query not found")
+	}
+	var path string
+	var sizeGB, mbps float64
+	if err := db.DB().QueryRowContext(ctx, query.SQL).Scan(&path, &sizeGB, &mbps); err != nil || path != "/media/big.mkv" {
+		t.Errorf("large-h264 returned %q, %v", path, err)
+	}
+
+	query, ok = FindCannedQuery("extra-video-streams")
+	if !ok {
+		t.Fatal("extra-video-streams canned query not found")
+	}
+	var extra int
+	var streams string
+	if err := db.DB().QueryRowContext(ctx, query.SQL).Scan(&path, &extra, &streams); err != nil || path != "/media/small.mkv" || extra != 1 {
+		t.Errorf("extra-video-streams returned %q, %d, %v", path, extra, err)
+	}
+	if streams != `h264 1920x1080 "Trailer"` {
+		t.Errorf("extra-video-streams described streams as %q", streams)
+	}
+}
+
+func TestOpenMediaDB_SpecialCharacters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "library?#1", "media.db")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	db, err := OpenMediaDB(path)
+	if err != nil {
+		t.Fatalf("OpenMediaDB failed: %v", err)
+	}
+	db.Close()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the database at %q: %v", path, err)
+	}
+}
@@ -0,0 +1,279 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mediaQueryColumns maps the field names accepted in queries to media table columns
+var mediaQueryColumns = map[string]string{
+	"path":         "file_path",
+	"size":         "file_size",
+	"duration":     "duration",
+	"codec":        "video_codec",
+	"bitrate":      "video_bitrate",
+	"width":        "video_width",
+	"height":       "video_height",
+	"profile":      "video_profile",
+	"pixel_format": "pixel_format",
+	"frame_rate":   "frame_rate",
+	"bit_depth":    "bit_depth",
+	"scan_type":    "scan_type",
+	"audio_tracks": "audio_tracks",
+	"analyzed_at":  "analyzed_at",
+}
+
+// MediaQuery filters, sorts, pages, and aggregates rows of a MediaDB.
+// Zero-valued filters are ignored.
+type MediaQuery struct {
+	Codecs        []string
+	MinBitrate    int64
+	MaxBitrate    int64
+	MinSize       int64
+	MaxSize       int64
+	MinHeight     int
+	MaxHeight     int
+	MinDuration   float64
+	MaxDuration   float64
+	PathContains  string
+	AudioLanguage string   // Only files with an audio track in this language
+	Sort          string   // Field to sort by; prefix with "-" for descending
+	Limit         int      // Maximum number of paths returned (0 for no limit)
+	Offset        int      // Number of matching paths to skip
+	Aggregates    []string // "count" or fn_field, e.g. sum_size, avg_bitrate, max_duration
+	GroupBy       string   // Field to group aggregates by (optional)
+}
+
+// MediaQueryResult holds the matching paths and any requested aggregates
+type MediaQueryResult struct {
+	Total      int                `json:"total"`
+	Paths      []string           `json:"-"`
+	Aggregates map[string]float64 `json:"aggregates,omitempty"`
+	Groups     []AggregateGroup   `json:"groups,omitempty"`
+}
+
+// AggregateGroup holds aggregates for one value of the group-by field
+type AggregateGroup struct {
+	Key        string             `json:"key"`
+	Aggregates map[string]float64 `json:"aggregates"`
+}
+
+// Validate checks the field and aggregate names in the query, so that errors Query returns
+// for a valid query come from the database rather than the caller
+func (q MediaQuery) Validate() error {
+	if q.Sort != "" {
+		if field := strings.TrimPrefix(q.Sort, "-"); mediaQueryColumns[field] == "" {
+			return fmt.Errorf("unknown sort field %q", field)
+		}
+	}
+	for _, name := range q.Aggregates {
+		if _, err := aggregateExpression(name); err != nil {
+			return err
+		}
+	}
+	if q.GroupBy != "" && mediaQueryColumns[q.GroupBy] == "" {
+		return fmt.Errorf("unknown group_by field %q", q.GroupBy)
+	}
+	return nil
+}
+
+// Query runs a media query, returning the matching file paths and aggregates
+func (m *MediaDB) Query(ctx context.Context, q MediaQuery) (*MediaQueryResult, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	where, args := q.whereClause()
+
+	orderBy := "file_path"
+	if q.Sort != "" {
+		field := strings.TrimPrefix(q.Sort, "-")
+		column, ok := mediaQueryColumns[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", field)
+		}
+		orderBy = column
+		if strings.HasPrefix(q.Sort, "-") {
+			orderBy += " DESC"
+		}
+		orderBy += ", file_path"
+	}
+
+	result := &MediaQueryResult{}
+	if err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM media"+where, args...).Scan(&result.Total); err != nil {
+		return nil, fmt.Errorf("failed to count media: %w", err)
+	}
+
+	query := "SELECT file_path FROM media" + where + " ORDER BY " + orderBy
+	pageArgs := append([]interface{}{}, args...)
+	if q.Limit > 0 || q.Offset > 0 {
+		limit := q.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		query += " LIMIT ? OFFSET ?"
+		pageArgs = append(pageArgs, limit, q.Offset)
+	}
+
+	rows, err := m.db.QueryContext(ctx, query, pageArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query media: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		result.Paths = append(result.Paths, path)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(q.Aggregates) > 0 {
+		if err := m.aggregate(ctx, q, where, args, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// whereClause builds a parameterized WHERE clause from the query filters
+func (q MediaQuery) whereClause() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, value interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, value)
+	}
+
+	if len(q.Codecs) > 0 {
+		placeholders := make([]string, len(q.Codecs))
+		for i, codec := range q.Codecs {
+			placeholders[i] = "?"
+			args = append(args, strings.ToLower(codec))
+		}
+		conditions = append(conditions, "LOWER(video_codec) IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if q.MinBitrate > 0 {
+		add("video_bitrate >= ?", q.MinBitrate)
+	}
+	if q.MaxBitrate > 0 {
+		add("video_bitrate <= ?", q.MaxBitrate)
+	}
+	if q.MinSize > 0 {
+		add("file_size >= ?", q.MinSize)
+	}
+	if q.MaxSize > 0 {
+		add("file_size <= ?", q.MaxSize)
+	}
+	if q.MinHeight > 0 {
+		add("video_height >= ?", q.MinHeight)
+	}
+	if q.MaxHeight > 0 {
+		add("video_height <= ?", q.MaxHeight)
+	}
+	if q.MinDuration > 0 {
+		add("duration >= ?", q.MinDuration)
+	}
+	if q.MaxDuration > 0 {
+		add("duration <= ?", q.MaxDuration)
+	}
+	if q.PathContains != "" {
+		add("INSTR(LOWER(file_path), LOWER(?)) > 0", q.PathContains)
+	}
+	if q.AudioLanguage != "" {
+		add("file_path IN (SELECT file_path FROM audio_tracks WHERE LOWER(language) = LOWER(?))", q.AudioLanguage)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// aggregateExpression converts an aggregate name such as sum_size into a SQL expression
+func aggregateExpression(name string) (string, error) {
+	if name == "count" {
+		return "COUNT(*)", nil
+	}
+
+	fn, field, ok := strings.Cut(name, "_")
+	if !ok {
+		return "", fmt.Errorf("invalid aggregate %q", name)
+	}
+	column, ok := mediaQueryColumns[field]
+	if !ok || column == "file_path" || column == "video_codec" || column == "video_profile" || column == "pixel_format" || column == "scan_type" || column == "analyzed_at" {
+		return "", fmt.Errorf("cannot aggregate field %q", field)
+	}
+
+	switch fn {
+	case "sum", "avg", "min", "max":
+		return strings.ToUpper(fn) + "(" + column + ")", nil
+	}
+	return "", fmt.Errorf("unknown aggregate function %q", fn)
+}
+
+// aggregate computes the requested aggregates over the matching rows, optionally grouped
+func (m *MediaDB) aggregate(ctx context.Context, q MediaQuery, where string, args []interface{}, result *MediaQueryResult) error {
+	names := append([]string{}, q.Aggregates...)
+	sort.Strings(names)
+
+	expressions := make([]string, len(names))
+	for i, name := range names {
+		expression, err := aggregateExpression(name)
+		if err != nil {
+			return err
+		}
+		expressions[i] = "COALESCE(" + expression + ", 0)"
+	}
+
+	groupColumn := ""
+	if q.GroupBy != "" {
+		column, ok := mediaQueryColumns[q.GroupBy]
+		if !ok {
+			return fmt.Errorf("unknown group_by field %q", q.GroupBy)
+		}
+		groupColumn = column
+	}
+
+	query := "SELECT " + strings.Join(expressions, ", ") + " FROM media" + where
+	if groupColumn != "" {
+		query = "SELECT CAST(" + groupColumn + " AS TEXT), " + strings.Join(expressions, ", ") +
+			" FROM media" + where + " GROUP BY " + groupColumn + " ORDER BY " + groupColumn
+	}
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate media: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values := make([]float64, len(names))
+		var key string
+		dest := make([]interface{}, 0, len(names)+1)
+		if groupColumn != "" {
+			dest = append(dest, &key)
+		}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		aggregates := make(map[string]float64, len(names))
+		for i, name := range names {
+			aggregates[name] = values[i]
+		}
+		if groupColumn != "" {
+			result.Groups = append(result.Groups, AggregateGroup{Key: key, Aggregates: aggregates})
+		} else {
+			result.Aggregates = aggregates
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,157 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MirrorEntry maps a master file to the streaming copy derived from it
+type MirrorEntry struct {
+	Master        string    `json:"master"`
+	Streaming     string    `json:"streaming"`
+	MasterSize    int64     `json:"master_size"`
+	MasterModTime time.Time `json:"master_mod_time"`
+	SyncedAt      time.Time `json:"synced_at"`
+}
+
+// InSync reports whether the streaming copy exists and the master is unchanged since it was derived
+func (e MirrorEntry) InSync() bool {
+	master, err := os.Stat(e.Master)
+	if err != nil || master.Size() != e.MasterSize || !master.ModTime().Equal(e.MasterModTime) {
+		return false
+	}
+	_, err = os.Stat(e.Streaming)
+	return err == nil
+}
+
+// MirrorStore persists the master-to-streaming mapping as a JSON file
+type MirrorStore struct {
+	Path    string
+	mutex   sync.Mutex
+	entries map[string]MirrorEntry
+}
+
+func NewMirrorStore(path string) *MirrorStore {
+	return &MirrorStore{Path: path}
+}
+
+// DefaultMirrorPath returns the location of the mirror database in the state directory
+func DefaultMirrorPath() string {
+	return filepath.Join(DefaultStateDir(), "mirror.json")
+}
+
+// load reads the mapping from disk on first use. Must be called with mutex held.
+func (ms *MirrorStore) load() error {
+	if ms.entries != nil {
+		return nil
+	}
+
+	ms.entries = make(map[string]MirrorEntry)
+	data, err := os.ReadFile(ms.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read mirror database: %w", err)
+	}
+
+	var entries []MirrorEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse mirror database: %w", err)
+	}
+	for _, entry := range entries {
+		ms.entries[entry.Master] = entry
+	}
+	return nil
+}
+
+// save writes the mapping to disk atomically. Must be called with mutex held.
+func (ms *MirrorStore) save() error {
+	entries := make([]MirrorEntry, 0, len(ms.entries))
+	for _, entry := range ms.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Master < entries[j].Master })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal mirror database: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(ms.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create mirror database directory: %w", err)
+	}
+
+	tmpPath := ms.Path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write mirror database: %w", err)
+	}
+	return os.Rename(tmpPath, ms.Path)
+}
+
+// Get returns the entry for a master file, if one exists
+func (ms *MirrorStore) Get(master string) (MirrorEntry, bool, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := ms.load(); err != nil {
+		return MirrorEntry{}, false, err
+	}
+	entry, ok := ms.entries[master]
+	return entry, ok, nil
+}
+
+// Entries returns all entries sorted by master path
+func (ms *MirrorStore) Entries() ([]MirrorEntry, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := ms.load(); err != nil {
+		return nil, err
+	}
+	entries := make([]MirrorEntry, 0, len(ms.entries))
+	for _, entry := range ms.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Master < entries[j].Master })
+	return entries, nil
+}
+
+// Record stores the mapping for a master using its current size and modification time
+func (ms *MirrorStore) Record(master, streaming string) error {
+	info, err := os.Stat(master)
+	if err != nil {
+		return fmt.Errorf("failed to stat master: %w", err)
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := ms.load(); err != nil {
+		return err
+	}
+	ms.entries[master] = MirrorEntry{
+		Master:        master,
+		Streaming:     streaming,
+		MasterSize:    info.Size(),
+		MasterModTime: info.ModTime(),
+		SyncedAt:      time.Now(),
+	}
+	return ms.save()
+}
+
+// Remove deletes the entry for a master file
+func (ms *MirrorStore) Remove(master string) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if err := ms.load(); err != nil {
+		return err
+	}
+	delete(ms.entries, master)
+	return ms.save()
+}
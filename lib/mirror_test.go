@@ -0,0 +1,62 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMirrorStore(t *testing.T) {
+	dir := t.TempDir()
+	master := filepath.Join(dir, "masters", "movie.mkv")
+	streaming := filepath.Join(dir, "streaming", "movie.mkv")
+	for _, path := range []string{master, streaming} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dbPath := filepath.Join(dir, "state", "mirror.json")
+	if err := NewMirrorStore(dbPath).Record(master, streaming); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	// A fresh store reads the mapping back from disk
+	store := NewMirrorStore(dbPath)
+	entry, ok, err := store.Get(master)
+	if err != nil || !ok || entry.Streaming != streaming {
+		t.Fatalf("Get() = %+v, %v, %v", entry, ok, err)
+	}
+	if !entry.InSync() {
+		t.Error("Expected a freshly recorded entry to be in sync")
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(master, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if entry.InSync() {
+		t.Error("Expected an entry whose master changed to be out of sync")
+	}
+	if err := os.WriteFile(master, []byte("video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Record(master, streaming); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	os.Remove(streaming)
+	if entry, _, _ := store.Get(master); entry.InSync() {
+		t.Error("Expected an entry without its streaming copy to be out of sync")
+	}
+
+	if err := store.Remove(master); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if entries, err := NewMirrorStore(dbPath).Entries(); err != nil || len(entries) != 0 {
+		t.Errorf("Entries() after Remove() = %+v, %v", entries, err)
+	}
+}
@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// Chapter is a chapter of a video file
+type Chapter struct {
+	Start float64 // Start time in seconds
+	End   float64 // End time in seconds
+	Title string  // Chapter name ("" if untitled)
+}
+
+// parseChapters converts ffprobe's chapters, skipping any with unparseable times
+func parseChapters(probeChapters []ProbeChapter) []Chapter {
+	var chapters []Chapter
+	for _, probeChapter := range probeChapters {
+		start, err := strconv.ParseFloat(probeChapter.StartTime, 64)
+		if err != nil {
+			continue
+		}
+		end, err := strconv.ParseFloat(probeChapter.EndTime, 64)
+		if err != nil {
+			continue
+		}
+		chapters = append(chapters, Chapter{Start: start, End: end, Title: probeChapter.Tags["title"]})
+	}
+	return chapters
+}
+
+// Thresholds for recognizing several titles ripped into one file
+const (
+	multiTitleMinDuration = 4 * 60 * 60 // Longer than any single title is likely to run
+	episodeChapterMinimum = 15 * 60     // Chapters at least this long could each be a whole episode
+	episodeChapterCount   = 3           // Episode-length chapters needed to look like a playlist
+	episodeLengthRatio    = 0.75        // Episodes of a series run close to the same length; a film's chapters vary more
+)
+
+// chapterNumberRegex matches generated chapter names such as "Chapter 01", "Chapitre 3", or "12"
+var chapterNumberRegex = regexp.MustCompile(`^(?:\pL+\s*)?0*(\d+)$`)
+
+// TitleRange is a run of chapters that looks like one title of a multi-title file
+type TitleRange struct {
+	FirstChapter int // Numbered from 1, as HandBrakeCLI --chapters counts them
+	LastChapter  int
+	Start        float64 // Start time in seconds
+	End          float64 // End time in seconds
+}
+
+// MultiTitleDiagnosis explains why a file looks like several disc titles or a playlist ripped
+// into one, and where it could be split
+type MultiTitleDiagnosis struct {
+	Reasons []string
+	Titles  []TitleRange // The titles found by chapter, or nil if the chapters do not show where they are
+}
+
+// DetectMultiTitle looks for the signs of several titles concatenated into one file: chapter
+// numbering that starts over, chapters each as long as an episode, or a running time longer
+// than a single title. Returns nil if the file looks like one title.
+func DetectMultiTitle(info *VideoInfo) *MultiTitleDiagnosis {
+	diagnosis := &MultiTitleDiagnosis{}
+	chapters := info.Chapters
+
+	// Chapter names restarting from 1 mark where each concatenated title begins
+	starts := []int{0}
+	previous := 0
+	for i, chapter := range chapters {
+		matches := chapterNumberRegex.FindStringSubmatch(chapter.Title)
+		if matches == nil {
+			starts = []int{0}
+			break
+		}
+		number, _ := strconv.Atoi(matches[1])
+		if i > 0 && number <= previous {
+			starts = append(starts, i)
+		}
+		previous = number
+	}
+	if len(starts) > 1 {
+		for _, start := range starts[1:] {
+			diagnosis.Reasons = append(diagnosis.Reasons, fmt.Sprintf("chapter numbering starts over at chapter %d (%s)", start+1, FormatDuration(chapters[start].Start)))
+		}
+		diagnosis.Titles = titleRanges(chapters, starts)
+	}
+
+	if looksLikeEpisodes(chapters) {
+		diagnosis.Reasons = append(diagnosis.Reasons, fmt.Sprintf("all %d chapters are at least %s long and close to the same length, like a playlist of episodes", len(chapters), FormatDuration(episodeChapterMinimum)))
+		if diagnosis.Titles == nil {
+			starts = make([]int, len(chapters))
+			for i := range starts {
+				starts[i] = i
+			}
+			diagnosis.Titles = titleRanges(chapters, starts)
+		}
+	}
+
+	if info.Duration >= multiTitleMinDuration {
+		diagnosis.Reasons = append(diagnosis.Reasons, fmt.Sprintf("runs %s, longer than a single title usually does", FormatDuration(info.Duration)))
+	}
+
+	if len(diagnosis.Reasons) == 0 {
+		return nil
+	}
+	return diagnosis
+}
+
+// looksLikeEpisodes reports whether every chapter is long enough to be an episode and the
+// chapters run close to the same length, as a season's episodes do. A film whose chapters
+// happen to be long has some much longer than others.
+func looksLikeEpisodes(chapters []Chapter) bool {
+	if len(chapters) < episodeChapterCount {
+		return false
+	}
+	shortest, longest := chapters[0].End-chapters[0].Start, 0.0
+	for _, chapter := range chapters {
+		length := chapter.End - chapter.Start
+		shortest, longest = min(shortest, length), max(longest, length)
+	}
+	return shortest >= episodeChapterMinimum && shortest >= longest*episodeLengthRatio
+}
+
+// titleRanges groups chapters into titles, each beginning at one of starts
+func titleRanges(chapters []Chapter, starts []int) []TitleRange {
+	titles := make([]TitleRange, len(starts))
+	for i, start := range starts {
+		end := len(chapters)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		titles[i] = TitleRange{FirstChapter: start + 1, LastChapter: end, Start: chapters[start].Start, End: chapters[end-1].End}
+	}
+	return titles
+}
@@ -0,0 +1,100 @@
+package lib
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// numberedChapters builds chapters of the given lengths in minutes, titled "Chapter N" with the
+// given numbers
+func numberedChapters(numbers []int, minutes []float64) []Chapter {
+	var chapters []Chapter
+	start := 0.0
+	for i, number := range numbers {
+		end := start + minutes[i]*60
+		chapters = append(chapters, Chapter{Start: start, End: end, Title: fmt.Sprintf("Chapter %02d", number)})
+		start = end
+	}
+	return chapters
+}
+
+func TestDetectMultiTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    VideoInfo
+		reasons int
+		titles  []TitleRange
+	}{
+		{
+			name: "single film",
+			info: VideoInfo{Duration: 7200, Chapters: numberedChapters([]int{1, 2, 3, 4}, []float64{25, 12, 60, 23})},
+		},
+		{
+			name: "untitled chapters",
+			info: VideoInfo{Duration: 600, Chapters: []Chapter{{Start: 0, End: 300}, {Start: 300, End: 600}}},
+		},
+		{
+			name:    "numbering starts over",
+			info:    VideoInfo{Duration: 3600, Chapters: numberedChapters([]int{1, 2, 3, 1, 2}, []float64{10, 10, 10, 15, 15})},
+			reasons: 1,
+			titles: []TitleRange{
+				{FirstChapter: 1, LastChapter: 3, Start: 0, End: 1800},
+				{FirstChapter: 4, LastChapter: 5, Start: 1800, End: 3600},
+			},
+		},
+		{
+			name:    "episode playlist",
+			info:    VideoInfo{Duration: 5340, Chapters: []Chapter{{0, 1380, "Pilot"}, {1380, 2640, "Two"}, {2640, 3960, "Three"}, {3960, 5340, "Four"}}},
+			reasons: 1,
+			titles: []TitleRange{
+				{FirstChapter: 1, LastChapter: 1, Start: 0, End: 1380},
+				{FirstChapter: 2, LastChapter: 2, Start: 1380, End: 2640},
+				{FirstChapter: 3, LastChapter: 3, Start: 2640, End: 3960},
+				{FirstChapter: 4, LastChapter: 4, Start: 3960, End: 5340},
+			},
+		},
+		{
+			name: "film with long chapters",
+			info: VideoInfo{Duration: 6960, Chapters: numberedChapters([]int{1, 2, 3, 4, 5}, []float64{18, 31, 22, 26, 19})},
+		},
+		{
+			name:    "long without chapters",
+			info:    VideoInfo{Duration: 5 * 3600},
+			reasons: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnosis := DetectMultiTitle(&tt.info)
+			if tt.reasons == 0 {
+				if diagnosis != nil {
+					t.Fatalf("Expected no diagnosis, got %+v", diagnosis)
+				}
+				return
+			}
+			if diagnosis == nil {
+				t.Fatal("Expected a diagnosis")
+			}
+			if len(diagnosis.Reasons) != tt.reasons {
+				t.Errorf("Expected %d reasons, got %q", tt.reasons, diagnosis.Reasons)
+			}
+			if !reflect.DeepEqual(diagnosis.Titles, tt.titles) {
+				t.Errorf("Titles = %+v, want %+v", diagnosis.Titles, tt.titles)
+			}
+		})
+	}
+}
+
+func TestParseChapters(t *testing.T) {
+	chapters := parseChapters([]ProbeChapter{
+		{StartTime: "0.000000", EndTime: "612.500000", Tags: map[string]string{"title": "Chapter 01"}},
+		{StartTime: "bad", EndTime: "700.0"},
+		{StartTime: "612.500000", EndTime: "1300.000000"},
+	})
+	want := []Chapter{{Start: 0, End: 612.5, Title: "Chapter 01"}, {Start: 612.5, End: 1300}}
+	if !reflect.DeepEqual(chapters, want) {
+		t.Errorf("parseChapters() = %+v, want %+v", chapters, want)
+	}
+}
@@ -0,0 +1,59 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const (
+	// DefaultProbeTimeout bounds each ffprobe attempt. Reading headers normally takes well under a
+	// second, so this only trips on stalled storage such as an unresponsive network mount.
+	DefaultProbeTimeout = time.Minute
+	// DefaultProbeRetries is how many times a failed or timed out ffprobe is repeated
+	DefaultProbeRetries = 2
+)
+
+// probeBackoff is the wait before the first retry, doubled before each one after it
+var probeBackoff = 2 * time.Second
+
+// retryProbe runs probe with the analyzer's per-attempt timeout, retrying failures with
+// exponential backoff. Cancelling ctx stops retries immediately.
+func (ma *MediaAnalyzer) retryProbe(ctx context.Context, filePath string, probe func(context.Context) error) error {
+	backoff := probeBackoff
+	for attempt := 1; ; attempt++ {
+		err := ma.probeOnce(ctx, probe)
+		if err == nil || ctx.Err() != nil || attempt > ma.ProbeRetries {
+			return err
+		}
+		slog.Warn("ffprobe failed, retrying", "file", filePath, "attempt", attempt, "retry_in", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// probeOnce runs one probe attempt, reporting a timeout rather than the kill signal it causes
+func (ma *MediaAnalyzer) probeOnce(ctx context.Context, probe func(context.Context) error) error {
+	return probeWithin(ctx, ma.ProbeTimeout, probe)
+}
+
+// probeWithin runs probe with a time limit (0 for none), reporting a timeout rather than the
+// kill signal it causes
+func probeWithin(ctx context.Context, timeout time.Duration, probe func(context.Context) error) error {
+	if timeout <= 0 {
+		return probe(ctx)
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := probe(probeCtx)
+	if err != nil && ctx.Err() == nil && errors.Is(probeCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
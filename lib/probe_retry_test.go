@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryProbe(t *testing.T) {
+	defer func(saved time.Duration) { probeBackoff = saved }(probeBackoff)
+	probeBackoff = time.Millisecond
+
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int
+		hang         bool
+		wantAttempts int
+		wantErr      string
+	}{
+		{name: "succeeds first time", retries: 2, wantAttempts: 1},
+		{name: "recovers after failures", retries: 2, failures: 2, wantAttempts: 3},
+		{name: "gives up after retries", retries: 2, failures: 5, wantAttempts: 3, wantErr: "input/output error"},
+		{name: "no retries", retries: 0, failures: 1, wantAttempts: 1, wantErr: "input/output error"},
+		{name: "times out hung probe", retries: 1, hang: true, wantAttempts: 2, wantErr: "timed out"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ma := &MediaAnalyzer{ProbeTimeout: 20 * time.Millisecond, ProbeRetries: tt.retries}
+			attempts := 0
+			err := ma.retryProbe(context.Background(), "movie.mkv", func(ctx context.Context) error {
+				attempts++
+				if tt.hang {
+					<-ctx.Done()
+					return ctx.Err()
+				}
+				if attempts <= tt.failures {
+					return errors.New("input/output error")
+				}
+				return nil
+			})
+
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryProbeStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ma := &MediaAnalyzer{ProbeRetries: 3}
+	attempts := 0
+	err := ma.retryProbe(ctx, "movie.mkv", func(ctx context.Context) error {
+		attempts++
+		return ctx.Err()
+	})
+	if attempts != 1 || !errors.Is(err, context.Canceled) {
+		t.Errorf("attempts = %d, err = %v; want 1 attempt and context.Canceled", attempts, err)
+	}
+}
@@ -2,18 +2,27 @@ package lib
 
 import (
 	"context"
-	"fmt"
 	"log/slog"
-	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/schollz/progressbar/v3"
 )
 
 type MediaProcessor struct {
 	analyzer    *MediaAnalyzer
-	cache       *CacheManager
+	cache       CacheManager
 	parallelism int
+	History     *HistoryStore // Ledger for fresh analyses (nil disables)
+	Failures    []FileFailure // Files the last ProcessFiles could not analyze, sorted by path
+
+	cacheHits   atomic.Int64 // Files the last ProcessFiles took from the cache
+	cacheMisses atomic.Int64 // Files the last ProcessFiles looked up in the cache and analyzed
+}
+
+// CacheCounts returns how many files the last ProcessFiles found and did not find in the cache
+func (mp *MediaProcessor) CacheCounts() (hits, misses int) {
+	return int(mp.cacheHits.Load()), int(mp.cacheMisses.Load())
 }
 
 func NewMediaProcessor(parallelism int) *MediaProcessor {
@@ -23,7 +32,7 @@ func NewMediaProcessor(parallelism int) *MediaProcessor {
 	}
 }
 
-func NewMediaProcessorWithCache(parallelism int, cache *CacheManager) *MediaProcessor {
+func NewMediaProcessorWithCache(parallelism int, cache CacheManager) *MediaProcessor {
 	return &MediaProcessor{
 		analyzer:    NewMediaAnalyzer(),
 		cache:       cache,
@@ -33,6 +42,8 @@ func NewMediaProcessorWithCache(parallelism int, cache *CacheManager) *MediaProc
 
 // ProcessFiles analyzes multiple video files in parallel
 func (mp *MediaProcessor) ProcessFiles(ctx context.Context, filePaths []string) ([]*MediaInfo, error) {
+	mp.cacheHits.Store(0)
+	mp.cacheMisses.Store(0)
 	if len(filePaths) == 0 {
 		return nil, nil
 	}
@@ -57,7 +68,7 @@ func (mp *MediaProcessor) ProcessFiles(ctx context.Context, filePaths []string)
 
 	jobs := make(chan string, len(filePaths))
 	results := make(chan *MediaInfo, len(filePaths))
-	errors := make(chan error, len(filePaths))
+	errors := make(chan *FileFailure, len(filePaths))
 
 	var wg sync.WaitGroup
 	for i := 0; i < mp.parallelism; i++ {
@@ -83,17 +94,17 @@ func (mp *MediaProcessor) ProcessFiles(ctx context.Context, filePaths []string)
 	}()
 
 	var mediaInfos []*MediaInfo
-	var errs []error
+	var failures []FileFailure
 
 	for i := 0; i < len(filePaths); i++ {
 		result := <-results
-		err := <-errors
+		failure := <-errors
 
 		if result != nil {
 			mediaInfos = append(mediaInfos, result)
 		}
-		if err != nil {
-			errs = append(errs, err)
+		if failure != nil {
+			failures = append(failures, *failure)
 		}
 
 		bar.Add(1)
@@ -103,16 +114,18 @@ func (mp *MediaProcessor) ProcessFiles(ctx context.Context, filePaths []string)
 
 	slog.Info("Parallel media analysis completed",
 		"processedFiles", len(mediaInfos),
-		"errors", len(errs))
+		"errors", len(failures))
 
-	for _, err := range errs {
-		slog.Warn("File analysis failed", "error", err)
+	sortFailures(failures)
+	for _, failure := range failures {
+		slog.Warn("File analysis failed", "file", failure.File, "stage", failure.Stage, "error", failure.Error)
 	}
+	mp.Failures = failures
 
 	return mediaInfos, nil
 }
 
-func (mp *MediaProcessor) worker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan string, results chan<- *MediaInfo, errors chan<- error) {
+func (mp *MediaProcessor) worker(ctx context.Context, wg *sync.WaitGroup, jobs <-chan string, results chan<- *MediaInfo, errors chan<- *FileFailure) {
 	defer wg.Done()
 
 	for {
@@ -124,11 +137,12 @@ func (mp *MediaProcessor) worker(ctx context.Context, wg *sync.WaitGroup, jobs <
 
 			var mediaInfo *MediaInfo
 			var err error
+			fresh := true
 
 			if mp.cache != nil {
-				fileInfo, statErr := os.Stat(filePath)
+				fileInfo, statErr := mp.analyzer.stat(filePath)
 				if statErr != nil {
-					errors <- fmt.Errorf("failed to stat file %s: %w", filePath, statErr)
+					errors <- &FileFailure{File: filePath, Stage: FailureStageStat, Error: statErr.Error()}
 					results <- nil
 					continue
 				}
@@ -138,10 +152,18 @@ func (mp *MediaProcessor) worker(ctx context.Context, wg *sync.WaitGroup, jobs <
 					slog.Warn("Cache check failed, will analyze fresh", "file", filePath, "error", cacheErr)
 				}
 
+				if hasCache && cachedInfo != nil && mp.analyzer.missingFromCache(filePath, cachedInfo) {
+					slog.Debug("Cached analysis lacks requested bitrate measurements, will re-analyze", "file", filePath)
+					hasCache = false
+				}
+
 				if hasCache && cachedInfo != nil {
 					mediaInfo = cachedInfo
+					fresh = false
+					mp.cacheHits.Add(1)
 					slog.Debug("Using cached analysis", "file", filePath)
 				} else {
+					mp.cacheMisses.Add(1)
 					mediaInfo, err = mp.analyzer.AnalyzeFile(ctx, filePath)
 					if err == nil && mediaInfo != nil {
 						if saveErr := mp.cache.SaveCache(filePath, fileInfo, mediaInfo); saveErr != nil {
@@ -153,8 +175,21 @@ func (mp *MediaProcessor) worker(ctx context.Context, wg *sync.WaitGroup, jobs <
 				mediaInfo, err = mp.analyzer.AnalyzeFile(ctx, filePath)
 			}
 
+			if err == nil && mediaInfo != nil && fresh {
+				mp.History.Record(HistoryRecord{
+					FilePath:      filePath,
+					Action:        HistoryActionAnalyzed,
+					Width:         mediaInfo.VideoWidth,
+					Height:        mediaInfo.VideoHeight,
+					MediaDuration: mediaInfo.Duration,
+					OriginalSize:  mediaInfo.FileSize,
+					Params:        map[string]string{"video_codec": mediaInfo.VideoCodec},
+				})
+			}
+
 			if err != nil {
-				errors <- fmt.Errorf("failed to analyze %s: %w", filePath, err)
+				failure := analysisFailure(filePath, err)
+				errors <- &failure
 				results <- nil
 			} else {
 				results <- mediaInfo
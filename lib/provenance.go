@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ContainerMetadata is the container-level metadata recording how, when, and by whom a file
+// was produced, for auditing where a library's files came from
+type ContainerMetadata struct {
+	Format           string            `json:"format,omitempty"`            // ffprobe format name, e.g. "matroska,webm"
+	Title            string            `json:"title,omitempty"`             // Title tag set by the muxer or release
+	Encoder          string            `json:"encoder,omitempty"`           // Muxing application, e.g. "HandBrake 1.7.2 2023122700"
+	VideoEncoder     string            `json:"video_encoder,omitempty"`     // Library that encoded the primary video stream, e.g. "Lavc60.3.100 libx265"
+	EncodingSettings string            `json:"encoding_settings,omitempty"` // Encoder options, when the release recorded them
+	CreationTime     *time.Time        `json:"creation_time,omitempty"`     // When the file was muxed
+	ReleaseGroup     string            `json:"release_group,omitempty"`     // Group named in the filename, e.g. "SPARKS"
+	Tags             map[string]string `json:"tags,omitempty"`              // Every format tag, with lowercased keys
+}
+
+var (
+	// sceneGroupPattern matches the group suffix of scene-style names such as
+	// Movie.2019.1080p.BluRay.x264-SPARKS
+	sceneGroupPattern = regexp.MustCompile(`-([A-Za-z0-9]{2,})$`)
+	// sceneMarkerPattern matches the source and codec tokens that mark a scene-style name, so
+	// ordinary hyphenated names such as home-video are not mistaken for releases
+	sceneMarkerPattern = regexp.MustCompile(`(?i)[. _](480p|576p|720p|1080[pi]|2160p|4k|x264|x265|h\.?26[45]|hevc|av1|bluray|blu-ray|bdrip|brrip|remux|web-?dl|webrip|hdtv|dvdrip)[. _-]`)
+	// bracketGroupPattern matches the leading group tag of fansub-style names such as
+	// [Group] Show - 01 [1080p]
+	bracketGroupPattern = regexp.MustCompile(`^\[([^\]]+)\]`)
+)
+
+// ParseContainerMetadata extracts container metadata from ffprobe format tags and the tags of
+// the primary video stream. Returns nil if there is nothing to report.
+func ParseContainerMetadata(filePath string, format Format, primary *Stream) *ContainerMetadata {
+	tags := make(map[string]string, len(format.Tags))
+	for key, value := range format.Tags {
+		tags[strings.ToLower(key)] = value
+	}
+	var streamTags map[string]string
+	if primary != nil {
+		streamTags = make(map[string]string, len(primary.Tags))
+		for key, value := range primary.Tags {
+			streamTags[strings.ToLower(key)] = value
+		}
+	}
+
+	meta := &ContainerMetadata{
+		Format:           format.FormatName,
+		Title:            tags["title"],
+		Encoder:          firstTag(tags, "encoder", "writing_application", "_statistics_writing_app"),
+		VideoEncoder:     firstTag(streamTags, "encoder"),
+		EncodingSettings: firstTag(tags, "encoder_settings", "encoding_settings", "encoder-settings"),
+		ReleaseGroup:     ParseReleaseGroup(filePath),
+	}
+	if meta.EncodingSettings == "" {
+		meta.EncodingSettings = firstTag(streamTags, "encoder_settings", "encoding_settings", "encoder-settings")
+	}
+	if created, err := time.Parse(time.RFC3339Nano, firstTag(tags, "creation_time", "date")); err == nil {
+		meta.CreationTime = &created
+	}
+	if len(tags) > 0 {
+		meta.Tags = tags
+	}
+
+	if meta.Format == "" && meta.Tags == nil && meta.ReleaseGroup == "" && meta.VideoEncoder == "" && meta.EncodingSettings == "" {
+		return nil
+	}
+	return meta
+}
+
+// ReleaseGroupName returns the release group, or "" for nil metadata
+func (c *ContainerMetadata) ReleaseGroupName() string {
+	if c == nil {
+		return ""
+	}
+	return c.ReleaseGroup
+}
+
+// EncoderName returns the muxing application, or "" for nil metadata
+func (c *ContainerMetadata) EncoderName() string {
+	if c == nil {
+		return ""
+	}
+	return c.Encoder
+}
+
+// CreatedAt formats the creation time as RFC 3339, or "" if it is unknown
+func (c *ContainerMetadata) CreatedAt() string {
+	if c == nil || c.CreationTime == nil {
+		return ""
+	}
+	return c.CreationTime.UTC().Format(time.RFC3339)
+}
+
+// firstTag returns the first non-empty value among keys
+func firstTag(tags map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value := strings.TrimSpace(tags[key]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParseReleaseGroup returns the release group named in a scene-style or fansub-style filename,
+// or "" if the name does not follow either convention
+func ParseReleaseGroup(filePath string) string {
+	stem := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	if match := bracketGroupPattern.FindStringSubmatch(stem); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	if match := sceneGroupPattern.FindStringSubmatch(stem); match != nil && sceneMarkerPattern.MatchString(stem) {
+		return match[1]
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReleaseGroup(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/movies/Movie.Name.2019.1080p.BluRay.x264-SPARKS.mkv", "SPARKS"},
+		{"/tv/Show.S01E02.WEB-DL.H.264-NTb.mkv", "NTb"},
+		{"/anime/[SubsPlease] Show - 01 (1080p).mkv", "SubsPlease"},
+		{"/home/family-reunion.mp4", ""},
+		{"/movies/Movie (2019).mkv", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := ParseReleaseGroup(tt.path); got != tt.expected {
+				t.Errorf("ParseReleaseGroup() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseContainerMetadata(t *testing.T) {
+	format := Format{
+		FormatName: "matroska,webm",
+		Tags: map[string]string{
+			"TITLE":            "Movie Name",
+			"ENCODER":          "libebml v1.4.2 + libmatroska v1.6.4",
+			"creation_time":    "2021-03-04T05:06:07.000000Z",
+			"ENCODER_SETTINGS": "crf=18 preset=slow",
+		},
+	}
+	primary := &Stream{Tags: map[string]string{"ENCODER": "Lavc58.134.100 libx265"}}
+
+	meta := ParseContainerMetadata("/movies/Movie.Name.2021.2160p.x265-GROUP.mkv", format, primary)
+	if meta == nil {
+		t.Fatal("Expected container metadata")
+	}
+	if meta.Title != "Movie Name" || meta.Encoder != "libebml v1.4.2 + libmatroska v1.6.4" || meta.VideoEncoder != "Lavc58.134.100 libx265" {
+		t.Errorf("Unexpected title or encoders: %+v", meta)
+	}
+	if meta.EncodingSettings != "crf=18 preset=slow" || meta.ReleaseGroup != "GROUP" {
+		t.Errorf("Unexpected settings or group: %+v", meta)
+	}
+	if meta.CreationTime == nil || !meta.CreationTime.Equal(time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)) {
+		t.Errorf("Unexpected creation time: %v", meta.CreationTime)
+	}
+	if meta.Tags["encoder"] == "" {
+		t.Errorf("Expected lowercased tag keys, got %v", meta.Tags)
+	}
+
+	if meta := ParseContainerMetadata("/home/clip.mp4", Format{}, nil); meta != nil {
+		t.Errorf("Expected nil metadata without tags, got %+v", meta)
+	}
+}
@@ -0,0 +1,84 @@
+package lib
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// RemoteLibrary is a library analyzed where it is stored, without mounting it. Files are
+// identified by URLs, such as ssh://seedbox/media/movie.mkv, which take the place of paths in
+// the cache, history, and reports.
+type RemoteLibrary interface {
+	// ScanVideoFiles lists the library's video files, recording their stats for Stat
+	ScanVideoFiles(ctx context.Context) ([]string, error)
+	// Stat returns the size and modification time of a file found by ScanVideoFiles
+	Stat(file string) (os.FileInfo, error)
+	// Probe reads a file's container and stream metadata with ffprobe
+	Probe(ctx context.Context, file string) (*FFProbeOutput, error)
+	// Close releases connections held by the library
+	Close()
+}
+
+// remoteSchemes are the input prefixes that name a remote library
+var remoteSchemes = []string{"ssh://", "sftp://", "s3://"}
+
+// IsRemoteInput reports whether an input names a remote library rather than a local directory
+func IsRemoteInput(input string) bool {
+	for _, scheme := range remoteSchemes {
+		if strings.HasPrefix(input, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateRemoteInput checks a remote input's syntax without connecting to it
+func ValidateRemoteInput(input string) error {
+	if strings.HasPrefix(input, "s3://") {
+		_, err := ParseS3Source(input)
+		return err
+	}
+	_, err := ParseSSHSource(input)
+	return err
+}
+
+// OpenRemoteLibrary connects to the remote library an input names
+func OpenRemoteLibrary(ctx context.Context, input string) (RemoteLibrary, error) {
+	if strings.HasPrefix(input, "s3://") {
+		source, err := ParseS3Source(input)
+		if err != nil {
+			return nil, err
+		}
+		if err := source.Connect(); err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+
+	source, err := ParseSSHSource(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := source.Connect(ctx); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// remoteFileInfo is a file's stat from a remote listing
+type remoteFileInfo struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi remoteFileInfo) Name() string       { return path.Base(fi.path) }
+func (fi remoteFileInfo) Size() int64        { return fi.size }
+func (fi remoteFileInfo) Mode() fs.FileMode  { return 0 }
+func (fi remoteFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi remoteFileInfo) IsDir() bool        { return false }
+func (fi remoteFileInfo) Sys() any           { return nil }
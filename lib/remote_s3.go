@@ -0,0 +1,348 @@
+package lib
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// s3PresignExpiry is how long the presigned URL handed to ffprobe stays valid
+	s3PresignExpiry = time.Hour
+	// s3EmptyPayloadHash is the SHA-256 of an empty request body
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Source is a library in an S3 bucket, or an S3-compatible store such as MinIO or Backblaze
+// B2, given as s3://bucket/prefix. Objects are listed with ListObjectsV2 and probed by the local
+// ffprobe over presigned HTTPS URLs, which reads only the parts of each object it needs.
+// Credentials, region, and endpoint come from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION, and AWS_ENDPOINT_URL variables.
+// Files are identified by their s3:// URLs.
+type S3Source struct {
+	Bucket   string
+	Prefix   string // Key prefix, ending in "/" unless empty
+	Region   string
+	Endpoint string // Base URL of an S3-compatible store, addressed path-style (empty uses AWS)
+
+	credentials s3Credentials
+	client      *http.Client
+	files       map[string]remoteFileInfo // Stats from the last ScanVideoFiles, by URL
+}
+
+// s3Credentials are the keys requests are signed with
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string // Set for temporary credentials
+}
+
+// ParseS3Source parses an s3:// input. The prefix is treated as a folder, so s3://bucket/movies
+// lists movies/ and not movies-old/.
+func ParseS3Source(input string) (*S3Source, error) {
+	u, err := url.Parse(input)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 input %q: use s3://bucket/prefix", input)
+	}
+
+	source := &S3Source{Bucket: u.Host, Prefix: strings.Trim(u.Path, "/"), Region: "us-east-1"}
+	if source.Prefix != "" {
+		source.Prefix += "/"
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			source.Region = region
+			break
+		}
+	}
+	for _, env := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if endpoint := os.Getenv(env); endpoint != "" {
+			source.Endpoint = strings.TrimRight(endpoint, "/")
+			break
+		}
+	}
+	return source, nil
+}
+
+// Connect loads credentials from the environment and checks that ffprobe is available to read
+// the presigned URLs
+func (s *S3Source) Connect() error {
+	s.credentials = s3Credentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if s.credentials.accessKey == "" || s.credentials.secretKey == "" {
+		return fmt.Errorf("S3 inputs require AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY to be set")
+	}
+	if err := CheckFFprobeAvailable(); err != nil {
+		return err
+	}
+	s.client = &http.Client{}
+	return nil
+}
+
+// Close does nothing; S3 requests hold no connections between them
+func (s *S3Source) Close() {}
+
+// URL returns the s3:// URL identifying an object
+func (s *S3Source) URL(key string) string {
+	return "s3://" + s.Bucket + "/" + key
+}
+
+// key returns the object key of a file URL from URL
+func (s *S3Source) key(fileURL string) string {
+	return strings.TrimPrefix(fileURL, "s3://"+s.Bucket+"/")
+}
+
+// hostAndBasePath locates the bucket: path-style for custom endpoints and for bucket names
+// with dots, which do not match AWS's wildcard certificate, and virtual-hosted otherwise
+func (s *S3Source) hostAndBasePath() (scheme, host, basePath string) {
+	if s.Endpoint != "" {
+		if u, err := url.Parse(s.Endpoint); err == nil && u.Host != "" {
+			return u.Scheme, u.Host, strings.TrimRight(u.Path, "/") + "/" + s.Bucket
+		}
+	}
+	if strings.Contains(s.Bucket, ".") {
+		return "https", "s3." + s.Region + ".amazonaws.com", "/" + s.Bucket
+	}
+	return "https", s.Bucket + ".s3." + s.Region + ".amazonaws.com", ""
+}
+
+// ScanVideoFiles lists the video objects under Prefix, recording their sizes and modification
+// times for Stat
+func (s *S3Source) ScanVideoFiles(ctx context.Context) ([]string, error) {
+	slog.Info("Listing S3 objects", "bucket", s.Bucket, "prefix", s.Prefix)
+	s.files = make(map[string]remoteFileInfo)
+	var files []string
+	token := ""
+	for {
+		page, err := s.listObjects(ctx, token)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to list s3://%s/%s: %w", s.Bucket, s.Prefix, err)
+		}
+		for _, object := range page.Contents {
+			if !videoExtensions[strings.ToLower(path.Ext(object.Key))] {
+				continue
+			}
+			fileURL := s.URL(object.Key)
+			s.files[fileURL] = remoteFileInfo{path: object.Key, size: object.Size, modTime: object.LastModified}
+			files = append(files, fileURL)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	slog.Info("S3 listing complete", "bucket", s.Bucket, "files", len(files))
+	return files, nil
+}
+
+// s3ListResult is a page of ListObjectsV2 results
+type s3ListResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// s3ErrorResponse is the body S3 returns with a failed request
+type s3ErrorResponse struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// listObjects fetches one page of ListObjectsV2 results, continuing from token if set
+func (s *S3Source) listObjects(ctx context.Context, token string) (*s3ListResult, error) {
+	query := map[string]string{"list-type": "2", "prefix": s.Prefix}
+	if token != "" {
+		query["continuation-token"] = token
+	}
+	scheme, host, basePath := s.hostAndBasePath()
+	canonicalURI := s3EscapePath(basePath + "/")
+	canonicalQuery := s3CanonicalQuery(query)
+
+	now := time.Now().UTC()
+	headers := map[string]string{
+		"host":                 host,
+		"x-amz-content-sha256": s3EmptyPayloadHash,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	if s.credentials.sessionToken != "" {
+		headers["x-amz-security-token"] = s.credentials.sessionToken
+	}
+	signedHeaders, signature := s3Sign(s.credentials, s.Region, http.MethodGet, canonicalURI, canonicalQuery, headers, s3EmptyPayloadHash, now)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, scheme+"://"+host+canonicalURI+"?"+canonicalQuery, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		if name != "host" {
+			req.Header.Set(name, value)
+		}
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.credentials.accessKey, s3Scope(s.Region, now), signedHeaders, signature))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var s3Err s3ErrorResponse
+		if xml.Unmarshal(body, &s3Err) == nil && s3Err.Code != "" {
+			return nil, fmt.Errorf("%s: %s", s3Err.Code, s3Err.Message)
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var result s3ListResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse listing: %w", err)
+	}
+	return &result, nil
+}
+
+// Stat returns the size and modification time of an object found by ScanVideoFiles
+func (s *S3Source) Stat(fileURL string) (os.FileInfo, error) {
+	info, ok := s.files[fileURL]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: fileURL, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+// Probe runs the local ffprobe on a presigned URL for the object. The URL is kept out of
+// errors, since it grants access to the object until it expires.
+func (s *S3Source) Probe(ctx context.Context, fileURL string) (*FFProbeOutput, error) {
+	presigned := s.presign(s.key(fileURL), time.Now().UTC())
+	probe, err := probeFormatAndStreams(ctx, presigned)
+	if err != nil {
+		return nil, errors.New(strings.ReplaceAll(err.Error(), presigned, fileURL))
+	}
+	probe.Format.Filename = fileURL
+	return probe, nil
+}
+
+// presign returns a GET URL for an object that is valid for s3PresignExpiry
+func (s *S3Source) presign(key string, now time.Time) string {
+	scheme, host, basePath := s.hostAndBasePath()
+	canonicalURI := s3EscapePath(basePath + "/" + key)
+	return scheme + "://" + host + canonicalURI + "?" + s3PresignQuery(s.credentials, s.Region, host, canonicalURI, s3PresignExpiry, now)
+}
+
+// s3PresignQuery builds the query string of a presigned GET URL, signature included
+func s3PresignQuery(creds s3Credentials, region, host, canonicalURI string, expires time.Duration, now time.Time) string {
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    creds.accessKey + "/" + s3Scope(region, now),
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       strconv.Itoa(int(expires.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if creds.sessionToken != "" {
+		query["X-Amz-Security-Token"] = creds.sessionToken
+	}
+	canonicalQuery := s3CanonicalQuery(query)
+	_, signature := s3Sign(creds, region, http.MethodGet, canonicalURI, canonicalQuery, map[string]string{"host": host}, "UNSIGNED-PAYLOAD", now)
+	return canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+// s3Scope is the credential scope of a request signed at t
+func s3Scope(region string, t time.Time) string {
+	return t.Format("20060102") + "/" + region + "/s3/aws4_request"
+}
+
+// s3Sign computes an AWS Signature Version 4 signature over a request with lowercase header
+// names, returning the signed header list along with the signature
+func s3Sign(creds s3Credentials, region, method, canonicalURI, canonicalQuery string, headers map[string]string, payloadHash string, t time.Time) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{method, canonicalURI, canonicalQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", t.Format("20060102T150405Z"), s3Scope(region, t), hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretKey), t.Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery encodes query parameters sorted by name, as signing requires
+func s3CanonicalQuery(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = s3Escape(name, false) + "=" + s3Escape(params[name], false)
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3EscapePath percent-encodes an object path, keeping its slashes
+func s3EscapePath(p string) string {
+	return s3Escape(p, true)
+}
+
+// s3Escape percent-encodes everything but RFC 3986 unreserved characters, and slashes if keepSlash
+func s3Escape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && keepSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
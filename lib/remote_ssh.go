@@ -0,0 +1,220 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSHSource is a library on another machine, such as a seedbox, reached over SSH and given as
+// ssh://[user@]host[:port]/path. Files are listed and stat'ed with a single find run and probed
+// with the remote host's ffprobe, so only metadata crosses the network and nothing is mounted.
+// Connections share one multiplexed SSH session. Files are identified by their ssh:// URLs.
+type SSHSource struct {
+	Host string // [user@]host as passed to ssh
+	Port string // SSH port (empty uses the ssh default)
+	Root string // Absolute directory on the remote host
+
+	prefix     string                    // URL prefix that remote paths are appended to
+	controlDir string                    // Directory holding the multiplexing socket
+	files      map[string]remoteFileInfo // Stats from the last ScanVideoFiles, by URL
+}
+
+// ParseSSHSource parses an ssh:// or sftp:// input. Relative paths such as ssh://host/media are
+// taken as absolute, since there is no working directory to resolve them against.
+func ParseSSHSource(input string) (*SSHSource, error) {
+	u, err := url.Parse(input)
+	if err != nil || (u.Scheme != "ssh" && u.Scheme != "sftp") {
+		return nil, fmt.Errorf("invalid remote input %q: use ssh://[user@]host[:port]/path", input)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid remote input %q: missing host", input)
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("invalid remote input %q: passwords are not supported; use an SSH key or agent", input)
+	}
+
+	source := &SSHSource{Host: u.Hostname(), Port: u.Port(), Root: path.Clean("/" + u.Path), prefix: "ssh://" + u.Host}
+	if user := u.User.Username(); user != "" {
+		source.Host = user + "@" + source.Host
+		source.prefix = "ssh://" + user + "@" + u.Host
+	}
+	return source, nil
+}
+
+// URL returns the ssh:// URL identifying a file on the remote host
+func (r *SSHSource) URL(remotePath string) string {
+	return r.prefix + remotePath
+}
+
+// remotePath returns the path on the remote host of a file URL from URL
+func (r *SSHSource) remotePath(fileURL string) string {
+	return strings.TrimPrefix(fileURL, r.prefix)
+}
+
+// command builds an ssh invocation running a shell command on the remote host. BatchMode makes
+// ssh fail rather than prompt for a password, since probes run in parallel with no terminal.
+func (r *SSHSource) command(ctx context.Context, remoteCommand string) *exec.Cmd {
+	args := []string{"-o", "BatchMode=yes"}
+	if r.controlDir != "" {
+		args = append(args, "-o", "ControlMaster=auto", "-o", "ControlPath="+filepath.Join(r.controlDir, "%C"), "-o", "ControlPersist=60")
+	}
+	if r.Port != "" {
+		args = append(args, "-p", r.Port)
+	}
+	args = append(args, r.Host, remoteCommand)
+	cmd := exec.CommandContext(ctx, ToolCommand("ssh"), args...)
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
+}
+
+// Connect opens the shared SSH session and checks that the remote host has ffprobe. Call Close
+// when done.
+func (r *SSHSource) Connect(ctx context.Context) error {
+	if _, err := exec.LookPath(ToolCommand("ssh")); err != nil {
+		return fmt.Errorf("remote inputs require ssh, which was not found in the tools directory or PATH")
+	}
+	dir, err := os.MkdirTemp("", "media-mgmt-ssh-")
+	if err != nil {
+		return fmt.Errorf("failed to create SSH control directory: %w", err)
+	}
+	r.controlDir = dir
+
+	output, err := r.command(ctx, "command -v ffprobe").CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return fmt.Errorf("ffprobe was not found on %s", r.Host)
+		}
+		return fmt.Errorf("failed to connect to %s: %w: %s", r.Host, err, strings.TrimSpace(string(output)))
+	}
+	slog.Debug("Connected to remote host", "host", r.Host, "ffprobe", strings.TrimSpace(string(output)))
+	return nil
+}
+
+// Close ends the shared SSH session
+func (r *SSHSource) Close() {
+	if r.controlDir == "" {
+		return
+	}
+	args := []string{"-o", "ControlPath=" + filepath.Join(r.controlDir, "%C"), "-O", "exit"}
+	if r.Port != "" {
+		args = append(args, "-p", r.Port)
+	}
+	exec.Command(ToolCommand("ssh"), append(args, r.Host)...).Run()
+	os.RemoveAll(r.controlDir)
+	r.controlDir = ""
+}
+
+// ScanVideoFiles lists the video files under Root, recording their sizes and modification times
+// for Stat. Needs GNU find on the remote host, as found on Linux.
+func (r *SSHSource) ScanVideoFiles(ctx context.Context) ([]string, error) {
+	slog.Info("Scanning remote directory", "host", r.Host, "dir", r.Root)
+	listing := fmt.Sprintf("find %s -type f -printf '%%s\\t%%T@\\t%%p\\0'", shellQuote(r.Root))
+	cmd := r.command(ctx, listing)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to list %s on %s: %w: %s", r.Root, r.Host, err, strings.TrimSpace(stderr.String()))
+	}
+
+	entries, err := parseFindListing(output)
+	if err != nil {
+		return nil, err
+	}
+	r.files = make(map[string]remoteFileInfo)
+	var files []string
+	for _, entry := range entries {
+		if !videoExtensions[strings.ToLower(path.Ext(entry.path))] {
+			continue
+		}
+		fileURL := r.URL(entry.path)
+		r.files[fileURL] = entry
+		files = append(files, fileURL)
+	}
+	slog.Info("Remote scan complete", "host", r.Host, "files", len(files))
+	return files, nil
+}
+
+// Stat returns the size and modification time of a file found by ScanVideoFiles
+func (r *SSHSource) Stat(fileURL string) (os.FileInfo, error) {
+	info, ok := r.files[fileURL]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: fileURL, Err: fs.ErrNotExist}
+	}
+	return info, nil
+}
+
+// Probe runs ffprobe on the remote host for a file's container and stream metadata
+func (r *SSHSource) Probe(ctx context.Context, fileURL string) (*FFProbeOutput, error) {
+	probe := "ffprobe -v quiet -print_format json -show_format -show_streams " + shellQuote(r.remotePath(fileURL))
+	cmd := r.command(ctx, probe)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			// ssh exits with 255 for its own errors and passes through ffprobe's otherwise
+			if exitErr.ExitCode() == 255 {
+				return nil, fmt.Errorf("ssh to %s failed: %s", r.Host, strings.TrimSpace(stderr.String()))
+			}
+			return nil, fmt.Errorf("ffprobe exit code %d: %s", exitErr.ExitCode(), strings.TrimSpace(stderr.String()))
+		}
+		return nil, err
+	}
+
+	var probeOutput FFProbeOutput
+	if err := json.Unmarshal(output, &probeOutput); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe JSON output: %w", err)
+	}
+	return &probeOutput, nil
+}
+
+// parseFindListing parses NUL-terminated "size\tmtime\tpath" records from find -printf, where
+// mtime is in seconds with a fractional part
+func parseFindListing(output []byte) ([]remoteFileInfo, error) {
+	var entries []remoteFileInfo
+	for _, record := range strings.Split(string(output), "\x00") {
+		if record == "" {
+			continue
+		}
+		fields := strings.SplitN(record, "\t", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected remote listing entry %q", record)
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected size in remote listing entry %q", record)
+		}
+		mtime, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected modification time in remote listing entry %q", record)
+		}
+		seconds := int64(mtime)
+		entries = append(entries, remoteFileInfo{
+			path:    fields[2],
+			size:    size,
+			modTime: time.Unix(seconds, int64((mtime-float64(seconds))*1e9)),
+		})
+	}
+	return entries, nil
+}
+
+// shellQuote quotes s as a single word for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,121 @@
+// Package report renders media reports to any io.Writer, for Go programs that embed media-mgmt
+// and for serve mode. Unlike lib.ReportGenerator it never touches the filesystem layout:
+// no output directory, timestamped filenames, snapshots, or hooks.
+package report
+
+import (
+	"fmt"
+	"io"
+	"media-mgmt/lib"
+	"slices"
+	"strings"
+)
+
+// Format is a report format
+type Format string
+
+// Supported report formats
+const (
+	CSV      Format = lib.ReportFormatCSV
+	JSON     Format = lib.ReportFormatJSON
+	Markdown Format = lib.ReportFormatMarkdown
+	HTML     Format = lib.ReportFormatHTML
+	SQLite   Format = lib.ReportFormatSQLite
+)
+
+// Formats lists the supported formats
+var Formats = []Format{CSV, JSON, Markdown, HTML, SQLite}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case JSON:
+		return "application/json"
+	case Markdown:
+		return "text/markdown; charset=utf-8"
+	case HTML:
+		return "text/html; charset=utf-8"
+	case SQLite:
+		return "application/vnd.sqlite3"
+	}
+	return "application/octet-stream"
+}
+
+// ParseFormat returns the format named by s, such as "csv" or "md"
+func ParseFormat(s string) (Format, error) {
+	format := Format(strings.ToLower(s))
+	if !slices.Contains(Formats, format) {
+		return "", fmt.Errorf("unsupported report format %q: must be csv, json, md, html, or sqlite", s)
+	}
+	return format, nil
+}
+
+// Options holds the optional sections of a report. The zero value renders files only.
+type Options struct {
+	Branding    lib.ReportBranding        // Title, logo, and notes (HTML and Markdown)
+	Trends      []lib.LibrarySnapshot     // Library snapshots over time (HTML and Markdown)
+	Previous    *lib.LibrarySnapshot      // Previous snapshot to show deltas against (HTML)
+	Savings     *lib.SavingsReport        // Transcode candidates (HTML and Markdown)
+	Comparisons []lib.TranscodeComparison // Before/after transcode pairs (HTML)
+	Filters     []lib.SavedFilter         // Saved filters offered as views (HTML)
+}
+
+// generator returns a ReportGenerator configured with the options
+func (o Options) generator() *lib.ReportGenerator {
+	rg := lib.NewReportGenerator("")
+	rg.Branding = o.Branding
+	rg.Trends = o.Trends
+	rg.Previous = o.Previous
+	rg.Savings = o.Savings
+	rg.Comparisons = o.Comparisons
+	rg.Filters = o.Filters
+	return rg
+}
+
+// Render writes a report of infos in format to w. The infos slice is not modified.
+func Render(w io.Writer, format Format, infos []*lib.MediaInfo, opts Options) error {
+	// The writers sort their input, so work on a copy
+	infos = slices.Clone(infos)
+	rg := opts.generator()
+
+	switch format {
+	case CSV:
+		return rg.WriteCSV(w, infos)
+	case JSON:
+		return rg.WriteJSON(w, infos)
+	case Markdown:
+		return rg.WriteMarkdown(w, infos)
+	case HTML:
+		return rg.WriteHTML(w, infos)
+	case SQLite:
+		return rg.WriteSQLite(w, infos)
+	}
+	return fmt.Errorf("unsupported report format %q", format)
+}
+
+// WriteCSV writes a CSV report with one row per file
+func WriteCSV(w io.Writer, infos []*lib.MediaInfo) error {
+	return Render(w, CSV, infos, Options{})
+}
+
+// WriteJSON writes a JSON report of every file's full analysis
+func WriteJSON(w io.Writer, infos []*lib.MediaInfo) error {
+	return Render(w, JSON, infos, Options{})
+}
+
+// WriteMarkdown writes a Markdown report with summary tables
+func WriteMarkdown(w io.Writer, infos []*lib.MediaInfo, opts Options) error {
+	return Render(w, Markdown, infos, opts)
+}
+
+// WriteHTML writes a self-contained interactive HTML report
+func WriteHTML(w io.Writer, infos []*lib.MediaInfo, opts Options) error {
+	return Render(w, HTML, infos, opts)
+}
+
+// WriteSQLite writes a SQLite database of every file and its tracks
+func WriteSQLite(w io.Writer, infos []*lib.MediaInfo) error {
+	return Render(w, SQLite, infos, Options{})
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"media-mgmt/lib"
+	"strings"
+	"testing"
+)
+
+func testInfos() []*lib.MediaInfo {
+	return []*lib.MediaInfo{
+		{FilePath: "/media/b.mkv", VideoCodec: "hevc", VideoWidth: 3840, VideoHeight: 2160},
+		{FilePath: "/media/a.mkv", VideoCodec: "h264", VideoWidth: 1920, VideoHeight: 1080},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		format Format
+		check  func(t *testing.T, output []byte)
+	}{
+		{CSV, func(t *testing.T, output []byte) {
+			rows, err := csv.NewReader(bytes.NewReader(output)).ReadAll()
+			if err != nil || len(rows) != 3 || rows[1][0] != "/media/a.mkv" {
+				t.Errorf("Expected a header and two sorted rows, got %v (%v)", rows, err)
+			}
+		}},
+		{JSON, func(t *testing.T, output []byte) {
+			var doc struct {
+				TotalFiles int `json:"total_files"`
+			}
+			if err := json.Unmarshal(output, &doc); err != nil || doc.TotalFiles != 2 {
+				t.Errorf("Expected 2 files, got %s (%v)", output, err)
+			}
+		}},
+		{Markdown, func(t *testing.T, output []byte) {
+			if !strings.HasPrefix(string(output), "# Library\n") {
+				t.Errorf("Expected the branded title, got %q", output)
+			}
+		}},
+		{SQLite, func(t *testing.T, output []byte) {
+			if !bytes.HasPrefix(output, []byte("SQLite format 3\x00")) {
+				t.Errorf("Expected a SQLite database, got %d bytes", len(output))
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			infos := testInfos()
+			var buf bytes.Buffer
+			if err := Render(&buf, tt.format, infos, Options{Branding: lib.ReportBranding{Title: "Library"}}); err != nil {
+				t.Fatalf("Render failed: %v", err)
+			}
+			tt.check(t, buf.Bytes())
+			if infos[0].FilePath != "/media/b.mkv" {
+				t.Error("Render reordered the caller's slice")
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat("MD"); err != nil || format != Markdown {
+		t.Errorf("ParseFormat(MD) = %q, %v", format, err)
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
@@ -1,10 +1,13 @@
 package lib
 
 import (
+	"context"
 	"embed"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"html"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
@@ -17,69 +20,199 @@ import (
 //go:embed templates/*
 var templatesFS embed.FS
 
+// Report formats supported by the report generator
+const (
+	ReportFormatCSV      = "csv"
+	ReportFormatJSON     = "json"
+	ReportFormatMarkdown = "md"
+	ReportFormatHTML     = "html"
+	ReportFormatSQLite   = "sqlite"
+)
+
+// DefaultReportFormats are generated when no formats are configured
+var DefaultReportFormats = []string{ReportFormatCSV, ReportFormatJSON, ReportFormatMarkdown, ReportFormatHTML}
+
+// sqliteReportFilename is fixed so queries can always target the latest database
+const sqliteReportFilename = "media.db"
+
 type ReportGenerator struct {
-	outputDir string
+	outputDir   string
+	Formats     []string              // Formats to generate (defaults to DefaultReportFormats)
+	Comparisons []TranscodeComparison // Before/after transcode pairs shown in the HTML report
+	Trends      []LibrarySnapshot     // Weekly library snapshots shown in the HTML and Markdown reports
+	Previous    *LibrarySnapshot      // Previous run's snapshot, compared against in the HTML report (nil for none)
+	Savings     *SavingsReport        // Transcode candidates shown in the HTML and Markdown reports and written as a file list
+	Branding    ReportBranding        // Title, logo, and notes heading the HTML and Markdown reports
+	Filters     []SavedFilter         // Saved filters offered as views in the HTML report
+	Hooks       []ReportHook          // External commands run after the reports are written
+	Failures    []FileFailure         // Files that could not be analyzed, listed in every report
+	InputDir    string                // Library root the files are grouped by directory under (their common directory if empty)
 }
 
 func NewReportGenerator(outputDir string) *ReportGenerator {
 	return &ReportGenerator{outputDir: outputDir}
 }
 
-// GenerateAllReports creates all report formats
-func (rg *ReportGenerator) GenerateAllReports(mediaInfos []*MediaInfo) error {
+// ValidateReportFormats checks that every format is supported
+func ValidateReportFormats(formats []string) error {
+	for _, format := range formats {
+		switch format {
+		case ReportFormatCSV, ReportFormatJSON, ReportFormatMarkdown, ReportFormatHTML, ReportFormatSQLite:
+		default:
+			return fmt.Errorf("unsupported report format %q: must be csv, json, md, html, or sqlite", format)
+		}
+	}
+	return nil
+}
+
+// GenerateAllReports creates every configured report format, then runs the report hooks until
+// ctx is cancelled
+func (rg *ReportGenerator) GenerateAllReports(ctx context.Context, mediaInfos []*MediaInfo) error {
 	if err := os.MkdirAll(rg.outputDir, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	formats := rg.Formats
+	if len(formats) == 0 {
+		formats = DefaultReportFormats
+	}
+	if err := ValidateReportFormats(formats); err != nil {
+		return err
+	}
+
 	slog.Info("Generating reports", "outputDir", rg.outputDir, "mediaCount", len(mediaInfos))
 
 	timestamp := time.Now().Format("20060102_150405")
+	var paths []string
+
+	for _, format := range formats {
+		filename := fmt.Sprintf("media_report_%s.%s", timestamp, format)
+		var err error
+		switch format {
+		case ReportFormatCSV:
+			err = rg.GenerateCSV(mediaInfos, filename)
+		case ReportFormatJSON:
+			err = rg.GenerateJSON(mediaInfos, filename)
+		case ReportFormatMarkdown:
+			err = rg.GenerateMarkdown(mediaInfos, filename)
+		case ReportFormatHTML:
+			err = rg.GenerateHTML(mediaInfos, filename)
+		case ReportFormatSQLite:
+			filename = sqliteReportFilename
+			err = rg.GenerateSQLite(mediaInfos, filename)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate %s report: %w", format, err)
+		}
+		paths = append(paths, filepath.Join(rg.outputDir, filename))
+	}
+
+	if rg.Savings != nil {
+		path := filepath.Join(rg.outputDir, TranscodeCandidatesFilename)
+		if err := rg.Savings.WriteFileList(path); err != nil {
+			return err
+		}
+		paths = append(paths, path)
+	}
+
+	slog.Info("All reports generated successfully", "paths", paths)
+	return rg.runHooks(ctx, mediaInfos, paths)
+}
 
-	csvFilename := fmt.Sprintf("media_report_%s.csv", timestamp)
-	if err := rg.GenerateCSV(mediaInfos, csvFilename); err != nil {
-		return fmt.Errorf("failed to generate CSV report: %w", err)
+// GenerateSQLite writes all media and their tracks into a fresh SQLite database
+func (rg *ReportGenerator) GenerateSQLite(mediaInfos []*MediaInfo, filename string) error {
+	filePath := filepath.Join(rg.outputDir, filename)
+	tmpPath := filePath + ".tmp"
+	if err := writeSQLiteFile(tmpPath, mediaInfos, rg.Failures); err != nil {
+		return err
 	}
 
-	jsonFilename := fmt.Sprintf("media_report_%s.json", timestamp)
-	if err := rg.GenerateJSON(mediaInfos, jsonFilename); err != nil {
-		return fmt.Errorf("failed to generate JSON report: %w", err)
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return err
 	}
+	slog.Debug("SQLite report generated", "path", filePath)
+	return nil
+}
 
-	mdFilename := fmt.Sprintf("media_report_%s.md", timestamp)
-	if err := rg.GenerateMarkdown(mediaInfos, mdFilename); err != nil {
-		return fmt.Errorf("failed to generate Markdown report: %w", err)
+// WriteSQLite writes a SQLite database of all media and their tracks to w.
+// SQLite needs a real file, so the database is built in a temporary file first.
+func (rg *ReportGenerator) WriteSQLite(w io.Writer, mediaInfos []*MediaInfo) error {
+	tmp, err := os.CreateTemp("", "media-mgmt-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary database: %w", err)
 	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
 
-	htmlFilename := fmt.Sprintf("media_report_%s.html", timestamp)
-	if err := rg.GenerateHTML(mediaInfos, htmlFilename); err != nil {
-		return fmt.Errorf("failed to generate HTML report: %w", err)
+	if err := writeSQLiteFile(tmpPath, mediaInfos, rg.Failures); err != nil {
+		return err
 	}
+	db, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = io.Copy(w, db)
+	return err
+}
 
-	slog.Info("All reports generated successfully", "paths", []string{
-		filepath.Join(rg.outputDir, csvFilename),
-		filepath.Join(rg.outputDir, jsonFilename),
-		filepath.Join(rg.outputDir, mdFilename),
-		filepath.Join(rg.outputDir, htmlFilename),
-	})
+// writeSQLiteFile creates a fresh database at path, removing it again if any write fails
+func writeSQLiteFile(path string, mediaInfos []*MediaInfo, failures []FileFailure) error {
+	os.Remove(path)
+
+	db, err := OpenMediaDB(path)
+	if err != nil {
+		return err
+	}
+	if err := db.Upsert(context.Background(), mediaInfos); err != nil {
+		db.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := db.ReplaceFailures(context.Background(), failures); err != nil {
+		db.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := db.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
 	return nil
 }
 
-// GenerateCSV creates a CSV report
-func (rg *ReportGenerator) GenerateCSV(mediaInfos []*MediaInfo, filename string) error {
+// writeReportFile creates filename in the output directory and writes a report into it
+func (rg *ReportGenerator) writeReportFile(filename, kind string, write func(w io.Writer) error) error {
 	filePath := filepath.Join(rg.outputDir, filename)
 	file, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	slog.Debug(kind+" report generated", "path", filePath)
+	return nil
+}
+
+// GenerateCSV creates a CSV report
+func (rg *ReportGenerator) GenerateCSV(mediaInfos []*MediaInfo, filename string) error {
+	return rg.writeReportFile(filename, "CSV", func(w io.Writer) error { return rg.WriteCSV(w, mediaInfos) })
+}
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+// WriteCSV writes a CSV report to w
+func (rg *ReportGenerator) WriteCSV(w io.Writer, mediaInfos []*MediaInfo) error {
+	writer := csv.NewWriter(w)
 
 	// Write header
 	header := []string{
 		"File Path", "File Size (MB)", "Duration (min)", "Video Codec",
-		"Video Bitrate (kbps)", "Resolution", "Audio Tracks", "Subtitle Tracks",
+		"Video Bitrate (kbps)", "Peak Video Bitrate (kbps)", "Bitrate Variability", "Resolution", "Frame Rate", "VFR", "Bit Depth", "Scan Type", "HDR", "Audio Tracks", "Commentary Tracks", "Descriptive Tracks", "Audio Sidecars", "Subtitle Tracks", "Forced Subtitles", "SDH Subtitles", "Video Streams", "Release Group", "Encoder", "Created", "Archived To", "Failed Stage", "Error",
 	}
 	if err := writer.Write(header); err != nil {
 		return err
@@ -92,63 +225,103 @@ func (rg *ReportGenerator) GenerateCSV(mediaInfos []*MediaInfo, filename string)
 
 	// Write data rows
 	for _, info := range mediaInfos {
+		// Measured bitrates are blank unless the file was analyzed with --accurate-bitrate
+		var peakBitrate, variability string
+		if info.BitrateStats != nil {
+			peakBitrate = strconv.FormatInt(info.BitrateStats.PeakVideoBitrate()/1000, 10)
+			variability = strconv.FormatFloat(info.BitrateStats.Variability(), 'f', 3, 64)
+		}
 		row := []string{
 			info.FilePath,
 			fmt.Sprintf("%.2f", float64(info.FileSize)/(1024*1024)),
 			fmt.Sprintf("%.2f", info.Duration/60),
 			info.VideoCodec,
 			strconv.FormatInt(info.VideoBitrate/1000, 10),
+			peakBitrate,
+			variability,
 			fmt.Sprintf("%dx%d", info.VideoWidth, info.VideoHeight),
+			strconv.FormatFloat(info.FrameRate, 'f', 3, 64),
+			strconv.FormatBool(info.IsVFR),
+			strconv.Itoa(info.BitDepth),
+			info.ScanType,
+			info.HDR.String(),
 			strconv.Itoa(len(info.AudioTracks)),
+			strconv.Itoa(info.AudioTracksWithRole(AudioRoleCommentary)),
+			strconv.Itoa(info.AudioTracksWithRole(AudioRoleDescriptive)),
+			strconv.Itoa(len(info.AudioSidecars)),
 			strconv.Itoa(len(info.SubtitleTracks)),
+			strconv.Itoa(info.SubtitleTracksOfKind(SubtitleKindForced)),
+			strconv.Itoa(info.SubtitleTracksOfKind(SubtitleKindSDH)),
+			strconv.Itoa(len(info.VideoStreams)),
+			info.Container.ReleaseGroupName(),
+			info.Container.EncoderName(),
+			info.Container.CreatedAt(),
+			info.ArchivedTo,
+			"",
+			"",
 		}
 		if err := writer.Write(row); err != nil {
 			return err
 		}
 	}
 
-	slog.Debug("CSV report generated", "path", filePath)
-	return nil
+	// Files that could not be analyzed get a row with only their path and the failure
+	for _, failure := range rg.Failures {
+		row := make([]string, len(header))
+		row[0] = failure.File
+		row[len(row)-2] = failure.Stage
+		row[len(row)-1] = failure.Error
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
 }
 
 // GenerateJSON creates a JSON report
 func (rg *ReportGenerator) GenerateJSON(mediaInfos []*MediaInfo, filename string) error {
-	filePath := filepath.Join(rg.outputDir, filename)
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return rg.writeReportFile(filename, "JSON", func(w io.Writer) error { return rg.WriteJSON(w, mediaInfos) })
+}
 
-	encoder := json.NewEncoder(file)
+// WriteJSON writes a JSON report to w
+func (rg *ReportGenerator) WriteJSON(w io.Writer, mediaInfos []*MediaInfo) error {
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 
-	report := map[string]interface{}{
+	return encoder.Encode(rg.jsonReport(mediaInfos))
+}
+
+// jsonReport is the document written by GenerateJSON and fed to report hooks
+func (rg *ReportGenerator) jsonReport(mediaInfos []*MediaInfo) map[string]interface{} {
+	failures := rg.Failures
+	if failures == nil {
+		failures = []FileFailure{}
+	}
+	return map[string]interface{}{
 		"generated_at": time.Now().Format(time.RFC3339),
 		"total_files":  len(mediaInfos),
 		"media_files":  mediaInfos,
+		"failed_files": failures,
 	}
-
-	if err := encoder.Encode(report); err != nil {
-		return err
-	}
-
-	slog.Debug("JSON report generated", "path", filePath)
-	return nil
 }
 
 // GenerateMarkdown creates a Markdown report
 func (rg *ReportGenerator) GenerateMarkdown(mediaInfos []*MediaInfo, filename string) error {
-	filePath := filepath.Join(rg.outputDir, filename)
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return rg.writeReportFile(filename, "Markdown", func(w io.Writer) error { return rg.WriteMarkdown(w, mediaInfos) })
+}
 
-	fmt.Fprintf(file, "# Media Analysis Report\n\n")
-	fmt.Fprintf(file, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Fprintf(file, "Total Files: %d\n\n", len(mediaInfos))
+// WriteMarkdown writes a Markdown report to w
+func (rg *ReportGenerator) WriteMarkdown(w io.Writer, mediaInfos []*MediaInfo) error {
+	fmt.Fprintf(w, "# %s\n\n", rg.Branding.ReportTitle())
+	if rg.Branding.Logo != "" {
+		fmt.Fprintf(w, "![Logo](%s)\n\n", rg.Branding.Logo)
+	}
+	fmt.Fprintf(w, "Generated: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(w, "Total Files: %d\n\n", len(mediaInfos))
+	if notes := strings.TrimSpace(rg.Branding.Notes); notes != "" {
+		fmt.Fprintf(w, "## Notes\n\n%s\n\n", notes)
+	}
 
 	// Summary statistics
 	var totalSize int64
@@ -161,18 +334,35 @@ func (rg *ReportGenerator) GenerateMarkdown(mediaInfos []*MediaInfo, filename st
 		codecCount[info.VideoCodec]++
 	}
 
-	fmt.Fprintf(file, "## Summary\n\n")
-	fmt.Fprintf(file, "- **Total Size**: %.2f GB\n", float64(totalSize)/(1024*1024*1024))
-	fmt.Fprintf(file, "- **Total Duration**: %.2f hours\n", totalDuration/3600)
-	fmt.Fprintf(file, "\n### Video Codecs\n\n")
+	fmt.Fprintf(w, "## Summary\n\n")
+	fmt.Fprintf(w, "- **Total Size**: %.2f GB\n", float64(totalSize)/(1024*1024*1024))
+	fmt.Fprintf(w, "- **Total Duration**: %.2f hours\n", totalDuration/3600)
+	fmt.Fprintf(w, "\n### Video Codecs\n\n")
 
 	for codec, count := range codecCount {
-		fmt.Fprintf(file, "- **%s**: %d files\n", codec, count)
+		fmt.Fprintf(w, "- **%s**: %d files\n", codec, count)
+	}
+
+	if len(rg.Trends) > 1 {
+		writeMarkdownTrends(w, rg.Trends)
+	}
+
+	writeMarkdownGroups(w, "By Directory", GroupByDirectory(rg.inputDir(mediaInfos), mediaInfos))
+	writeMarkdownGroups(w, "By Show", GroupByShow(mediaInfos))
+
+	if rg.Savings != nil && len(rg.Savings.Candidates) > 0 {
+		writeMarkdownSavings(w, rg.Savings)
+	}
+
+	writeMarkdownVideoStreams(w, mediaInfos)
+	writeMarkdownVFR(w, mediaInfos)
+	if len(rg.Failures) > 0 {
+		writeMarkdownFailures(w, rg.Failures)
 	}
 
-	fmt.Fprintf(file, "\n## Detailed Analysis\n\n")
-	fmt.Fprintf(file, "| File | Size (MB) | Duration | Codec | Bitrate | Resolution | Audio | Subs |\n")
-	fmt.Fprintf(file, "|------|-----------|----------|-------|---------|------------|-------|------|\n")
+	fmt.Fprintf(w, "\n## Detailed Analysis\n\n")
+	fmt.Fprintf(w, "| File | Size (MB) | Duration | Codec | Bitrate | Resolution | FPS | Depth | Scan | HDR | Audio | Subs |\n")
+	fmt.Fprintf(w, "|------|-----------|----------|-------|---------|------------|-----|-------|------|-----|-------|------|\n")
 
 	// Sort by file path
 	sort.Slice(mediaInfos, func(i, j int) bool {
@@ -181,98 +371,306 @@ func (rg *ReportGenerator) GenerateMarkdown(mediaInfos []*MediaInfo, filename st
 
 	for _, info := range mediaInfos {
 		fileName := filepath.Base(info.FilePath)
-		fmt.Fprintf(file, "| %s | %.1f | %.1fm | %s | %dkbps | %dx%d | %d | %d |\n",
+		if info.ArchivedTo != "" {
+			fileName += " (archived)"
+		}
+		fmt.Fprintf(w, "| %s | %.1f | %.1fm | %s | %dkbps | %dx%d | %.2f | %d | %s | %s | %d | %d |\n",
 			fileName,
 			float64(info.FileSize)/(1024*1024),
 			info.Duration/60,
 			info.VideoCodec,
 			info.VideoBitrate/1000,
 			info.VideoWidth, info.VideoHeight,
+			info.FrameRate,
+			info.BitDepth,
+			info.ScanType,
+			info.HDR,
 			len(info.AudioTracks),
 			len(info.SubtitleTracks))
 	}
-
-	slog.Debug("Markdown report generated", "path", filePath)
 	return nil
 }
 
+// writeMarkdownTrends writes a table of weekly snapshots with sparklines of size and compression progress
+func writeMarkdownTrends(w io.Writer, trends []LibrarySnapshot) {
+	sizes := make([]float64, len(trends))
+	shares := make([]float64, len(trends))
+	for i, snapshot := range trends {
+		sizes[i] = float64(snapshot.TotalSize)
+		shares[i] = snapshot.EfficientShare
+	}
+
+	fmt.Fprintf(w, "\n## Trends\n\n")
+	fmt.Fprintf(w, "- **Total Size**: `%s`\n", Sparkline(sizes))
+	fmt.Fprintf(w, "- **HEVC/AV1 Share**: `%s`\n\n", Sparkline(shares))
+	fmt.Fprintf(w, "| Week | Files | Total Size (GB) | Avg Bitrate | HEVC/AV1 Share |\n")
+	fmt.Fprintf(w, "|------|-------|-----------------|-------------|----------------|\n")
+	for _, snapshot := range trends {
+		fmt.Fprintf(w, "| %s | %d | %.2f | %dkbps | %.0f%% |\n",
+			snapshot.Timestamp.Format("2006-01-02"),
+			snapshot.Files,
+			float64(snapshot.TotalSize)/(1024*1024*1024),
+			snapshot.AvgBitrate/1000,
+			snapshot.EfficientShare*100)
+	}
+}
+
+// writeMarkdownGroups writes a table of aggregated groups, omitting the section when there are none
+func writeMarkdownGroups(w io.Writer, title string, groups []LibraryGroup) {
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\n## %s\n\n", title)
+	fmt.Fprintf(w, "| Name | Files | Total Size (GB) | Duration (h) | Avg Bitrate |\n")
+	fmt.Fprintf(w, "|------|-------|-----------------|--------------|-------------|\n")
+	for _, group := range groups {
+		fmt.Fprintf(w, "| %s | %d | %.2f | %.1f | %dkbps |\n",
+			group.Name,
+			group.Files,
+			float64(group.TotalSize)/(1024*1024*1024),
+			group.TotalDuration/3600,
+			group.AvgBitrate/1000)
+	}
+}
+
+// writeMarkdownVideoStreams lists files with extra video streams worth stripping, such as alternate
+// angles or embedded trailers, omitting the section when there are none
+func writeMarkdownVideoStreams(w io.Writer, mediaInfos []*MediaInfo) {
+	var files []*MediaInfo
+	for _, info := range mediaInfos {
+		if len(info.ExtraVideoStreams()) > 0 {
+			files = append(files, info)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
+
+	fmt.Fprintf(w, "\n## Multiple Video Streams\n\n")
+	fmt.Fprintf(w, "| File | Stream | Kind | Codec | Resolution | FPS | Disposition | Title |\n")
+	fmt.Fprintf(w, "|------|--------|------|-------|------------|-----|-------------|-------|\n")
+	for _, info := range files {
+		for _, stream := range info.VideoStreams {
+			fmt.Fprintf(w, "| %s | %d | %s | %s | %dx%d | %.2f | %s | %s |\n",
+				filepath.Base(info.FilePath),
+				stream.Index,
+				stream.Kind,
+				stream.Codec,
+				stream.Width, stream.Height,
+				stream.FrameRate,
+				strings.Join(stream.Disposition, ", "),
+				stream.Title)
+		}
+	}
+}
+
+// writeMarkdownVFR lists files with a variable frame rate, which need transcode --cfr-convert
+// before they edit cleanly in most NLEs
+func writeMarkdownVFR(w io.Writer, mediaInfos []*MediaInfo) {
+	var files []*MediaInfo
+	for _, info := range mediaInfos {
+		if info.IsVFR {
+			files = append(files, info)
+		}
+	}
+	if len(files) == 0 {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].FilePath < files[j].FilePath })
+
+	fmt.Fprintf(w, "\n## Variable Frame Rate\n\n")
+	fmt.Fprintf(w, "These files may drift out of sync in editing applications; `transcode --cfr-convert` fixes them.\n\n")
+	for _, info := range files {
+		fmt.Fprintf(w, "- %s (average %.2f fps)\n", filepath.Base(info.FilePath), info.FrameRate)
+	}
+}
+
+// markdownSavingsLimit caps the candidates listed in the Markdown report; the file list has all of them
+const markdownSavingsLimit = 25
+
+// writeMarkdownSavings writes the predicted reclaimable space and the top transcode candidates
+func writeMarkdownSavings(w io.Writer, savings *SavingsReport) {
+	fmt.Fprintf(w, "\n## Savings Opportunities\n\n")
+	fmt.Fprintf(w, "Transcoding %d files at quality %d could reclaim about **%s** of %s. ",
+		len(savings.Candidates), savings.Quality, FormatSize(savings.ReclaimableSize), FormatSize(savings.CandidateSize))
+	fmt.Fprintf(w, "The full list is in `%s` for use with `transcode --file-list`.\n\n", TranscodeCandidatesFilename)
+	fmt.Fprintf(w, "| File | Codec | Resolution | Size (MB) | Predicted (MB) | Savings (MB) |\n")
+	fmt.Fprintf(w, "|------|-------|------------|-----------|----------------|--------------|\n")
+	for i, candidate := range savings.Candidates {
+		if i == markdownSavingsLimit {
+			break
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %.1f | %.1f | %.1f |\n",
+			filepath.Base(candidate.FilePath),
+			candidate.VideoCodec,
+			candidate.Resolution,
+			float64(candidate.FileSize)/(1024*1024),
+			float64(candidate.PredictedSize)/(1024*1024),
+			float64(candidate.PredictedSavings)/(1024*1024))
+	}
+}
+
 // GenerateHTML creates an interactive HTML report
 func (rg *ReportGenerator) GenerateHTML(mediaInfos []*MediaInfo, filename string) error {
-	filePath := filepath.Join(rg.outputDir, filename)
-	file, err := os.Create(filePath)
+	return rg.writeReportFile(filename, "HTML", func(w io.Writer) error {
+		_, err := io.WriteString(w, rg.generateHTMLContent(mediaInfos))
+		return err
+	})
+}
+
+// WriteHTML writes an interactive HTML report to w, failing if the UI cannot be built
+func (rg *ReportGenerator) WriteHTML(w io.Writer, mediaInfos []*MediaInfo) error {
+	page, err := rg.renderHTML(mediaInfos)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
+	_, err = io.WriteString(w, page)
+	return err
+}
 
-	html := rg.generateHTMLContent(mediaInfos)
-	if _, err := file.WriteString(html); err != nil {
-		return err
+// generateHTMLContent renders the HTML report, or a page describing why the UI could not be built
+func (rg *ReportGenerator) generateHTMLContent(mediaInfos []*MediaInfo) string {
+	page, err := rg.renderHTML(mediaInfos)
+	if err != nil {
+		slog.Error("Failed to render HTML report", "error", err)
+		return fmt.Sprintf("<html><body><h1>Error: Failed to build UI</h1><p>%s</p></body></html>", html.EscapeString(err.Error()))
 	}
+	return page
+}
 
-	slog.Debug("HTML report generated", "path", filePath)
-	return nil
+// renderHTML builds the UI bundle around the report data and renders it into the page template
+func (rg *ReportGenerator) renderHTML(mediaInfos []*MediaInfo) (string, error) {
+	mediaData := BuildMediaData(rg.InputDir, mediaInfos)
+	if len(rg.Comparisons) > 0 {
+		comparisons := make([]TranscodeComparison, len(rg.Comparisons))
+		for i, comparison := range rg.Comparisons {
+			comparison.Before = sanitizeMediaInfo(comparison.Before)
+			comparison.After = sanitizeMediaInfo(comparison.After)
+			comparisons[i] = comparison
+		}
+		mediaData["transcodes"] = comparisons
+	}
+	if len(rg.Trends) > 1 {
+		mediaData["trends"] = rg.Trends
+	}
+	if rg.Savings != nil && len(rg.Savings.Candidates) > 0 {
+		mediaData["savings"] = rg.Savings
+	}
+	if rg.Previous != nil {
+		mediaData["previous"] = sanitizeSnapshot(rg.Previous)
+	}
+	branding := rg.Branding.ForHTML()
+	mediaData["branding"] = branding
+	if len(rg.Failures) > 0 {
+		mediaData["failedFiles"] = rg.Failures
+	}
+	if len(rg.Filters) > 0 {
+		mediaData["savedFilters"] = ApplySavedFilters(rg.Filters, mediaInfos)
+	}
+
+	// Build React bundle with esbuild
+	uiBuilder := NewUIBuilder()
+	jsBundle, err := uiBuilder.BuildReactBundle(mediaData)
+	if err != nil {
+		return "", fmt.Errorf("failed to build React bundle: %w", err)
+	}
+
+	page, err := RenderHTMLPage(branding.Title, jsBundle)
+	if err != nil {
+		return "", fmt.Errorf("failed to render HTML template: %w", err)
+	}
+	return page, nil
 }
 
-func (rg *ReportGenerator) generateHTMLContent(mediaInfos []*MediaInfo) string {
+// BuildMediaData prepares the data payload consumed by the React UI for the library at inputDir,
+// or at the files' common directory if it is empty.
+// Sorts files by path and sanitizes fields that would otherwise break rendering.
+func BuildMediaData(inputDir string, mediaInfos []*MediaInfo) map[string]interface{} {
 	// Sort by file path for consistent output
 	sort.Slice(mediaInfos, func(i, j int) bool {
 		return mediaInfos[i].FilePath < mediaInfos[j].FilePath
 	})
 
-	// Sanitize media data to ensure nil slices become empty arrays
 	sanitizedMediaInfos := make([]*MediaInfo, len(mediaInfos))
 	for i, info := range mediaInfos {
-		sanitized := *info // Copy the struct
-
-		// Ensure slices are not nil (nil becomes null in JSON, breaking React)
-		if sanitized.AudioTracks == nil {
-			sanitized.AudioTracks = []AudioTrack{}
-		}
-		if sanitized.SubtitleTracks == nil {
-			sanitized.SubtitleTracks = []SubtitleTrack{}
-		}
-
-		// Ensure string fields are not empty for critical data
-		if sanitized.VideoCodec == "" {
-			sanitized.VideoCodec = "unknown"
-		}
-
-		sanitizedMediaInfos[i] = &sanitized
+		sanitizedMediaInfos[i] = sanitizeMediaInfo(info)
 	}
 
-	// Prepare media data
-	mediaData := map[string]interface{}{
+	if inputDir == "" {
+		inputDir = getInputDir(mediaInfos)
+	}
+	return map[string]interface{}{
 		"mediaFiles":  sanitizedMediaInfos,
 		"totalFiles":  len(mediaInfos),
 		"generatedAt": time.Now().Format(time.RFC3339),
-		"inputDir":    rg.getInputDir(mediaInfos),
+		"inputDir":    inputDir,
+		"groups": map[string][]LibraryGroup{
+			"directories": GroupByDirectory(inputDir, mediaInfos),
+			"shows":       GroupByShow(mediaInfos),
+		},
 	}
+}
 
-	// Build React bundle with esbuild
-	uiBuilder := NewUIBuilder()
-	jsBundle, err := uiBuilder.BuildReactBundle(mediaData)
-	if err != nil {
-		slog.Error("Failed to build React bundle", "error", err)
-		return fmt.Sprintf("<html><body><h1>Error: Failed to build UI</h1><p>%s</p></body></html>", err.Error())
+// sanitizeMediaInfo returns a copy of info safe for the React UI.
+// Nil slices become empty arrays (nil becomes null in JSON, breaking React) and a missing codec is labeled.
+func sanitizeMediaInfo(info *MediaInfo) *MediaInfo {
+	sanitized := *info
+	if sanitized.AudioTracks == nil {
+		sanitized.AudioTracks = []AudioTrack{}
 	}
+	if sanitized.SubtitleTracks == nil {
+		sanitized.SubtitleTracks = []SubtitleTrack{}
+	}
+	if sanitized.VideoStreams == nil {
+		sanitized.VideoStreams = []VideoStream{}
+	}
+	if sanitized.VideoCodec == "" {
+		sanitized.VideoCodec = "unknown"
+	}
+	return &sanitized
+}
+
+// sanitizeSnapshot returns a copy of a snapshot whose codec counts use the same "unknown" label
+// as sanitizeMediaInfo, so the UI can compare them with the current files
+func sanitizeSnapshot(snapshot *LibrarySnapshot) *LibrarySnapshot {
+	sanitized := *snapshot
+	if count, ok := snapshot.Codecs[""]; ok {
+		sanitized.Codecs = make(map[string]int, len(snapshot.Codecs))
+		for codec, n := range snapshot.Codecs {
+			if codec != "" {
+				sanitized.Codecs[codec] = n
+			}
+		}
+		sanitized.Codecs["unknown"] += count
+	}
+	return &sanitized
+}
 
+// RenderHTMLPage wraps a compiled JavaScript bundle in the HTML report shell with the given page title
+func RenderHTMLPage(title, jsBundle string) (string, error) {
 	// Read template shell from embedded filesystem
 	templateBytes, err := templatesFS.ReadFile("templates/report-shell.html")
 	if err != nil {
-		slog.Error("Failed to read HTML template", "error", err)
-		return fmt.Sprintf("<html><body><h1>Error: Failed to load template</h1><p>%s</p></body></html>", err.Error())
+		return "", err
 	}
 
 	// Replace the placeholder with compiled JavaScript bundle
-	templateContent := string(templateBytes)
-	templateContent = strings.Replace(templateContent, "{{.JSBundle}}", jsBundle, 1)
+	templateContent := strings.Replace(string(templateBytes), "{{.Title}}", html.EscapeString(title), 1)
+	return strings.Replace(templateContent, "{{.JSBundle}}", jsBundle, 1), nil
+}
 
-	return templateContent
+// inputDir returns the library root the files are grouped by directory under
+func (rg *ReportGenerator) inputDir(mediaInfos []*MediaInfo) string {
+	if rg.InputDir != "" {
+		return rg.InputDir
+	}
+	return getInputDir(mediaInfos)
 }
 
 // getInputDir finds the common input directory from all file paths
-func (rg *ReportGenerator) getInputDir(mediaInfos []*MediaInfo) string {
+func getInputDir(mediaInfos []*MediaInfo) string {
 	if len(mediaInfos) == 0 {
 		return ""
 	}
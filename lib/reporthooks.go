@@ -0,0 +1,112 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultReportHookTimeout bounds a report hook that does not set its own timeout
+const defaultReportHookTimeout = 5 * time.Minute
+
+// ReportHook is an external command run after reports are generated, for custom formats or
+// postprocessing such as publishing to a wiki. It receives the JSON report on stdin.
+type ReportHook struct {
+	Name     string        `yaml:"name"`
+	Command  []string      `yaml:"command"`  // Program and arguments, run without a shell
+	Timeout  time.Duration `yaml:"timeout"`  // Defaults to 5m
+	Required bool          `yaml:"required"` // Fail the run if the hook fails, instead of warning
+}
+
+// validate checks that the hook has a command
+func (h ReportHook) validate() error {
+	if len(h.Command) == 0 || h.Command[0] == "" {
+		return fmt.Errorf("report hook %q must set command", h.Name)
+	}
+	return nil
+}
+
+// resolveCommand makes a relative program path such as ./hooks/notion.sh relative to the
+// directory of the config file that set it. Bare names are looked up in PATH as usual.
+func (h *ReportHook) resolveCommand(configDir string) {
+	program := h.Command[0]
+	if strings.ContainsRune(program, filepath.Separator) && !filepath.IsAbs(program) {
+		h.Command[0] = filepath.Join(configDir, program)
+	}
+}
+
+// Run executes the hook in outputDir with the JSON report on stdin. The hook's output is
+// passed through to stderr, and the generated report paths are listed in MEDIA_MGMT_REPORT_PATHS.
+func (h ReportHook) Run(ctx context.Context, report []byte, outputDir string, paths []string) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultReportHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+	absPaths := make([]string, len(paths))
+	for i, path := range paths {
+		absPaths[i] = filepath.Join(absOutputDir, filepath.Base(path))
+	}
+
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Dir = absOutputDir
+	cmd.Stdin = bytes.NewReader(report)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"MEDIA_MGMT_REPORT_DIR="+absOutputDir,
+		"MEDIA_MGMT_REPORT_PATHS="+strings.Join(absPaths, string(os.PathListSeparator)),
+	)
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("report hook %q timed out after %s", h.Name, timeout)
+		}
+		return fmt.Errorf("report hook %q failed: %w", h.Name, err)
+	}
+	return nil
+}
+
+// runHooks runs each report hook in order. Failures of optional hooks are logged;
+// the first failure of a required hook is returned. Cancelling ctx stops the running hook
+// and skips the rest.
+func (rg *ReportGenerator) runHooks(ctx context.Context, mediaInfos []*MediaInfo, paths []string) error {
+	if len(rg.Hooks) == 0 {
+		return nil
+	}
+
+	report, err := json.Marshal(rg.jsonReport(mediaInfos))
+	if err != nil {
+		return fmt.Errorf("failed to marshal report for hooks: %w", err)
+	}
+
+	for _, hook := range rg.Hooks {
+		slog.Info("Running report hook", "hook", hook.Name, "command", hook.Command[0])
+		start := time.Now()
+		if err := hook.Run(ctx, report, rg.outputDir, paths); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("report hook %q cancelled: %w", hook.Name, ctx.Err())
+			}
+			if hook.Required {
+				return err
+			}
+			slog.Warn("Report hook failed", "hook", hook.Name, "error", err)
+			continue
+		}
+		slog.Debug("Report hook finished", "hook", hook.Name, "duration_ms", time.Since(start).Milliseconds())
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportHooks(t *testing.T) {
+	dir := t.TempDir()
+	rg := NewReportGenerator(dir)
+	rg.Formats = []string{ReportFormatCSV}
+	rg.Hooks = []ReportHook{
+		{Name: "capture", Command: []string{"sh", "-c", `cat > received.json && echo "$MEDIA_MGMT_REPORT_PATHS" > paths.txt`}},
+		{Name: "optional failure", Command: []string{"sh", "-c", "exit 3"}},
+	}
+
+	mediaInfos := []*MediaInfo{{FilePath: "/media/a.mkv", VideoCodec: "h264"}}
+	if err := rg.GenerateAllReports(context.Background(), mediaInfos); err != nil {
+		t.Fatalf("GenerateAllReports failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "received.json"))
+	if err != nil {
+		t.Fatalf("Hook did not receive the report: %v", err)
+	}
+	var report struct {
+		TotalFiles int          `json:"total_files"`
+		MediaFiles []*MediaInfo `json:"media_files"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil || report.TotalFiles != 1 || report.MediaFiles[0].FilePath != "/media/a.mkv" {
+		t.Errorf("Unexpected report on stdin: %s (%v)", data, err)
+	}
+
+	paths, err := os.ReadFile(filepath.Join(dir, "paths.txt"))
+	if err != nil || !strings.HasSuffix(strings.TrimSpace(string(paths)), ".csv") || !filepath.IsAbs(string(paths)) {
+		t.Errorf("Expected the absolute CSV path in MEDIA_MGMT_REPORT_PATHS, got %q (%v)", paths, err)
+	}
+}
+
+func TestReportHookRequiredFailure(t *testing.T) {
+	rg := NewReportGenerator(t.TempDir())
+	rg.Formats = []string{ReportFormatCSV}
+	rg.Hooks = []ReportHook{{Name: "publish", Command: []string{"sh", "-c", "exit 1"}, Required: true}}
+
+	err := rg.GenerateAllReports(context.Background(), []*MediaInfo{{FilePath: "/media/a.mkv"}})
+	if err == nil || !strings.Contains(err.Error(), `"publish"`) {
+		t.Errorf("Expected the required hook failure, got %v", err)
+	}
+}
+
+func TestReportHookTimeout(t *testing.T) {
+	hook := ReportHook{Name: "slow", Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond}
+	err := hook.Run(context.Background(), nil, t.TempDir(), nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+}
+
+func TestLoadConfigReportHooks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	data := "report_hooks:\n  - command: [./hooks/notion.sh, --db, media]\n    timeout: 2m\n  - name: wiki\n    command: [publish-wiki]\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(config.Hooks) != 2 {
+		t.Fatalf("Expected 2 hooks, got %+v", config.Hooks)
+	}
+	if hook := config.Hooks[0]; hook.Name != "hook-1" || hook.Command[0] != filepath.Join(dir, "hooks", "notion.sh") || hook.Timeout != 2*time.Minute {
+		t.Errorf("Unexpected first hook: %+v", hook)
+	}
+	if hook := config.Hooks[1]; hook.Command[0] != "publish-wiki" {
+		t.Errorf("Expected PATH command to be left alone, got %+v", hook)
+	}
+
+	if err := os.WriteFile(path, []byte("report_hooks:\n  - name: empty\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("Expected an error for a hook without a command")
+	}
+}
+
+func TestReportHooksCancelled(t *testing.T) {
+	dir := t.TempDir()
+	rg := NewReportGenerator(dir)
+	rg.Formats = []string{ReportFormatCSV}
+	rg.Hooks = []ReportHook{
+		{Name: "slow", Command: []string{"sleep", "5"}},
+		{Name: "after", Command: []string{"touch", "after"}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err := rg.GenerateAllReports(ctx, []*MediaInfo{{FilePath: "/media/a.mkv"}})
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Errorf("Expected the hooks to be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Cancelled hook ran for %s", elapsed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "after")); err == nil {
+		t.Error("Expected hooks after the cancelled one to be skipped")
+	}
+}
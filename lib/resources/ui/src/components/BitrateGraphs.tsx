@@ -0,0 +1,80 @@
+import type { MediaFile, BitrateGraph } from '../types/media'
+import { getDisplayPath } from '../utils/pathUtils'
+
+interface BitrateGraphsProps {
+  readonly mediaFiles: readonly MediaFile[]
+  readonly inputDir?: string
+}
+
+const GRAPH_WIDTH = 720
+const GRAPH_HEIGHT = 120
+
+const formatMbps = (bitrate: number): string => {
+  return (bitrate / 1000000).toFixed(1)
+}
+
+const formatOffset = (seconds: number): string => {
+  const h = Math.floor(seconds / 3600)
+  const m = Math.floor((seconds % 3600) / 60)
+  const s = Math.floor(seconds % 60)
+  return h > 0 ? `${h}:${String(m).padStart(2, '0')}:${String(s).padStart(2, '0')}` : `${m}:${String(s).padStart(2, '0')}`
+}
+
+const BitrateChart = ({ graph, average }: { readonly graph: BitrateGraph, readonly average: number }): JSX.Element => {
+  const peak = Math.max(...graph.bitrates, average, 1)
+  const step = graph.bitrates.length > 1 ? GRAPH_WIDTH / (graph.bitrates.length - 1) : GRAPH_WIDTH
+  const y = (bitrate: number): number => GRAPH_HEIGHT - (bitrate / peak) * GRAPH_HEIGHT
+  const points = graph.bitrates.map((bitrate, i) => `${(i * step).toFixed(1)},${y(bitrate).toFixed(1)}`).join(' ')
+  const area = `0,${GRAPH_HEIGHT} ${points} ${((graph.bitrates.length - 1) * step).toFixed(1)},${GRAPH_HEIGHT}`
+
+  return (
+    <svg viewBox={`0 0 ${GRAPH_WIDTH} ${GRAPH_HEIGHT}`} className="w-full h-32" preserveAspectRatio="none">
+      <polygon points={area} className="fill-blue-100" />
+      <polyline points={points} className="fill-none stroke-blue-600" strokeWidth="1.5" vectorEffect="non-scaling-stroke" />
+      <line
+        x1="0" x2={GRAPH_WIDTH} y1={y(average)} y2={y(average)}
+        className="stroke-gray-400" strokeDasharray="4 4" vectorEffect="non-scaling-stroke"
+      >
+        <title>Average {formatMbps(average)} Mbps</title>
+      </line>
+      {graph.bitrates.map((bitrate, i) => (
+        <rect key={i} x={i * step - step / 2} y="0" width={step} height={GRAPH_HEIGHT} className="fill-transparent">
+          <title>{formatOffset(i * graph.interval_seconds)}: {formatMbps(bitrate)} Mbps</title>
+        </rect>
+      ))}
+    </svg>
+  )
+}
+
+export const BitrateGraphs = ({ mediaFiles, inputDir }: BitrateGraphsProps): JSX.Element | null => {
+  const graphed = mediaFiles.filter(item => item.bitrate_graph != null && item.bitrate_graph.bitrates.length > 0)
+  if (graphed.length === 0) {
+    return null
+  }
+
+  return (
+    <div className="px-6 py-8 border-b border-gray-200">
+      <h2 className="text-xl font-bold text-gray-900 mb-1">Bitrate Over Time</h2>
+      <p className="text-sm text-gray-600 mb-4">
+        Video bitrate measured from every packet. Dips can mark starved, poorly encoded sections; spikes show where VBR spent its bits.
+      </p>
+      <div className="space-y-6">
+        {graphed.map(item => {
+          const graph = item.bitrate_graph as BitrateGraph
+          const peak = Math.max(...graph.bitrates)
+          return (
+            <div key={item.file_path}>
+              <div className="flex flex-wrap justify-between gap-2 text-sm mb-1">
+                <span className="font-medium text-gray-900 break-all">{getDisplayPath(item.file_path, true, inputDir)}</span>
+                <span className="text-gray-600 whitespace-nowrap">
+                  avg {formatMbps(item.video_bitrate)} · peak {formatMbps(peak)} Mbps · {graph.interval_seconds}s per point
+                </span>
+              </div>
+              <BitrateChart graph={graph} average={item.video_bitrate} />
+            </div>
+          )
+        })}
+      </div>
+    </div>
+  )
+}
@@ -1,5 +1,5 @@
 import type { MediaFile, ColumnVisibility, SortableColumn, SortConfig } from '../types/media'
-import { formatFileSize, formatDuration, formatAudioTracks, formatSubtitleTracks } from '../utils/formatters'
+import { formatFileSize, formatDuration, formatAudioTracks, formatAudioSidecars, formatSubtitleTracks, formatVideoStreams, formatHDR, formatProvenance, formatDate } from '../utils/formatters'
 import { getDisplayPath } from '../utils/pathUtils'
 
 interface DataTableProps {
@@ -115,6 +115,30 @@ export const DataTable = ({
                 Pixel Format{getSortIcon('pixelFormat', sortConfig)}
               </th>
             )}
+            {columnVisibility.frameRate && (
+              <th
+                onClick={() => { handleSort('frameRate') }}
+                className="px-6 py-3 text-right text-xs font-medium text-gray-500 uppercase tracking-wider cursor-pointer hover:bg-gray-100 select-none"
+              >
+                FPS{getSortIcon('frameRate', sortConfig)}
+              </th>
+            )}
+            {columnVisibility.bitDepth && (
+              <th
+                onClick={() => { handleSort('bitDepth') }}
+                className="px-6 py-3 text-right text-xs font-medium text-gray-500 uppercase tracking-wider cursor-pointer hover:bg-gray-100 select-none"
+              >
+                Bit Depth{getSortIcon('bitDepth', sortConfig)}
+              </th>
+            )}
+            {columnVisibility.scanType && (
+              <th
+                onClick={() => { handleSort('scanType') }}
+                className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider cursor-pointer hover:bg-gray-100 select-none"
+              >
+                Scan{getSortIcon('scanType', sortConfig)}
+              </th>
+            )}
             {columnVisibility.colorInfo && (
               <th
                 onClick={() => { handleSort('colorInfo') }}
@@ -139,6 +163,22 @@ export const DataTable = ({
                 Subtitle Tracks{getSortIcon('subtitleTracks', sortConfig)}
               </th>
             )}
+            {columnVisibility.videoStreams && (
+              <th
+                onClick={() => { handleSort('videoStreams') }}
+                className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider cursor-pointer hover:bg-gray-100 select-none"
+              >
+                Video Streams{getSortIcon('videoStreams', sortConfig)}
+              </th>
+            )}
+            {columnVisibility.provenance && (
+              <th
+                onClick={() => { handleSort('provenance') }}
+                className="px-6 py-3 text-left text-xs font-medium text-gray-500 uppercase tracking-wider cursor-pointer hover:bg-gray-100 select-none"
+              >
+                Provenance{getSortIcon('provenance', sortConfig)}
+              </th>
+            )}
           </tr>
         </thead>
         <tbody className="bg-white divide-y divide-gray-200">
@@ -150,6 +190,23 @@ export const DataTable = ({
                   title={item.file_path}
                 >
                   {getDisplayPath(item.file_path, showRelativePaths, inputDir)}
+                  {item.archived_to && (
+                    <span
+                      className="ml-2 px-2 py-0.5 rounded bg-gray-200 text-gray-700 text-xs font-sans"
+                      title={`Archived to ${item.archived_to}`}
+                    >
+                      Archived
+                    </span>
+                  )}
+                  {item.thumbnails != null && item.thumbnails.length > 0 && (
+                    <div className="mt-2 flex gap-1">
+                      {item.thumbnails.map(src => (
+                        <a key={src} href={src} target="_blank" rel="noreferrer">
+                          <img src={src} loading="lazy" alt="" className="h-12 rounded border border-gray-200" />
+                        </a>
+                      ))}
+                    </div>
+                  )}
                 </td>
               )}
               {columnVisibility.size && (
@@ -172,6 +229,15 @@ export const DataTable = ({
               {columnVisibility.bitrate && (
                 <td className="px-6 py-4 text-sm text-gray-900 text-right">
                   {(item.video_bitrate / 1000000).toFixed(1)}
+                  {item.bitrate_stats && (
+                    <div
+                      className="text-xs text-gray-500"
+                      title="Measured from packets: one-second peak and coefficient of variation"
+                    >
+                      peak {(item.bitrate_stats.video_peak_bitrate / 1000000).toFixed(1)} · CV{' '}
+                      {item.bitrate_stats.video_variability.toFixed(2)}
+                    </div>
+                  )}
                 </td>
               )}
               {columnVisibility.resolution && (
@@ -194,17 +260,42 @@ export const DataTable = ({
                   {item.pixel_format || 'N/A'}
                 </td>
               )}
+              {columnVisibility.frameRate && (
+                <td className="px-6 py-4 text-sm text-gray-900 text-right">
+                  {item.frame_rate != null && item.frame_rate > 0 ? item.frame_rate.toFixed(3) : 'N/A'}
+                  {item.is_vfr && (
+                    <span
+                      className="ml-1 inline-flex items-center px-1.5 py-0.5 rounded text-xs font-medium bg-orange-100 text-orange-800"
+                      title="Variable frame rate: convert with transcode --cfr-convert before editing"
+                    >
+                      VFR
+                    </span>
+                  )}
+                </td>
+              )}
+              {columnVisibility.bitDepth && (
+                <td className="px-6 py-4 text-sm text-gray-900 text-right">
+                  {item.bit_depth != null && item.bit_depth > 0 ? item.bit_depth : 'N/A'}
+                </td>
+              )}
+              {columnVisibility.scanType && (
+                <td className="px-6 py-4 text-sm text-gray-900">
+                  {item.scan_type ?? 'N/A'}
+                </td>
+              )}
               {columnVisibility.colorInfo && (
                 <td className="px-6 py-4 text-sm text-gray-900">
                   <div className="flex flex-col">
-                    {item.color_transfer === 'smpte2084' && (
-                      <span className="inline-flex items-center px-1.5 py-0.5 rounded text-xs font-medium bg-yellow-100 text-yellow-800 mb-1">
-                        HDR10
-                      </span>
-                    )}
-                    {item.has_dolby_vision && (
-                      <span className="inline-flex items-center px-1.5 py-0.5 rounded text-xs font-medium bg-purple-100 text-purple-800 mb-1">
-                        Dolby Vision
+                    {item.hdr && (
+                      <span
+                        className={`inline-flex items-center px-1.5 py-0.5 rounded text-xs font-medium mb-1 ${
+                          item.hdr.format === 'dolby_vision'
+                            ? 'bg-purple-100 text-purple-800'
+                            : 'bg-yellow-100 text-yellow-800'
+                        }`}
+                        title={item.hdr.max_cll ? `MaxCLL ${item.hdr.max_cll}, MaxFALL ${item.hdr.max_fall ?? 0} cd/m²` : undefined}
+                      >
+                        {formatHDR(item.hdr)}
                       </span>
                     )}
                     {item.is_vbr && (
@@ -220,6 +311,11 @@ export const DataTable = ({
                   <span className="font-mono text-xs">
                     {formatAudioTracks(item.audio_tracks)}
                   </span>
+                  {item.audio_sidecars != null && item.audio_sidecars.length > 0 && (
+                    <div className="font-mono text-xs text-gray-500" title={item.audio_sidecars.map(s => s.path).join('\n')}>
+                      {formatAudioSidecars(item.audio_sidecars)}
+                    </div>
+                  )}
                 </td>
               )}
               {columnVisibility.subtitleTracks && (
@@ -229,6 +325,28 @@ export const DataTable = ({
                   </span>
                 </td>
               )}
+              {columnVisibility.videoStreams && (
+                <td className="px-6 py-4 text-sm text-gray-900">
+                  <span className="font-mono text-xs">
+                    {formatVideoStreams(item.video_streams ?? [])}
+                  </span>
+                </td>
+              )}
+              {columnVisibility.provenance && (
+                <td
+                  className="px-6 py-4 text-sm text-gray-900"
+                  title={[
+                    item.container?.title,
+                    item.container?.video_encoder,
+                    item.container?.encoding_settings,
+                    item.container?.creation_time != null ? `Created ${formatDate(item.container.creation_time)}` : undefined
+                  ].filter(line => line != null && line !== '').join('\n')}
+                >
+                  <span className="font-mono text-xs">
+                    {formatProvenance(item.container)}
+                  </span>
+                </td>
+              )}
             </tr>
           ))}
         </tbody>
@@ -0,0 +1,28 @@
+interface DeltaBadgeProps {
+  readonly current: number
+  readonly previous?: number
+  readonly format?: (delta: number) => string
+}
+
+// DeltaBadge shows the change from the previous run as a ▲/▼ badge, or nothing when unchanged or unknown
+export const DeltaBadge = ({ current, previous, format = String }: DeltaBadgeProps): JSX.Element | null => {
+  if (previous == null) {
+    return null
+  }
+  const delta = current - previous
+  const formatted = format(Math.abs(delta))
+  if (delta === 0 || parseFloat(formatted) === 0) {
+    return null
+  }
+
+  const increased = delta > 0
+  return (
+    <span
+      className={`inline-flex items-center ml-1 px-1.5 py-0.5 rounded text-xs font-medium ${
+        increased ? 'bg-amber-100 text-amber-800' : 'bg-emerald-100 text-emerald-800'
+      }`}
+    >
+      {increased ? '▲' : '▼'} {formatted}
+    </span>
+  )
+}
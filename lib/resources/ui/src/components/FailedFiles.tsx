@@ -0,0 +1,42 @@
+import type { FileFailure } from '../types/media'
+import { getDisplayPath } from '../utils/pathUtils'
+
+interface FailedFilesProps {
+  readonly failures: readonly FileFailure[]
+  readonly inputDir?: string
+}
+
+export const FailedFiles = ({ failures, inputDir }: FailedFilesProps): JSX.Element | null => {
+  if (failures.length === 0) {
+    return null
+  }
+
+  return (
+    <div className="px-6 py-8 border-b border-gray-200 bg-red-50">
+      <h2 className="text-xl font-bold text-red-900 mb-1">Failed Files</h2>
+      <p className="text-sm text-red-800 mb-4">
+        {failures.length} {failures.length === 1 ? 'file' : 'files'} could not be analyzed and {failures.length === 1 ? 'is' : 'are'} missing from this report.
+      </p>
+      <div className="overflow-x-auto">
+        <table className="min-w-full divide-y divide-red-200">
+          <thead>
+            <tr className="text-left text-xs font-medium text-red-700 uppercase tracking-wider">
+              <th className="px-4 py-2">File</th>
+              <th className="px-4 py-2">Stage</th>
+              <th className="px-4 py-2">Error</th>
+            </tr>
+          </thead>
+          <tbody className="divide-y divide-red-100">
+            {failures.map(failure => (
+              <tr key={failure.file}>
+                <td className="px-4 py-2 text-sm text-gray-900 break-all">{getDisplayPath(failure.file, true, inputDir)}</td>
+                <td className="px-4 py-2 text-sm text-gray-600">{failure.stage ?? ''}</td>
+                <td className="px-4 py-2 text-sm text-gray-600 break-words">{failure.error}</td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+    </div>
+  )
+}
@@ -0,0 +1,106 @@
+import { useState } from 'react'
+import type { LibraryGroup, LibraryGroups } from '../types/media'
+import { formatTotalSize, formatTotalDuration } from '../utils/formatters'
+
+interface LibraryGroupsProps {
+  readonly groups?: LibraryGroups
+}
+
+type GroupView = 'directories' | 'shows'
+
+const viewLabels: { readonly [view in GroupView]: string } = {
+  directories: 'By Directory',
+  shows: 'By Show'
+}
+
+const collapsedRows = 10
+
+const GroupTable = ({ groups }: { readonly groups: readonly LibraryGroup[] }): JSX.Element => {
+  const [expanded, setExpanded] = useState(false)
+  const totalSize = groups.reduce((sum, g) => sum + g.total_size, 0)
+  const visible = expanded ? groups : groups.slice(0, collapsedRows)
+
+  return (
+    <>
+      <table className="min-w-full divide-y divide-gray-200">
+        <thead>
+          <tr className="text-left text-xs font-medium text-gray-500 uppercase tracking-wider">
+            <th className="px-4 py-2">Name</th>
+            <th className="px-4 py-2 text-right">Files</th>
+            <th className="px-4 py-2 text-right">Size (GB)</th>
+            <th className="px-4 py-2 w-1/4">Share</th>
+            <th className="px-4 py-2 text-right">Duration (h)</th>
+            <th className="px-4 py-2 text-right">Avg Bitrate</th>
+          </tr>
+        </thead>
+        <tbody className="divide-y divide-gray-100">
+          {visible.map(group => {
+            const share = totalSize > 0 ? (group.total_size / totalSize) * 100 : 0
+            return (
+              <tr key={group.name}>
+                <td className="px-4 py-2 text-sm text-gray-900">{group.name}</td>
+                <td className="px-4 py-2 text-sm text-right text-gray-600">{group.files}</td>
+                <td className="px-4 py-2 text-sm text-right text-gray-900">{formatTotalSize(group.total_size)}</td>
+                <td className="px-4 py-2">
+                  <div className="flex items-center gap-2">
+                    <div className="flex-1 bg-gray-100 rounded h-2">
+                      <div className="bg-blue-500 rounded h-2" style={{ width: `${share}%` }} />
+                    </div>
+                    <span className="text-xs text-gray-500 w-10 text-right">{share.toFixed(0)}%</span>
+                  </div>
+                </td>
+                <td className="px-4 py-2 text-sm text-right text-gray-600">{formatTotalDuration(group.total_duration)}</td>
+                <td className="px-4 py-2 text-sm text-right text-gray-600">
+                  {group.avg_bitrate != null ? `${(group.avg_bitrate / 1000000).toFixed(1)} Mbps` : '—'}
+                </td>
+              </tr>
+            )
+          })}
+        </tbody>
+      </table>
+      {groups.length > collapsedRows && (
+        <button
+          className="mt-2 text-sm text-blue-600 hover:text-blue-800"
+          onClick={() => { setExpanded(!expanded) }}
+        >
+          {expanded ? 'Show fewer' : `Show all ${groups.length}`}
+        </button>
+      )}
+    </>
+  )
+}
+
+export const LibraryGroupsView = ({ groups }: LibraryGroupsProps): JSX.Element | null => {
+  const [view, setView] = useState<GroupView>('directories')
+  if (groups == null) {
+    return null
+  }
+
+  const available = (Object.keys(viewLabels) as GroupView[]).filter(v => groups[v].length > 0)
+  if (available.length === 0) {
+    return null
+  }
+  const current = available.includes(view) ? view : available[0] ?? 'directories'
+
+  return (
+    <div className="px-6 py-8 border-b border-gray-200">
+      <div className="flex justify-between items-baseline mb-4">
+        <h2 className="text-xl font-bold text-gray-900">Library Breakdown</h2>
+        <div className="flex gap-2">
+          {available.map(v => (
+            <button
+              key={v}
+              className={`px-3 py-1 text-sm rounded-md ${v === current ? 'bg-blue-600 text-white' : 'bg-gray-100 text-gray-700 hover:bg-gray-200'}`}
+              onClick={() => { setView(v) }}
+            >
+              {viewLabels[v]}
+            </button>
+          ))}
+        </div>
+      </div>
+      <div className="overflow-x-auto">
+        <GroupTable key={current} groups={groups[current]} />
+      </div>
+    </div>
+  )
+}
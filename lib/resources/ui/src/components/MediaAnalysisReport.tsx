@@ -10,10 +10,18 @@ import { PageSizeSelector } from './PageSizeSelector'
 import { DataTable } from './DataTable'
 import { Pagination } from './Pagination'
 import { Footer } from './Footer'
+import { TranscodeComparisons } from './TranscodeComparisons'
+import { Trends } from './Trends'
+import { LibraryGroupsView } from './LibraryGroups'
+import { SavingsOpportunities } from './SavingsOpportunities'
+import { SavedFilters } from './SavedFilters'
+import { BitrateGraphs } from './BitrateGraphs'
+import { FailedFiles } from './FailedFiles'
 
 export const MediaAnalysisReport = (): JSX.Element => {
   const data = useMediaData()
   const [searchTerm, setSearchTerm] = useState('')
+  const [selectedView, setSelectedView] = useState<string | null>(null)
   const [sortConfig, setSortConfig] = useState<SortConfig>({ key: null, direction: 'asc' })
   const [showRelativePaths, setShowRelativePaths] = useState(false)
   const [columnVisibility, setColumnVisibility] = useState<ColumnVisibility>({
@@ -26,16 +34,29 @@ export const MediaAnalysisReport = (): JSX.Element => {
     videoProfile: false,
     videoLevel: false,
     pixelFormat: false,
+    frameRate: true,
+    bitDepth: false,
+    scanType: false,
     colorInfo: false,
     audioTracks: true,
-    subtitleTracks: true
+    subtitleTracks: true,
+    videoStreams: false,
+    provenance: false
   })
   const [showColumnMenu, setShowColumnMenu] = useState(false)
   const [currentPage, setCurrentPage] = useState(1)
   const [pageSize, setPageSize] = useState(10)
 
+  const viewMatches = useMemo(() => {
+    const view = data.savedFilters?.find(filter => filter.name === selectedView)
+    return view != null ? new Set(view.matches) : null
+  }, [data.savedFilters, selectedView])
+
   const filteredAndSortedData = useMemo(() => {
     const filtered = data.mediaFiles.filter(item => {
+      if (viewMatches != null && !viewMatches.has(item.file_path)) {
+        return false
+      }
       const searchLower = searchTerm.toLowerCase()
       return (
         item.file_path.toLowerCase().includes(searchLower) ||
@@ -44,7 +65,7 @@ export const MediaAnalysisReport = (): JSX.Element => {
     })
 
     return sortMediaFiles(filtered, sortConfig, showRelativePaths, data.inputDir)
-  }, [data.mediaFiles, searchTerm, sortConfig, showRelativePaths])
+  }, [data.mediaFiles, viewMatches, searchTerm, sortConfig, showRelativePaths])
 
   const paginatedData = useMemo(() => {
     const startIndex = (currentPage - 1) * pageSize
@@ -54,10 +75,10 @@ export const MediaAnalysisReport = (): JSX.Element => {
 
   const totalPages = Math.ceil(filteredAndSortedData.length / pageSize)
 
-  // Reset to page 1 when search term changes
+  // Reset to page 1 when search term or view changes
   useEffect(() => {
     setCurrentPage(1)
-  }, [searchTerm])
+  }, [searchTerm, selectedView])
 
   const handlePageSizeChange = (newPageSize: number): void => {
     setPageSize(newPageSize)
@@ -84,6 +105,20 @@ export const MediaAnalysisReport = (): JSX.Element => {
         <div className="bg-white shadow-xl rounded-lg overflow-hidden">
           <SummaryCards data={data} />
 
+          <Trends trends={data.trends ?? []} />
+
+          <LibraryGroupsView groups={data.groups} />
+
+          <SavingsOpportunities savings={data.savings} inputDir={data.inputDir} />
+
+          <TranscodeComparisons transcodes={data.transcodes ?? []} inputDir={data.inputDir} />
+
+          <BitrateGraphs mediaFiles={data.mediaFiles} inputDir={data.inputDir} />
+
+          <FailedFiles failures={data.failedFiles ?? []} inputDir={data.inputDir} />
+
+          <SavedFilters filters={data.savedFilters} selected={selectedView} onSelect={setSelectedView} />
+
           <div className="px-6 py-4 bg-gray-50 border-b border-gray-200">
             <div className="flex flex-col sm:flex-row gap-4 items-start sm:items-center justify-between">
               <SearchBar searchTerm={searchTerm} onSearchChange={setSearchTerm} />
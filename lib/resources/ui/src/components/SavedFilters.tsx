@@ -0,0 +1,43 @@
+import type { SavedFilterView } from '../types/media'
+
+interface SavedFiltersProps {
+  readonly filters?: readonly SavedFilterView[]
+  readonly selected: string | null
+  readonly onSelect: (name: string | null) => void
+}
+
+const buttonClass = (active: boolean): string =>
+  `inline-flex items-center px-3 py-1 rounded-full text-sm font-medium border ${
+    active
+      ? 'bg-blue-600 border-blue-600 text-white'
+      : 'bg-white border-gray-300 text-gray-700 hover:bg-gray-100'
+  }`
+
+export const SavedFilters = ({ filters, selected, onSelect }: SavedFiltersProps): JSX.Element | null => {
+  if (filters == null || filters.length === 0) {
+    return null
+  }
+
+  return (
+    <div className="px-6 py-3 bg-white border-b border-gray-200">
+      <div className="flex flex-wrap items-center gap-2">
+        <span className="text-sm font-medium text-gray-500 mr-1">Views:</span>
+        <button type="button" className={buttonClass(selected === null)} onClick={() => { onSelect(null) }}>
+          All files
+        </button>
+        {filters.map(filter => (
+          <button
+            key={filter.name}
+            type="button"
+            className={buttonClass(selected === filter.name)}
+            title={filter.description != null && filter.description !== '' ? `${filter.description}\n${filter.expression}` : filter.expression}
+            onClick={() => { onSelect(selected === filter.name ? null : filter.name) }}
+          >
+            {filter.name}
+            <span className="ml-2 text-xs opacity-75">{filter.matches.length}</span>
+          </button>
+        ))}
+      </div>
+    </div>
+  )
+}
@@ -0,0 +1,75 @@
+import { useState } from 'react'
+import type { SavingsReport } from '../types/media'
+import { formatFileSize, formatTotalSize } from '../utils/formatters'
+import { getDisplayPath } from '../utils/pathUtils'
+
+interface SavingsOpportunitiesProps {
+  readonly savings?: SavingsReport
+  readonly inputDir?: string
+}
+
+const collapsedRows = 10
+
+export const SavingsOpportunities = ({ savings, inputDir }: SavingsOpportunitiesProps): JSX.Element | null => {
+  const [expanded, setExpanded] = useState(false)
+  if (savings == null || savings.candidates.length === 0) {
+    return null
+  }
+
+  const visible = expanded ? savings.candidates : savings.candidates.slice(0, collapsedRows)
+  const reclaimedPercent = savings.candidate_size > 0 ? (savings.reclaimable_size / savings.candidate_size) * 100 : 0
+
+  return (
+    <div className="px-6 py-8 border-b border-gray-200">
+      <div className="flex justify-between items-baseline mb-2">
+        <h2 className="text-xl font-bold text-gray-900">Savings Opportunities</h2>
+        <span className="text-sm text-gray-600">
+          at quality {savings.quality}
+        </span>
+      </div>
+      <p className="text-sm text-gray-600 mb-4">
+        Transcoding <span className="font-medium text-gray-900">{savings.candidates.length}</span> files could reclaim
+        about <span className="font-medium text-green-700">{formatTotalSize(savings.reclaimable_size)} GB</span> of{' '}
+        {formatTotalSize(savings.candidate_size)} GB ({reclaimedPercent.toFixed(0)}%). The full list is written
+        to <code className="text-xs bg-gray-100 px-1 rounded">transcode-candidates.txt</code> for use
+        with <code className="text-xs bg-gray-100 px-1 rounded">transcode --file-list</code>.
+      </p>
+      <div className="overflow-x-auto">
+        <table className="min-w-full divide-y divide-gray-200">
+          <thead>
+            <tr className="text-left text-xs font-medium text-gray-500 uppercase tracking-wider">
+              <th className="px-4 py-2">File</th>
+              <th className="px-4 py-2">Codec</th>
+              <th className="px-4 py-2">Resolution</th>
+              <th className="px-4 py-2 text-right">Bits/Pixel</th>
+              <th className="px-4 py-2 text-right">Size (MB)</th>
+              <th className="px-4 py-2 text-right">Predicted (MB)</th>
+              <th className="px-4 py-2 text-right">Savings (MB)</th>
+            </tr>
+          </thead>
+          <tbody className="divide-y divide-gray-100">
+            {visible.map(candidate => (
+              <tr key={candidate.file_path}>
+                <td className="px-4 py-2 text-sm text-gray-900 break-all">{getDisplayPath(candidate.file_path, true, inputDir)}</td>
+                <td className="px-4 py-2 text-sm text-gray-600">{candidate.video_codec}</td>
+                <td className="px-4 py-2 text-sm text-gray-600">{candidate.resolution}</td>
+                <td className="px-4 py-2 text-sm text-right text-gray-600">{candidate.bits_per_pixel.toFixed(2)}</td>
+                <td className="px-4 py-2 text-sm text-right text-gray-600">{formatFileSize(candidate.file_size)}</td>
+                <td className="px-4 py-2 text-sm text-right text-gray-600">{formatFileSize(candidate.predicted_size)}</td>
+                <td className="px-4 py-2 text-sm text-right font-medium text-green-700">{formatFileSize(candidate.predicted_savings)}</td>
+              </tr>
+            ))}
+          </tbody>
+        </table>
+      </div>
+      {savings.candidates.length > collapsedRows && (
+        <button
+          className="mt-2 text-sm text-blue-600 hover:text-blue-800"
+          onClick={() => { setExpanded(!expanded) }}
+        >
+          {expanded ? 'Show fewer' : `Show all ${savings.candidates.length}`}
+        </button>
+      )}
+    </div>
+  )
+}
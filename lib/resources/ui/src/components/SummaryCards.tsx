@@ -1,5 +1,6 @@
 import type { MediaData, CodecCounts } from '../types/media'
-import { formatTotalSize, formatTotalDuration } from '../utils/formatters'
+import { formatTotalSize, formatTotalDuration, formatDate } from '../utils/formatters'
+import { DeltaBadge } from './DeltaBadge'
 
 interface SummaryCardsProps {
   readonly data: MediaData
@@ -14,26 +15,43 @@ export const SummaryCards = ({ data }: SummaryCardsProps): JSX.Element => {
     return { ...acc, [item.video_codec]: count + 1 }
   }, {})
 
+  // Codecs that disappeared since the previous run are listed with zero files so their drop shows
+  const previous = data.previous
+  const codecs = Object.keys({ ...previous?.codecs, ...codecCounts })
+
   return (
     <div className="px-6 py-8 border-b border-gray-200">
+      {data.branding?.logo != null && data.branding.logo !== '' && (
+        <img src={data.branding.logo} alt="Logo" className="mx-auto mb-4 max-h-16" />
+      )}
       <h1 className="text-3xl font-bold text-gray-900 text-center mb-6">
-        Media Analysis Report
+        {data.branding?.title ?? 'Media Analysis Report'}
       </h1>
+      {data.branding?.notes != null && data.branding.notes !== '' && (
+        <p className="max-w-3xl mx-auto -mt-3 mb-6 text-center text-gray-600 whitespace-pre-line">
+          {data.branding.notes}
+        </p>
+      )}
 
       <div className="grid grid-cols-1 md:grid-cols-3 gap-6 mb-6">
         <div className="bg-blue-50 rounded-lg p-4 text-center">
-          <div className="text-2xl font-bold text-blue-600">{data.totalFiles}</div>
+          <div className="text-2xl font-bold text-blue-600">
+            {data.totalFiles}
+            <DeltaBadge current={data.totalFiles} previous={previous?.files} />
+          </div>
           <div className="text-sm text-gray-600">Total Files</div>
         </div>
         <div className="bg-green-50 rounded-lg p-4 text-center">
           <div className="text-2xl font-bold text-green-600">
             {formatTotalSize(totalSize)} GB
+            <DeltaBadge current={totalSize} previous={previous?.total_size} format={delta => `${formatTotalSize(delta)} GB`} />
           </div>
           <div className="text-sm text-gray-600">Total Size</div>
         </div>
         <div className="bg-purple-50 rounded-lg p-4 text-center">
           <div className="text-2xl font-bold text-purple-600">
             {formatTotalDuration(totalDuration)} hrs
+            <DeltaBadge current={totalDuration} previous={previous?.total_duration} format={delta => `${formatTotalDuration(delta)} hrs`} />
           </div>
           <div className="text-sm text-gray-600">Total Duration</div>
         </div>
@@ -42,15 +60,19 @@ export const SummaryCards = ({ data }: SummaryCardsProps): JSX.Element => {
       <div className="bg-gray-50 rounded-lg p-4 mb-6">
         <h3 className="text-sm font-medium text-gray-700 mb-2">Video Codecs</h3>
         <div className="flex flex-wrap gap-2">
-          {Object.entries(codecCounts).map(([codec, count]) => (
+          {codecs.map(codec => (
             <span
               key={codec}
               className="inline-flex items-center px-2.5 py-0.5 rounded-full text-xs font-medium bg-blue-100 text-blue-800"
             >
-              {codec}: {count} files
+              {codec}: {codecCounts[codec] ?? 0} files
+              <DeltaBadge current={codecCounts[codec] ?? 0} previous={previous == null ? undefined : previous.codecs[codec] ?? 0} />
             </span>
           ))}
         </div>
+        {previous != null && (
+          <p className="mt-2 text-xs text-gray-500">Changes since the previous run on {formatDate(previous.timestamp)}</p>
+        )}
       </div>
     </div>
   )
@@ -0,0 +1,144 @@
+import type { TranscodeComparison } from '../types/media'
+import { formatFileSize, formatTotalSize, formatAudioTracks } from '../utils/formatters'
+import { getDisplayPath } from '../utils/pathUtils'
+
+interface TranscodeComparisonsProps {
+  readonly transcodes: readonly TranscodeComparison[]
+  readonly inputDir?: string
+}
+
+interface CodecSavings {
+  readonly label: string
+  readonly files: number
+  readonly before: number
+  readonly after: number
+}
+
+const savedPercent = (before: number, after: number): number => {
+  return before > 0 ? (1 - after / before) * 100 : 0
+}
+
+const formatMbps = (bitrate: number): string => {
+  return (bitrate / 1000000).toFixed(1)
+}
+
+const groupByCodecChange = (transcodes: readonly TranscodeComparison[]): CodecSavings[] => {
+  const groups = new Map<string, CodecSavings>()
+  for (const t of transcodes) {
+    const label = `${t.before.video_codec} → ${t.after.video_codec}`
+    const group = groups.get(label) ?? { label, files: 0, before: 0, after: 0 }
+    groups.set(label, {
+      label,
+      files: group.files + 1,
+      before: group.before + t.before.file_size,
+      after: group.after + t.after.file_size
+    })
+  }
+  return Array.from(groups.values()).sort((a, b) => (b.before - b.after) - (a.before - a.after))
+}
+
+const ChangeCell = ({ before, after, unit }: { readonly before: string, readonly after: string, readonly unit?: string }): JSX.Element => {
+  const changed = before !== after
+  return (
+    <td className="px-4 py-2 text-sm text-right whitespace-nowrap">
+      <span className="text-gray-500">{before}</span>
+      <span className="text-gray-400"> → </span>
+      <span className={changed ? 'font-medium text-gray-900' : 'text-gray-500'}>{after}</span>
+      {unit != null && <span className="text-gray-400"> {unit}</span>}
+    </td>
+  )
+}
+
+export const TranscodeComparisons = ({ transcodes, inputDir }: TranscodeComparisonsProps): JSX.Element | null => {
+  if (transcodes.length === 0) {
+    return null
+  }
+
+  const totalBefore = transcodes.reduce((sum, t) => sum + t.before.file_size, 0)
+  const totalAfter = transcodes.reduce((sum, t) => sum + t.after.file_size, 0)
+  const groups = groupByCodecChange(transcodes)
+  const largestGroup = Math.max(...groups.map(g => g.before), 1)
+  const bySavings = [...transcodes].sort(
+    (a, b) => (b.before.file_size - b.after.file_size) - (a.before.file_size - a.after.file_size)
+  )
+
+  return (
+    <div className="px-6 py-8 border-b border-gray-200">
+      <h2 className="text-xl font-bold text-gray-900 mb-4">Transcode Results</h2>
+
+      <div className="grid grid-cols-1 md:grid-cols-3 gap-6 mb-6">
+        <div className="bg-gray-50 rounded-lg p-4 text-center">
+          <div className="text-2xl font-bold text-gray-700">{formatTotalSize(totalBefore)} GB</div>
+          <div className="text-sm text-gray-600">Before ({transcodes.length} files)</div>
+        </div>
+        <div className="bg-blue-50 rounded-lg p-4 text-center">
+          <div className="text-2xl font-bold text-blue-600">{formatTotalSize(totalAfter)} GB</div>
+          <div className="text-sm text-gray-600">After</div>
+        </div>
+        <div className="bg-green-50 rounded-lg p-4 text-center">
+          <div className="text-2xl font-bold text-green-600">
+            {formatTotalSize(totalBefore - totalAfter)} GB
+          </div>
+          <div className="text-sm text-gray-600">Saved ({savedPercent(totalBefore, totalAfter).toFixed(0)}%)</div>
+        </div>
+      </div>
+
+      <div className="bg-gray-50 rounded-lg p-4 mb-6">
+        <h3 className="text-sm font-medium text-gray-700 mb-3">Savings by Codec</h3>
+        <div className="space-y-3">
+          {groups.map(group => (
+            <div key={group.label}>
+              <div className="flex justify-between text-xs text-gray-600 mb-1">
+                <span>{group.label} ({group.files} files)</span>
+                <span>
+                  {formatTotalSize(group.before)} → {formatTotalSize(group.after)} GB
+                  ({savedPercent(group.before, group.after).toFixed(0)}% saved)
+                </span>
+              </div>
+              <div className="h-2 bg-gray-200 rounded" style={{ width: `${(group.before / largestGroup) * 100}%` }}>
+                <div className="h-2 bg-green-500 rounded" style={{ width: `${group.before > 0 ? (group.after / group.before) * 100 : 0}%` }} />
+              </div>
+            </div>
+          ))}
+        </div>
+      </div>
+
+      <div className="overflow-x-auto">
+        <table className="min-w-full divide-y divide-gray-200">
+          <thead className="bg-gray-50">
+            <tr>
+              <th className="px-4 py-2 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">File</th>
+              <th className="px-4 py-2 text-right text-xs font-medium text-gray-500 uppercase tracking-wider">Size (MB)</th>
+              <th className="px-4 py-2 text-right text-xs font-medium text-gray-500 uppercase tracking-wider">Bitrate</th>
+              <th className="px-4 py-2 text-right text-xs font-medium text-gray-500 uppercase tracking-wider">Codec</th>
+              <th className="px-4 py-2 text-left text-xs font-medium text-gray-500 uppercase tracking-wider">Audio</th>
+              <th className="px-4 py-2 text-right text-xs font-medium text-gray-500 uppercase tracking-wider">Saved</th>
+            </tr>
+          </thead>
+          <tbody className="bg-white divide-y divide-gray-200">
+            {bySavings.map(t => {
+              const beforeAudio = formatAudioTracks(t.before.audio_tracks)
+              const afterAudio = formatAudioTracks(t.after.audio_tracks)
+              return (
+                <tr key={t.file_path} className="hover:bg-gray-50">
+                  <td className="px-4 py-2 text-sm font-mono text-gray-900 break-all">
+                    {getDisplayPath(t.file_path, true, inputDir)}
+                  </td>
+                  <ChangeCell before={formatFileSize(t.before.file_size)} after={formatFileSize(t.after.file_size)} />
+                  <ChangeCell before={formatMbps(t.before.video_bitrate)} after={formatMbps(t.after.video_bitrate)} unit="Mbps" />
+                  <ChangeCell before={t.before.video_codec} after={t.after.video_codec} />
+                  <td className="px-4 py-2 text-xs text-gray-600">
+                    {beforeAudio === afterAudio ? afterAudio : `${beforeAudio} → ${afterAudio}`}
+                  </td>
+                  <td className="px-4 py-2 text-sm text-right font-medium text-green-600">
+                    {savedPercent(t.before.file_size, t.after.file_size).toFixed(0)}%
+                  </td>
+                </tr>
+              )
+            })}
+          </tbody>
+        </table>
+      </div>
+    </div>
+  )
+}
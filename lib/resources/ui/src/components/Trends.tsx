@@ -0,0 +1,84 @@
+import type { LibrarySnapshot } from '../types/media'
+import { formatTotalSize } from '../utils/formatters'
+
+interface TrendsProps {
+  readonly trends: readonly LibrarySnapshot[]
+}
+
+interface LineChartProps {
+  readonly title: string
+  readonly values: readonly number[]
+  readonly labels: readonly string[]
+  readonly format: (value: number) => string
+  readonly color: string
+}
+
+const chartWidth = 400
+const chartHeight = 100
+
+const LineChart = ({ title, values, labels, format, color }: LineChartProps): JSX.Element => {
+  const max = Math.max(...values)
+  const min = Math.min(...values)
+  const range = max - min > 0 ? max - min : 1
+  const step = values.length > 1 ? chartWidth / (values.length - 1) : 0
+  const points = values
+    .map((value, i) => `${i * step},${chartHeight - ((value - min) / range) * chartHeight}`)
+    .join(' ')
+  const first = values[0] ?? 0
+  const last = values[values.length - 1] ?? 0
+
+  return (
+    <div className="bg-gray-50 rounded-lg p-4">
+      <div className="flex justify-between items-baseline mb-2">
+        <h3 className="text-sm font-medium text-gray-700">{title}</h3>
+        <span className="text-sm text-gray-600">
+          {format(first)} → <span className="font-medium text-gray-900">{format(last)}</span>
+        </span>
+      </div>
+      <svg viewBox={`-4 -4 ${chartWidth + 8} ${chartHeight + 8}`} className="w-full h-24" preserveAspectRatio="none">
+        <polyline points={points} fill="none" stroke={color} strokeWidth="2" vectorEffect="non-scaling-stroke" />
+      </svg>
+      <div className="flex justify-between text-xs text-gray-400 mt-1">
+        <span>{labels[0]}</span>
+        <span>{labels[labels.length - 1]}</span>
+      </div>
+    </div>
+  )
+}
+
+export const Trends = ({ trends }: TrendsProps): JSX.Element | null => {
+  if (trends.length < 2) {
+    return null
+  }
+
+  const labels = trends.map(s => new Date(s.timestamp).toLocaleDateString())
+
+  return (
+    <div className="px-6 py-8 border-b border-gray-200">
+      <h2 className="text-xl font-bold text-gray-900 mb-4">Trends ({trends.length} weeks)</h2>
+      <div className="grid grid-cols-1 md:grid-cols-3 gap-6">
+        <LineChart
+          title="Total Size"
+          values={trends.map(s => s.total_size)}
+          labels={labels}
+          format={value => `${formatTotalSize(value)} GB`}
+          color="#16a34a"
+        />
+        <LineChart
+          title="Average Bitrate"
+          values={trends.map(s => s.avg_bitrate ?? 0)}
+          labels={labels}
+          format={value => `${(value / 1000000).toFixed(1)} Mbps`}
+          color="#2563eb"
+        />
+        <LineChart
+          title="HEVC/AV1 Share"
+          values={trends.map(s => s.efficient_share ?? 0)}
+          labels={labels}
+          format={value => `${(value * 100).toFixed(0)}%`}
+          color="#9333ea"
+        />
+      </div>
+    </div>
+  )
+}
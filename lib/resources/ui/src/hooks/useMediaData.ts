@@ -1,10 +1,11 @@
 import { useState, useEffect } from 'react'
-import type { MediaData } from '../types/media'
+import type { MediaData, MediaApiConfig } from '../types/media'
 
 // Declare global for injected data
 declare global {
   interface Window {
     __MEDIA_DATA__?: MediaData
+    __MEDIA_API__?: MediaApiConfig
   }
 }
 
@@ -12,10 +13,42 @@ export const useMediaData = (): MediaData => {
   const [data, setData] = useState<MediaData>({
     mediaFiles: [],
     totalFiles: 0,
-    generatedAt: ''
+    generatedAt: '',
+    inputDir: ''
   })
 
   useEffect(() => {
+    // Live mode: load data from the serve API and poll for updates
+    const api = window.__MEDIA_API__
+    if (api != null) {
+      let lastGeneratedAt = ''
+      const load = (): void => {
+        fetch(api.url, { cache: 'no-store' })
+          .then(async response => {
+            if (!response.ok) {
+              throw new Error(`HTTP ${response.status}`)
+            }
+            return await (response.json() as Promise<MediaData>)
+          })
+          .then(parsedData => {
+            if (parsedData.generatedAt !== lastGeneratedAt) {
+              lastGeneratedAt = parsedData.generatedAt
+              setData(parsedData)
+            }
+          })
+          .catch(error => {
+            console.error('Failed to load media data:', error)
+          })
+      }
+
+      load()
+      if (api.refreshIntervalMs > 0) {
+        const timer = setInterval(load, api.refreshIntervalMs)
+        return () => { clearInterval(timer) }
+      }
+      return
+    }
+
     // First try to use injected data from esbuild
     if (window.__MEDIA_DATA__ != null) {
       setData(window.__MEDIA_DATA__)
@@ -35,4 +68,4 @@ export const useMediaData = (): MediaData => {
   }, [])
 
   return data
-}
\ No newline at end of file
+}
@@ -4,12 +4,78 @@ export interface AudioTrack {
   readonly bitrate: number
   readonly language: string
   readonly channels: number
+  readonly title?: string
+  readonly role?: 'main' | 'commentary' | 'descriptive'
+}
+
+export interface AudioSidecar {
+  readonly path: string
+  readonly label?: string
+  readonly language?: string
+  readonly codec: string
+  readonly bitrate: number
+  readonly channels: number
+  readonly role: 'main' | 'commentary' | 'descriptive'
 }
 
 export interface SubtitleTrack {
   readonly index: number
   readonly codec: string
   readonly language: string
+  readonly title?: string
+  readonly kind?: 'full' | 'forced' | 'sdh'
+}
+
+export interface VideoStream {
+  readonly index: number
+  readonly codec: string
+  readonly width: number
+  readonly height: number
+  readonly frame_rate: number
+  readonly disposition?: readonly string[]
+  readonly title?: string
+  readonly kind: 'primary' | 'cover_art' | 'thumbnail' | 'video'
+}
+
+export interface HDRInfo {
+  readonly format: 'dolby_vision' | 'hdr10plus' | 'hdr10' | 'hlg'
+  readonly dv_profile?: number
+  readonly dv_level?: number
+  readonly dv_compatibility_id?: number
+  readonly max_cll?: number
+  readonly max_fall?: number
+  readonly master_display?: string
+  readonly max_luminance?: number
+  readonly min_luminance?: number
+}
+
+export interface ContainerMetadata {
+  readonly format?: string
+  readonly title?: string
+  readonly encoder?: string
+  readonly video_encoder?: string
+  readonly encoding_settings?: string
+  readonly creation_time?: string
+  readonly release_group?: string
+  readonly tags?: Readonly<Record<string, string>>
+}
+
+export interface StreamBitrate {
+  readonly index: number
+  readonly codec_type: string
+  readonly bitrate: number
+  readonly bytes: number
+}
+
+export interface BitrateStats {
+  readonly streams: readonly StreamBitrate[]
+  readonly video_peak_bitrate: number
+  readonly video_variability: number
+}
+
+export interface BitrateGraph {
+  readonly interval_seconds: number
+  readonly bitrates: readonly number[]
 }
 
 export interface MediaFile {
@@ -23,13 +89,88 @@ export interface MediaFile {
   readonly video_profile?: string
   readonly video_level?: string
   readonly pixel_format?: string
+  readonly frame_rate?: number
+  readonly bit_depth?: number
+  readonly scan_type?: 'progressive' | 'interlaced' | 'telecined'
   readonly is_vbr?: boolean
+  readonly is_vfr?: boolean
   readonly color_space?: string
   readonly color_transfer?: string
   readonly has_dolby_vision?: boolean
+  readonly hdr?: HDRInfo
   readonly audio_tracks: readonly AudioTrack[]
+  readonly audio_sidecars?: readonly AudioSidecar[]
   readonly subtitle_tracks: readonly SubtitleTrack[]
+  readonly video_streams?: readonly VideoStream[]
   readonly analyzed_at: string
+  readonly archived_to?: string
+  readonly container?: ContainerMetadata
+  readonly bitrate_stats?: BitrateStats
+  readonly bitrate_graph?: BitrateGraph
+  readonly thumbnails?: readonly string[]
+}
+
+export interface TranscodeComparison {
+  readonly file_path: string
+  readonly output_path: string
+  readonly transcoded_at: string
+  readonly encoder?: string
+  readonly quality?: number
+  readonly before: MediaFile
+  readonly after: MediaFile
+}
+
+export interface LibrarySnapshot {
+  readonly timestamp: string
+  readonly files: number
+  readonly total_size: number
+  readonly total_duration?: number
+  readonly avg_bitrate?: number
+  readonly efficient_share?: number
+  readonly codecs: { readonly [codec: string]: number }
+}
+
+export interface LibraryGroup {
+  readonly name: string
+  readonly files: number
+  readonly total_size: number
+  readonly total_duration: number
+  readonly avg_bitrate?: number
+}
+
+export interface LibraryGroups {
+  readonly directories: readonly LibraryGroup[]
+  readonly shows: readonly LibraryGroup[]
+}
+
+export interface SavingsCandidate {
+  readonly file_path: string
+  readonly file_size: number
+  readonly video_codec: string
+  readonly resolution: string
+  readonly bits_per_pixel: number
+  readonly predicted_size: number
+  readonly predicted_savings: number
+}
+
+export interface SavingsReport {
+  readonly quality: number
+  readonly candidates: readonly SavingsCandidate[]
+  readonly candidate_size: number
+  readonly reclaimable_size: number
+}
+
+export interface ReportBranding {
+  readonly title: string
+  readonly logo?: string
+  readonly notes?: string
+}
+
+export interface SavedFilterView {
+  readonly name: string
+  readonly expression: string
+  readonly description?: string
+  readonly matches: readonly string[]
 }
 
 export interface MediaData {
@@ -37,6 +178,25 @@ export interface MediaData {
   readonly totalFiles: number
   readonly generatedAt: string
   readonly inputDir: string
+  readonly transcodes?: readonly TranscodeComparison[]
+  readonly trends?: readonly LibrarySnapshot[]
+  readonly groups?: LibraryGroups
+  readonly savings?: SavingsReport
+  readonly branding?: ReportBranding
+  readonly previous?: LibrarySnapshot
+  readonly savedFilters?: readonly SavedFilterView[]
+  readonly failedFiles?: readonly FileFailure[]
+}
+
+export interface FileFailure {
+  readonly file: string
+  readonly stage?: string
+  readonly error: string
+}
+
+export interface MediaApiConfig {
+  readonly url: string
+  readonly refreshIntervalMs: number
 }
 
 export interface SortConfig {
@@ -54,9 +214,14 @@ export interface ColumnVisibility {
   readonly videoProfile: boolean
   readonly videoLevel: boolean
   readonly pixelFormat: boolean
+  readonly frameRate: boolean
+  readonly bitDepth: boolean
+  readonly scanType: boolean
   readonly colorInfo: boolean
   readonly audioTracks: boolean
   readonly subtitleTracks: boolean
+  readonly videoStreams: boolean
+  readonly provenance: boolean
 }
 
 export type SortableColumn = 
@@ -69,9 +234,14 @@ export type SortableColumn =
   | 'videoProfile'
   | 'videoLevel'
   | 'pixelFormat'
+  | 'frameRate'
+  | 'bitDepth'
+  | 'scanType'
   | 'colorInfo'
   | 'audioTracks'
   | 'subtitleTracks'
+  | 'videoStreams'
+  | 'provenance'
 
 export interface CodecCounts {
   readonly [codec: string]: number
@@ -1,3 +1,5 @@
+import type { ContainerMetadata, HDRInfo } from '../types/media'
+
 export const formatFileSize = (bytes: number): string => {
   return (bytes / (1024 * 1024)).toFixed(1)
 }
@@ -18,24 +20,56 @@ export const formatDate = (dateString: string): string => {
   return new Date(dateString).toLocaleString()
 }
 
-export const formatAudioTracks = (tracks: readonly { codec: string, language: string, channels: number }[]): string => {
+const audioRoleLabel = (role?: string): string => (role != null && role !== 'main' ? `, ${role}` : '')
+
+export const formatAudioTracks = (tracks: readonly { codec: string, language: string, channels: number, role?: string }[]): string => {
   if (tracks.length === 0) return '0'
   if (tracks.length === 1) {
     const track = tracks[0]
     if (track != null) {
-      return `${track.codec} (${track.language}, ${track.channels}ch)`
+      return `${track.codec} (${track.language}, ${track.channels}ch${audioRoleLabel(track.role)})`
     }
   }
-  return `${tracks.length} tracks: ${tracks.map(t => `${t.codec} (${t.language})`).join(', ')}`
+  return `${tracks.length} tracks: ${tracks.map(t => `${t.codec} (${t.language}${audioRoleLabel(t.role)})`).join(', ')}`
+}
+
+export const formatAudioSidecars = (sidecars: readonly { codec: string, language?: string, role: string }[]): string => {
+  return `+${sidecars.length} external: ${sidecars.map(s => `${s.codec} (${s.language ?? 'und'}${audioRoleLabel(s.role)})`).join(', ')}`
+}
+
+export const formatVideoStreams = (streams: readonly { codec: string, width: number, height: number, frame_rate: number, kind: string }[]): string => {
+  if (streams.length <= 1) return String(streams.length)
+  return `${streams.length}: ${streams.map(s => `${s.codec} ${s.width}×${s.height}${s.frame_rate > 0 ? ` @${s.frame_rate.toFixed(2)}` : ''} (${s.kind})`).join(', ')}`
+}
+
+export const formatHDR = (hdr: HDRInfo): string => {
+  switch (hdr.format) {
+    case 'dolby_vision':
+      if (hdr.dv_profile == null) return 'Dolby Vision'
+      return `Dolby Vision ${hdr.dv_profile}${hdr.dv_compatibility_id != null ? `.${hdr.dv_compatibility_id}` : ''}`
+    case 'hdr10plus':
+      return 'HDR10+'
+    case 'hdr10':
+      return 'HDR10'
+    case 'hlg':
+      return 'HLG'
+  }
 }
 
-export const formatSubtitleTracks = (tracks: readonly { codec: string, language: string }[]): string => {
+const subtitleKindLabel = (kind?: string): string => (kind != null && kind !== 'full' ? ` ${kind === 'sdh' ? 'SDH' : kind}` : '')
+
+export const formatSubtitleTracks = (tracks: readonly { codec: string, language: string, kind?: string }[]): string => {
   if (tracks.length === 0) return '0'
   if (tracks.length === 1) {
     const track = tracks[0]
     if (track != null) {
-      return `${track.codec} (${track.language})`
+      return `${track.codec} (${track.language}${subtitleKindLabel(track.kind)})`
     }
   }
-  return `${tracks.length}: ${tracks.map(t => `${t.language}`).join(', ')}`
-}
\ No newline at end of file
+  return `${tracks.length}: ${tracks.map(t => `${t.language}${subtitleKindLabel(t.kind)}`).join(', ')}`
+}
+export const formatProvenance = (container?: ContainerMetadata): string => {
+  if (container == null) return 'N/A'
+  const parts = [container.release_group, container.encoder ?? container.video_encoder].filter(part => part != null && part !== '')
+  return parts.length > 0 ? parts.join(' · ') : 'N/A'
+}
@@ -1,6 +1,10 @@
 import type { MediaFile, SortableColumn, SortConfig } from '../types/media'
 import { getDisplayPath } from './pathUtils'
 
+const hdrRanks: Record<string, number> = { dolby_vision: 4, hdr10plus: 3, hdr10: 2, hlg: 1 }
+
+const hdrRank = (file: MediaFile): number => (file.hdr ? hdrRanks[file.hdr.format] ?? 0 : 0)
+
 export const sortMediaFiles = (
   files: readonly MediaFile[],
   sortConfig: SortConfig,
@@ -52,10 +56,22 @@ export const sortMediaFiles = (
         aVal = a.pixel_format || ''
         bVal = b.pixel_format || ''
         break
+      case 'frameRate':
+        aVal = a.frame_rate ?? 0
+        bVal = b.frame_rate ?? 0
+        break
+      case 'bitDepth':
+        aVal = a.bit_depth ?? 0
+        bVal = b.bit_depth ?? 0
+        break
+      case 'scanType':
+        aVal = a.scan_type ?? ''
+        bVal = b.scan_type ?? ''
+        break
       case 'colorInfo':
-        // Sort by HDR capability (Dolby Vision > HDR10 > SDR)
-        aVal = (a.has_dolby_vision ? 3 : 0) + (a.color_transfer === 'smpte2084' ? 2 : 0) + (a.is_vbr ? 1 : 0)
-        bVal = (b.has_dolby_vision ? 3 : 0) + (b.color_transfer === 'smpte2084' ? 2 : 0) + (b.is_vbr ? 1 : 0)
+        // Sort by HDR format (Dolby Vision > HDR10+ > HDR10 > HLG > SDR), then VBR
+        aVal = hdrRank(a) * 2 + (a.is_vbr ? 1 : 0)
+        bVal = hdrRank(b) * 2 + (b.is_vbr ? 1 : 0)
         break
       case 'audioTracks':
         aVal = a.audio_tracks.length
@@ -65,6 +81,14 @@ export const sortMediaFiles = (
         aVal = a.subtitle_tracks.length
         bVal = b.subtitle_tracks.length
         break
+      case 'videoStreams':
+        aVal = a.video_streams?.length ?? 0
+        bVal = b.video_streams?.length ?? 0
+        break
+      case 'provenance':
+        aVal = a.container?.release_group ?? a.container?.encoder ?? ''
+        bVal = b.container?.release_group ?? b.container?.encoder ?? ''
+        break
       default:
         return 0
     }
@@ -0,0 +1,222 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run summary artifacts written for every analyze and transcode run
+const (
+	RunSummaryJSONFilename     = "run_summary.json"
+	RunSummaryMarkdownFilename = "run_summary.md"
+)
+
+// Run outcomes recorded in a RunReport
+const (
+	RunStatusSuccess   = "success"   // Every file was processed
+	RunStatusPartial   = "partial"   // The run finished but some files failed
+	RunStatusFailed    = "failed"    // The run stopped with an error
+	RunStatusCancelled = "cancelled" // The run was interrupted
+)
+
+// RunReport is the machine-readable outcome of an analyze or transcode run, for automation
+// such as cron wrappers that need more than an exit code
+type RunReport struct {
+	Command         string            `json:"command"`
+	Status          string            `json:"status"`
+	ExitCode        int               `json:"exit_code"`
+	StartedAt       time.Time         `json:"started_at"`
+	FinishedAt      time.Time         `json:"finished_at"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Inputs          []string          `json:"inputs"`                  // Directories, files, or file lists the run was given
+	Settings        map[string]string `json:"settings"`                // Every option the run used, by flag name
+	Versions        map[string]string `json:"versions"`                // media-mgmt, Go, and external tool versions
+	Counts          map[string]int    `json:"counts"`                  // Files per outcome, such as analyzed or skipped
+	BytesProcessed  int64             `json:"bytes_processed"`         // Total size of the files read
+	BytesWritten    int64             `json:"bytes_written,omitempty"` // Total size of the files produced
+	Files           []FileOutcome     `json:"files"`
+	Failures        []FileFailure     `json:"failures"`
+	Discrepancies   []Discrepancy     `json:"discrepancies,omitempty"` // Filesystem state that does not match what the run did
+	Error           string            `json:"error,omitempty"`         // Why the run stopped, for failed runs
+}
+
+// FileOutcome is what a run did with one file
+type FileOutcome struct {
+	File            string             `json:"file"`
+	Outcome         string             `json:"outcome"` // Such as analyzed, archived, done, skipped, or failed
+	Error           string             `json:"error,omitempty"`
+	DurationSeconds float64            `json:"duration_seconds,omitempty"` // Time spent on the file, where the command tracks it
+	InputBytes      int64              `json:"input_bytes,omitempty"`
+	OutputBytes     int64              `json:"output_bytes,omitempty"`
+	Quality         map[string]float64 `json:"quality,omitempty"` // Scores against the source by metric, such as vmaf, where the command compares them
+}
+
+// Discrepancy is a difference between what a run recorded doing to a file and what is on disk
+type Discrepancy struct {
+	File    string `json:"file"`
+	Problem string `json:"problem"`
+}
+
+// NewRunReport starts a report for command, timed from now
+func NewRunReport(command string) *RunReport {
+	return &RunReport{
+		Command:   command,
+		StartedAt: time.Now(),
+		Inputs:    []string{},
+		Settings:  map[string]string{},
+		Versions:  map[string]string{},
+		Counts:    map[string]int{},
+		Files:     []FileOutcome{},
+		Failures:  []FileFailure{},
+	}
+}
+
+// DefaultRunSummaryDir is where commands without an output directory keep their latest run summary
+func DefaultRunSummaryDir(command string) string {
+	return filepath.Join(DefaultStateDir(), "runs", command)
+}
+
+// RecordVersions records the media-mgmt build and the versions of the named external tools
+func (r *RunReport) RecordVersions(ctx context.Context, tools ...string) {
+	r.Versions["media-mgmt"] = "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		r.Versions["media-mgmt"] = info.Main.Version
+	}
+	r.Versions["go"] = runtime.Version()
+	caps := DetectCapabilities(ctx, DefaultCapabilitiesPath(), tools...)
+	for _, name := range tools {
+		if tool := caps.Tool(name); tool == nil {
+			r.Versions[name] = "not found"
+		} else if tool.Version != "" {
+			r.Versions[name] = tool.Version
+		} else {
+			r.Versions[name] = "unknown"
+		}
+	}
+}
+
+// Finish records the outcome: cancelled or failed if err is set, partial if any files failed,
+// otherwise success
+func (r *RunReport) Finish(err error, exitCode int) {
+	r.FinishedAt = time.Now()
+	r.DurationSeconds = r.FinishedAt.Sub(r.StartedAt).Seconds()
+	r.ExitCode = exitCode
+	switch {
+	case errors.Is(err, context.Canceled):
+		r.Status = RunStatusCancelled
+	case err != nil:
+		r.Status = RunStatusFailed
+		r.Error = err.Error()
+	case len(r.Failures) > 0:
+		r.Status = RunStatusPartial
+	default:
+		r.Status = RunStatusSuccess
+	}
+}
+
+// WriteFile writes the report as indented JSON, replacing path atomically so a watcher never
+// reads a partial file
+func (r *RunReport) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run summary: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	return nil
+}
+
+// ReadRunReport reads a report written by WriteFile
+func ReadRunReport(path string) (*RunReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report RunReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse run summary %s: %w", path, err)
+	}
+	return &report, nil
+}
+
+// Markdown renders the report for people reading it after the fact
+func (r *RunReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s run: %s\n\n", r.Command, r.Status)
+	fmt.Fprintf(&b, "- **Started**: %s\n", r.StartedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- **Duration**: %s\n", FormatDuration(r.DurationSeconds))
+	fmt.Fprintf(&b, "- **Exit code**: %d\n", r.ExitCode)
+	if len(r.Inputs) > 0 {
+		fmt.Fprintf(&b, "- **Inputs**: %s\n", strings.Join(r.Inputs, ", "))
+	}
+	fmt.Fprintf(&b, "- **Read**: %s\n", FormatSize(r.BytesProcessed))
+	if r.BytesWritten > 0 {
+		fmt.Fprintf(&b, "- **Written**: %s\n", FormatSize(r.BytesWritten))
+	}
+	if r.Error != "" {
+		fmt.Fprintf(&b, "- **Error**: %s\n", r.Error)
+	}
+
+	writeMarkdownMap(&b, "Totals", r.Counts)
+	writeMarkdownMap(&b, "Versions", r.Versions)
+	writeMarkdownMap(&b, "Settings", r.Settings)
+
+	if len(r.Files) > 0 {
+		fmt.Fprintf(&b, "\n## Files\n\n| File | Outcome | Error |\n|------|---------|-------|\n")
+		for _, file := range r.Files {
+			fmt.Fprintf(&b, "| %s | %s | %s |\n", markdownCell(file.File), file.Outcome, markdownCell(file.Error))
+		}
+	}
+	if len(r.Discrepancies) > 0 {
+		fmt.Fprintf(&b, "\n## Discrepancies\n\n| File | Problem |\n|------|---------|\n")
+		for _, d := range r.Discrepancies {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownCell(d.File), markdownCell(d.Problem))
+		}
+	}
+	return b.String()
+}
+
+// writeMarkdownMap writes a map as a sorted list under a heading
+func writeMarkdownMap[V any](b *strings.Builder, title string, values map[string]V) {
+	if len(values) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(b, "\n## %s\n\n", title)
+	for _, key := range keys {
+		fmt.Fprintf(b, "- **%s**: %v\n", key, values[key])
+	}
+}
+
+// WriteArtifacts writes run_summary.json and run_summary.md into dir, replacing the previous run's
+func (r *RunReport) WriteArtifacts(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create run summary directory: %w", err)
+	}
+	if err := r.WriteFile(filepath.Join(dir, RunSummaryJSONFilename)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, RunSummaryMarkdownFilename), []byte(r.Markdown()), 0644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunReportFinish(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		failures []FileFailure
+		expected string
+	}{
+		{name: "success", expected: RunStatusSuccess},
+		{name: "partial", failures: []FileFailure{{File: "/media/a.mkv", Error: "boom"}}, expected: RunStatusPartial},
+		{name: "failed", err: errors.New("no input"), expected: RunStatusFailed},
+		{name: "cancelled", err: fmt.Errorf("stopped: %w", context.Canceled), expected: RunStatusCancelled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewRunReport("analyze")
+			report.Failures = append(report.Failures, tt.failures...)
+			report.Finish(tt.err, 2)
+			if report.Status != tt.expected {
+				t.Errorf("Status = %q, want %q", report.Status, tt.expected)
+			}
+			if report.ExitCode != 2 || report.FinishedAt.Before(report.StartedAt) {
+				t.Errorf("unexpected exit code or timing: %+v", report)
+			}
+		})
+	}
+}
+
+func TestRunReportWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+	report := NewRunReport("transcode")
+	report.Counts["transcoded"] = 3
+	report.BytesProcessed = 1 << 30
+	report.Finish(nil, 0)
+	if err := report.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read summary: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Summary is not JSON: %v", err)
+	}
+	if decoded["status"] != RunStatusSuccess || decoded["counts"].(map[string]interface{})["transcoded"] != float64(3) {
+		t.Errorf("unexpected summary: %s", data)
+	}
+	if failures, ok := decoded["failures"].([]interface{}); !ok || len(failures) != 0 {
+		t.Errorf("failures should be an empty array, got %v", decoded["failures"])
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file was left behind")
+	}
+}
+
+func TestRunReportWriteArtifacts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "runs")
+	report := NewRunReport("analyze")
+	report.Inputs = append(report.Inputs, "/media/movies")
+	report.Settings["parallelism"] = "4"
+	report.Files = append(report.Files,
+		FileOutcome{File: "/media/movies/a.mkv", Outcome: "analyzed"},
+		FileOutcome{File: "/media/movies/b.mkv", Outcome: "failed", Error: "probe | timed out"})
+	report.Finish(nil, 0)
+	if err := report.WriteArtifacts(dir); err != nil {
+		t.Fatalf("WriteArtifacts failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, RunSummaryJSONFilename)); err != nil {
+		t.Errorf("JSON summary missing: %v", err)
+	}
+	markdown, err := os.ReadFile(filepath.Join(dir, RunSummaryMarkdownFilename))
+	if err != nil {
+		t.Fatalf("Markdown summary missing: %v", err)
+	}
+	for _, want := range []string{"# analyze run: success", "- **Inputs**: /media/movies", "- **parallelism**: 4", `| /media/movies/b.mkv | failed | probe \| timed out |`} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("Markdown summary missing %q:\n%s", want, markdown)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// savedFiltersFilename stores filters saved with the filters command in the state directory
+const savedFiltersFilename = "filters.json"
+
+// SavedFilter is a named filter expression, such as "h264 over 8Mbps", offered as a view in every HTML report
+type SavedFilter struct {
+	Name        string `yaml:"name" json:"name"`
+	Expression  string `yaml:"expression" json:"expression"` // Filter expression, as accepted by query
+	Description string `yaml:"description" json:"description,omitempty"`
+}
+
+// Validate checks that the filter is named and its expression parses
+func (f SavedFilter) Validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("saved filter must have a name")
+	}
+	if _, err := ParseFilterExpr(f.Expression); err != nil {
+		return fmt.Errorf("saved filter %q has an invalid expression: %w", f.Name, err)
+	}
+	return nil
+}
+
+// SavedFilterView is a saved filter applied to a library, as embedded in the HTML report
+type SavedFilterView struct {
+	SavedFilter
+	Matches []string `json:"matches"` // Paths of the matching files
+}
+
+// DefaultSavedFiltersPath returns the saved filter store location in the state directory
+func DefaultSavedFiltersPath() string {
+	return filepath.Join(DefaultStateDir(), savedFiltersFilename)
+}
+
+// SavedFilterStore persists filters saved from the command line as a JSON file
+type SavedFilterStore struct {
+	path string
+}
+
+// NewSavedFilterStore creates a store backed by the file at path
+func NewSavedFilterStore(path string) *SavedFilterStore {
+	return &SavedFilterStore{path: path}
+}
+
+// Load returns the stored filters sorted by name, or none if the file does not exist
+func (s *SavedFilterStore) Load() ([]SavedFilter, error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read saved filters: %w", err)
+	}
+
+	var filters []SavedFilter
+	if err := json.Unmarshal(data, &filters); err != nil {
+		return nil, fmt.Errorf("failed to parse saved filters %s: %w", s.path, err)
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Name < filters[j].Name })
+	return filters, nil
+}
+
+// Save adds a filter, replacing any stored filter with the same name
+func (s *SavedFilterStore) Save(filter SavedFilter) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+	filters, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, existing := range filters {
+		if existing.Name == filter.Name {
+			filters[i] = filter
+			replaced = true
+		}
+	}
+	if !replaced {
+		filters = append(filters, filter)
+	}
+	return s.write(filters)
+}
+
+// Delete removes the named filter, returning an error if it is not stored
+func (s *SavedFilterStore) Delete(name string) error {
+	filters, err := s.Load()
+	if err != nil {
+		return err
+	}
+
+	kept := make([]SavedFilter, 0, len(filters))
+	for _, filter := range filters {
+		if filter.Name != name {
+			kept = append(kept, filter)
+		}
+	}
+	if len(kept) == len(filters) {
+		return fmt.Errorf("no saved filter named %q", name)
+	}
+	return s.write(kept)
+}
+
+// write replaces the store file with filters, sorted by name
+func (s *SavedFilterStore) write(filters []SavedFilter) error {
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Name < filters[j].Name })
+	data, err := json.MarshalIndent(filters, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved filters: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write saved filters: %w", err)
+	}
+	return nil
+}
+
+// MergeSavedFilters combines filters from the config file with those saved from the command line.
+// Config filters come first and win over stored filters of the same name.
+func MergeSavedFilters(configured, stored []SavedFilter) []SavedFilter {
+	merged := make([]SavedFilter, 0, len(configured)+len(stored))
+	names := make(map[string]bool, len(configured))
+	for _, filter := range configured {
+		merged = append(merged, filter)
+		names[filter.Name] = true
+	}
+	for _, filter := range stored {
+		if names[filter.Name] {
+			slog.Warn("Saved filter is overridden by the config file", "filter", filter.Name)
+			continue
+		}
+		merged = append(merged, filter)
+	}
+	return merged
+}
+
+// LoadSavedFilters returns the filters from the config file merged with those in the default store
+func LoadSavedFilters(config *Config) ([]SavedFilter, error) {
+	stored, err := NewSavedFilterStore(DefaultSavedFiltersPath()).Load()
+	if err != nil {
+		return nil, err
+	}
+	return MergeSavedFilters(config.Filters, stored), nil
+}
+
+// FindSavedFilter returns the filter with the given name
+func FindSavedFilter(filters []SavedFilter, name string) (SavedFilter, bool) {
+	for _, filter := range filters {
+		if filter.Name == name {
+			return filter, true
+		}
+	}
+	return SavedFilter{}, false
+}
+
+// ApplySavedFilters evaluates each filter against the library. Filters that fail to parse
+// are left out with a warning, so one bad filter does not break the report.
+func ApplySavedFilters(filters []SavedFilter, mediaInfos []*MediaInfo) []SavedFilterView {
+	views := make([]SavedFilterView, 0, len(filters))
+	for _, filter := range filters {
+		expr, err := ParseFilterExpr(filter.Expression)
+		if err != nil {
+			slog.Warn("Omitting invalid saved filter from report", "filter", filter.Name, "error", err)
+			continue
+		}
+
+		view := SavedFilterView{SavedFilter: filter, Matches: []string{}}
+		for _, info := range mediaInfos {
+			if expr.Match(info) {
+				view.Matches = append(view.Matches, info.FilePath)
+			}
+		}
+		views = append(views, view)
+	}
+	return views
+}
@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSavedFilterStore(t *testing.T) {
+	store := NewSavedFilterStore(filepath.Join(t.TempDir(), "state", "filters.json"))
+
+	filters, err := store.Load()
+	if err != nil || len(filters) != 0 {
+		t.Fatalf("Expected no filters before saving, got %+v, %v", filters, err)
+	}
+
+	if err := store.Save(SavedFilter{Name: "missing subs", Expression: "subtitle_tracks = 0"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Save(SavedFilter{Name: "h264 over 8Mbps", Expression: "codec = h264 AND bitrate > 4M"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if err := store.Save(SavedFilter{Name: "h264 over 8Mbps", Expression: "codec = h264 AND bitrate > 8M"}); err != nil {
+		t.Fatalf("Save replacing a filter failed: %v", err)
+	}
+	if err := store.Save(SavedFilter{Name: "broken", Expression: "codec ="}); err == nil {
+		t.Error("Expected an invalid expression to be rejected")
+	}
+
+	filters, err = store.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := []SavedFilter{
+		{Name: "h264 over 8Mbps", Expression: "codec = h264 AND bitrate > 8M"},
+		{Name: "missing subs", Expression: "subtitle_tracks = 0"},
+	}
+	if !reflect.DeepEqual(filters, want) {
+		t.Errorf("Expected %+v, got %+v", want, filters)
+	}
+
+	if err := store.Delete("missing subs"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete("missing subs"); err == nil {
+		t.Error("Expected deleting a missing filter to fail")
+	}
+	if filters, _ := store.Load(); len(filters) != 1 {
+		t.Errorf("Expected 1 filter after delete, got %+v", filters)
+	}
+}
+
+func TestMergeSavedFilters(t *testing.T) {
+	configured := []SavedFilter{{Name: "large", Expression: "size > 8G"}}
+	stored := []SavedFilter{{Name: "hevc", Expression: "codec = hevc"}, {Name: "large", Expression: "size > 1G"}}
+
+	merged := MergeSavedFilters(configured, stored)
+	want := []SavedFilter{{Name: "large", Expression: "size > 8G"}, {Name: "hevc", Expression: "codec = hevc"}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("Expected %+v, got %+v", want, merged)
+	}
+}
+
+func TestApplySavedFilters(t *testing.T) {
+	mediaInfos := []*MediaInfo{
+		{FilePath: "/media/a.mkv", VideoCodec: "h264", VideoBitrate: 12_000_000},
+		{FilePath: "/media/b.mkv", VideoCodec: "h264", VideoBitrate: 4_000_000, SubtitleTracks: []SubtitleTrack{{Language: "eng"}}},
+		{FilePath: "/media/c.mkv", VideoCodec: "hevc", VideoBitrate: 9_000_000},
+	}
+	filters := []SavedFilter{
+		{Name: "h264 over 8Mbps", Expression: "codec = h264 AND bitrate > 8M"},
+		{Name: "broken", Expression: "codec ="},
+		{Name: "missing subs", Expression: "subtitle_tracks = 0"},
+		{Name: "av1", Expression: "codec = av1"},
+	}
+
+	views := ApplySavedFilters(filters, mediaInfos)
+	got := make(map[string][]string, len(views))
+	for _, view := range views {
+		got[view.Name] = view.Matches
+	}
+	want := map[string][]string{
+		"h264 over 8Mbps": {"/media/a.mkv"},
+		"missing subs":    {"/media/a.mkv", "/media/c.mkv"},
+		"av1":             {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestLoadConfigFilters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", "filters:\n  - name: large\n    expression: size > 8G\n", false},
+		{"missing name", "filters:\n  - expression: size > 8G\n", true},
+		{"invalid expression", "filters:\n  - name: large\n    expression: size >\n", true},
+		{"duplicate name", "filters:\n  - name: large\n    expression: size > 8G\n  - name: large\n    expression: size > 1G\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+			config, err := LoadConfig(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", config.Filters)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig failed: %v", err)
+			}
+			if len(config.Filters) != 1 {
+				t.Errorf("Expected 1 filter, got %+v", config.Filters)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package lib
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultSavingsQuality matches the transcode command's default quality
+	DefaultSavingsQuality = 70
+	// DefaultMinSavingsRatio matches the transcode command's default --max-size-ratio of 0.8,
+	// so recommended files are unlikely to be skipped for insufficient savings
+	DefaultMinSavingsRatio = 0.2
+	// TranscodeCandidatesFilename is the file list written next to reports for transcode --file-list
+	TranscodeCandidatesFilename = "transcode-candidates.txt"
+
+	// referenceBitsPerPixel is the HEVC video bitrate per pixel (bits per second) expected at
+	// DefaultSavingsQuality, about 3.5 Mbps for 1080p
+	referenceBitsPerPixel = 1.7
+	// qualityScale is how many quality points multiply the expected bitrate by e
+	qualityScale = 15.0
+)
+
+// SavingsCandidate is a file ranked by the space transcoding it is predicted to reclaim
+type SavingsCandidate struct {
+	FilePath         string  `json:"file_path"`
+	FileSize         int64   `json:"file_size"`
+	VideoCodec       string  `json:"video_codec"`
+	Resolution       string  `json:"resolution"`
+	BitsPerPixel     float64 `json:"bits_per_pixel"` // Current video bitrate per pixel, in bits per second
+	PredictedSize    int64   `json:"predicted_size"`
+	PredictedSavings int64   `json:"predicted_savings"`
+}
+
+// SavingsReport lists transcode candidates at a quality target, largest savings first
+type SavingsReport struct {
+	Quality         int                `json:"quality"`
+	Candidates      []SavingsCandidate `json:"candidates"`
+	CandidateSize   int64              `json:"candidate_size"`   // Current total size of all candidates
+	ReclaimableSize int64              `json:"reclaimable_size"` // Predicted total savings of all candidates
+}
+
+// TargetVideoBitrate predicts the HEVC video bitrate of a transcode at a quality (0-100).
+// Scales with pixel count, and exponentially with quality around DefaultSavingsQuality.
+func TargetVideoBitrate(width, height, quality int) int64 {
+	bitsPerPixel := referenceBitsPerPixel * math.Exp(float64(quality-DefaultSavingsQuality)/qualityScale)
+	return int64(float64(width*height) * bitsPerPixel)
+}
+
+// RecommendTranscodes predicts the size of each file after transcoding at quality and returns the
+// files predicted to shrink by at least minSavingsRatio. Only the video stream is assumed to change;
+// archived files and files without a known resolution or duration are skipped.
+func RecommendTranscodes(mediaInfos []*MediaInfo, quality int, minSavingsRatio float64) *SavingsReport {
+	report := &SavingsReport{Quality: quality, Candidates: []SavingsCandidate{}}
+
+	for _, info := range mediaInfos {
+		if info.ArchivedTo != "" || info.Duration <= 0 || info.VideoWidth <= 0 || info.VideoHeight <= 0 || info.FileSize <= 0 {
+			continue
+		}
+
+		videoBitrate := info.VideoBitrate
+		if videoBitrate <= 0 {
+			videoBitrate = int64(float64(info.FileSize*8) / info.Duration)
+		}
+		target := TargetVideoBitrate(info.VideoWidth, info.VideoHeight, quality)
+		if target >= videoBitrate {
+			continue
+		}
+
+		savings := int64(float64(videoBitrate-target) * info.Duration / 8)
+		if savings > info.FileSize {
+			savings = info.FileSize
+		}
+		if float64(savings) < float64(info.FileSize)*minSavingsRatio {
+			continue
+		}
+
+		report.Candidates = append(report.Candidates, SavingsCandidate{
+			FilePath:         info.FilePath,
+			FileSize:         info.FileSize,
+			VideoCodec:       info.VideoCodec,
+			Resolution:       ResolutionClass(info.VideoHeight),
+			BitsPerPixel:     float64(videoBitrate) / float64(info.VideoWidth*info.VideoHeight),
+			PredictedSize:    info.FileSize - savings,
+			PredictedSavings: savings,
+		})
+		report.CandidateSize += info.FileSize
+		report.ReclaimableSize += savings
+	}
+
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		if report.Candidates[i].PredictedSavings != report.Candidates[j].PredictedSavings {
+			return report.Candidates[i].PredictedSavings > report.Candidates[j].PredictedSavings
+		}
+		return report.Candidates[i].FilePath < report.Candidates[j].FilePath
+	})
+	return report
+}
+
+// WriteFileList writes candidate paths one per line, in ranked order, for transcode --file-list
+func (r *SavingsReport) WriteFileList(path string) error {
+	var sb strings.Builder
+	for _, candidate := range r.Candidates {
+		sb.WriteString(candidate.FilePath)
+		sb.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write transcode candidates: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTargetVideoBitrate(t *testing.T) {
+	hd := TargetVideoBitrate(1920, 1080, DefaultSavingsQuality)
+	if hd < 3000000 || hd > 4000000 {
+		t.Errorf("Expected about 3.5 Mbps for 1080p at the default quality, got %d", hd)
+	}
+	if TargetVideoBitrate(1920, 1080, 90) <= hd || TargetVideoBitrate(1920, 1080, 50) >= hd {
+		t.Error("Expected target bitrate to increase with quality")
+	}
+	if TargetVideoBitrate(3840, 2160, DefaultSavingsQuality) != 4*hd {
+		t.Error("Expected target bitrate to scale with pixel count")
+	}
+}
+
+func TestRecommendTranscodes(t *testing.T) {
+	hour := 3600.0
+	mediaInfos := []*MediaInfo{
+		// 20 Mbps 1080p h264: large savings
+		{FilePath: "/m/bloated.mkv", FileSize: 9000000000, Duration: hour, VideoCodec: "h264", VideoBitrate: 20000000, VideoWidth: 1920, VideoHeight: 1080},
+		// 8 Mbps 1080p: smaller savings
+		{FilePath: "/m/medium.mkv", FileSize: 3600000000, Duration: hour, VideoCodec: "h264", VideoBitrate: 8000000, VideoWidth: 1920, VideoHeight: 1080},
+		// Already below the target bitrate
+		{FilePath: "/m/efficient.mkv", FileSize: 1000000000, Duration: hour, VideoCodec: "hevc", VideoBitrate: 2000000, VideoWidth: 1920, VideoHeight: 1080},
+		// Unknown video bitrate falls back to the overall bitrate
+		{FilePath: "/m/unknown.mkv", FileSize: 10000000000, Duration: hour, VideoCodec: "mpeg2video", VideoWidth: 1920, VideoHeight: 1080},
+		{FilePath: "/m/archived.mkv", FileSize: 9000000000, Duration: hour, VideoBitrate: 20000000, VideoWidth: 1920, VideoHeight: 1080, ArchivedTo: "s3://x"},
+		{FilePath: "/m/no-duration.mkv", FileSize: 9000000000, VideoBitrate: 20000000, VideoWidth: 1920, VideoHeight: 1080},
+	}
+
+	report := RecommendTranscodes(mediaInfos, DefaultSavingsQuality, DefaultMinSavingsRatio)
+	var paths []string
+	for _, candidate := range report.Candidates {
+		paths = append(paths, filepath.Base(candidate.FilePath))
+		if candidate.PredictedSize+candidate.PredictedSavings != candidate.FileSize {
+			t.Errorf("Predicted size and savings of %s don't add up to its size", candidate.FilePath)
+		}
+	}
+	if got := strings.Join(paths, ","); got != "unknown.mkv,bloated.mkv,medium.mkv" {
+		t.Errorf("Unexpected candidate ranking %s", got)
+	}
+	if report.CandidateSize != 22600000000 {
+		t.Errorf("Expected candidate size to total the three candidates, got %d", report.CandidateSize)
+	}
+
+	strict := RecommendTranscodes(mediaInfos, DefaultSavingsQuality, 0.6)
+	if len(strict.Candidates) != 2 {
+		t.Errorf("Expected higher minimum savings to drop the medium file, got %d candidates", len(strict.Candidates))
+	}
+
+	path := filepath.Join(t.TempDir(), TranscodeCandidatesFilename)
+	if err := report.WriteFileList(path); err != nil {
+		t.Fatalf("WriteFileList failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "/m/unknown.mkv\n/m/bloated.mkv\n/m/medium.mkv\n" {
+		t.Errorf("Unexpected file list %q", data)
+	}
+}
@@ -5,7 +5,11 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var videoExtensions = map[string]bool{
@@ -24,48 +28,156 @@ var videoExtensions = map[string]bool{
 	".mts":  true,
 }
 
+// IsVideoFile reports whether a file name has one of the video extensions scans look for
+func IsVideoFile(name string) bool {
+	return videoExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+const (
+	// DefaultScanWorkers is how many directories are read at once. Network filesystems answer
+	// each directory listing slowly but serve many in parallel.
+	DefaultScanWorkers = 16
+	// scanProgressInterval is how often a long scan logs its progress
+	scanProgressInterval = 5 * time.Second
+)
+
 type FileScanner struct {
 	rootDir string
+	Workers int // Directories read concurrently (defaults to DefaultScanWorkers)
 }
 
 func NewFileScanner(rootDir string) *FileScanner {
-	return &FileScanner{rootDir: rootDir}
+	return &FileScanner{rootDir: rootDir, Workers: DefaultScanWorkers}
 }
 
-// ScanVideoFiles recursively finds all video files in the root directory
+// scanState is shared by the workers of one scan
+type scanState struct {
+	ctx     context.Context
+	ignore  *IgnoreMatcher
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queue   []string // Directories waiting to be read
+	active  int      // Directories being read
+	files   []string
+	dirs    atomic.Int64
+	ignored atomic.Int64
+}
+
+// ScanVideoFiles recursively finds all video files in the root directory, leaving out paths
+// excluded by .mediamgmtignore files. Directories are read by a bounded pool of workers, and
+// the files are returned sorted by path.
 func (fs *FileScanner) ScanVideoFiles(ctx context.Context) ([]string, error) {
-	slog.Debug("Starting video file scan", "rootDir", fs.rootDir)
+	slog.Debug("Starting video file scan", "rootDir", fs.rootDir, "workers", fs.Workers)
+	start := time.Now()
 
-	var videoFiles []string
+	state := &scanState{ctx: ctx, ignore: NewIgnoreMatcher(fs.rootDir), queue: []string{fs.rootDir}}
+	state.cond = sync.NewCond(&state.mutex)
 
-	err := filepath.Walk(fs.rootDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			slog.Warn("Error accessing path", "path", path, "error", err)
-			return nil // Continue walking despite individual file errors
-		}
+	stopProgress := make(chan struct{})
+	go state.logProgress(stopProgress)
+	defer close(stopProgress)
 
-		if info.IsDir() {
-			return nil
-		}
+	var wg sync.WaitGroup
+	for range max(fs.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state.work()
+		}()
+	}
+	// Wake the workers if the scan is cancelled while they wait for directories
+	stopWake := context.AfterFunc(ctx, func() {
+		state.mutex.Lock()
+		state.cond.Broadcast()
+		state.mutex.Unlock()
+	})
+	wg.Wait()
+	stopWake()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(state.files)
+	slog.Info("Video file scan completed",
+		"filesFound", len(state.files),
+		"ignored", state.ignored.Load(),
+		"directories", state.dirs.Load(),
+		"duration_ms", time.Since(start).Milliseconds())
+	return state.files, nil
+}
 
-		ext := strings.ToLower(filepath.Ext(path))
-		if videoExtensions[ext] {
-			videoFiles = append(videoFiles, path)
-			slog.Debug("Found video file", "path", path, "size", info.Size())
+// work reads queued directories until none are left or the scan is cancelled
+func (s *scanState) work() {
+	for {
+		s.mutex.Lock()
+		for len(s.queue) == 0 && s.active > 0 && s.ctx.Err() == nil {
+			s.cond.Wait()
 		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		if len(s.queue) == 0 || s.ctx.Err() != nil {
+			// Nothing queued and nothing being read that could queue more
+			s.cond.Broadcast()
+			s.mutex.Unlock()
+			return
 		}
+		dir := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		s.active++
+		s.mutex.Unlock()
 
-		return nil
-	})
+		subdirs, files := s.readDir(dir)
+
+		s.mutex.Lock()
+		s.queue = append(s.queue, subdirs...)
+		s.files = append(s.files, files...)
+		s.active--
+		s.cond.Broadcast()
+		s.mutex.Unlock()
+	}
+}
 
+// readDir lists one directory, returning its subdirectories to scan and its video files
+func (s *scanState) readDir(dir string) (subdirs, files []string) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, err
+		slog.Warn("Error accessing path", "path", dir, "error", err)
+		return nil, nil // Continue scanning despite individual directory errors
 	}
+	s.dirs.Add(1)
+	s.ignore.Enter(dir)
 
-	slog.Info("Video file scan completed", "filesFound", len(videoFiles))
-	return videoFiles, nil
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if s.ignore.Ignored(path, entry.IsDir()) {
+			slog.Debug("Ignoring path", "path", path)
+			s.ignored.Add(1)
+			continue
+		}
+		if entry.IsDir() {
+			subdirs = append(subdirs, path)
+			continue
+		}
+		if videoExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+			slog.Debug("Found video file", "path", path)
+		}
+	}
+	return subdirs, files
+}
+
+// logProgress reports a long scan's progress until stop is closed
+func (s *scanState) logProgress(stop <-chan struct{}) {
+	ticker := time.NewTicker(scanProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			found, queued := len(s.files), len(s.queue)
+			s.mutex.Unlock()
+			slog.Info("Scanning for video files", "directories", s.dirs.Load(), "filesFound", found, "queued", queued)
+		}
+	}
 }
@@ -2,8 +2,10 @@ package lib
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 )
@@ -90,3 +92,39 @@ func TestVideoExtensions(t *testing.T) {
 		}
 	}
 }
+
+func TestScanVideoFilesConcurrent(t *testing.T) {
+	root := t.TempDir()
+	var want []string
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 5; j++ {
+			path := filepath.Join(root, fmt.Sprintf("show%02d", i), fmt.Sprintf("season%d", j), "e01.mkv")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			want = append(want, path)
+		}
+	}
+	sort.Strings(want)
+
+	for _, workers := range []int{1, 4, 32} {
+		scanner := NewFileScanner(root)
+		scanner.Workers = workers
+		got, err := scanner.ScanVideoFiles(context.Background())
+		if err != nil {
+			t.Fatalf("ScanVideoFiles() with %d workers error = %v", workers, err)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("ScanVideoFiles() with %d workers found %d files, want %d in order", workers, len(got), len(want))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewFileScanner(root).ScanVideoFiles(ctx); err != context.Canceled {
+		t.Errorf("ScanVideoFiles() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"media-mgmt/lib"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// analyzeRequest is the JSON body accepted by the analyze endpoint
+type analyzeRequest struct {
+	Path string `json:"path"`
+}
+
+// handleAnalyze probes a single file synchronously and returns its MediaInfo.
+// Accepts either a JSON body naming a file inside the library, or a multipart upload in the "file" field.
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var path, uploadName string
+	if mediaType == "multipart/form-data" {
+		if s.MaxUploadSize <= 0 {
+			writeError(w, http.StatusForbidden, fmt.Errorf("uploads are disabled"))
+			return
+		}
+		uploaded, name, err := s.saveUpload(w, r)
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("upload exceeds %s", lib.FormatSize(s.MaxUploadSize)))
+				return
+			}
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		defer os.Remove(uploaded)
+		path, uploadName = uploaded, name
+	} else {
+		var req analyzeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if req.Path == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("no path specified"))
+			return
+		}
+
+		resolved, err := s.resolveLibraryPath(req.Path)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		path = resolved
+	}
+
+	started := time.Now()
+	info, err := lib.NewMediaAnalyzer().AnalyzeFile(r.Context(), path)
+	entry := lib.AuditEntry{
+		User:       requester(r),
+		Source:     lib.AuditSourceAPI,
+		Command:    "analyze",
+		Args:       []string{path},
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if uploadName != "" {
+		entry.Args = []string{"upload:" + uploadName}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.Audit.Record(entry)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if uploadName != "" {
+		info.FilePath = uploadName
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+// saveUpload streams the uploaded "file" part to a temporary file and returns its path and the client's file name.
+// The original extension is kept since ffprobe uses it as a format hint.
+func (s *Server) saveUpload(w http.ResponseWriter, r *http.Request) (string, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUploadSize)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", "", fmt.Errorf("invalid multipart body: %w", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return "", "", fmt.Errorf("no file uploaded in the \"file\" field")
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		tmp, err := os.CreateTemp("", "media-mgmt-upload-*"+filepath.Ext(part.FileName()))
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create upload file: %w", err)
+		}
+		_, copyErr := io.Copy(tmp, part)
+		closeErr := tmp.Close()
+		part.Close()
+		if copyErr != nil || closeErr != nil {
+			os.Remove(tmp.Name())
+			if copyErr != nil {
+				return "", "", copyErr
+			}
+			return "", "", closeErr
+		}
+
+		slog.Debug("Saved uploaded file for analysis", "file", part.FileName(), "path", tmp.Name())
+		return tmp.Name(), filepath.Base(part.FileName()), nil
+	}
+}
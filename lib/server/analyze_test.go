@@ -0,0 +1,59 @@
+package server
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// uploadBody returns a multipart body uploading content as the "file" field, and its content type
+func uploadBody(t *testing.T, content string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("file", "clip.mkv")
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	if _, err := part.Write([]byte(content)); err != nil {
+		t.Fatalf("Failed to write form file: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close multipart writer: %v", err)
+	}
+	return buf.String(), writer.FormDataContentType()
+}
+
+func TestHandleAnalyze_Rejections(t *testing.T) {
+	small, smallType := uploadBody(t, "tiny")
+	large, largeType := uploadBody(t, strings.Repeat("x", 4096))
+	jsonType := "application/json"
+
+	tests := []struct {
+		name          string
+		maxUploadSize int64
+		header        http.Header
+		body          string
+		want          int
+	}{
+		{"viewer token", 1 << 20, http.Header{"Authorization": {"Bearer viewer-secret"}, "Content-Type": {smallType}}, small, http.StatusForbidden},
+		{"uploads disabled", 0, http.Header{"Authorization": {"Bearer operator-secret"}, "Content-Type": {smallType}}, small, http.StatusForbidden},
+		{"upload too large", 1024, http.Header{"Authorization": {"Bearer operator-secret"}, "Content-Type": {largeType}}, large, http.StatusRequestEntityTooLarge},
+		{"relative path escape", 0, http.Header{"Authorization": {"Bearer operator-secret"}, "Content-Type": {jsonType}}, `{"path": "../outside.mkv"}`, http.StatusBadRequest},
+		{"absolute path outside library", 0, http.Header{"Authorization": {"Bearer operator-secret"}, "Content-Type": {jsonType}}, `{"path": "/etc/passwd"}`, http.StatusBadRequest},
+		{"no path", 0, http.Header{"Authorization": {"Bearer operator-secret"}, "Content-Type": {jsonType}}, `{}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, testTokens...)
+			s.MaxUploadSize = tt.maxUploadSize
+			rec := serve(s, http.MethodPost, "/analyze", tt.body, tt.header)
+			if rec.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"media-mgmt/lib"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// handleAudit returns audit log entries, most recent last.
+// Accepts since (a duration such as 24h), command, user, and limit (default 100) query parameters.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := lib.AuditFilter{Command: params.Get("command"), User: params.Get("user"), Limit: 100}
+
+	if value := params.Get("since"); value != "" {
+		since, err := time.ParseDuration(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since: %w", err))
+			return
+		}
+		filter.Since = time.Now().Add(-since)
+	}
+	if value := params.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit %q", value))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	entries, err := s.Audit.Entries(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if entries == nil {
+		entries = []lib.AuditEntry{}
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
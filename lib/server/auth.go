@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"media-mgmt/lib"
+	"net/http"
+	"strings"
+)
+
+// tokenCookie carries an API token for browsers, which cannot add headers to page loads or event streams
+const tokenCookie = "media_mgmt_token"
+
+// identityKey is the request context key for the authenticated token
+type identityKey struct{}
+
+// authenticate requires a valid token on every request when tokens are configured.
+// Tokens are read from an "Authorization: Bearer" header, the token cookie, or a "token" query
+// parameter; a valid query token sets the cookie so the UI keeps working after the first visit.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if len(s.Tokens) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, fromQuery := requestToken(r)
+		token, ok := s.lookupToken(presented)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="media-mgmt"`)
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("a valid API token is required"))
+			return
+		}
+
+		if fromQuery {
+			http.SetCookie(w, &http.Cookie{
+				Name:     tokenCookie,
+				Value:    token.Token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, token)))
+	})
+}
+
+// requestToken extracts the presented token and reports whether it came from the query string
+func requestToken(r *http.Request) (string, bool) {
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer "), false
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token, true
+	}
+	if cookie, err := r.Cookie(tokenCookie); err == nil {
+		return cookie.Value, false
+	}
+	return "", false
+}
+
+// lookupToken finds the configured token matching the presented value in constant time
+func (s *Server) lookupToken(presented string) (lib.APIToken, bool) {
+	if presented == "" {
+		return lib.APIToken{}, false
+	}
+	for _, token := range s.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token.Token), []byte(presented)) == 1 {
+			return token, true
+		}
+	}
+	return lib.APIToken{}, false
+}
+
+// requireOperator rejects requests from tokens without the operator role.
+// Always allows requests when no tokens are configured.
+func (s *Server) requireOperator(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := r.Context().Value(identityKey{}).(lib.APIToken); ok && token.Role != lib.RoleOperator {
+			writeError(w, http.StatusForbidden, fmt.Errorf("token %q is %s-only; an operator token is required", token.Name, token.Role))
+			return
+		}
+		next(w, r)
+	}
+}
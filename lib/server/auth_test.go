@@ -0,0 +1,119 @@
+package server
+
+import (
+	"media-mgmt/lib"
+	"net/http"
+	"testing"
+)
+
+var testTokens = []lib.APIToken{
+	{Name: "tv", Token: "viewer-secret", Role: lib.RoleViewer},
+	{Name: "admin", Token: "operator-secret", Role: lib.RoleOperator},
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestAuthenticate_RequiresValidToken(t *testing.T) {
+	s := newTestServer(t, testTokens...)
+
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   int
+	}{
+		{"missing token", "/api/media", nil, http.StatusUnauthorized},
+		{"invalid bearer token", "/api/media", bearer("wrong"), http.StatusUnauthorized},
+		{"invalid query token", "/api/media?token=wrong", nil, http.StatusUnauthorized},
+		{"invalid cookie", "/api/media", http.Header{"Cookie": {tokenCookie + "=wrong"}}, http.StatusUnauthorized},
+		{"viewer bearer token", "/api/media", bearer("viewer-secret"), http.StatusOK},
+		{"operator cookie", "/api/media", http.Header{"Cookie": {tokenCookie + "=operator-secret"}}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, http.MethodGet, tt.target, "", tt.header)
+			if rec.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+			if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
+
+func TestAuthenticate_QueryTokenSetsCookie(t *testing.T) {
+	s := newTestServer(t, testTokens...)
+
+	rec := serve(s, http.MethodGet, "/?token=viewer-secret", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected one cookie, got %v", cookies)
+	}
+	cookie := cookies[0]
+	if cookie.Name != tokenCookie || cookie.Value != "viewer-secret" {
+		t.Errorf("Expected %s=viewer-secret, got %s=%s", tokenCookie, cookie.Name, cookie.Value)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Expected an HttpOnly SameSite=Strict cookie, got %+v", cookie)
+	}
+
+	rec = serve(s, http.MethodGet, "/", "", bearer("viewer-secret"))
+	if len(rec.Result().Cookies()) != 0 {
+		t.Error("Expected no cookie when the token comes from a header")
+	}
+}
+
+func TestRequireOperator(t *testing.T) {
+	body := `{"files": ["movie.mkv"]}`
+
+	tests := []struct {
+		name   string
+		tokens []lib.APIToken
+		header http.Header
+		want   int
+	}{
+		{"viewer token", testTokens, bearer("viewer-secret"), http.StatusForbidden},
+		{"operator token", testTokens, bearer("operator-secret"), http.StatusAccepted},
+		{"missing token", testTokens, nil, http.StatusUnauthorized},
+		{"no tokens configured", nil, nil, http.StatusAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.tokens...)
+			rec := serve(s, http.MethodPost, "/api/transcode", body, tt.header)
+			if rec.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+			queued := len(s.jobs.snapshot())
+			if tt.want == http.StatusAccepted && queued != 1 {
+				t.Errorf("Expected one queued job, got %d", queued)
+			}
+			if tt.want != http.StatusAccepted && queued != 0 {
+				t.Errorf("Expected no queued jobs, got %d", queued)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_OpenWithoutTokens(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, target := range []string{"/", "/api/media", "/api/transcode/jobs"} {
+		rec := serve(s, http.MethodGet, target, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status 200 for %s, got %d: %s", target, rec.Code, rec.Body)
+		}
+		if len(rec.Result().Cookies()) != 0 {
+			t.Errorf("Expected no cookie for %s", target)
+		}
+	}
+}
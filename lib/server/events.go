@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// event is a single server-sent event ready to be written to subscribers
+type event struct {
+	name string
+	data []byte
+}
+
+// eventHub fans out events to all connected server-sent event subscribers.
+// Remembers the latest event per name so new subscribers immediately see current state.
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[chan event]struct{}
+	latest      map[string]event
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[chan event]struct{}),
+		latest:      make(map[string]event),
+	}
+}
+
+// publish encodes a value as JSON and delivers it to all subscribers.
+// Slow subscribers drop events rather than blocking the publisher.
+func (h *eventHub) publish(name string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.Debug("Failed to marshal event", "event", name, "error", err)
+		return
+	}
+	ev := event{name: name, data: data}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.latest[name] = ev
+	for ch := range h.subscribers {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// subscribe registers a new subscriber, returning its channel, the current state, and an unsubscribe func
+func (h *eventHub) subscribe() (chan event, []event, func()) {
+	ch := make(chan event, 64)
+
+	h.mutex.Lock()
+	h.subscribers[ch] = struct{}{}
+	snapshot := make([]event, 0, len(h.latest))
+	for _, ev := range h.latest {
+		snapshot = append(snapshot, ev)
+	}
+	h.mutex.Unlock()
+
+	return ch, snapshot, func() {
+		h.mutex.Lock()
+		delete(h.subscribers, ch)
+		h.mutex.Unlock()
+	}
+}
+
+// serveEvents streams hub events to the client using the server-sent events protocol
+func (h *eventHub) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch, snapshot, unsubscribe := h.subscribe()
+	defer unsubscribe()
+
+	for _, ev := range snapshot {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-ch:
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a single event in server-sent events wire format
+func writeEvent(w http.ResponseWriter, ev event) error {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+	return err
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// subscriberCount returns how many subscribers the hub has
+func (h *eventHub) subscriberCount() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.subscribers)
+}
+
+func TestEventHub_PublishSubscribe(t *testing.T) {
+	hub := newEventHub()
+	first, _, unsubscribeFirst := hub.subscribe()
+	second, _, unsubscribeSecond := hub.subscribe()
+	defer unsubscribeSecond()
+
+	hub.publish("job", map[string]string{"id": "1"})
+	for _, ch := range []chan event{first, second} {
+		select {
+		case ev := <-ch:
+			if ev.name != "job" || string(ev.data) != `{"id":"1"}` {
+				t.Errorf("Unexpected event %s: %s", ev.name, ev.data)
+			}
+		default:
+			t.Fatal("Expected every subscriber to receive the event")
+		}
+	}
+
+	unsubscribeFirst()
+	hub.publish("job", map[string]string{"id": "2"})
+	select {
+	case ev := <-first:
+		t.Errorf("Expected no events after unsubscribing, got %s", ev.data)
+	default:
+	}
+	if ev := <-second; string(ev.data) != `{"id":"2"}` {
+		t.Errorf("Unexpected event %s", ev.data)
+	}
+
+	// A subscriber that stops reading must not block the publisher
+	for i := 0; i < 200; i++ {
+		hub.publish("progress", i)
+	}
+}
+
+func TestEventHub_ReplaysLatestToNewSubscribers(t *testing.T) {
+	hub := newEventHub()
+	hub.publish("job", map[string]string{"status": JobQueued})
+	hub.publish("job", map[string]string{"status": JobRunning})
+	hub.publish("progress", map[string]int{"percent": 40})
+
+	_, snapshot, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	latest := make(map[string]string)
+	for _, ev := range snapshot {
+		latest[ev.name] = string(ev.data)
+	}
+	if len(snapshot) != 2 || latest["job"] != `{"status":"running"}` || latest["progress"] != `{"percent":40}` {
+		t.Errorf("Expected the latest job and progress events, got %v", latest)
+	}
+}
+
+func TestEventHub_ServeEvents(t *testing.T) {
+	hub := newEventHub()
+	hub.publish("job", map[string]string{"status": JobQueued})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/api/transcode/events", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		hub.serveEvents(rec, req)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.subscriberCount() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the client to subscribe")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	hub.publish("progress", map[string]int{"percent": 40})
+
+	// Disconnecting the client must unsubscribe it
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for serveEvents to return after the client disconnected")
+	}
+	if count := hub.subscriberCount(); count != 0 {
+		t.Errorf("Expected no subscribers after disconnect, got %d", count)
+	}
+
+	if got := rec.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Unexpected content type %q", got)
+	}
+	body := rec.Body.String()
+	if !strings.HasPrefix(body, "event: job\ndata: {\"status\":\"queued\"}\n\n") {
+		t.Errorf("Expected the latest job event to be replayed first, got %q", body)
+	}
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job is a batch of files queued for transcoding in serve mode
+type Job struct {
+	ID          string            `json:"id"`
+	Files       []string          `json:"files"`
+	Status      string            `json:"status"`
+	Error       string            `json:"error,omitempty"`
+	Failures    []lib.FileFailure `json:"failures,omitempty"` // Files that failed, with how each failure was classified
+	RequestedBy string            `json:"requested_by,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	StartedAt   *time.Time        `json:"started_at,omitempty"`
+	FinishedAt  *time.Time        `json:"finished_at,omitempty"`
+}
+
+// TranscoderFactory creates a configured transcoder for the given files
+type TranscoderFactory func(files []string) *handbrake.HandBrakeTranscoder
+
+// jobQueue runs transcode jobs one at a time in submission order
+type jobQueue struct {
+	mutex   sync.RWMutex
+	jobs    []*Job
+	pending chan *Job
+	nextID  int
+	running *Job                           // Job being transcoded (nil when idle)
+	active  *handbrake.HandBrakeTranscoder // Transcoder of the running job
+}
+
+func newJobQueue() *jobQueue {
+	return &jobQueue{pending: make(chan *Job, 100)}
+}
+
+// enqueue adds a new job for the given files and returns a copy, failing if the queue is full
+func (q *jobQueue) enqueue(files []string, requestedBy string) (Job, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.nextID++
+	job := &Job{
+		ID:          fmt.Sprintf("%d", q.nextID),
+		Files:       files,
+		Status:      JobQueued,
+		RequestedBy: requestedBy,
+		CreatedAt:   time.Now(),
+	}
+
+	select {
+	case q.pending <- job:
+	default:
+		return Job{}, fmt.Errorf("job queue is full")
+	}
+
+	q.jobs = append(q.jobs, job)
+	return *job, nil
+}
+
+// snapshot returns copies of all known jobs
+func (q *jobQueue) snapshot() []Job {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+
+	jobs := make([]Job, len(q.jobs))
+	for i, job := range q.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// update applies a change to a job while holding the queue lock and returns a copy
+func (q *jobQueue) update(job *Job, change func(*Job)) Job {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	change(job)
+	return *job
+}
+
+// setActive records the job being transcoded and its transcoder, or that none is
+func (q *jobQueue) setActive(job *Job, transcoder *handbrake.HandBrakeTranscoder) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.running, q.active = job, transcoder
+}
+
+// runJobs processes queued jobs until the context is cancelled
+func (s *Server) runJobs(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.jobs.pending:
+			s.runJob(ctx, job)
+		}
+	}
+}
+
+// runJob transcodes a single job, publishing job state and per-file progress events
+func (s *Server) runJob(ctx context.Context, job *Job) {
+	s.events.publish("job", s.jobs.update(job, func(j *Job) {
+		now := time.Now()
+		j.Status = JobRunning
+		j.StartedAt = &now
+	}))
+
+	transcoder := s.NewTranscoder(job.Files)
+	s.jobs.setActive(job, transcoder)
+	defer s.jobs.setActive(nil, nil)
+	var affected []string
+	transcoder.OnProgress = func(progress handbrake.Progress) {
+		if progress.Stage == handbrake.StageDone {
+			affected = append(affected, transcoder.OutputPath(progress.File))
+		}
+		s.events.publish("progress", struct {
+			JobID string `json:"job_id"`
+			handbrake.Progress
+		}{job.ID, progress})
+	}
+
+	slog.Info("Starting transcode job", "job_id", job.ID, "files", len(job.Files))
+	started := time.Now()
+	err := transcoder.Run(ctx)
+	// Run only fails when the batch could not run at all; files that failed are in the result
+	failures := transcoder.Result().Failures
+	if err == nil && len(failures) > 0 {
+		err = fmt.Errorf("%d of %d files failed", len(failures), len(job.Files))
+	}
+
+	s.events.publish("job", s.jobs.update(job, func(j *Job) {
+		now := time.Now()
+		j.FinishedAt = &now
+		j.Failures = failures
+		if err != nil {
+			j.Status = JobFailed
+			j.Error = err.Error()
+		} else {
+			j.Status = JobCompleted
+		}
+	}))
+
+	entry := lib.AuditEntry{
+		User:       job.RequestedBy,
+		Source:     lib.AuditSourceAPI,
+		Command:    "transcode",
+		Flags:      map[string]string{"job_id": job.ID},
+		Args:       job.Files,
+		Files:      affected,
+		DurationMS: time.Since(started).Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.Audit.Record(entry)
+
+	if err != nil {
+		slog.Error("Transcode job failed", "job_id", job.ID, "duration_ms", time.Since(started).Milliseconds(), "error", err)
+	} else {
+		slog.Info("Transcode job completed", "job_id", job.ID, "duration_ms", time.Since(started).Milliseconds())
+	}
+}
+
+// transcodeRequest is the body accepted by the transcode endpoint
+type transcodeRequest struct {
+	Files []string `json:"files"`
+}
+
+func (s *Server) handleTranscode(w http.ResponseWriter, r *http.Request) {
+	var req transcodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if len(req.Files) == 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("no files specified"))
+		return
+	}
+
+	files := make([]string, 0, len(req.Files))
+	for _, file := range req.Files {
+		path, err := s.resolveLibraryPath(file)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		files = append(files, path)
+	}
+
+	job, err := s.jobs.enqueue(files, requester(r))
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	s.events.publish("job", job)
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// requester identifies the client making an API request for the audit log,
+// using the token name when the request was authenticated
+func requester(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if token, ok := r.Context().Value(identityKey{}).(lib.APIToken); ok {
+		return token.Name + "@" + host
+	}
+	return "api@" + host
+}
+
+func (s *Server) handleJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.jobs.snapshot())
+}
+
+// transcodeStatus is the progress of the running job, for clients that poll rather than
+// follow the event stream
+type transcodeStatus struct {
+	JobID string `json:"job_id,omitempty"`
+	handbrake.BatchStatus
+}
+
+func (s *Server) handleTranscodeStatus(w http.ResponseWriter, r *http.Request) {
+	s.jobs.mutex.RLock()
+	job, transcoder := s.jobs.running, s.jobs.active
+	s.jobs.mutex.RUnlock()
+
+	if transcoder == nil {
+		writeJSON(w, http.StatusOK, transcodeStatus{BatchStatus: handbrake.BatchStatus{State: handbrake.BatchIdle}})
+		return
+	}
+	writeJSON(w, http.StatusOK, transcodeStatus{JobID: job.ID, BatchStatus: transcoder.Status()})
+}
+
+// resolveLibraryPath validates that a path refers to an existing file inside the input directory
+func (s *Server) resolveLibraryPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.InputDir, path)
+	}
+	path = filepath.Clean(path)
+
+	root, err := filepath.Abs(s.InputDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve input directory: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %w", path, err)
+	}
+	if abs != root && !strings.HasPrefix(abs, root+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %s is outside the library", path)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	return abs, nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"media-mgmt/lib/handbrake"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveLibraryPath(t *testing.T) {
+	s := newTestServer(t)
+	outside := filepath.Join(filepath.Dir(s.InputDir), "outside.mkv")
+	if err := os.WriteFile(outside, []byte("video data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(s.InputDir, "season"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	movie := filepath.Join(s.InputDir, "movie.mkv")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"relative path", "movie.mkv", movie, false},
+		{"absolute path", movie, movie, false},
+		{"dot segments inside the library", "season/../movie.mkv", movie, false},
+		{"relative escape", "../outside.mkv", "", true},
+		{"nested relative escape", "season/../../outside.mkv", "", true},
+		{"absolute escape", outside, "", true},
+		{"absolute escape through the library", filepath.Join(s.InputDir, "..", "outside.mkv"), "", true},
+		{"sibling with the library as a prefix", s.InputDir + "-other/movie.mkv", "", true},
+		{"directory", "season", "", true},
+		{"missing file", "missing.mkv", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.resolveLibraryPath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTranscodeJobStates(t *testing.T) {
+	tests := []struct {
+		name      string
+		handBrake string // Script installed as HandBrakeCLI ("" leaves it missing)
+		encode    bool   // Write outputs to a separate directory, so the file is not already done
+		want      string
+		wantError string
+	}{
+		{
+			// The output path is the input itself, so the file is skipped as already transcoded
+			name:      "completed",
+			handBrake: "#!/bin/sh\necho 'HandBrake 1.8.0'\necho '  x264 x265'\n",
+			want:      JobCompleted,
+		},
+		{
+			// Without ffprobe the file cannot be probed, so the batch runs but the file fails
+			name:      "file failed",
+			handBrake: "#!/bin/sh\necho 'HandBrake 1.8.0'\necho '  x264 x265'\n",
+			encode:    true,
+			want:      JobFailed,
+			wantError: "1 of 1 files failed",
+		},
+		{
+			name:      "failed",
+			want:      JobFailed,
+			wantError: "HandBrakeCLI not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := t.TempDir()
+			if tt.handBrake != "" {
+				if err := os.WriteFile(filepath.Join(bin, "HandBrakeCLI"), []byte(tt.handBrake), 0755); err != nil {
+					t.Fatalf("Failed to write HandBrakeCLI: %v", err)
+				}
+			}
+			t.Setenv("PATH", bin)
+			t.Setenv("MEDIA_MGMT_STATE_DIR", t.TempDir())
+
+			s := newTestServer(t)
+			if tt.encode {
+				outputDir := t.TempDir()
+				s.NewTranscoder = func(files []string) *handbrake.HandBrakeTranscoder {
+					return &handbrake.HandBrakeTranscoder{Files: files, InputRoot: s.InputDir, OutputDir: outputDir}
+				}
+			}
+			events, _, unsubscribe := s.events.subscribe()
+			defer unsubscribe()
+
+			rec := serve(s, http.MethodPost, "/api/transcode", `{"files": ["movie.mkv"]}`, nil)
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("Expected status 202, got %d: %s", rec.Code, rec.Body)
+			}
+			var queued Job
+			if err := json.Unmarshal(rec.Body.Bytes(), &queued); err != nil {
+				t.Fatalf("Failed to decode job: %v", err)
+			}
+			if queued.Status != JobQueued || queued.RequestedBy == "" {
+				t.Errorf("Expected a queued job recording its requester, got %+v", queued)
+			}
+
+			s.runJob(context.Background(), <-s.jobs.pending)
+			unsubscribe()
+
+			var statuses []string
+			var final Job
+			for len(events) > 0 {
+				ev := <-events
+				if ev.name != "job" {
+					continue
+				}
+				if err := json.Unmarshal(ev.data, &final); err != nil {
+					t.Fatalf("Failed to decode job event: %v", err)
+				}
+				if final.ID != queued.ID {
+					t.Errorf("Expected events for job %s, got %s", queued.ID, final.ID)
+				}
+				statuses = append(statuses, final.Status)
+			}
+			if want := JobQueued + "," + JobRunning + "," + tt.want; strings.Join(statuses, ",") != want {
+				t.Errorf("Expected job states %s, got %s", want, strings.Join(statuses, ","))
+			}
+			if final.StartedAt == nil || final.FinishedAt == nil {
+				t.Errorf("Expected start and finish times, got %+v", final)
+			}
+			if tt.wantError == "" && final.Error != "" || !strings.Contains(final.Error, tt.wantError) {
+				t.Errorf("Expected error containing %q, got %q", tt.wantError, final.Error)
+			}
+			if tt.encode && len(final.Failures) != 1 {
+				t.Errorf("Expected the failed file in the job, got %+v", final.Failures)
+			}
+
+			jobs := s.jobs.snapshot()
+			if len(jobs) != 1 || jobs[0].Status != tt.want {
+				t.Errorf("Expected the job list to show the job %s, got %+v", tt.want, jobs)
+			}
+			if s.jobs.running != nil || s.jobs.active != nil {
+				t.Error("Expected no active job once the job finished")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"media-mgmt/lib"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// handleQuery filters, sorts, and aggregates the library without sending the full dataset.
+// Example: /media?codec=h264&min_bitrate=8000000&sort=-size&limit=50&agg=sum_size,count
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	query, err := parseMediaQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := s.db.Query(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.mutex.RLock()
+	items := make([]*lib.MediaInfo, 0, len(result.Paths))
+	for _, path := range result.Paths {
+		if info, ok := s.media[path]; ok {
+			items = append(items, info)
+		}
+	}
+	s.mutex.RUnlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		*lib.MediaQueryResult
+		Items []*lib.MediaInfo `json:"items"`
+	}{result, items})
+}
+
+// parseMediaQuery converts URL query parameters into a media query
+func parseMediaQuery(values url.Values) (lib.MediaQuery, error) {
+	var q lib.MediaQuery
+	var err error
+
+	for _, codec := range values["codec"] {
+		q.Codecs = append(q.Codecs, strings.Split(codec, ",")...)
+	}
+	q.PathContains = values.Get("path")
+	q.AudioLanguage = values.Get("audio_language")
+	q.Sort = values.Get("sort")
+	q.GroupBy = values.Get("group_by")
+	for _, agg := range values["agg"] {
+		q.Aggregates = append(q.Aggregates, strings.Split(agg, ",")...)
+	}
+
+	intParams := []struct {
+		name string
+		dest *int64
+	}{
+		{"min_bitrate", &q.MinBitrate},
+		{"max_bitrate", &q.MaxBitrate},
+		{"min_size", &q.MinSize},
+		{"max_size", &q.MaxSize},
+	}
+	for _, param := range intParams {
+		if value := values.Get(param.name); value != "" {
+			if *param.dest, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return q, fmt.Errorf("invalid %s: %q", param.name, value)
+			}
+		}
+	}
+
+	smallIntParams := []struct {
+		name string
+		dest *int
+	}{
+		{"min_height", &q.MinHeight},
+		{"max_height", &q.MaxHeight},
+		{"limit", &q.Limit},
+		{"offset", &q.Offset},
+	}
+	for _, param := range smallIntParams {
+		if value := values.Get(param.name); value != "" {
+			if *param.dest, err = strconv.Atoi(value); err != nil || *param.dest < 0 {
+				return q, fmt.Errorf("invalid %s: %q", param.name, value)
+			}
+		}
+	}
+
+	floatParams := []struct {
+		name string
+		dest *float64
+	}{
+		{"min_duration", &q.MinDuration},
+		{"max_duration", &q.MaxDuration},
+	}
+	for _, param := range floatParams {
+		if value := values.Get(param.name); value != "" {
+			if *param.dest, err = strconv.ParseFloat(value, 64); err != nil {
+				return q, fmt.Errorf("invalid %s: %q", param.name, value)
+			}
+		}
+	}
+
+	return q, q.Validate()
+}
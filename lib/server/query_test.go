@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"media-mgmt/lib"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseMediaQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    lib.MediaQuery
+		wantErr bool
+	}{
+		{
+			name:  "filters, sort, and paging",
+			query: "codec=h264,hevc&min_height=720&sort=-size&limit=50&offset=100&agg=count,sum_size",
+			want: lib.MediaQuery{
+				Codecs:     []string{"h264", "hevc"},
+				MinHeight:  720,
+				Sort:       "-size",
+				Limit:      50,
+				Offset:     100,
+				Aggregates: []string{"count", "sum_size"},
+			},
+		},
+		{name: "zero limit and offset", query: "limit=0&offset=0", want: lib.MediaQuery{}},
+		{name: "non-numeric limit", query: "limit=ten", wantErr: true},
+		{name: "fractional limit", query: "limit=1.5", wantErr: true},
+		{name: "negative limit", query: "limit=-1", wantErr: true},
+		{name: "non-numeric offset", query: "offset=abc", wantErr: true},
+		{name: "negative offset", query: "offset=-10", wantErr: true},
+		{name: "limit with SQL", query: "limit=" + url.QueryEscape("10; DROP TABLE media"), wantErr: true},
+		{name: "invalid bitrate", query: "min_bitrate=fast", wantErr: true},
+		{name: "invalid duration", query: "max_duration=long", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("Failed to parse query: %v", err)
+			}
+			got, err := parseMediaQuery(values)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHandleQuery(t *testing.T) {
+	s := newTestServer(t)
+	db, err := lib.OpenMediaDB(":memory:")
+	if err != nil {
+		t.Fatalf("OpenMediaDB failed: %v", err)
+	}
+	defer db.Close()
+	s.db = db
+
+	mediaInfos := []*lib.MediaInfo{
+		{FilePath: "/media/a.mkv", FileSize: 9 << 30, VideoCodec: "h264"},
+		{FilePath: "/media/b.mkv", FileSize: 2 << 30, VideoCodec: "h264"},
+		{FilePath: "/media/c.mkv", FileSize: 5 << 30, VideoCodec: "hevc"},
+	}
+	if err := db.Upsert(context.Background(), mediaInfos); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	for _, info := range mediaInfos {
+		s.media[info.FilePath] = info
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		want      int
+		wantPaths []string
+	}{
+		{"sorted page", "sort=-size&limit=2", http.StatusOK, []string{"/media/a.mkv", "/media/c.mkv"}},
+		{"offset", "sort=size&offset=1", http.StatusOK, []string{"/media/c.mkv", "/media/a.mkv"}},
+		{"unknown sort field", "sort=color", http.StatusBadRequest, nil},
+		{"sort injection", "sort=" + url.QueryEscape("size; DROP TABLE media; --"), http.StatusBadRequest, nil},
+		{"sort injection through descending prefix", "sort=" + url.QueryEscape("-file_size DESC, (SELECT 1)"), http.StatusBadRequest, nil},
+		{"negative limit", "limit=-1", http.StatusBadRequest, nil},
+		{"unknown aggregate", "agg=median_size", http.StatusBadRequest, nil},
+		{"unknown group field", "agg=count&group_by=color", http.StatusBadRequest, nil},
+		{"path filter is not SQL", "path=" + url.QueryEscape("' OR 1=1 --"), http.StatusOK, nil},
+		{"table intact after injection attempts", "sort=path", http.StatusOK, []string{"/media/a.mkv", "/media/b.mkv", "/media/c.mkv"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(s, http.MethodGet, "/media?"+tt.query, "", nil)
+			if rec.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				Items []*lib.MediaInfo `json:"items"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			var paths []string
+			for _, item := range result.Items {
+				paths = append(paths, item.FilePath)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("Expected %v, got %v", tt.wantPaths, paths)
+			}
+		})
+	}
+
+	db.Close()
+	if rec := serve(s, http.MethodGet, "/media?sort=size", "", nil); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status %d from a closed database, got %d", http.StatusInternalServerError, rec.Code)
+	}
+}
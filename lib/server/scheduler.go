@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// ScheduleStatus reports the state of a scheduled analyze run
+type ScheduleStatus struct {
+	lib.ScheduleConfig
+	Running    bool       `json:"running"`
+	LastRun    *time.Time `json:"last_run,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+	NextRun    *time.Time `json:"next_run,omitempty"`
+	RunCount   int        `json:"run_count"`
+	SkipCount  int        `json:"skip_count"` // Runs skipped because the previous one was still going
+	DurationMS int64      `json:"duration_ms,omitempty"`
+}
+
+// scheduler runs analyze jobs on cron schedules, never running two jobs for the same library at once
+type scheduler struct {
+	cron     *cron.Cron
+	mutex    sync.Mutex
+	statuses []*ScheduleStatus
+	entries  []cron.EntryID
+	locks    map[string]*sync.Mutex // Keyed by absolute input directory
+}
+
+// newScheduler validates the schedules and registers them with a cron runner.
+// Returns an error if any cron expression is invalid.
+func (s *Server) newScheduler(ctx context.Context) (*scheduler, error) {
+	sched := &scheduler{
+		cron:  cron.New(),
+		locks: make(map[string]*sync.Mutex),
+	}
+
+	for _, config := range s.Schedules {
+		status := &ScheduleStatus{ScheduleConfig: config}
+
+		input, err := filepath.Abs(config.Input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve input for schedule %s: %w", config.Name, err)
+		}
+		if sched.locks[input] == nil {
+			sched.locks[input] = &sync.Mutex{}
+		}
+		lock := sched.locks[input]
+
+		id, err := sched.cron.AddFunc(config.Cron, func() {
+			s.runScheduled(ctx, sched, status, lock)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q for schedule %s: %w", config.Cron, config.Name, err)
+		}
+		sched.statuses = append(sched.statuses, status)
+		sched.entries = append(sched.entries, id)
+	}
+
+	return sched, nil
+}
+
+// start runs the scheduler until the context is cancelled
+func (sched *scheduler) start(ctx context.Context) {
+	sched.cron.Start()
+	<-ctx.Done()
+	<-sched.cron.Stop().Done()
+}
+
+// snapshot returns copies of all schedule statuses with their next run times
+func (sched *scheduler) snapshot() []ScheduleStatus {
+	sched.mutex.Lock()
+	defer sched.mutex.Unlock()
+
+	statuses := make([]ScheduleStatus, len(sched.statuses))
+	for i, status := range sched.statuses {
+		statuses[i] = *status
+		if next := sched.cron.Entry(sched.entries[i]).Next; !next.IsZero() {
+			statuses[i].NextRun = &next
+		}
+	}
+	return statuses
+}
+
+// runScheduled performs one analyze run for a schedule, skipping it if the library is already being analyzed
+func (s *Server) runScheduled(ctx context.Context, sched *scheduler, status *ScheduleStatus, lock *sync.Mutex) {
+	if !lock.TryLock() {
+		sched.mutex.Lock()
+		status.SkipCount++
+		sched.mutex.Unlock()
+		slog.Warn("Skipping scheduled analysis, previous run still in progress", "schedule", status.Name)
+		return
+	}
+	defer lock.Unlock()
+
+	started := time.Now()
+	sched.mutex.Lock()
+	status.Running = true
+	sched.mutex.Unlock()
+
+	slog.Info("Starting scheduled analysis", "schedule", status.Name, "input", status.Input)
+	app := &lib.App{
+		InputDir:     status.Input,
+		OutputDir:    status.Output,
+		Parallelism:  s.Parallelism,
+		NoCache:      status.NoCache,
+		Cache:        s.Cache,
+		ProbeTimeout: lib.DefaultProbeTimeout,
+		ProbeRetries: lib.DefaultProbeRetries,
+		History:      s.History,
+		Snapshots:    s.Snapshots,
+		Library:      status.Name,
+		Branding:     s.Branding,
+		Filters:      s.Filters,
+		Hooks:        s.Hooks,
+	}
+	err := app.Run(ctx)
+	elapsed := time.Since(started)
+
+	entry := lib.AuditEntry{
+		User:       "scheduler",
+		Source:     lib.AuditSourceScheduler,
+		Command:    "analyze",
+		Flags:      map[string]string{"schedule": status.Name, "input": status.Input, "output": status.Output},
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	s.Audit.Record(entry)
+
+	sched.mutex.Lock()
+	status.Running = false
+	status.LastRun = &started
+	status.RunCount++
+	status.DurationMS = elapsed.Milliseconds()
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	sched.mutex.Unlock()
+
+	if err != nil {
+		slog.Error("Scheduled analysis failed", "schedule", status.Name, "duration_ms", elapsed.Milliseconds(), "error", err)
+		return
+	}
+	slog.Info("Scheduled analysis completed", "schedule", status.Name, "duration_ms", elapsed.Milliseconds())
+}
+
+func (s *Server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.scheduler.snapshot())
+}
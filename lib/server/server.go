@@ -0,0 +1,320 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"media-mgmt/lib"
+	"media-mgmt/lib/report"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileState captures the attributes used to detect changed files between scans
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Server hosts the interactive report UI against live library data.
+// Keeps analysis results in memory and periodically rescans the input directory,
+// analyzing only new or changed files so the UI stays current.
+type Server struct {
+	Addr            string               // Listen address (e.g., ":8080")
+	InputDir        string               // Directory to scan for video files
+	Processor       *lib.MediaProcessor  // Processor used to analyze new or changed files
+	WatchInterval   time.Duration        // How often to rescan the input directory (0 disables)
+	RefreshInterval time.Duration        // How often the UI polls for updated data (0 disables)
+	NewTranscoder   TranscoderFactory    // Creates transcoders for queued jobs (nil disables transcoding)
+	Schedules       []lib.ScheduleConfig // Recurring analyze runs for report generation (optional)
+	Snapshots       *lib.SnapshotStore   // Store for library snapshots taken by scheduled runs, shared with analyze (nil disables)
+	Parallelism     int                  // Number of workers for scheduled analyze runs
+	History         *lib.HistoryStore    // Ledger for analyses performed by scheduled runs (nil disables)
+	MaxUploadSize   int64                // Largest file accepted for on-demand analysis uploads (0 disables uploads)
+	Audit           *lib.AuditLog        // Log of actions taken through the API and schedules (nil disables)
+	Tokens          []lib.APIToken       // API tokens and their roles (empty leaves the API open)
+	Branding        lib.ReportBranding   // Title, logo, and notes for the UI page and scheduled reports
+	Filters         []lib.SavedFilter    // Saved filters offered as views in the UI and scheduled reports
+	Hooks           []lib.ReportHook     // Commands run after each scheduled report is generated
+	Features        *lib.FeatureReport   // What the host supports, served at /api/capabilities (nil disables)
+	Cache           lib.CacheOptions     // Analysis cache location and storage for scheduled runs
+
+	scheduler *scheduler
+	db        *lib.MediaDB
+
+	jobs      *jobQueue
+	events    *eventHub
+	mutex     sync.RWMutex
+	media     map[string]*lib.MediaInfo
+	states    map[string]fileState
+	updatedAt time.Time
+	page      string
+	branding  lib.ReportBranding
+}
+
+// Run performs an initial scan, starts the watcher, and serves HTTP until the context is cancelled
+func (s *Server) Run(ctx context.Context) error {
+	s.media = make(map[string]*lib.MediaInfo)
+	s.states = make(map[string]fileState)
+	s.jobs = newJobQueue()
+	s.events = newEventHub()
+
+	db, err := lib.OpenMediaDB(":memory:")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	s.db = db
+
+	if err := s.buildPage(); err != nil {
+		return err
+	}
+
+	if len(s.Schedules) > 0 {
+		if s.scheduler, err = s.newScheduler(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := s.sync(ctx); err != nil {
+		return fmt.Errorf("initial scan failed: %w", err)
+	}
+
+	if s.WatchInterval > 0 {
+		go s.watch(ctx)
+	}
+	if s.NewTranscoder != nil {
+		go s.runJobs(ctx)
+	}
+	if s.scheduler != nil {
+		go s.scheduler.start(ctx)
+	}
+
+	httpServer := &http.Server{
+		Addr:    s.Addr,
+		Handler: s.routes(),
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Failed to shut down HTTP server cleanly", "error", err)
+		}
+	}()
+
+	slog.Info("Serving live media report", "addr", s.Addr, "watchInterval", s.WatchInterval)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("HTTP server failed: %w", err)
+	}
+	return nil
+}
+
+// buildPage compiles the UI bundle and renders the page served at /, which loads library data
+// from the media API
+func (s *Server) buildPage() error {
+	jsBundle, err := lib.NewUIBuilder().BuildLiveBundle("/api/media", s.RefreshInterval)
+	if err != nil {
+		return fmt.Errorf("failed to build UI bundle: %w", err)
+	}
+	s.branding = s.Branding.ForHTML()
+	page, err := lib.RenderHTMLPage(s.branding.Title, jsBundle)
+	if err != nil {
+		return fmt.Errorf("failed to render UI page: %w", err)
+	}
+	s.page = page
+	return nil
+}
+
+// routes registers the HTTP handlers served by the server
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /api/media", s.handleMedia)
+	mux.HandleFunc("GET /api/report", s.handleReport)
+	mux.HandleFunc("POST /analyze", s.requireOperator(s.handleAnalyze))
+	mux.HandleFunc("GET /media", s.handleQuery)
+	if s.Audit != nil {
+		mux.HandleFunc("GET /api/audit", s.handleAudit)
+	}
+	if s.NewTranscoder != nil {
+		mux.HandleFunc("POST /api/transcode", s.requireOperator(s.handleTranscode))
+		mux.HandleFunc("GET /api/transcode/jobs", s.handleJobs)
+		mux.HandleFunc("GET /api/transcode/events", s.events.serveEvents)
+		mux.HandleFunc("GET /api/transcode/status", s.handleTranscodeStatus)
+	}
+	if s.scheduler != nil {
+		mux.HandleFunc("GET /api/schedules", s.handleSchedules)
+	}
+	if s.Features != nil {
+		mux.HandleFunc("GET /api/capabilities", s.handleCapabilities)
+	}
+	return s.authenticate(mux)
+}
+
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write([]byte(s.page)); err != nil {
+		slog.Debug("Failed to write index page", "error", err)
+	}
+}
+
+// snapshotMedia returns the files currently in the library and when they were last updated
+func (s *Server) snapshotMedia() ([]*lib.MediaInfo, time.Time) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	mediaInfos := make([]*lib.MediaInfo, 0, len(s.media))
+	for _, info := range s.media {
+		mediaInfos = append(mediaInfos, info)
+	}
+	return mediaInfos, s.updatedAt
+}
+
+func (s *Server) handleMedia(w http.ResponseWriter, r *http.Request) {
+	mediaInfos, updatedAt := s.snapshotMedia()
+
+	mediaData := lib.BuildMediaData(s.InputDir, mediaInfos)
+	mediaData["generatedAt"] = updatedAt.Format(time.RFC3339)
+	mediaData["branding"] = s.branding
+	if len(s.Filters) > 0 {
+		mediaData["savedFilters"] = lib.ApplySavedFilters(s.Filters, mediaInfos)
+	}
+
+	writeJSON(w, http.StatusOK, mediaData)
+}
+
+// handleReport downloads a report of the current library in the format given by ?format= (default csv)
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = string(report.CSV)
+	}
+	format, err := report.ParseFormat(name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	mediaInfos, _ := s.snapshotMedia()
+	var buf bytes.Buffer
+	if err := report.Render(&buf, format, mediaInfos, report.Options{Branding: s.Branding, Filters: s.Filters}); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="media_report.%s"`, format))
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		slog.Debug("Failed to write report", "error", err)
+	}
+}
+
+// watch rescans the input directory on every tick until the context is cancelled
+func (s *Server) watch(ctx context.Context) {
+	ticker := time.NewTicker(s.WatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sync(ctx); err != nil && ctx.Err() == nil {
+				slog.Warn("Library rescan failed", "error", err)
+			}
+		}
+	}
+}
+
+// sync scans the input directory and brings the in-memory library up to date.
+// New and modified files are analyzed; files that disappeared are dropped.
+func (s *Server) sync(ctx context.Context) error {
+	scanner := lib.NewFileScanner(s.InputDir)
+	videoFiles, err := scanner.ScanVideoFiles(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan video files: %w", err)
+	}
+
+	current := make(map[string]fileState, len(videoFiles))
+	var changed []string
+
+	s.mutex.RLock()
+	for _, path := range videoFiles {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		state := fileState{modTime: info.ModTime(), size: info.Size()}
+		current[path] = state
+		if previous, exists := s.states[path]; !exists || previous != state {
+			changed = append(changed, path)
+		}
+	}
+	removed := 0
+	for path := range s.states {
+		if _, exists := current[path]; !exists {
+			removed++
+		}
+	}
+	s.mutex.RUnlock()
+
+	if len(changed) == 0 && removed == 0 {
+		slog.Debug("Library unchanged", "files", len(current))
+		return nil
+	}
+
+	slog.Info("Library changed, updating", "changed", len(changed), "removed", removed)
+
+	mediaInfos, err := s.Processor.ProcessFiles(ctx, changed)
+	if err != nil {
+		return fmt.Errorf("failed to process video files: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var deleted []string
+	for path := range s.media {
+		if _, exists := current[path]; !exists {
+			delete(s.media, path)
+			deleted = append(deleted, path)
+		}
+	}
+	if err := s.db.Delete(ctx, deleted); err != nil {
+		slog.Warn("Failed to remove deleted files from query database", "error", err)
+	}
+	if err := s.db.Upsert(ctx, mediaInfos); err != nil {
+		slog.Warn("Failed to update query database", "error", err)
+	}
+	for _, info := range mediaInfos {
+		s.media[info.FilePath] = info
+	}
+	s.states = current
+	s.updatedAt = time.Now()
+	return nil
+}
+
+func (s *Server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Features)
+}
+
+// writeJSON encodes a value as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Debug("Failed to write JSON response", "error", err)
+	}
+}
+
+// writeError writes an error message as a JSON response
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"media-mgmt/lib"
+	"media-mgmt/lib/handbrake"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server over a temporary library holding movie.mkv, with transcoding
+// enabled and the given tokens, set up as Run does without scanning or listening
+func newTestServer(t *testing.T, tokens ...lib.APIToken) *Server {
+	t.Helper()
+	inputDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(inputDir, "movie.mkv"), []byte("video data"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	return &Server{
+		InputDir: inputDir,
+		Tokens:   tokens,
+		NewTranscoder: func(files []string) *handbrake.HandBrakeTranscoder {
+			return &handbrake.HandBrakeTranscoder{Files: files}
+		},
+		jobs:   newJobQueue(),
+		events: newEventHub(),
+		media:  make(map[string]*lib.MediaInfo),
+		states: make(map[string]fileState),
+	}
+}
+
+// serve sends a request through the server's routes and returns the recorded response
+func serve(s *Server, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	s.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleIndex(t *testing.T) {
+	s := newTestServer(t)
+	s.RefreshInterval = 30 * time.Second
+	s.Branding = lib.ReportBranding{Title: "Home <Media>"}
+	if err := s.buildPage(); err != nil {
+		t.Fatalf("buildPage failed: %v", err)
+	}
+
+	rec := serve(s, http.MethodGet, "/", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Unexpected content type %q", got)
+	}
+
+	page := rec.Body.String()
+	for _, want := range []string{
+		"<title>Home &lt;Media&gt;</title>",
+		"__MEDIA_API__",
+		`"/api/media"`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("Page missing %q", want)
+		}
+	}
+	if strings.Contains(page, "{{.JSBundle}}") || strings.Contains(page, "{{.Title}}") {
+		t.Error("Page still contains template placeholders")
+	}
+	if len(page) < 10000 {
+		t.Errorf("Expected the compiled UI bundle to be embedded, got a %d byte page", len(page))
+	}
+
+	if rec := serve(s, http.MethodGet, "/missing", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown path, got %d", rec.Code)
+	}
+}
+
+func TestHandleMedia(t *testing.T) {
+	s := newTestServer(t)
+	s.Branding = lib.ReportBranding{Title: "Home Media"}
+	s.branding = s.Branding.ForHTML()
+	s.Filters = []lib.SavedFilter{{Name: "hevc", Expression: "codec = hevc"}}
+	s.updatedAt = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, info := range []*lib.MediaInfo{
+		{FilePath: "/media/b.mkv", FileSize: 2 << 30, VideoCodec: "h264"},
+		{FilePath: "/media/a.mkv", FileSize: 5 << 30, VideoCodec: "hevc"},
+	} {
+		s.media[info.FilePath] = info
+	}
+
+	rec := serve(s, http.MethodGet, "/api/media", "", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Unexpected content type %q", got)
+	}
+
+	var data struct {
+		MediaFiles   []lib.MediaInfo            `json:"mediaFiles"`
+		TotalFiles   int                        `json:"totalFiles"`
+		GeneratedAt  string                     `json:"generatedAt"`
+		Branding     lib.ReportBranding         `json:"branding"`
+		SavedFilters []lib.SavedFilterView      `json:"savedFilters"`
+		Groups       map[string]json.RawMessage `json:"groups"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &data); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if data.TotalFiles != 2 || len(data.MediaFiles) != 2 || data.MediaFiles[0].FilePath != "/media/a.mkv" {
+		t.Errorf("Expected both files sorted by path, got %+v", data.MediaFiles)
+	}
+	if data.GeneratedAt != "2026-03-01T12:00:00Z" {
+		t.Errorf("Expected generatedAt to be the last scan time, got %q", data.GeneratedAt)
+	}
+	if data.Branding.Title != "Home Media" {
+		t.Errorf("Unexpected branding %+v", data.Branding)
+	}
+	if len(data.SavedFilters) != 1 || !reflect.DeepEqual(data.SavedFilters[0].Matches, []string{"/media/a.mkv"}) {
+		t.Errorf("Unexpected saved filters %+v", data.SavedFilters)
+	}
+	if _, ok := data.Groups["directories"]; !ok {
+		t.Errorf("Expected library groups, got %v", data.Groups)
+	}
+}
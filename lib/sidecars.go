@@ -0,0 +1,346 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// audioSidecarExtensions are the standalone audio formats kept next to videos
+var audioSidecarExtensions = map[string]bool{
+	".ac3":  true,
+	".eac3": true,
+	".dts":  true,
+	".thd":  true,
+	".aac":  true,
+	".m4a":  true,
+	".mka":  true,
+	".flac": true,
+	".mp3":  true,
+	".opus": true,
+	".wav":  true,
+}
+
+// sidecarLabelWords are short name parts that label a sidecar rather than name its language
+var sidecarLabelWords = map[string]bool{"ad": true, "dvs": true, "com": true, "cc": true, "sdh": true}
+
+// AudioSidecar is a standalone audio file associated with a video by name, such as
+// movie.commentary.ac3 or movie.de.dts next to movie.mkv
+type AudioSidecar struct {
+	Path     string `json:"path"`
+	Label    string `json:"label,omitempty"`    // Name parts between the video name and extension, such as "commentary"
+	Language string `json:"language,omitempty"` // From a two or three letter name part, or the file's language tag
+	Codec    string `json:"codec"`
+	Bitrate  int64  `json:"bitrate"`
+	Channels int    `json:"channels"`
+	Role     string `json:"role"` // main, commentary, or descriptive
+}
+
+// FindAudioSidecars lists the audio files next to videoPath whose names start with the video's
+// name, sorted by name. A sidecar that also matches a sibling video with a longer name, such as
+// movie.part2.ac3 next to movie.mkv and movie.part2.mkv, belongs to that video instead.
+func FindAudioSidecars(videoPath string) ([]string, error) {
+	dir := filepath.Dir(videoPath)
+	stem := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var videoStems, candidates []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		switch {
+		case videoExtensions[ext]:
+			videoStems = append(videoStems, strings.TrimSuffix(name, filepath.Ext(name)))
+		case audioSidecarExtensions[ext] && strings.HasPrefix(name, stem+"."):
+			candidates = append(candidates, name)
+		}
+	}
+
+	var sidecars []string
+	for _, name := range candidates {
+		if ownerStem(name, videoStems) == stem {
+			sidecars = append(sidecars, filepath.Join(dir, name))
+		}
+	}
+	return sidecars, nil
+}
+
+// ownerStem returns the longest video stem that prefixes the sidecar name, followed by a dot,
+// or by a dash as in Kodi's movie-poster.jpg
+func ownerStem(name string, videoStems []string) string {
+	owner := ""
+	for _, stem := range videoStems {
+		if hasStemPrefix(name, stem) && len(stem) > len(owner) {
+			owner = stem
+		}
+	}
+	return owner
+}
+
+// hasStemPrefix reports whether name starts with a video stem followed by a dot or dash
+func hasStemPrefix(name, stem string) bool {
+	return strings.HasPrefix(name, stem+".") || strings.HasPrefix(name, stem+"-")
+}
+
+// metadataSidecarExtensions are the subtitle, metadata, and artwork formats media servers such
+// as Kodi and Jellyfin read from next to a video
+var metadataSidecarExtensions = map[string]bool{
+	".srt":  true,
+	".ass":  true,
+	".ssa":  true,
+	".vtt":  true,
+	".sub":  true,
+	".idx":  true,
+	".sup":  true,
+	".nfo":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".tbn":  true,
+}
+
+// folderSidecarExtensions are the metadata and artwork formats of directory-level sidecars
+var folderSidecarExtensions = map[string]bool{".nfo": true, ".jpg": true, ".jpeg": true, ".png": true, ".tbn": true}
+
+// folderSidecarStems name the artwork and metadata files that describe a whole directory
+// rather than one video, such as poster.jpg or tvshow.nfo
+var folderSidecarStems = map[string]bool{
+	"poster":    true,
+	"fanart":    true,
+	"folder":    true,
+	"cover":     true,
+	"banner":    true,
+	"backdrop":  true,
+	"landscape": true,
+	"logo":      true,
+	"clearlogo": true,
+	"clearart":  true,
+	"discart":   true,
+	"disc":      true,
+	"movie":     true,
+	"tvshow":    true,
+	"season":    true,
+}
+
+// FindMetadataSidecars lists the subtitle, metadata, and artwork files next to videoPath whose
+// names start with the video's name, such as movie.en.srt, movie.nfo, or movie-poster.jpg,
+// sorted by name. As with audio sidecars, a file matching a sibling video with a longer name
+// belongs to that video instead.
+func FindMetadataSidecars(videoPath string) ([]string, error) {
+	dir := filepath.Dir(videoPath)
+	stem := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var videoStems, candidates []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		switch {
+		case videoExtensions[ext]:
+			videoStems = append(videoStems, strings.TrimSuffix(name, filepath.Ext(name)))
+		case metadataSidecarExtensions[ext] && hasStemPrefix(name, stem):
+			candidates = append(candidates, name)
+		}
+	}
+
+	var sidecars []string
+	for _, name := range candidates {
+		if ownerStem(name, videoStems) == stem {
+			sidecars = append(sidecars, filepath.Join(dir, name))
+		}
+	}
+	return sidecars, nil
+}
+
+// externalSubtitleExtensions are the text subtitle formats HandBrake can import
+var externalSubtitleExtensions = map[string]bool{".srt": true, ".ass": true, ".ssa": true}
+
+// ExternalSubtitle is a text subtitle file associated with a video by name, such as
+// movie.en.srt or movie.eng.forced.ass next to movie.mkv
+type ExternalSubtitle struct {
+	Path     string
+	Language string // From a two or three letter name part ("" if the name has none)
+	Kind     string // SubtitleKindForced or SubtitleKindSDH when a name part says so, otherwise SubtitleKindFull
+}
+
+// FindExternalSubtitles lists the SRT and SSA/ASS subtitle files next to videoPath named after
+// it, such as movie.srt, movie.en.srt, or movie.en.forced.srt, sorted by name
+func FindExternalSubtitles(videoPath string) ([]ExternalSubtitle, error) {
+	paths, err := FindMetadataSidecars(videoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	var subtitles []ExternalSubtitle
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if !externalSubtitleExtensions[strings.ToLower(filepath.Ext(name))] || !strings.HasPrefix(name, stem+".") {
+			continue
+		}
+		label, language := parseSidecarName(videoPath, path)
+		subtitles = append(subtitles, ExternalSubtitle{Path: path, Language: language, Kind: subtitleLabelKind(label)})
+	}
+	return subtitles, nil
+}
+
+// subtitleLabelKind classifies an external subtitle by the label in its name, such as
+// "forced" in movie.en.forced.srt or "sdh" in movie.en.sdh.srt
+func subtitleLabelKind(label string) string {
+	for _, word := range strings.Fields(strings.ToLower(label)) {
+		switch word {
+		case "forced":
+			return SubtitleKindForced
+		case "sdh", "cc", "hi":
+			return SubtitleKindSDH
+		}
+	}
+	return SubtitleKindFull
+}
+
+// FindFolderSidecars lists the directory-level artwork and metadata files in dir, such as
+// poster.jpg, fanart.png, or tvshow.nfo, sorted by name
+func FindFolderSidecars(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	var sidecars []string
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if entry.IsDir() || !folderSidecarExtensions[ext] {
+			continue
+		}
+		if folderSidecarStems[strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))] {
+			sidecars = append(sidecars, filepath.Join(dir, name))
+		}
+	}
+	return sidecars, nil
+}
+
+// SidecarDestination returns where a sidecar of videoPath goes for the video's output: in the
+// output's directory, renamed so it starts with the output's name instead of the video's
+func SidecarDestination(sidecarPath, videoPath, outputPath string) string {
+	stem := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	outputStem := strings.TrimSuffix(filepath.Base(outputPath), filepath.Ext(outputPath))
+	name := filepath.Base(sidecarPath)
+	if strings.HasPrefix(name, stem) {
+		name = outputStem + strings.TrimPrefix(name, stem)
+	}
+	return filepath.Join(filepath.Dir(outputPath), name)
+}
+
+// CopySidecar copies a sidecar file to dst, creating or replacing it
+func CopySidecar(src, dst string) error {
+	return copyFile(src, dst)
+}
+
+// parseSidecarName splits the name parts between the video name and the sidecar's extension
+// into a language, taken from the first two or three letter part, and a label of the rest.
+// For movie.en.commentary.ac3 next to movie.mkv it returns "commentary" and "en".
+func parseSidecarName(videoPath, sidecarPath string) (label, language string) {
+	stem := strings.TrimSuffix(filepath.Base(videoPath), filepath.Ext(videoPath))
+	name := strings.TrimSuffix(filepath.Base(sidecarPath), filepath.Ext(sidecarPath))
+	middle := strings.TrimPrefix(strings.TrimPrefix(name, stem), ".")
+	if middle == "" {
+		return "", ""
+	}
+
+	var labels []string
+	for _, part := range strings.Split(middle, ".") {
+		if language == "" && isLanguageCode(part) {
+			language = strings.ToLower(part)
+			continue
+		}
+		labels = append(labels, part)
+	}
+	return strings.Join(labels, " "), language
+}
+
+// isLanguageCode reports whether a name part looks like an ISO 639 code such as "en" or "deu"
+func isLanguageCode(part string) bool {
+	if len(part) < 2 || len(part) > 3 || sidecarLabelWords[strings.ToLower(part)] {
+		return false
+	}
+	for _, r := range part {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// sidecarRole classifies a sidecar by its label or title. Unlabeled sidecars are alternate main
+// tracks, such as a dub.
+func sidecarRole(title string) string {
+	switch {
+	case commentaryTitlePattern.MatchString(title):
+		return AudioRoleCommentary
+	case descriptiveTitlePattern.MatchString(title):
+		return AudioRoleDescriptive
+	}
+	return AudioRoleMain
+}
+
+// AnalyzeAudioSidecars finds and probes the audio sidecars of a video. Sidecars that cannot be
+// probed are logged and left out.
+func (ma *MediaAnalyzer) AnalyzeAudioSidecars(ctx context.Context, videoPath string) []AudioSidecar {
+	paths, err := FindAudioSidecars(videoPath)
+	if err != nil {
+		slog.Warn("Failed to look for audio sidecars", "file", videoPath, "error", err)
+		return nil
+	}
+
+	var sidecars []AudioSidecar
+	for _, path := range paths {
+		probe, err := ma.runFFprobe(ctx, path)
+		if err != nil {
+			slog.Warn("Failed to probe audio sidecar", "file", path, "error", err)
+			continue
+		}
+		tracks := parseAudioTracks(probe.Streams)
+		if len(tracks) == 0 {
+			slog.Warn("Audio sidecar has no audio stream", "file", path)
+			continue
+		}
+
+		track := tracks[0]
+		label, language := parseSidecarName(videoPath, path)
+		if language == "" {
+			language = track.Language
+		}
+		title := label
+		if title == "" {
+			title = track.Title
+		}
+		sidecars = append(sidecars, AudioSidecar{
+			Path:     path,
+			Label:    label,
+			Language: language,
+			Codec:    track.Codec,
+			Bitrate:  track.Bitrate,
+			Channels: track.Channels,
+			Role:     sidecarRole(title),
+		})
+	}
+	return sidecars
+}
@@ -0,0 +1,137 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindAudioSidecars(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"movie.mkv", "movie.commentary.ac3", "movie.de.dts", "movie.en.srt", "movie2.ac3",
+		"movie.part2.mkv", "movie.part2.ac3", "other.mkv",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	sidecars, err := FindAudioSidecars(filepath.Join(dir, "movie.mkv"))
+	if err != nil {
+		t.Fatalf("FindAudioSidecars failed: %v", err)
+	}
+	want := []string{filepath.Join(dir, "movie.commentary.ac3"), filepath.Join(dir, "movie.de.dts")}
+	if !reflect.DeepEqual(sidecars, want) {
+		t.Errorf("Expected %v, got %v", want, sidecars)
+	}
+
+	sidecars, _ = FindAudioSidecars(filepath.Join(dir, "movie.part2.mkv"))
+	if len(sidecars) != 1 || filepath.Base(sidecars[0]) != "movie.part2.ac3" {
+		t.Errorf("Expected movie.part2.ac3 to belong to movie.part2.mkv, got %v", sidecars)
+	}
+}
+
+func TestParseSidecarName(t *testing.T) {
+	tests := []struct {
+		sidecar      string
+		wantLabel    string
+		wantLanguage string
+		wantRole     string
+	}{
+		{"/m/movie.commentary.ac3", "commentary", "", AudioRoleCommentary},
+		{"/m/movie.en.commentary.ac3", "commentary", "en", AudioRoleCommentary},
+		{"/m/movie.deu.dts", "", "deu", AudioRoleMain},
+		{"/m/movie.AD.aac", "AD", "", AudioRoleDescriptive},
+		{"/m/movie.director.commentary.flac", "director commentary", "", AudioRoleCommentary},
+		{"/m/movie.flac", "", "", AudioRoleMain},
+	}
+
+	for _, tt := range tests {
+		t.Run(filepath.Base(tt.sidecar), func(t *testing.T) {
+			label, language := parseSidecarName("/m/movie.mkv", tt.sidecar)
+			if label != tt.wantLabel || language != tt.wantLanguage {
+				t.Errorf("Expected label %q and language %q, got %q and %q", tt.wantLabel, tt.wantLanguage, label, language)
+			}
+			if role := sidecarRole(label); role != tt.wantRole {
+				t.Errorf("Expected role %s, got %s", tt.wantRole, role)
+			}
+		})
+	}
+}
+
+func TestFindMetadataSidecars(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"movie.mkv", "movie.en.srt", "movie.idx", "movie.sub", "movie.nfo", "movie-poster.jpg",
+		"movie.commentary.ac3", "movie-2.mkv", "movie-2.nfo", "poster.jpg", "fanart.png", "notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	sidecars, err := FindMetadataSidecars(filepath.Join(dir, "movie.mkv"))
+	if err != nil {
+		t.Fatalf("FindMetadataSidecars failed: %v", err)
+	}
+	var names []string
+	for _, sidecar := range sidecars {
+		names = append(names, filepath.Base(sidecar))
+	}
+	want := []string{"movie-poster.jpg", "movie.en.srt", "movie.idx", "movie.nfo", "movie.sub"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+
+	folder, err := FindFolderSidecars(dir)
+	if err != nil {
+		t.Fatalf("FindFolderSidecars failed: %v", err)
+	}
+	want = []string{filepath.Join(dir, "fanart.png"), filepath.Join(dir, "movie.nfo"), filepath.Join(dir, "poster.jpg")}
+	if !reflect.DeepEqual(folder, want) {
+		t.Errorf("Expected folder sidecars %v, got %v", want, folder)
+	}
+}
+
+func TestSidecarDestination(t *testing.T) {
+	tests := []struct {
+		sidecar, output, want string
+	}{
+		{"/m/movie.en.srt", "/m/movie-optimized.mkv", "/m/movie-optimized.en.srt"},
+		{"/m/movie-poster.jpg", "/s/movie.mp4", "/s/movie-poster.jpg"},
+		{"/m/movie.nfo", "/s/Movie (1999).mkv", "/s/Movie (1999).nfo"},
+	}
+	for _, tt := range tests {
+		if got := SidecarDestination(tt.sidecar, "/m/movie.mkv", tt.output); got != tt.want {
+			t.Errorf("SidecarDestination(%q, %q) = %q, want %q", tt.sidecar, tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestFindExternalSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"movie.mkv", "movie.srt", "movie.en.srt", "movie.en.sdh.srt", "movie.ger.forced.ass",
+		"movie.idx", "movie-poster.jpg", "movie.part2.mkv", "movie.part2.en.srt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	subtitles, err := FindExternalSubtitles(filepath.Join(dir, "movie.mkv"))
+	if err != nil {
+		t.Fatalf("FindExternalSubtitles failed: %v", err)
+	}
+	want := []ExternalSubtitle{
+		{filepath.Join(dir, "movie.en.sdh.srt"), "en", SubtitleKindSDH},
+		{filepath.Join(dir, "movie.en.srt"), "en", SubtitleKindFull},
+		{filepath.Join(dir, "movie.ger.forced.ass"), "ger", SubtitleKindForced},
+		{filepath.Join(dir, "movie.srt"), "", SubtitleKindFull},
+	}
+	if !reflect.DeepEqual(subtitles, want) {
+		t.Errorf("Expected %v, got %v", want, subtitles)
+	}
+}
@@ -0,0 +1,137 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LibrarySnapshot records aggregate statistics of a library at the time of an analyze run
+type LibrarySnapshot struct {
+	Timestamp     time.Time      `json:"timestamp"`
+	Library       string         `json:"library"`
+	InputDir      string         `json:"input_dir"`
+	Files         int            `json:"files"`
+	Failures      int            `json:"failures"`
+	TotalSize     int64          `json:"total_size"`
+	TotalDuration float64        `json:"total_duration"` // Sum of media durations in seconds
+	Codecs        map[string]int `json:"codecs"`         // File count per video codec
+	DurationMS    int64          `json:"duration_ms"`    // Wall-clock time the analyze run took
+
+	AvgBitrate     int64            `json:"avg_bitrate,omitempty"`     // Mean video bitrate of files with a known bitrate
+	CodecSizes     map[string]int64 `json:"codec_sizes,omitempty"`     // Total bytes per video codec
+	EfficientShare float64          `json:"efficient_share,omitempty"` // Fraction of total size in an efficient codec
+}
+
+// efficientCodecs are modern video codecs that transcoding converts libraries to
+var efficientCodecs = map[string]bool{"hevc": true, "h265": true, "av1": true, "vp9": true}
+
+// NewLibrarySnapshot summarizes analyzed media into a snapshot
+func NewLibrarySnapshot(library, inputDir string, mediaInfos []*MediaInfo, failures int) LibrarySnapshot {
+	snapshot := LibrarySnapshot{
+		Timestamp:  time.Now(),
+		Library:    library,
+		InputDir:   inputDir,
+		Files:      len(mediaInfos),
+		Failures:   failures,
+		Codecs:     make(map[string]int),
+		CodecSizes: make(map[string]int64),
+	}
+
+	var bitrateTotal int64
+	var bitrateFiles int64
+	var efficientSize int64
+	for _, info := range mediaInfos {
+		snapshot.TotalSize += info.FileSize
+		snapshot.TotalDuration += info.Duration
+		snapshot.Codecs[info.VideoCodec]++
+		snapshot.CodecSizes[info.VideoCodec] += info.FileSize
+		if info.VideoBitrate > 0 {
+			bitrateTotal += info.VideoBitrate
+			bitrateFiles++
+		}
+		if efficientCodecs[strings.ToLower(info.VideoCodec)] {
+			efficientSize += info.FileSize
+		}
+	}
+
+	if bitrateFiles > 0 {
+		snapshot.AvgBitrate = bitrateTotal / bitrateFiles
+	}
+	if snapshot.TotalSize > 0 {
+		snapshot.EfficientShare = float64(efficientSize) / float64(snapshot.TotalSize)
+	}
+	return snapshot
+}
+
+// WeeklyTrend reduces snapshots to the latest one taken in each ISO week, oldest first
+func WeeklyTrend(snapshots []LibrarySnapshot) []LibrarySnapshot {
+	sorted := append([]LibrarySnapshot{}, snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Timestamp.Before(sorted[j].Timestamp) })
+
+	var weekly []LibrarySnapshot
+	for _, snapshot := range sorted {
+		year, week := snapshot.Timestamp.ISOWeek()
+		if n := len(weekly); n > 0 {
+			lastYear, lastWeek := weekly[n-1].Timestamp.ISOWeek()
+			if lastYear == year && lastWeek == week {
+				weekly[n-1] = snapshot
+				continue
+			}
+		}
+		weekly = append(weekly, snapshot)
+	}
+	return weekly
+}
+
+// SnapshotStore persists library snapshots as append-only JSON lines
+type SnapshotStore struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func NewSnapshotStore(path string) *SnapshotStore {
+	return &SnapshotStore{Path: path}
+}
+
+// DefaultSnapshotPath returns the location of the snapshot history in the state directory
+func DefaultSnapshotPath() string {
+	return filepath.Join(DefaultStateDir(), "snapshots.jsonl")
+}
+
+// Append adds a snapshot to the end of the snapshot file
+func (ss *SnapshotStore) Append(snapshot LibrarySnapshot) error {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	if err := appendJSONLine(ss.Path, snapshot); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// Snapshots returns the recorded snapshots for a library in the order they were taken.
+// An empty library name returns snapshots for all libraries.
+func (ss *SnapshotStore) Snapshots(library string) ([]LibrarySnapshot, error) {
+	ss.mutex.Lock()
+	defer ss.mutex.Unlock()
+
+	var snapshots []LibrarySnapshot
+	err := readJSONLines(ss.Path, func(line []byte) {
+		var snapshot LibrarySnapshot
+		if json.Unmarshal(line, &snapshot) != nil {
+			return
+		}
+		if library == "" || snapshot.Library == library {
+			snapshots = append(snapshots, snapshot)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots: %w", err)
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,71 @@
+package lib
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewLibrarySnapshot(t *testing.T) {
+	snapshot := NewLibrarySnapshot("movies", "/media/movies", []*MediaInfo{
+		{VideoCodec: "h264", FileSize: 300, VideoBitrate: 8000000},
+		{VideoCodec: "hevc", FileSize: 100, VideoBitrate: 4000000},
+		{VideoCodec: "hevc", FileSize: 100},
+	}, 0)
+
+	if snapshot.Files != 3 || snapshot.TotalSize != 500 {
+		t.Errorf("Unexpected totals: %+v", snapshot)
+	}
+	if snapshot.AvgBitrate != 6000000 {
+		t.Errorf("AvgBitrate = %d, want 6000000", snapshot.AvgBitrate)
+	}
+	if snapshot.EfficientShare != 0.4 {
+		t.Errorf("EfficientShare = %v, want 0.4", snapshot.EfficientShare)
+	}
+	if snapshot.CodecSizes["hevc"] != 200 {
+		t.Errorf("CodecSizes[hevc] = %d, want 200", snapshot.CodecSizes["hevc"])
+	}
+}
+
+func TestWeeklyTrend(t *testing.T) {
+	monday := time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC)
+	snapshots := []LibrarySnapshot{
+		{Timestamp: monday.AddDate(0, 0, 7), Files: 4},
+		{Timestamp: monday, Files: 1},
+		{Timestamp: monday.AddDate(0, 0, 2), Files: 2},
+		{Timestamp: monday.AddDate(0, 0, 6), Files: 3},
+	}
+
+	weekly := WeeklyTrend(snapshots)
+	want := []int{3, 4}
+	if len(weekly) != len(want) {
+		t.Fatalf("WeeklyTrend returned %d snapshots, want %d", len(weekly), len(want))
+	}
+	for i, snapshot := range weekly {
+		if snapshot.Files != want[i] {
+			t.Errorf("week %d kept snapshot with %d files, want %d", i, snapshot.Files, want[i])
+		}
+	}
+}
+
+func TestRecordTrendPrevious(t *testing.T) {
+	app := &App{InputDir: "/media/movies", Snapshots: NewSnapshotStore(filepath.Join(t.TempDir(), "snapshots.jsonl"))}
+
+	_, previous := app.recordTrend([]*MediaInfo{{VideoCodec: "h264", FileSize: 300}})
+	if previous != nil {
+		t.Fatalf("Expected no previous snapshot on the first run, got %+v", previous)
+	}
+
+	trend, previous := app.recordTrend([]*MediaInfo{{VideoCodec: "hevc", FileSize: 100}, {FileSize: 50}})
+	if previous == nil || previous.Files != 1 || previous.Codecs["h264"] != 1 {
+		t.Fatalf("Expected the first run as previous snapshot, got %+v", previous)
+	}
+	if len(trend) != 1 || trend[0].Files != 2 {
+		t.Errorf("Expected the weekly trend to end with the current run, got %+v", trend)
+	}
+
+	sanitized := sanitizeSnapshot(&LibrarySnapshot{Codecs: map[string]int{"": 2, "hevc": 1}})
+	if sanitized.Codecs["unknown"] != 2 || sanitized.Codecs["hevc"] != 1 || len(sanitized.Codecs) != 2 {
+		t.Errorf("Expected empty codec relabeled as unknown, got %+v", sanitized.Codecs)
+	}
+}
@@ -0,0 +1,20 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DefaultStateDir returns the directory used for persistent tool state such as history.
+// Honors the MEDIA_MGMT_STATE_DIR environment variable, otherwise uses ~/.media-mgmt.
+func DefaultStateDir() string {
+	if dir := os.Getenv("MEDIA_MGMT_STATE_DIR"); dir != "" {
+		return dir
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".media-mgmt"
+	}
+	return filepath.Join(home, ".media-mgmt")
+}
@@ -0,0 +1,152 @@
+package lib
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// sparkBlocks are the glyphs used to draw sparklines, from lowest to highest
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// StatGroup aggregates the files sharing one value of a column
+type StatGroup struct {
+	Key   string
+	Files int
+	Size  int64
+}
+
+// GroupStats counts files and total size per key, sorted by file count descending
+func GroupStats(mediaInfos []*MediaInfo, key func(info *MediaInfo) string) []StatGroup {
+	index := make(map[string]int)
+	var groups []StatGroup
+
+	for _, info := range mediaInfos {
+		k := key(info)
+		if k == "" {
+			k = "unknown"
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			groups = append(groups, StatGroup{Key: k})
+		}
+		groups[i].Files++
+		groups[i].Size += info.FileSize
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Files != groups[j].Files {
+			return groups[i].Files > groups[j].Files
+		}
+		return groups[i].Key < groups[j].Key
+	})
+	return groups
+}
+
+// Percentile returns the p-th percentile (0-100) of sorted values using linear interpolation
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// Histogram counts values into equal-width buckets between the minimum and maximum value.
+// Returns the bucket counts and the lower bound of each bucket.
+func Histogram(values []float64, buckets int) ([]int, []float64) {
+	if len(values) == 0 || buckets < 1 {
+		return nil, nil
+	}
+
+	minValue, maxValue := values[0], values[0]
+	for _, v := range values {
+		minValue = math.Min(minValue, v)
+		maxValue = math.Max(maxValue, v)
+	}
+
+	if maxValue == minValue {
+		return []int{len(values)}, []float64{minValue}
+	}
+
+	width := (maxValue - minValue) / float64(buckets)
+	counts := make([]int, buckets)
+	bounds := make([]float64, buckets)
+	for i := range bounds {
+		bounds[i] = minValue + width*float64(i)
+	}
+	for _, v := range values {
+		i := int((v - minValue) / width)
+		if i >= buckets {
+			i = buckets - 1
+		}
+		counts[i]++
+	}
+	return counts, bounds
+}
+
+// Sparkline renders values as a single line of block characters scaled to the maximum value
+func Sparkline(values []float64) string {
+	maxValue := 0.0
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+
+	var b strings.Builder
+	for _, v := range values {
+		level := 0
+		if maxValue > 0 {
+			level = int(math.Round(v / maxValue * float64(len(sparkBlocks)-1)))
+		}
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// GrowthPoint records the files added to a library during one month
+type GrowthPoint struct {
+	Month      string // YYYY-MM
+	Added      int
+	AddedSize  int64
+	TotalFiles int
+	TotalSize  int64
+}
+
+// LibraryGrowth buckets cached files by the month their source file was last modified,
+// with running totals, to show how a library grew over time.
+func LibraryGrowth(entries []*CacheEntry) []GrowthPoint {
+	byMonth := make(map[string]*GrowthPoint)
+	for _, entry := range entries {
+		month := entry.FileModTime.Format("2006-01")
+		point, ok := byMonth[month]
+		if !ok {
+			point = &GrowthPoint{Month: month}
+			byMonth[month] = point
+		}
+		point.Added++
+		point.AddedSize += entry.FileSize
+	}
+
+	points := make([]GrowthPoint, 0, len(byMonth))
+	for _, point := range byMonth {
+		points = append(points, *point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Month < points[j].Month })
+
+	var totalFiles int
+	var totalSize int64
+	for i := range points {
+		totalFiles += points[i].Added
+		totalSize += points[i].AddedSize
+		points[i].TotalFiles = totalFiles
+		points[i].TotalSize = totalSize
+	}
+	return points
+}
@@ -0,0 +1,58 @@
+package lib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5}
+	tests := []struct {
+		p    float64
+		want float64
+	}{
+		{0, 1},
+		{50, 3},
+		{100, 5},
+		{25, 2},
+		{90, 4.6},
+	}
+	for _, tt := range tests {
+		if got := Percentile(sorted, tt.p); got < tt.want-1e-9 || got > tt.want+1e-9 {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := Sparkline([]float64{0, 1, 2, 7}); got != "▁▂▃█" {
+		t.Errorf("Sparkline() = %q", got)
+	}
+	if got := Sparkline([]float64{0, 0}); got != "▁▁" {
+		t.Errorf("Sparkline() of zeros = %q", got)
+	}
+}
+
+func TestLibraryGrowth(t *testing.T) {
+	jan := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+	entries := []*CacheEntry{
+		{FileModTime: mar, FileSize: 5},
+		{FileModTime: jan, FileSize: 10},
+		{FileModTime: jan, FileSize: 20},
+	}
+
+	points := LibraryGrowth(entries)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 months, got %d", len(points))
+	}
+	if points[0].Month != "2024-01" || points[0].Added != 2 || points[0].TotalSize != 30 {
+		t.Errorf("unexpected first point: %+v", points[0])
+	}
+	if points[1].Month != "2024-03" || points[1].TotalFiles != 3 || points[1].TotalSize != 35 {
+		t.Errorf("unexpected second point: %+v", points[1])
+	}
+}
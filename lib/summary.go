@@ -0,0 +1,128 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileFailure records a file that could not be processed and why
+type FileFailure struct {
+	File      string `json:"file"`
+	Stage     string `json:"stage,omitempty"` // Step that failed, such as FailureStageProbe, when known
+	Error     string `json:"error"`
+	Kind      string `json:"kind,omitempty"`      // Class of failure, such as disk_full, where the command classifies them
+	Permanent bool   `json:"permanent,omitempty"` // Retrying cannot succeed without the file changing
+}
+
+// SummaryStat is a labelled value shown in a run summary
+type SummaryStat struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// RunSummary describes the outcome of a batch run for notifications
+type RunSummary struct {
+	Title    string        `json:"title"`
+	Stats    []SummaryStat `json:"stats"`
+	Failures []FileFailure `json:"failures,omitempty"`
+}
+
+// AddStat appends a formatted statistic to the summary
+func (s *RunSummary) AddStat(label, format string, args ...interface{}) {
+	s.Stats = append(s.Stats, SummaryStat{Label: label, Value: fmt.Sprintf(format, args...)})
+}
+
+// Table renders the summary's statistics for the terminal, headed by its title
+func (s *RunSummary) Table() *Table {
+	table := NewTable(s.Title, "")
+	for _, stat := range s.Stats {
+		table.AddRow(stat.Label, stat.Value)
+	}
+	return table
+}
+
+// Markdown renders the summary as Markdown
+func (s *RunSummary) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", s.Title)
+	for _, stat := range s.Stats {
+		fmt.Fprintf(&b, "- **%s**: %s\n", stat.Label, stat.Value)
+	}
+
+	if len(s.Failures) > 0 {
+		fmt.Fprintf(&b, "\n## %s\n\n", T(MsgFailures, len(s.Failures)))
+		for _, failure := range s.Failures {
+			fmt.Fprintf(&b, "- `%s`: %s\n", failure.File, failure.Error)
+		}
+	}
+	return b.String()
+}
+
+var summaryHTMLTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{"t": T}).Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif">
+<h1>{{.Title}}</h1>
+<table cellpadding="4">
+{{range .Stats}}<tr><th align="left">{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Failures}}<h2>{{t "summary.failures" (len .Failures)}}</h2>
+<ul>
+{{range .Failures}}<li><code>{{.File}}</code>: {{.Error}}</li>
+{{end}}</ul>
+{{end}}</body></html>
+`))
+
+// HTML renders the summary as a standalone HTML document
+func (s *RunSummary) HTML() (string, error) {
+	var buf bytes.Buffer
+	if err := summaryHTMLTemplate.Execute(&buf, s); err != nil {
+		return "", fmt.Errorf("failed to render summary HTML: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// AnalysisSummary summarizes an analyze run. Scanned files without media info are reported as
+// failures, with the reason from failures when one was recorded.
+func AnalysisSummary(inputDir string, scanned []string, mediaInfos []*MediaInfo, failures []FileFailure) *RunSummary {
+	analyzed := make(map[string]bool, len(mediaInfos))
+	var totalSize int64
+	var totalDuration float64
+	codecCount := make(map[string]int)
+	for _, info := range mediaInfos {
+		analyzed[info.FilePath] = true
+		totalSize += info.FileSize
+		totalDuration += info.Duration
+		codecCount[info.VideoCodec]++
+	}
+
+	summary := &RunSummary{Title: T(MsgAnalysisTitle, filepath.Base(inputDir))}
+	summary.AddStat(T(MsgFilesAnalyzed), "%s", T(MsgFilesAnalyzedValue, len(mediaInfos), len(scanned)))
+	summary.AddStat(T(MsgTotalSize), "%s", FormatSize(totalSize))
+	summary.AddStat(T(MsgTotalDuration), "%s", T(MsgTotalDurationValue, totalDuration/3600))
+
+	codecs := make([]string, 0, len(codecCount))
+	for codec, count := range codecCount {
+		codecs = append(codecs, fmt.Sprintf("%s (%d)", codec, count))
+	}
+	sort.Strings(codecs)
+	summary.AddStat(T(MsgVideoCodecs), "%s", strings.Join(codecs, ", "))
+
+	reasons := make(map[string]FileFailure, len(failures))
+	for _, failure := range failures {
+		reasons[failure.File] = failure
+	}
+	for _, file := range scanned {
+		if analyzed[file] {
+			continue
+		}
+		failure, ok := reasons[file]
+		if !ok {
+			failure = FileFailure{File: file, Error: T(MsgAnalysisFailed)}
+		}
+		summary.Failures = append(summary.Failures, failure)
+	}
+	return summary
+}
@@ -0,0 +1,82 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunSummaryRendering(t *testing.T) {
+	tests := []struct {
+		name         string
+		summary      *RunSummary
+		markdown     []string // Substrings expected in the Markdown
+		html         []string // Substrings expected in the HTML
+		htmlExcluded []string // Substrings that must not appear in the HTML
+	}{
+		{
+			name:         "stats only",
+			summary:      &RunSummary{Title: "Analysis of movies", Stats: []SummaryStat{{"Files analyzed", "3 of 3"}}},
+			markdown:     []string{"# Analysis of movies\n", "- **Files analyzed**: 3 of 3\n"},
+			html:         []string{"<h1>Analysis of movies</h1>", "<th align=\"left\">Files analyzed</th><td>3 of 3</td>"},
+			htmlExcluded: []string{"<h2>", "<ul>"},
+		},
+		{
+			name: "failures section",
+			summary: &RunSummary{Title: "Analysis of movies", Failures: []FileFailure{
+				{File: "/movies/broken.mkv", Error: "ffprobe failed"},
+				{File: "/movies/truncated.mp4", Error: "moov atom not found"},
+			}},
+			markdown: []string{
+				"\n## " + T(MsgFailures, 2) + "\n\n",
+				"- `/movies/broken.mkv`: ffprobe failed\n",
+				"- `/movies/truncated.mp4`: moov atom not found\n",
+			},
+			html: []string{
+				"<h2>" + T(MsgFailures, 2) + "</h2>",
+				"<li><code>/movies/broken.mkv</code>: ffprobe failed</li>",
+				"<li><code>/movies/truncated.mp4</code>: moov atom not found</li>",
+			},
+		},
+		{
+			name: "escapes file names in HTML",
+			summary: &RunSummary{Title: "Tom & Jerry <Season 1>", Failures: []FileFailure{
+				{File: "/movies/<script>alert(1)</script>.mkv", Error: `bad "data" & more`},
+			}},
+			markdown: []string{"- `/movies/<script>alert(1)</script>.mkv`: bad \"data\" & more\n"},
+			html: []string{
+				"<h1>Tom &amp; Jerry &lt;Season 1&gt;</h1>",
+				"<code>/movies/&lt;script&gt;alert(1)&lt;/script&gt;.mkv</code>: bad &#34;data&#34; &amp; more",
+			},
+			htmlExcluded: []string{"<script>", "<Season 1>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			markdown := tt.summary.Markdown()
+			for _, want := range tt.markdown {
+				if !strings.Contains(markdown, want) {
+					t.Errorf("Markdown missing %q:\n%s", want, markdown)
+				}
+			}
+			if len(tt.summary.Failures) == 0 && strings.Contains(markdown, "##") {
+				t.Errorf("Expected no failures section:\n%s", markdown)
+			}
+
+			html, err := tt.summary.HTML()
+			if err != nil {
+				t.Fatalf("HTML failed: %v", err)
+			}
+			for _, want := range tt.html {
+				if !strings.Contains(html, want) {
+					t.Errorf("HTML missing %q:\n%s", want, html)
+				}
+			}
+			for _, excluded := range tt.htmlExcluded {
+				if strings.Contains(html, excluded) {
+					t.Errorf("HTML unexpectedly contains %q:\n%s", excluded, html)
+				}
+			}
+		})
+	}
+}
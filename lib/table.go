@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table output formats shared by commands that print tabular results
+const (
+	TableFormatText = "table"
+	TableFormatTSV  = "tsv"
+)
+
+// Table is tabular terminal output with automatically sized columns.
+// Columns whose cells are all numeric are right-aligned.
+type Table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+// NewTable creates a table with the given column headers
+func NewTable(headers ...string) *Table {
+	return &Table{Headers: headers}
+}
+
+// cellReplacer flattens characters that would break row and column alignment
+var cellReplacer = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+
+// AddRow appends a row, padding or truncating it to the number of headers
+func (t *Table) AddRow(cells ...string) {
+	row := make([]string, len(t.Headers))
+	copy(row, cells)
+	for i, cell := range row {
+		row[i] = cellReplacer.Replace(cell)
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+// Render writes the table as aligned text or as tab-separated values.
+// Color bolds the header of text tables and is ignored for TSV.
+func (t *Table) Render(w io.Writer, format string, color bool) error {
+	switch format {
+	case TableFormatTSV:
+		return t.renderTSV(w)
+	case TableFormatText, "":
+		return t.renderText(w, color)
+	}
+	return fmt.Errorf("unsupported table format %q", format)
+}
+
+func (t *Table) renderTSV(w io.Writer) error {
+	for _, row := range append([][]string{t.Headers}, t.Rows...) {
+		if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Table) renderText(w io.Writer, color bool) error {
+	widths := make([]int, len(t.Headers))
+	numeric := make([]bool, len(t.Headers))
+	for i, header := range t.Headers {
+		widths[i] = utf8.RuneCountInString(header)
+		numeric[i] = len(t.Rows) > 0
+	}
+	for _, row := range t.Rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+			if cell != "" && !isNumericCell(cell) {
+				numeric[i] = false
+			}
+		}
+	}
+
+	writeRow := func(cells []string, prefix, suffix string) error {
+		var b strings.Builder
+		b.WriteString(prefix)
+		for i, cell := range cells {
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			if numeric[i] {
+				b.WriteString(padding + cell)
+			} else if i < len(cells)-1 {
+				b.WriteString(cell + padding)
+			} else {
+				b.WriteString(cell)
+			}
+		}
+		b.WriteString(suffix)
+		_, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " "))
+		return err
+	}
+
+	prefix, suffix := "", ""
+	if color {
+		prefix, suffix = colorBold, colorReset
+	}
+	if err := writeRow(t.Headers, prefix, suffix); err != nil {
+		return err
+	}
+	for _, row := range t.Rows {
+		if err := writeRow(row, "", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNumericCell reports whether a cell holds a plain number, optionally a percentage
+func isNumericCell(cell string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSuffix(cell, "%"), 64)
+	return err == nil
+}
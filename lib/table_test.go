@@ -0,0 +1,37 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTable_Render(t *testing.T) {
+	table := NewTable("CODEC", "FILES", "SIZE")
+	table.AddRow("h264", "12", "1.5 GB")
+	table.AddRow("hevc", "3", "700.0 MB")
+	table.AddRow("av1\tx")
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{TableFormatText, "CODEC  FILES  SIZE\nh264      12  1.5 GB\nhevc       3  700.0 MB\nav1 x\n"},
+		{TableFormatTSV, "CODEC\tFILES\tSIZE\nh264\t12\t1.5 GB\nhevc\t3\t700.0 MB\nav1 x\t\t\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := table.Render(&buf, tt.format, false); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Render() =\n%q\nwant\n%q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	if err := table.Render(&bytes.Buffer{}, "xml", false); err == nil {
+		t.Error("Render() expected an error for an unknown format")
+	}
+}
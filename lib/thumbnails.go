@@ -0,0 +1,125 @@
+package lib
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	// thumbnailsDirName holds extracted thumbnails inside the report directory, so the HTML
+	// report can link them with relative paths
+	thumbnailsDirName = "thumbnails"
+	// thumbnailWidth is the width in pixels of each thumbnail; height keeps the aspect ratio
+	thumbnailWidth = 320
+)
+
+// thumbnailPositions returns count evenly spaced offsets in seconds, avoiding the very start
+// and end of a file, where studio logos and credits make frames hard to recognize
+func thumbnailPositions(duration float64, count int) []float64 {
+	if duration <= 0 || count <= 0 {
+		return nil
+	}
+	positions := make([]float64, count)
+	for i := range positions {
+		positions[i] = duration * float64(i+1) / float64(count+1)
+	}
+	return positions
+}
+
+// thumbnailName returns a stable file name for a file's nth thumbnail
+func thumbnailName(filePath string, n int) string {
+	sum := sha1.Sum([]byte(filePath))
+	return fmt.Sprintf("%s-%d.jpg", hex.EncodeToString(sum[:8]), n+1)
+}
+
+// GenerateThumbnails extracts count keyframe thumbnails per file into the report directory and
+// records their paths relative to it in each MediaInfo. Thumbnails newer than their source are
+// reused. Files that fail are logged and left without thumbnails.
+func GenerateThumbnails(ctx context.Context, outputDir string, mediaInfos []*MediaInfo, count, parallelism int) error {
+	if _, err := exec.LookPath(ToolCommand("ffmpeg")); err != nil {
+		return fmt.Errorf("thumbnails require ffmpeg, which was not found in the tools directory or PATH")
+	}
+	dir := filepath.Join(outputDir, thumbnailsDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create thumbnails directory: %w", err)
+	}
+
+	jobs := make(chan *MediaInfo)
+	var wg sync.WaitGroup
+	for range max(parallelism, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for info := range jobs {
+				thumbnails, err := extractThumbnails(ctx, dir, info, count)
+				if err != nil {
+					slog.Warn("Failed to extract thumbnails", "file", info.FilePath, "error", err)
+				}
+				info.Thumbnails = thumbnails
+			}
+		}()
+	}
+
+	for _, info := range mediaInfos {
+		if info.ArchivedTo != "" {
+			continue
+		}
+		select {
+		case jobs <- info:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return ctx.Err()
+}
+
+// extractThumbnails writes a file's thumbnails to dir and returns the ones it has, relative to
+// the report directory
+func extractThumbnails(ctx context.Context, dir string, info *MediaInfo, count int) ([]string, error) {
+	source, err := os.Stat(info.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var thumbnails []string
+	for i, position := range thumbnailPositions(info.Duration, count) {
+		name := thumbnailName(info.FilePath, i)
+		path := filepath.Join(dir, name)
+		if existing, err := os.Stat(path); err != nil || existing.ModTime().Before(source.ModTime()) {
+			if err := extractFrame(ctx, info.FilePath, path, position); err != nil {
+				return thumbnails, err
+			}
+		}
+		thumbnails = append(thumbnails, thumbnailsDirName+"/"+name)
+	}
+	return thumbnails, nil
+}
+
+// extractFrame saves the keyframe nearest position as a JPEG. Seeking before the input and
+// decoding only keyframes keeps this to a fraction of a second even for large files.
+func extractFrame(ctx context.Context, inputPath, outputPath string, position float64) error {
+	cmd := exec.CommandContext(ctx, ToolCommand("ffmpeg"),
+		"-v", "error", "-y", "-nostdin",
+		"-skip_frame", "nokey",
+		"-ss", fmt.Sprintf("%.3f", position),
+		"-i", inputPath,
+		"-map", "0:V:0",
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", thumbnailWidth),
+		"-q:v", "4",
+		outputPath)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg failed at %.0fs: %w: %s", position, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
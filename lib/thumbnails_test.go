@@ -0,0 +1,36 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThumbnailPositions(t *testing.T) {
+	got := thumbnailPositions(100, 4)
+	expected := []float64{20, 40, 60, 80}
+	if len(got) != len(expected) {
+		t.Fatalf("thumbnailPositions() = %v, want %v", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("position %d = %v, want %v", i, got[i], expected[i])
+		}
+	}
+
+	if got := thumbnailPositions(0, 4); got != nil {
+		t.Errorf("expected no positions without a duration, got %v", got)
+	}
+}
+
+func TestThumbnailName(t *testing.T) {
+	first := thumbnailName("/movies/a.mkv", 0)
+	if !strings.HasSuffix(first, "-1.jpg") {
+		t.Errorf("thumbnailName() = %q, want a -1.jpg suffix", first)
+	}
+	if first != thumbnailName("/movies/a.mkv", 0) {
+		t.Error("thumbnailName() should be stable across runs")
+	}
+	if first == thumbnailName("/movies/b.mkv", 0) {
+		t.Error("thumbnailName() should differ between files")
+	}
+}
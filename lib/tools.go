@@ -0,0 +1,322 @@
+package lib
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// PinnedFFmpegVersion is the FFmpeg release installed by tools install unless overridden
+	PinnedFFmpegVersion = "6.1"
+	// DefaultToolsBaseURL hosts versioned static FFmpeg builds, one zip per tool and platform
+	DefaultToolsBaseURL = "https://github.com/ffbinaries/ffbinaries-prebuilt/releases/download"
+	// toolsLockFilename records the installed tools in the tools directory
+	toolsLockFilename = "tools.json"
+)
+
+// ManagedTools are the tools tools install downloads by default
+var ManagedTools = []string{"ffprobe", "ffmpeg"}
+
+// toolPlatforms maps GOOS/GOARCH to the platform names used in release asset names.
+// Apple Silicon uses the x86-64 macOS build through Rosetta.
+var toolPlatforms = map[string]string{
+	"linux/amd64":   "linux-64",
+	"linux/arm64":   "linux-arm-64",
+	"darwin/amd64":  "macos-64",
+	"darwin/arm64":  "macos-64",
+	"windows/amd64": "win-64",
+}
+
+// DefaultToolsDir returns the directory holding managed tool binaries.
+// Honors the MEDIA_MGMT_TOOLS_DIR environment variable, otherwise uses tools in the state directory.
+func DefaultToolsDir() string {
+	if dir := os.Getenv("MEDIA_MGMT_TOOLS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(DefaultStateDir(), "tools")
+}
+
+// ToolCommand returns the managed binary for a tool if one is installed, otherwise the bare
+// name so it is resolved from PATH.
+func ToolCommand(name string) string {
+	path := filepath.Join(DefaultToolsDir(), executableName(name))
+	if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+		return path
+	}
+	return name
+}
+
+// executableName adds the platform's executable extension to a tool name
+func executableName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// InstalledTool records a tool downloaded into the tools directory
+type InstalledTool struct {
+	Name        string    `json:"name"`
+	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	SHA256      string    `json:"sha256"`                  // Digest of the downloaded archive
+	Binary      string    `json:"binary_sha256,omitempty"` // Digest of the extracted binary, checked by Verify
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// ToolSpec describes a pinned tool download
+type ToolSpec struct {
+	Name    string
+	Version string
+	URL     string
+	SHA256  string // Expected archive digest; empty accepts the download and records its digest
+}
+
+// PinnedToolSpec returns the download for a tool at a version on the current platform
+func PinnedToolSpec(baseURL, name, version string) (ToolSpec, error) {
+	platform, ok := toolPlatforms[runtime.GOOS+"/"+runtime.GOARCH]
+	if !ok {
+		return ToolSpec{}, fmt.Errorf("no prebuilt %s available for %s/%s", name, runtime.GOOS, runtime.GOARCH)
+	}
+	url := fmt.Sprintf("%s/v%s/%s-%s-%s.zip", strings.TrimSuffix(baseURL, "/"), version, name, version, platform)
+	return ToolSpec{Name: name, Version: version, URL: url}, nil
+}
+
+// ToolManager installs and tracks pinned tool binaries in a directory
+type ToolManager struct {
+	Dir    string
+	Client *http.Client
+}
+
+// NewToolManager creates a manager for the given tools directory
+func NewToolManager(dir string) *ToolManager {
+	return &ToolManager{Dir: dir, Client: http.DefaultClient}
+}
+
+// Installed returns the tools recorded in the lock file, sorted by name
+func (tm *ToolManager) Installed() ([]InstalledTool, error) {
+	lock, err := tm.readLock()
+	if err != nil {
+		return nil, err
+	}
+
+	tools := make([]InstalledTool, 0, len(lock))
+	for _, tool := range lock {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools, nil
+}
+
+// Install downloads a tool, verifies its digest, and extracts the binary into the tools directory.
+// Without an expected digest, a reinstall of the same version and URL must match the digest
+// recorded by the first install so every machine ends up with the same build.
+func (tm *ToolManager) Install(ctx context.Context, spec ToolSpec) (*InstalledTool, error) {
+	lock, err := tm.readLock()
+	if err != nil {
+		return nil, err
+	}
+	expected := strings.ToLower(spec.SHA256)
+	if previous, ok := lock[spec.Name]; ok && expected == "" && previous.Version == spec.Version && previous.URL == spec.URL {
+		expected = previous.SHA256
+	}
+
+	if err := os.MkdirAll(tm.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tools directory: %w", err)
+	}
+
+	archive, err := os.CreateTemp(tm.Dir, spec.Name+"-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download file: %w", err)
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	digest, err := tm.download(ctx, spec.URL, archive)
+	if err != nil {
+		return nil, err
+	}
+	if expected != "" && digest != expected {
+		return nil, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", spec.URL, expected, digest)
+	}
+
+	binary := tm.BinaryPath(spec.Name)
+	if err := extractToolBinary(archive.Name(), executableName(spec.Name), binary); err != nil {
+		return nil, err
+	}
+	sum, err := checksumFile(binary)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum %s: %w", binary, err)
+	}
+
+	tool := InstalledTool{
+		Name:        spec.Name,
+		Version:     spec.Version,
+		URL:         spec.URL,
+		SHA256:      digest,
+		Binary:      sum.sha256,
+		InstalledAt: time.Now().UTC(),
+	}
+	lock[spec.Name] = tool
+	if err := tm.writeLock(lock); err != nil {
+		return nil, err
+	}
+	return &tool, nil
+}
+
+// BinaryPath returns where a tool's managed binary is installed
+func (tm *ToolManager) BinaryPath(name string) string {
+	return filepath.Join(tm.Dir, executableName(name))
+}
+
+// Verify checks that an installed tool's binary is still the one tools install extracted.
+// Tools installed before binary digests were recorded are accepted.
+func (tm *ToolManager) Verify(tool InstalledTool) error {
+	if tool.Binary == "" {
+		return nil
+	}
+	binary := tm.BinaryPath(tool.Name)
+	sum, err := checksumFile(binary)
+	if err != nil {
+		return fmt.Errorf("failed to checksum %s: %w", binary, err)
+	}
+	if sum.sha256 != tool.Binary {
+		return fmt.Errorf("%s has changed since it was installed: sha256 %s, expected %s", binary, sum.sha256, tool.Binary)
+	}
+	return nil
+}
+
+// Remove deletes a managed tool binary and its lock entry
+func (tm *ToolManager) Remove(name string) error {
+	lock, err := tm.readLock()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(tm.Dir, executableName(name))); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", name, err)
+	}
+	delete(lock, name)
+	return tm.writeLock(lock)
+}
+
+// download streams a URL into w and returns the hex SHA-256 digest of the body
+func (tm *ToolManager) download(ctx context.Context, url string, w io.Writer) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("invalid download URL: %w", err)
+	}
+	resp, err := tm.Client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// extractToolBinary copies the named binary out of a zip archive, replacing target atomically
+func extractToolBinary(archivePath, binary, target string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open tool archive: %w", err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || filepath.Base(file.Name) != binary {
+			continue
+		}
+
+		src, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("failed to read %s from archive: %w", binary, err)
+		}
+		defer src.Close()
+
+		tmp := target + ".tmp"
+		dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", binary, err)
+		}
+		if _, err := io.Copy(dst, src); err != nil {
+			dst.Close()
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write %s: %w", binary, err)
+		}
+		if err := dst.Close(); err != nil {
+			os.Remove(tmp)
+			return fmt.Errorf("failed to write %s: %w", binary, err)
+		}
+		return os.Rename(tmp, target)
+	}
+	return fmt.Errorf("tool archive does not contain %s", binary)
+}
+
+// readLock loads the lock file, returning an empty lock if none exists
+func (tm *ToolManager) readLock() (map[string]InstalledTool, error) {
+	lock := make(map[string]InstalledTool)
+	data, err := os.ReadFile(filepath.Join(tm.Dir, toolsLockFilename))
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tools lock file: %w", err)
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse tools lock file: %w", err)
+	}
+	return lock, nil
+}
+
+// writeLock saves the lock file
+func (tm *ToolManager) writeLock(lock map[string]InstalledTool) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tools lock file: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(tm.Dir, toolsLockFilename), data, 0644); err != nil {
+		return fmt.Errorf("failed to write tools lock file: %w", err)
+	}
+	return nil
+}
+
+// ToolNotFoundError describes a tool that could not be run, naming the pinned build tools install
+// recorded when there is one, since that is where the tool was expected rather than PATH
+func ToolNotFoundError(name, hint string) error {
+	manager := NewToolManager(DefaultToolsDir())
+	if installed, err := manager.Installed(); err == nil {
+		for _, tool := range installed {
+			if tool.Name == name {
+				return fmt.Errorf("pinned %s build %s is missing or cannot be run - run media-mgmt tools install %s", name, manager.BinaryPath(name), name)
+			}
+		}
+	}
+	return fmt.Errorf("%s not found in PATH - %s", name, hint)
+}
+
+// ResolveTool reports where a tool would be run from: the tools directory, PATH, or nowhere
+func ResolveTool(name string) (path string, managed bool, err error) {
+	command := ToolCommand(name)
+	path, err = exec.LookPath(command)
+	return path, command != name, err
+}
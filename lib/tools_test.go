@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// toolZip builds a release zip containing a single binary with the given contents
+func toolZip(t *testing.T, name, contents string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	file, err := writer.Create("bin/" + executableName(name))
+	if err != nil {
+		t.Fatalf("Failed to create zip entry: %v", err)
+	}
+	file.Write([]byte(contents))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to finish zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestToolManagerInstall(t *testing.T) {
+	archive := toolZip(t, "ffprobe", "v1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	t.Setenv("MEDIA_MGMT_TOOLS_DIR", dir)
+	manager := NewToolManager(dir)
+	spec := ToolSpec{Name: "ffprobe", Version: "6.1", URL: server.URL + "/ffprobe.zip"}
+
+	if ToolCommand("ffprobe") != "ffprobe" {
+		t.Fatalf("Expected bare name before install, got %s", ToolCommand("ffprobe"))
+	}
+
+	tool, err := manager.Install(context.Background(), spec)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	binary := filepath.Join(dir, executableName("ffprobe"))
+	if data, err := os.ReadFile(binary); err != nil || string(data) != "v1" {
+		t.Fatalf("Expected extracted binary, got %q, err %v", data, err)
+	}
+	if ToolCommand("ffprobe") != binary {
+		t.Errorf("Expected managed binary to be preferred, got %s", ToolCommand("ffprobe"))
+	}
+
+	// A changed download for the same pinned version must be rejected
+	archive = toolZip(t, "ffprobe", "tampered")
+	if _, err := manager.Install(context.Background(), spec); err == nil {
+		t.Error("Expected checksum mismatch for changed download")
+	}
+	if data, _ := os.ReadFile(binary); string(data) != "v1" {
+		t.Errorf("Expected original binary to be kept, got %q", data)
+	}
+
+	spec.SHA256 = "0000"
+	if _, err := manager.Install(context.Background(), spec); err == nil {
+		t.Error("Expected mismatch against explicit digest")
+	}
+
+	installed, err := manager.Installed()
+	if err != nil || len(installed) != 1 || installed[0].SHA256 != tool.SHA256 {
+		t.Errorf("Expected lock file to record the first install, got %+v, err %v", installed, err)
+	}
+
+	if err := manager.Verify(installed[0]); err != nil {
+		t.Errorf("Verify() of the installed binary error = %v", err)
+	}
+	if err := os.WriteFile(binary, []byte("patched"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Verify(installed[0]); err == nil {
+		t.Error("Expected Verify() to reject a changed binary")
+	}
+	os.Remove(binary)
+	if err := ToolNotFoundError("ffprobe", "install it"); !strings.Contains(err.Error(), binary) {
+		t.Errorf("Expected the missing pinned build's path, got %v", err)
+	}
+
+	if err := manager.Remove("ffprobe"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if ToolCommand("ffprobe") != "ffprobe" {
+		t.Errorf("Expected bare name after remove, got %s", ToolCommand("ffprobe"))
+	}
+}
+
+func TestToolManagerInstallMissingBinary(t *testing.T) {
+	archive := toolZip(t, "ffmpeg", "v1")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	manager := NewToolManager(t.TempDir())
+	if _, err := manager.Install(context.Background(), ToolSpec{Name: "ffprobe", URL: server.URL}); err == nil {
+		t.Error("Expected error when the archive lacks the requested binary")
+	}
+}
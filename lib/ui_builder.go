@@ -9,6 +9,7 @@ import (
 	"log/slog"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/evanw/esbuild/pkg/api"
 )
@@ -34,7 +35,40 @@ func (ub *UIBuilder) BuildReactBundle(mediaData interface{}) (string, error) {
 		return "", fmt.Errorf("failed to marshal media data: %w", err)
 	}
 
-	hash := sha256.Sum256(dataJSON)
+	preamble := fmt.Sprintf(`
+// Injected media data
+const MEDIA_DATA = %s;
+
+// Override the media data hook to use injected data
+window.__MEDIA_DATA__ = MEDIA_DATA;
+`, string(dataJSON))
+
+	return ub.buildBundle(preamble)
+}
+
+// BuildLiveBundle compiles the TypeScript React app configured to load media data from an API.
+// The UI polls the endpoint at the given interval so it reflects library changes without reloading.
+func (ub *UIBuilder) BuildLiveBundle(apiURL string, refreshInterval time.Duration) (string, error) {
+	apiConfig := map[string]interface{}{
+		"url":               apiURL,
+		"refreshIntervalMs": refreshInterval.Milliseconds(),
+	}
+	configJSON, err := json.Marshal(apiConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal API config: %w", err)
+	}
+
+	preamble := fmt.Sprintf(`
+// Injected live API configuration
+window.__MEDIA_API__ = %s;
+`, string(configJSON))
+
+	return ub.buildBundle(preamble)
+}
+
+// buildBundle compiles the TypeScript React app with the given preamble prepended to the entry point
+func (ub *UIBuilder) buildBundle(preamble string) (string, error) {
+	hash := sha256.Sum256([]byte(preamble))
 	cacheKey := hex.EncodeToString(hash[:])
 
 	ub.mutex.RLock()
@@ -46,7 +80,7 @@ func (ub *UIBuilder) BuildReactBundle(mediaData interface{}) (string, error) {
 	ub.mutex.RUnlock()
 
 	sourceFiles := make(map[string]string)
-	err = ub.readSourceFiles("resources/ui/src", sourceFiles)
+	err := ub.readSourceFiles("resources/ui/src", sourceFiles)
 	if err != nil {
 		return "", fmt.Errorf("failed to read source files: %w", err)
 	}
@@ -61,16 +95,7 @@ func (ub *UIBuilder) BuildReactBundle(mediaData interface{}) (string, error) {
 		return "", fmt.Errorf("index.tsx not found in embedded sources (available: %v)", keys(sourceFiles))
 	}
 
-	dataConstant := fmt.Sprintf(`
-// Injected media data
-const MEDIA_DATA = %s;
-
-// Override the media data hook to use injected data
-window.__MEDIA_DATA__ = MEDIA_DATA;
-
-%s`, string(dataJSON), indexContent)
-
-	sourceFiles["index.tsx"] = dataConstant
+	sourceFiles["index.tsx"] = preamble + "\n" + indexContent
 
 	result := api.Build(api.BuildOptions{
 		Bundle:            true,
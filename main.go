@@ -13,6 +13,9 @@ var rootCmd = &cobra.Command{
 	Short: "Media management and analysis tool",
 	Long: `A comprehensive tool for analyzing and managing media files.
 Supports video analysis, report generation, and various output formats.`,
+	// Errors are printed once by main, and a failed run is not a reason to show the usage text
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 func init() {
@@ -20,8 +23,10 @@ func init() {
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	cmd.FinishAudit(err)
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(cmd.ExitCode(err))
 	}
 }